@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/config"
+	"github.com/redentordev/tako-cli/pkg/engine"
+	"github.com/redentordev/tako-cli/pkg/nodeclient"
+	"github.com/redentordev/tako-cli/pkg/ssh"
+	"github.com/redentordev/tako-cli/pkg/takoapi"
+	"github.com/redentordev/tako-cli/pkg/takod"
+	"github.com/redentordev/tako-cli/pkg/takodclient"
+	"github.com/spf13/cobra"
+)
+
+var (
+	alertsServer         string
+	alertsSilenceFor     time.Duration
+	alertsSilenceComment string
+	alertsSilenceRemove  bool
+)
+
+var alertsCmd = &cobra.Command{
+	Use:          "alerts",
+	Short:        "Show threshold alert rules and their state on each node",
+	SilenceUsage: true,
+	Long: `Show the alerts: rules from tako.yaml as takod on each node evaluates them.
+
+takod samples the node and every service's replicas once a minute and checks
+each rule against its own samples, whether or not anyone is running tako
+monitor. A rule is pending once its metric reaches the threshold and firing
+once it has stayed there for the rule's duration; firing sends one
+notification, and dropping back below the threshold sends a resolve.
+
+Rules reach the nodes on tako deploy.`,
+	Example: `  # Rule states on every node of the environment
+  tako alerts
+
+  # Silence one rule during planned work
+  tako alerts silence web-memory --for 2h --comment "load test"
+
+  # Silence every rule, then lift all silences
+  tako alerts silence --for 30m
+  tako alerts silence --remove`,
+	Args: cobra.NoArgs,
+	RunE: runAlertsStatus,
+}
+
+var alertsSilenceCmd = &cobra.Command{
+	Use:          "silence [RULE]",
+	Short:        "Silence alert notifications for a while",
+	SilenceUsage: true,
+	Long: `Suppress notifications for one rule, or for every rule when RULE is
+omitted, on every node of the environment. Rules keep being evaluated while
+silenced; a rule still firing when its silence ends notifies then.
+
+--remove lifts the rule's silences (every silence without RULE) early.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runAlertsSilence,
+}
+
+func init() {
+	rootCmd.AddCommand(alertsCmd)
+	alertsCmd.AddCommand(alertsSilenceCmd)
+	alertsCmd.PersistentFlags().StringVarP(&alertsServer, "server", "s", "", "Limit to a specific node")
+	alertsSilenceCmd.Flags().DurationVar(&alertsSilenceFor, "for", time.Hour, "How long the silence lasts")
+	alertsSilenceCmd.Flags().StringVar(&alertsSilenceComment, "comment", "", "Why the rule is silenced")
+	alertsSilenceCmd.Flags().BoolVar(&alertsSilenceRemove, "remove", false, "Lift silences instead of adding one")
+	alertsSilenceCmd.MarkFlagsMutuallyExclusive("remove", "for")
+	alertsSilenceCmd.MarkFlagsMutuallyExclusive("remove", "comment")
+}
+
+func runAlertsStatus(cmd *cobra.Command, args []string) error {
+	return runAlertsCommand(cmd.Context(), "status", "", nil)
+}
+
+func runAlertsSilence(cmd *cobra.Command, args []string) error {
+	rule := ""
+	if len(args) == 1 {
+		rule = args[0]
+	}
+	request := &takod.AlertSilenceRequest{Rule: rule, Comment: alertsSilenceComment, Remove: alertsSilenceRemove}
+	action := "unsilence"
+	if !alertsSilenceRemove {
+		if alertsSilenceFor <= 0 {
+			return &engine.InvalidRequestError{Err: fmt.Errorf("--for must be a positive duration")}
+		}
+		request.Until = time.Now().Add(alertsSilenceFor).UTC().Truncate(time.Second)
+		action = "silence"
+	}
+	return runAlertsCommand(cmd.Context(), action, rule, request)
+}
+
+func runAlertsCommand(ctx context.Context, action string, rule string, silence *takod.AlertSilenceRequest) error {
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := requireTakodRuntime(cfg); err != nil {
+		return err
+	}
+	if rule != "" {
+		if _, ok := cfg.Alerts[rule]; !ok {
+			return &engine.InvalidRequestError{Err: fmt.Errorf("alert rule %q is not defined in the alerts block", rule)}
+		}
+	}
+	envName := getEnvironmentName(cfg)
+	servers, err := cfg.GetEnvironmentServers(envName)
+	if err != nil {
+		return fmt.Errorf("failed to get servers: %w", err)
+	}
+	sort.Strings(servers)
+	if alertsServer != "" {
+		if !slices.Contains(servers, alertsServer) {
+			return &engine.InvalidRequestError{Err: fmt.Errorf("server %s not found in environment %s", alertsServer, envName)}
+		}
+		servers = []string{alertsServer}
+	}
+
+	sshPool := ssh.NewPool()
+	defer sshPool.CloseAll()
+	factory, err := nodeclient.NewFactory(cfg, sshPool, takodSocketFromConfig(cfg))
+	if err != nil {
+		return err
+	}
+	defer factory.CloseIdleConnections()
+
+	result := engine.AlertsResult{
+		APIVersion:  takoapi.APIVersionCurrent,
+		Kind:        engine.KindAlertsResult,
+		Project:     cfg.Project.Name,
+		Environment: envName,
+		Action:      action,
+		Rule:        rule,
+		Nodes:       make([]engine.AlertsNodeResult, len(servers)),
+	}
+	if silence != nil {
+		silence.Project, silence.Environment = cfg.Project.Name, envName
+		if !silence.Remove {
+			until := silence.Until
+			result.Until = &until
+		}
+	}
+
+	var wg sync.WaitGroup
+	for index, serverName := range servers {
+		wg.Add(1)
+		go func(index int, serverName string) {
+			defer wg.Done()
+			node := engine.AlertsNodeResult{Server: serverName, Alerts: []takod.AlertStatus{}, Silences: []takod.AlertSilence{}}
+			if server, ok := cfg.Servers[serverName]; ok {
+				node.Host = server.Host
+			}
+			response, err := requestAlertsViaTakod(ctx, cfg, factory, serverName, envName, silence)
+			if err != nil {
+				node.Error = err.Error()
+			} else {
+				node.Alerts, node.Silences = response.Alerts, response.Silences
+			}
+			result.Nodes[index] = node
+		}(index, serverName)
+	}
+	wg.Wait()
+
+	var out io.Writer = os.Stdout
+	if machineOutputEnabled() {
+		out = os.Stderr
+	}
+	displayAlertsResult(out, result, time.Now())
+
+	failures := 0
+	for _, node := range result.Nodes {
+		if node.Error != "" {
+			failures++
+		}
+	}
+	switch {
+	case failures == len(result.Nodes) && failures > 0:
+		err = fmt.Errorf("failed to %s alerts on all %d node(s)", alertsActionVerb(action), failures)
+		result.Error = err.Error()
+	case failures > 0:
+		err = &engine.AttentionError{Err: fmt.Errorf("failed to %s alerts on %d of %d node(s)", alertsActionVerb(action), failures, len(result.Nodes))}
+		result.Error = err.Error()
+	}
+	if emitErr := emitResultDocument(result); emitErr != nil && err == nil {
+		err = emitErr
+	}
+	return err
+}
+
+func alertsActionVerb(action string) string {
+	if action == "status" {
+		return "read"
+	}
+	return action
+}
+
+func requestAlertsViaTakod(ctx context.Context, cfg *config.Config, factory *nodeclient.Factory, serverName string, envName string, silence *takod.AlertSilenceRequest) (*takod.AlertsResponse, error) {
+	client, _, err := factory.Client(ctx, serverName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	socket := takodSocketFromConfig(cfg)
+	if err := takodclient.RequireCapability(ctx, client, socket, serverName, takod.CapabilityAlertsV1, "alert rules (tako alerts)"); err != nil {
+		return nil, err
+	}
+	var output string
+	if silence != nil {
+		output, err = takodclient.RequestJSONWithContext(ctx, client, socket, "POST", takodclient.AlertSilenceEndpoint(), silence)
+	} else {
+		output, err = takodclient.RequestJSONWithContext(ctx, client, socket, "GET", takodclient.AlertsEndpoint(cfg.Project.Name, envName), nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var response takod.AlertsResponse
+	if err := decodeTakodJSON(output, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func displayAlertsResult(out io.Writer, result engine.AlertsResult, now time.Time) {
+	switch result.Action {
+	case "silence":
+		fmt.Fprintf(out, "\n🔕 Silenced %s until %s\n", alertsRuleLabel(result.Rule), result.Until.Local().Format("2006-01-02 15:04"))
+	case "unsilence":
+		fmt.Fprintf(out, "\n🔔 Lifted silences for %s\n", alertsRuleLabel(result.Rule))
+	}
+	fmt.Fprintf(out, "\n=== Alerts (%s) ===\n\n", result.Environment)
+	for _, node := range result.Nodes {
+		if node.Error != "" {
+			fmt.Fprintf(out, "❌ %s (%s): %s\n\n", node.Server, node.Host, node.Error)
+			continue
+		}
+		fmt.Fprintf(out, "%s (%s)\n", node.Server, node.Host)
+		if len(node.Alerts) == 0 {
+			fmt.Fprintf(out, "  No alert rules deployed\n\n")
+			continue
+		}
+		for _, alert := range node.Alerts {
+			fmt.Fprintf(out, "  %s %-24s %-8s %s\n", alertStateIcon(alert.State), alert.Rule, alert.State, alertConditionLabel(alert, now))
+		}
+		for _, silence := range node.Silences {
+			line := fmt.Sprintf("  🔕 %s silenced until %s", alertsRuleLabel(silence.Rule), silence.Until.Local().Format("2006-01-02 15:04"))
+			if silence.Comment != "" {
+				line += fmt.Sprintf(" (%s)", silence.Comment)
+			}
+			fmt.Fprintln(out, line)
+		}
+		fmt.Fprintln(out)
+	}
+}
+
+func alertsRuleLabel(rule string) string {
+	if rule == "" {
+		return "all rules"
+	}
+	return rule
+}
+
+func alertStateIcon(state string) string {
+	switch state {
+	case takod.AlertStateFiring:
+		return "🔴"
+	case takod.AlertStatePending:
+		return "🟡"
+	}
+	return "🟢"
+}
+
+// alertConditionLabel describes a rule as "service memory_percent 92.0% ≥
+// 90.0% for 5m, since 14:02", leaving out what does not apply.
+func alertConditionLabel(alert takod.AlertStatus, now time.Time) string {
+	var parts []string
+	if alert.Service != "" {
+		parts = append(parts, alert.Service)
+	}
+	condition := alert.Metric + " "
+	if alert.Value != nil {
+		condition += formatAlertValue(alert.Metric, *alert.Value) + " "
+	}
+	condition += "≥ " + formatAlertValue(alert.Metric, alert.Threshold)
+	if alert.DurationSeconds > 0 {
+		condition += " for " + (time.Duration(alert.DurationSeconds) * time.Second).String()
+	}
+	parts = append(parts, condition, "["+alert.Severity+"]")
+	if alert.Since != nil {
+		parts = append(parts, "since "+alert.Since.Local().Format("15:04"))
+	}
+	if alert.SilencedUntil != nil && alert.SilencedUntil.After(now) {
+		parts = append(parts, "silenced")
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatAlertValue(metric string, value float64) string {
+	switch {
+	case strings.HasSuffix(metric, "_percent"):
+		return formatHistoryPercent(value)
+	case strings.HasSuffix(metric, "_bytes"):
+		return formatHistoryBytes(value)
+	}
+	return fmt.Sprintf("%.2f", value)
+}
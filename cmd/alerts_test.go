@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/takod"
+)
+
+func TestAlertConditionLabel(t *testing.T) {
+	now := time.Date(2026, 7, 6, 12, 0, 0, 0, time.UTC)
+	value := 93.5
+	silenced := now.Add(time.Hour)
+	got := alertConditionLabel(takod.AlertStatus{Rule: "web-memory", Metric: takod.AlertMetricMemoryPercent, Service: "web", Threshold: 90, DurationSeconds: 300, Severity: takod.AlertSeverityCritical, Value: &value, SilencedUntil: &silenced}, now)
+	if want := "web memory_percent 93.5% ≥ 90.0% for 5m0s [critical] silenced"; got != want {
+		t.Fatalf("label = %q, want %q", got, want)
+	}
+
+	got = alertConditionLabel(takod.AlertStatus{Rule: "busy", Metric: takod.AlertMetricLoad1, Threshold: 4, Severity: takod.AlertSeverityWarning}, now)
+	if want := "load1 ≥ 4.00 [warning]"; got != want {
+		t.Fatalf("label = %q, want %q", got, want)
+	}
+}
@@ -27,6 +27,8 @@ var (
 	backupDelete  string
 	backupCleanup int
 	backupServer  string
+
+	backupFromStorage bool
 )
 
 var backupCmd = &cobra.Command{
@@ -51,8 +53,14 @@ Examples:
   # Restore a node-local volume from a backup
   tako backup --server node-a --volume data --restore 20240101-120000
 
+  # Restore a backup that is only left in the configured object storage
+  tako backup --server node-a --volume data --restore 20240101-120000 --from-storage
+
   # Delete old backups across the environment mesh
   tako backup --cleanup 7  # Delete backups older than 7 days
+
+  # Prove the newest backups restore (see tako backup verify --help)
+  tako backup verify
 `,
 	RunE: runBackup,
 }
@@ -66,6 +74,7 @@ func init() {
 	backupCmd.Flags().StringVar(&backupDelete, "delete", "", "Backup ID to delete")
 	backupCmd.Flags().IntVar(&backupCleanup, "cleanup", 0, "Delete backups older than N days")
 	backupCmd.Flags().StringVarP(&backupServer, "server", "s", "", "Node to run the backup operation on")
+	backupCmd.Flags().BoolVar(&backupFromStorage, "from-storage", false, "Download the backup from backup.storage when restoring one missing on the node")
 }
 
 func runBackup(cmd *cobra.Command, args []string) error {
@@ -111,6 +120,9 @@ func runBackup(cmd *cobra.Command, args []string) error {
 			return err
 		}
 	}
+	if backupFromStorage && backupRestore == "" {
+		return fmt.Errorf("--from-storage requires --restore")
+	}
 	if backupDelete != "" && backupVolume == "" {
 		return fmt.Errorf("--volume is required for delete")
 	}
@@ -130,7 +142,7 @@ func runBackup(cmd *cobra.Command, args []string) error {
 	switch {
 	case backupRestore != "":
 		serverName := targetServerNames[0]
-		return restoreBackupOnNode(cfg, runtimeFactory, envName, serverName, servers[serverName], backupVolume, backupRestore)
+		return restoreBackupOnNode(cfg, runtimeFactory, envName, serverName, servers[serverName], backupVolume, backupRestore, backupFromStorage)
 
 	case backupDelete != "":
 		return deleteBackupAcrossNodes(cfg, runtimeFactory, envName, servers, backupVolume, backupDelete)
@@ -219,17 +231,42 @@ func createBackupAcrossNodes(cfg *config.Config, factory *nodeclient.Factory, en
 	return emitBackupResult(cfg, envName, engine.BackupActionCreate, volumeName, backupID, results, err)
 }
 
-func restoreBackup(client any, cfg *config.Config, envName string, volumeName string, backupID string) error {
+func restoreBackup(client any, cfg *config.Config, envName string, serverName string, volumeName string, backupID string, fromStorage bool) error {
 	fmt.Fprintf(humanOut(), "=== Restoring volume: %s from backup %s ===\n\n", volumeName, backupID)
 	fmt.Fprintf(humanOut(), "⚠️  WARNING: This will overwrite all data in the volume!\n\n")
 
+	spec, err := backupVolumeSpecForName(cfg, envName, volumeName)
+	if err != nil {
+		return fmt.Errorf("restore failed: %w", err)
+	}
+	if err := requireConsistentBackupCapability(client, cfg, serverName, spec); err != nil {
+		return fmt.Errorf("restore failed: %w", err)
+	}
+	request := backupRequestForSpec(cfg, envName, spec, backupID)
+	request.RetentionDays = 0
+	request.Retention = nil
+	request.Storage = nil
+	if fromStorage {
+		if spec.storage == nil {
+			return fmt.Errorf("restore failed: --from-storage requires backup.storage for volume %s", volumeName)
+		}
+		if err := requireChunkedBackupCapability(client, cfg, serverName, spec); err != nil {
+			return fmt.Errorf("restore failed: %w", err)
+		}
+		if err := requireBackupTargetCapability(client, cfg, serverName, spec); err != nil {
+			return fmt.Errorf("restore failed: %w", err)
+		}
+		request.Storage = takodBackupStorageFromConfig(spec.storage)
+		request.FromStorage = true
+	}
+
 	var response map[string]bool
-	err := takodBackupRequestJSON(
+	err = takodBackupRequestJSON(
 		client,
 		cfg,
 		"POST",
 		"/v1/backups/restore",
-		backupRequest(cfg, envName, volumeName, backupID, 0),
+		request,
 		&response,
 	)
 	if err != nil {
@@ -240,7 +277,7 @@ func restoreBackup(client any, cfg *config.Config, envName string, volumeName st
 	return nil
 }
 
-func restoreBackupOnNode(cfg *config.Config, factory *nodeclient.Factory, envName string, serverName string, serverCfg config.ServerConfig, volumeName string, backupID string) error {
+func restoreBackupOnNode(cfg *config.Config, factory *nodeclient.Factory, envName string, serverName string, serverCfg config.ServerConfig, volumeName string, backupID string, fromStorage bool) error {
 	client, _, err := factory.Client(context.Background(), serverName)
 	if err != nil {
 		return fmt.Errorf("failed to connect to node %s: %w", serverName, err)
@@ -248,7 +285,7 @@ func restoreBackupOnNode(cfg *config.Config, factory *nodeclient.Factory, envNam
 	if verbose {
 		fmt.Fprintf(humanOut(), "Using node: %s (%s)\n", serverName, serverCfg.Host)
 	}
-	err = restoreBackup(client, cfg, envName, volumeName, backupID)
+	err = restoreBackup(client, cfg, envName, serverName, volumeName, backupID, fromStorage)
 	results := []backupNodeResult{{serverName: serverName, host: serverCfg.Host, err: err}}
 	return emitBackupResult(cfg, envName, engine.BackupActionRestore, volumeName, backupID, results, err)
 }
@@ -322,9 +359,11 @@ func backupAllVolumesAcrossNodes(cfg *config.Config, factory *nodeclient.Factory
 }
 
 type backupNodeActionResult struct {
-	backups []takod.BackupInfo
-	deleted int
-	skipped []string
+	backups       []takod.BackupInfo
+	deleted       int
+	skipped       []string
+	verifications []takod.BackupVerification
+	recovery      *takod.PITRRestoreResponse
 }
 
 type backupNodeAction func(serverName string, serverCfg config.ServerConfig) (backupNodeActionResult, error)
@@ -381,11 +420,13 @@ func emitBackupResult(cfg *config.Config, envName string, action string, volume
 	}
 	for _, result := range results {
 		outcome := engine.BackupNodeOutcome{
-			Server:  result.serverName,
-			Host:    result.host,
-			Backups: result.backups,
-			Deleted: result.deleted,
-			Skipped: result.skipped,
+			Server:        result.serverName,
+			Host:          result.host,
+			Backups:       result.backups,
+			Deleted:       result.deleted,
+			Skipped:       result.skipped,
+			Verifications: result.verifications,
+			Recovery:      result.recovery,
 		}
 		if result.err != nil {
 			outcome.Error = result.err.Error()
@@ -426,12 +467,28 @@ func printBackupGroups(backups []takod.BackupInfo) {
 		fmt.Fprintf(humanOut(), "  Volume: %s\n", volume)
 		for _, backup := range byVolume[volume] {
 			sizeStr := formatSize(backup.Size)
-			fmt.Fprintf(humanOut(), "    - %s  %s  %s\n", backup.ID, backup.CreatedAt.Format("2006-01-02 15:04"), sizeStr)
+			if backup.Mode != "" && backup.Mode != takod.BackupModeVolume {
+				sizeStr += "  " + backup.Mode
+			}
+			fmt.Fprintf(humanOut(), "    - %s  %s  %s%s\n", backup.ID, backup.CreatedAt.Format("2006-01-02 15:04"), sizeStr, backupVerificationLabel(backup.Verification))
 		}
 	}
 	fmt.Fprintln(humanOut())
 }
 
+// backupVerificationLabel summarizes the latest restore drill of a backup
+// for the --list output.
+func backupVerificationLabel(verification *takod.BackupVerification) string {
+	if verification == nil {
+		return ""
+	}
+	label := "  verify " + verification.Status + " " + verification.FinishedAt.Local().Format("2006-01-02 15:04")
+	if verification.Status != takod.BackupVerifyPassed && verification.Error != "" {
+		label += ": " + verification.Error
+	}
+	return label
+}
+
 func printBackupMutationResults(results []backupNodeResult, operation string, emptyMessage string) error {
 	successes := 0
 	failures := 0
@@ -451,7 +508,14 @@ func printBackupMutationResults(results []backupNodeResult, operation string, em
 			}
 			fmt.Fprintf(humanOut(), "  Created: %s%s  %s  %s\n", backup.Volume, serviceLabel, backup.ID, sizeStr)
 			if backup.Remote != nil {
-				fmt.Fprintf(humanOut(), "    Remote: %s://%s/%s\n", backup.Remote.Provider, backup.Remote.Bucket, backup.Remote.Key)
+				if backup.Remote.Bucket != "" {
+					fmt.Fprintf(humanOut(), "    Remote: %s://%s/%s\n", backup.Remote.Provider, backup.Remote.Bucket, backup.Remote.Key)
+				} else {
+					fmt.Fprintf(humanOut(), "    Remote: %s:%s/%s\n", backup.Remote.Provider, strings.TrimSuffix(backup.Remote.Endpoint, "/"), backup.Remote.Key)
+				}
+				if backup.Remote.Format == takod.BackupStorageFormatChunked {
+					fmt.Fprintf(humanOut(), "    Chunks: %d (%d new)\n", backup.Remote.Chunks, backup.Remote.NewChunks)
+				}
 			}
 			for _, warning := range backup.Warnings {
 				fmt.Fprintf(humanOut(), "    Warning: %s\n", warning)
@@ -522,7 +586,20 @@ type backupVolumeSpec struct {
 	name          string
 	service       string
 	retentionDays int
+	retention     *takod.BackupRetentionPolicy
 	storage       *config.BackupStorageConfig
+	mode          string
+	database      string
+	preBackup     string
+	postBackup    string
+	verify        *config.BackupVerifyConfig
+	pitr          bool
+}
+
+// consistent reports whether the backup runs a dump or hooks inside the
+// service container, which needs a takod that understands backup modes.
+func (s backupVolumeSpec) consistent() bool {
+	return s.mode != "" || s.preBackup != "" || s.postBackup != ""
 }
 
 func backupVolumesFromConfig(cfg *config.Config, envName string) ([]backupVolumeSpec, error) {
@@ -542,7 +619,7 @@ func backupVolumesFromConfig(cfg *config.Config, envName string) ([]backupVolume
 		service := services[serviceName]
 		for _, spec := range backupVolumeSpecsForService(serviceName, service) {
 			existing, ok := seen[spec.name]
-			if !ok || (existing.storage == nil && spec.storage != nil) {
+			if !ok || (existing.storage == nil && spec.storage != nil) || (!existing.consistent() && spec.consistent()) || (existing.verify == nil && spec.verify != nil) {
 				seen[spec.name] = spec
 			}
 		}
@@ -587,8 +664,15 @@ func backupVolumeSpecsForService(serviceName string, service config.ServiceConfi
 		}
 		spec := backupVolumeSpec{name: source, service: serviceName}
 		if service.Backup != nil && (len(backupVolumeSet) == 0 || backupVolumeSet[source]) {
-			spec.retentionDays = service.Backup.Retain
+			spec.retentionDays = service.Backup.Retain.Days
+			spec.retention = takodBackupRetentionFromConfig(service.Backup.Retain)
 			spec.storage = cloneConfigBackupStorage(service.Backup.Storage)
+			spec.mode = service.Backup.Mode
+			spec.database = service.Backup.Database
+			spec.preBackup = service.Backup.PreBackup
+			spec.postBackup = service.Backup.PostBackup
+			spec.verify = service.Backup.Verify
+			spec.pitr = service.Backup.PITR
 		}
 		specs = append(specs, spec)
 	}
@@ -622,15 +706,69 @@ func backupRequestForSpec(cfg *config.Config, envName string, volume backupVolum
 		Volume:        backupArchiveVolumeName(volume.name),
 		BackupID:      backupID,
 		RetentionDays: volume.retentionDays,
+		Retention:     volume.retention,
 		Storage:       takodBackupStorageFromConfig(volume.storage),
 	}
 	if volume.name != "" {
 		request.DockerVolume = cfg.GetVolumeName(volume.name, envName)
 		request.ExternalVolume = cfg.IsVolumeExternal(volume.name)
 	}
+	if volume.consistent() {
+		request.Service = volume.service
+		request.Mode = volume.mode
+		request.Database = volume.database
+		request.PreBackup = volume.preBackup
+		request.PostBackup = volume.postBackup
+		request.PITR = volume.pitr
+	}
 	return request
 }
 
+func requireConsistentBackupCapability(client any, cfg *config.Config, serverName string, volume backupVolumeSpec) error {
+	if !volume.consistent() {
+		return nil
+	}
+	return takodclient.RequireCapability(context.Background(), client, takodSocketFromConfig(cfg), serverName, takod.CapabilityBackupConsistentV1, "application-consistent backups (backup.mode, preBackup, postBackup)")
+}
+
+func requireChunkedBackupCapability(client any, cfg *config.Config, serverName string, volume backupVolumeSpec) error {
+	if volume.storage == nil || volume.storage.Format != config.BackupStorageFormatChunked {
+		return nil
+	}
+	return takodclient.RequireCapability(context.Background(), client, takodSocketFromConfig(cfg), serverName, takod.CapabilityBackupChunkedV1, "encrypted chunked backups (backup.storage.format: chunked)")
+}
+
+// requireBackupTargetCapability refuses sftp, filesystem, and peer storage on
+// a takod that only knows how to reach S3-compatible buckets.
+func requireBackupTargetCapability(client any, cfg *config.Config, serverName string, volume backupVolumeSpec) error {
+	if volume.storage == nil {
+		return nil
+	}
+	switch volume.storage.Provider {
+	case config.BackupStorageProviderSFTP, config.BackupStorageProviderFilesystem, config.BackupStorageProviderPeer:
+		return takodclient.RequireCapability(context.Background(), client, takodSocketFromConfig(cfg), serverName, takod.CapabilityBackupTargetsV1, "sftp, filesystem, and peer backup storage (backup.storage.provider)")
+	}
+	return nil
+}
+
+// requireBackupRetentionCapability refuses bucketed retention on a takod that
+// would fall back to its 7-day default and delete the older restore points.
+func requireBackupRetentionCapability(client any, cfg *config.Config, serverName string, volume backupVolumeSpec) error {
+	if volume.retention == nil {
+		return nil
+	}
+	return takodclient.RequireCapability(context.Background(), client, takodSocketFromConfig(cfg), serverName, takod.CapabilityBackupRetentionV1, "bucketed backup retention (backup.retain: {hourly, daily, weekly, monthly, yearly})")
+}
+
+// requireBackupPITRCapability refuses pg_basebackup on a takod that cannot
+// take one or archive the WAL between them.
+func requireBackupPITRCapability(client any, cfg *config.Config, serverName string, volume backupVolumeSpec) error {
+	if !volume.pitr && volume.mode != config.BackupModePgBaseBackup {
+		return nil
+	}
+	return takodclient.RequireCapability(context.Background(), client, takodSocketFromConfig(cfg), serverName, takod.CapabilityBackupPITRV1, "point-in-time recovery (backup.pitr)")
+}
+
 func readBackupsFromNode(cfg *config.Config, factory *nodeclient.Factory, serverName string, serverCfg config.ServerConfig, envName string, volumeName string) ([]takod.BackupInfo, error) {
 	client, _, err := factory.Client(context.Background(), serverName)
 	if err != nil {
@@ -657,6 +795,21 @@ func createBackupOnNode(cfg *config.Config, factory *nodeclient.Factory, serverN
 	if err != nil {
 		return takod.BackupInfo{}, err
 	}
+	if err := requireConsistentBackupCapability(client, cfg, serverName, volume); err != nil {
+		return takod.BackupInfo{}, err
+	}
+	if err := requireChunkedBackupCapability(client, cfg, serverName, volume); err != nil {
+		return takod.BackupInfo{}, err
+	}
+	if err := requireBackupTargetCapability(client, cfg, serverName, volume); err != nil {
+		return takod.BackupInfo{}, err
+	}
+	if err := requireBackupRetentionCapability(client, cfg, serverName, volume); err != nil {
+		return takod.BackupInfo{}, err
+	}
+	if err := requireBackupPITRCapability(client, cfg, serverName, volume); err != nil {
+		return takod.BackupInfo{}, err
+	}
 
 	var info takod.BackupInfo
 	err = takodBackupRequestJSON(
@@ -763,6 +916,19 @@ func cloneConfigBackupStorage(storage *config.BackupStorageConfig) *config.Backu
 	return &copied
 }
 
+func takodBackupRetentionFromConfig(retain config.BackupRetention) *takod.BackupRetentionPolicy {
+	if !retain.IsGFS() {
+		return nil
+	}
+	return &takod.BackupRetentionPolicy{
+		Hourly:  retain.Hourly,
+		Daily:   retain.Daily,
+		Weekly:  retain.Weekly,
+		Monthly: retain.Monthly,
+		Yearly:  retain.Yearly,
+	}
+}
+
 func takodBackupStorageFromConfig(storage *config.BackupStorageConfig) *takod.BackupStorageConfig {
 	if storage == nil {
 		return nil
@@ -777,6 +943,16 @@ func takodBackupStorageFromConfig(storage *config.BackupStorageConfig) *takod.Ba
 		SecretAccessKey: storage.SecretAccessKey,
 		SessionToken:    storage.SessionToken,
 		ForcePathStyle:  storage.ForcePathStyle,
+		Format:          storage.Format,
+		EncryptionKey:   storage.EncryptionKey,
+		Path:            storage.Path,
+		Host:            storage.Host,
+		Port:            storage.Port,
+		User:            storage.User,
+		Password:        storage.Password,
+		PrivateKey:      storage.PrivateKey,
+		HostKey:         storage.HostKey,
+		Node:            storage.Node,
 	}
 }
 
@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/config"
+	"github.com/redentordev/tako-cli/pkg/engine"
+	"github.com/redentordev/tako-cli/pkg/nodeclient"
+	"github.com/redentordev/tako-cli/pkg/ssh"
+	"github.com/redentordev/tako-cli/pkg/takod"
+	"github.com/redentordev/tako-cli/pkg/takodclient"
+	"github.com/spf13/cobra"
+)
+
+// defaultBackupRestoreTimeout mirrors takod's point-in-time recovery timeout.
+const defaultBackupRestoreTimeout = time.Hour
+
+var (
+	backupRestoreVolume  string
+	backupRestoreServer  string
+	backupRestoreAt      string
+	backupRestoreInto    string
+	backupRestoreTimeout time.Duration
+)
+
+// backupRestoreTimeLayouts are the --at forms accepted besides RFC 3339.
+// Layouts without a zone are read as UTC.
+var backupRestoreTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+var backupRestoreCmd = &cobra.Command{
+	Use:          "restore",
+	Short:        "Recover a Postgres volume to a point in time",
+	SilenceUsage: true,
+	Long: `Recover a Postgres volume to a point in time.
+
+Volumes with backup.pitr keep pg_basebackup base backups and the WAL archived
+between them in backup.storage. takod replays the newest base backup taken
+before --at forward through the archived WAL into a new volume, using a copy
+of the service image without network access. The running service and its
+volume are never touched; point the service at the recovered volume and
+redeploy once it looks right.
+
+--at accepts RFC 3339 (2026-10-15T14:03:00Z) or a plain date and time, which
+is read as UTC.
+
+Examples:
+  # Recover pgdata to just before a bad migration
+  tako backup restore --volume pgdata --at 2026-10-15T14:03:00Z
+
+  # Choose the node and the new volume's name
+  tako backup restore --server node-a --volume pgdata --at "2026-10-15 14:03" --into pgdata-before-migration
+`,
+	RunE: runBackupRestore,
+}
+
+func init() {
+	backupCmd.AddCommand(backupRestoreCmd)
+	backupRestoreCmd.Flags().StringVar(&backupRestoreVolume, "volume", "", "Volume to recover (must configure backup.pitr)")
+	backupRestoreCmd.Flags().StringVar(&backupRestoreAt, "at", "", "Time to recover to (RFC 3339, or UTC date and time)")
+	backupRestoreCmd.Flags().StringVarP(&backupRestoreServer, "server", "s", "", "Node to recover on")
+	backupRestoreCmd.Flags().StringVar(&backupRestoreInto, "into", "", "Volume name to recover into (default: <volume>-pitr-<time>)")
+	backupRestoreCmd.Flags().DurationVar(&backupRestoreTimeout, "timeout", defaultBackupRestoreTimeout, "How long recovery may take")
+}
+
+func runBackupRestore(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := requireTakodRuntime(cfg); err != nil {
+		return err
+	}
+	if backupRestoreVolume == "" {
+		return fmt.Errorf("--volume is required")
+	}
+	if backupRestoreAt == "" {
+		return fmt.Errorf("--at is required")
+	}
+	target, err := parseBackupRestoreTime(backupRestoreAt)
+	if err != nil {
+		return err
+	}
+	if backupRestoreTimeout <= 0 {
+		return fmt.Errorf("--timeout must be positive")
+	}
+
+	envName := getEnvironmentName(cfg)
+	volume, err := backupVolumeSpecForName(cfg, envName, backupRestoreVolume)
+	if err != nil {
+		return err
+	}
+	if !volume.pitr || volume.storage == nil {
+		return fmt.Errorf("volume %s does not configure backup.pitr", backupRestoreVolume)
+	}
+	into := strings.TrimSpace(backupRestoreInto)
+	if into == "" {
+		into = fmt.Sprintf("%s-pitr-%s", volume.name, target.UTC().Format("200601021504"))
+	}
+	if into == volume.name {
+		return fmt.Errorf("--into must name a new volume, not %s", volume.name)
+	}
+
+	servers, err := resolveEnvironmentServerSet(cfg, envName, backupRestoreServer)
+	if err != nil {
+		return err
+	}
+	servers, targetServerNames, err := schedulableMutationServerSet(cfg, envName, servers, true)
+	if err != nil {
+		return err
+	}
+	if err := ensureSingleBackupRestoreTarget(targetServerNames, backupRestoreServer); err != nil {
+		return err
+	}
+	sshPool := ssh.NewPool()
+	defer sshPool.CloseAll()
+	runtimeFactory, err := nodeclient.NewFactory(cfg, sshPool, takodSocketFromConfig(cfg))
+	if err != nil {
+		return err
+	}
+	defer runtimeFactory.CloseIdleConnections()
+
+	leaseSet, err := acquireRemoteOperationLeases(sshPool, cfg, envName, targetServerNames, "backup")
+	if err != nil {
+		return err
+	}
+	defer leaseSet.Release(verbose)
+	if verbose {
+		fmt.Fprintf(humanOut(), "→ Acquired remote backup leases: %s\n", leaseSet.Summary())
+	}
+
+	serverName := targetServerNames[0]
+	serverCfg := servers[serverName]
+	fmt.Fprintf(humanOut(), "=== Recovering %s to %s ===\n\n", volume.name, target.UTC().Format(time.RFC3339))
+	request := backupRestoreRequestForSpec(cfg, envName, volume, into, target, backupRestoreTimeout)
+	recovery, err := restorePointInTimeOnNode(cfg, runtimeFactory, serverName, request, backupRestoreTimeout)
+	results := []backupNodeResult{{serverName: serverName, host: serverCfg.Host, backupNodeActionResult: backupNodeActionResult{recovery: recovery}, err: err}}
+	if err == nil {
+		printBackupRestoreResult(serverName, volume.name, into, recovery)
+	}
+	return emitBackupResult(cfg, envName, engine.BackupActionRestore, volume.name, "", results, err)
+}
+
+// parseBackupRestoreTime reads --at, treating a time without a zone as UTC.
+func parseBackupRestoreTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range backupRestoreTimeLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --at %q: use RFC 3339 (2026-10-15T14:03:00Z) or a UTC date and time (2026-10-15 14:03)", value)
+}
+
+func backupRestoreRequestForSpec(cfg *config.Config, envName string, volume backupVolumeSpec, into string, target time.Time, timeout time.Duration) takod.PITRRestoreRequest {
+	return takod.PITRRestoreRequest{
+		Project:        cfg.Project.Name,
+		Environment:    envName,
+		Service:        volume.service,
+		Volume:         backupArchiveVolumeName(volume.name),
+		DockerVolume:   cfg.GetVolumeName(volume.name, envName),
+		TargetVolume:   cfg.GetVolumeName(into, envName),
+		Target:         target,
+		Storage:        takodBackupStorageFromConfig(volume.storage),
+		TimeoutSeconds: int(timeout / time.Second),
+	}
+}
+
+func restorePointInTimeOnNode(cfg *config.Config, factory *nodeclient.Factory, serverName string, request takod.PITRRestoreRequest, timeout time.Duration) (*takod.PITRRestoreResponse, error) {
+	client, _, err := factory.Client(context.Background(), serverName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to node %s: %w", serverName, err)
+	}
+	if err := takodclient.RequireCapability(context.Background(), client, takodSocketFromConfig(cfg), serverName, takod.CapabilityBackupPITRV1, "point-in-time recovery (tako backup restore)"); err != nil {
+		return nil, err
+	}
+	output, err := takodclient.RequestJSONWithTimeout(client, takodSocketFromConfig(cfg), "POST", "/v1/backups/pitr-restore", request, timeout+time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	var recovery takod.PITRRestoreResponse
+	if err := decodeTakodJSON(output, &recovery); err != nil {
+		return nil, err
+	}
+	return &recovery, nil
+}
+
+func printBackupRestoreResult(serverName string, volumeName string, into string, recovery *takod.PITRRestoreResponse) {
+	fmt.Fprintf(humanOut(), "Node: %s\n", serverName)
+	fmt.Fprintf(humanOut(), "  Base backup: %s\n", recovery.BaseBackupID)
+	fmt.Fprintf(humanOut(), "  WAL segments replayed: %d\n", recovery.WALSegments)
+	for _, warning := range recovery.Warnings {
+		fmt.Fprintf(humanOut(), "  Warning: %s\n", warning)
+	}
+	fmt.Fprintf(humanOut(), "\n✓ Recovered %s into volume %s (%s)\n", volumeName, into, recovery.Volume)
+	fmt.Fprintf(humanOut(), "  To switch over, mount %s in place of %s in the service's volumes and run tako deploy.\n", into, volumeName)
+}
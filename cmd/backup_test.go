@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -154,7 +155,7 @@ func TestBackupVolumesFromConfigCarriesServiceBackupStorage(t *testing.T) {
 						Volumes: []string{"pgdata:/var/lib/postgresql/data", "cache:/cache"},
 						Backup: &config.BackupConfig{
 							Schedule: "@daily",
-							Retain:   14,
+							Retain:   config.BackupRetention{Days: 14},
 							Volumes:  []string{"pgdata"},
 							Storage: &config.BackupStorageConfig{
 								Provider:        config.BackupStorageProviderR2,
@@ -253,6 +254,166 @@ func TestBackupRequestForSpecIncludesStorage(t *testing.T) {
 	}
 }
 
+func TestBackupRequestForSpecCarriesConsistentBackupMode(t *testing.T) {
+	cfg := &config.Config{
+		Project: config.ProjectConfig{Name: "demo"},
+		Environments: map[string]config.EnvironmentConfig{
+			"production": {
+				Services: map[string]config.ServiceConfig{
+					"postgres": {
+						Volumes: []string{"pgdata:/var/lib/postgresql/data"},
+						Backup:  &config.BackupConfig{Schedule: "@daily", Mode: config.BackupModePgDump, Database: "app", PreBackup: "sync"},
+					},
+					"web": {Volumes: []string{"uploads:/uploads"}},
+				},
+			},
+		},
+	}
+
+	spec, err := backupVolumeSpecForName(cfg, "production", "pgdata")
+	if err != nil {
+		t.Fatalf("backupVolumeSpecForName returned error: %v", err)
+	}
+	got := backupRequestForSpec(cfg, "production", spec, "20261016-020000")
+	if got.Service != "postgres" || got.Mode != takod.BackupModePgDump || got.Database != "app" || got.PreBackup != "sync" {
+		t.Fatalf("request = %#v, want pg_dump mode in the postgres container", got)
+	}
+
+	spec, err = backupVolumeSpecForName(cfg, "production", "uploads")
+	if err != nil {
+		t.Fatalf("backupVolumeSpecForName returned error: %v", err)
+	}
+	if got := backupRequestForSpec(cfg, "production", spec, "20261016-020000"); got.Service != "" || got.Mode != "" {
+		t.Fatalf("plain volume request = %#v, want no service container", got)
+	}
+}
+
+func TestBackupVerifyVolumesDrillConfiguredVolumes(t *testing.T) {
+	cfg := &config.Config{
+		Project: config.ProjectConfig{Name: "demo"},
+		Environments: map[string]config.EnvironmentConfig{
+			"production": {
+				Services: map[string]config.ServiceConfig{
+					"postgres": {
+						Volumes: []string{"pgdata:/var/lib/postgresql/data"},
+						Backup: &config.BackupConfig{Schedule: "@daily", Mode: config.BackupModePgDump, Database: "app", Verify: &config.BackupVerifyConfig{
+							Schedule: "@weekly",
+							Check:    "pg_isready",
+							Timeout:  "20m",
+						}},
+					},
+					"web": {Volumes: []string{"uploads:/uploads"}},
+				},
+			},
+		},
+	}
+
+	volumes, err := backupVerifyVolumes(cfg, "production", "")
+	if err != nil {
+		t.Fatalf("backupVerifyVolumes returned error: %v", err)
+	}
+	if len(volumes) != 1 || volumes[0].name != "pgdata" {
+		t.Fatalf("volumes = %#v, want only the volume with backup.verify", volumes)
+	}
+	request, timeout, err := backupVerifyRequestForSpec(cfg, "production", volumes[0], "")
+	if err != nil {
+		t.Fatalf("backupVerifyRequestForSpec returned error: %v", err)
+	}
+	if !request.Boot || request.Check != "pg_isready" || request.Service != "postgres" || request.Database != "app" || request.TimeoutSeconds != 1200 || timeout != 20*time.Minute {
+		t.Fatalf("request = %#v timeout = %s, want a booted pg drill", request, timeout)
+	}
+
+	volumes, err = backupVerifyVolumes(cfg, "production", "uploads")
+	if err != nil {
+		t.Fatalf("backupVerifyVolumes returned error: %v", err)
+	}
+	request, _, err = backupVerifyRequestForSpec(cfg, "production", volumes[0], "20261016-020000")
+	if err != nil || request.Boot || request.Service != "" || request.BackupID != "20261016-020000" {
+		t.Fatalf("plain volume request = %#v, %v; want a restore-only drill", request, err)
+	}
+
+	delete(cfg.Environments["production"].Services, "postgres")
+	if _, err := backupVerifyVolumes(cfg, "production", ""); err == nil || !strings.Contains(err.Error(), "no volumes configure backup.verify") {
+		t.Fatalf("backupVerifyVolumes without verify = %v", err)
+	}
+}
+
+func TestParseBackupRestoreTime(t *testing.T) {
+	want := time.Date(2026, 10, 15, 14, 3, 0, 0, time.UTC)
+	for _, value := range []string{
+		"2026-10-15T14:03:00Z",
+		"2026-10-15T22:03:00+08:00",
+		"2026-10-15T14:03Z",
+		"2026-10-15T14:03",
+		"2026-10-15 14:03:00",
+		" 2026-10-15 14:03 ",
+	} {
+		got, err := parseBackupRestoreTime(value)
+		if err != nil || !got.Equal(want) || got.Location() != time.UTC {
+			t.Fatalf("parseBackupRestoreTime(%q) = %s, %v; want %s", value, got, err, want)
+		}
+	}
+	if _, err := parseBackupRestoreTime("yesterday"); err == nil || !strings.Contains(err.Error(), "invalid --at") {
+		t.Fatalf("parseBackupRestoreTime(yesterday) = %v", err)
+	}
+}
+
+func TestBackupRestoreRequestForSpecTargetsNewVolume(t *testing.T) {
+	storage := &config.BackupStorageConfig{Provider: config.BackupStorageProviderFilesystem, Path: "/mnt/nas"}
+	cfg := &config.Config{
+		Project: config.ProjectConfig{Name: "demo"},
+		Environments: map[string]config.EnvironmentConfig{
+			"production": {
+				Services: map[string]config.ServiceConfig{
+					"postgres": {
+						Persistent: true,
+						Volumes:    []string{"pgdata:/var/lib/postgresql/data"},
+						Backup:     &config.BackupConfig{Schedule: "@daily", Mode: config.BackupModePgBaseBackup, PITR: true, Storage: storage},
+					},
+				},
+			},
+		},
+	}
+
+	spec, err := backupVolumeSpecForName(cfg, "production", "pgdata")
+	if err != nil {
+		t.Fatalf("backupVolumeSpecForName returned error: %v", err)
+	}
+	if backup := backupRequestForSpec(cfg, "production", spec, "20261016-020000"); !backup.PITR || backup.Mode != takod.BackupModePgBaseBackup {
+		t.Fatalf("backup request = %#v, want pitr base backup", backup)
+	}
+	target := time.Date(2026, 10, 15, 14, 3, 0, 0, time.UTC)
+	request := backupRestoreRequestForSpec(cfg, "production", spec, "pgdata-pitr-202610151403", target, time.Hour)
+	if request.Service != "postgres" || request.Volume != "pgdata" || request.DockerVolume != cfg.GetVolumeName("pgdata", "production") {
+		t.Fatalf("request = %#v, want the postgres service volume", request)
+	}
+	if request.TargetVolume != cfg.GetVolumeName("pgdata-pitr-202610151403", "production") || !request.Target.Equal(target) || request.TimeoutSeconds != 3600 {
+		t.Fatalf("request = %#v, want a new target volume", request)
+	}
+	if request.Storage == nil || request.Storage.Path != "/mnt/nas" {
+		t.Fatalf("storage = %#v", request.Storage)
+	}
+}
+
+func TestPrintBackupVerifyResultsFailsOnFailedDrill(t *testing.T) {
+	results := []backupNodeResult{{
+		serverName: "node-a",
+		backupNodeActionResult: backupNodeActionResult{
+			verifications: []takod.BackupVerification{
+				{Volume: "pgdata", BackupID: "20261016-020000", Status: takod.BackupVerifyPassed, Booted: true, Checked: true},
+				{Volume: "uploads", BackupID: "20261016-020000", Status: takod.BackupVerifyFailed, Error: "backup did not restore"},
+			},
+		},
+	}}
+	if err := printBackupVerifyResults(results); err == nil || !strings.Contains(err.Error(), "1 restore drill(s) failed") {
+		t.Fatalf("printBackupVerifyResults = %v", err)
+	}
+	results[0].verifications = results[0].verifications[:1]
+	if err := printBackupVerifyResults(results); err != nil {
+		t.Fatalf("printBackupVerifyResults returned error: %v", err)
+	}
+}
+
 func TestConnectBackupNodeUsesProvidedPool(t *testing.T) {
 	provider := &fakeSSHClientProvider{}
 	server := config.ServerConfig{
@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/config"
+	"github.com/redentordev/tako-cli/pkg/engine"
+	"github.com/redentordev/tako-cli/pkg/nodeclient"
+	"github.com/redentordev/tako-cli/pkg/ssh"
+	"github.com/redentordev/tako-cli/pkg/takod"
+	"github.com/redentordev/tako-cli/pkg/takodclient"
+	"github.com/spf13/cobra"
+)
+
+// defaultBackupVerifyTimeout mirrors takod's drill timeout when backup.verify
+// does not set one.
+const defaultBackupVerifyTimeout = 10 * time.Minute
+
+var (
+	backupVerifyVolume string
+	backupVerifyServer string
+	backupVerifyID     string
+)
+
+var backupVerifyCmd = &cobra.Command{
+	Use:          "verify",
+	Short:        "Run a restore drill against the newest backup",
+	SilenceUsage: true,
+	Long: `Run a restore drill against the newest backup.
+
+takod restores the backup into a throwaway volume on each node that has it.
+Volumes with backup.verify.boot also start the service image against the
+restored data without network access and run backup.verify.check. The volume
+itself is never touched, and each outcome is shown next to the backup in
+tako backup --list.
+
+Examples:
+  # Drill every volume that configures backup.verify
+  tako backup verify
+
+  # Drill one volume on one node
+  tako backup verify --server node-a --volume pgdata
+
+  # Drill a specific backup instead of the newest
+  tako backup verify --volume pgdata --backup 20240101-120000
+`,
+	RunE: runBackupVerify,
+}
+
+func init() {
+	backupCmd.AddCommand(backupVerifyCmd)
+	backupVerifyCmd.Flags().StringVar(&backupVerifyVolume, "volume", "", "Volume to verify (default: every volume with backup.verify)")
+	backupVerifyCmd.Flags().StringVarP(&backupVerifyServer, "server", "s", "", "Node to run the drill on")
+	backupVerifyCmd.Flags().StringVar(&backupVerifyID, "backup", "", "Backup ID to verify (default: newest)")
+}
+
+func runBackupVerify(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := requireTakodRuntime(cfg); err != nil {
+		return err
+	}
+	if backupVerifyID != "" && backupVerifyVolume == "" {
+		return fmt.Errorf("--volume is required with --backup")
+	}
+
+	envName := getEnvironmentName(cfg)
+	volumes, err := backupVerifyVolumes(cfg, envName, backupVerifyVolume)
+	if err != nil {
+		return err
+	}
+
+	servers, err := resolveEnvironmentServerSet(cfg, envName, backupVerifyServer)
+	if err != nil {
+		return err
+	}
+	servers, targetServerNames, err := schedulableMutationServerSet(cfg, envName, servers, true)
+	if err != nil {
+		return err
+	}
+	if len(targetServerNames) == 0 {
+		return fmt.Errorf("no servers configured for environment %s", envName)
+	}
+	sshPool := ssh.NewPool()
+	defer sshPool.CloseAll()
+	runtimeFactory, err := nodeclient.NewFactory(cfg, sshPool, takodSocketFromConfig(cfg))
+	if err != nil {
+		return err
+	}
+	defer runtimeFactory.CloseIdleConnections()
+
+	leaseSet, err := acquireRemoteOperationLeases(sshPool, cfg, envName, targetServerNames, "backup")
+	if err != nil {
+		return err
+	}
+	defer leaseSet.Release(verbose)
+	if verbose {
+		fmt.Fprintf(humanOut(), "→ Acquired remote backup leases: %s\n", leaseSet.Summary())
+	}
+
+	return verifyBackupsAcrossNodes(cfg, runtimeFactory, envName, servers, volumes, backupVerifyID)
+}
+
+// backupVerifyVolumes picks the volumes to drill: the named one, even without
+// backup.verify (a plain restore drill), or every volume that configures it.
+func backupVerifyVolumes(cfg *config.Config, envName string, volumeName string) ([]backupVolumeSpec, error) {
+	if volumeName != "" {
+		spec, err := backupVolumeSpecForName(cfg, envName, volumeName)
+		if err != nil {
+			return nil, err
+		}
+		return []backupVolumeSpec{spec}, nil
+	}
+	all, err := backupVolumesFromConfig(cfg, envName)
+	if err != nil {
+		return nil, err
+	}
+	var volumes []backupVolumeSpec
+	for _, volume := range all {
+		if volume.verify != nil {
+			volumes = append(volumes, volume)
+		}
+	}
+	if len(volumes) == 0 {
+		return nil, fmt.Errorf("no volumes configure backup.verify; pass --volume to drill one")
+	}
+	return volumes, nil
+}
+
+func verifyBackupsAcrossNodes(cfg *config.Config, factory *nodeclient.Factory, envName string, servers map[string]config.ServerConfig, volumes []backupVolumeSpec, backupID string) error {
+	fmt.Fprintf(humanOut(), "=== Verifying backups ===\n\n")
+
+	results := collectBackupNodes(servers, func(serverName string, serverCfg config.ServerConfig) (backupNodeActionResult, error) {
+		var payload backupNodeActionResult
+		var failures []string
+		for _, volume := range volumes {
+			verification, err := verifyBackupOnNode(cfg, factory, serverName, envName, volume, backupID)
+			if backupVerifyMissing(err) {
+				payload.skipped = append(payload.skipped, fmt.Sprintf("%s: no backup on node", volume.name))
+				continue
+			}
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", volume.name, err))
+				continue
+			}
+			verification.Volume = volume.name
+			payload.verifications = append(payload.verifications, verification)
+		}
+		if len(failures) > 0 {
+			return payload, errors.New(strings.Join(failures, "; "))
+		}
+		return payload, nil
+	})
+
+	err := printBackupVerifyResults(results)
+	volumeName := ""
+	if len(volumes) == 1 {
+		volumeName = volumes[0].name
+	}
+	return emitBackupResult(cfg, envName, engine.BackupActionVerify, volumeName, backupID, results, err)
+}
+
+func verifyBackupOnNode(cfg *config.Config, factory *nodeclient.Factory, serverName string, envName string, volume backupVolumeSpec, backupID string) (takod.BackupVerification, error) {
+	client, _, err := factory.Client(context.Background(), serverName)
+	if err != nil {
+		return takod.BackupVerification{}, err
+	}
+	if err := takodclient.RequireCapability(context.Background(), client, takodSocketFromConfig(cfg), serverName, takod.CapabilityBackupVerifyV1, "backup restore drills (tako backup verify)"); err != nil {
+		return takod.BackupVerification{}, err
+	}
+
+	request, timeout, err := backupVerifyRequestForSpec(cfg, envName, volume, backupID)
+	if err != nil {
+		return takod.BackupVerification{}, err
+	}
+	output, err := takodclient.RequestJSONWithTimeout(client, takodSocketFromConfig(cfg), "POST", "/v1/backups/verify", request, timeout+time.Minute)
+	if err != nil {
+		return takod.BackupVerification{}, err
+	}
+	var verification takod.BackupVerification
+	if err := decodeTakodJSON(output, &verification); err != nil {
+		return takod.BackupVerification{}, err
+	}
+	return verification, nil
+}
+
+// backupVerifyRequestForSpec builds the drill request and the time takod may
+// spend on it.
+func backupVerifyRequestForSpec(cfg *config.Config, envName string, volume backupVolumeSpec, backupID string) (takod.BackupVerifyRequest, time.Duration, error) {
+	request := takod.BackupVerifyRequest{
+		Project:      cfg.Project.Name,
+		Environment:  envName,
+		Volume:       backupArchiveVolumeName(volume.name),
+		DockerVolume: cfg.GetVolumeName(volume.name, envName),
+		BackupID:     backupID,
+		Storage:      takodBackupStorageFromConfig(volume.storage),
+	}
+	timeout := defaultBackupVerifyTimeout
+	if volume.verify == nil {
+		return request, timeout, nil
+	}
+	if volume.verify.Timeout != "" {
+		parsed, err := time.ParseDuration(volume.verify.Timeout)
+		if err != nil {
+			return takod.BackupVerifyRequest{}, 0, fmt.Errorf("invalid backup.verify.timeout for %s: %w", volume.name, err)
+		}
+		timeout = parsed
+		request.TimeoutSeconds = int(parsed / time.Second)
+	}
+	request.Boot = volume.verify.Boot || volume.verify.Check != ""
+	request.Check = volume.verify.Check
+	if request.Boot {
+		request.Service = volume.service
+		request.Database = volume.database
+	}
+	return request, timeout, nil
+}
+
+func backupVerifyMissing(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "no backup of volume")
+}
+
+func printBackupVerifyResults(results []backupNodeResult) error {
+	passed := 0
+	failed := 0
+	errored := 0
+	skipped := 0
+	for _, result := range results {
+		fmt.Fprintf(humanOut(), "Node: %s (%s)\n", result.serverName, result.host)
+		for _, message := range result.skipped {
+			skipped++
+			fmt.Fprintf(humanOut(), "  Skipped: %s\n", message)
+		}
+		for _, verification := range result.verifications {
+			if verification.Status == takod.BackupVerifyPassed {
+				passed++
+				fmt.Fprintf(humanOut(), "  Passed: %s  %s%s\n", verification.Volume, verification.BackupID, backupVerificationDetail(verification))
+				continue
+			}
+			failed++
+			fmt.Fprintf(humanOut(), "  Failed: %s  %s\n    %s\n", verification.Volume, verification.BackupID, verification.Error)
+		}
+		if result.err != nil {
+			errored++
+			fmt.Fprintf(humanOut(), "  Failed: %v\n", result.err)
+		}
+		fmt.Fprintln(humanOut())
+	}
+
+	switch {
+	case failed > 0:
+		return fmt.Errorf("%d restore drill(s) failed", failed)
+	case errored > 0:
+		return fmt.Errorf("verify completed with %d error(s)", errored)
+	case passed == 0 && skipped > 0:
+		return fmt.Errorf("no target node had a backup to verify")
+	case passed == 0:
+		return fmt.Errorf("no target nodes selected for verify")
+	}
+	fmt.Fprintf(humanOut(), "✓ %d restore drill(s) passed\n", passed)
+	return nil
+}
+
+// backupVerificationDetail describes how far a passed drill went.
+func backupVerificationDetail(verification takod.BackupVerification) string {
+	switch {
+	case verification.Checked:
+		return "  (restored, booted, check passed)"
+	case verification.Booted:
+		return "  (restored, booted)"
+	default:
+		return "  (restored)"
+	}
+}
@@ -132,6 +132,16 @@ func runConfigExplain(cmd *cobra.Command, args []string) error {
 			if service.Proxy.DynamicDomains != nil && service.Proxy.DynamicDomains.IsEnabled() {
 				fmt.Fprintf(out, "    proxy.dynamicDomains.ask: %s (%s)\n", service.Proxy.DynamicDomains.Ask, explainStringSource(rawServiceProxyDynamicAsk(rawService)))
 			}
+			if limit := service.Proxy.RateLimit; limit != nil {
+				scope := "all paths"
+				if len(limit.Paths) > 0 {
+					scope = strings.Join(limit.Paths, " ")
+				}
+				fmt.Fprintf(out, "    proxy.rateLimit: %d requests per %s by %s on %s (config)\n", limit.Requests, limit.Window, limit.Key, scope)
+			}
+			if service.Proxy.MaxBodySize != "" {
+				fmt.Fprintf(out, "    proxy.maxBodySize: %s (%d bytes, %s)\n", service.Proxy.MaxBodySize, service.Proxy.MaxBodyBytes(), explainStringSource(rawServiceProxyMaxBodySize(rawService)))
+			}
 		}
 	}
 
@@ -248,6 +258,13 @@ func rawServiceProxyVisibility(service takoconfig.ServiceConfig) string {
 	return service.Proxy.Visibility
 }
 
+func rawServiceProxyMaxBodySize(service takoconfig.ServiceConfig) string {
+	if service.Proxy == nil {
+		return ""
+	}
+	return service.Proxy.MaxBodySize
+}
+
 func rawServiceProxyDynamicAsk(service takoconfig.ServiceConfig) string {
 	if service.Proxy == nil || service.Proxy.DynamicDomains == nil {
 		return ""
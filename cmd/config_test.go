@@ -47,6 +47,11 @@ environments:
         proxy:
           domain: app.example.com
           cdn: cloudflare
+          maxBodySize: 10mb
+          rateLimit:
+            requests: 5
+            window: 1m
+            paths: [/login]
 `)
 	if err := os.WriteFile(filepath.Join(root, "tako.yaml"), configData, 0600); err != nil {
 		t.Fatalf("failed to write config: %v", err)
@@ -79,6 +84,8 @@ environments:
 		"deploy.strategy: recreate (default)",
 		"proxy.domain: app.example.com (config)",
 		"proxy.cdn: cloudflare (config)",
+		"proxy.rateLimit: 5 requests per 1m0s by ip on /login (config)",
+		"proxy.maxBodySize: 10mb (10485760 bytes, config)",
 	} {
 		if !strings.Contains(out.String(), want) {
 			t.Fatalf("output = %q, want %q", out.String(), want)
@@ -115,6 +115,7 @@ func runDeploy(cmd *cobra.Command, args []string) error {
 	for _, warning := range config.ValidationWarnings(cfg) {
 		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s\n", warning.Message)
 	}
+	initCommandTracing(cfg, getEnvironmentName(cfg))
 
 	request := engine.DeployRequest{
 		Config:          cfg,
@@ -1237,7 +1237,7 @@ func checkSecrets(record func(checkResult), cfg *config.Config, envName string)
 	}
 
 	// Validate required secrets
-	mgr, err := secrets.NewManager(envName)
+	mgr, err := secrets.NewManagerForConfig(cfg, envName)
 	if err != nil {
 		record(checkResult{"WARN", fmt.Sprintf("Secrets manager: %v", err), ""})
 		return
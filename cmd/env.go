@@ -524,6 +524,16 @@ type envBundleUploadResult struct {
 }
 
 func uploadEnvBundleToMesh(factory *nodeclient.Factory, cfg *config.Config, serverNames []string, request takod.EnvBundleRequest) (int, []string) {
+	return uploadBundleToMesh(factory, cfg, serverNames, request, uploadEnvBundleToServerFunc)
+}
+
+type bundleUploadFunc func(*nodeclient.Factory, *config.Config, string, config.ServerConfig, takod.EnvBundleRequest) error
+
+type bundleDownloadFunc func(*nodeclient.Factory, *config.Config, string, string, config.ServerConfig) (*takod.EnvBundleResponse, error)
+
+// uploadBundleToMesh writes request to every server in parallel, returning
+// the number that accepted it and an error line for each that did not.
+func uploadBundleToMesh(factory *nodeclient.Factory, cfg *config.Config, serverNames []string, request takod.EnvBundleRequest, upload bundleUploadFunc) (int, []string) {
 	resultCh := make(chan envBundleUploadResult, len(serverNames))
 	var wg sync.WaitGroup
 
@@ -544,7 +554,7 @@ func uploadEnvBundleToMesh(factory *nodeclient.Factory, cfg *config.Config, serv
 			resultCh <- envBundleUploadResult{
 				index:      index,
 				serverName: serverName,
-				err:        uploadEnvBundleToServerFunc(factory, cfg, serverName, serverCfg, request),
+				err:        upload(factory, cfg, serverName, serverCfg, request),
 			}
 		}(index, serverName, serverCfg)
 	}
@@ -583,6 +593,12 @@ type envBundleDownloadResult struct {
 }
 
 func downloadEnvBundleFromMesh(factory *nodeclient.Factory, cfg *config.Config, envName string) (*takod.EnvBundleResponse, string, error) {
+	return downloadBundleFromMesh(factory, cfg, envName, "environment bundle", downloadEnvBundleFromServerFunc)
+}
+
+// downloadBundleFromMesh reads the bundle from every environment server and
+// returns the freshest copy with the server it came from.
+func downloadBundleFromMesh(factory *nodeclient.Factory, cfg *config.Config, envName string, noun string, download bundleDownloadFunc) (*takod.EnvBundleResponse, string, error) {
 	serverNames, err := statePullServerNames(cfg, envName, "")
 	if err != nil {
 		return nil, "", err
@@ -605,7 +621,7 @@ func downloadEnvBundleFromMesh(factory *nodeclient.Factory, cfg *config.Config,
 		wg.Add(1)
 		go func(index int, serverName string, serverCfg config.ServerConfig) {
 			defer wg.Done()
-			response, err := downloadEnvBundleFromServerFunc(factory, cfg, envName, serverName, serverCfg)
+			response, err := download(factory, cfg, envName, serverName, serverCfg)
 			resultCh <- envBundleDownloadResult{
 				index:      index,
 				serverName: serverName,
@@ -632,7 +648,7 @@ func downloadEnvBundleFromMesh(factory *nodeclient.Factory, cfg *config.Config,
 		}
 		if result.response != nil && result.response.Found {
 			if result.response.UpdatedAt.IsZero() {
-				nodeErrors = append(nodeErrors, fmt.Sprintf("%s: environment bundle missing updatedAt metadata", result.serverName))
+				nodeErrors = append(nodeErrors, fmt.Sprintf("%s: %s missing updatedAt metadata", result.serverName, noun))
 				continue
 			}
 			candidates = append(candidates, envBundleDownloadCandidate{
@@ -647,7 +663,7 @@ func downloadEnvBundleFromMesh(factory *nodeclient.Factory, cfg *config.Config,
 		return selected.response, selected.source, nil
 	}
 	if len(nodeErrors) == len(serverNames) {
-		return nil, "", fmt.Errorf("failed to read environment bundle from any node: %s", strings.Join(nodeErrors, "; "))
+		return nil, "", fmt.Errorf("failed to read %s from any node: %s", noun, strings.Join(nodeErrors, "; "))
 	}
 	return &takod.EnvBundleResponse{Found: false}, "", nil
 }
@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/redentordev/tako-cli/pkg/config"
+	"github.com/redentordev/tako-cli/pkg/engine"
+	"github.com/redentordev/tako-cli/pkg/nodeclient"
+	"github.com/redentordev/tako-cli/pkg/ssh"
+	"github.com/redentordev/tako-cli/pkg/takoapi"
+	"github.com/redentordev/tako-cli/pkg/takod"
+	"github.com/redentordev/tako-cli/pkg/takodclient"
+	"github.com/spf13/cobra"
+)
+
+var (
+	eventsServer  string
+	eventsService string
+	eventsLimit   int
+)
+
+var eventsCmd = &cobra.Command{
+	Use:          "events",
+	Short:        "Show container crashes, restarts, and OOM kills on each node",
+	SilenceUsage: true,
+	Long: `Show what happened to the environment's service containers, as takod on
+each node recorded it from the docker event stream.
+
+A container exiting without tako or an operator stopping it is a crash, the
+kernel ending it for memory is an OOM kill, and docker's restart policy
+bringing a crashed container back is a restart. Three crashes of one service
+within ten minutes are a crash loop. OOM kills and crash loops are sent to
+the notifications: targets, applied on tako deploy.
+
+Counters cover the node's whole history; each node keeps its last 500
+events per environment.`,
+	Example: `  # Recent events and per-service counters on every node
+  tako events
+
+  # One service's last 20 events
+  tako events --service api -n 20`,
+	Args: cobra.NoArgs,
+	RunE: runEvents,
+}
+
+func init() {
+	rootCmd.AddCommand(eventsCmd)
+	eventsCmd.Flags().StringVarP(&eventsServer, "server", "s", "", "Limit to a specific node")
+	eventsCmd.Flags().StringVar(&eventsService, "service", "", "Limit to one service")
+	eventsCmd.Flags().IntVarP(&eventsLimit, "limit", "n", takod.DefaultContainerEventsLimit, "Number of events to show per node")
+}
+
+func runEvents(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := requireTakodRuntime(cfg); err != nil {
+		return err
+	}
+	if eventsLimit <= 0 || eventsLimit > takod.MaxContainerEventHistory {
+		return &engine.InvalidRequestError{Err: fmt.Errorf("--limit must be between 1 and %d", takod.MaxContainerEventHistory)}
+	}
+	envName := getEnvironmentName(cfg)
+	if eventsService != "" {
+		services, err := cfg.GetServices(envName)
+		if err != nil {
+			return fmt.Errorf("failed to get services: %w", err)
+		}
+		if _, ok := services[eventsService]; !ok {
+			return &engine.InvalidRequestError{Err: fmt.Errorf("service %s not found in environment %s", eventsService, envName)}
+		}
+	}
+	servers, err := cfg.GetEnvironmentServers(envName)
+	if err != nil {
+		return fmt.Errorf("failed to get servers: %w", err)
+	}
+	sort.Strings(servers)
+	if eventsServer != "" {
+		if !slices.Contains(servers, eventsServer) {
+			return &engine.InvalidRequestError{Err: fmt.Errorf("server %s not found in environment %s", eventsServer, envName)}
+		}
+		servers = []string{eventsServer}
+	}
+
+	sshPool := ssh.NewPool()
+	defer sshPool.CloseAll()
+	factory, err := nodeclient.NewFactory(cfg, sshPool, takodSocketFromConfig(cfg))
+	if err != nil {
+		return err
+	}
+	defer factory.CloseIdleConnections()
+
+	result := engine.EventsResult{
+		APIVersion:  takoapi.APIVersionCurrent,
+		Kind:        engine.KindEventsResult,
+		Project:     cfg.Project.Name,
+		Environment: envName,
+		Service:     eventsService,
+		Limit:       eventsLimit,
+		Nodes:       make([]engine.EventsNodeResult, len(servers)),
+	}
+
+	ctx := cmd.Context()
+	var wg sync.WaitGroup
+	for index, serverName := range servers {
+		wg.Add(1)
+		go func(index int, serverName string) {
+			defer wg.Done()
+			node := engine.EventsNodeResult{Server: serverName, Services: []takod.ContainerServiceCounters{}, Events: []takod.ContainerEvent{}}
+			if server, ok := cfg.Servers[serverName]; ok {
+				node.Host = server.Host
+			}
+			response, err := requestEventsViaTakod(ctx, cfg, factory, serverName, envName)
+			if err != nil {
+				node.Error = err.Error()
+			} else {
+				node.Services, node.Events = response.Services, response.Events
+			}
+			result.Nodes[index] = node
+		}(index, serverName)
+	}
+	wg.Wait()
+
+	var out io.Writer = os.Stdout
+	if machineOutputEnabled() {
+		out = os.Stderr
+	}
+	displayEventsResult(out, result)
+
+	failures := 0
+	for _, node := range result.Nodes {
+		if node.Error != "" {
+			failures++
+		}
+	}
+	switch {
+	case failures == len(result.Nodes) && failures > 0:
+		err = fmt.Errorf("failed to read container events on all %d node(s)", failures)
+		result.Error = err.Error()
+	case failures > 0:
+		err = &engine.AttentionError{Err: fmt.Errorf("failed to read container events on %d of %d node(s)", failures, len(result.Nodes))}
+		result.Error = err.Error()
+	}
+	if emitErr := emitResultDocument(result); emitErr != nil && err == nil {
+		err = emitErr
+	}
+	return err
+}
+
+func requestEventsViaTakod(ctx context.Context, cfg *config.Config, factory *nodeclient.Factory, serverName string, envName string) (*takod.ContainerEventsResponse, error) {
+	client, _, err := factory.Client(ctx, serverName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	socket := takodSocketFromConfig(cfg)
+	if err := takodclient.RequireCapability(ctx, client, socket, serverName, takod.CapabilityContainerEventsV1, "container events (tako events)"); err != nil {
+		return nil, err
+	}
+	output, err := takodclient.RequestJSONWithContext(ctx, client, socket, "GET", takodclient.ContainerEventsEndpoint(cfg.Project.Name, envName, eventsService, eventsLimit), nil)
+	if err != nil {
+		return nil, err
+	}
+	var response takod.ContainerEventsResponse
+	if err := decodeTakodJSON(output, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func displayEventsResult(out io.Writer, result engine.EventsResult) {
+	fmt.Fprintf(out, "\n=== Container events (%s) ===\n\n", result.Environment)
+	for _, node := range result.Nodes {
+		if node.Error != "" {
+			fmt.Fprintf(out, "❌ %s (%s): %s\n\n", node.Server, node.Host, node.Error)
+			continue
+		}
+		fmt.Fprintf(out, "%s (%s)\n", node.Server, node.Host)
+		if len(node.Services) == 0 {
+			fmt.Fprintf(out, "  No crashes, restarts, or OOM kills recorded\n\n")
+			continue
+		}
+		for _, service := range node.Services {
+			fmt.Fprintf(out, "  %s %s\n", eventsServiceIcon(service), eventsCountersLabel(service))
+		}
+		if len(node.Events) > 0 {
+			fmt.Fprintln(out)
+		}
+		for _, event := range node.Events {
+			fmt.Fprintf(out, "  %s  %-10s %-16s %s\n", event.Time.Local().Format("2006-01-02 15:04:05"), event.Kind, event.Service, eventDetailLabel(event))
+		}
+		fmt.Fprintln(out)
+	}
+}
+
+func eventsServiceIcon(service takod.ContainerServiceCounters) string {
+	switch {
+	case service.CrashLooping:
+		return "🔴"
+	case service.Crashes > 0 || service.OOMKills > 0:
+		return "🟡"
+	}
+	return "🟢"
+}
+
+// eventsCountersLabel describes a service as "api 4 crashes, 3 restarts,
+// 1 OOM kill, crash looping".
+func eventsCountersLabel(service takod.ContainerServiceCounters) string {
+	parts := []string{
+		pluralCount(service.Crashes, "crash", "crashes"),
+		pluralCount(service.Restarts, "restart", "restarts"),
+		pluralCount(service.OOMKills, "OOM kill", "OOM kills"),
+	}
+	if service.CrashLooping {
+		parts = append(parts, "crash looping")
+	}
+	return fmt.Sprintf("%-16s %s", service.Service, strings.Join(parts, ", "))
+}
+
+func pluralCount(count int, singular string, plural string) string {
+	if count == 1 {
+		return "1 " + singular
+	}
+	return fmt.Sprintf("%d %s", count, plural)
+}
+
+func eventDetailLabel(event takod.ContainerEvent) string {
+	var parts []string
+	if event.Container != "" {
+		parts = append(parts, event.Container)
+	}
+	if event.Message != "" {
+		parts = append(parts, event.Message)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,17 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/redentordev/tako-cli/pkg/takod"
+)
+
+func TestEventsCountersLabel(t *testing.T) {
+	got := eventsCountersLabel(takod.ContainerServiceCounters{Service: "api", Crashes: 4, Restarts: 3, OOMKills: 1, CrashLooping: true})
+	if want := "api              4 crashes, 3 restarts, 1 OOM kill, crash looping"; got != want {
+		t.Fatalf("label = %q, want %q", got, want)
+	}
+	if got := eventsServiceIcon(takod.ContainerServiceCounters{Service: "web", Restarts: 1}); got != "🟢" {
+		t.Fatalf("icon for restarts only = %q", got)
+	}
+}
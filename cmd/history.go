@@ -141,6 +141,9 @@ func renderHistoryResult(result *engine.HistoryResult) error {
 		if dep.Status == state.StatusFailed && dep.Error != "" {
 			fmt.Printf("             Error: %s\n", dep.Error)
 		}
+		if len(dep.Secrets) > 0 {
+			fmt.Printf("             Secrets: %s\n", formatSecretRefs(dep.Secrets))
+		}
 	}
 
 	fmt.Println(strings.Repeat("─", 120))
@@ -195,6 +198,23 @@ func listDeploymentsFromHistory(history *state.DeploymentHistory, opts *state.Hi
 	return result
 }
 
+// formatSecretRefs renders secret refs as KEY@vN, KEY (provider), or
+// KEY (unversioned) for local values with no matching history entry.
+func formatSecretRefs(refs []state.SecretRef) string {
+	parts := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		switch {
+		case ref.Version > 0:
+			parts = append(parts, fmt.Sprintf("%s@v%d", ref.Key, ref.Version))
+		case ref.Source == "provider":
+			parts = append(parts, ref.Key+" (provider)")
+		default:
+			parts = append(parts, ref.Key+" (unversioned)")
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
 func formatStatus(status state.DeploymentStatus) string {
 	switch status {
 	case state.StatusSuccess:
@@ -10,7 +10,11 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var jobsServer string
+var (
+	jobsServer     string
+	jobsRunID      string
+	jobsLogsFollow bool
+)
 
 var jobsCmd = &cobra.Command{
 	Use:          "jobs",
@@ -20,13 +24,23 @@ var jobsCmd = &cobra.Command{
 
 Jobs run on a cron schedule inside one-off containers on a single owning
 node. Deploys register the schedule with the node agent; this command shows
-what is scheduled, its run history, and can trigger a run immediately.`,
+what is scheduled, its run history, and can trigger a run immediately.
+
+Jobs that declare after, onSuccess, or onFailure have no schedule of their
+own: they run after their upstream jobs within the same workflow run, and
+every job run in that workflow shares one run ID.`,
 	Example: `  # List scheduled jobs with their next and last runs
   tako jobs
 
   # Show run history for one job
   tako jobs runs report
 
+  # Show every job run of one workflow run
+  tako jobs runs --run run-20260101T020000Z-1a2b3c4d
+
+  # Show the full output of a job's newest run
+  tako jobs logs report
+
   # Run a job right now and stream its output
   tako jobs trigger report`,
 	Args: cobra.NoArgs,
@@ -38,8 +52,13 @@ var jobsRunsCmd = &cobra.Command{
 	Short:        "Show recorded job runs",
 	SilenceUsage: true,
 	Long: `Show the run history recorded on the environment's nodes, newest
-first. Each record carries the trigger (schedule or manual), exit code,
-status, and a bounded tail of the run's output.`,
+first. Each record carries the workflow run ID, the trigger (schedule,
+manual, or dependency), the attempt number for jobs with retries, exit code,
+status, and a bounded tail of the run's output; tako jobs logs shows the
+full output. Every retry attempt is recorded separately.
+
+--run shows one whole workflow run in execution order, including downstream
+jobs skipped because their conditions were not met.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runJobsRuns,
 }
@@ -50,8 +69,11 @@ var jobsTriggerCmd = &cobra.Command{
 	SilenceUsage: true,
 	Long: `Run a job now on the node holding its schedule, streaming output
 until the run finishes. The run is recorded in the job's history with
-trigger "manual". A run already in progress is not interrupted; the trigger
-fails instead.
+trigger "manual". Failed attempts are retried as the job's retries allow. A
+run already in progress is not interrupted and the trigger fails, unless the
+job's concurrencyPolicy is allow (both run) or replace (the running run is
+stopped). Downstream jobs continue the workflow run on the node after
+the triggered job finishes; follow them with tako jobs runs --run.
 
 In the default text mode the tako process mirrors the job's exit code; in
 machine modes the exit code is structured into the JobTriggerResult document
@@ -60,11 +82,41 @@ instead.`,
 	RunE: runJobsTrigger,
 }
 
+var jobsLogsCmd = &cobra.Command{
+	Use:          "logs JOB",
+	Short:        "Show the full output of a job run",
+	SilenceUsage: true,
+	Long: `Show the complete output of one job run, read from the archive the
+owning node keeps for every attempt. Without --run the newest run is shown,
+including one still in progress. --run takes a workflow run ID (every
+attempt of the job in that run, each headed by its attempt number when the
+job has retries) or the container name of a single attempt.
+
+Each attempt's archive is capped by the job's logArchive.maxSizeMB (default
+64 MB) and kept for logArchive.retain days (default 14), or until the run
+falls out of the job's recorded history. --follow streams a run in progress
+until it finishes.`,
+	Example: `  # Full output of the newest run
+  tako jobs logs report
+
+  # Output of the report job within one workflow run
+  tako jobs logs report --run run-20260101T020000Z-1a2b3c4d
+
+  # Follow a run in progress
+  tako jobs logs report --follow`,
+	Args: cobra.ExactArgs(1),
+	RunE: runJobsLogs,
+}
+
 func init() {
 	rootCmd.AddCommand(jobsCmd)
 	jobsCmd.AddCommand(jobsRunsCmd)
 	jobsCmd.AddCommand(jobsTriggerCmd)
+	jobsCmd.AddCommand(jobsLogsCmd)
 	jobsCmd.PersistentFlags().StringVarP(&jobsServer, "server", "s", "", "Limit to a specific node")
+	jobsRunsCmd.Flags().StringVar(&jobsRunID, "run", "", "Show only the job runs of one workflow run ID")
+	jobsLogsCmd.Flags().StringVar(&jobsRunID, "run", "", "Workflow run ID or attempt container name (default: newest run)")
+	jobsLogsCmd.Flags().BoolVarP(&jobsLogsFollow, "follow", "f", false, "Stream a run in progress until it finishes")
 }
 
 func loadJobsConfig() (*config.Config, error) {
@@ -118,12 +170,25 @@ func renderJobsResult(result *engine.JobsResult) error {
 			lastRun = job.LastRun.StartedAt.Local().Format("2006-01-02 15:04:05")
 			lastStatus = job.LastRun.Status
 		}
-		fmt.Printf("%-15s %-16s %-10s %-20s %-20s %-10s\n", job.Name, job.Schedule, job.Server, nextRun, lastRun, lastStatus)
+		fmt.Printf("%-15s %-16s %-10s %-20s %-20s %-10s\n", job.Name, jobScheduleLabel(job), job.Server, nextRun, lastRun, lastStatus)
 	}
 	fmt.Println()
 	return nil
 }
 
+// jobScheduleLabel shows a dependent job's upstreams where a scheduled job
+// shows its cron expression.
+func jobScheduleLabel(job engine.JobInfo) string {
+	if job.Schedule != "" {
+		return job.Schedule
+	}
+	var upstreams []string
+	upstreams = append(upstreams, job.After...)
+	upstreams = append(upstreams, job.OnSuccess...)
+	upstreams = append(upstreams, job.OnFailure...)
+	return "after " + strings.Join(upstreams, ",")
+}
+
 func runJobsRuns(cmd *cobra.Command, args []string) error {
 	cfg, err := loadJobsConfig()
 	if err != nil {
@@ -137,6 +202,7 @@ func runJobsRuns(cmd *cobra.Command, args []string) error {
 		Config:      cfg,
 		Environment: getEnvironmentName(cfg),
 		Job:         job,
+		RunID:       jobsRunID,
 		Server:      jobsServer,
 	})
 	if result != nil {
@@ -156,13 +222,23 @@ func renderJobRunsResult(result *engine.JobRunsResult) error {
 		return nil
 	}
 	fmt.Println()
-	fmt.Printf("%-15s %-20s %-10s %-10s %-6s %-10s\n", "JOB", "STARTED", "TRIGGER", "STATUS", "EXIT", "DURATION")
-	fmt.Println(strings.Repeat("─", 80))
+	fmt.Printf("%-15s %-32s %-20s %-10s %-8s %-10s %-6s %-10s\n", "JOB", "RUN ID", "STARTED", "TRIGGER", "ATTEMPT", "STATUS", "EXIT", "DURATION")
+	fmt.Println(strings.Repeat("─", 122))
 	for _, run := range result.Runs {
-		fmt.Printf("%-15s %-20s %-10s %-10s %-6d %-10s\n",
+		runID := run.RunID
+		if runID == "" {
+			runID = "-"
+		}
+		attempt := "-"
+		if run.MaxAttempts > 0 {
+			attempt = fmt.Sprintf("%d/%d", run.Attempt, run.MaxAttempts)
+		}
+		fmt.Printf("%-15s %-32s %-20s %-10s %-8s %-10s %-6d %-10s\n",
 			run.Job,
+			runID,
 			run.StartedAt.Local().Format("2006-01-02 15:04:05"),
 			run.Trigger,
+			attempt,
 			run.Status,
 			run.ExitCode,
 			(time.Duration(run.DurationMs) * time.Millisecond).Round(time.Millisecond).String(),
@@ -198,3 +274,24 @@ func runJobsTrigger(cmd *cobra.Command, args []string) error {
 	}
 	return nil
 }
+
+func runJobsLogs(cmd *cobra.Command, args []string) error {
+	cfg, err := loadJobsConfig()
+	if err != nil {
+		return err
+	}
+	result, err := cliEngine().JobLogs(cmd.Context(), engine.JobLogsRequest{
+		Config:      cfg,
+		Environment: getEnvironmentName(cfg),
+		Job:         args[0],
+		RunID:       jobsRunID,
+		Follow:      jobsLogsFollow,
+		Server:      jobsServer,
+	})
+	if result != nil {
+		if emitErr := emitResultDocument(result); emitErr != nil && err == nil {
+			err = emitErr
+		}
+	}
+	return err
+}
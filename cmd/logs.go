@@ -3,9 +3,13 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/redentordev/tako-cli/pkg/config"
 	"github.com/redentordev/tako-cli/pkg/engine"
+	"github.com/redentordev/tako-cli/pkg/takod"
 	"github.com/spf13/cobra"
 )
 
@@ -14,6 +18,13 @@ var (
 	logsService string
 	logsFollow  bool
 	logsTail    int
+	logsSince   string
+	logsUntil   string
+	logsReplica int
+	logsGrep    []string
+	logsExclude []string
+	logsLevel   string
+	logsFields  []string
 )
 
 var logsCmd = &cobra.Command{
@@ -23,10 +34,18 @@ var logsCmd = &cobra.Command{
 
 If --server is not specified, streams logs from every configured environment node.
 
+Query flags filter on the nodes and merge every replica and node into one
+time-ordered stream: --since/--until take a duration ago (30m, 2h, 7d) or a
+timestamp, --replica selects one replica slot, --grep/--exclude take regular
+expressions, and --level/--field match JSON log lines. With query flags
+--tail counts matching lines, and --since alone returns up to 10000 of them.
+
 Examples:
   tako logs --service web               # View logs from the environment mesh
   tako logs --service web --server prod # View logs from a specific node
-  tako logs --service web -f            # Follow logs in real-time`,
+  tako logs --service web -f            # Follow logs in real-time
+  tako logs --service web --since 1h --level error --field user_id=42
+  tako logs --service web --replica 2 --grep timeout --exclude healthz`,
 	RunE: runLogs,
 }
 
@@ -36,6 +55,13 @@ func init() {
 	logsCmd.Flags().StringVar(&logsService, "service", "", "Service to view logs from (required)")
 	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Follow log output")
 	logsCmd.Flags().IntVarP(&logsTail, "tail", "n", 100, "Number of lines to show")
+	logsCmd.Flags().StringVar(&logsSince, "since", "", "Show lines since a duration ago (1h, 7d) or a timestamp")
+	logsCmd.Flags().StringVar(&logsUntil, "until", "", "Show lines before a duration ago or a timestamp")
+	logsCmd.Flags().IntVar(&logsReplica, "replica", 0, "Only show logs from this replica slot")
+	logsCmd.Flags().StringArrayVar(&logsGrep, "grep", nil, "Only show lines matching this regular expression (repeatable, all must match)")
+	logsCmd.Flags().StringArrayVar(&logsExclude, "exclude", nil, "Hide lines matching this regular expression (repeatable)")
+	logsCmd.Flags().StringVar(&logsLevel, "level", "", "Only show JSON lines at or above this level (trace, debug, info, warn, error, fatal)")
+	logsCmd.Flags().StringArrayVar(&logsFields, "field", nil, "Only show JSON lines where KEY=VALUE; dotted keys reach nested fields (repeatable)")
 	logsCmd.MarkFlagRequired("service")
 }
 
@@ -50,14 +76,23 @@ func runLogs(cmd *cobra.Command, args []string) error {
 	if logsTail < 0 {
 		return fmt.Errorf("tail cannot be negative")
 	}
+	query, err := logsQueryFromFlags(time.Now())
+	if err != nil {
+		return err
+	}
+	tail := logsTail
+	if !query.Since.IsZero() && !cmd.Flags().Changed("tail") {
+		tail = takod.MaxLogTail
+	}
 
 	request := engine.LogsRequest{
 		Config:      cfg,
 		Environment: getEnvironmentName(cfg),
 		Service:     logsService,
 		Server:      logsServer,
-		Tail:        logsTail,
+		Tail:        tail,
 		Follow:      logsFollow,
+		Query:       query,
 	}
 
 	result, err := cliEngine().StreamLogs(cmd.Context(), request)
@@ -69,6 +104,63 @@ func runLogs(cmd *cobra.Command, args []string) error {
 	return err
 }
 
+func logsQueryFromFlags(now time.Time) (engine.LogsQuery, error) {
+	query := engine.LogsQuery{
+		Replica: logsReplica,
+		Grep:    logsGrep,
+		Exclude: logsExclude,
+		Level:   strings.ToLower(strings.TrimSpace(logsLevel)),
+	}
+	var err error
+	if query.Since, err = parseLogsTime("--since", logsSince, now); err != nil {
+		return query, err
+	}
+	if query.Until, err = parseLogsTime("--until", logsUntil, now); err != nil {
+		return query, err
+	}
+	for _, raw := range logsFields {
+		key, value, ok := strings.Cut(raw, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return query, &engine.InvalidRequestError{Err: fmt.Errorf("--field %q must be KEY=VALUE", raw)}
+		}
+		if query.Fields == nil {
+			query.Fields = map[string]string{}
+		}
+		query.Fields[strings.TrimSpace(key)] = value
+	}
+	return query, nil
+}
+
+// parseLogsTime reads a --since/--until value: a duration before now (Go
+// syntax plus a d suffix for days), an RFC 3339 timestamp, or a local date
+// and time.
+func parseLogsTime(flag string, value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if count, err := strconv.Atoi(days); err == nil && count > 0 {
+			return now.Add(-time.Duration(count) * 24 * time.Hour), nil
+		}
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		if duration <= 0 {
+			return time.Time{}, &engine.InvalidRequestError{Err: fmt.Errorf("%s duration must be positive", flag)}
+		}
+		return now.Add(-duration), nil
+	}
+	if parsed, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return parsed, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if parsed, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, &engine.InvalidRequestError{Err: fmt.Errorf("%s must be a duration (30m, 2h, 7d) or a timestamp (2006-01-02T15:04:05Z)", flag)}
+}
+
 // The logs pipeline lives in pkg/engine; the aliases below keep the
 // historical cmd-level names for tests that still reference them.
 
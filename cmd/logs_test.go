@@ -102,3 +102,49 @@ func waitForLogStarts(t *testing.T, started <-chan string, count int) {
 		}
 	}
 }
+
+func TestParseLogsTimeAcceptsDurationsAndTimestamps(t *testing.T) {
+	now := time.Date(2026, 7, 6, 12, 0, 0, 0, time.UTC)
+	cases := map[string]time.Time{
+		"":                     {},
+		"90m":                  now.Add(-90 * time.Minute),
+		"2d":                   now.Add(-48 * time.Hour),
+		"2026-07-05T08:30:00Z": time.Date(2026, 7, 5, 8, 30, 0, 0, time.UTC),
+		"2026-07-05 08:30":     time.Date(2026, 7, 5, 8, 30, 0, 0, time.UTC),
+		"2026-07-05":           time.Date(2026, 7, 5, 0, 0, 0, 0, time.UTC),
+	}
+	for value, want := range cases {
+		got, err := parseLogsTime("--since", value, now)
+		if err != nil {
+			t.Fatalf("parseLogsTime(%q) error: %v", value, err)
+		}
+		if !got.Equal(want) {
+			t.Fatalf("parseLogsTime(%q) = %s, want %s", value, got, want)
+		}
+	}
+	for _, value := range []string{"-1h", "yesterday", "0s"} {
+		if _, err := parseLogsTime("--since", value, now); err == nil {
+			t.Fatalf("parseLogsTime(%q) accepted", value)
+		}
+	}
+}
+
+func TestLogsQueryFromFlagsParsesFields(t *testing.T) {
+	restoreFields, restoreLevel := logsFields, logsLevel
+	defer func() { logsFields, logsLevel = restoreFields, restoreLevel }()
+	logsFields = []string{"user_id=42", "req.path=/a=b"}
+	logsLevel = "ERROR"
+
+	query, err := logsQueryFromFlags(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.Level != "error" || query.Fields["user_id"] != "42" || query.Fields["req.path"] != "/a=b" {
+		t.Fatalf("query = %#v", query)
+	}
+
+	logsFields = []string{"user_id"}
+	if _, err := logsQueryFromFlags(time.Now()); err == nil {
+		t.Fatal("field without a value accepted")
+	}
+}
@@ -15,7 +15,11 @@ import (
 
 var machineFullContractCommands = map[string]bool{
 	"tako access":                   true,
+	"tako alerts":                   true,
+	"tako alerts silence":           true,
 	"tako backup":                   true,
+	"tako backup restore":           true,
+	"tako backup verify":            true,
 	"tako cleanup":                  true,
 	"tako certs ls":                 true,
 	"tako certs push":               true,
@@ -24,6 +28,7 @@ var machineFullContractCommands = map[string]bool{
 	"tako config export":            true,
 	"tako config pull":              true,
 	"tako deploy":                   true,
+	"tako events":                   true,
 	"tako destroy":                  true,
 	"tako discovery exports":        true,
 	"tako doctor":                   true,
@@ -34,6 +39,7 @@ var machineFullContractCommands = map[string]bool{
 	"tako history":                  true,
 	"tako jobs":                     true,
 	"tako jobs runs":                true,
+	"tako jobs logs":                true,
 	"tako jobs trigger":             true,
 	"tako live":                     true,
 	"tako logs":                     true,
@@ -47,7 +53,9 @@ var machineFullContractCommands = map[string]bool{
 	"tako rollback":                 true,
 	"tako run":                      true,
 	"tako scale":                    true,
+	"tako secrets history":          true,
 	"tako secrets list":             true,
+	"tako secrets rotate":           true,
 	"tako secrets validate":         true,
 	"tako setup":                    true,
 	"tako start":                    true,
@@ -100,6 +108,9 @@ var humanOnlyCommands = map[string]bool{
 	"tako secrets fetch":                        true,
 	"tako secrets import":                       true,
 	"tako secrets init":                         true,
+	"tako secrets pull":                         true,
+	"tako secrets push":                         true,
+	"tako secrets rollback":                     true,
 	"tako secrets set":                          true,
 	"tako upgrade":                              true,
 }
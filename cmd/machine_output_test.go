@@ -672,6 +672,222 @@ func TestMetricsResultDocumentGolden(t *testing.T) {
 	}
 }
 
+// TestMetricsHistoryResultDocumentGolden pins the machine-facing metrics
+// history schema; series reuse the takod /v1/metrics/history points.
+func TestMetricsHistoryResultDocumentGolden(t *testing.T) {
+	cpu := 12.5
+	result := engine.MetricsHistoryResult{
+		APIVersion:  takoapi.APIVersionCurrent,
+		Kind:        engine.KindMetricsHistoryResult,
+		Project:     "demo",
+		Environment: "production",
+		Service:     "api",
+		Since:       time.Date(2026, 7, 5, 12, 0, 0, 0, time.UTC),
+		Until:       time.Date(2026, 7, 6, 12, 0, 0, 0, time.UTC),
+		Nodes: []engine.MetricsHistoryNode{
+			{Server: "node-a", Host: "10.0.0.1", StepSeconds: 60, Series: []takod.MetricsHistorySeries{
+				{Scope: takod.MetricsHistoryScopeService, Project: "demo", Environment: "production", Service: "api", Points: []takod.MetricsHistoryPoint{
+					{Time: time.Date(2026, 7, 6, 11, 59, 0, 0, time.UTC), CPUPercent: &cpu},
+				}},
+			}},
+			{Server: "node-b", Host: "10.0.0.2", Error: "node-b does not support metrics history (tako metrics --since)"},
+		},
+	}
+	payload, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		t.Fatalf("marshal result: %v", err)
+	}
+	want := `{
+  "apiVersion": "tako.redentor.dev/v1alpha1",
+  "kind": "MetricsHistoryResult",
+  "project": "demo",
+  "environment": "production",
+  "service": "api",
+  "since": "2026-07-05T12:00:00Z",
+  "until": "2026-07-06T12:00:00Z",
+  "nodes": [
+    {
+      "server": "node-a",
+      "host": "10.0.0.1",
+      "stepSeconds": 60,
+      "series": [
+        {
+          "scope": "service",
+          "project": "demo",
+          "environment": "production",
+          "service": "api",
+          "points": [
+            {
+              "time": "2026-07-06T11:59:00Z",
+              "cpuPercent": 12.5
+            }
+          ]
+        }
+      ]
+    },
+    {
+      "server": "node-b",
+      "host": "10.0.0.2",
+      "error": "node-b does not support metrics history (tako metrics --since)"
+    }
+  ]
+}`
+	if string(payload) != want {
+		t.Fatalf("metrics history result document drifted:\n%s", payload)
+	}
+}
+
+// TestAlertsResultDocumentGolden pins the machine-facing alerts schema;
+// alerts and silences reuse the takod /v1/alerts records.
+func TestAlertsResultDocumentGolden(t *testing.T) {
+	value := 93.5
+	since := time.Date(2026, 7, 6, 11, 55, 0, 0, time.UTC)
+	until := time.Date(2026, 7, 6, 14, 0, 0, 0, time.UTC)
+	result := engine.AlertsResult{
+		APIVersion:  takoapi.APIVersionCurrent,
+		Kind:        engine.KindAlertsResult,
+		Project:     "demo",
+		Environment: "production",
+		Action:      "silence",
+		Rule:        "web-memory",
+		Until:       &until,
+		Nodes: []engine.AlertsNodeResult{
+			{Server: "node-a", Host: "10.0.0.1", Alerts: []takod.AlertStatus{
+				{Rule: "web-memory", Metric: takod.AlertMetricMemoryPercent, Service: "web", Threshold: 90, DurationSeconds: 300, Severity: takod.AlertSeverityCritical, State: takod.AlertStateFiring, Since: &since, Value: &value, SilencedUntil: &until},
+			}, Silences: []takod.AlertSilence{{Rule: "web-memory", Until: until, Comment: "load test"}}},
+			{Server: "node-b", Host: "10.0.0.2", Alerts: []takod.AlertStatus{}, Silences: []takod.AlertSilence{}, Error: "node-b does not support alert rules (tako alerts)"},
+		},
+	}
+	payload, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		t.Fatalf("marshal result: %v", err)
+	}
+	want := `{
+  "apiVersion": "tako.redentor.dev/v1alpha1",
+  "kind": "AlertsResult",
+  "project": "demo",
+  "environment": "production",
+  "action": "silence",
+  "rule": "web-memory",
+  "until": "2026-07-06T14:00:00Z",
+  "nodes": [
+    {
+      "server": "node-a",
+      "host": "10.0.0.1",
+      "alerts": [
+        {
+          "rule": "web-memory",
+          "metric": "memory_percent",
+          "service": "web",
+          "threshold": 90,
+          "durationSeconds": 300,
+          "severity": "critical",
+          "state": "firing",
+          "since": "2026-07-06T11:55:00Z",
+          "value": 93.5,
+          "silencedUntil": "2026-07-06T14:00:00Z"
+        }
+      ],
+      "silences": [
+        {
+          "rule": "web-memory",
+          "until": "2026-07-06T14:00:00Z",
+          "comment": "load test"
+        }
+      ]
+    },
+    {
+      "server": "node-b",
+      "host": "10.0.0.2",
+      "alerts": [],
+      "silences": [],
+      "error": "node-b does not support alert rules (tako alerts)"
+    }
+  ]
+}`
+	if string(payload) != want {
+		t.Fatalf("alerts result document drifted:\n%s", payload)
+	}
+}
+
+// TestEventsResultDocumentGolden pins the machine-facing container events
+// schema; services and events reuse the takod /v1/events records.
+func TestEventsResultDocumentGolden(t *testing.T) {
+	last := time.Date(2026, 7, 6, 12, 3, 0, 0, time.UTC)
+	exitCode := 137
+	result := engine.EventsResult{
+		APIVersion:  takoapi.APIVersionCurrent,
+		Kind:        engine.KindEventsResult,
+		Project:     "demo",
+		Environment: "production",
+		Service:     "api",
+		Limit:       2,
+		Nodes: []engine.EventsNodeResult{
+			{Server: "node-a", Host: "10.0.0.1", Services: []takod.ContainerServiceCounters{
+				{Service: "api", Crashes: 3, Restarts: 2, OOMKills: 1, LastEventAt: &last, CrashLooping: true},
+			}, Events: []takod.ContainerEvent{
+				{Time: last, Kind: takod.ContainerEventCrashLoop, Service: "api", Message: "3 crashes within 10m0s"},
+				{Time: last, Kind: takod.ContainerEventCrash, Service: "api", Container: "a1b2c3d4e5f6", ExitCode: &exitCode, Message: "killed by the OOM killer"},
+			}},
+			{Server: "node-b", Host: "10.0.0.2", Services: []takod.ContainerServiceCounters{}, Events: []takod.ContainerEvent{}, Error: "node-b does not support container events (tako events)"},
+		},
+	}
+	payload, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		t.Fatalf("marshal result: %v", err)
+	}
+	want := `{
+  "apiVersion": "tako.redentor.dev/v1alpha1",
+  "kind": "EventsResult",
+  "project": "demo",
+  "environment": "production",
+  "service": "api",
+  "limit": 2,
+  "nodes": [
+    {
+      "server": "node-a",
+      "host": "10.0.0.1",
+      "services": [
+        {
+          "service": "api",
+          "crashes": 3,
+          "restarts": 2,
+          "oomKills": 1,
+          "lastEventAt": "2026-07-06T12:03:00Z",
+          "crashLooping": true
+        }
+      ],
+      "events": [
+        {
+          "time": "2026-07-06T12:03:00Z",
+          "kind": "crash_loop",
+          "service": "api",
+          "message": "3 crashes within 10m0s"
+        },
+        {
+          "time": "2026-07-06T12:03:00Z",
+          "kind": "crash",
+          "service": "api",
+          "container": "a1b2c3d4e5f6",
+          "exitCode": 137,
+          "message": "killed by the OOM killer"
+        }
+      ]
+    },
+    {
+      "server": "node-b",
+      "host": "10.0.0.2",
+      "services": [],
+      "events": [],
+      "error": "node-b does not support container events (tako events)"
+    }
+  ]
+}`
+	if string(payload) != want {
+		t.Fatalf("events result document drifted:\n%s", payload)
+	}
+}
+
 // TestStatsResultDocumentGolden pins the machine-facing stats schema.
 func TestStatsResultDocumentGolden(t *testing.T) {
 	result := engine.StatsResult{
@@ -789,6 +1005,47 @@ func TestSecretsResultDocumentsGolden(t *testing.T) {
 	if string(payload) != wantValidate {
 		t.Fatalf("secrets validate document drifted:\n%s", payload)
 	}
+
+	history := engine.SecretsHistoryResult{
+		APIVersion:  takoapi.APIVersionCurrent,
+		Kind:        engine.KindSecretsHistoryResult,
+		Environment: "production",
+		Key:         "API_KEY",
+		Versions: []engine.SecretsHistoryVersion{
+			{Version: 1, Action: "set", Fingerprint: "2c26b46b68ff", Author: "ana@laptop", Timestamp: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)},
+			{Version: 2, Action: "rollback", Fingerprint: "2c26b46b68ff", Author: "ana@laptop", Timestamp: time.Date(2026, 10, 2, 8, 30, 0, 0, time.UTC), RestoredFrom: 1},
+		},
+	}
+	payload, err = json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		t.Fatalf("marshal history result: %v", err)
+	}
+	wantHistory := `{
+  "apiVersion": "tako.redentor.dev/v1alpha1",
+  "kind": "SecretsHistoryResult",
+  "environment": "production",
+  "key": "API_KEY",
+  "versions": [
+    {
+      "version": 1,
+      "action": "set",
+      "fingerprint": "2c26b46b68ff",
+      "author": "ana@laptop",
+      "timestamp": "2026-10-01T12:00:00Z"
+    },
+    {
+      "version": 2,
+      "action": "rollback",
+      "fingerprint": "2c26b46b68ff",
+      "author": "ana@laptop",
+      "timestamp": "2026-10-02T08:30:00Z",
+      "restoredFrom": 1
+    }
+  ]
+}`
+	if string(payload) != wantHistory {
+		t.Fatalf("secrets history document drifted:\n%s", payload)
+	}
 }
 
 // TestDomainsResultDocumentsGolden pins the machine-facing domains schemas.
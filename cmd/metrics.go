@@ -21,9 +21,11 @@ import (
 )
 
 var (
-	metricsLive   bool
-	metricsOnce   bool
-	metricsServer string
+	metricsLive    bool
+	metricsOnce    bool
+	metricsServer  string
+	metricsSince   string
+	metricsService string
 )
 
 // MetricsData represents the JSON structure returned by the monitoring agent
@@ -80,7 +82,15 @@ var metricsCmd = &cobra.Command{
 	Long: `Display real-time system metrics (CPU, RAM, Disk) collected from deployed servers.
 
 The monitoring agent runs continuously on each server, collecting metrics every 60 seconds.
-This command fetches and displays the latest metrics.`,
+This command fetches and displays the latest metrics.
+
+With --since, takod's recorded history is shown instead: node usage and each
+service's summed replica usage as sparklines, at one-minute resolution for the
+last seven days and hourly averages for up to ninety days. --service narrows
+the history to one service.`,
+	Example: `  tako metrics
+  tako metrics --since 24h
+  tako metrics --since 6h --service api --output json`,
 	RunE: runMetrics,
 }
 
@@ -89,6 +99,8 @@ func init() {
 	metricsCmd.Flags().BoolVar(&metricsLive, "live", false, "Continuous live updates")
 	metricsCmd.Flags().BoolVar(&metricsOnce, "once", false, "Collect metrics once immediately")
 	metricsCmd.Flags().StringVarP(&metricsServer, "server", "s", "", "Specific server to monitor")
+	metricsCmd.Flags().StringVar(&metricsSince, "since", "", "Show recorded history since a duration ago (24h, 7d) or a timestamp")
+	metricsCmd.Flags().StringVar(&metricsService, "service", "", "Limit history to one service (requires --since)")
 }
 
 func runMetrics(cmd *cobra.Command, args []string) error {
@@ -102,6 +114,18 @@ func runMetrics(cmd *cobra.Command, args []string) error {
 
 	envName := getEnvironmentName(cfg)
 
+	var since time.Time
+	if metricsSince != "" {
+		if metricsLive || metricsOnce {
+			return &engine.InvalidRequestError{Err: fmt.Errorf("--since cannot be combined with --live or --once")}
+		}
+		if since, err = parseLogsTime("--since", metricsSince, time.Now()); err != nil {
+			return err
+		}
+	} else if metricsService != "" {
+		return &engine.InvalidRequestError{Err: fmt.Errorf("--service requires --since")}
+	}
+
 	servers, err := cfg.GetEnvironmentServers(envName)
 	if err != nil {
 		return fmt.Errorf("failed to get servers: %w", err)
@@ -131,6 +155,10 @@ func runMetrics(cmd *cobra.Command, args []string) error {
 	}
 	defer factory.CloseIdleConnections()
 
+	if !since.IsZero() {
+		return showMetricsHistory(cfg, envName, servers, factory, since)
+	}
+
 	if metricsLive {
 		if machineOutputEnabled() {
 			return &engine.InvalidRequestError{Err: fmt.Errorf("--live is interactive-only; run single-shot metrics reads in machine output modes")}
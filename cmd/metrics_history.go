@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/config"
+	"github.com/redentordev/tako-cli/pkg/engine"
+	"github.com/redentordev/tako-cli/pkg/nodeclient"
+	"github.com/redentordev/tako-cli/pkg/takoapi"
+	"github.com/redentordev/tako-cli/pkg/takod"
+	"github.com/redentordev/tako-cli/pkg/takodclient"
+)
+
+// metricsSparklineWidth is how many columns a history sparkline spans.
+const metricsSparklineWidth = 60
+
+var metricsSparklineLevels = []rune("▁▂▃▄▅▆▇█")
+
+func showMetricsHistory(cfg *config.Config, envName string, servers []string, factory *nodeclient.Factory, since time.Time) error {
+	var out io.Writer = os.Stdout
+	if machineOutputEnabled() {
+		out = os.Stderr
+	}
+	until := time.Now().UTC()
+	result := engine.MetricsHistoryResult{
+		APIVersion:  takoapi.APIVersionCurrent,
+		Kind:        engine.KindMetricsHistoryResult,
+		Project:     cfg.Project.Name,
+		Environment: envName,
+		Server:      metricsServer,
+		Service:     metricsService,
+		Since:       since.UTC(),
+		Until:       until,
+		Nodes:       make([]engine.MetricsHistoryNode, len(servers)),
+	}
+
+	var wg sync.WaitGroup
+	for index, serverName := range servers {
+		wg.Add(1)
+		go func(index int, serverName string) {
+			defer wg.Done()
+			node := engine.MetricsHistoryNode{Server: serverName}
+			if server, ok := cfg.Servers[serverName]; ok {
+				node.Host = server.Host
+			}
+			response, err := readMetricsHistoryViaTakod(cfg, factory, serverName, envName, since)
+			if err != nil {
+				node.Error = err.Error()
+			} else {
+				node.StepSeconds = response.StepSeconds
+				node.Series = response.Series
+			}
+			result.Nodes[index] = node
+		}(index, serverName)
+	}
+	wg.Wait()
+
+	fmt.Fprintf(out, "\n=== Metrics History (since %s) ===\n\n", since.Local().Format("2006-01-02 15:04"))
+	failures := 0
+	for _, node := range result.Nodes {
+		if node.Error != "" {
+			fmt.Fprintf(out, "❌ %s (%s): %s\n\n", node.Server, node.Host, node.Error)
+			failures++
+			continue
+		}
+		displayMetricsHistory(out, node, since, until)
+	}
+
+	var err error
+	switch {
+	case failures == len(result.Nodes) && failures > 0:
+		err = fmt.Errorf("failed to read metrics history from all %d node(s)", failures)
+		result.Error = err.Error()
+	case failures > 0:
+		err = &engine.AttentionError{Err: fmt.Errorf("failed to read metrics history from %d of %d node(s)", failures, len(result.Nodes))}
+		result.Error = err.Error()
+	}
+	if emitErr := emitResultDocument(result); emitErr != nil && err == nil {
+		err = emitErr
+	}
+	return err
+}
+
+func readMetricsHistoryViaTakod(cfg *config.Config, factory *nodeclient.Factory, serverName string, envName string, since time.Time) (*takod.MetricsHistoryResponse, error) {
+	ctx := context.Background()
+	client, _, err := factory.Client(ctx, serverName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	socket := takodSocketFromConfig(cfg)
+	if err := takodclient.RequireCapability(ctx, client, socket, serverName, takod.CapabilityMetricsHistoryV1, "metrics history (tako metrics --since)"); err != nil {
+		return nil, err
+	}
+	output, err := takodclient.RequestJSON(client, socket, "GET", takodclient.MetricsHistoryEndpoint(cfg.Project.Name, envName, metricsService, since, time.Time{}), nil)
+	if err != nil {
+		return nil, err
+	}
+	var response takod.MetricsHistoryResponse
+	if err := decodeTakodJSON(output, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// metricsHistoryRow is one rendered line of a series: a label, how to read
+// the field from a point, and how to format its values.
+type metricsHistoryRow struct {
+	label  string
+	field  func(takod.MetricsHistoryPoint) *float64
+	format func(float64) string
+}
+
+var metricsHistoryRows = []metricsHistoryRow{
+	{"CPU", func(p takod.MetricsHistoryPoint) *float64 { return p.CPUPercent }, formatHistoryPercent},
+	{"Memory", func(p takod.MetricsHistoryPoint) *float64 { return p.MemoryBytes }, formatHistoryBytes},
+	{"Net ↓", func(p takod.MetricsHistoryPoint) *float64 { return p.NetRxBytesPerSecond }, formatHistoryRate},
+	{"Net ↑", func(p takod.MetricsHistoryPoint) *float64 { return p.NetTxBytesPerSecond }, formatHistoryRate},
+	{"Disk R", func(p takod.MetricsHistoryPoint) *float64 { return p.DiskReadBytesPerSecond }, formatHistoryRate},
+	{"Disk W", func(p takod.MetricsHistoryPoint) *float64 { return p.DiskWriteBytesPerSecond }, formatHistoryRate},
+	{"Load", func(p takod.MetricsHistoryPoint) *float64 { return p.Load1 }, func(v float64) string { return fmt.Sprintf("%.2f", v) }},
+	{"Replicas", func(p takod.MetricsHistoryPoint) *float64 { return p.Replicas }, func(v float64) string { return fmt.Sprintf("%.0f", v) }},
+}
+
+func displayMetricsHistory(out io.Writer, node engine.MetricsHistoryNode, since time.Time, until time.Time) {
+	fmt.Fprintf(out, "📊 %s (%s), %s steps\n", node.Server, node.Host, time.Duration(node.StepSeconds)*time.Second)
+	fmt.Fprintf(out, "─────────────────────────────────────────────────\n")
+	if len(node.Series) == 0 {
+		fmt.Fprintf(out, "No history recorded in this range yet\n\n")
+		return
+	}
+	for _, series := range node.Series {
+		if series.Scope == takod.MetricsHistoryScopeNode {
+			fmt.Fprintf(out, "Node\n")
+		} else {
+			fmt.Fprintf(out, "Service %s\n", series.Service)
+		}
+		for _, row := range metricsHistoryRows {
+			values := make([]float64, 0, len(series.Points))
+			times := make([]time.Time, 0, len(series.Points))
+			for _, point := range series.Points {
+				if value := row.field(point); value != nil {
+					values = append(values, *value)
+					times = append(times, point.Time)
+				}
+			}
+			if len(values) == 0 {
+				continue
+			}
+			low, high := values[0], values[0]
+			for _, value := range values {
+				low, high = math.Min(low, value), math.Max(high, value)
+			}
+			fmt.Fprintf(out, "  %-8s %s  %s … %s, now %s\n", row.label, renderSparkline(times, values, since, until, metricsSparklineWidth),
+				row.format(low), row.format(high), row.format(values[len(values)-1]))
+		}
+		fmt.Fprintln(out)
+	}
+}
+
+// renderSparkline places values on width columns spanning since to until,
+// averaging values that share a column and leaving gaps blank.
+func renderSparkline(times []time.Time, values []float64, since time.Time, until time.Time, width int) string {
+	span := until.Sub(since)
+	if span <= 0 || width <= 0 {
+		return ""
+	}
+	sums := make([]float64, width)
+	counts := make([]int, width)
+	for i, at := range times {
+		column := max(0, min(int(float64(at.Sub(since))/float64(span)*float64(width)), width-1))
+		sums[column] += values[i]
+		counts[column]++
+	}
+	low, high := math.Inf(1), math.Inf(-1)
+	for column := range sums {
+		if counts[column] > 0 {
+			sums[column] /= float64(counts[column])
+			low, high = math.Min(low, sums[column]), math.Max(high, sums[column])
+		}
+	}
+	var line strings.Builder
+	for column := range sums {
+		if counts[column] == 0 {
+			line.WriteRune(' ')
+			continue
+		}
+		level := len(metricsSparklineLevels) / 2
+		if high > low {
+			level = int((sums[column] - low) / (high - low) * float64(len(metricsSparklineLevels)-1))
+		}
+		line.WriteRune(metricsSparklineLevels[level])
+	}
+	return line.String()
+}
+
+func formatHistoryPercent(value float64) string {
+	return fmt.Sprintf("%.1f%%", value)
+}
+
+func formatHistoryBytes(value float64) string {
+	return formatSize(int64(value))
+}
+
+func formatHistoryRate(value float64) string {
+	return formatSize(int64(value)) + "/s"
+}
@@ -80,3 +80,15 @@ func testMetricsServers(names []string) map[string]config.ServerConfig {
 	}
 	return servers
 }
+
+func TestRenderSparklineScalesAndLeavesGaps(t *testing.T) {
+	since := time.Date(2026, 7, 6, 12, 0, 0, 0, time.UTC)
+	times := []time.Time{since, since.Add(time.Minute), since.Add(3 * time.Minute)}
+	got := renderSparkline(times, []float64{0, 50, 100}, since, since.Add(4*time.Minute), 4)
+	if got != "▁▄ █" {
+		t.Fatalf("renderSparkline() = %q", got)
+	}
+	if flat := renderSparkline(times[:1], []float64{7}, since, since.Add(time.Minute), 2); flat != "▅ " {
+		t.Fatalf("flat sparkline = %q", flat)
+	}
+}
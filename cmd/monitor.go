@@ -30,6 +30,10 @@ For services without health checks, it verifies containers are running.
 The monitor runs indefinitely until stopped with Ctrl+C. Use --once to
 run a single check and exit.
 
+For resource thresholds that keep alerting without a terminal open,
+declare an alerts: block in tako.yaml instead; takod evaluates it on every
+node (see tako alerts).
+
 Examples:
   tako monitor                  # Monitor all services continuously
   tako monitor --service web    # Monitor specific service only
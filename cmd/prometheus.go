@@ -19,7 +19,10 @@ var prometheusCmd = &cobra.Command{
 	Long: `Export system and container metrics in Prometheus exposition format.
 
 This command outputs metrics that can be scraped by Prometheus or viewed directly.
-The output follows the Prometheus text-based exposition format.`,
+The output follows the Prometheus text-based exposition format.
+
+For continuous scraping, add a metrics: block to tako.yaml instead; takod on
+every node then serves an authenticated /metrics endpoint on its mesh address.`,
 	RunE: runPrometheus,
 }
 
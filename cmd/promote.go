@@ -14,13 +14,17 @@ var promoteRevision string
 
 var promoteCmd = &cobra.Command{
 	Use:          "promote SERVICE",
-	Short:        "Promote a warmed blue-green revision",
+	Short:        "Promote a warmed blue-green or canary revision",
 	SilenceUsage: true,
-	Long: `Promote a warmed blue-green revision.
+	Long: `Promote a warmed blue-green or canary revision.
 
 For services using deploy.strategy=blue_green and promotion=manual, deploy warms
 the new revision without moving public traffic. promote switches proxy routes to
-the warmed revision, prunes stale revisions, and persists the promoted state.`,
+the warmed revision, prunes stale revisions, and persists the promoted state.
+
+For services using deploy.strategy=canary, each promote advances the canary to
+its next deploy.canary.steps traffic share. The final step activates the canary
+revision exactly like a blue-green promotion.`,
 	Args: cobra.ExactArgs(1),
 	RunE: runPromote,
 }
@@ -126,6 +126,7 @@ func Execute() {
 	defer stop()
 
 	err := rootCmd.ExecuteContext(ctx)
+	shutdownCommandTracing()
 	if err != nil {
 		os.Exit(exitCodeForError(err))
 	}
@@ -29,6 +29,12 @@ var secretsProviderFlags struct {
 	region          string
 	path            string
 	from            string
+	address         string
+	mount           string
+	project         string
+	version         string
+	namespace       string
+	tokenEnv        string
 	env             string
 	prefixStrip     string
 	maps            []string
@@ -210,7 +216,7 @@ func runSecretsValidate(cmd *cobra.Command, args []string) error {
 	}
 
 	// Create manager
-	mgr, err := secrets.NewManager(env)
+	mgr, err := secrets.NewManagerForConfig(cfg, env)
 	if err != nil {
 		return err
 	}
@@ -272,8 +278,20 @@ var secretsFetchCmd = &cobra.Command{
 	Long: `Fetch secrets from an external provider and print only redacted values by default.
 
 Supported providers:
-  aws-ssm
-  aws-secrets-manager`,
+  aws-ssm              SSM parameter names, or --path to read recursively
+  aws-secrets-manager  secret names; JSON secrets become name/KEY
+  vault                HashiCorp Vault KV v2 secret at --path under --mount
+                       (VAULT_ADDR or --address, VAULT_TOKEN)
+  1password            1Password Connect item --path vault/item, or
+                       vault/item/field names (OP_CONNECT_HOST or --address,
+                       OP_CONNECT_TOKEN)
+  doppler              Doppler config --path project/config (DOPPLER_TOKEN)
+  gcp-secret-manager   secret IDs in --project, or every secret when no names
+                       are given (GOOGLE_OAUTH_ACCESS_TOKEN or gcloud login)
+  sops                 SOPS-encrypted file at --path, decrypted with the sops CLI
+
+Provider tokens are read from the environment, never from flags; --token-env
+names a different variable.`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		provider := args[0]
@@ -389,10 +407,16 @@ func getEnvDisplay(env string) string {
 
 func providerOptionsFromFlags() secrets.ProviderOptions {
 	return secrets.ProviderOptions{
-		Profile: secretsProviderFlags.profile,
-		Region:  secretsProviderFlags.region,
-		Path:    secretsProviderFlags.path,
-		From:    secretsProviderFlags.from,
+		Profile:   secretsProviderFlags.profile,
+		Region:    secretsProviderFlags.region,
+		Path:      secretsProviderFlags.path,
+		From:      secretsProviderFlags.from,
+		Address:   secretsProviderFlags.address,
+		Mount:     secretsProviderFlags.mount,
+		Project:   secretsProviderFlags.project,
+		Version:   secretsProviderFlags.version,
+		Namespace: secretsProviderFlags.namespace,
+		TokenEnv:  secretsProviderFlags.tokenEnv,
 	}
 }
 
@@ -520,6 +544,12 @@ func init() {
 		cmd.Flags().StringVar(&secretsProviderFlags.region, "region", "", "AWS region")
 		cmd.Flags().StringVar(&secretsProviderFlags.path, "path", "", "Provider path/prefix to import recursively")
 		cmd.Flags().StringVar(&secretsProviderFlags.from, "from", "", "Provider folder/name prefix for explicit names")
+		cmd.Flags().StringVar(&secretsProviderFlags.address, "address", "", "Vault or 1Password Connect URL, or API endpoint override")
+		cmd.Flags().StringVar(&secretsProviderFlags.mount, "mount", "", "Vault KV v2 mount (default: secret)")
+		cmd.Flags().StringVar(&secretsProviderFlags.project, "project", "", "GCP project")
+		cmd.Flags().StringVar(&secretsProviderFlags.version, "secret-version", "", "Vault or GCP secret version (default: latest)")
+		cmd.Flags().StringVar(&secretsProviderFlags.namespace, "namespace", "", "Vault Enterprise namespace")
+		cmd.Flags().StringVar(&secretsProviderFlags.tokenEnv, "token-env", "", "Environment variable holding the provider token")
 		cmd.Flags().StringVarP(&secretsProviderFlags.env, "env", "e", "", "Environment (e.g., production, staging)")
 		cmd.Flags().StringVar(&secretsProviderFlags.prefixStrip, "prefix-strip", "", "Strip this provider prefix before deriving local secret keys")
 		cmd.Flags().StringArrayVar(&secretsProviderFlags.maps, "map", nil, "Map provider source to local secret key (SOURCE=DEST)")
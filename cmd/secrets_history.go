@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/redentordev/tako-cli/pkg/engine"
+	"github.com/redentordev/tako-cli/pkg/secrets"
+	"github.com/redentordev/tako-cli/pkg/takoapi"
+	"github.com/spf13/cobra"
+)
+
+// secretFingerprintLength is how much of a value's SHA-256 history output
+// shows: enough to tell versions apart, too little to test guesses against.
+const secretFingerprintLength = 12
+
+var secretsHistoryCmd = &cobra.Command{
+	Use:   "history KEY",
+	Short: "Show the recorded versions of a secret",
+	Long: `Show every recorded change to a secret: version, action, who made it and
+when, and a fingerprint of the value. Values are never printed.
+
+tako secrets set, delete, rollback, and rotate KEY=value record a version in
+an encrypted history file beside the secrets file (.tako/secrets.<env>.history,
+or .tako/secrets.common.history without --env). tako history records which
+version each deployment used.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSecretsHistory,
+}
+
+var secretsRollbackCmd = &cobra.Command{
+	Use:   "rollback KEY --to N",
+	Short: "Restore a secret to an earlier version",
+	Long: `Restore a secret to the value recorded as version N. The restore is
+recorded as a new version, so it can itself be rolled back. Run tako deploy
+(or tako secrets rotate KEY for files-delivered services) to roll the
+restored value out.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSecretsRollback,
+}
+
+var secretsRollbackTo int
+
+func init() {
+	secretsCmd.AddCommand(secretsHistoryCmd)
+	secretsCmd.AddCommand(secretsRollbackCmd)
+	for _, cmd := range []*cobra.Command{secretsHistoryCmd, secretsRollbackCmd} {
+		cmd.Flags().StringP("env", "e", "", "Environment (e.g., production, staging)")
+	}
+	markHumanOnly(secretsRollbackCmd)
+	secretsRollbackCmd.Flags().IntVar(&secretsRollbackTo, "to", 0, "Version to restore (see tako secrets history)")
+	_ = secretsRollbackCmd.MarkFlagRequired("to")
+}
+
+func runSecretsHistory(cmd *cobra.Command, args []string) error {
+	key := args[0]
+	env, _ := cmd.Flags().GetString("env")
+
+	mgr, err := secrets.NewManager(env)
+	if err != nil {
+		return err
+	}
+	versions, err := mgr.History(key, env)
+	if err != nil {
+		return err
+	}
+
+	result := engine.SecretsHistoryResult{
+		APIVersion:  takoapi.APIVersionCurrent,
+		Kind:        engine.KindSecretsHistoryResult,
+		Environment: secretsFileDisplay(env),
+		Key:         key,
+		Versions:    make([]engine.SecretsHistoryVersion, 0, len(versions)),
+	}
+	for _, version := range versions {
+		result.Versions = append(result.Versions, engine.SecretsHistoryVersion{
+			Version:      version.Version,
+			Action:       version.Action,
+			Fingerprint:  secretFingerprint(version.SHA256),
+			Author:       version.Author,
+			Timestamp:    version.Timestamp,
+			RestoredFrom: version.RestoredFrom,
+		})
+	}
+
+	// Machine modes reserve stdout for parseable output.
+	var out io.Writer = os.Stdout
+	if machineOutputEnabled() {
+		out = os.Stderr
+	}
+	if len(result.Versions) == 0 {
+		fmt.Fprintf(out, "No recorded history for '%s' in %s secrets\n", key, result.Environment)
+		return emitResultDocument(result)
+	}
+	fmt.Fprintf(out, "History of '%s' (%s):\n", key, result.Environment)
+	fmt.Fprintf(out, "  %-8s %-10s %-14s %-20s %s\n", "VERSION", "ACTION", "FINGERPRINT", "TIMESTAMP", "AUTHOR")
+	for i := len(result.Versions) - 1; i >= 0; i-- {
+		version := result.Versions[i]
+		action := version.Action
+		if version.RestoredFrom > 0 {
+			action = fmt.Sprintf("%s→%d", action, version.RestoredFrom)
+		}
+		fingerprint := version.Fingerprint
+		if fingerprint == "" {
+			fingerprint = "-"
+		}
+		fmt.Fprintf(out, "  %-8d %-10s %-14s %-20s %s\n", version.Version, action, fingerprint, version.Timestamp.Local().Format("2006-01-02 15:04:05"), version.Author)
+	}
+	return emitResultDocument(result)
+}
+
+func runSecretsRollback(cmd *cobra.Command, args []string) error {
+	key := args[0]
+	env, _ := cmd.Flags().GetString("env")
+	if secretsRollbackTo <= 0 {
+		return fmt.Errorf("--to must be a version number from tako secrets history %s", key)
+	}
+
+	mgr, err := secrets.NewManager(env)
+	if err != nil {
+		return err
+	}
+	version, err := mgr.Rollback(key, env, secretsRollbackTo)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Secret '%s' restored to version %d in %s secrets (recorded as version %d)\n", key, secretsRollbackTo, secretsFileDisplay(env), version.Version)
+	fmt.Println("  Run tako deploy, or tako secrets rotate for files-delivered services, to apply it")
+	return nil
+}
+
+// secretsFileDisplay names the secrets file set, delete, and history act on.
+func secretsFileDisplay(env string) string {
+	if env == "" {
+		return "common"
+	}
+	return env
+}
+
+func secretFingerprint(sha string) string {
+	if len(sha) > secretFingerprintLength {
+		return sha[:secretFingerprintLength]
+	}
+	return sha
+}
@@ -0,0 +1,361 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/redentordev/tako-cli/pkg/config"
+	"github.com/redentordev/tako-cli/pkg/deployer"
+	"github.com/redentordev/tako-cli/pkg/engine"
+	"github.com/redentordev/tako-cli/pkg/nodeclient"
+	"github.com/redentordev/tako-cli/pkg/secrets"
+	"github.com/redentordev/tako-cli/pkg/ssh"
+	"github.com/redentordev/tako-cli/pkg/takoapi"
+	"github.com/redentordev/tako-cli/pkg/takod"
+	"github.com/redentordev/tako-cli/pkg/takodclient"
+	"github.com/spf13/cobra"
+)
+
+var (
+	secretsRotateService string
+	secretsRotateServer  string
+)
+
+var secretsRotateCmd = &cobra.Command{
+	Use:          "rotate KEY[=value]",
+	Short:        "Replace a secret on running replicas without a redeploy",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	Long: `Replace a secret on running replicas without a redeploy.
+
+Services with secretsDelivery.mode: files read their secrets from
+/run/secrets, a node tmpfs takod manages. rotate rewrites the file for KEY in
+place on every node running such a service, then notifies each running
+replica the way secretsDelivery.reload asks: a signal such as SIGHUP, or an
+HTTP POST to a reload path. Without reload, the application must re-read the
+file itself.
+
+With KEY=value the new value is first saved to the environment's secrets
+file; with KEY alone the current value (for example after updating it in the
+external secret provider) is pushed. Services that receive KEY as an
+environment variable are listed and need tako deploy to pick it up.
+
+Examples:
+  # Save a new API key and push it to every replica
+  tako secrets rotate STRIPE_KEY=sk_live_new --env production
+
+  # Push the value already updated in Vault to one service
+  tako secrets rotate DATABASE_PASSWORD --service api
+`,
+	RunE: runSecretsRotate,
+}
+
+func init() {
+	secretsCmd.AddCommand(secretsRotateCmd)
+	secretsRotateCmd.Flags().StringVar(&secretsRotateService, "service", "", "Only rotate for this service")
+	secretsRotateCmd.Flags().StringVarP(&secretsRotateServer, "server", "s", "", "Only rotate on this node")
+}
+
+// secretRotateTarget is one files-delivery service that reads the rotated key.
+type secretRotateTarget struct {
+	service string
+	request takod.SecretRotateRequest
+}
+
+type secretRotateNodeResult struct {
+	index      int
+	serverName string
+	host       string
+	responses  []*takod.SecretRotateResponse
+	err        error
+}
+
+func runSecretsRotate(cmd *cobra.Command, args []string) error {
+	var out io.Writer = os.Stdout
+	if machineOutputEnabled() {
+		out = os.Stderr
+	}
+
+	key, value, setValue := strings.Cut(args[0], "=")
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return fmt.Errorf("invalid format, use: KEY or KEY=value")
+	}
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := requireTakodRuntime(cfg); err != nil {
+		return err
+	}
+	envName := getEnvironmentName(cfg)
+	services, err := cfg.GetServices(envName)
+	if err != nil {
+		return fmt.Errorf("failed to get services for environment %s: %w", envName, err)
+	}
+	fileServices, envServices, err := secretRotateServices(services, key, secretsRotateService)
+	if err != nil {
+		return err
+	}
+	for _, name := range envServices {
+		fmt.Fprintf(out, "⚠️  Service %s receives %s as an environment variable; run tako deploy to roll it out\n", name, key)
+	}
+	if len(fileServices) == 0 {
+		return fmt.Errorf("no service in environment %s reads %s through secretsDelivery.mode: files", envName, key)
+	}
+
+	if setValue {
+		local, err := secrets.NewManager(envName)
+		if err != nil {
+			return err
+		}
+		if err := local.Set(key, value, envName); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "✓ Secret '%s' saved to %s secrets\n", key, envName)
+	}
+	secretsMgr, err := secrets.NewManagerForConfig(cfg, envName)
+	if err != nil {
+		return fmt.Errorf("failed to create secrets manager: %w", err)
+	}
+	targets, err := secretRotateTargets(cfg, envName, services, fileServices, key, secretsMgr)
+	if err != nil {
+		return err
+	}
+
+	servers, err := resolveEnvironmentServerSet(cfg, envName, secretsRotateServer)
+	if err != nil {
+		return err
+	}
+	servers, targetServerNames, err := schedulableMutationServerSet(cfg, envName, servers, secretsRotateServer != "")
+	if err != nil {
+		return err
+	}
+	sshPool := ssh.NewPool()
+	defer sshPool.CloseAll()
+	runtimeFactory, err := nodeclient.NewFactory(cfg, sshPool, takodSocketFromConfig(cfg))
+	if err != nil {
+		return err
+	}
+	defer runtimeFactory.CloseIdleConnections()
+	leaseSet, err := acquireRemoteOperationLeases(sshPool, cfg, envName, targetServerNames, "secrets")
+	if err != nil {
+		return err
+	}
+	defer leaseSet.Release(verbose)
+	if verbose {
+		fmt.Fprintf(out, "→ Acquired remote secrets leases: %s\n", leaseSet.Summary())
+	}
+
+	fmt.Fprintf(out, "Rotating %s for %s on %d node(s)...\n\n", key, strings.Join(fileServices, ", "), len(targetServerNames))
+	results := rotateSecretOnNodes(servers, targetServerNames, func(serverName string) ([]*takod.SecretRotateResponse, error) {
+		return rotateSecretOnNode(cmd.Context(), cfg, runtimeFactory, serverName, targets)
+	})
+	ack, delivered := secretRotateActionResult(cfg, envName, fileServices, results)
+	printSecretRotateResults(out, results)
+
+	if ack.Outcome != engine.ActionOutcomeOK {
+		var err error = fmt.Errorf("secret rotation failed on %d/%d node(s)", secretRotateFailedNodes(ack), len(results))
+		if ack.Outcome == engine.ActionOutcomePartial {
+			err = &engine.AttentionError{Err: err}
+		}
+		ack.Error = err.Error()
+		if emitErr := emitResultDocument(ack); emitErr != nil {
+			return emitErr
+		}
+		return err
+	}
+	if delivered == 0 {
+		fmt.Fprintf(out, "⚠️  No node holds files-delivered replicas yet; the new value applies at the next tako deploy\n")
+	} else {
+		fmt.Fprintf(out, "✓ Rotated %s on %d node(s)\n", key, delivered)
+	}
+	return emitResultDocument(ack)
+}
+
+// secretRotateServices splits the services that read key into those using
+// files delivery, which rotate can update, and those still on env delivery.
+func secretRotateServices(services map[string]config.ServiceConfig, key string, only string) ([]string, []string, error) {
+	if only != "" {
+		if _, ok := services[only]; !ok {
+			return nil, nil, fmt.Errorf("service %s not found", only)
+		}
+	}
+	var files, env []string
+	for name, service := range services {
+		if only != "" && name != only {
+			continue
+		}
+		reads := false
+		for _, secretKey := range service.SecretFileNames() {
+			reads = reads || secretKey == key
+		}
+		if !reads {
+			continue
+		}
+		if service.SecretFilesDelivery() {
+			files = append(files, name)
+		} else {
+			env = append(env, name)
+		}
+	}
+	sort.Strings(files)
+	sort.Strings(env)
+	return files, env, nil
+}
+
+func secretRotateTargets(cfg *config.Config, envName string, services map[string]config.ServiceConfig, names []string, key string, secretsMgr *secrets.Manager) ([]secretRotateTarget, error) {
+	targets := make([]secretRotateTarget, 0, len(names))
+	for _, name := range names {
+		service := services[name]
+		values, err := secretsMgr.SecretFiles(&service)
+		if err != nil {
+			return nil, err
+		}
+		for fileName, secretKey := range service.SecretFileNames() {
+			if secretKey != key {
+				delete(values, fileName)
+			}
+		}
+		spec, err := deployer.SecretFilesPayload(name, &service, values)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, secretRotateTarget{service: name, request: takod.SecretRotateRequest{
+			Project:     cfg.Project.Name,
+			Environment: envName,
+			Service:     name,
+			Network:     maintenanceNetworkName(cfg.Project.Name, envName),
+			Secrets:     *spec,
+			Reload:      deployer.SecretReloadPayload(&service),
+		}})
+	}
+	return targets, nil
+}
+
+func rotateSecretOnNodes(servers map[string]config.ServerConfig, targetServers []string, action func(serverName string) ([]*takod.SecretRotateResponse, error)) []secretRotateNodeResult {
+	results := make([]secretRotateNodeResult, len(targetServers))
+	var wg sync.WaitGroup
+	for index, serverName := range targetServers {
+		wg.Add(1)
+		go func(index int, serverName string) {
+			defer wg.Done()
+			responses, err := action(serverName)
+			results[index] = secretRotateNodeResult{index: index, serverName: serverName, host: servers[serverName].Host, responses: responses, err: err}
+		}(index, serverName)
+	}
+	wg.Wait()
+	return results
+}
+
+func rotateSecretOnNode(ctx context.Context, cfg *config.Config, factory *nodeclient.Factory, serverName string, targets []secretRotateTarget) ([]*takod.SecretRotateResponse, error) {
+	client, _, err := factory.Client(ctx, serverName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to node %s: %w", serverName, err)
+	}
+	socket := takodSocketFromConfig(cfg)
+	if err := takodclient.RequireCapability(ctx, client, socket, serverName, takod.CapabilityServiceSecretFilesV1, "secret rotation (tako secrets rotate)"); err != nil {
+		return nil, err
+	}
+	responses := make([]*takod.SecretRotateResponse, 0, len(targets))
+	for _, target := range targets {
+		output, err := takodclient.RequestJSONWithContext(ctx, client, socket, "POST", "/v1/service-secrets/rotate", target.request)
+		if err != nil {
+			return responses, fmt.Errorf("%s: %w", target.service, err)
+		}
+		var response takod.SecretRotateResponse
+		if err := decodeTakodJSON(output, &response); err != nil {
+			return responses, fmt.Errorf("%s: %w", target.service, err)
+		}
+		responses = append(responses, &response)
+	}
+	return responses, nil
+}
+
+// secretRotateActionResult builds the acknowledgement document and counts the
+// nodes that held files-delivered replicas.
+func secretRotateActionResult(cfg *config.Config, envName string, services []string, results []secretRotateNodeResult) (engine.ActionResult, int) {
+	ack := engine.ActionResult{
+		APIVersion:  takoapi.APIVersionCurrent,
+		Kind:        engine.KindActionResult,
+		Project:     cfg.Project.Name,
+		Environment: envName,
+		Action:      engine.ActionSecretsRotate,
+		Servers:     []engine.ActionNodeOutcome{},
+	}
+	if len(services) == 1 {
+		ack.Service = services[0]
+	}
+	failures, delivered := 0, 0
+	for _, result := range results {
+		outcome := engine.ActionNodeOutcome{Server: result.serverName, Host: result.host}
+		var errs []string
+		if result.err != nil {
+			errs = append(errs, result.err.Error())
+		}
+		nodeDelivered := false
+		for _, response := range result.responses {
+			if !response.Delivered {
+				outcome.Warnings = append(outcome.Warnings, fmt.Sprintf("%s: no files-delivered replicas on this node", response.Service))
+				continue
+			}
+			nodeDelivered = true
+			for _, reloadErr := range response.Errors {
+				errs = append(errs, fmt.Sprintf("%s: reload %s", response.Service, reloadErr))
+			}
+		}
+		if nodeDelivered {
+			delivered++
+		}
+		outcome.Done = len(errs) == 0
+		if !outcome.Done {
+			outcome.Error = strings.Join(errs, "; ")
+			failures++
+		}
+		ack.Servers = append(ack.Servers, outcome)
+	}
+	switch {
+	case failures == 0:
+		ack.Outcome = engine.ActionOutcomeOK
+	case failures == len(results):
+		ack.Outcome = engine.ActionOutcomeFailed
+	default:
+		ack.Outcome = engine.ActionOutcomePartial
+	}
+	return ack, delivered
+}
+
+func secretRotateFailedNodes(ack engine.ActionResult) int {
+	failed := 0
+	for _, outcome := range ack.Servers {
+		if !outcome.Done {
+			failed++
+		}
+	}
+	return failed
+}
+
+func printSecretRotateResults(out io.Writer, results []secretRotateNodeResult) {
+	for _, result := range results {
+		fmt.Fprintf(out, "→ %s (%s)\n", result.serverName, result.host)
+		for _, response := range result.responses {
+			if !response.Delivered {
+				fmt.Fprintf(out, "  %s: no files-delivered replicas\n", response.Service)
+				continue
+			}
+			fmt.Fprintf(out, "  %s: updated %s; %d running replica(s)\n", response.Service, strings.Join(response.Updated, ", "), len(response.Containers))
+			for _, reloadErr := range response.Errors {
+				fmt.Fprintf(out, "    reload failed: %s\n", reloadErr)
+			}
+		}
+		if result.err != nil {
+			fmt.Fprintf(out, "  failed: %v\n", result.err)
+		}
+	}
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,367 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/redentordev/tako-cli/pkg/config"
+	"github.com/redentordev/tako-cli/pkg/crypto"
+	"github.com/redentordev/tako-cli/pkg/nodeclient"
+	"github.com/redentordev/tako-cli/pkg/secrets"
+	"github.com/redentordev/tako-cli/pkg/ssh"
+	"github.com/redentordev/tako-cli/pkg/takod"
+	"github.com/redentordev/tako-cli/pkg/takodclient"
+	"github.com/spf13/cobra"
+	cryptossh "golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// secretsIdentityVar names the SSH private key tako secrets pull opens the
+// shared secrets with when --identity is not given.
+const secretsIdentityVar = "TAKO_SECRETS_IDENTITY"
+
+var (
+	secretsPushRecipients []string
+	secretsPullIdentity   string
+	secretsPullForce      bool
+)
+
+var uploadSharedSecretsToServerFunc = uploadSharedSecretsToServer
+var downloadSharedSecretsFromServerFunc = downloadSharedSecretsFromServer
+
+var secretsPushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "Share the environment's secrets with the team through takod",
+	Long: `Seal the environment's secrets to the team's SSH public keys and store them
+in takod state on the environment's nodes, so teammates and CI can run
+tako secrets pull instead of copying .tako/secrets files by hand.
+
+The bundle holds .tako/secrets.<env>, .tako/secrets (common), and their
+history files. It is encrypted with a random key that is wrapped for each
+recipient (ssh-ed25519 or ssh-rsa); takod stores it without being able to
+read it. Recipients come from environments.<env>.secretRecipients in
+tako.yaml plus any --recipient flags.
+
+Examples:
+  tako secrets push --env production
+  tako secrets push --recipient ~/.ssh/ci_deploy.pub`,
+	Args: cobra.NoArgs,
+	RunE: runSecretsPush,
+}
+
+var secretsPullCmd = &cobra.Command{
+	Use:   "pull",
+	Short: "Restore the environment's secrets shared through takod",
+	Long: `Download the secrets tako secrets push shared for the environment, open them
+with your SSH private key, and write them to .tako encrypted with this
+machine's project key.
+
+The identity defaults to $TAKO_SECRETS_IDENTITY, then ~/.ssh/id_ed25519,
+then ~/.ssh/id_rsa. By default, refuses to overwrite local secrets files that
+differ from the shared copy. Use --force to override.`,
+	Args: cobra.NoArgs,
+	RunE: runSecretsPull,
+}
+
+func init() {
+	secretsCmd.AddCommand(secretsPushCmd)
+	secretsCmd.AddCommand(secretsPullCmd)
+	markHumanOnly(secretsPushCmd)
+	markHumanOnly(secretsPullCmd)
+
+	secretsPushCmd.Flags().StringArrayVar(&secretsPushRecipients, "recipient", nil, "Additional SSH public key, or a file of them (repeatable)")
+	secretsPullCmd.Flags().StringVarP(&secretsPullIdentity, "identity", "i", "", "SSH private key to open the shared secrets with")
+	secretsPullCmd.Flags().BoolVar(&secretsPullForce, "force", false, "Overwrite local secrets files that differ")
+}
+
+func runSecretsPush(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := requireTakodRuntime(cfg); err != nil {
+		return err
+	}
+	envName := getEnvironmentName(cfg)
+
+	recipients, err := sharedSecretsRecipients(cfg, envName, secretsPushRecipients)
+	if err != nil {
+		return err
+	}
+	bundle, err := secrets.ExportSharedBundle(envName)
+	if err != nil {
+		return err
+	}
+	sealed, err := secrets.SealSharedBundle(bundle, recipients)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt shared secrets: %w", err)
+	}
+	names := make([]string, 0, len(bundle.Files))
+	for name := range bundle.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("Including: .tako/%s\n", name)
+	}
+	fmt.Printf("\nSealed to %d recipient(s):\n", len(recipients))
+	for _, recipient := range recipients {
+		fmt.Printf("  %s %s\n", recipient.Fingerprint, recipient.Comment)
+	}
+
+	serverNames, err := statePullServerNames(cfg, envName, "")
+	if err != nil {
+		return err
+	}
+	serverNames, err = config.ResolveSchedulableMutationTargets(cfg.Servers, serverNames, envName, true)
+	if err != nil {
+		return err
+	}
+	sshPool := ssh.NewPool()
+	defer sshPool.CloseAll()
+	runtimeFactory, err := nodeclient.NewFactory(cfg, sshPool, takodSocketFromConfig(cfg))
+	if err != nil {
+		return err
+	}
+	defer runtimeFactory.CloseIdleConnections()
+	leaseSet, err := acquireRemoteOperationLeasesFunc(sshPool, cfg, envName, serverNames, "secrets-push")
+	if err != nil {
+		return err
+	}
+	defer leaseSet.Release(verbose)
+	if verbose {
+		fmt.Printf("→ Acquired remote secrets-push leases: %s\n", leaseSet.Summary())
+	}
+
+	request := takod.EnvBundleRequest{
+		Project:     cfg.Project.Name,
+		Environment: envName,
+		Content:     base64.StdEncoding.EncodeToString(sealed),
+	}
+	uploaded, nodeErrors := uploadBundleToMesh(runtimeFactory, cfg, serverNames, request, uploadSharedSecretsToServerFunc)
+	if uploaded == 0 {
+		return fmt.Errorf("failed to upload shared secrets to any node: %s", strings.Join(nodeErrors, "; "))
+	}
+	if len(nodeErrors) > 0 {
+		return fmt.Errorf("shared secrets uploaded to %d/%d node(s), failed on %s", uploaded, len(serverNames), strings.Join(nodeErrors, "; "))
+	}
+
+	fmt.Printf("\n✓ %s secrets shared on %d node(s)\n", envName, uploaded)
+	fmt.Printf("Recipients restore them with: tako secrets pull --env %s\n", envName)
+	return nil
+}
+
+func runSecretsPull(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := requireTakodRuntime(cfg); err != nil {
+		return err
+	}
+	envName := getEnvironmentName(cfg)
+
+	identityPath, err := sharedSecretsIdentityPath(secretsPullIdentity)
+	if err != nil {
+		return err
+	}
+	identity, err := loadSharedSecretsIdentity(identityPath)
+	if err != nil {
+		return err
+	}
+
+	sshPool := ssh.NewPool()
+	defer sshPool.CloseAll()
+	runtimeFactory, err := nodeclient.NewFactory(cfg, sshPool, takodSocketFromConfig(cfg))
+	if err != nil {
+		return err
+	}
+	defer runtimeFactory.CloseIdleConnections()
+
+	response, source, err := downloadBundleFromMesh(runtimeFactory, cfg, envName, "shared secrets", downloadSharedSecretsFromServerFunc)
+	if err != nil {
+		return err
+	}
+	if response == nil || !response.Found {
+		fmt.Printf("No shared secrets found for %s on reachable nodes.\n", envName)
+		fmt.Println("Run 'tako secrets push' from a machine that has them first.")
+		return nil
+	}
+
+	result, err := restoreSharedSecrets(response, envName, identity, secretsPullForce)
+	if err != nil {
+		return err
+	}
+	if len(result.Conflicts) > 0 && !secretsPullForce {
+		fmt.Println("The following local files differ from the shared secrets:")
+		for _, name := range result.Conflicts {
+			fmt.Printf("  - .tako/%s\n", name)
+		}
+		fmt.Println("\nUse --force to overwrite them.")
+		return nil
+	}
+	for _, name := range result.Written {
+		fmt.Printf("Restored: .tako/%s\n", name)
+	}
+	fmt.Printf("\n✓ Restored %d file(s) from %s (%d already up to date)\n", len(result.Written), source, len(result.Unchanged))
+	return nil
+}
+
+func restoreSharedSecrets(response *takod.EnvBundleResponse, envName string, identity crypto.Identity, force bool) (*secrets.SharedImport, error) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(response.Content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode shared secrets: %w", err)
+	}
+	bundle, err := secrets.OpenSharedBundle(sealed, identity)
+	if errors.Is(err, crypto.ErrNotRecipient) {
+		recipients, _ := crypto.EnvelopeRecipients(sealed)
+		return nil, fmt.Errorf("your key %s is not a recipient of the %s secrets (sealed to %s); ask a teammate to add your public key to environments.%s.secretRecipients and run tako secrets push",
+			identity.Fingerprint, envName, strings.Join(recipients, ", "), envName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open shared secrets: %w", err)
+	}
+	return secrets.ImportSharedBundle(bundle, envName, force)
+}
+
+// sharedSecretsRecipients merges the environment's secretRecipients with
+// --recipient values, each a public key line or a file of them.
+func sharedSecretsRecipients(cfg *config.Config, envName string, extra []string) ([]crypto.Recipient, error) {
+	recipients, err := cfg.EnvironmentSecretRecipients(envName)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(recipients))
+	for _, recipient := range recipients {
+		seen[recipient.Fingerprint] = true
+	}
+	for _, value := range extra {
+		data := []byte(value)
+		if !strings.HasPrefix(value, "ssh-") {
+			if data, err = os.ReadFile(expandHome(value)); err != nil {
+				return nil, fmt.Errorf("failed to read recipient %s: %w", value, err)
+			}
+		}
+		parsed, err := crypto.ParseRecipients(data)
+		if err != nil {
+			return nil, fmt.Errorf("recipient %s: %w", value, err)
+		}
+		for _, recipient := range parsed {
+			if !seen[recipient.Fingerprint] {
+				seen[recipient.Fingerprint] = true
+				recipients = append(recipients, recipient)
+			}
+		}
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("no recipients for %s secrets: add SSH public keys to environments.%s.secretRecipients in tako.yaml or pass --recipient", envName, envName)
+	}
+	return recipients, nil
+}
+
+func sharedSecretsIdentityPath(flag string) (string, error) {
+	if flag != "" {
+		return expandHome(flag), nil
+	}
+	if fromEnv := strings.TrimSpace(os.Getenv(secretsIdentityVar)); fromEnv != "" {
+		return expandHome(fromEnv), nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate home directory: %w", err)
+	}
+	for _, name := range []string{"id_ed25519", "id_rsa"} {
+		path := filepath.Join(homeDir, ".ssh", name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no SSH identity found in ~/.ssh; pass --identity or set %s", secretsIdentityVar)
+}
+
+func loadSharedSecretsIdentity(path string) (crypto.Identity, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return crypto.Identity{}, fmt.Errorf("failed to read identity: %w", err)
+	}
+	identity, err := crypto.ParseIdentity(data, nil)
+	var missing *cryptossh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		if err != nil {
+			return crypto.Identity{}, fmt.Errorf("failed to parse identity %s: %w", path, err)
+		}
+		return identity, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return crypto.Identity{}, fmt.Errorf("identity %s is passphrase-protected and no terminal is available to ask for it", path)
+	}
+	fmt.Printf("Enter passphrase for %s: ", path)
+	passphrase, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Println()
+	if err != nil {
+		return crypto.Identity{}, fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	identity, err = crypto.ParseIdentity(data, passphrase)
+	if err != nil {
+		return crypto.Identity{}, fmt.Errorf("failed to parse identity %s: %w", path, err)
+	}
+	return identity, nil
+}
+
+func uploadSharedSecretsToServer(factory *nodeclient.Factory, cfg *config.Config, serverName string, _ config.ServerConfig, request takod.EnvBundleRequest) error {
+	if factory == nil {
+		return fmt.Errorf("runtime client factory is not initialized")
+	}
+	ctx := context.Background()
+	client, _, err := factory.Client(ctx, serverName)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", serverName, err)
+	}
+	socket := takodSocketFromConfig(cfg)
+	if err := takodclient.RequireCapability(ctx, client, socket, serverName, takod.CapabilitySharedSecretsV1, "shared secrets (tako secrets push)"); err != nil {
+		return err
+	}
+	output, err := takodclient.RequestJSONWithContext(ctx, client, socket, "PUT", "/v1/shared-secrets", request)
+	if err != nil {
+		return fmt.Errorf("failed to upload shared secrets through takod: %w", err)
+	}
+	var response takod.EnvBundleResponse
+	if err := decodeTakodJSON(output, &response); err != nil {
+		return err
+	}
+	if !response.Found {
+		return fmt.Errorf("takod did not confirm shared secrets write")
+	}
+	return nil
+}
+
+func downloadSharedSecretsFromServer(factory *nodeclient.Factory, cfg *config.Config, envName string, serverName string, _ config.ServerConfig) (*takod.EnvBundleResponse, error) {
+	if factory == nil {
+		return nil, fmt.Errorf("runtime client factory is not initialized")
+	}
+	ctx := context.Background()
+	client, _, err := factory.Client(ctx, serverName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", serverName, err)
+	}
+	socket := takodSocketFromConfig(cfg)
+	if err := takodclient.RequireCapability(ctx, client, socket, serverName, takod.CapabilitySharedSecretsV1, "shared secrets (tako secrets pull)"); err != nil {
+		return nil, err
+	}
+	output, err := takodclient.RequestJSONWithContext(ctx, client, socket, "GET", takodclient.SharedSecretsEndpoint(cfg.Project.Name, envName), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download shared secrets through takod: %w", err)
+	}
+	var response takod.EnvBundleResponse
+	if err := decodeTakodJSON(output, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
@@ -1,15 +1,21 @@
 package cmd
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/redentordev/tako-cli/pkg/config"
 	"github.com/redentordev/tako-cli/pkg/engine"
 	"github.com/redentordev/tako-cli/pkg/secrets"
+	"github.com/redentordev/tako-cli/pkg/takod"
 	"github.com/spf13/cobra"
+	cryptossh "golang.org/x/crypto/ssh"
 )
 
 func newSecretsTestCommand(env string) *cobra.Command {
@@ -156,3 +162,121 @@ func TestParseSecretMappingsValidatesDestinationKeys(t *testing.T) {
 		t.Fatal("expected invalid destination key to fail")
 	}
 }
+
+func TestSecretRotateServicesSplitsFilesAndEnvDelivery(t *testing.T) {
+	files := &config.SecretsDeliveryConfig{Mode: config.SecretsDeliveryFiles}
+	services := map[string]config.ServiceConfig{
+		"api":    {Secrets: []string{"STRIPE:STRIPE_KEY"}, SecretsDelivery: files},
+		"worker": {Secrets: []string{"STRIPE_KEY"}, SecretsDelivery: files},
+		"web":    {Secrets: []string{"STRIPE_KEY"}},
+		"cron":   {Secrets: []string{"OTHER"}, SecretsDelivery: files},
+	}
+	fileServices, envServices, err := secretRotateServices(services, "STRIPE_KEY", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fileServices, []string{"api", "worker"}) || !reflect.DeepEqual(envServices, []string{"web"}) {
+		t.Fatalf("files = %v, env = %v", fileServices, envServices)
+	}
+	if fileServices, _, err = secretRotateServices(services, "STRIPE_KEY", "worker"); err != nil || !reflect.DeepEqual(fileServices, []string{"worker"}) {
+		t.Fatalf("--service worker = %v, %v", fileServices, err)
+	}
+	if _, _, err = secretRotateServices(services, "STRIPE_KEY", "missing"); err == nil {
+		t.Fatal("unknown --service accepted")
+	}
+}
+
+func TestSecretRotateActionResultFailsNodesWithReloadErrors(t *testing.T) {
+	cfg := &config.Config{Project: config.ProjectConfig{Name: "demo"}}
+	results := []secretRotateNodeResult{
+		{serverName: "a", responses: []*takod.SecretRotateResponse{{Service: "api", Delivered: true, Updated: []string{"STRIPE"}}}},
+		{serverName: "b", responses: []*takod.SecretRotateResponse{{Service: "api"}}},
+		{serverName: "c", responses: []*takod.SecretRotateResponse{{Service: "api", Delivered: true, Errors: []string{"api_1: reload returned status 503"}}}},
+	}
+	ack, delivered := secretRotateActionResult(cfg, "production", []string{"api"}, results)
+	if ack.Action != engine.ActionSecretsRotate || ack.Service != "api" || ack.Outcome != engine.ActionOutcomePartial || delivered != 2 {
+		t.Fatalf("ack = %+v delivered %d", ack, delivered)
+	}
+	if !ack.Servers[0].Done || !ack.Servers[1].Done || len(ack.Servers[1].Warnings) != 1 {
+		t.Fatalf("servers = %+v", ack.Servers)
+	}
+	if ack.Servers[2].Done || !strings.Contains(ack.Servers[2].Error, "reload api_1") {
+		t.Fatalf("failed server = %+v", ack.Servers[2])
+	}
+}
+
+func TestRunSecretsHistoryMachineOutputNeverCarriesValues(t *testing.T) {
+	switchToTempDir(t)
+	mgr, err := secrets.NewManager("production")
+	if err != nil {
+		t.Fatalf("failed to create secrets manager: %v", err)
+	}
+	for _, value := range []string{"first-value-xyzzy", "second-value-xyzzy"} {
+		if err := mgr.Set("API_KEY", value, "production"); err != nil {
+			t.Fatalf("failed to set secret: %v", err)
+		}
+	}
+
+	restoreOutput := outputFormatFlag
+	outputFormatFlag = outputFormatJSON
+	t.Cleanup(func() { outputFormatFlag = restoreOutput })
+
+	var runErr error
+	stdout := captureStdout(t, func() {
+		runErr = runSecretsHistory(newSecretsTestCommand("production"), []string{"API_KEY"})
+	})
+	if runErr != nil {
+		t.Fatalf("runSecretsHistory returned error: %v", runErr)
+	}
+	if strings.Contains(stdout, "xyzzy") {
+		t.Fatalf("secret value leaked into machine output:\n%s", stdout)
+	}
+	var result engine.SecretsHistoryResult
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("stdout is not a single JSON document: %v\n%s", err, stdout)
+	}
+	if result.Kind != engine.KindSecretsHistoryResult || result.Environment != "production" || len(result.Versions) != 2 {
+		t.Fatalf("unexpected result document: %+v", result)
+	}
+	if len(result.Versions[1].Fingerprint) != secretFingerprintLength || result.Versions[0].Fingerprint == result.Versions[1].Fingerprint {
+		t.Fatalf("fingerprints = %+v", result.Versions)
+	}
+}
+
+func TestSharedSecretsRecipientsMergesConfigAndFlags(t *testing.T) {
+	root := t.TempDir()
+	keyLine := func(comment string) string {
+		public, _, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := cryptossh.NewPublicKey(public)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(string(cryptossh.MarshalAuthorizedKey(key))) + " " + comment
+	}
+	ana, ci, ben := keyLine("ana@laptop"), keyLine("ci@runner"), keyLine("ben@laptop")
+	keyFile := filepath.Join(root, "team.pub")
+	if err := os.WriteFile(keyFile, []byte("# team\n"+ci+"\n"+ana+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Environments: map[string]config.EnvironmentConfig{
+		"production": {SecretRecipients: []string{ana}},
+	}}
+
+	recipients, err := sharedSecretsRecipients(cfg, "production", []string{keyFile, ben})
+	if err != nil {
+		t.Fatalf("sharedSecretsRecipients: %v", err)
+	}
+	var comments []string
+	for _, recipient := range recipients {
+		comments = append(comments, recipient.Comment)
+	}
+	if want := []string{"ana@laptop", "ci@runner", "ben@laptop"}; !reflect.DeepEqual(comments, want) {
+		t.Fatalf("recipients = %v, want %v", comments, want)
+	}
+	if _, err := sharedSecretsRecipients(cfg, "staging", nil); err == nil || !strings.Contains(err.Error(), "environments.staging.secretRecipients") {
+		t.Fatalf("empty recipients error = %v", err)
+	}
+}
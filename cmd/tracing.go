@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/config"
+	"github.com/redentordev/tako-cli/pkg/telemetry"
+)
+
+// tracingShutdownTimeout bounds flushing buffered spans before exit.
+const tracingShutdownTimeout = 5 * time.Second
+
+// initCommandTracing exports this command's spans when
+// OTEL_EXPORTER_OTLP_ENDPOINT (or TAKO_TRACE_DEBUG=1) is set, or else when
+// tako.yaml has a tracing block. Tracing never fails the command.
+func initCommandTracing(cfg *config.Config, envName string) {
+	tracingConfig := telemetry.DefaultConfig()
+	tracingConfig.ServiceVersion = Version
+	tracingConfig.Environment = envName
+	if tracingConfig.OTLPEndpoint == "" && cfg != nil && cfg.Tracing != nil {
+		tracingConfig.OTLPEndpoint = cfg.Tracing.Endpoint
+		tracingConfig.OTLPInsecure = cfg.Tracing.Insecure
+		tracingConfig.OTLPHeaders = cfg.Tracing.Headers
+	}
+	if err := telemetry.Init(tracingConfig); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: tracing disabled: %v\n", err)
+	}
+}
+
+// shutdownCommandTracing flushes spans the command produced.
+func shutdownCommandTracing() {
+	ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+	defer cancel()
+	if err := telemetry.Shutdown(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush traces: %v\n", err)
+	}
+}
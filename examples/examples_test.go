@@ -410,7 +410,7 @@ func assertVolumePattern(t *testing.T, cfg *config.Config) {
 	if !slices.Contains(web.Volumes, "app_data:/app/data") {
 		t.Fatalf("volume template missing app_data mount: %#v", web.Volumes)
 	}
-	if web.Backup == nil || web.Backup.Schedule == "" || web.Backup.Retain != 14 {
+	if web.Backup == nil || web.Backup.Schedule == "" || web.Backup.Retain.Days != 14 {
 		t.Fatalf("volume template missing backup schedule: %#v", web.Backup)
 	}
 }
@@ -39,6 +39,7 @@ type ServiceState struct {
 	SharedBuildHash  string             `json:"sharedBuildHash,omitempty"`
 	FilesContentHash string             `json:"filesContentHash,omitempty"`
 	Files            []ServiceFileState `json:"files,omitempty"`
+	Secrets          []SecretRef        `json:"secrets,omitempty"`
 	Run              *RunState          `json:"run,omitempty"`
 	ImageID          string             `json:"imageId"`     // Docker image ID
 	ContainerID      string             `json:"containerId"` // Running container ID
@@ -54,6 +55,14 @@ type ServiceFileState struct {
 	Owner  string `json:"owner,omitempty"`
 }
 
+// SecretRef records which version of a secret a deployment read. Version is
+// 0 when the value had no matching entry in the local secret history.
+type SecretRef struct {
+	Key     string `json:"key"`
+	Source  string `json:"source"`
+	Version int    `json:"version,omitempty"`
+}
+
 // RunState records a completed deploy-time kind:run execution.
 type RunState struct {
 	Command    []string `json:"command"`
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	maxAlertRules    = 64
+	maxAlertDuration = 24 * time.Hour
+	// DefaultAlertSeverity applies to rules that leave severity unset.
+	DefaultAlertSeverity = "warning"
+)
+
+// Alert metrics takod evaluates. disk_percent and load1 only exist for the
+// node, so rules on them cannot name a service.
+var (
+	alertMetrics         = []string{"cpu_percent", "memory_percent", "memory_bytes", "disk_percent", "load1"}
+	nodeOnlyAlertMetrics = []string{"disk_percent", "load1"}
+	alertSeverities      = []string{"info", DefaultAlertSeverity, "critical"}
+	alertRoutes          = []string{"slack", "discord", "webhook"}
+)
+
+// AlertRuleConfig is one threshold rule from the alerts block, keyed by
+// rule name. takod on every node running the environment evaluates it
+// against its own samples: the rule fires once Metric stays at or above
+// Threshold for Duration, and resolves once it drops below. Without a
+// service the rule watches the node itself; with one, the service's
+// replicas on that node, summed.
+type AlertRuleConfig struct {
+	Metric    string  `yaml:"metric" json:"metric"`
+	Service   string  `yaml:"service,omitempty" json:"service,omitempty"`
+	Threshold float64 `yaml:"threshold" json:"threshold"`
+	// Duration like 5m; empty fires on the first sample over the threshold.
+	Duration string `yaml:"duration,omitempty" json:"duration,omitempty"`
+	// Severity is info, warning (default), or critical.
+	Severity string `yaml:"severity,omitempty" json:"severity,omitempty"`
+	// Routes picks which notifications channels (slack, discord, webhook)
+	// the rule notifies; empty notifies every configured channel.
+	Routes []string `yaml:"routes,omitempty" json:"routes,omitempty"`
+}
+
+// DurationSeconds returns the validated duration in whole seconds.
+func (r AlertRuleConfig) DurationSeconds() int64 {
+	duration, err := time.ParseDuration(strings.TrimSpace(r.Duration))
+	if err != nil {
+		return 0
+	}
+	return int64(duration / time.Second)
+}
+
+// SeverityOrDefault returns the rule's severity, defaulting to warning.
+func (r AlertRuleConfig) SeverityOrDefault() string {
+	if r.Severity == "" {
+		return DefaultAlertSeverity
+	}
+	return r.Severity
+}
+
+// RoutedNotifications narrows notifications to the rule's routes. It
+// returns nil when nothing is left to notify.
+func (r AlertRuleConfig) RoutedNotifications(notifications *NotificationsConfig) *NotificationsConfig {
+	if notifications == nil {
+		return nil
+	}
+	routed := *notifications
+	if len(r.Routes) > 0 {
+		if !slices.Contains(r.Routes, "slack") {
+			routed.Slack = ""
+		}
+		if !slices.Contains(r.Routes, "discord") {
+			routed.Discord = ""
+		}
+		if !slices.Contains(r.Routes, "webhook") {
+			routed.Webhook = ""
+		}
+	}
+	if routed.Slack == "" && routed.Discord == "" && routed.Webhook == "" {
+		return nil
+	}
+	return &routed
+}
+
+// AlertRuleNames returns the configured rule names in sorted order.
+func (c *Config) AlertRuleNames() []string {
+	names := make([]string, 0, len(c.Alerts))
+	for name := range c.Alerts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateAlerts validates the alerts block against the notifications
+// channels and services it references.
+func validateAlerts(cfg *Config) error {
+	if len(cfg.Alerts) > maxAlertRules {
+		return fmt.Errorf("alerts: at most %d rules are allowed", maxAlertRules)
+	}
+	for _, name := range cfg.AlertRuleNames() {
+		rule := cfg.Alerts[name]
+		if !isValidRuntimeIdentifier(name) {
+			return fmt.Errorf("alerts: rule name %q is invalid: must start with a lowercase letter, contain only lowercase letters, numbers, hyphens, and underscores, and be 1-63 characters long", name)
+		}
+		if !slices.Contains(alertMetrics, rule.Metric) {
+			return fmt.Errorf("alerts.%s: metric must be one of %s", name, strings.Join(alertMetrics, ", "))
+		}
+		if rule.Service != "" {
+			if slices.Contains(nodeOnlyAlertMetrics, rule.Metric) {
+				return fmt.Errorf("alerts.%s: %s is a node metric and cannot be scoped to a service", name, rule.Metric)
+			}
+			if !configDefinesService(cfg, rule.Service) {
+				return fmt.Errorf("alerts.%s: service %q is not defined in any environment", name, rule.Service)
+			}
+		}
+		if math.IsNaN(rule.Threshold) || math.IsInf(rule.Threshold, 0) || rule.Threshold < 0 {
+			return fmt.Errorf("alerts.%s: threshold must be a non-negative number", name)
+		}
+		if strings.HasSuffix(rule.Metric, "_percent") && rule.Threshold > 100 {
+			return fmt.Errorf("alerts.%s: threshold for %s cannot exceed 100", name, rule.Metric)
+		}
+		if value := strings.TrimSpace(rule.Duration); value != "" {
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("alerts.%s: duration must be a duration like 30s or 5m: %w", name, err)
+			}
+			if duration < 0 || duration > maxAlertDuration {
+				return fmt.Errorf("alerts.%s: duration must be between 0 and %s", name, maxAlertDuration)
+			}
+		}
+		if rule.Severity != "" && !slices.Contains(alertSeverities, rule.Severity) {
+			return fmt.Errorf("alerts.%s: severity must be one of %s", name, strings.Join(alertSeverities, ", "))
+		}
+		for _, route := range rule.Routes {
+			if !slices.Contains(alertRoutes, route) {
+				return fmt.Errorf("alerts.%s: route %q must be one of %s", name, route, strings.Join(alertRoutes, ", "))
+			}
+			if !notificationChannelConfigured(cfg.Notifications, route) {
+				return fmt.Errorf("alerts.%s: route %q needs notifications.%s to be configured", name, route, route)
+			}
+		}
+	}
+	return nil
+}
+
+func configDefinesService(cfg *Config, service string) bool {
+	for _, env := range cfg.Environments {
+		if _, ok := env.Services[service]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func notificationChannelConfigured(notifications *NotificationsConfig, channel string) bool {
+	if notifications == nil {
+		return false
+	}
+	switch channel {
+	case "slack":
+		return notifications.Slack != ""
+	case "discord":
+		return notifications.Discord != ""
+	case "webhook":
+		return notifications.Webhook != ""
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const alertsTestConfigTemplate = `project:
+  name: demo
+  version: 1.0.0
+notifications:
+  slack: https://hooks.slack.com/services/T000/B000/XXXX
+  webhook: https://hooks.example.com/tako
+alerts:
+%s
+servers:
+  node-a:
+    host: 10.0.0.1
+    user: deploy
+    password: sshpass
+environments:
+  production:
+    servers: [node-a]
+    services:
+      web:
+        image: ghcr.io/acme/web:v1
+        port: 3000
+`
+
+func loadAlertsTestConfig(t *testing.T, block string) (*Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tako.yaml")
+	content := strings.Replace(alertsTestConfigTemplate, "%s", block, 1)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return LoadConfig(path)
+}
+
+func TestLoadConfigParsesAlerts(t *testing.T) {
+	cfg, err := loadAlertsTestConfig(t, `  web-memory:
+    metric: memory_percent
+    service: web
+    threshold: 90
+    duration: 5m
+    severity: critical
+    routes: [slack]
+  disk-full:
+    metric: disk_percent
+    threshold: 85`)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if names := cfg.AlertRuleNames(); len(names) != 2 || names[0] != "disk-full" || names[1] != "web-memory" {
+		t.Fatalf("rule names = %v", names)
+	}
+	memory := cfg.Alerts["web-memory"]
+	if memory.DurationSeconds() != 300 || memory.SeverityOrDefault() != "critical" {
+		t.Fatalf("web-memory = %+v", memory)
+	}
+	if routed := memory.RoutedNotifications(cfg.Notifications); routed == nil || routed.Slack == "" || routed.Webhook != "" {
+		t.Fatalf("routed notifications = %+v", routed)
+	}
+	disk := cfg.Alerts["disk-full"]
+	if disk.DurationSeconds() != 0 || disk.SeverityOrDefault() != DefaultAlertSeverity {
+		t.Fatalf("disk-full = %+v", disk)
+	}
+	if routed := disk.RoutedNotifications(cfg.Notifications); routed == nil || routed.Slack == "" || routed.Webhook == "" {
+		t.Fatalf("default routes = %+v", routed)
+	}
+}
+
+func TestLoadConfigRejectsInvalidAlerts(t *testing.T) {
+	for name, tc := range map[string]struct {
+		block string
+		want  string
+	}{
+		"metric":        {block: "  slow:\n    metric: latency\n    threshold: 1", want: "metric must be one of"},
+		"node metric":   {block: "  busy:\n    metric: load1\n    service: web\n    threshold: 4", want: "node metric"},
+		"service":       {block: "  busy:\n    metric: cpu_percent\n    service: api\n    threshold: 80", want: `service "api" is not defined`},
+		"percent":       {block: "  busy:\n    metric: cpu_percent\n    threshold: 120", want: "cannot exceed 100"},
+		"duration":      {block: "  busy:\n    metric: cpu_percent\n    threshold: 80\n    duration: soon", want: "duration must be a duration"},
+		"severity":      {block: "  busy:\n    metric: cpu_percent\n    threshold: 80\n    severity: page", want: "severity must be one of"},
+		"unknown route": {block: "  busy:\n    metric: cpu_percent\n    threshold: 80\n    routes: [email]", want: "route \"email\" must be one of"},
+		"unset route":   {block: "  busy:\n    metric: cpu_percent\n    threshold: 80\n    routes: [discord]", want: "needs notifications.discord"},
+		"name":          {block: "  Busy:\n    metric: cpu_percent\n    threshold: 80", want: "rule name \"Busy\" is invalid"},
+	} {
+		if _, err := loadAlertsTestConfig(t, tc.block); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: expected %q error, got %v", name, tc.want, err)
+		}
+	}
+}
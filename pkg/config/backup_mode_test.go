@@ -0,0 +1,186 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func backupModeValidationConfig(volumes []string, backup *BackupConfig) *Config {
+	cfg := validValidationConfig()
+	production := cfg.Environments["production"]
+	web := production.Services["web"]
+	web.Volumes = volumes
+	web.Backup = backup
+	production.Services["web"] = web
+	cfg.Environments["production"] = production
+	return cfg
+}
+
+func TestValidateConfigAcceptsBackupModes(t *testing.T) {
+	cfg := backupModeValidationConfig([]string{"pgdata:/var/lib/postgresql/data"}, &BackupConfig{
+		Schedule:  "@daily",
+		Mode:      " pg_dump ",
+		Database:  "app",
+		PreBackup: " psql -c CHECKPOINT ",
+	})
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig returned error: %v", err)
+	}
+	backup := cfg.Environments["production"].Services["web"].Backup
+	if backup.Mode != BackupModePgDump || backup.PreBackup != "psql -c CHECKPOINT" || !backup.IsDatabaseDump() {
+		t.Fatalf("backup = %+v", backup)
+	}
+
+	cfg = backupModeValidationConfig([]string{"data:/data", "cache:/cache"}, &BackupConfig{Schedule: "@daily", Mode: "volume"})
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig returned error: %v", err)
+	}
+	if mode := cfg.Environments["production"].Services["web"].Backup.Mode; mode != "" {
+		t.Fatalf("explicit volume mode kept as %q, want the default", mode)
+	}
+
+	cfg = backupModeValidationConfig([]string{"data:/data", "cache:/cache"}, &BackupConfig{Schedule: "@daily", Mode: BackupModeRedisBGSave, Volumes: []string{"data"}})
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("dump with one selected volume rejected: %v", err)
+	}
+}
+
+func TestValidateConfigRejectsInvalidBackupModes(t *testing.T) {
+	cases := []struct {
+		name    string
+		volumes []string
+		backup  BackupConfig
+		wantErr string
+	}{
+		{"unknown mode", []string{"data:/data"}, BackupConfig{Mode: "snapshot"}, "backup mode must be"},
+		{"hook without commands", []string{"data:/data"}, BackupConfig{Mode: BackupModeHook}, "requires backup.preBackup or backup.postBackup"},
+		{"database without dump", []string{"data:/data"}, BackupConfig{Mode: BackupModeRedisBGSave, Database: "app"}, "backup.database requires"},
+		{"unsafe database", []string{"data:/data"}, BackupConfig{Mode: BackupModePgDump, Database: "app; drop"}, "invalid backup.database"},
+		{"dump across volumes", []string{"data:/data", "cache:/cache"}, BackupConfig{Mode: BackupModeMySQLDump}, "single volume that holds the database"},
+		{"oversized hook", []string{"data:/data"}, BackupConfig{PostBackup: strings.Repeat("x", 4097)}, "4096 bytes or less"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			backup := tc.backup
+			backup.Schedule = "@daily"
+			err := ValidateConfig(backupModeValidationConfig(tc.volumes, &backup))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("error = %v, want substring %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateConfigChecksChunkedBackupStorage(t *testing.T) {
+	storage := func(format string, key string) *BackupConfig {
+		return &BackupConfig{Schedule: "@daily", Storage: &BackupStorageConfig{
+			Provider: BackupStorageProviderS3, Bucket: "backups", Region: "us-east-1",
+			AccessKeyID: "access", SecretAccessKey: "secret", Format: format, EncryptionKey: key,
+		}}
+	}
+	cfg := backupModeValidationConfig([]string{"data:/data"}, storage(" chunked ", " correct horse battery staple "))
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig returned error: %v", err)
+	}
+	if got := cfg.Environments["production"].Services["web"].Backup.Storage; got.Format != BackupStorageFormatChunked || got.EncryptionKey != "correct horse battery staple" {
+		t.Fatalf("storage = %+v", got)
+	}
+
+	cases := []struct {
+		name    string
+		backup  *BackupConfig
+		wantErr string
+	}{
+		{"missing key", storage(BackupStorageFormatChunked, ""), "encryptionKey is required"},
+		{"short key", storage(BackupStorageFormatChunked, "hunter2"), "at least 16 characters"},
+		{"key without chunks", storage(BackupStorageFormatArchive, "correct horse battery staple"), "requires backup.storage.format: chunked"},
+		{"unknown format", storage("restic", ""), "format must be archive or chunked"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateConfig(backupModeValidationConfig([]string{"data:/data"}, tc.backup))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("error = %v, want substring %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateConfigChecksBackupVerify(t *testing.T) {
+	cfg := backupModeValidationConfig([]string{"pgdata:/var/lib/postgresql/data"}, &BackupConfig{
+		Schedule: "@daily",
+		Verify:   &BackupVerifyConfig{Schedule: " 0 5 * * 0 ", Check: " pg_isready ", Timeout: "15m"},
+	})
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig returned error: %v", err)
+	}
+	verify := cfg.Environments["production"].Services["web"].Backup.Verify
+	if verify.Schedule != "0 5 * * 0" || verify.Check != "pg_isready" || !verify.Boot {
+		t.Fatalf("verify = %+v, want a check to imply boot", verify)
+	}
+
+	cases := []struct {
+		name    string
+		verify  BackupVerifyConfig
+		wantErr string
+	}{
+		{"missing schedule", BackupVerifyConfig{Boot: true}, "backup.verify.schedule is required"},
+		{"bad schedule", BackupVerifyConfig{Schedule: "sundays"}, "invalid backup.verify.schedule"},
+		{"oversized check", BackupVerifyConfig{Schedule: "@weekly", Check: strings.Repeat("x", 4097)}, "4096 bytes or less"},
+		{"short timeout", BackupVerifyConfig{Schedule: "@weekly", Timeout: "1s"}, "between 10s and 6h"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			verify := tc.verify
+			cfg := backupModeValidationConfig([]string{"pgdata:/var/lib/postgresql/data"}, &BackupConfig{Schedule: "@daily", Verify: &verify})
+			if err := ValidateConfig(cfg); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("ValidateConfig error = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateConfigBackupPITR(t *testing.T) {
+	pitrConfig := func(backup *BackupConfig, persistent bool) *Config {
+		cfg := backupModeValidationConfig([]string{"pgdata:/var/lib/postgresql/data"}, backup)
+		production := cfg.Environments["production"]
+		web := production.Services["web"]
+		web.Persistent = persistent
+		web.Placement = &PlacementConfig{Strategy: "pinned", Servers: []string{"node-a"}}
+		production.Services["web"] = web
+		cfg.Environments["production"] = production
+		return cfg
+	}
+	storage := func() *BackupStorageConfig {
+		return &BackupStorageConfig{Provider: BackupStorageProviderFilesystem, Path: "/mnt/nas/tako"}
+	}
+
+	cfg := pitrConfig(&BackupConfig{Schedule: "@daily", PITR: true, Storage: storage()}, true)
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig returned error: %v", err)
+	}
+	if backup := cfg.Environments["production"].Services["web"].Backup; backup.Mode != BackupModePgBaseBackup || !backup.IsDatabaseDump() {
+		t.Fatalf("backup = %+v", backup)
+	}
+	// A chunked target encrypts archived WAL along with the base backups.
+	chunked := pitrConfig(&BackupConfig{Schedule: "@daily", PITR: true, Storage: &BackupStorageConfig{
+		Provider: BackupStorageProviderFilesystem, Path: "/mnt/nas/tako", Format: BackupStorageFormatChunked, EncryptionKey: "correct horse battery staple",
+	}}, true)
+	if err := ValidateConfig(chunked); err != nil {
+		t.Fatalf("ValidateConfig with chunked storage returned error: %v", err)
+	}
+
+	for _, tc := range []struct {
+		backup     *BackupConfig
+		persistent bool
+		wantErr    string
+	}{
+		{&BackupConfig{Schedule: "@daily", PITR: true, Mode: BackupModePgDump, Storage: storage()}, true, "backup.pitr requires backup mode pg_basebackup"},
+		{&BackupConfig{Schedule: "@daily", PITR: true, Storage: storage()}, false, "requires persistent: true"},
+		{&BackupConfig{Schedule: "@daily", PITR: true}, true, "requires backup.storage"},
+	} {
+		if err := ValidateConfig(pitrConfig(tc.backup, tc.persistent)); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Fatalf("ValidateConfig error = %v, want substring %q", err, tc.wantErr)
+		}
+	}
+}
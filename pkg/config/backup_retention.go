@@ -0,0 +1,90 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxBackupRetentionBucket bounds each bucket; a year of hourlies is 8784.
+const maxBackupRetentionBucket = 10000
+
+// BackupRetention is either a flat number of days (`retain: 30`) or
+// grandfather-father-son buckets (`retain: {daily: 14, weekly: 8}`) that keep
+// the newest backup of each of the last N hours, days, weeks, months, and
+// years.
+type BackupRetention struct {
+	Days    int `yaml:"-" json:"-"`
+	Hourly  int `yaml:"hourly,omitempty" json:"hourly,omitempty"`
+	Daily   int `yaml:"daily,omitempty" json:"daily,omitempty"`
+	Weekly  int `yaml:"weekly,omitempty" json:"weekly,omitempty"`
+	Monthly int `yaml:"monthly,omitempty" json:"monthly,omitempty"`
+	Yearly  int `yaml:"yearly,omitempty" json:"yearly,omitempty"`
+}
+
+// IsGFS reports whether retention uses buckets instead of a flat day count.
+func (r BackupRetention) IsGFS() bool {
+	return r.Hourly != 0 || r.Daily != 0 || r.Weekly != 0 || r.Monthly != 0 || r.Yearly != 0
+}
+
+var backupRetentionFields = map[string]bool{"hourly": true, "daily": true, "weekly": true, "monthly": true, "yearly": true}
+
+func (r *BackupRetention) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if len(data) > 0 && data[0] != '{' {
+		var days int
+		if err := decoder.Decode(&days); err != nil {
+			return fmt.Errorf("backup retain must be a number of days or hourly/daily/weekly/monthly/yearly counts")
+		}
+		*r = BackupRetention{Days: days}
+		return nil
+	}
+	type plain BackupRetention
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode((*plain)(r)); err != nil {
+		return fmt.Errorf("invalid backup retain: %w", err)
+	}
+	return nil
+}
+
+func (r BackupRetention) MarshalJSON() ([]byte, error) {
+	if !r.IsGFS() {
+		return json.Marshal(r.Days)
+	}
+	type plain BackupRetention
+	return json.Marshal(plain(r))
+}
+
+func (r *BackupRetention) UnmarshalYAML(node *yaml.Node) error {
+	if node == nil {
+		return fmt.Errorf("backup retain is required")
+	}
+	if node.Kind == yaml.ScalarNode {
+		var days int
+		if err := node.Decode(&days); err != nil {
+			return fmt.Errorf("backup retain must be a number of days or hourly/daily/weekly/monthly/yearly counts")
+		}
+		*r = BackupRetention{Days: days}
+		return nil
+	}
+	type plain BackupRetention
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("backup retain must be a number of days or hourly/daily/weekly/monthly/yearly counts")
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if !backupRetentionFields[node.Content[i].Value] {
+			return fmt.Errorf("unknown backup retain field %q", node.Content[i].Value)
+		}
+	}
+	return node.Decode((*plain)(r))
+}
+
+func (r BackupRetention) MarshalYAML() (any, error) {
+	if !r.IsGFS() {
+		return r.Days, nil
+	}
+	type plain BackupRetention
+	return plain(r), nil
+}
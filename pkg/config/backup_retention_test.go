@@ -0,0 +1,83 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestBackupRetentionDecodesDaysAndBuckets(t *testing.T) {
+	type document struct {
+		Retain BackupRetention `yaml:"retain" json:"retain"`
+	}
+	for _, tt := range []struct {
+		name     string
+		yaml     string
+		want     BackupRetention
+		wantJSON string
+	}{
+		{name: "days", yaml: "retain: 30\n", want: BackupRetention{Days: 30}, wantJSON: `{"retain":30}`},
+		{name: "buckets", yaml: "retain: {hourly: 24, daily: 14, weekly: 8, monthly: 12}\n", want: BackupRetention{Hourly: 24, Daily: 14, Weekly: 8, Monthly: 12}, wantJSON: `{"retain":{"hourly":24,"daily":14,"weekly":8,"monthly":12}}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var got document
+			if err := yaml.Unmarshal([]byte(tt.yaml), &got); err != nil {
+				t.Fatalf("yaml.Unmarshal returned error: %v", err)
+			}
+			if got.Retain != tt.want {
+				t.Fatalf("retain = %+v, want %+v", got.Retain, tt.want)
+			}
+			encoded, err := json.Marshal(got)
+			if err != nil || string(encoded) != tt.wantJSON {
+				t.Fatalf("json = %s, %v; want %s", encoded, err, tt.wantJSON)
+			}
+			var roundTrip document
+			if err := json.Unmarshal(encoded, &roundTrip); err != nil || roundTrip.Retain != tt.want {
+				t.Fatalf("json round trip = %+v, %v", roundTrip.Retain, err)
+			}
+			reencoded, err := yaml.Marshal(got)
+			if err != nil {
+				t.Fatalf("yaml.Marshal returned error: %v", err)
+			}
+			var yamlRoundTrip document
+			if err := yaml.Unmarshal(reencoded, &yamlRoundTrip); err != nil || yamlRoundTrip.Retain != tt.want {
+				t.Fatalf("yaml round trip of %q = %+v, %v", reencoded, yamlRoundTrip.Retain, err)
+			}
+		})
+	}
+
+	var got document
+	if err := yaml.Unmarshal([]byte("retain: {quarterly: 4}\n"), &got); err == nil || !strings.Contains(err.Error(), `unknown backup retain field "quarterly"`) {
+		t.Fatalf("unknown bucket = %v", err)
+	}
+	if err := yaml.Unmarshal([]byte("retain: [7]\n"), &got); err == nil || !strings.Contains(err.Error(), "number of days or") {
+		t.Fatalf("list retain = %v", err)
+	}
+}
+
+func TestValidateConfigChecksBackupRetention(t *testing.T) {
+	cfg := backupModeValidationConfig([]string{"data:/data"}, &BackupConfig{Schedule: "@daily"})
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig returned error: %v", err)
+	}
+	if retain := cfg.Environments["production"].Services["web"].Backup.Retain; retain != (BackupRetention{Days: 7}) {
+		t.Fatalf("default retain = %+v, want 7 days", retain)
+	}
+
+	cfg = backupModeValidationConfig([]string{"data:/data"}, &BackupConfig{Schedule: "@hourly", Retain: BackupRetention{Hourly: 24, Yearly: 3}})
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig returned error: %v", err)
+	}
+	if retain := cfg.Environments["production"].Services["web"].Backup.Retain; retain.Days != 0 || !retain.IsGFS() {
+		t.Fatalf("bucketed retain = %+v, want no day default", retain)
+	}
+
+	for _, retain := range []BackupRetention{{Days: 4000}, {Daily: -1}, {Hourly: 10001}} {
+		cfg = backupModeValidationConfig([]string{"data:/data"}, &BackupConfig{Schedule: "@daily", Retain: retain})
+		if err := ValidateConfig(cfg); err == nil || !strings.Contains(err.Error(), "backup retain") {
+			t.Fatalf("retain %+v = %v", retain, err)
+		}
+	}
+}
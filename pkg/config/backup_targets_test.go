@@ -0,0 +1,51 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateConfigAcceptsBackupTargetProviders(t *testing.T) {
+	hostKey := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIHc6X4dZk2mFJ8nV6W0o5P5d6t1iVtPGx2Y3dHo7y4mS"
+	for _, storage := range []BackupStorageConfig{
+		{Provider: BackupStorageProviderSFTP, Host: " backup.example.com ", User: "tako", PrivateKey: "key", HostKey: hostKey, Path: "/srv/backups/"},
+		{Provider: BackupStorageProviderFilesystem, Path: "/mnt/nas/backups"},
+		{Provider: BackupStorageProviderPeer, Node: "worker-2", Format: BackupStorageFormatChunked, EncryptionKey: "correct horse battery staple"},
+	} {
+		storage := storage
+		cfg := backupModeValidationConfig([]string{"data:/data"}, &BackupConfig{Schedule: "@daily", Storage: &storage})
+		if err := ValidateConfig(cfg); err != nil {
+			t.Fatalf("ValidateConfig(%s) returned error: %v", storage.Provider, err)
+		}
+	}
+
+	storage := BackupStorageConfig{Provider: BackupStorageProviderSFTP, Host: "backup.example.com", User: "tako", Password: "secret", HostKey: hostKey, Path: "/srv/backups/"}
+	cfg := backupModeValidationConfig([]string{"data:/data"}, &BackupConfig{Schedule: "@daily", Storage: &storage})
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Environments["production"].Services["web"].Backup.Storage; got.Port != 22 || got.Path != "/srv/backups" {
+		t.Fatalf("sftp storage = %+v, want port 22 and trimmed path", got)
+	}
+}
+
+func TestValidateConfigRejectsIncompleteBackupTargets(t *testing.T) {
+	hostKey := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIHc6X4dZk2mFJ8nV6W0o5P5d6t1iVtPGx2Y3dHo7y4mS"
+	for _, tt := range []struct {
+		storage BackupStorageConfig
+		want    string
+	}{
+		{BackupStorageConfig{Provider: BackupStorageProviderSFTP, Host: "backup.example.com", User: "tako", Password: "secret"}, "backup.storage.hostKey is required"},
+		{BackupStorageConfig{Provider: BackupStorageProviderSFTP, Host: "backup.example.com", User: "tako", HostKey: hostKey}, "privateKey or backup.storage.password"},
+		{BackupStorageConfig{Provider: BackupStorageProviderSFTP, Host: "bad host", User: "tako", Password: "secret", HostKey: hostKey}, "backup.storage.host"},
+		{BackupStorageConfig{Provider: BackupStorageProviderFilesystem, Path: "relative/backups"}, "clean absolute directory"},
+		{BackupStorageConfig{Provider: BackupStorageProviderPeer}, "backup.storage.node is required"},
+		{BackupStorageConfig{Provider: "ftp"}, "sftp, filesystem, or peer"},
+	} {
+		storage := tt.storage
+		cfg := backupModeValidationConfig([]string{"data:/data"}, &BackupConfig{Schedule: "@daily", Storage: &storage})
+		if err := ValidateConfig(cfg); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("ValidateConfig(%+v) = %v, want %q", tt.storage, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/redentordev/tako-cli/pkg/logship"
+)
+
+const (
+	maxLoggingSinks      = 8
+	maxLoggingBufferSize = 1000000
+)
+
+// LoggingConfig ships the environment's container logs, and optionally
+// the proxy access log lines its routes serve, to external sinks. Every
+// node running the environment forwards its own logs.
+type LoggingConfig struct {
+	Sinks []LogSinkConfig `yaml:"sinks" json:"sinks"`
+	// AccessLogs also ships proxy access log lines attributed to the
+	// environment's routes from the nodes that run the proxy.
+	AccessLogs bool `yaml:"accessLogs,omitempty" json:"accessLogs,omitempty"`
+	// BufferSize caps the entries buffered per sink before reading blocks;
+	// 0 uses the default of 10000.
+	BufferSize int `yaml:"bufferSize,omitempty" json:"bufferSize,omitempty"`
+}
+
+// LogSinkConfig is one log destination: type loki or http posts to URL,
+// type syslog writes RFC 5424 over TCP (or TLS) to Address. Headers may
+// carry credentials; reference them as ${ENV_VAR}.
+type LogSinkConfig struct {
+	Name     string            `yaml:"name,omitempty" json:"name,omitempty"`
+	Type     string            `yaml:"type" json:"type"`
+	URL      string            `yaml:"url,omitempty" json:"url,omitempty"`
+	Address  string            `yaml:"address,omitempty" json:"address,omitempty"`
+	TLS      bool              `yaml:"tls,omitempty" json:"tls,omitempty"`
+	Facility string            `yaml:"facility,omitempty" json:"facility,omitempty"`
+	Headers  map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	Labels   map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+}
+
+// SinkConfig converts the sink to the shape takod ships with.
+func (c LogSinkConfig) SinkConfig() logship.SinkConfig {
+	return logship.SinkConfig{
+		Name:     c.Name,
+		Type:     c.Type,
+		URL:      c.URL,
+		Address:  c.Address,
+		TLS:      c.TLS,
+		Facility: c.Facility,
+		Headers:  c.Headers,
+		Labels:   c.Labels,
+	}
+}
+
+// validateLogging validates the logging block.
+func validateLogging(logging *LoggingConfig) error {
+	if logging == nil {
+		return nil
+	}
+	if len(logging.Sinks) == 0 {
+		return fmt.Errorf("logging: at least one sink is required")
+	}
+	if len(logging.Sinks) > maxLoggingSinks {
+		return fmt.Errorf("logging: at most %d sinks are allowed", maxLoggingSinks)
+	}
+	names := map[string]bool{}
+	for i, sink := range logging.Sinks {
+		shipped := sink.SinkConfig()
+		if err := logship.ValidateSinkConfig(shipped); err != nil {
+			return fmt.Errorf("logging: sinks[%d]: %w", i, err)
+		}
+		if names[shipped.DisplayName()] {
+			return fmt.Errorf("logging: duplicate sink %q; give sinks of the same type distinct names", shipped.DisplayName())
+		}
+		names[shipped.DisplayName()] = true
+	}
+	if logging.BufferSize < 0 || logging.BufferSize > maxLoggingBufferSize {
+		return fmt.Errorf("logging: bufferSize must be between 0 and %d", maxLoggingBufferSize)
+	}
+	return nil
+}
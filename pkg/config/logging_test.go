@@ -0,0 +1,85 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const loggingTestConfigTemplate = `project:
+  name: demo
+  version: 1.0.0
+logging:
+  accessLogs: true
+%s
+servers:
+  node-a:
+    host: 10.0.0.1
+    user: deploy
+    password: sshpass
+environments:
+  production:
+    servers: [node-a]
+    services:
+      web:
+        image: ghcr.io/acme/web:v1
+        port: 3000
+`
+
+func loadLoggingTestConfig(t *testing.T, sinks string) (*Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tako.yaml")
+	content := strings.Replace(loggingTestConfigTemplate, "%s", sinks, 1)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return LoadConfig(path)
+}
+
+func TestLoadConfigParsesLoggingSinks(t *testing.T) {
+	t.Setenv("TAKO_TEST_LOKI_TOKEN", "loki-token")
+	cfg, err := loadLoggingTestConfig(t, `  sinks:
+    - type: loki
+      url: https://loki.example.com/loki/api/v1/push
+      headers:
+        Authorization: Bearer ${TAKO_TEST_LOKI_TOKEN}
+      labels:
+        team: payments
+    - type: syslog
+      address: logs.example.com:6514
+      tls: true
+      facility: local0`)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Logging == nil || !cfg.Logging.AccessLogs || len(cfg.Logging.Sinks) != 2 {
+		t.Fatalf("logging = %+v", cfg.Logging)
+	}
+	loki := cfg.Logging.Sinks[0].SinkConfig()
+	if loki.Headers["Authorization"] != "Bearer loki-token" || loki.Labels["team"] != "payments" {
+		t.Fatalf("loki sink = %+v", loki)
+	}
+	if syslog := cfg.Logging.Sinks[1]; !syslog.TLS || syslog.Facility != "local0" {
+		t.Fatalf("syslog sink = %+v", syslog)
+	}
+}
+
+func TestLoadConfigRejectsInvalidLoggingSinks(t *testing.T) {
+	for name, tc := range map[string]struct {
+		sinks string
+		want  string
+	}{
+		"no sinks":  {sinks: "  sinks: []", want: "at least one sink"},
+		"bad type":  {sinks: "  sinks:\n    - type: kafka", want: "unknown sink type"},
+		"bad url":   {sinks: "  sinks:\n    - type: http\n      url: logs.example.com", want: "http(s) URL"},
+		"duplicate": {sinks: "  sinks:\n    - type: http\n      url: https://a.example.com\n    - type: http\n      url: https://b.example.com", want: "duplicate sink"},
+		"reserved":  {sinks: "  sinks:\n    - type: loki\n      url: https://loki.example.com\n      labels:\n        service: web", want: "set by tako"},
+		"sd name":   {sinks: "  sinks:\n    - type: syslog\n      address: logs.example.com:6514\n      labels:\n        \"team=ops\": web", want: "invalid sink label name"},
+		"long name": {sinks: "  sinks:\n    - type: syslog\n      address: logs.example.com:6514\n      labels:\n        " + strings.Repeat("a", 33) + ": web", want: "invalid sink label name"},
+	} {
+		if _, err := loadLoggingTestConfig(t, tc.sinks); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: expected %q error, got %v", name, tc.want, err)
+		}
+	}
+}
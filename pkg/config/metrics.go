@@ -0,0 +1,89 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultMetricsPort is where takod serves /metrics when the metrics
+	// block leaves port unset.
+	DefaultMetricsPort = 9465
+	// minMetricsTokenLength keeps scrape tokens out of guessing range.
+	minMetricsTokenLength = 16
+)
+
+// MetricsConfig has takod on every node running the environment serve a
+// Prometheus scrape target on the node's mesh address. Scrapes must send
+// Token as a bearer token and see node metrics plus this environment's
+// containers, jobs, backups, certificates, lease, and proxy routes.
+type MetricsConfig struct {
+	// Port is the TCP port on the mesh address; 0 uses 9465.
+	Port int `yaml:"port,omitempty" json:"port,omitempty"`
+	// Token must be an environment variable reference like
+	// ${TAKO_METRICS_TOKEN}; literal tokens in the config file are rejected.
+	Token string `yaml:"token" json:"token"`
+}
+
+// ListenPort returns the configured port or the default.
+func (c *MetricsConfig) ListenPort() int {
+	if c == nil || c.Port == 0 {
+		return DefaultMetricsPort
+	}
+	return c.Port
+}
+
+// rawMetricsDocument is the pre-expansion shadow of the metrics block.
+type rawMetricsDocument struct {
+	Metrics *struct {
+		Token string `yaml:"token" json:"token"`
+	} `yaml:"metrics" json:"metrics"`
+}
+
+// validateRawMetricsToken rejects a literal scrape token in the raw config
+// content, before ${VAR} expansion erases the distinction.
+func validateRawMetricsToken(data []byte, isJSON bool) error {
+	var doc rawMetricsDocument
+	if isJSON {
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil // the strict parse after expansion reports the real error
+		}
+	} else if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil
+	}
+	if doc.Metrics == nil {
+		return nil
+	}
+	token := strings.TrimSpace(doc.Metrics.Token)
+	if token != "" && !envRefPattern.MatchString(token) {
+		return fmt.Errorf("metrics: token must be an environment variable reference like ${TAKO_METRICS_TOKEN}; literal credentials in the config file are not allowed")
+	}
+	return nil
+}
+
+// validateMetrics validates the expanded metrics block.
+func validateMetrics(cfg *Config) error {
+	metrics := cfg.Metrics
+	if metrics == nil {
+		return nil
+	}
+	if metrics.Port < 0 || metrics.Port > 65535 {
+		return fmt.Errorf("metrics: port must be between 1 and 65535")
+	}
+	if metrics.Token == "" {
+		return fmt.Errorf("metrics: token is required (use ${ENV_VAR})")
+	}
+	if len(metrics.Token) < minMetricsTokenLength {
+		return fmt.Errorf("metrics: token must be at least %d characters", minMetricsTokenLength)
+	}
+	if hasConfigControlChars(metrics.Token) || strings.ContainsAny(metrics.Token, " \t") {
+		return fmt.Errorf("metrics: token must not contain whitespace or control characters")
+	}
+	if !cfg.IsMeshEnabled() {
+		return fmt.Errorf("metrics: the scrape target listens on the mesh address, so mesh must be enabled")
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const metricsTestConfigTemplate = `project:
+  name: demo
+  version: 1.0.0
+metrics:
+%s
+servers:
+  node-a:
+    host: 10.0.0.1
+    user: deploy
+    password: sshpass
+environments:
+  production:
+    servers: [node-a]
+    services:
+      web:
+        image: ghcr.io/acme/web:v1
+        port: 3000
+`
+
+func loadMetricsTestConfig(t *testing.T, block string) (*Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tako.yaml")
+	content := strings.Replace(metricsTestConfigTemplate, "%s", block, 1)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return LoadConfig(path)
+}
+
+func TestLoadConfigParsesMetrics(t *testing.T) {
+	t.Setenv("TAKO_TEST_METRICS_TOKEN", "scrape-token-0123456789")
+	cfg, err := loadMetricsTestConfig(t, "  token: ${TAKO_TEST_METRICS_TOKEN}")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Metrics == nil || cfg.Metrics.Token != "scrape-token-0123456789" || cfg.Metrics.ListenPort() != DefaultMetricsPort {
+		t.Fatalf("metrics = %+v", cfg.Metrics)
+	}
+}
+
+func TestLoadConfigRejectsInvalidMetrics(t *testing.T) {
+	t.Setenv("TAKO_TEST_METRICS_TOKEN", "scrape-token-0123456789")
+	t.Setenv("TAKO_TEST_SHORT_TOKEN", "short")
+	for name, tc := range map[string]struct {
+		block string
+		want  string
+	}{
+		"literal":  {block: "  token: scrape-token-0123456789", want: "environment variable reference"},
+		"short":    {block: "  token: ${TAKO_TEST_SHORT_TOKEN}", want: "at least 16 characters"},
+		"bad port": {block: "  port: 70000\n  token: ${TAKO_TEST_METRICS_TOKEN}", want: "port must be between"},
+	} {
+		if _, err := loadMetricsTestConfig(t, tc.block); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: expected %q error, got %v", name, tc.want, err)
+		}
+	}
+}
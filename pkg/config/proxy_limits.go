@@ -0,0 +1,141 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ProxyRateLimitKeyIP = "ip"
+
+	proxyRateLimitHeaderKeyPrefix = "header:"
+	maxProxyRateLimitRequests     = 1000000
+	maxProxyRateLimitWindow       = 24 * time.Hour
+	maxProxyBodySize              = 10 << 30
+)
+
+// ProxyRateLimitConfig allows Requests per Window for each client of a
+// proxy route.
+type ProxyRateLimitConfig struct {
+	Requests int    `yaml:"requests" json:"requests"`
+	Window   string `yaml:"window" json:"window"`
+	// Key identifies a client: "ip" (the default) or "header:<Name>", e.g.
+	// header:X-Api-Key. The client IP honors proxy.trustedProxies.
+	Key string `yaml:"key,omitempty" json:"key,omitempty"`
+	// Paths limits throttling to these request paths, e.g. ["/login"]; a
+	// trailing * matches a prefix.
+	Paths []string `yaml:"paths,omitempty" json:"paths,omitempty"`
+}
+
+// KeyHeader returns the request header that keys the limit, or "" when
+// clients are keyed by IP.
+func (c *ProxyRateLimitConfig) KeyHeader() string {
+	if c == nil || !strings.HasPrefix(c.Key, proxyRateLimitHeaderKeyPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(c.Key, proxyRateLimitHeaderKeyPrefix)
+}
+
+// MaxBodyBytes returns the validated proxy.maxBodySize in bytes, or 0 when
+// request bodies are not limited.
+func (p *ProxyConfig) MaxBodyBytes() int64 {
+	if p == nil {
+		return 0
+	}
+	size, err := parseProxyBodySize(p.MaxBodySize)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// validateProxyLimits validates and normalizes proxy.rateLimit and
+// proxy.maxBodySize.
+func validateProxyLimits(serviceName string, proxy *ProxyConfig) error {
+	if proxy.MaxBodySize != "" {
+		size, err := parseProxyBodySize(proxy.MaxBodySize)
+		if err != nil {
+			return fmt.Errorf("service %s: invalid proxy.maxBodySize %q: %w", serviceName, proxy.MaxBodySize, err)
+		}
+		if size > maxProxyBodySize {
+			return fmt.Errorf("service %s: proxy.maxBodySize must be at most 10gb", serviceName)
+		}
+	}
+
+	limit := proxy.RateLimit
+	if limit == nil {
+		return nil
+	}
+	if limit.Requests < 1 || limit.Requests > maxProxyRateLimitRequests {
+		return fmt.Errorf("service %s: proxy.rateLimit.requests must be between 1 and %d", serviceName, maxProxyRateLimitRequests)
+	}
+	window, err := time.ParseDuration(strings.TrimSpace(limit.Window))
+	if err != nil || window < time.Second || window > maxProxyRateLimitWindow {
+		return fmt.Errorf("service %s: proxy.rateLimit.window must be a duration between 1s and 24h (got %q)", serviceName, limit.Window)
+	}
+	limit.Window = window.String()
+
+	limit.Key = strings.TrimSpace(limit.Key)
+	switch {
+	case limit.Key == "" || strings.EqualFold(limit.Key, ProxyRateLimitKeyIP):
+		limit.Key = ProxyRateLimitKeyIP
+	case strings.HasPrefix(strings.ToLower(limit.Key), proxyRateLimitHeaderKeyPrefix):
+		header := strings.TrimSpace(limit.Key[len(proxyRateLimitHeaderKeyPrefix):])
+		if !proxyHeaderNamePattern.MatchString(header) {
+			return fmt.Errorf("service %s: invalid proxy.rateLimit.key header %q", serviceName, header)
+		}
+		limit.Key = proxyRateLimitHeaderKeyPrefix + header
+	default:
+		return fmt.Errorf("service %s: proxy.rateLimit.key must be ip or header:<Name>", serviceName)
+	}
+
+	if len(limit.Paths) > maxProxyPaths {
+		return fmt.Errorf("service %s: proxy.rateLimit.paths allows at most %d paths", serviceName, maxProxyPaths)
+	}
+	if len(limit.Paths) > 0 && proxy.StripPrefix {
+		return fmt.Errorf("service %s: proxy.rateLimit.paths cannot be combined with proxy.stripPrefix; the limit would match the stripped path", serviceName)
+	}
+	for i, path := range limit.Paths {
+		path = strings.TrimSpace(path)
+		if !proxyPathPattern.MatchString(path) || path == "/*" || len(path) > 256 {
+			return fmt.Errorf("service %s: invalid proxy.rateLimit path %q", serviceName, path)
+		}
+		limit.Paths[i] = path
+	}
+	return nil
+}
+
+// parseProxyBodySize parses a size such as 512kb, 10mb, or 1gib into bytes.
+// Decimal and binary units are both powers of 1024, as in Docker limits.
+func parseProxyBodySize(value string) (int64, error) {
+	normalized, err := normalizeDockerMemoryLimit(value)
+	if err != nil {
+		return 0, err
+	}
+	if normalized == "" {
+		return 0, nil
+	}
+	unitStart := len(normalized)
+	for unitStart > 0 && normalized[unitStart-1] >= 'a' && normalized[unitStart-1] <= 'z' {
+		unitStart--
+	}
+	number, err := strconv.ParseInt(normalized[:unitStart], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	multiplier := int64(1)
+	switch normalized[unitStart:] {
+	case "k", "kb", "kib":
+		multiplier = 1 << 10
+	case "m", "mb", "mib":
+		multiplier = 1 << 20
+	case "g", "gb", "gib":
+		multiplier = 1 << 30
+	}
+	if number > maxProxyBodySize/multiplier+1 {
+		return 0, fmt.Errorf("size is too large")
+	}
+	return number * multiplier, nil
+}
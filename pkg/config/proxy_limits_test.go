@@ -0,0 +1,66 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateConfigNormalizesProxyLimits(t *testing.T) {
+	cfg := multiDomainValidationConfig(func(p *ProxyConfig) {
+		p.MaxBodySize = "10MB"
+		p.RateLimit = &ProxyRateLimitConfig{Requests: 5, Window: "60s", Key: "Header: X-Api-Key", Paths: []string{" /login "}}
+	})
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig returned error: %v", err)
+	}
+	proxy := cfg.Environments["production"].Services["web"].Proxy
+	if proxy.MaxBodyBytes() != 10<<20 {
+		t.Fatalf("MaxBodyBytes = %d", proxy.MaxBodyBytes())
+	}
+	limit := proxy.RateLimit
+	if limit.Window != "1m0s" || limit.Key != "header:X-Api-Key" || limit.KeyHeader() != "X-Api-Key" || limit.Paths[0] != "/login" {
+		t.Fatalf("rate limit = %+v", limit)
+	}
+
+	cfg = multiDomainValidationConfig(func(p *ProxyConfig) { p.RateLimit = &ProxyRateLimitConfig{Requests: 5, Window: "1m"} })
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig returned error: %v", err)
+	}
+	if limit := cfg.Environments["production"].Services["web"].Proxy.RateLimit; limit.Key != ProxyRateLimitKeyIP || limit.KeyHeader() != "" {
+		t.Fatalf("default key = %+v", limit)
+	}
+}
+
+func TestValidateConfigRejectsInvalidProxyLimits(t *testing.T) {
+	cases := []struct {
+		name    string
+		mutate  func(*ProxyConfig)
+		wantErr string
+	}{
+		{"body unit", func(p *ProxyConfig) { p.MaxBodySize = "10tb" }, "invalid proxy.maxBodySize"},
+		{"body too large", func(p *ProxyConfig) { p.MaxBodySize = "11gb" }, "at most 10gb"},
+		{"no requests", func(p *ProxyConfig) { p.RateLimit = &ProxyRateLimitConfig{Window: "1m"} }, "requests must be between"},
+		{"short window", func(p *ProxyConfig) { p.RateLimit = &ProxyRateLimitConfig{Requests: 1, Window: "500ms"} }, "window must be a duration"},
+		{"bad key", func(p *ProxyConfig) {
+			p.RateLimit = &ProxyRateLimitConfig{Requests: 1, Window: "1m", Key: "cookie:session"}
+		}, "must be ip or header"},
+		{"bad header", func(p *ProxyConfig) {
+			p.RateLimit = &ProxyRateLimitConfig{Requests: 1, Window: "1m", Key: "header:X Key"}
+		}, "invalid proxy.rateLimit.key header"},
+		{"bad path", func(p *ProxyConfig) {
+			p.RateLimit = &ProxyRateLimitConfig{Requests: 1, Window: "1m", Paths: []string{"login"}}
+		}, "invalid proxy.rateLimit path"},
+		{"paths with stripPrefix", func(p *ProxyConfig) {
+			p.Paths, p.StripPrefix = []string{"/api/*"}, true
+			p.RateLimit = &ProxyRateLimitConfig{Requests: 1, Window: "1m", Paths: []string{"/api/login"}}
+		}, "cannot be combined with proxy.stripPrefix"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateConfig(multiDomainValidationConfig(tc.mutate))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("error = %v, want substring %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func pathRoutingValidationConfig(api *ProxyConfig) *Config {
+	cfg := multiDomainValidationConfig(func(p *ProxyConfig) { p.Domains = nil })
+	production := cfg.Environments["production"]
+	production.Services["api"] = ServiceConfig{Image: "nginx:alpine", Port: 3000, Proxy: api}
+	cfg.Environments["production"] = production
+	return cfg
+}
+
+func TestValidateConfigAcceptsPathRoutesOnSharedDomain(t *testing.T) {
+	cfg := pathRoutingValidationConfig(&ProxyConfig{Domain: "example.com", Paths: []string{" /api/* ", "/healthz"}, StripPrefix: true})
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig returned error: %v", err)
+	}
+	if got := strings.Join(cfg.Environments["production"].Services["api"].Proxy.Paths, ","); got != "/api/*,/healthz" {
+		t.Fatalf("paths = %q", got)
+	}
+
+	cfg = pathRoutingValidationConfig(&ProxyConfig{Domain: "other.example.com", Paths: []string{"/"}})
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig returned error: %v", err)
+	}
+	if paths := cfg.Environments["production"].Services["api"].Proxy.Paths; paths != nil {
+		t.Fatalf("catch-all path kept as %v, want no paths", paths)
+	}
+}
+
+func TestValidateConfigRejectsInvalidPathRoutes(t *testing.T) {
+	cases := []struct {
+		name    string
+		proxy   *ProxyConfig
+		wantErr string
+	}{
+		{"relative", &ProxyConfig{Domain: "example.com", Paths: []string{"api/*"}}, "invalid proxy path"},
+		{"inner wildcard", &ProxyConfig{Domain: "example.com", Paths: []string{"/api/*/v1"}}, "invalid proxy path"},
+		{"duplicate", &ProxyConfig{Domain: "example.com", Paths: []string{"/api/*", "/api/*"}}, "duplicate proxy path"},
+		{"catch-all mixed", &ProxyConfig{Domain: "example.com", Paths: []string{"/", "/api/*"}}, "cannot mix /"},
+		{"strip without paths", &ProxyConfig{Domain: "example.com", StripPrefix: true}, "stripPrefix requires proxy.paths"},
+		{"whole domain conflict", &ProxyConfig{Domain: "example.com"}, "domain conflict"},
+		{"redirect conflict", &ProxyConfig{Domain: "api.example.com", Paths: []string{"/api/*"}, RedirectFrom: []string{"example.com"}}, "domain conflict"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateConfig(pathRoutingValidationConfig(tc.proxy))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("error = %v, want substring %q", err, tc.wantErr)
+			}
+		})
+	}
+
+	cfg := pathRoutingValidationConfig(&ProxyConfig{Domain: "example.com", Paths: []string{"/api/*"}})
+	production := cfg.Environments["production"]
+	production.Services["api-v2"] = ServiceConfig{Image: "nginx:alpine", Port: 3000, Proxy: &ProxyConfig{Domain: "example.com", Paths: []string{"/api/*"}}}
+	cfg.Environments["production"] = production
+	if err := ValidateConfig(cfg); err == nil || !strings.Contains(err.Error(), "path 'example.com/api/*' is routed to both") {
+		t.Fatalf("error = %v, want path conflict", err)
+	}
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	SecretProviderVault             = "vault"
+	SecretProviderOnePassword       = "1password"
+	SecretProviderDoppler           = "doppler"
+	SecretProviderGCP               = "gcp-secret-manager"
+	SecretProviderSOPS              = "sops"
+	SecretProviderAWSSSM            = "aws-ssm"
+	SecretProviderAWSSecretsManager = "aws-secrets-manager"
+
+	maxSecretProviderCacheTTL = 24 * time.Hour
+)
+
+var secretProviderTokenEnvPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SecretProviderConfig reads the environment's secrets from an external store
+// at deploy time. Keys also set in the .tako/secrets files keep the file's
+// value. The credential is never written in tako.yaml: each provider reads its usual
+// environment variable (VAULT_TOKEN, OP_CONNECT_TOKEN, DOPPLER_TOKEN,
+// GOOGLE_OAUTH_ACCESS_TOKEN) unless TokenEnv names another one.
+type SecretProviderConfig struct {
+	Provider string `yaml:"provider" json:"provider"`                   // vault, 1password, doppler, gcp-secret-manager, sops, aws-ssm, aws-secrets-manager
+	Address  string `yaml:"address,omitempty" json:"address,omitempty"` // Vault or 1Password Connect URL (default: VAULT_ADDR, OP_CONNECT_HOST); API endpoint override for Doppler and GCP
+	Mount    string `yaml:"mount,omitempty" json:"mount,omitempty"`     // Vault KV v2 mount (default: secret)
+	// Path selects what to read: the Vault secret path under Mount, the
+	// 1Password "vault/item", the Doppler "project/config", the SOPS file,
+	// or the AWS SSM parameter path.
+	Path      string `yaml:"path,omitempty" json:"path,omitempty"`
+	Project   string `yaml:"project,omitempty" json:"project,omitempty"`     // GCP project (default: GOOGLE_CLOUD_PROJECT)
+	Version   string `yaml:"version,omitempty" json:"version,omitempty"`     // pin a Vault or GCP secret version (default: latest)
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"` // Vault Enterprise namespace
+	TokenEnv  string `yaml:"tokenEnv,omitempty" json:"tokenEnv,omitempty"`   // environment variable holding the provider token
+	Profile   string `yaml:"profile,omitempty" json:"profile,omitempty"`     // AWS CLI profile
+	Region    string `yaml:"region,omitempty" json:"region,omitempty"`       // AWS region
+	CacheTTL  string `yaml:"cacheTTL,omitempty" json:"cacheTTL,omitempty"`   // how long fetched values are reused within one run (default: 5m)
+}
+
+// EnvironmentSecretProvider returns the environment's secret provider, or
+// nil when secrets only come from .tako/secrets files.
+func (c *Config) EnvironmentSecretProvider(envName string) *SecretProviderConfig {
+	if c == nil {
+		return nil
+	}
+	env, ok := c.Environments[envName]
+	if !ok {
+		return nil
+	}
+	return env.SecretProvider
+}
+
+func validateSecretProvider(envName string, provider *SecretProviderConfig) error {
+	if provider == nil {
+		return nil
+	}
+	provider.Provider = strings.ToLower(strings.TrimSpace(provider.Provider))
+	provider.Address = strings.TrimRight(strings.TrimSpace(provider.Address), "/")
+	provider.Mount = strings.Trim(strings.TrimSpace(provider.Mount), "/")
+	provider.Path = strings.TrimSpace(provider.Path)
+	provider.Version = strings.TrimSpace(provider.Version)
+	provider.TokenEnv = strings.TrimSpace(provider.TokenEnv)
+	field := func(name string) string {
+		return fmt.Sprintf("environment %s secretProvider.%s", envName, name)
+	}
+
+	switch provider.Provider {
+	case SecretProviderVault:
+		if strings.Trim(provider.Path, "/") == "" {
+			return fmt.Errorf("%s is required for vault", field("path"))
+		}
+	case SecretProviderOnePassword:
+		if vault, item, ok := strings.Cut(strings.Trim(provider.Path, "/"), "/"); !ok || vault == "" || item == "" {
+			return fmt.Errorf("%s must be vault/item for 1password", field("path"))
+		}
+	case SecretProviderDoppler:
+		if provider.Path != "" {
+			if project, cfg, ok := strings.Cut(strings.Trim(provider.Path, "/"), "/"); !ok || project == "" || cfg == "" {
+				return fmt.Errorf("%s must be project/config for doppler", field("path"))
+			}
+		}
+	case SecretProviderGCP:
+	case SecretProviderSOPS:
+		if provider.Path == "" {
+			return fmt.Errorf("%s is required for sops (the encrypted file)", field("path"))
+		}
+	case SecretProviderAWSSSM, SecretProviderAWSSecretsManager:
+	default:
+		return fmt.Errorf("%s must be one of vault, 1password, doppler, gcp-secret-manager, sops, aws-ssm, aws-secrets-manager", field("provider"))
+	}
+
+	if provider.Address != "" {
+		parsed, err := url.Parse(provider.Address)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			return fmt.Errorf("%s must be an http(s) URL", field("address"))
+		}
+	}
+	if provider.Version != "" {
+		switch provider.Provider {
+		case SecretProviderVault, SecretProviderGCP:
+		default:
+			return fmt.Errorf("%s is only supported for vault and gcp-secret-manager", field("version"))
+		}
+		if provider.Version != "latest" && strings.Trim(provider.Version, "0123456789") != "" {
+			return fmt.Errorf("%s must be a version number", field("version"))
+		}
+	}
+	if provider.TokenEnv != "" && !secretProviderTokenEnvPattern.MatchString(provider.TokenEnv) {
+		return fmt.Errorf("%s must be an environment variable name like VAULT_TOKEN", field("tokenEnv"))
+	}
+	if provider.CacheTTL != "" {
+		ttl, err := time.ParseDuration(provider.CacheTTL)
+		if err != nil || ttl < 0 || ttl > maxSecretProviderCacheTTL {
+			return fmt.Errorf("%s must be a duration between 0s and %s", field("cacheTTL"), maxSecretProviderCacheTTL)
+		}
+	}
+	for name, value := range map[string]string{
+		"address": provider.Address, "mount": provider.Mount, "path": provider.Path, "project": provider.Project,
+		"namespace": provider.Namespace, "profile": provider.Profile, "region": provider.Region,
+	} {
+		if hasConfigControlChars(value) {
+			return fmt.Errorf("%s contains control characters", field(name))
+		}
+	}
+	return nil
+}
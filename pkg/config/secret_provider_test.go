@@ -0,0 +1,57 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateConfigSecretProvider(t *testing.T) {
+	withProvider := func(provider *SecretProviderConfig) *Config {
+		cfg := validValidationConfig()
+		production := cfg.Environments["production"]
+		production.SecretProvider = provider
+		cfg.Environments["production"] = production
+		return cfg
+	}
+
+	cfg := withProvider(&SecretProviderConfig{Provider: " Vault ", Address: "https://vault.internal:8200/", Mount: "/kv/", Path: "myapp/production", Version: "4", TokenEnv: "TEAM_VAULT_TOKEN"})
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig returned error: %v", err)
+	}
+	provider := cfg.EnvironmentSecretProvider("production")
+	if provider.Provider != SecretProviderVault || provider.Address != "https://vault.internal:8200" || provider.Mount != "kv" {
+		t.Fatalf("provider = %+v", provider)
+	}
+	for _, valid := range []*SecretProviderConfig{
+		{Provider: SecretProviderOnePassword, Path: "Production/myapp"},
+		{Provider: SecretProviderDoppler},
+		{Provider: SecretProviderDoppler, Path: "myapp/prd"},
+		{Provider: SecretProviderGCP, Project: "acme", Version: "latest"},
+		{Provider: SecretProviderSOPS, Path: "secrets/production.enc.yaml", CacheTTL: "0s"},
+		{Provider: SecretProviderAWSSSM, Path: "/myapp/production", Region: "us-east-1"},
+	} {
+		if err := ValidateConfig(withProvider(valid)); err != nil {
+			t.Fatalf("ValidateConfig(%+v) returned error: %v", valid, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		provider *SecretProviderConfig
+		wantErr  string
+	}{
+		{&SecretProviderConfig{Provider: "keepass"}, "secretProvider.provider must be one of"},
+		{&SecretProviderConfig{Provider: SecretProviderVault}, "secretProvider.path is required for vault"},
+		{&SecretProviderConfig{Provider: SecretProviderOnePassword, Path: "myapp"}, "vault/item"},
+		{&SecretProviderConfig{Provider: SecretProviderDoppler, Path: "myapp"}, "project/config"},
+		{&SecretProviderConfig{Provider: SecretProviderSOPS}, "secretProvider.path is required for sops"},
+		{&SecretProviderConfig{Provider: SecretProviderVault, Path: "app", Address: "vault.internal"}, "http(s) URL"},
+		{&SecretProviderConfig{Provider: SecretProviderDoppler, Version: "3"}, "only supported for vault"},
+		{&SecretProviderConfig{Provider: SecretProviderVault, Path: "app", Version: "v3"}, "version number"},
+		{&SecretProviderConfig{Provider: SecretProviderVault, Path: "app", TokenEnv: "${VAULT_TOKEN}"}, "environment variable name"},
+		{&SecretProviderConfig{Provider: SecretProviderVault, Path: "app", CacheTTL: "48h"}, "cacheTTL"},
+	} {
+		if err := ValidateConfig(withProvider(tc.provider)); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Fatalf("ValidateConfig(%+v) error = %v, want substring %q", tc.provider, err, tc.wantErr)
+		}
+	}
+}
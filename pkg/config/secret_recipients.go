@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/redentordev/tako-cli/pkg/crypto"
+)
+
+// EnvironmentSecretRecipients parses the environment's secretRecipients
+func (c *Config) EnvironmentSecretRecipients(envName string) ([]crypto.Recipient, error) {
+	if c == nil {
+		return nil, nil
+	}
+	env, ok := c.Environments[envName]
+	if !ok {
+		return nil, nil
+	}
+	recipients := make([]crypto.Recipient, 0, len(env.SecretRecipients))
+	for i, line := range env.SecretRecipients {
+		recipient, err := crypto.ParseRecipient(line)
+		if err != nil {
+			return nil, fmt.Errorf("environment %s secretRecipients[%d]: %w", envName, i, err)
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, nil
+}
+
+func validateSecretRecipients(envName string, recipients []string) error {
+	seen := make(map[string]int, len(recipients))
+	for i, line := range recipients {
+		recipient, err := crypto.ParseRecipient(line)
+		if err != nil {
+			return fmt.Errorf("environment %s secretRecipients[%d]: %w", envName, i, err)
+		}
+		if first, ok := seen[recipient.Fingerprint]; ok {
+			return fmt.Errorf("environment %s secretRecipients[%d] repeats secretRecipients[%d] (%s)", envName, i, first, recipient.Fingerprint)
+		}
+		seen[recipient.Fingerprint] = i
+	}
+	return nil
+}
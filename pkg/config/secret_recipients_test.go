@@ -0,0 +1,57 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func testSecretRecipient(t *testing.T, comment string) string {
+	t.Helper()
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))) + " " + comment
+}
+
+func TestValidateConfigSecretRecipients(t *testing.T) {
+	withRecipients := func(recipients ...string) *Config {
+		cfg := validValidationConfig()
+		production := cfg.Environments["production"]
+		production.SecretRecipients = recipients
+		cfg.Environments["production"] = production
+		return cfg
+	}
+
+	ana, ben := testSecretRecipient(t, "ana@laptop"), testSecretRecipient(t, "ben@ci")
+	cfg := withRecipients(ana, ben)
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig returned error: %v", err)
+	}
+	recipients, err := cfg.EnvironmentSecretRecipients("production")
+	if err != nil || len(recipients) != 2 || recipients[0].Comment != "ana@laptop" {
+		t.Fatalf("recipients = %+v, %v", recipients, err)
+	}
+
+	for _, tc := range []struct {
+		recipients []string
+		wantErr    string
+	}{
+		{[]string{"not a key"}, "secretRecipients[0]: invalid SSH public key"},
+		{[]string{"ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAAIbmlzdHAyNTYAAABBBEmKSENjQEezOmxkZMy7opKgwFB9nkt5YRrYMjNuG5N87uRgg6CLrbo5wAdT/y6v0mKV0U2w0WZ2YB/++Tpockg="}, "secretRecipients[0]"},
+		{[]string{ana, ben, ana}, "secretRecipients[2] repeats secretRecipients[0]"},
+	} {
+		err := ValidateConfig(withRecipients(tc.recipients...))
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Fatalf("ValidateConfig(%v) error = %v, want %q", tc.recipients, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	SecretsDeliveryEnv   = "env"
+	SecretsDeliveryFiles = "files"
+
+	// SecretFilesTarget is where files delivery mounts a service's secrets.
+	SecretFilesTarget = "/run/secrets"
+)
+
+// secretReloadSignals are the signals a rotation may send. Signals that
+// conventionally stop a process are excluded so a rotation cannot take a
+// replica down.
+var secretReloadSignals = map[string]bool{
+	"SIGHUP":   true,
+	"SIGUSR1":  true,
+	"SIGUSR2":  true,
+	"SIGWINCH": true,
+}
+
+// SecretsDeliveryConfig chooses how a service's secrets reach its containers.
+// The default, env, writes them into the container environment. With files,
+// takod writes one file per secret to a node tmpfs mounted read-only at
+// /run/secrets, so values stay out of docker inspect and tako secrets rotate
+// can replace them without a redeploy.
+type SecretsDeliveryConfig struct {
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"` // env (default) or files
+	// Owner is the numeric uid[:gid] owning the secret files (mode 0400).
+	// It defaults to a numeric service user; otherwise files are root-owned
+	// and world-readable inside the container (mode 0444).
+	Owner  string              `yaml:"owner,omitempty" json:"owner,omitempty"`
+	Reload *SecretReloadConfig `yaml:"reload,omitempty" json:"reload,omitempty"` // how running replicas learn about a rotation
+}
+
+// SecretReloadConfig tells takod how to notify running replicas after a
+// rotation rewrites their secret files: send a signal, or POST to an HTTP
+// path on each replica.
+type SecretReloadConfig struct {
+	Signal string `yaml:"signal,omitempty" json:"signal,omitempty"` // SIGHUP, SIGUSR1, SIGUSR2, or SIGWINCH
+	Path   string `yaml:"path,omitempty" json:"path,omitempty"`     // HTTP path POSTed on each replica, e.g. /-/reload
+	Port   int    `yaml:"port,omitempty" json:"port,omitempty"`     // port for path (default: service port)
+}
+
+// SecretFilesDelivery reports whether the service receives its secrets as
+// files under /run/secrets instead of environment variables.
+func (s *ServiceConfig) SecretFilesDelivery() bool {
+	return s != nil && s.SecretsDelivery != nil && s.SecretsDelivery.Mode == SecretsDeliveryFiles
+}
+
+// SecretFileNames maps each file name under /run/secrets to the secret key it
+// holds. Names follow the secrets list, including CONTAINER_VAR:SECRET_KEY
+// aliases.
+func (s *ServiceConfig) SecretFileNames() map[string]string {
+	names := make(map[string]string, len(s.Secrets))
+	for _, ref := range s.Secrets {
+		name, key, aliased := strings.Cut(ref, ":")
+		if !aliased {
+			key = name
+		}
+		names[name] = key
+	}
+	return names
+}
+
+func validateSecretsDelivery(serviceName string, service *ServiceConfig) error {
+	delivery := service.SecretsDelivery
+	if delivery == nil {
+		return nil
+	}
+	delivery.Mode = strings.ToLower(strings.TrimSpace(delivery.Mode))
+	delivery.Owner = strings.TrimSpace(delivery.Owner)
+	switch delivery.Mode {
+	case "", SecretsDeliveryEnv:
+		if delivery.Owner != "" || delivery.Reload != nil {
+			return fmt.Errorf("service %s: secretsDelivery.owner and secretsDelivery.reload require mode files", serviceName)
+		}
+		return nil
+	case SecretsDeliveryFiles:
+	default:
+		return fmt.Errorf("service %s: secretsDelivery.mode must be env or files", serviceName)
+	}
+
+	if service.IsJob() || service.IsRun() {
+		return fmt.Errorf("service %s: secretsDelivery.mode files is only supported for long-running services", serviceName)
+	}
+	if len(service.Secrets) == 0 {
+		return fmt.Errorf("service %s: secretsDelivery.mode files requires secrets", serviceName)
+	}
+	seen := make(map[string]bool, len(service.Secrets))
+	for _, ref := range service.Secrets {
+		name, _, _ := strings.Cut(ref, ":")
+		if !isValidSecretFileName(name) {
+			return fmt.Errorf("service %s: secret %q cannot be delivered as a file; names may contain letters, digits, '.', '_' and '-'", serviceName, name)
+		}
+		if seen[name] {
+			return fmt.Errorf("service %s: secret file %s is listed twice", serviceName, name)
+		}
+		seen[name] = true
+	}
+	for _, file := range service.Files {
+		if path.Clean(strings.TrimSpace(file.Target)) == SecretFilesTarget {
+			return fmt.Errorf("service %s: files target %s is reserved for secretsDelivery.mode files", serviceName, SecretFilesTarget)
+		}
+	}
+	for _, volume := range service.Volumes {
+		parts := strings.Split(volume, ":")
+		if len(parts) >= 2 && path.Clean(strings.TrimSpace(parts[1])) == SecretFilesTarget {
+			return fmt.Errorf("service %s: volume target %s is reserved for secretsDelivery.mode files", serviceName, SecretFilesTarget)
+		}
+	}
+	if _, _, _, err := ParseServiceFileOwner(delivery.Owner); err != nil {
+		return fmt.Errorf("service %s: secretsDelivery.owner: %w", serviceName, err)
+	}
+	return validateSecretReload(serviceName, service, delivery.Reload)
+}
+
+func validateSecretReload(serviceName string, service *ServiceConfig, reload *SecretReloadConfig) error {
+	if reload == nil {
+		return nil
+	}
+	reload.Signal = strings.ToUpper(strings.TrimSpace(reload.Signal))
+	if reload.Signal != "" && !strings.HasPrefix(reload.Signal, "SIG") {
+		reload.Signal = "SIG" + reload.Signal
+	}
+	reload.Path = strings.TrimSpace(reload.Path)
+	switch {
+	case reload.Signal == "" && reload.Path == "":
+		return fmt.Errorf("service %s: secretsDelivery.reload needs a signal or a path", serviceName)
+	case reload.Signal != "" && reload.Path != "":
+		return fmt.Errorf("service %s: secretsDelivery.reload accepts a signal or a path, not both", serviceName)
+	}
+	if reload.Signal != "" && !secretReloadSignals[reload.Signal] {
+		return fmt.Errorf("service %s: secretsDelivery.reload.signal must be SIGHUP, SIGUSR1, SIGUSR2, or SIGWINCH", serviceName)
+	}
+	if reload.Path == "" {
+		if reload.Port != 0 {
+			return fmt.Errorf("service %s: secretsDelivery.reload.port requires a path", serviceName)
+		}
+		return nil
+	}
+	if !strings.HasPrefix(reload.Path, "/") || len(reload.Path) > 2048 || strings.ContainsAny(reload.Path, " #") || hasConfigControlChars(reload.Path) {
+		return fmt.Errorf("service %s: secretsDelivery.reload.path must be an absolute HTTP path like /-/reload", serviceName)
+	}
+	if reload.Port < 0 || reload.Port > 65535 {
+		return fmt.Errorf("service %s: secretsDelivery.reload.port must be between 1 and 65535", serviceName)
+	}
+	if reload.Port == 0 && service.Port <= 0 {
+		return fmt.Errorf("service %s: secretsDelivery.reload.path needs a port (set reload.port or the service port)", serviceName)
+	}
+	return nil
+}
+
+func isValidSecretFileName(name string) bool {
+	if name == "" || len(name) > 255 || name[0] == '.' {
+		return false
+	}
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '-' || r == '.' {
+			continue
+		}
+		return false
+	}
+	return true
+}
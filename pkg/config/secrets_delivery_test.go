@@ -0,0 +1,74 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfigSecretsDeliveryFiles(t *testing.T) {
+	service := ServiceConfig{
+		Image:   "busybox",
+		Port:    8080,
+		Secrets: []string{"DATABASE_URL", "STRIPE:STRIPE_SECRET_KEY"},
+		SecretsDelivery: &SecretsDeliveryConfig{
+			Mode:   " Files ",
+			Owner:  "1000:1000",
+			Reload: &SecretReloadConfig{Signal: "hup"},
+		},
+	}
+	cfg := minimalValidConfigWithService(service)
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig: %v", err)
+	}
+	validated := cfg.Environments["production"].Services["web"]
+	if !validated.SecretFilesDelivery() || validated.SecretsDelivery.Reload.Signal != "SIGHUP" {
+		t.Fatalf("secretsDelivery = %+v reload %+v", validated.SecretsDelivery, validated.SecretsDelivery.Reload)
+	}
+	want := map[string]string{"DATABASE_URL": "DATABASE_URL", "STRIPE": "STRIPE_SECRET_KEY"}
+	if got := validated.SecretFileNames(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("SecretFileNames = %v, want %v", got, want)
+	}
+
+	service.SecretsDelivery = &SecretsDeliveryConfig{Mode: "files", Reload: &SecretReloadConfig{Path: "/-/reload"}}
+	if err := ValidateConfig(minimalValidConfigWithService(service)); err != nil {
+		t.Fatalf("ValidateConfig with reload path: %v", err)
+	}
+}
+
+func TestValidateConfigRejectsInvalidSecretsDelivery(t *testing.T) {
+	base := func(delivery *SecretsDeliveryConfig) ServiceConfig {
+		return ServiceConfig{Image: "busybox", Secrets: []string{"API_KEY"}, SecretsDelivery: delivery}
+	}
+	files := func(reload *SecretReloadConfig) *SecretsDeliveryConfig {
+		return &SecretsDeliveryConfig{Mode: SecretsDeliveryFiles, Reload: reload}
+	}
+	tests := []struct {
+		name    string
+		service ServiceConfig
+		want    string
+	}{
+		{name: "unknown mode", service: base(&SecretsDeliveryConfig{Mode: "tmpfs"}), want: "must be env or files"},
+		{name: "owner without files", service: base(&SecretsDeliveryConfig{Owner: "1000"}), want: "require mode files"},
+		{name: "no secrets", service: ServiceConfig{Image: "busybox", SecretsDelivery: files(nil)}, want: "requires secrets"},
+		{name: "job", service: ServiceConfig{Image: "busybox", Kind: ServiceKindJob, Schedule: "@daily", Command: StringValue("true"), Secrets: []string{"API_KEY"}, SecretsDelivery: files(nil)}, want: "long-running services"},
+		{name: "hidden file name", service: ServiceConfig{Image: "busybox", Secrets: []string{".env:API_KEY"}, SecretsDelivery: files(nil)}, want: "cannot be delivered as a file"},
+		{name: "duplicate file", service: ServiceConfig{Image: "busybox", Secrets: []string{"API_KEY", "API_KEY:OTHER"}, SecretsDelivery: files(nil)}, want: "listed twice"},
+		{name: "reserved volume", service: ServiceConfig{Image: "busybox", Secrets: []string{"API_KEY"}, Volumes: []string{"data:/run/secrets"}, SecretsDelivery: files(nil)}, want: "reserved"},
+		{name: "named owner", service: base(&SecretsDeliveryConfig{Mode: SecretsDeliveryFiles, Owner: "app"}), want: "secretsDelivery.owner"},
+		{name: "empty reload", service: base(files(&SecretReloadConfig{})), want: "needs a signal or a path"},
+		{name: "signal and path", service: base(files(&SecretReloadConfig{Signal: "SIGHUP", Path: "/reload", Port: 80})), want: "not both"},
+		{name: "terminating signal", service: base(files(&SecretReloadConfig{Signal: "SIGTERM"})), want: "reload.signal must be"},
+		{name: "port without path", service: base(files(&SecretReloadConfig{Signal: "SIGHUP", Port: 80})), want: "requires a path"},
+		{name: "relative path", service: base(files(&SecretReloadConfig{Path: "reload", Port: 80})), want: "absolute HTTP path"},
+		{name: "path without port", service: base(files(&SecretReloadConfig{Path: "/reload"})), want: "needs a port"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConfig(minimalValidConfigWithService(tt.service))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
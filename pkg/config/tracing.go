@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/redentordev/tako-cli/pkg/telemetry"
+)
+
+// TracingConfig exports deploy traces to an OTLP/gRPC collector. tako
+// deploy sends its own spans there, and takod on every node the deploy
+// touches continues the trace with spans for image builds and pulls,
+// container starts, health waits, and proxy reloads. The collector must be
+// reachable from the nodes as well as from where tako runs.
+type TracingConfig struct {
+	// Endpoint is the collector's host:port, e.g. otel.example.com:4317.
+	Endpoint string `yaml:"endpoint" json:"endpoint"`
+	// Insecure sends spans without TLS, for collectors on the mesh.
+	Insecure bool `yaml:"insecure,omitempty" json:"insecure,omitempty"`
+	// Headers ride every export and may carry credentials; reference them
+	// as ${ENV_VAR}.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+}
+
+// validateTracing validates the tracing block.
+func validateTracing(tracing *TracingConfig) error {
+	if tracing == nil {
+		return nil
+	}
+	if strings.TrimSpace(tracing.Endpoint) == "" {
+		return fmt.Errorf("tracing: endpoint is required")
+	}
+	if err := telemetry.ValidateOTLPExporter(tracing.Endpoint, tracing.Headers); err != nil {
+		return fmt.Errorf("tracing: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const tracingTestConfigTemplate = `project:
+  name: demo
+  version: 1.0.0
+tracing:
+%s
+servers:
+  node-a:
+    host: 10.0.0.1
+    user: deploy
+    password: sshpass
+environments:
+  production:
+    servers: [node-a]
+    services:
+      web:
+        image: ghcr.io/acme/web:v1
+        port: 3000
+`
+
+func loadTracingTestConfig(t *testing.T, block string) (*Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tako.yaml")
+	content := strings.Replace(tracingTestConfigTemplate, "%s", block, 1)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return LoadConfig(path)
+}
+
+func TestLoadConfigParsesTracing(t *testing.T) {
+	t.Setenv("TAKO_TEST_OTLP_KEY", "collector-key")
+	cfg, err := loadTracingTestConfig(t, `  endpoint: otel.example.com:4317
+  headers:
+    x-api-key: ${TAKO_TEST_OTLP_KEY}`)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Tracing == nil || cfg.Tracing.Endpoint != "otel.example.com:4317" || cfg.Tracing.Insecure || cfg.Tracing.Headers["x-api-key"] != "collector-key" {
+		t.Fatalf("tracing = %+v", cfg.Tracing)
+	}
+}
+
+func TestLoadConfigRejectsInvalidTracing(t *testing.T) {
+	for name, tc := range map[string]struct {
+		block string
+		want  string
+	}{
+		"no endpoint": {block: "  insecure: true", want: "endpoint is required"},
+		"url":         {block: "  endpoint: https://otel.example.com:4317", want: "must be host:port"},
+		"bad port":    {block: "  endpoint: otel.example.com:0", want: "invalid port"},
+		"bad header":  {block: "  endpoint: otel.example.com:4317\n  headers:\n    \"x api key\": secret", want: "invalid header name"},
+	} {
+		if _, err := loadTracingTestConfig(t, tc.block); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: expected %q error, got %v", name, tc.want, err)
+		}
+	}
+}
@@ -28,6 +28,10 @@ type Config struct {
 	State         *StateConfig                 `yaml:"state,omitempty" json:"state,omitempty"`
 	Deployment    *DeploymentConfig            `yaml:"deployment,omitempty" json:"deployment,omitempty"`
 	Notifications *NotificationsConfig         `yaml:"notifications,omitempty" json:"notifications,omitempty"`
+	Logging       *LoggingConfig               `yaml:"logging,omitempty" json:"logging,omitempty"`
+	Metrics       *MetricsConfig               `yaml:"metrics,omitempty" json:"metrics,omitempty"`
+	Alerts        map[string]AlertRuleConfig   `yaml:"alerts,omitempty" json:"alerts,omitempty"`
+	Tracing       *TracingConfig               `yaml:"tracing,omitempty" json:"tracing,omitempty"`
 	Volumes       map[string]VolumeConfig      `yaml:"volumes,omitempty" json:"volumes,omitempty"` // Top-level volume definitions
 	Builds        map[string]SharedBuildConfig `yaml:"builds,omitempty" json:"builds,omitempty"`
 	// Registries holds private image registry credentials keyed by host
@@ -81,6 +85,7 @@ const (
 	DeployStrategyRecreate  = "recreate"
 	DeployStrategyRolling   = "rolling"
 	DeployStrategyBlueGreen = "blue_green"
+	DeployStrategyCanary    = "canary"
 
 	DeployPromotionAutomatic = "automatic"
 	DeployPromotionManual    = "manual"
@@ -92,6 +97,19 @@ const (
 	BackupStorageProviderS3           = "s3"
 	BackupStorageProviderR2           = "r2"
 	BackupStorageProviderS3Compatible = "s3-compatible"
+	BackupStorageProviderSFTP         = "sftp"
+	BackupStorageProviderFilesystem   = "filesystem"
+	BackupStorageProviderPeer         = "peer"
+
+	BackupStorageFormatArchive = "archive"
+	BackupStorageFormatChunked = "chunked"
+
+	BackupModeVolume       = "volume"
+	BackupModePgDump       = "pg_dump"
+	BackupModeMySQLDump    = "mysqldump"
+	BackupModeRedisBGSave  = "redis-bgsave"
+	BackupModeHook         = "hook"
+	BackupModePgBaseBackup = "pg_basebackup"
 )
 
 // RuntimeConfig selects the orchestration runtime. Tako has one public runtime:
@@ -265,6 +283,29 @@ const (
 	ServiceKindRun     = "run"
 )
 
+// Job concurrency policies (kind: job concurrencyPolicy).
+const (
+	JobConcurrencyForbid  = "forbid"
+	JobConcurrencyReplace = "replace"
+	JobConcurrencyAllow   = "allow"
+)
+
+// MaxJobRetries bounds kind: job retries.
+const MaxJobRetries = 10
+
+// Job log archive bounds (kind: job logArchive).
+const (
+	MaxJobLogArchiveSizeMB = 1024
+	MaxJobLogArchiveRetain = 365
+)
+
+// JobLogArchiveConfig bounds the full per-run output takod keeps for a job.
+// Zero values use the node defaults: 64 MB per attempt, kept 14 days.
+type JobLogArchiveConfig struct {
+	MaxSizeMB int `yaml:"maxSizeMB,omitempty" json:"maxSizeMB,omitempty"` // cap per attempt; later output is dropped
+	Retain    int `yaml:"retain,omitempty" json:"retain,omitempty"`       // days to keep archived runs
+}
+
 // ServiceConfig defines service deployment settings
 type ServiceConfig struct {
 	buildStructured bool
@@ -279,6 +320,24 @@ type ServiceConfig struct {
 	Timezone string `yaml:"timezone,omitempty" json:"timezone,omitempty"`
 	// Timeout kills a job run after this duration (kind: job, default 1h).
 	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	// After, OnSuccess, and OnFailure name upstream jobs (kind: job). A job
+	// with upstreams has no schedule: it runs in its upstream's workflow run
+	// once every after job finished, every onSuccess job succeeded, and
+	// every onFailure job failed.
+	After     []string `yaml:"after,omitempty" json:"after,omitempty"`
+	OnSuccess []string `yaml:"onSuccess,omitempty" json:"onSuccess,omitempty"`
+	OnFailure []string `yaml:"onFailure,omitempty" json:"onFailure,omitempty"`
+	// Retries re-runs a failed or timed-out job run up to this many times
+	// (kind: job), waiting retryBackoff (default 10s) doubled per retry.
+	Retries      int    `yaml:"retries,omitempty" json:"retries,omitempty"`
+	RetryBackoff string `yaml:"retryBackoff,omitempty" json:"retryBackoff,omitempty"`
+	// ConcurrencyPolicy decides what happens when a job fires while a run
+	// is in progress: forbid (default) skips, replace stops the running run,
+	// allow runs both (kind: job).
+	ConcurrencyPolicy string `yaml:"concurrencyPolicy,omitempty" json:"concurrencyPolicy,omitempty"`
+	// LogArchive bounds the full run output archived on the owning node and
+	// served by tako jobs logs (kind: job).
+	LogArchive *JobLogArchiveConfig `yaml:"logArchive,omitempty" json:"logArchive,omitempty"`
 
 	// Build or Image (mutually exclusive)
 	Build       string            `yaml:"build,omitempty" json:"build,omitempty"` // Path to build context (auto-detects Dockerfile)
@@ -318,9 +377,10 @@ type ServiceConfig struct {
 	ShmSize         string                  `yaml:"shmSize,omitempty" json:"shmSize,omitempty"`
 
 	// Secrets: ["DATABASE_URL", "JWT_SECRET"] or ["VAR_NAME:SECRET_KEY"].
-	Secrets []string            `yaml:"secrets,omitempty" json:"secrets,omitempty"` // Tako secrets from .tako/secrets files
-	Volumes []string            `yaml:"volumes,omitempty" json:"volumes,omitempty"`
-	Files   []ServiceFileConfig `yaml:"files,omitempty" json:"files,omitempty"`
+	Secrets         []string               `yaml:"secrets,omitempty" json:"secrets,omitempty"`                 // Tako secrets from .tako/secrets files
+	SecretsDelivery *SecretsDeliveryConfig `yaml:"secretsDelivery,omitempty" json:"secretsDelivery,omitempty"` // env (default) or tmpfs files under /run/secrets
+	Volumes         []string               `yaml:"volumes,omitempty" json:"volumes,omitempty"`
+	Files           []ServiceFileConfig    `yaml:"files,omitempty" json:"files,omitempty"`
 	// FilesContentHash is an internal digest of fully resolved operator file
 	// metadata and bytes; file contents never enter desired state or labels.
 	FilesContentHash string `yaml:"-" json:"-"`
@@ -378,6 +438,15 @@ func (s *ServiceConfig) IsJob() bool {
 	return s.Kind == ServiceKindJob
 }
 
+// JobUpstreams returns every upstream job across after, onSuccess, and
+// onFailure.
+func (s ServiceConfig) JobUpstreams() []string {
+	upstreams := make([]string, 0, len(s.After)+len(s.OnSuccess)+len(s.OnFailure))
+	upstreams = append(upstreams, s.After...)
+	upstreams = append(upstreams, s.OnSuccess...)
+	return append(upstreams, s.OnFailure...)
+}
+
 // IsRun reports whether the service is a deploy-time run-to-completion step.
 func (s *ServiceConfig) IsRun() bool {
 	return s.Kind == ServiceKindRun
@@ -406,7 +475,7 @@ type HealthCheckConfig struct {
 
 // DeployConfig defines deployment strategy
 type DeployConfig struct {
-	Strategy          string                `yaml:"strategy,omitempty" json:"strategy,omitempty"` // recreate, rolling, blue_green
+	Strategy          string                `yaml:"strategy,omitempty" json:"strategy,omitempty"` // recreate, rolling, blue_green, canary
 	MaxUnavailable    int                   `yaml:"maxUnavailable,omitempty" json:"maxUnavailable,omitempty"`
 	MaxSurge          int                   `yaml:"maxSurge,omitempty" json:"maxSurge,omitempty"`
 	RollbackOnFailure bool                  `yaml:"rollbackOnFailure,omitempty" json:"rollbackOnFailure,omitempty"`
@@ -415,6 +484,60 @@ type DeployConfig struct {
 	Promotion         string                `yaml:"promotion,omitempty" json:"promotion,omitempty"` // automatic, manual
 	GracePeriod       string                `yaml:"gracePeriod,omitempty" json:"gracePeriod,omitempty"`
 	Release           *ReleaseConfig        `yaml:"release,omitempty" json:"release,omitempty"`
+	Canary            *DeployCanaryConfig   `yaml:"canary,omitempty" json:"canary,omitempty"`
+	Analysis          *DeployAnalysisConfig `yaml:"analysis,omitempty" json:"analysis,omitempty"`
+}
+
+// DefaultAnalysisMinRequests is the traffic floor below which analysis
+// cannot judge a revision and lets it proceed.
+const DefaultAnalysisMinRequests = 20
+
+// DeployAnalysisConfig declares regression thresholds checked against live
+// proxy traffic. Canary revisions are analyzed during and at the end of every
+// step hold; blue_green revisions are analyzed during and at the end of
+// deploy.gracePeriod while the previous revision is still running. Breaching
+// any threshold rolls back.
+type DeployAnalysisConfig struct {
+	// MaxErrorRate is the highest tolerated share of 5xx responses, in
+	// percent (0-100).
+	MaxErrorRate *float64 `yaml:"maxErrorRate,omitempty" json:"maxErrorRate,omitempty"`
+	// MaxP95Latency and MaxP99Latency bound response latency percentiles,
+	// as durations such as 300ms.
+	MaxP95Latency string `yaml:"maxP95Latency,omitempty" json:"maxP95Latency,omitempty"`
+	MaxP99Latency string `yaml:"maxP99Latency,omitempty" json:"maxP99Latency,omitempty"`
+	// MinRequests is the number of requests a revision must serve before
+	// thresholds apply. Defaults to DefaultAnalysisMinRequests.
+	MinRequests int `yaml:"minRequests,omitempty" json:"minRequests,omitempty"`
+}
+
+// EffectiveMinRequests returns MinRequests or its default.
+func (a *DeployAnalysisConfig) EffectiveMinRequests() int {
+	if a == nil || a.MinRequests <= 0 {
+		return DefaultAnalysisMinRequests
+	}
+	return a.MinRequests
+}
+
+// DefaultCanarySteps is the traffic ramp used when deploy.canary.steps is
+// omitted.
+var DefaultCanarySteps = []int{5, 25, 100}
+
+// DeployCanaryConfig describes the weighted traffic ramp of a canary deploy.
+// Steps are ascending percentages of proxy traffic sent to the new revision;
+// the final step must be 100, which promotes the canary like blue_green.
+// With automatic promotion each step is held for Hold while the canary stays
+// healthy; with manual promotion `tako promote` advances one step at a time.
+type DeployCanaryConfig struct {
+	Steps []int  `yaml:"steps,omitempty" json:"steps,omitempty"`
+	Hold  string `yaml:"hold,omitempty" json:"hold,omitempty"`
+}
+
+// EffectiveSteps returns the configured canary ramp or DefaultCanarySteps.
+func (c *DeployCanaryConfig) EffectiveSteps() []int {
+	if c == nil || len(c.Steps) == 0 {
+		return append([]int(nil), DefaultCanarySteps...)
+	}
+	return append([]int(nil), c.Steps...)
 }
 
 // ReleaseConfig runs a command from the new revision's image exactly once
@@ -500,6 +623,24 @@ type ProxyConfig struct {
 	// TrustedProxies declares the explicit proxy/CDN CIDRs whose forwarded
 	// client IP headers Caddy may trust for this route. CIDRs only.
 	TrustedProxies []string `yaml:"trustedProxies,omitempty" json:"trustedProxies,omitempty"`
+
+	// Paths limits the route to these request paths on its domains so other
+	// services can serve the rest, e.g. ["/api/*"]. A trailing * matches a
+	// prefix. Omit paths (or use "/") to serve every path not claimed by
+	// another service on the same domain.
+	Paths []string `yaml:"paths,omitempty" json:"paths,omitempty"`
+
+	// StripPrefix removes the matched path prefix before proxying, so
+	// /api/users reaches the service as /users.
+	StripPrefix bool `yaml:"stripPrefix,omitempty" json:"stripPrefix,omitempty"`
+
+	// RateLimit throttles each client of the route; requests over the
+	// limit receive 429 before reaching the service.
+	RateLimit *ProxyRateLimitConfig `yaml:"rateLimit,omitempty" json:"rateLimit,omitempty"`
+
+	// MaxBodySize rejects request bodies larger than this with 413,
+	// e.g. "10mb".
+	MaxBodySize string `yaml:"maxBodySize,omitempty" json:"maxBodySize,omitempty"`
 }
 
 // ProxyBasicAuthConfig protects a proxy route with HTTP basic auth.
@@ -629,16 +770,45 @@ type TLSConfig struct {
 
 // BackupConfig defines per-service backup settings.
 type BackupConfig struct {
-	Schedule string               `yaml:"schedule" json:"schedule"`                   // cron format (e.g., "0 2 * * *")
-	Retain   int                  `yaml:"retain" json:"retain"`                       // days to retain backups
-	Volumes  []string             `yaml:"volumes,omitempty" json:"volumes,omitempty"` // optional logical service volumes to back up
-	Storage  *BackupStorageConfig `yaml:"storage,omitempty" json:"storage,omitempty"` // optional object storage target
+	Schedule   string               `yaml:"schedule" json:"schedule"`                         // cron format (e.g., "0 2 * * *")
+	Retain     BackupRetention      `yaml:"retain" json:"retain"`                             // days to retain backups, or hourly/daily/weekly/monthly/yearly counts
+	Volumes    []string             `yaml:"volumes,omitempty" json:"volumes,omitempty"`       // optional logical service volumes to back up
+	Storage    *BackupStorageConfig `yaml:"storage,omitempty" json:"storage,omitempty"`       // optional object storage target
+	Mode       string               `yaml:"mode,omitempty" json:"mode,omitempty"`             // volume (default), pg_dump, mysqldump, redis-bgsave, pg_basebackup, hook
+	Database   string               `yaml:"database,omitempty" json:"database,omitempty"`     // pg_dump/mysqldump database (default: the image's POSTGRES_DB or all MySQL databases)
+	PreBackup  string               `yaml:"preBackup,omitempty" json:"preBackup,omitempty"`   // shell command run in the service container before the backup
+	PostBackup string               `yaml:"postBackup,omitempty" json:"postBackup,omitempty"` // shell command run after the backup, even when it failed
+	Verify     *BackupVerifyConfig  `yaml:"verify,omitempty" json:"verify,omitempty"`         // optional scheduled restore drill
+	PITR       bool                 `yaml:"pitr,omitempty" json:"pitr,omitempty"`             // archive Postgres WAL continuously for point-in-time recovery
+}
+
+// BackupVerifyConfig schedules restore drills: takod restores the newest
+// backup into a throwaway volume and, when Boot is set, starts the service
+// image against it without network access and runs Check.
+type BackupVerifyConfig struct {
+	Schedule string `yaml:"schedule" json:"schedule"`                   // cron format (e.g., "0 5 * * 0")
+	Boot     bool   `yaml:"boot,omitempty" json:"boot,omitempty"`       // start the service image against the restored volume
+	Check    string `yaml:"check,omitempty" json:"check,omitempty"`     // shell command that must succeed in the booted container; implies boot
+	Timeout  string `yaml:"timeout,omitempty" json:"timeout,omitempty"` // how long the drill may take (default: 10m)
+}
+
+// IsDatabaseDump reports whether the backup runs a database's own dump tool
+// instead of archiving the volume.
+func (b *BackupConfig) IsDatabaseDump() bool {
+	if b == nil {
+		return false
+	}
+	switch b.Mode {
+	case BackupModePgDump, BackupModeMySQLDump, BackupModeRedisBGSave, BackupModePgBaseBackup:
+		return true
+	}
+	return false
 }
 
 // BackupStorageConfig defines an S3-compatible object storage target for
 // off-node backup copies. R2, MinIO, B2, and Spaces use the s3-compatible API.
 type BackupStorageConfig struct {
-	Provider        string `yaml:"provider,omitempty" json:"provider,omitempty"`               // s3, r2, s3-compatible
+	Provider        string `yaml:"provider,omitempty" json:"provider,omitempty"`               // s3, r2, s3-compatible, sftp, filesystem, peer
 	Bucket          string `yaml:"bucket,omitempty" json:"bucket,omitempty"`                   // Object storage bucket
 	Region          string `yaml:"region,omitempty" json:"region,omitempty"`                   // AWS region or "auto" for R2
 	Endpoint        string `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`               // Required for r2/s3-compatible
@@ -647,6 +817,16 @@ type BackupStorageConfig struct {
 	SecretAccessKey string `yaml:"secretAccessKey,omitempty" json:"secretAccessKey,omitempty"` // Use ${ENV_VAR}
 	SessionToken    string `yaml:"sessionToken,omitempty" json:"sessionToken,omitempty"`       // Optional temporary credential token
 	ForcePathStyle  bool   `yaml:"forcePathStyle,omitempty" json:"forcePathStyle,omitempty"`   // Needed by some S3-compatible stores
+	Format          string `yaml:"format,omitempty" json:"format,omitempty"`                   // archive (default) or chunked
+	EncryptionKey   string `yaml:"encryptionKey,omitempty" json:"encryptionKey,omitempty"`     // Chunk passphrase; use ${ENV_VAR}
+	Path            string `yaml:"path,omitempty" json:"path,omitempty"`                       // Mounted directory (filesystem) or remote directory (sftp)
+	Host            string `yaml:"host,omitempty" json:"host,omitempty"`                       // SFTP server host
+	Port            int    `yaml:"port,omitempty" json:"port,omitempty"`                       // SFTP port (default: 22)
+	User            string `yaml:"user,omitempty" json:"user,omitempty"`                       // SFTP login user
+	Password        string `yaml:"password,omitempty" json:"password,omitempty"`               // SFTP password; use ${ENV_VAR}
+	PrivateKey      string `yaml:"privateKey,omitempty" json:"privateKey,omitempty"`           // SFTP private key PEM; use ${ENV_VAR}
+	HostKey         string `yaml:"hostKey,omitempty" json:"hostKey,omitempty"`                 // Pinned SFTP host key in authorized_keys form
+	Node            string `yaml:"node,omitempty" json:"node,omitempty"`                       // Peer node that receives replicas over the mesh
 }
 
 // ResourceLimitsConfig defines container runtime resource limits.
@@ -670,6 +850,10 @@ type EnvironmentConfig struct {
 	Proxy          *EnvironmentProxyConfig  `yaml:"proxy,omitempty" json:"proxy,omitempty"`                   // Environment-level proxy placement
 	Labels         map[string]string        `yaml:"labels,omitempty" json:"labels,omitempty"`                 // Environment labels for nodes
 	Services       map[string]ServiceConfig `yaml:"services" json:"services"`                                 // Services to deploy in this environment
+	SecretProvider *SecretProviderConfig    `yaml:"secretProvider,omitempty" json:"secretProvider,omitempty"` // External store the environment's secrets come from
+	// SecretRecipients are the SSH public keys (authorized_keys lines) of the
+	// team members `tako secrets push` seals the environment's secrets to.
+	SecretRecipients []string `yaml:"secretRecipients,omitempty" json:"secretRecipients,omitempty"`
 }
 
 // EnvironmentProxyConfig controls where environment-level proxy routes are
@@ -1165,6 +1349,9 @@ func LoadConfig(configPath string) (*Config, error) {
 	if err := validateRawACMEDNSCredentials(data, isJSON); err != nil {
 		return nil, err
 	}
+	if err := validateRawMetricsToken(data, isJSON); err != nil {
+		return nil, err
+	}
 
 	// Expand environment variables in the content with trimming
 	// This handles cases where environment variables have trailing spaces
@@ -279,7 +279,7 @@ func TestValidateConfigAcceptsBackupStorageConfig(t *testing.T) {
 	web.Volumes = []string{"data:/data"}
 	web.Backup = &BackupConfig{
 		Schedule: "@daily",
-		Retain:   14,
+		Retain:   BackupRetention{Days: 14},
 		Volumes:  []string{"data"},
 		Storage: &BackupStorageConfig{
 			Provider:        BackupStorageProviderR2,
@@ -856,7 +856,7 @@ func TestValidateConfigRejectsUnknownDeploymentStrategy(t *testing.T) {
 	cfg := validValidationConfig()
 	production := cfg.Environments["production"]
 	web := production.Services["web"]
-	web.Deploy.Strategy = "canary"
+	web.Deploy.Strategy = "linear"
 	production.Services["web"] = web
 	cfg.Environments["production"] = production
 
@@ -864,7 +864,7 @@ func TestValidateConfigRejectsUnknownDeploymentStrategy(t *testing.T) {
 	if err == nil {
 		t.Fatal("ValidateConfig should reject unsupported deployment strategies")
 	}
-	for _, want := range []string{"invalid deployment strategy", "recreate, rolling, and blue_green"} {
+	for _, want := range []string{"invalid deployment strategy", "recreate, rolling, blue_green, and canary"} {
 		if !strings.Contains(err.Error(), want) {
 			t.Fatalf("error = %q, want %q", err, want)
 		}
@@ -953,6 +953,207 @@ func TestValidateConfigAllowsBlueGreenGracePeriod(t *testing.T) {
 	}
 }
 
+func TestValidateConfigAllowsCanaryStrategy(t *testing.T) {
+	cfg := validValidationConfig()
+	production := cfg.Environments["production"]
+	web := production.Services["web"]
+	web.Replicas = 2
+	web.Deploy.Strategy = DeployStrategyCanary
+	web.Deploy.Canary = &DeployCanaryConfig{Steps: []int{10, 50, 100}, Hold: "2m"}
+	web.Deploy.GracePeriod = "30s"
+	configureValidationWebProxy(&production, &web)
+	production.Services["web"] = web
+	cfg.Environments["production"] = production
+
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig should allow canary strategy: %v", err)
+	}
+}
+
+func TestValidateConfigAllowsManualCanaryWithoutHold(t *testing.T) {
+	cfg := validValidationConfig()
+	production := cfg.Environments["production"]
+	web := production.Services["web"]
+	web.Replicas = 1
+	web.Deploy.Strategy = DeployStrategyCanary
+	web.Deploy.Promotion = DeployPromotionManual
+	configureValidationWebProxy(&production, &web)
+	production.Services["web"] = web
+	cfg.Environments["production"] = production
+
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig should allow manual canary without hold: %v", err)
+	}
+}
+
+func TestValidateConfigRejectsInvalidCanaryOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*ServiceConfig)
+		want   string
+	}{
+		{
+			name: "canary block on other strategy",
+			mutate: func(web *ServiceConfig) {
+				web.Deploy.Strategy = DeployStrategyBlueGreen
+			},
+			want: "deploy.canary is only supported by canary",
+		},
+		{
+			name: "automatic without hold",
+			mutate: func(web *ServiceConfig) {
+				web.Deploy.Canary.Hold = ""
+			},
+			want: "deploy.canary.hold is required",
+		},
+		{
+			name: "steps not ascending",
+			mutate: func(web *ServiceConfig) {
+				web.Deploy.Canary.Steps = []int{25, 10, 100}
+			},
+			want: "strictly ascending",
+		},
+		{
+			name: "steps do not finish",
+			mutate: func(web *ServiceConfig) {
+				web.Deploy.Canary.Steps = []int{5, 50}
+			},
+			want: "must end at 100",
+		},
+		{
+			name: "step out of range",
+			mutate: func(web *ServiceConfig) {
+				web.Deploy.Canary.Steps = []int{0, 100}
+			},
+			want: "between 1 and 100",
+		},
+		{
+			name: "sticky load balancing",
+			mutate: func(web *ServiceConfig) {
+				web.LoadBalancer.Strategy = "sticky"
+			},
+			want: "cannot use loadBalancer.strategy sticky",
+		},
+		{
+			name: "requires proxy",
+			mutate: func(web *ServiceConfig) {
+				web.Proxy = nil
+			},
+			want: "requires a public proxy route",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validValidationConfig()
+			production := cfg.Environments["production"]
+			web := production.Services["web"]
+			web.Replicas = 2
+			web.Deploy.Strategy = DeployStrategyCanary
+			web.Deploy.Canary = &DeployCanaryConfig{Steps: []int{5, 25, 100}, Hold: "1m"}
+			configureValidationWebProxy(&production, &web)
+			tt.mutate(&web)
+			production.Services["web"] = web
+			cfg.Environments["production"] = production
+
+			err := ValidateConfig(cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateConfigRejectsInvalidDeployAnalysis(t *testing.T) {
+	rate := func(value float64) *float64 { return &value }
+	tests := []struct {
+		name   string
+		mutate func(*ServiceConfig)
+		want   string
+	}{
+		{
+			name: "rolling strategy",
+			mutate: func(web *ServiceConfig) {
+				web.Deploy.Strategy = DeployStrategyRolling
+				web.Deploy.Canary = nil
+			},
+			want: "deploy.analysis is only supported by canary and blue_green",
+		},
+		{
+			name: "blue_green without grace period",
+			mutate: func(web *ServiceConfig) {
+				web.Deploy.Strategy = DeployStrategyBlueGreen
+				web.Deploy.Canary = nil
+			},
+			want: "requires deploy.gracePeriod",
+		},
+		{
+			name: "no thresholds",
+			mutate: func(web *ServiceConfig) {
+				web.Deploy.Analysis = &DeployAnalysisConfig{MinRequests: 10}
+			},
+			want: "requires at least one of",
+		},
+		{
+			name: "error rate out of range",
+			mutate: func(web *ServiceConfig) {
+				web.Deploy.Analysis.MaxErrorRate = rate(101)
+			},
+			want: "between 0 and 100",
+		},
+		{
+			name: "invalid latency",
+			mutate: func(web *ServiceConfig) {
+				web.Deploy.Analysis.MaxP99Latency = "fast"
+			},
+			want: "deploy.analysis.maxP99Latency must be a positive duration",
+		},
+		{
+			name: "negative min requests",
+			mutate: func(web *ServiceConfig) {
+				web.Deploy.Analysis.MinRequests = -1
+			},
+			want: "minRequests cannot be negative",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validValidationConfig()
+			production := cfg.Environments["production"]
+			web := production.Services["web"]
+			web.Replicas = 2
+			web.Deploy.Strategy = DeployStrategyCanary
+			web.Deploy.Canary = &DeployCanaryConfig{Steps: []int{5, 25, 100}, Hold: "1m"}
+			web.Deploy.Analysis = &DeployAnalysisConfig{MaxErrorRate: rate(1), MaxP95Latency: "300ms"}
+			configureValidationWebProxy(&production, &web)
+			tt.mutate(&web)
+			production.Services["web"] = web
+			cfg.Environments["production"] = production
+
+			err := ValidateConfig(cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateConfigAllowsBlueGreenAnalysisWithGracePeriod(t *testing.T) {
+	cfg := validValidationConfig()
+	production := cfg.Environments["production"]
+	web := production.Services["web"]
+	web.Replicas = 2
+	web.Deploy.Strategy = DeployStrategyBlueGreen
+	web.Deploy.GracePeriod = "2m"
+	web.Deploy.Analysis = &DeployAnalysisConfig{MaxP99Latency: "1s", MinRequests: 50}
+	configureValidationWebProxy(&production, &web)
+	production.Services["web"] = web
+	cfg.Environments["production"] = production
+
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig should allow blue_green analysis: %v", err)
+	}
+}
+
 func TestValidateConfigRejectsUnsupportedNoDowntimeStrategyOptions(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -1559,6 +1760,121 @@ func TestValidateConfigAcceptsJobService(t *testing.T) {
 	}
 }
 
+func TestValidateConfigJobRetryPolicy(t *testing.T) {
+	job := func(mutate func(*ServiceConfig)) ServiceConfig {
+		service := ServiceConfig{Kind: ServiceKindJob, Image: "busybox", Schedule: "@hourly", Command: StringValue("sync-billing")}
+		mutate(&service)
+		return service
+	}
+	valid := job(func(s *ServiceConfig) {
+		s.Retries = 3
+		s.RetryBackoff = "30s"
+		s.ConcurrencyPolicy = JobConcurrencyReplace
+		s.LogArchive = &JobLogArchiveConfig{MaxSizeMB: 256, Retain: 30}
+	})
+	cfg := validValidationConfig()
+	production := cfg.Environments["production"]
+	production.Services["billing-sync"] = valid
+	cfg.Environments["production"] = production
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig rejected a valid retry policy: %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		service ServiceConfig
+		want    string
+	}{
+		{"negative retries", job(func(s *ServiceConfig) { s.Retries = -1 }), "retries must be between 0 and 10"},
+		{"excessive retries", job(func(s *ServiceConfig) { s.Retries = MaxJobRetries + 1 }), "retries must be between 0 and 10"},
+		{"backoff without retries", job(func(s *ServiceConfig) { s.RetryBackoff = "30s" }), "retryBackoff requires retries"},
+		{"bad backoff", job(func(s *ServiceConfig) { s.Retries = 1; s.RetryBackoff = "soon" }), "retryBackoff must be a duration"},
+		{"excessive backoff", job(func(s *ServiceConfig) { s.Retries = 1; s.RetryBackoff = "1h" }), "retryBackoff must be a duration"},
+		{"sub-second backoff", job(func(s *ServiceConfig) { s.Retries = 1; s.RetryBackoff = "500ms" }), "retryBackoff must be a duration"},
+		{"fractional backoff", job(func(s *ServiceConfig) { s.Retries = 1; s.RetryBackoff = "1.5s" }), "retryBackoff must be a duration of whole seconds"},
+		{"bad policy", job(func(s *ServiceConfig) { s.ConcurrencyPolicy = "queue" }), "concurrencyPolicy must be forbid, replace, or allow"},
+		{"retries on plain service", ServiceConfig{Image: "busybox", Retries: 2}, "require kind: job"},
+		{"excessive log archive size", job(func(s *ServiceConfig) { s.LogArchive = &JobLogArchiveConfig{MaxSizeMB: MaxJobLogArchiveSizeMB + 1} }), "logArchive.maxSizeMB must be between 0 and 1024"},
+		{"negative log archive retention", job(func(s *ServiceConfig) { s.LogArchive = &JobLogArchiveConfig{Retain: -1} }), "logArchive.retain must be between 0 and 365"},
+		{"log archive on plain service", ServiceConfig{Image: "busybox", LogArchive: &JobLogArchiveConfig{Retain: 7}}, "logArchive requires kind: job"},
+	}
+	for _, tc := range cases {
+		cfg := validValidationConfig()
+		production := cfg.Environments["production"]
+		production.Services["candidate"] = tc.service
+		cfg.Environments["production"] = production
+
+		err := ValidateConfig(cfg)
+		if err == nil {
+			t.Fatalf("%s: config accepted", tc.name)
+		}
+		if !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: error = %q, want %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestValidateConfigAcceptsJobWorkflow(t *testing.T) {
+	cfg := validValidationConfig()
+	production := cfg.Environments["production"]
+	production.Services["export"] = ServiceConfig{Kind: ServiceKindJob, Image: "busybox", Schedule: "0 2 * * *", Command: StringValue("export")}
+	production.Services["transform"] = ServiceConfig{Kind: ServiceKindJob, Image: "busybox", OnSuccess: []string{"export"}, Command: StringValue("transform")}
+	production.Services["upload"] = ServiceConfig{Kind: ServiceKindJob, Image: "busybox", OnSuccess: []string{"transform"}, Command: StringValue("upload")}
+	production.Services["alert"] = ServiceConfig{Kind: ServiceKindJob, Image: "busybox", OnFailure: []string{"export", "transform"}, Command: StringValue("alert")}
+	production.Services["cleanup"] = ServiceConfig{Kind: ServiceKindJob, Image: "busybox", After: []string{"upload", "alert"}, Command: StringValue("cleanup")}
+	cfg.Environments["production"] = production
+
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig rejected a valid job workflow: %v", err)
+	}
+}
+
+func TestValidateConfigRejectsInvalidJobWorkflows(t *testing.T) {
+	root := ServiceConfig{Kind: ServiceKindJob, Image: "busybox", Schedule: "@hourly", Command: StringValue("true")}
+	dependent := func(mutate func(*ServiceConfig)) ServiceConfig {
+		service := ServiceConfig{Kind: ServiceKindJob, Image: "busybox", Command: StringValue("true")}
+		mutate(&service)
+		return service
+	}
+	cases := []struct {
+		name     string
+		services map[string]ServiceConfig
+		want     string
+	}{
+		{"schedule with upstream", map[string]ServiceConfig{"root": root, "candidate": dependent(func(s *ServiceConfig) { s.After = []string{"root"}; s.Schedule = "@daily" })}, "cannot set schedule or timezone"},
+		{"self edge", map[string]ServiceConfig{"candidate": dependent(func(s *ServiceConfig) { s.After = []string{"candidate"} })}, "cannot depend on itself"},
+		{"duplicate edge", map[string]ServiceConfig{"root": root, "candidate": dependent(func(s *ServiceConfig) { s.After = []string{"root"}; s.OnSuccess = []string{"root"} })}, "listed more than once"},
+		{"unknown upstream", map[string]ServiceConfig{"candidate": dependent(func(s *ServiceConfig) { s.After = []string{"missing"} })}, "unknown job"},
+		{"service upstream", map[string]ServiceConfig{"candidate": dependent(func(s *ServiceConfig) { s.After = []string{"web"} })}, "not kind: job"},
+		{"cycle", map[string]ServiceConfig{
+			"candidate": dependent(func(s *ServiceConfig) { s.After = []string{"other"} }),
+			"other":     dependent(func(s *ServiceConfig) { s.After = []string{"candidate"} }),
+		}, "job dependency cycle"},
+		{"several roots", map[string]ServiceConfig{
+			"root":      root,
+			"second":    root,
+			"candidate": dependent(func(s *ServiceConfig) { s.After = []string{"root", "second"} }),
+		}, "several scheduled jobs"},
+		{"edge on plain service", map[string]ServiceConfig{"root": root, "candidate": {Image: "busybox", After: []string{"root"}}}, "require kind: job"},
+	}
+	for _, tc := range cases {
+		cfg := validValidationConfig()
+		production := cfg.Environments["production"]
+		for name, service := range tc.services {
+			production.Services[name] = service
+		}
+		cfg.Environments["production"] = production
+
+		err := ValidateConfig(cfg)
+		if err == nil {
+			t.Fatalf("%s: config accepted", tc.name)
+		}
+		if !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: error = %q, want %q", tc.name, err, tc.want)
+		}
+	}
+}
+
 func TestValidateConfigRejectsInvalidJobServices(t *testing.T) {
 	cases := []struct {
 		name    string
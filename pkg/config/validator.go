@@ -24,6 +24,7 @@ import (
 const (
 	maxServiceHealthRetries  = 100
 	maxServiceHealthDuration = 24 * time.Hour
+	maxCanarySteps           = 10
 	maxContainerCommandArgs  = 256
 	maxContainerCommandBytes = 64 * 1024
 	maxContainerHealthBytes  = 4096
@@ -33,6 +34,8 @@ const (
 	maxServiceBuildArgs      = 128
 	maxServiceExtraHosts     = 128
 	maxServiceUlimits        = 64
+
+	minBackupEncryptionKeyLength = 16
 )
 
 var (
@@ -71,6 +74,19 @@ func ValidateConfig(cfg *Config) error {
 		return err
 	}
 
+	if err := validateLogging(cfg.Logging); err != nil {
+		return err
+	}
+	if err := validateMetrics(cfg); err != nil {
+		return err
+	}
+	if err := validateAlerts(cfg); err != nil {
+		return err
+	}
+	if err := validateTracing(cfg.Tracing); err != nil {
+		return err
+	}
+
 	// Validate servers
 	if len(cfg.Servers) == 0 {
 		return fmt.Errorf("at least one server must be configured")
@@ -318,6 +334,12 @@ func validateEnvironment(envName string, env *EnvironmentConfig, cfg *Config) er
 	if err := validateEnvironmentACME(envName, env.Proxy); err != nil {
 		return err
 	}
+	if err := validateSecretProvider(envName, env.SecretProvider); err != nil {
+		return err
+	}
+	if err := validateSecretRecipients(envName, env.SecretRecipients); err != nil {
+		return err
+	}
 
 	// Validate services
 	if len(env.Services) == 0 {
@@ -334,6 +356,9 @@ func validateEnvironment(envName string, env *EnvironmentConfig, cfg *Config) er
 	if err := validateRunImageSources(envName, env, cfg.Builds); err != nil {
 		return err
 	}
+	if err := validateJobWorkflows(envName, env); err != nil {
+		return err
+	}
 
 	if err := validateEnvironmentPersistentPlacement(envName, env, cfg); err != nil {
 		return err
@@ -813,6 +838,9 @@ func validateService(envName string, name string, service *ServiceConfig, cfg *C
 	if err := validateServiceFiles(name, service); err != nil {
 		return err
 	}
+	if err := validateSecretsDelivery(name, service); err != nil {
+		return err
+	}
 	if err := validateResourceLimits(name, service.Resources); err != nil {
 		return err
 	}
@@ -1170,11 +1198,8 @@ func validateBackupConfig(name string, service *ServiceConfig) error {
 	if err := validateBackupSchedule(service.Backup.Schedule); err != nil {
 		return fmt.Errorf("service %s: invalid backup schedule: %w", name, err)
 	}
-	if service.Backup.Retain <= 0 {
-		service.Backup.Retain = 7
-	}
-	if service.Backup.Retain > 3660 {
-		return fmt.Errorf("service %s: backup retain must be 3660 days or less", name)
+	if err := validateBackupRetention(name, &service.Backup.Retain); err != nil {
+		return err
 	}
 	if len(service.Backup.Volumes) > 0 {
 		serviceVolumes := backupableServiceVolumeNames(service.Volumes)
@@ -1194,6 +1219,139 @@ func validateBackupConfig(name string, service *ServiceConfig) error {
 			return err
 		}
 	}
+	if service.Backup.Verify != nil {
+		if err := validateBackupVerify(name, service.Backup.Verify); err != nil {
+			return err
+		}
+	}
+	return validateBackupMode(name, service)
+}
+
+// validateBackupRetention defaults a flat retention to 7 days. Bucketed
+// retention needs no default: every bucket left out keeps nothing extra.
+func validateBackupRetention(name string, retain *BackupRetention) error {
+	if !retain.IsGFS() {
+		if retain.Days <= 0 {
+			retain.Days = 7
+		}
+		if retain.Days > 3660 {
+			return fmt.Errorf("service %s: backup retain must be 3660 days or less", name)
+		}
+		return nil
+	}
+	for _, bucket := range []struct {
+		name  string
+		count int
+	}{
+		{"hourly", retain.Hourly},
+		{"daily", retain.Daily},
+		{"weekly", retain.Weekly},
+		{"monthly", retain.Monthly},
+		{"yearly", retain.Yearly},
+	} {
+		if bucket.count < 0 || bucket.count > maxBackupRetentionBucket {
+			return fmt.Errorf("service %s: backup retain.%s must be between 0 and %d", name, bucket.name, maxBackupRetentionBucket)
+		}
+	}
+	return nil
+}
+
+func validateBackupVerify(name string, verify *BackupVerifyConfig) error {
+	verify.Schedule = strings.TrimSpace(verify.Schedule)
+	if verify.Schedule == "" {
+		return fmt.Errorf("service %s: backup.verify.schedule is required", name)
+	}
+	if err := validateBackupSchedule(verify.Schedule); err != nil {
+		return fmt.Errorf("service %s: invalid backup.verify.schedule: %w", name, err)
+	}
+	verify.Check = strings.TrimSpace(verify.Check)
+	if len(verify.Check) > 4096 {
+		return fmt.Errorf("service %s: backup.verify.check must be 4096 bytes or less", name)
+	}
+	if verify.Check != "" {
+		verify.Boot = true
+	}
+	verify.Timeout = strings.TrimSpace(verify.Timeout)
+	if verify.Timeout != "" {
+		timeout, err := time.ParseDuration(verify.Timeout)
+		if err != nil || timeout < 10*time.Second || timeout > 6*time.Hour {
+			return fmt.Errorf("service %s: backup.verify.timeout must be a duration between 10s and 6h", name)
+		}
+	}
+	return nil
+}
+
+// validateBackupMode checks the application-consistent backup settings. A
+// database dump is one artifact per service, so it is filed under the single
+// volume that holds the database.
+func validateBackupMode(name string, service *ServiceConfig) error {
+	backup := service.Backup
+	backup.Mode = strings.TrimSpace(backup.Mode)
+	backup.Database = strings.TrimSpace(backup.Database)
+	backup.PreBackup = strings.TrimSpace(backup.PreBackup)
+	backup.PostBackup = strings.TrimSpace(backup.PostBackup)
+	if backup.Mode == BackupModeVolume {
+		backup.Mode = ""
+	}
+	if backup.PITR {
+		if err := validateBackupPITR(name, service); err != nil {
+			return err
+		}
+	}
+	switch backup.Mode {
+	case "", BackupModePgDump, BackupModeMySQLDump, BackupModeRedisBGSave, BackupModePgBaseBackup:
+	case BackupModeHook:
+		if backup.PreBackup == "" && backup.PostBackup == "" {
+			return fmt.Errorf("service %s: backup mode hook requires backup.preBackup or backup.postBackup", name)
+		}
+	default:
+		return fmt.Errorf("service %s: backup mode must be volume, pg_dump, mysqldump, redis-bgsave, pg_basebackup, or hook", name)
+	}
+	if backup.Database != "" {
+		if backup.Mode != BackupModePgDump && backup.Mode != BackupModeMySQLDump {
+			return fmt.Errorf("service %s: backup.database requires backup mode pg_dump or mysqldump", name)
+		}
+		if !backupDatabasePattern.MatchString(backup.Database) {
+			return fmt.Errorf("service %s: invalid backup.database %q", name, backup.Database)
+		}
+	}
+	if len(backup.PreBackup) > 4096 || len(backup.PostBackup) > 4096 {
+		return fmt.Errorf("service %s: backup hooks must be 4096 bytes or less", name)
+	}
+	if !backup.IsDatabaseDump() {
+		return nil
+	}
+	volumes := backup.Volumes
+	if len(volumes) == 0 {
+		for volume := range backupableServiceVolumeNames(service.Volumes) {
+			volumes = append(volumes, volume)
+		}
+	}
+	if len(volumes) != 1 {
+		return fmt.Errorf("service %s: backup mode %s writes one database dump; set backup.volumes to the single volume that holds the database", name, backup.Mode)
+	}
+	return nil
+}
+
+// validateBackupPITR defaults a PITR backup to pg_basebackup mode. WAL is
+// archived to backup storage between base backups, so the service must be a
+// persistent Postgres with a storage target. A chunked target encrypts the
+// archived WAL; an archive target stores it as is, like its backups.
+func validateBackupPITR(name string, service *ServiceConfig) error {
+	backup := service.Backup
+	switch backup.Mode {
+	case "":
+		backup.Mode = BackupModePgBaseBackup
+	case BackupModePgBaseBackup:
+	default:
+		return fmt.Errorf("service %s: backup.pitr requires backup mode pg_basebackup", name)
+	}
+	if !service.Persistent {
+		return fmt.Errorf("service %s: backup.pitr requires persistent: true", name)
+	}
+	if backup.Storage == nil {
+		return fmt.Errorf("service %s: backup.pitr requires backup.storage to archive WAL into", name)
+	}
 	return nil
 }
 
@@ -1374,11 +1532,52 @@ func validateBackupStorageConfig(name string, storage *BackupStorageConfig) erro
 	if storage.Provider == "" {
 		storage.Provider = BackupStorageProviderS3
 	}
+	var err error
 	switch storage.Provider {
 	case BackupStorageProviderS3, BackupStorageProviderR2, BackupStorageProviderS3Compatible:
+		err = validateS3BackupStorageConfig(name, storage)
+	case BackupStorageProviderSFTP:
+		err = validateSFTPBackupStorageConfig(name, storage)
+	case BackupStorageProviderFilesystem:
+		err = validateFilesystemBackupStorageConfig(name, storage)
+	case BackupStorageProviderPeer:
+		storage.Node = strings.TrimSpace(storage.Node)
+		if storage.Node == "" {
+			err = fmt.Errorf("service %s: backup.storage.node is required for peer", name)
+		} else if !isValidRuntimeName(storage.Node) {
+			err = fmt.Errorf("service %s: backup.storage.node %q is not a valid node name", name, storage.Node)
+		}
+	default:
+		return fmt.Errorf("service %s: backup.storage.provider must be s3, r2, s3-compatible, sftp, filesystem, or peer", name)
+	}
+	if err != nil {
+		return err
+	}
+	storage.Prefix = cleanBackupStoragePrefix(storage.Prefix)
+	storage.Format = strings.TrimSpace(storage.Format)
+	if storage.Format == BackupStorageFormatArchive {
+		storage.Format = ""
+	}
+	storage.EncryptionKey = strings.TrimSpace(storage.EncryptionKey)
+	switch storage.Format {
+	case "":
+		if storage.EncryptionKey != "" {
+			return fmt.Errorf("service %s: backup.storage.encryptionKey requires backup.storage.format: chunked", name)
+		}
+	case BackupStorageFormatChunked:
+		if storage.EncryptionKey == "" {
+			return fmt.Errorf("service %s: backup.storage.encryptionKey is required for chunked backups", name)
+		}
+		if len(storage.EncryptionKey) < minBackupEncryptionKeyLength {
+			return fmt.Errorf("service %s: backup.storage.encryptionKey must be at least %d characters", name, minBackupEncryptionKeyLength)
+		}
 	default:
-		return fmt.Errorf("service %s: backup.storage.provider must be s3, r2, or s3-compatible", name)
+		return fmt.Errorf("service %s: backup.storage.format must be archive or chunked", name)
 	}
+	return nil
+}
+
+func validateS3BackupStorageConfig(name string, storage *BackupStorageConfig) error {
 	storage.Bucket = strings.TrimSpace(storage.Bucket)
 	if storage.Bucket == "" {
 		return fmt.Errorf("service %s: backup.storage.bucket is required", name)
@@ -1404,7 +1603,6 @@ func validateBackupStorageConfig(name string, storage *BackupStorageConfig) erro
 			return fmt.Errorf("service %s: backup.storage.endpoint must use http or https", name)
 		}
 	}
-	storage.Prefix = cleanBackupStoragePrefix(storage.Prefix)
 	storage.AccessKeyID = strings.TrimSpace(storage.AccessKeyID)
 	if storage.AccessKeyID == "" {
 		return fmt.Errorf("service %s: backup.storage.accessKeyId is required", name)
@@ -1417,6 +1615,47 @@ func validateBackupStorageConfig(name string, storage *BackupStorageConfig) erro
 	return nil
 }
 
+func validateSFTPBackupStorageConfig(name string, storage *BackupStorageConfig) error {
+	storage.Host = strings.TrimSpace(storage.Host)
+	if err := validateHostOrIP(storage.Host); err != nil {
+		return fmt.Errorf("service %s: backup.storage.host: %w", name, err)
+	}
+	if storage.Port == 0 {
+		storage.Port = 22
+	}
+	if storage.Port < 1 || storage.Port > 65535 {
+		return fmt.Errorf("service %s: backup.storage.port must be between 1 and 65535", name)
+	}
+	storage.User = strings.TrimSpace(storage.User)
+	if storage.User == "" {
+		return fmt.Errorf("service %s: backup.storage.user is required for sftp", name)
+	}
+	storage.PrivateKey = strings.TrimSpace(storage.PrivateKey)
+	if storage.PrivateKey == "" && storage.Password == "" {
+		return fmt.Errorf("service %s: backup.storage.privateKey or backup.storage.password is required for sftp", name)
+	}
+	storage.HostKey = strings.TrimSpace(storage.HostKey)
+	if storage.HostKey == "" {
+		return fmt.Errorf("service %s: backup.storage.hostKey is required for sftp so the server identity is pinned", name)
+	}
+	storage.Path = strings.TrimRight(strings.TrimSpace(storage.Path), "/")
+	if strings.Contains(storage.Path, "..") {
+		return fmt.Errorf("service %s: backup.storage.path must not contain '..'", name)
+	}
+	return nil
+}
+
+func validateFilesystemBackupStorageConfig(name string, storage *BackupStorageConfig) error {
+	storage.Path = strings.TrimSpace(storage.Path)
+	if storage.Path == "" {
+		return fmt.Errorf("service %s: backup.storage.path is required for filesystem", name)
+	}
+	if !path.IsAbs(storage.Path) || path.Clean(storage.Path) != storage.Path || storage.Path == "/" {
+		return fmt.Errorf("service %s: backup.storage.path must be a clean absolute directory other than /", name)
+	}
+	return nil
+}
+
 func cleanBackupStoragePrefix(prefix string) string {
 	prefix = strings.TrimSpace(prefix)
 	prefix = strings.Trim(prefix, "/")
@@ -1448,6 +1687,14 @@ func backupableServiceVolumeNames(volumes []string) map[string]bool {
 }
 
 func validateDeployStrategy(name string, service *ServiceConfig) error {
+	if service.Deploy.Canary != nil && service.Deploy.Strategy != DeployStrategyCanary {
+		return fmt.Errorf("service %s: deploy.canary is only supported by canary", name)
+	}
+	if service.Deploy.Analysis != nil {
+		if err := validateDeployAnalysis(name, service.Deploy); err != nil {
+			return err
+		}
+	}
 	switch service.Deploy.Strategy {
 	case DeployStrategyRecreate:
 		if service.Deploy.MaxUnavailable < 0 {
@@ -1457,7 +1704,7 @@ func validateDeployStrategy(name string, service *ServiceConfig) error {
 			return fmt.Errorf("service %s: deploy.gracePeriod is only supported by blue_green", name)
 		}
 		return nil
-	case DeployStrategyRolling, DeployStrategyBlueGreen:
+	case DeployStrategyRolling, DeployStrategyBlueGreen, DeployStrategyCanary:
 		if service.Persistent {
 			return fmt.Errorf("service %s: deploy.strategy=%s is not supported for persistent services; use recreate with declared volumes or move state outside the app container before using no-downtime strategies", name, service.Deploy.Strategy)
 		}
@@ -1520,9 +1767,30 @@ func validateDeployStrategy(name string, service *ServiceConfig) error {
 			}
 			return nil
 		}
+		if service.Deploy.Strategy == DeployStrategyCanary {
+			if service.Proxy == nil {
+				return fmt.Errorf("service %s: deploy.strategy=canary requires a public proxy route to split traffic", name)
+			}
+			if service.LoadBalancer.Strategy == "sticky" {
+				return fmt.Errorf("service %s: deploy.strategy=canary cannot use loadBalancer.strategy sticky; weighted canary routing replaces the load balancing policy", name)
+			}
+			if service.Deploy.SmokeTest.Path != "" && service.Port <= 0 {
+				return fmt.Errorf("service %s: deploy.smokeTest requires service port", name)
+			}
+			if service.Deploy.MaxUnavailable > 0 {
+				return fmt.Errorf("service %s: deploy.maxUnavailable is not supported for canary; the previous revision keeps serving until the canary reaches 100%%", name)
+			}
+			if service.Deploy.MaxSurge > 0 && service.Deploy.MaxSurge < service.Replicas {
+				return fmt.Errorf("service %s: deploy.maxSurge must be at least replicas (%d) for canary, or omit it to let Tako warm a full canary revision", name, service.Replicas)
+			}
+			if err := validateBlueGreenGracePeriod(name, service.Deploy.GracePeriod); err != nil {
+				return err
+			}
+			return validateDeployCanary(name, service.Deploy)
+		}
 		return nil
 	default:
-		return fmt.Errorf("service %s: invalid deployment strategy %q; supported strategies are recreate, rolling, and blue_green", name, service.Deploy.Strategy)
+		return fmt.Errorf("service %s: invalid deployment strategy %q; supported strategies are recreate, rolling, blue_green, and canary", name, service.Deploy.Strategy)
 	}
 }
 
@@ -1575,6 +1843,15 @@ func validateServiceKind(name string, service *ServiceConfig) error {
 		if service.Timeout != "" {
 			return fmt.Errorf("service %s: timeout requires kind: job", name)
 		}
+		if len(service.JobUpstreams()) > 0 {
+			return fmt.Errorf("service %s: after, onSuccess, and onFailure require kind: job", name)
+		}
+		if service.Retries != 0 || service.RetryBackoff != "" || service.ConcurrencyPolicy != "" {
+			return fmt.Errorf("service %s: retries, retryBackoff, and concurrencyPolicy require kind: job", name)
+		}
+		if service.LogArchive != nil {
+			return fmt.Errorf("service %s: logArchive requires kind: job", name)
+		}
 		return nil
 	case ServiceKindJob:
 		// fallthrough to job validation below
@@ -1584,12 +1861,21 @@ func validateServiceKind(name string, service *ServiceConfig) error {
 		return fmt.Errorf("service %s: kind must be service, job, or run", name)
 	}
 
-	if strings.TrimSpace(service.Schedule) == "" {
-		return fmt.Errorf("service %s: kind: job requires a schedule (cron expression)", name)
+	if err := validateJobUpstreams(name, service); err != nil {
+		return err
 	}
-	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
-	if _, err := parser.Parse(service.Schedule); err != nil {
-		return fmt.Errorf("service %s: invalid schedule: %v", name, err)
+	if len(service.JobUpstreams()) > 0 {
+		if service.Schedule != "" || service.Timezone != "" {
+			return fmt.Errorf("service %s: a job with after, onSuccess, or onFailure runs in its upstream's workflow and cannot set schedule or timezone", name)
+		}
+	} else {
+		if strings.TrimSpace(service.Schedule) == "" {
+			return fmt.Errorf("service %s: kind: job requires a schedule (cron expression) or upstream jobs (after, onSuccess, onFailure)", name)
+		}
+		parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+		if _, err := parser.Parse(service.Schedule); err != nil {
+			return fmt.Errorf("service %s: invalid schedule: %v", name, err)
+		}
 	}
 	if !service.Command.IsSet() {
 		return fmt.Errorf("service %s: kind: job requires a command", name)
@@ -1604,6 +1890,17 @@ func validateServiceKind(name string, service *ServiceConfig) error {
 			return err
 		}
 	}
+	if err := validateJobRetryPolicy(name, service); err != nil {
+		return err
+	}
+	if archive := service.LogArchive; archive != nil {
+		if archive.MaxSizeMB < 0 || archive.MaxSizeMB > MaxJobLogArchiveSizeMB {
+			return fmt.Errorf("service %s: logArchive.maxSizeMB must be between 0 and %d", name, MaxJobLogArchiveSizeMB)
+		}
+		if archive.Retain < 0 || archive.Retain > MaxJobLogArchiveRetain {
+			return fmt.Errorf("service %s: logArchive.retain must be between 0 and %d days", name, MaxJobLogArchiveRetain)
+		}
+	}
 	if service.Proxy != nil {
 		return fmt.Errorf("service %s: kind: job cannot be proxied (remove proxy)", name)
 	}
@@ -1622,6 +1919,120 @@ func validateServiceKind(name string, service *ServiceConfig) error {
 	return nil
 }
 
+func validateJobRetryPolicy(name string, service *ServiceConfig) error {
+	if service.Retries < 0 || service.Retries > MaxJobRetries {
+		return fmt.Errorf("service %s: retries must be between 0 and %d", name, MaxJobRetries)
+	}
+	if service.RetryBackoff != "" {
+		if service.Retries == 0 {
+			return fmt.Errorf("service %s: retryBackoff requires retries", name)
+		}
+		backoff, err := time.ParseDuration(service.RetryBackoff)
+		// takod schedules retries in whole seconds; reject what it would
+		// truncate.
+		if err != nil || backoff < time.Second || backoff > 15*time.Minute || backoff%time.Second != 0 {
+			return fmt.Errorf("service %s: retryBackoff must be a duration of whole seconds between 1s and 15m", name)
+		}
+	}
+	switch service.ConcurrencyPolicy {
+	case "", JobConcurrencyForbid, JobConcurrencyReplace, JobConcurrencyAllow:
+		return nil
+	default:
+		return fmt.Errorf("service %s: concurrencyPolicy must be forbid, replace, or allow", name)
+	}
+}
+
+func validateJobUpstreams(name string, service *ServiceConfig) error {
+	seen := make(map[string]bool)
+	for _, upstream := range service.JobUpstreams() {
+		if !isValidRuntimeIdentifier(upstream) {
+			return fmt.Errorf("service %s: invalid upstream job %q", name, upstream)
+		}
+		if upstream == name {
+			return fmt.Errorf("service %s: a job cannot depend on itself", name)
+		}
+		if seen[upstream] {
+			return fmt.Errorf("service %s: upstream job %s is listed more than once across after, onSuccess, and onFailure", name, upstream)
+		}
+		seen[upstream] = true
+	}
+	return nil
+}
+
+// validateJobWorkflows checks job dependency edges across the environment:
+// upstreams must be jobs, edges must not form a cycle, and every dependent
+// job must trace back to exactly one scheduled job, whose run it joins.
+func validateJobWorkflows(envName string, env *EnvironmentConfig) error {
+	var names []string
+	for name, service := range env.Services {
+		if service.IsJob() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, upstream := range env.Services[name].JobUpstreams() {
+			source, ok := env.Services[upstream]
+			if !ok {
+				return fmt.Errorf("environment %s: job %s depends on unknown job %q", envName, name, upstream)
+			}
+			if !source.IsJob() {
+				return fmt.Errorf("environment %s: job %s depends on %s, which is not kind: job", envName, name, upstream)
+			}
+		}
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	roots := make(map[string]map[string]bool)
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("environment %s: job dependency cycle: %s", envName, strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		upstreams := env.Services[name].JobUpstreams()
+		reached := make(map[string]bool)
+		if len(upstreams) == 0 {
+			reached[name] = true
+		}
+		for _, upstream := range upstreams {
+			if err := visit(upstream, append(path, name)); err != nil {
+				return err
+			}
+			for root := range roots[upstream] {
+				reached[root] = true
+			}
+		}
+		state[name] = visited
+		roots[name] = reached
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		if len(roots[name]) <= 1 {
+			continue
+		}
+		scheduled := make([]string, 0, len(roots[name]))
+		for root := range roots[name] {
+			scheduled = append(scheduled, root)
+		}
+		sort.Strings(scheduled)
+		return fmt.Errorf("environment %s: job %s depends on several scheduled jobs (%s); a workflow must start from a single scheduled job", envName, name, strings.Join(scheduled, ", "))
+	}
+	return nil
+}
+
 func validateSharedBuilds(builds map[string]SharedBuildConfig) error {
 	for name, build := range builds {
 		if !isValidRuntimeIdentifier(name) {
@@ -1662,6 +2073,15 @@ func validateRunServiceKind(name string, service *ServiceConfig) error {
 	if service.Schedule != "" || service.Timezone != "" {
 		return fmt.Errorf("service %s: kind: run cannot set schedule or timezone", name)
 	}
+	if len(service.JobUpstreams()) > 0 {
+		return fmt.Errorf("service %s: kind: run cannot set after, onSuccess, or onFailure", name)
+	}
+	if service.Retries != 0 || service.RetryBackoff != "" || service.ConcurrencyPolicy != "" {
+		return fmt.Errorf("service %s: kind: run cannot set retries, retryBackoff, or concurrencyPolicy", name)
+	}
+	if service.LogArchive != nil {
+		return fmt.Errorf("service %s: kind: run cannot set logArchive", name)
+	}
 	if !service.Command.IsList() {
 		return fmt.Errorf("service %s: kind: run requires command in argv list form", name)
 	}
@@ -1753,6 +2173,88 @@ func validateDeploySmokeTest(name string, smoke DeploySmokeTestConfig) error {
 	return nil
 }
 
+// validateDeployCanary checks the canary traffic ramp: ascending
+// percentages ending at 100, and a hold period whenever steps advance
+// without an operator.
+func validateDeployCanary(name string, deploy DeployConfig) error {
+	steps := deploy.Canary.EffectiveSteps()
+	if len(steps) > maxCanarySteps {
+		return fmt.Errorf("service %s: deploy.canary.steps cannot have more than %d entries", name, maxCanarySteps)
+	}
+	previous := 0
+	for _, step := range steps {
+		if step < 1 || step > 100 {
+			return fmt.Errorf("service %s: deploy.canary.steps must be percentages between 1 and 100", name)
+		}
+		if step <= previous {
+			return fmt.Errorf("service %s: deploy.canary.steps must be strictly ascending", name)
+		}
+		previous = step
+	}
+	if previous != 100 {
+		return fmt.Errorf("service %s: deploy.canary.steps must end at 100 so the canary can be promoted", name)
+	}
+	hold := ""
+	if deploy.Canary != nil {
+		hold = strings.TrimSpace(deploy.Canary.Hold)
+	}
+	if hold == "" {
+		if deploy.Promotion != DeployPromotionManual && len(steps) > 1 {
+			return fmt.Errorf("service %s: deploy.canary.hold is required for automatic canary promotion; set deploy.promotion: manual to advance steps with tako promote", name)
+		}
+		return nil
+	}
+	duration, err := time.ParseDuration(hold)
+	if err != nil {
+		return fmt.Errorf("service %s: deploy.canary.hold must be a duration like 5m or 1h: %w", name, err)
+	}
+	if duration <= 0 {
+		return fmt.Errorf("service %s: deploy.canary.hold must be positive", name)
+	}
+	if duration > maxServiceHealthDuration {
+		return fmt.Errorf("service %s: deploy.canary.hold cannot exceed %s", name, maxServiceHealthDuration)
+	}
+	return nil
+}
+
+// validateDeployAnalysis checks traffic analysis thresholds. Analysis needs
+// a window in which the previous revision still runs: every canary step, or
+// the blue_green grace period.
+func validateDeployAnalysis(name string, deploy DeployConfig) error {
+	analysis := deploy.Analysis
+	switch deploy.Strategy {
+	case DeployStrategyCanary:
+	case DeployStrategyBlueGreen:
+		if strings.TrimSpace(deploy.GracePeriod) == "" {
+			return fmt.Errorf("service %s: deploy.analysis with blue_green requires deploy.gracePeriod as the analysis window", name)
+		}
+	default:
+		return fmt.Errorf("service %s: deploy.analysis is only supported by canary and blue_green", name)
+	}
+	if analysis.MaxErrorRate == nil && analysis.MaxP95Latency == "" && analysis.MaxP99Latency == "" {
+		return fmt.Errorf("service %s: deploy.analysis requires at least one of maxErrorRate, maxP95Latency, or maxP99Latency", name)
+	}
+	if analysis.MaxErrorRate != nil && (*analysis.MaxErrorRate < 0 || *analysis.MaxErrorRate > 100) {
+		return fmt.Errorf("service %s: deploy.analysis.maxErrorRate must be a percentage between 0 and 100", name)
+	}
+	for _, threshold := range []struct{ field, value string }{
+		{field: "maxP95Latency", value: analysis.MaxP95Latency},
+		{field: "maxP99Latency", value: analysis.MaxP99Latency},
+	} {
+		if strings.TrimSpace(threshold.value) == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(strings.TrimSpace(threshold.value))
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("service %s: deploy.analysis.%s must be a positive duration like 300ms", name, threshold.field)
+		}
+	}
+	if analysis.MinRequests < 0 {
+		return fmt.Errorf("service %s: deploy.analysis.minRequests cannot be negative", name)
+	}
+	return nil
+}
+
 func validateBlueGreenGracePeriod(name string, value string) error {
 	value = strings.TrimSpace(value)
 	if value == "" {
@@ -1878,6 +2380,12 @@ func validateProxy(projectName string, envName string, serviceName string, proxy
 	if err := validateProxyAccessControls(serviceName, proxy); err != nil {
 		return err
 	}
+	if err := validateProxyPaths(serviceName, proxy); err != nil {
+		return err
+	}
+	if err := validateProxyLimits(serviceName, proxy); err != nil {
+		return err
+	}
 	visibility := strings.ToLower(strings.TrimSpace(proxy.Visibility))
 	if visibility == "" {
 		visibility = ProxyVisibilityPublic
@@ -2020,8 +2528,54 @@ func validateProxy(projectName string, envName string, serviceName string, proxy
 var (
 	dockerCPULimitPattern     = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
 	proxyBasicAuthUserPattern = regexp.MustCompile(`^[A-Za-z0-9._@-]+$`)
+	proxyPathPattern          = regexp.MustCompile(`^/[A-Za-z0-9/._~%-]*\*?$`)
+	proxyHeaderNamePattern    = regexp.MustCompile(`^[A-Za-z0-9-]{1,64}$`)
+	backupDatabasePattern     = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_$-]{0,63}$`)
 )
 
+const maxProxyPaths = 16
+
+// validateProxyPaths normalizes proxy.paths. A lone "/" or "/*" is the
+// catch-all, which is the same as declaring no paths.
+func validateProxyPaths(serviceName string, proxy *ProxyConfig) error {
+	seen := map[string]bool{}
+	paths := make([]string, 0, len(proxy.Paths))
+	catchAll := false
+	for _, path := range proxy.Paths {
+		path = strings.TrimSpace(path)
+		if path == "/" || path == "/*" {
+			catchAll = true
+			continue
+		}
+		if !proxyPathPattern.MatchString(path) || len(path) > 256 {
+			return fmt.Errorf("service %s: invalid proxy path %q (must start with / and may end with a single *)", serviceName, path)
+		}
+		if seen[path] {
+			return fmt.Errorf("service %s: duplicate proxy path %q", serviceName, path)
+		}
+		seen[path] = true
+		paths = append(paths, path)
+	}
+	if catchAll && len(paths) > 0 {
+		return fmt.Errorf("service %s: proxy.paths cannot mix / with other paths; omit paths to serve every path", serviceName)
+	}
+	if len(paths) > maxProxyPaths {
+		return fmt.Errorf("service %s: proxy.paths allows at most %d paths", serviceName, maxProxyPaths)
+	}
+	if len(paths) == 0 {
+		proxy.Paths = nil
+	} else {
+		proxy.Paths = paths
+	}
+	if proxy.StripPrefix && len(proxy.Paths) == 0 {
+		return fmt.Errorf("service %s: proxy.stripPrefix requires proxy.paths", serviceName)
+	}
+	if len(proxy.Paths) > 0 && proxy.DynamicDomains != nil && proxy.DynamicDomains.IsEnabled() {
+		return fmt.Errorf("service %s: proxy.paths cannot be combined with dynamicDomains", serviceName)
+	}
+	return nil
+}
+
 func validateProxyAccessControls(serviceName string, proxy *ProxyConfig) error {
 	if auth := proxy.BasicAuth; auth != nil {
 		auth.Username = strings.TrimSpace(auth.Username)
@@ -2286,8 +2840,18 @@ func isValidRuntimeName(name string) bool {
 // validateDomainUniqueness checks for duplicate domains across all services in an environment
 func validateDomainUniqueness(envName string, env *EnvironmentConfig) error {
 	domainToService := make(map[string]string)
+	// Services may share a domain when they route disjoint paths; at most
+	// one of them (the one without paths) serves the remaining paths.
+	pathToService := make(map[string]string)
 
-	for serviceName, service := range env.Services {
+	serviceNames := make([]string, 0, len(env.Services))
+	for serviceName := range env.Services {
+		serviceNames = append(serviceNames, serviceName)
+	}
+	sort.Strings(serviceNames)
+
+	for _, serviceName := range serviceNames {
+		service := env.Services[serviceName]
 		if service.Proxy == nil {
 			continue
 		}
@@ -2297,11 +2861,33 @@ func validateDomainUniqueness(envName string, env *EnvironmentConfig) error {
 		for _, domain := range allDomains {
 			normalizedDomain := strings.ToLower(domain)
 
+			if len(service.Proxy.Paths) > 0 {
+				if existingService, exists := domainToService[normalizedDomain]; exists && strings.HasSuffix(existingService, " (redirect)") {
+					return fmt.Errorf(
+						"environment %s: domain conflict - domain '%s' routes paths for service '%s' but redirects for service '%s'\n"+
+							"  A redirect domain cannot also serve paths.",
+						envName, domain, serviceName, strings.TrimSuffix(existingService, " (redirect)"),
+					)
+				}
+				for _, path := range service.Proxy.Paths {
+					key := normalizedDomain + path
+					if existingService, exists := pathToService[key]; exists {
+						return fmt.Errorf(
+							"environment %s: domain conflict - path '%s%s' is routed to both service '%s' and service '%s'\n"+
+								"  Each path on a domain can only be routed to one service.",
+							envName, domain, path, existingService, serviceName,
+						)
+					}
+					pathToService[key] = serviceName
+				}
+				continue
+			}
+
 			if existingService, exists := domainToService[normalizedDomain]; exists {
 				return fmt.Errorf(
 					"environment %s: domain conflict - domain '%s' is used by both service '%s' and service '%s'\n"+
 						"  Each domain can only be assigned to one service.\n"+
-						"  Suggestion: Remove the duplicate domain from one of the services or use different domains.",
+						"  Suggestion: Remove the duplicate domain from one of the services, use different domains, or split it with proxy.paths.",
 					envName, domain, existingService, serviceName,
 				)
 			}
@@ -2313,7 +2899,11 @@ func validateDomainUniqueness(envName string, env *EnvironmentConfig) error {
 		for _, redirectDomain := range service.Proxy.GetRedirectDomains() {
 			normalizedDomain := strings.ToLower(redirectDomain)
 
-			if existingService, exists := domainToService[normalizedDomain]; exists {
+			existingService, exists := domainToService[normalizedDomain]
+			if !exists {
+				existingService, exists = proxyPathDomainService(pathToService, normalizedDomain)
+			}
+			if exists {
 				return fmt.Errorf(
 					"environment %s: domain conflict - redirect domain '%s' (service '%s') conflicts with domain in service '%s'\n"+
 						"  Each domain can only be assigned to one service.\n"+
@@ -2329,6 +2919,16 @@ func validateDomainUniqueness(envName string, env *EnvironmentConfig) error {
 	return nil
 }
 
+// proxyPathDomainService returns a service routing any path on domain.
+func proxyPathDomainService(pathToService map[string]string, domain string) (string, bool) {
+	for key, service := range pathToService {
+		if strings.HasPrefix(key, domain+"/") {
+			return service, true
+		}
+	}
+	return "", false
+}
+
 // validateVolumes validates the top-level volumes section
 func validateVolumes(volumes map[string]VolumeConfig) error {
 	for name, vol := range volumes {
@@ -345,6 +345,13 @@ func desiredServiceToConfig(name string, service takoapi.DesiredServiceDocument)
 		}
 		out.HealthCheck = healthCheck
 	}
+	if len(service.SecretsDelivery) > 0 {
+		delivery, err := decodeRaw[config.SecretsDeliveryConfig](service.SecretsDelivery, "secretsDelivery", name)
+		if err != nil {
+			return config.ServiceConfig{}, warnings, err
+		}
+		out.SecretsDelivery = &delivery
+	}
 	if out.IsRun() {
 		out.Restart = ""
 		out.Deploy = config.DeployConfig{}
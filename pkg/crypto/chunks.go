@@ -0,0 +1,120 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// ChunkHeader marks an encrypted content-addressed backup chunk
+const ChunkHeader = "TAKO_CHUNK_V1:"
+
+// ChunkKeyParams records how a chunk repository derives its keys. It holds no
+// secret material and is stored in cleartext next to the chunks.
+type ChunkKeyParams struct {
+	Salt    string `json:"salt"`    // Base64 encoded Argon2id salt
+	Time    uint32 `json:"time"`    // Argon2id time parameter
+	Memory  uint32 `json:"memory"`  // Argon2id memory parameter (KiB)
+	Threads uint8  `json:"threads"` // Argon2id threads parameter
+}
+
+// ChunkKeys encrypts and addresses chunks of one repository. Chunk IDs are
+// keyed so the store cannot confirm guesses about cleartext content.
+type ChunkKeys struct {
+	idKey []byte
+	gcm   cipher.AEAD
+}
+
+// NewChunkKeyParams generates a fresh salt with the default Argon2id cost
+func NewChunkKeyParams() (ChunkKeyParams, error) {
+	salt := make([]byte, argonSaltLen)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return ChunkKeyParams{}, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return ChunkKeyParams{
+		Salt:    base64.StdEncoding.EncodeToString(salt),
+		Time:    argonTime,
+		Memory:  argonMemory,
+		Threads: argonThreads,
+	}, nil
+}
+
+// DeriveChunkKeys stretches the passphrase once with Argon2id and splits the
+// result into independent encryption and chunk ID keys with HKDF.
+func DeriveChunkKeys(passphrase string, params ChunkKeyParams) (*ChunkKeys, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("chunk passphrase is required")
+	}
+	salt, err := base64.StdEncoding.DecodeString(params.Salt)
+	if err != nil || len(salt) < argonSaltLen {
+		return nil, fmt.Errorf("invalid chunk key salt")
+	}
+	if params.Time == 0 || params.Memory == 0 || params.Threads == 0 {
+		return nil, fmt.Errorf("invalid chunk key parameters")
+	}
+	master := argon2.IDKey([]byte(passphrase), salt, params.Time, params.Memory, params.Threads, keySize)
+	encKey, err := hkdf.Key(sha256.New, master, nil, "tako chunk encryption", keySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive chunk encryption key: %w", err)
+	}
+	idKey, err := hkdf.Key(sha256.New, master, nil, "tako chunk id", keySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive chunk id key: %w", err)
+	}
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return &ChunkKeys{idKey: idKey, gcm: gcm}, nil
+}
+
+// ID returns the hex HMAC-SHA256 of plaintext under the repository ID key
+func (k *ChunkKeys) ID(plaintext []byte) string {
+	mac := hmac.New(sha256.New, k.idKey)
+	mac.Write(plaintext)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Seal encrypts plaintext with AES-256-GCM, binding it to name so an object
+// moved to another key fails to open.
+func (k *ChunkKeys) Seal(name string, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, k.gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	out := make([]byte, 0, len(ChunkHeader)+len(nonce)+len(plaintext)+k.gcm.Overhead())
+	out = append(out, ChunkHeader...)
+	out = append(out, nonce...)
+	return k.gcm.Seal(out, nonce, plaintext, []byte(name)), nil
+}
+
+// Open decrypts data produced by Seal for the same name
+func (k *ChunkKeys) Open(name string, data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(ChunkHeader)) {
+		return nil, fmt.Errorf("data is not an encrypted chunk (missing header)")
+	}
+	data = data[len(ChunkHeader):]
+	if len(data) < k.gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted chunk is truncated")
+	}
+	nonce, ciphertext := data[:k.gcm.NonceSize()], data[k.gcm.NonceSize():]
+	plaintext, err := k.gcm.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed (wrong key or corrupted chunk)")
+	}
+	return plaintext, nil
+}
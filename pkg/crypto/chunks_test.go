@@ -0,0 +1,86 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+// testChunkKeyParams keeps Argon2id cheap; the derivation is the same at any
+// cost.
+func testChunkKeyParams(t *testing.T) ChunkKeyParams {
+	t.Helper()
+	params, err := NewChunkKeyParams()
+	if err != nil {
+		t.Fatalf("NewChunkKeyParams: %v", err)
+	}
+	params.Time, params.Memory, params.Threads = 1, 1024, 1
+	return params
+}
+
+func deriveTestChunkKeys(t *testing.T, passphrase string, params ChunkKeyParams) *ChunkKeys {
+	t.Helper()
+	keys, err := DeriveChunkKeys(passphrase, params)
+	if err != nil {
+		t.Fatalf("DeriveChunkKeys: %v", err)
+	}
+	return keys
+}
+
+func TestChunkIDsAreDeterministicPerRepository(t *testing.T) {
+	params := testChunkKeyParams(t)
+	keys := deriveTestChunkKeys(t, "correct horse battery staple", params)
+	chunk := []byte("volume chunk contents")
+
+	id := keys.ID(chunk)
+	if len(id) != sha256.Size*2 {
+		t.Fatalf("chunk id %q is not a hex SHA-256", id)
+	}
+	if again := deriveTestChunkKeys(t, "correct horse battery staple", params).ID(chunk); again != id {
+		t.Fatalf("re-derived keys gave id %s, want %s", again, id)
+	}
+	if keys.ID([]byte("volume chunk contents!")) == id {
+		t.Fatal("different chunks share an id")
+	}
+	plain := sha256.Sum256(chunk)
+	if id == hex.EncodeToString(plain[:]) {
+		t.Fatal("chunk id is the unkeyed SHA-256 of the chunk")
+	}
+	if other := deriveTestChunkKeys(t, "another passphrase", params).ID(chunk); other == id {
+		t.Fatal("another passphrase gave the same chunk id")
+	}
+	if other := deriveTestChunkKeys(t, "correct horse battery staple", testChunkKeyParams(t)).ID(chunk); other == id {
+		t.Fatal("another repository salt gave the same chunk id")
+	}
+}
+
+func TestChunkKeysSealBindsName(t *testing.T) {
+	params := testChunkKeyParams(t)
+	keys := deriveTestChunkKeys(t, "correct horse battery staple", params)
+	plaintext := []byte("volume chunk contents")
+
+	sealed, err := keys.Seal("chunk-a", plaintext)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if !bytes.HasPrefix(sealed, []byte(ChunkHeader)) || bytes.Contains(sealed, plaintext) {
+		t.Fatalf("sealed chunk = %q", sealed)
+	}
+	opened, err := deriveTestChunkKeys(t, "correct horse battery staple", params).Open("chunk-a", sealed)
+	if err != nil || !bytes.Equal(opened, plaintext) {
+		t.Fatalf("Open = %q, %v", opened, err)
+	}
+	if _, err := keys.Open("chunk-b", sealed); err == nil {
+		t.Fatal("chunk opened under another name")
+	}
+	if _, err := deriveTestChunkKeys(t, "another passphrase", params).Open("chunk-a", sealed); err == nil {
+		t.Fatal("chunk opened with another passphrase")
+	}
+	if _, err := keys.Open("chunk-a", sealed[:len(ChunkHeader)+4]); err == nil {
+		t.Fatal("truncated chunk opened")
+	}
+	if _, err := DeriveChunkKeys("", params); err == nil {
+		t.Fatal("DeriveChunkKeys accepted an empty passphrase")
+	}
+}
@@ -0,0 +1,424 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// EnvelopeHeader marks content sealed to SSH recipients
+const EnvelopeHeader = "TAKO_ENVELOPE_V1:"
+
+const (
+	envelopeInfoEd25519 = "tako-envelope-v1/ssh-ed25519"
+	envelopeLabelRSA    = "tako-envelope-v1/ssh-rsa"
+	minRecipientRSABits = 2048
+)
+
+// ErrNotRecipient is returned by OpenEnvelope when the identity is not one of
+// the envelope's recipients.
+var ErrNotRecipient = errors.New("identity is not a recipient of this envelope")
+
+// Recipient is an SSH public key (ssh-ed25519 or ssh-rsa) an envelope is
+// sealed to.
+type Recipient struct {
+	Fingerprint string // SHA256 fingerprint, as printed by ssh-keygen -l
+	Comment     string
+	key         ssh.PublicKey
+}
+
+// Identity is the SSH private key that opens envelopes sealed to its public
+// half.
+type Identity struct {
+	Fingerprint string
+	key         any
+}
+
+type envelope struct {
+	Recipients []envelopeStanza `json:"recipients"`
+	Nonce      string           `json:"nonce"`
+	Ciphertext string           `json:"ciphertext"`
+}
+
+// envelopeStanza carries the file key wrapped for one recipient. Ephemeral is
+// set for ssh-ed25519 stanzas only.
+type envelopeStanza struct {
+	Type        string `json:"type"`
+	Fingerprint string `json:"fingerprint"`
+	Ephemeral   string `json:"ephemeral,omitempty"`
+	WrappedKey  string `json:"wrappedKey"`
+}
+
+// ParseRecipients reads SSH public keys in authorized_keys format, one per
+// line. Blank lines and # comments are skipped; duplicate keys are dropped.
+func ParseRecipients(data []byte) ([]Recipient, error) {
+	var recipients []Recipient
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		recipient, err := ParseRecipient(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if seen[recipient.Fingerprint] {
+			continue
+		}
+		seen[recipient.Fingerprint] = true
+		recipients = append(recipients, recipient)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return recipients, nil
+}
+
+// ParseRecipient reads one SSH public key line
+func ParseRecipient(line string) (Recipient, error) {
+	key, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+	if err != nil {
+		return Recipient{}, fmt.Errorf("invalid SSH public key: %w", err)
+	}
+	switch key.Type() {
+	case ssh.KeyAlgoED25519:
+	case ssh.KeyAlgoRSA:
+		cryptoKey, ok := key.(ssh.CryptoPublicKey)
+		if !ok {
+			return Recipient{}, fmt.Errorf("unsupported ssh-rsa public key")
+		}
+		if rsaKey, ok := cryptoKey.CryptoPublicKey().(*rsa.PublicKey); !ok || rsaKey.N.BitLen() < minRecipientRSABits {
+			return Recipient{}, fmt.Errorf("ssh-rsa recipients must be at least %d bits", minRecipientRSABits)
+		}
+	default:
+		return Recipient{}, fmt.Errorf("unsupported recipient key type %s (use ssh-ed25519 or ssh-rsa)", key.Type())
+	}
+	return Recipient{Fingerprint: ssh.FingerprintSHA256(key), Comment: comment, key: key}, nil
+}
+
+// ParseIdentity reads an OpenSSH or PEM private key. Encrypted keys return
+// *ssh.PassphraseMissingError unless passphrase is set.
+func ParseIdentity(pemBytes []byte, passphrase []byte) (Identity, error) {
+	var (
+		raw any
+		err error
+	)
+	if len(passphrase) > 0 {
+		raw, err = ssh.ParseRawPrivateKeyWithPassphrase(pemBytes, passphrase)
+	} else {
+		raw, err = ssh.ParseRawPrivateKey(pemBytes)
+	}
+	if err != nil {
+		return Identity{}, err
+	}
+	var public any
+	switch key := raw.(type) {
+	case *ed25519.PrivateKey:
+		raw, public = *key, key.Public()
+	case ed25519.PrivateKey:
+		public = key.Public()
+	case *rsa.PrivateKey:
+		public = key.Public()
+	default:
+		return Identity{}, fmt.Errorf("unsupported identity key type %T (use ssh-ed25519 or ssh-rsa)", raw)
+	}
+	sshPublic, err := ssh.NewPublicKey(public)
+	if err != nil {
+		return Identity{}, err
+	}
+	return Identity{Fingerprint: ssh.FingerprintSHA256(sshPublic), key: raw}, nil
+}
+
+// IsEnvelope checks if data has the envelope header
+func IsEnvelope(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(EnvelopeHeader))
+}
+
+// SealEnvelope encrypts plaintext with a random AES-256-GCM file key and
+// wraps that key for every recipient, so any one of their private keys can
+// open it. The recipient stanzas are authenticated with the ciphertext, so a
+// stanza added, dropped, or altered after sealing fails to open.
+func SealEnvelope(plaintext []byte, recipients []Recipient) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("at least one recipient is required")
+	}
+	fileKey := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, fileKey); err != nil {
+		return nil, fmt.Errorf("failed to generate file key: %w", err)
+	}
+	env := envelope{Recipients: make([]envelopeStanza, 0, len(recipients))}
+	for _, recipient := range recipients {
+		stanza, err := wrapFileKey(fileKey, recipient)
+		if err != nil {
+			return nil, fmt.Errorf("recipient %s: %w", recipient.Fingerprint, err)
+		}
+		env.Recipients = append(env.Recipients, stanza)
+	}
+	additionalData, err := envelopeAdditionalData(env.Recipients)
+	if err != nil {
+		return nil, err
+	}
+	nonce, ciphertext, err := sealAESGCM(fileKey, plaintext, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	env.Nonce = base64.StdEncoding.EncodeToString(nonce)
+	env.Ciphertext = base64.StdEncoding.EncodeToString(ciphertext)
+	data, err := json.Marshal(env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+	return []byte(EnvelopeHeader + base64.StdEncoding.EncodeToString(data)), nil
+}
+
+// OpenEnvelope decrypts an envelope with identity, returning ErrNotRecipient
+// when no stanza matches it.
+func OpenEnvelope(data []byte, identity Identity) ([]byte, error) {
+	env, err := decodeEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, stanza := range env.Recipients {
+		if stanza.Fingerprint != identity.Fingerprint {
+			continue
+		}
+		fileKey, err := unwrapFileKey(stanza, identity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unwrap file key: %w", err)
+		}
+		nonce, err := base64.StdEncoding.DecodeString(env.Nonce)
+		if err != nil {
+			return nil, fmt.Errorf("invalid envelope nonce: %w", err)
+		}
+		ciphertext, err := base64.StdEncoding.DecodeString(env.Ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("invalid envelope ciphertext: %w", err)
+		}
+		additionalData, err := envelopeAdditionalData(env.Recipients)
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := openAESGCM(fileKey, nonce, ciphertext, additionalData)
+		if err != nil {
+			return nil, fmt.Errorf("decryption failed - envelope may be corrupted")
+		}
+		return plaintext, nil
+	}
+	return nil, ErrNotRecipient
+}
+
+// EnvelopeRecipients lists the fingerprints an envelope is sealed to
+func EnvelopeRecipients(data []byte) ([]string, error) {
+	env, err := decodeEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	fingerprints := make([]string, 0, len(env.Recipients))
+	for _, stanza := range env.Recipients {
+		fingerprints = append(fingerprints, stanza.Fingerprint)
+	}
+	return fingerprints, nil
+}
+
+func decodeEnvelope(data []byte) (*envelope, error) {
+	if !IsEnvelope(data) {
+		return nil, fmt.Errorf("data is not a recipient envelope")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data[len(EnvelopeHeader):])))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode envelope: %w", err)
+	}
+	var env envelope
+	if err := json.Unmarshal(decoded, &env); err != nil {
+		return nil, fmt.Errorf("failed to parse envelope: %w", err)
+	}
+	return &env, nil
+}
+
+// envelopeAdditionalData binds the header and every recipient stanza to the
+// ciphertext.
+func envelopeAdditionalData(recipients []envelopeStanza) ([]byte, error) {
+	stanzas, err := json.Marshal(recipients)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal envelope recipients: %w", err)
+	}
+	return append([]byte(EnvelopeHeader), stanzas...), nil
+}
+
+func wrapFileKey(fileKey []byte, recipient Recipient) (envelopeStanza, error) {
+	stanza := envelopeStanza{Type: recipient.key.Type(), Fingerprint: recipient.Fingerprint}
+	cryptoKey, ok := recipient.key.(ssh.CryptoPublicKey)
+	if !ok {
+		return stanza, fmt.Errorf("unsupported public key")
+	}
+	switch public := cryptoKey.CryptoPublicKey().(type) {
+	case ed25519.PublicKey:
+		recipientX, err := ed25519PublicToX25519(public)
+		if err != nil {
+			return stanza, err
+		}
+		ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return stanza, fmt.Errorf("failed to generate ephemeral key: %w", err)
+		}
+		shared, err := ephemeral.ECDH(recipientX)
+		if err != nil {
+			return stanza, err
+		}
+		wrapKey, err := x25519WrapKey(shared, ephemeral.PublicKey(), recipientX)
+		if err != nil {
+			return stanza, err
+		}
+		nonce, sealed, err := sealAESGCM(wrapKey, fileKey, nil)
+		if err != nil {
+			return stanza, err
+		}
+		stanza.Ephemeral = base64.StdEncoding.EncodeToString(ephemeral.PublicKey().Bytes())
+		stanza.WrappedKey = base64.StdEncoding.EncodeToString(append(nonce, sealed...))
+	case *rsa.PublicKey:
+		wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, public, fileKey, []byte(envelopeLabelRSA))
+		if err != nil {
+			return stanza, err
+		}
+		stanza.WrappedKey = base64.StdEncoding.EncodeToString(wrapped)
+	default:
+		return stanza, fmt.Errorf("unsupported public key type %T", public)
+	}
+	return stanza, nil
+}
+
+func unwrapFileKey(stanza envelopeStanza, identity Identity) ([]byte, error) {
+	wrapped, err := base64.StdEncoding.DecodeString(stanza.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	switch private := identity.key.(type) {
+	case ed25519.PrivateKey:
+		if stanza.Type != ssh.KeyAlgoED25519 {
+			return nil, fmt.Errorf("stanza type %s does not match identity", stanza.Type)
+		}
+		ephemeralBytes, err := base64.StdEncoding.DecodeString(stanza.Ephemeral)
+		if err != nil {
+			return nil, err
+		}
+		ephemeral, err := ecdh.X25519().NewPublicKey(ephemeralBytes)
+		if err != nil {
+			return nil, err
+		}
+		scalar := sha512.Sum512(private.Seed())
+		identityX, err := ecdh.X25519().NewPrivateKey(scalar[:32])
+		if err != nil {
+			return nil, err
+		}
+		shared, err := identityX.ECDH(ephemeral)
+		if err != nil {
+			return nil, err
+		}
+		wrapKey, err := x25519WrapKey(shared, ephemeral, identityX.PublicKey())
+		if err != nil {
+			return nil, err
+		}
+		if len(wrapped) < nonceSize {
+			return nil, fmt.Errorf("wrapped key too short")
+		}
+		return openAESGCM(wrapKey, wrapped[:nonceSize], wrapped[nonceSize:], nil)
+	case *rsa.PrivateKey:
+		if stanza.Type != ssh.KeyAlgoRSA {
+			return nil, fmt.Errorf("stanza type %s does not match identity", stanza.Type)
+		}
+		return rsa.DecryptOAEP(sha256.New(), nil, private, wrapped, []byte(envelopeLabelRSA))
+	default:
+		return nil, fmt.Errorf("unsupported identity key type %T", identity.key)
+	}
+}
+
+// x25519WrapKey derives the key wrapping one stanza from the X25519 shared
+// secret. The salt binds both public halves so a stanza cannot be replayed
+// against another recipient.
+func x25519WrapKey(shared []byte, ephemeral, recipient *ecdh.PublicKey) ([]byte, error) {
+	salt := append(append([]byte{}, ephemeral.Bytes()...), recipient.Bytes()...)
+	return hkdf.Key(sha256.New, shared, salt, envelopeInfoEd25519, keySize)
+}
+
+// ed25519PublicToX25519 maps an Edwards point to its Montgomery u-coordinate,
+// u = (1 + y) / (1 - y) mod 2^255 - 19, the birational map RFC 7748 names.
+func ed25519PublicToX25519(public ed25519.PublicKey) (*ecdh.PublicKey, error) {
+	if len(public) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid ed25519 public key")
+	}
+	p := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+	le := make([]byte, len(public))
+	for i := range public {
+		le[len(public)-1-i] = public[i]
+	}
+	le[0] &= 0x7f // drop the sign bit of x
+	y := new(big.Int).SetBytes(le)
+	if y.Cmp(p) >= 0 {
+		return nil, fmt.Errorf("invalid ed25519 public key")
+	}
+	denominator := new(big.Int).Sub(big.NewInt(1), y)
+	denominator.Mod(denominator, p)
+	if denominator.Sign() == 0 {
+		return nil, fmt.Errorf("invalid ed25519 public key")
+	}
+	u := new(big.Int).Add(big.NewInt(1), y)
+	u.Mul(u, denominator.ModInverse(denominator, p))
+	u.Mod(u, p)
+	be := u.FillBytes(make([]byte, 32))
+	out := make([]byte, 32)
+	for i := range be {
+		out[31-i] = be[i]
+	}
+	return ecdh.X25519().NewPublicKey(out)
+}
+
+func sealAESGCM(key, plaintext, additionalData []byte) ([]byte, []byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return nonce, gcm.Seal(nil, nonce, plaintext, additionalData), nil
+}
+
+func openAESGCM(key, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce size")
+	}
+	return gcm.Open(nil, nonce, ciphertext, additionalData)
+}
@@ -0,0 +1,252 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func testEnvelopeKeyPair(t *testing.T, rsaBits int) (Recipient, Identity) {
+	t.Helper()
+	var (
+		public  any
+		private any
+	)
+	if rsaBits > 0 {
+		key, err := rsa.GenerateKey(rand.Reader, rsaBits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		public, private = key.Public(), key
+	} else {
+		edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		public, private = edPublic, edPrivate
+	}
+	sshPublic, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient, err := ParseRecipient(string(ssh.MarshalAuthorizedKey(sshPublic)))
+	if err != nil {
+		t.Fatalf("ParseRecipient: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(private, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity, err := ParseIdentity(pem.EncodeToMemory(block), nil)
+	if err != nil {
+		t.Fatalf("ParseIdentity: %v", err)
+	}
+	if identity.Fingerprint != recipient.Fingerprint {
+		t.Fatalf("identity fingerprint %s, recipient %s", identity.Fingerprint, recipient.Fingerprint)
+	}
+	return recipient, identity
+}
+
+// rewriteEnvelope decodes a sealed envelope, lets edit change it, and
+// encodes it again the way SealEnvelope does.
+func rewriteEnvelope(t *testing.T, sealed []byte, edit func(*envelope)) []byte {
+	t.Helper()
+	env, err := decodeEnvelope(sealed)
+	if err != nil {
+		t.Fatalf("decodeEnvelope: %v", err)
+	}
+	edit(env)
+	data, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []byte(EnvelopeHeader + base64.StdEncoding.EncodeToString(data))
+}
+
+func flipBase64Byte(t *testing.T, value string, index int) string {
+	t.Helper()
+	raw, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw[index] ^= 0x01
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+func TestEnvelopeRoundTripsForEd25519AndRSARecipients(t *testing.T) {
+	edRecipient, edIdentity := testEnvelopeKeyPair(t, 0)
+	rsaRecipient, rsaIdentity := testEnvelopeKeyPair(t, 2048)
+	plaintext := []byte("DATABASE_URL=postgres://prod\n")
+
+	sealed, err := SealEnvelope(plaintext, []Recipient{edRecipient, rsaRecipient})
+	if err != nil {
+		t.Fatalf("SealEnvelope: %v", err)
+	}
+	if !IsEnvelope(sealed) || bytes.Contains(sealed, plaintext) {
+		t.Fatalf("sealed envelope = %q", sealed)
+	}
+	for name, identity := range map[string]Identity{"ssh-ed25519": edIdentity, "ssh-rsa": rsaIdentity} {
+		opened, err := OpenEnvelope(sealed, identity)
+		if err != nil {
+			t.Fatalf("%s: OpenEnvelope: %v", name, err)
+		}
+		if !bytes.Equal(opened, plaintext) {
+			t.Fatalf("%s: opened %q, want %q", name, opened, plaintext)
+		}
+	}
+	fingerprints, err := EnvelopeRecipients(sealed)
+	if err != nil {
+		t.Fatalf("EnvelopeRecipients: %v", err)
+	}
+	if len(fingerprints) != 2 || fingerprints[0] != edRecipient.Fingerprint || fingerprints[1] != rsaRecipient.Fingerprint {
+		t.Fatalf("recipients = %v", fingerprints)
+	}
+
+	empty, err := SealEnvelope(nil, []Recipient{rsaRecipient})
+	if err != nil {
+		t.Fatalf("SealEnvelope empty: %v", err)
+	}
+	if opened, err := OpenEnvelope(empty, rsaIdentity); err != nil || len(opened) != 0 {
+		t.Fatalf("empty envelope opened %q, %v", opened, err)
+	}
+	if _, err := SealEnvelope(plaintext, nil); err == nil {
+		t.Fatal("SealEnvelope accepted no recipients")
+	}
+}
+
+func TestParseRecipientRejectsShortRSAKeys(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPublic, err := ssh.NewPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseRecipient(string(ssh.MarshalAuthorizedKey(sshPublic))); err == nil {
+		t.Fatal("ParseRecipient accepted a 1024-bit ssh-rsa key")
+	}
+}
+
+func TestOpenEnvelopeRejectsWrongRecipient(t *testing.T) {
+	edRecipient, _ := testEnvelopeKeyPair(t, 0)
+	rsaRecipient, _ := testEnvelopeKeyPair(t, 2048)
+	_, otherEd := testEnvelopeKeyPair(t, 0)
+	_, otherRSA := testEnvelopeKeyPair(t, 2048)
+	sealed, err := SealEnvelope([]byte("secret"), []Recipient{edRecipient, rsaRecipient})
+	if err != nil {
+		t.Fatalf("SealEnvelope: %v", err)
+	}
+	for name, identity := range map[string]Identity{"ssh-ed25519": otherEd, "ssh-rsa": otherRSA} {
+		if _, err := OpenEnvelope(sealed, identity); !errors.Is(err, ErrNotRecipient) {
+			t.Fatalf("%s: OpenEnvelope error = %v, want ErrNotRecipient", name, err)
+		}
+	}
+
+	// A stanza relabeled with an outsider's fingerprint still wraps the key
+	// for its real recipient, so the outsider cannot unwrap it.
+	for name, identity := range map[string]Identity{"ssh-ed25519": otherEd, "ssh-rsa": otherRSA} {
+		relabeled := rewriteEnvelope(t, sealed, func(env *envelope) {
+			for index := range env.Recipients {
+				env.Recipients[index].Fingerprint = identity.Fingerprint
+			}
+		})
+		if _, err := OpenEnvelope(relabeled, identity); err == nil || errors.Is(err, ErrNotRecipient) {
+			t.Fatalf("%s: relabeled stanza opened with error %v", name, err)
+		}
+	}
+}
+
+func TestOpenEnvelopeRejectsTamperedCiphertext(t *testing.T) {
+	recipient, identity := testEnvelopeKeyPair(t, 0)
+	sealed, err := SealEnvelope([]byte("secret value"), []Recipient{recipient})
+	if err != nil {
+		t.Fatalf("SealEnvelope: %v", err)
+	}
+	for name, edit := range map[string]func(*envelope){
+		"ciphertext": func(env *envelope) { env.Ciphertext = flipBase64Byte(t, env.Ciphertext, 0) },
+		"tag":        func(env *envelope) { env.Ciphertext = flipBase64Byte(t, env.Ciphertext, len("secret value")) },
+		"nonce":      func(env *envelope) { env.Nonce = flipBase64Byte(t, env.Nonce, 0) },
+	} {
+		if opened, err := OpenEnvelope(rewriteEnvelope(t, sealed, edit), identity); err == nil {
+			t.Fatalf("%s: tampered envelope opened as %q", name, opened)
+		}
+	}
+}
+
+func TestOpenEnvelopeRejectsTamperedHeader(t *testing.T) {
+	edRecipient, edIdentity := testEnvelopeKeyPair(t, 0)
+	rsaRecipient, rsaIdentity := testEnvelopeKeyPair(t, 2048)
+	outsider, _ := testEnvelopeKeyPair(t, 0)
+	sealed, err := SealEnvelope([]byte("secret"), []Recipient{edRecipient, rsaRecipient})
+	if err != nil {
+		t.Fatalf("SealEnvelope: %v", err)
+	}
+	extra, err := wrapFileKey(make([]byte, keySize), outsider)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, edit := range map[string]func(*envelope){
+		"ephemeral key": func(env *envelope) { env.Recipients[0].Ephemeral = flipBase64Byte(t, env.Recipients[0].Ephemeral, 0) },
+		"ed25519 key": func(env *envelope) {
+			env.Recipients[0].WrappedKey = flipBase64Byte(t, env.Recipients[0].WrappedKey, nonceSize)
+		},
+		"rsa key": func(env *envelope) { env.Recipients[1].WrappedKey = flipBase64Byte(t, env.Recipients[1].WrappedKey, 0) },
+		"stanza type": func(env *envelope) {
+			env.Recipients[0].Type, env.Recipients[1].Type = ssh.KeyAlgoRSA, ssh.KeyAlgoED25519
+		},
+		"added stanza": func(env *envelope) { env.Recipients = append(env.Recipients, extra) },
+		"dropped stanza": func(env *envelope) {
+			env.Recipients = env.Recipients[:1]
+		},
+		"other stanza": func(env *envelope) { env.Recipients[1].Fingerprint = outsider.Fingerprint },
+	} {
+		tampered := rewriteEnvelope(t, sealed, edit)
+		if opened, err := OpenEnvelope(tampered, edIdentity); err == nil {
+			t.Fatalf("%s: ssh-ed25519 recipient opened tampered envelope as %q", name, opened)
+		}
+		if name == "dropped stanza" || name == "other stanza" {
+			continue
+		}
+		if opened, err := OpenEnvelope(tampered, rsaIdentity); err == nil {
+			t.Fatalf("%s: ssh-rsa recipient opened tampered envelope as %q", name, opened)
+		}
+	}
+
+	if _, err := OpenEnvelope(append([]byte("TAKO_ENVELOPE_V2:"), sealed[len(EnvelopeHeader):]...), edIdentity); err == nil {
+		t.Fatal("opened an envelope with a different header")
+	}
+}
+
+func TestOpenEnvelopeRejectsTruncatedInput(t *testing.T) {
+	recipient, identity := testEnvelopeKeyPair(t, 0)
+	sealed, err := SealEnvelope([]byte("secret value"), []Recipient{recipient})
+	if err != nil {
+		t.Fatalf("SealEnvelope: %v", err)
+	}
+	for _, length := range []int{0, len(EnvelopeHeader) - 1, len(EnvelopeHeader), len(EnvelopeHeader) + 4, len(sealed) / 2, len(sealed) - 4} {
+		if opened, err := OpenEnvelope(sealed[:length], identity); err == nil {
+			t.Fatalf("envelope truncated to %d bytes opened as %q", length, opened)
+		}
+	}
+	for name, edit := range map[string]func(*envelope){
+		"ciphertext":            func(env *envelope) { env.Ciphertext = env.Ciphertext[:len(env.Ciphertext)/2] },
+		"ciphertext to nothing": func(env *envelope) { env.Ciphertext = "" },
+		"nonce":                 func(env *envelope) { env.Nonce = base64.StdEncoding.EncodeToString([]byte("short")) },
+		"wrapped key":           func(env *envelope) { env.Recipients[0].WrappedKey = base64.StdEncoding.EncodeToString([]byte("short")) },
+		"ephemeral":             func(env *envelope) { env.Recipients[0].Ephemeral = base64.StdEncoding.EncodeToString([]byte("short")) },
+	} {
+		if opened, err := OpenEnvelope(rewriteEnvelope(t, sealed, edit), identity); err == nil {
+			t.Fatalf("%s truncated: envelope opened as %q", name, opened)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package deployer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/redentordev/tako-cli/pkg/takoapi/events"
+	"github.com/redentordev/tako-cli/pkg/takod"
+	"github.com/redentordev/tako-cli/pkg/takodclient"
+)
+
+// ApplyAlertRules hands the alerts block to every target node, where takod
+// evaluates it against the node's own samples. Rules scoped to a service
+// this environment does not define are left out. Without rules it clears
+// any earlier spec, skipping nodes too old to have evaluated alerts at all.
+func (d *Deployer) ApplyAlertRules() error {
+	targetServers, err := d.getTakodTargetServers()
+	if err != nil {
+		return fmt.Errorf("failed to get takod target servers: %w", err)
+	}
+	if len(targetServers) == 0 {
+		return nil
+	}
+	rules, err := d.alertRules()
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		return runTakodNodeActions(targetServers, func(serverName string) error {
+			client, err := d.getRuntimeClient(serverName)
+			if err != nil {
+				return err
+			}
+			var capabilityErr *takodclient.CapabilityRequiredError
+			if err := d.ensureTakodCapability(client, serverName, takod.CapabilityAlertsV1, "alert rules"); errors.As(err, &capabilityErr) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			return d.applyNodeAlertRules(client, serverName, nil)
+		})
+	}
+
+	return runTakodJobApplyPhases(targetServers, func() error {
+		if err := d.preflightTakodCapability(targetServers, takod.CapabilityAlertsV1, "alert rules"); err != nil {
+			return fmt.Errorf("alerts requires alert rule support: %w", err)
+		}
+		return nil
+	}, func(serverName string) error {
+		client, err := d.getRuntimeClient(serverName)
+		if err != nil {
+			return err
+		}
+		return d.applyNodeAlertRules(client, serverName, &takod.AlertSpec{Node: serverName, Rules: rules})
+	})
+}
+
+func (d *Deployer) alertRules() ([]takod.AlertRule, error) {
+	if len(d.config.Alerts) == 0 {
+		return nil, nil
+	}
+	services, err := d.config.GetServices(d.environment)
+	if err != nil {
+		return nil, err
+	}
+	var rules []takod.AlertRule
+	for _, name := range d.config.AlertRuleNames() {
+		rule := d.config.Alerts[name]
+		if rule.Service != "" {
+			if _, ok := services[rule.Service]; !ok {
+				continue
+			}
+		}
+		rules = append(rules, takod.AlertRule{
+			Name:            name,
+			Metric:          rule.Metric,
+			Service:         rule.Service,
+			Threshold:       rule.Threshold,
+			DurationSeconds: rule.DurationSeconds(),
+			Severity:        rule.SeverityOrDefault(),
+			Notifications:   jobNotificationTargets(rule.RoutedNotifications(d.config.Notifications)),
+		})
+	}
+	return rules, nil
+}
+
+func (d *Deployer) applyNodeAlertRules(client any, serverName string, spec *takod.AlertSpec) error {
+	output, err := takodclient.RequestJSONWithContext(d.baseContext(), client, d.takodSocket(), "POST", takodclient.AlertsApplyEndpoint(), takod.AlertsApplyRequest{
+		Project:     d.config.Project.Name,
+		Environment: d.environment,
+		Spec:        spec,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply alert rules on %s: %w", serverName, err)
+	}
+	var response takod.AlertsApplyResponse
+	if err := json.Unmarshal([]byte(output), &response); err != nil {
+		return fmt.Errorf("failed to parse alert rules response from %s: %w", serverName, err)
+	}
+	if response.Rules == 0 {
+		return nil
+	}
+	d.emitEvent(events.Event{
+		Type:    events.TypeDeployAlertsApplied,
+		Phase:   events.PhaseDeploy,
+		Level:   events.LevelInfo,
+		Node:    serverName,
+		Message: fmt.Sprintf("  ✓ Alerts on %s: %d rule(s)\n", serverName, response.Rules),
+		Data:    map[string]any{"node": serverName, "rules": response.Rules},
+	})
+	return nil
+}
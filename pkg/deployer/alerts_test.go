@@ -0,0 +1,38 @@
+package deployer
+
+import (
+	"testing"
+
+	"github.com/redentordev/tako-cli/pkg/config"
+)
+
+func TestAlertRulesRouteNotificationsAndSkipOtherServices(t *testing.T) {
+	cfg := &config.Config{
+		Project:       config.ProjectConfig{Name: "demo"},
+		Notifications: &config.NotificationsConfig{Slack: "https://hooks.slack.com/services/T/B/X", Webhook: "https://hooks.example.com/tako"},
+		Alerts: map[string]config.AlertRuleConfig{
+			"web-memory":    {Metric: "memory_percent", Service: "web", Threshold: 90, Duration: "5m", Severity: "critical", Routes: []string{"slack"}},
+			"disk-full":     {Metric: "disk_percent", Threshold: 85},
+			"worker-memory": {Metric: "memory_bytes", Service: "worker", Threshold: 1 << 30},
+		},
+		Environments: map[string]config.EnvironmentConfig{
+			"production": {Services: map[string]config.ServiceConfig{"web": {Image: "ghcr.io/acme/web:v1"}}},
+		},
+	}
+	deploy := NewDeployer(nil, cfg, "production", false)
+
+	rules, err := deploy.alertRules()
+	if err != nil {
+		t.Fatalf("alertRules: %v", err)
+	}
+	if len(rules) != 2 || rules[0].Name != "disk-full" || rules[1].Name != "web-memory" {
+		t.Fatalf("rules = %+v", rules)
+	}
+	disk, memory := rules[0], rules[1]
+	if disk.Severity != config.DefaultAlertSeverity || disk.Notifications == nil || disk.Notifications.Slack == "" || disk.Notifications.Webhook == "" {
+		t.Fatalf("disk rule = %+v", disk)
+	}
+	if memory.DurationSeconds != 300 || memory.Notifications == nil || memory.Notifications.Slack == "" || memory.Notifications.Webhook != "" {
+		t.Fatalf("memory rule = %+v (%+v)", memory, memory.Notifications)
+	}
+}
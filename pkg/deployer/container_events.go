@@ -0,0 +1,66 @@
+package deployer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/redentordev/tako-cli/pkg/takoapi/events"
+	"github.com/redentordev/tako-cli/pkg/takod"
+	"github.com/redentordev/tako-cli/pkg/takodclient"
+)
+
+// ApplyContainerEvents tells every target node where to send OOM and
+// crash-loop notifications for this environment: the notifications block,
+// or nowhere without one. takod records container events either way, so
+// nodes too old to watch them are skipped rather than failing the deploy.
+func (d *Deployer) ApplyContainerEvents() error {
+	targetServers, err := d.getTakodTargetServers()
+	if err != nil {
+		return fmt.Errorf("failed to get takod target servers: %w", err)
+	}
+	if len(targetServers) == 0 {
+		return nil
+	}
+	notifications := jobNotificationTargets(d.config.Notifications)
+	return runTakodNodeActions(targetServers, func(serverName string) error {
+		client, err := d.getRuntimeClient(serverName)
+		if err != nil {
+			return err
+		}
+		var capabilityErr *takodclient.CapabilityRequiredError
+		if err := d.ensureTakodCapability(client, serverName, takod.CapabilityContainerEventsV1, "container events"); errors.As(err, &capabilityErr) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		return d.applyNodeContainerEvents(client, serverName, &takod.ContainerEventsSpec{Node: serverName, Notifications: notifications})
+	})
+}
+
+func (d *Deployer) applyNodeContainerEvents(client any, serverName string, spec *takod.ContainerEventsSpec) error {
+	output, err := takodclient.RequestJSONWithContext(d.baseContext(), client, d.takodSocket(), "POST", takodclient.ContainerEventsApplyEndpoint(), takod.ContainerEventsApplyRequest{
+		Project:     d.config.Project.Name,
+		Environment: d.environment,
+		Spec:        spec,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply container event notifications on %s: %w", serverName, err)
+	}
+	var response takod.ContainerEventsApplyResponse
+	if err := json.Unmarshal([]byte(output), &response); err != nil {
+		return fmt.Errorf("failed to parse container events response from %s: %w", serverName, err)
+	}
+	if !response.Notifying {
+		return nil
+	}
+	d.emitEvent(events.Event{
+		Type:    events.TypeDeployContainerEventsApplied,
+		Phase:   events.PhaseDeploy,
+		Level:   events.LevelInfo,
+		Node:    serverName,
+		Message: fmt.Sprintf("  ✓ Container events on %s: notifying OOM kills and crash loops\n", serverName),
+		Data:    map[string]any{"node": serverName},
+	})
+	return nil
+}
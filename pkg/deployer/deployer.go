@@ -25,6 +25,7 @@ import (
 	"github.com/redentordev/tako-cli/pkg/takoapi/events"
 	"github.com/redentordev/tako-cli/pkg/takod"
 	"github.com/redentordev/tako-cli/pkg/takodclient"
+	"github.com/redentordev/tako-cli/pkg/telemetry"
 )
 
 // streamWriter wraps an io.Writer with a prefix for each line
@@ -147,6 +148,12 @@ func (d *Deployer) baseContext() context.Context {
 	return context.Background()
 }
 
+// traceContext carries only the base context's trace, for proxy requests
+// that have always run detached from the deploy's cancellation and fence.
+func (d *Deployer) traceContext() context.Context {
+	return telemetry.Detach(d.baseContext())
+}
+
 func (d *Deployer) SetCLIVersion(version string) {
 	d.cliVersion = strings.TrimSpace(version)
 }
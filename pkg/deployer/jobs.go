@@ -81,35 +81,58 @@ func (d *Deployer) buildJobSpec(serviceName string, service *config.ServiceConfi
 		}
 		timeoutSeconds = int(parsed / time.Second)
 	}
+	retryBackoffSeconds := 0
+	if strings.TrimSpace(service.RetryBackoff) != "" {
+		parsed, err := time.ParseDuration(service.RetryBackoff)
+		if err != nil {
+			return takod.JobSpec{}, fmt.Errorf("job %s: invalid retryBackoff: %w", serviceName, err)
+		}
+		retryBackoffSeconds = int(parsed / time.Second)
+	}
+	var logMaxBytes int64
+	logRetainDays := 0
+	if service.LogArchive != nil {
+		logMaxBytes = int64(service.LogArchive.MaxSizeMB) << 20
+		logRetainDays = service.LogArchive.Retain
+	}
 	image := d.jobImageFor(serviceName)
 	if image == "" && service.Image != "" {
 		image = service.Image
 	}
 	hash, _ := reconcile.SafeServiceConfigHash(*service)
 	return takod.JobSpec{
-		Name:               serviceName,
-		Schedule:           service.Schedule,
-		Timezone:           service.Timezone,
-		Image:              image,
-		Command:            service.Command.ContainerCommand(),
-		Entrypoint:         service.Entrypoint.Arguments(),
-		Labels:             copyJobLabels(service.Labels),
-		EnvFileContent:     envContent,
-		Network:            runtimeid.NetworkName(d.config.Project.Name, d.environment),
-		Mounts:             mounts,
-		Files:              fileBundles,
-		FileSetID:          fileSetID,
-		MemoryLimit:        serviceMemoryLimit(service),
-		CPULimit:           serviceCPULimit(service),
-		User:               service.User,
-		WorkingDir:         service.WorkingDir,
-		StopTimeoutSeconds: serviceStopTimeoutSeconds(service),
-		Init:               service.Init,
-		ExtraHosts:         append([]string(nil), service.ExtraHosts...),
-		Ulimits:            copyServiceUlimits(service.Ulimits),
-		ShmSize:            service.ShmSize,
-		TimeoutSeconds:     timeoutSeconds,
-		ConfigHash:         hash,
+		Name:                serviceName,
+		Schedule:            service.Schedule,
+		Timezone:            service.Timezone,
+		After:               append([]string(nil), service.After...),
+		OnSuccess:           append([]string(nil), service.OnSuccess...),
+		OnFailure:           append([]string(nil), service.OnFailure...),
+		Image:               image,
+		Command:             service.Command.ContainerCommand(),
+		Entrypoint:          service.Entrypoint.Arguments(),
+		Labels:              copyJobLabels(service.Labels),
+		EnvFileContent:      envContent,
+		Network:             runtimeid.NetworkName(d.config.Project.Name, d.environment),
+		Mounts:              mounts,
+		Files:               fileBundles,
+		FileSetID:           fileSetID,
+		MemoryLimit:         serviceMemoryLimit(service),
+		CPULimit:            serviceCPULimit(service),
+		User:                service.User,
+		WorkingDir:          service.WorkingDir,
+		StopTimeoutSeconds:  serviceStopTimeoutSeconds(service),
+		Init:                service.Init,
+		ExtraHosts:          append([]string(nil), service.ExtraHosts...),
+		Ulimits:             copyServiceUlimits(service.Ulimits),
+		ShmSize:             service.ShmSize,
+		TimeoutSeconds:      timeoutSeconds,
+		Retries:             service.Retries,
+		RetryBackoffSeconds: retryBackoffSeconds,
+		ConcurrencyPolicy:   service.ConcurrencyPolicy,
+		LogMaxBytes:         logMaxBytes,
+		LogRetainDays:       logRetainDays,
+		Notifications:       jobNotificationTargets(d.config.Notifications),
+		ConfigHash:          hash,
 	}, nil
 }
 
@@ -146,6 +169,7 @@ func (d *Deployer) ApplyJobSchedules(services map[string]config.ServiceConfig) e
 	sort.Strings(names)
 
 	jobsByNode := make(map[string][]takod.JobSpec)
+	owners := make(map[string]string, len(names))
 	for _, name := range names {
 		service := services[name]
 		owner, err := d.JobOwnerServer(name, &service)
@@ -156,16 +180,28 @@ func (d *Deployer) ApplyJobSchedules(services map[string]config.ServiceConfig) e
 		if err != nil {
 			return err
 		}
+		owners[name] = owner
 		jobsByNode[owner] = append(jobsByNode[owner], spec)
 	}
+	if err := checkJobWorkflowOwners(services, owners); err != nil {
+		return err
+	}
 
 	var argvServers []string
 	var runtimeControlServers []string
 	var fileServers []string
+	var workflowServers []string
+	var retryServers []string
+	var logArchiveServers []string
+	var notificationServers []string
 	for _, serverName := range targetServers {
 		needsArgv := false
 		needsRuntimeControls := false
 		needsFiles := false
+		needsWorkflows := false
+		needsRetries := false
+		needsLogArchive := false
+		needsNotifications := false
 		for _, job := range jobsByNode[serverName] {
 			if len(job.Entrypoint) > 0 {
 				needsArgv = true
@@ -176,6 +212,18 @@ func (d *Deployer) ApplyJobSchedules(services map[string]config.ServiceConfig) e
 			if len(job.Files) > 0 {
 				needsFiles = true
 			}
+			if job.IsDependent() {
+				needsWorkflows = true
+			}
+			if jobSpecNeedsRetryPolicy(job) {
+				needsRetries = true
+			}
+			if job.LogMaxBytes > 0 || job.LogRetainDays > 0 {
+				needsLogArchive = true
+			}
+			if job.Notifications != nil {
+				needsNotifications = true
+			}
 		}
 		if needsArgv {
 			argvServers = append(argvServers, serverName)
@@ -186,6 +234,18 @@ func (d *Deployer) ApplyJobSchedules(services map[string]config.ServiceConfig) e
 		if needsFiles {
 			fileServers = append(fileServers, serverName)
 		}
+		if needsWorkflows {
+			workflowServers = append(workflowServers, serverName)
+		}
+		if needsRetries {
+			retryServers = append(retryServers, serverName)
+		}
+		if needsLogArchive {
+			logArchiveServers = append(logArchiveServers, serverName)
+		}
+		if needsNotifications {
+			notificationServers = append(notificationServers, serverName)
+		}
 	}
 	return runTakodJobApplyPhases(targetServers, func() error {
 		if err := d.preflightTakodCapability(argvServers, takod.CapabilityContainerArgvV1, "container argv payloads"); err != nil {
@@ -197,6 +257,18 @@ func (d *Deployer) ApplyJobSchedules(services map[string]config.ServiceConfig) e
 		if err := d.preflightTakodCapability(fileServers, takod.CapabilityServiceFilesV1, "operator file distribution"); err != nil {
 			return fmt.Errorf("job files require operator file support: %w", err)
 		}
+		if err := d.preflightTakodCapability(workflowServers, takod.CapabilityJobWorkflowsV1, "job workflows"); err != nil {
+			return fmt.Errorf("job dependencies require job workflow support: %w", err)
+		}
+		if err := d.preflightTakodCapability(retryServers, takod.CapabilityJobRetriesV1, "job retries and concurrency policies"); err != nil {
+			return fmt.Errorf("job retries and concurrency policies require job retry support: %w", err)
+		}
+		if err := d.preflightTakodCapability(logArchiveServers, takod.CapabilityJobLogsV1, "job log archives"); err != nil {
+			return fmt.Errorf("job logArchive requires job log archive support: %w", err)
+		}
+		if err := d.preflightTakodCapability(notificationServers, takod.CapabilityJobNotificationsV1, "job alerts"); err != nil {
+			return fmt.Errorf("job alerts for the project's notifications require job notification support: %w", err)
+		}
 		return nil
 	}, func(serverName string) error {
 		client, err := d.getRuntimeClient(serverName)
@@ -253,6 +325,28 @@ func (d *Deployer) ApplyJobSchedules(services map[string]config.ServiceConfig) e
 	})
 }
 
+// checkJobWorkflowOwners requires every dependent job to share its owning
+// node with its upstream jobs: a workflow run executes on a single node.
+func checkJobWorkflowOwners(services map[string]config.ServiceConfig, owners map[string]string) error {
+	var names []string
+	for name := range owners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, upstream := range services[name].JobUpstreams() {
+			upstreamOwner, ok := owners[upstream]
+			if !ok {
+				return fmt.Errorf("job %s depends on %s, which is not a job in this environment", name, upstream)
+			}
+			if upstreamOwner != owners[name] {
+				return fmt.Errorf("job %s runs on %s but its upstream job %s runs on %s; pin them to the same server with placement", name, owners[name], upstream, upstreamOwner)
+			}
+		}
+	}
+	return nil
+}
+
 func runTakodJobApplyPhases(targetServers []string, preflight func() error, apply func(string) error) error {
 	if err := preflight(); err != nil {
 		return err
@@ -260,6 +354,23 @@ func runTakodJobApplyPhases(targetServers []string, preflight func() error, appl
 	return runTakodNodeActions(targetServers, apply)
 }
 
+// jobNotificationTargets hands the project's notification webhooks to the
+// node owning each job, which alerts on failures and missed schedules.
+func jobNotificationTargets(notifications *config.NotificationsConfig) *takod.JobNotifications {
+	if notifications == nil || (notifications.Slack == "" && notifications.Discord == "" && notifications.Webhook == "") {
+		return nil
+	}
+	return &takod.JobNotifications{
+		Slack:   notifications.Slack,
+		Discord: notifications.Discord,
+		Webhook: notifications.Webhook,
+	}
+}
+
+func jobSpecNeedsRetryPolicy(spec takod.JobSpec) bool {
+	return spec.Retries > 0 || (spec.ConcurrencyPolicy != "" && spec.ConcurrencyPolicy != takod.JobConcurrencyForbid)
+}
+
 func jobSpecNeedsRuntimeControls(spec takod.JobSpec) bool {
 	return spec.User != "" || spec.WorkingDir != "" || spec.StopTimeoutSeconds > 0 || spec.Init || len(spec.ExtraHosts) > 0 || len(spec.Ulimits) > 0 || spec.ShmSize != ""
 }
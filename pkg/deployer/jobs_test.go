@@ -212,3 +212,84 @@ func TestJobOwnerServerIsDeterministic(t *testing.T) {
 		}
 	}
 }
+
+func TestBuildJobSpecCarriesDependencyEdges(t *testing.T) {
+	d, service := jobDeployerFixture()
+	service.Schedule = ""
+	service.Timezone = ""
+	service.After = []string{"export"}
+	service.OnFailure = []string{"transform"}
+
+	spec, err := d.buildJobSpec("cleanup", service)
+	if err != nil {
+		t.Fatalf("buildJobSpec: %v", err)
+	}
+	if !spec.IsDependent() || strings.Join(spec.After, ",") != "export" || strings.Join(spec.OnFailure, ",") != "transform" || spec.Schedule != "" {
+		t.Fatalf("spec = %+v", spec)
+	}
+}
+
+func TestCheckJobWorkflowOwnersRequiresSharedNode(t *testing.T) {
+	services := map[string]config.ServiceConfig{
+		"export":    {Kind: config.ServiceKindJob, Schedule: "@daily"},
+		"transform": {Kind: config.ServiceKindJob, OnSuccess: []string{"export"}},
+	}
+	if err := checkJobWorkflowOwners(services, map[string]string{"export": "node-a", "transform": "node-a"}); err != nil {
+		t.Fatalf("same-node workflow rejected: %v", err)
+	}
+	err := checkJobWorkflowOwners(services, map[string]string{"export": "node-a", "transform": "node-b"})
+	if err == nil || !strings.Contains(err.Error(), "pin them to the same server") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestBuildJobSpecCarriesRetryPolicy(t *testing.T) {
+	d, service := jobDeployerFixture()
+	service.Retries = 3
+	service.RetryBackoff = "2m"
+	service.ConcurrencyPolicy = config.JobConcurrencyAllow
+
+	spec, err := d.buildJobSpec("report", service)
+	if err != nil {
+		t.Fatalf("buildJobSpec: %v", err)
+	}
+	if spec.Retries != 3 || spec.RetryBackoffSeconds != 120 || spec.ConcurrencyPolicy != takod.JobConcurrencyAllow {
+		t.Fatalf("spec = %+v", spec)
+	}
+	if !jobSpecNeedsRetryPolicy(spec) || jobSpecNeedsRetryPolicy(takod.JobSpec{ConcurrencyPolicy: takod.JobConcurrencyForbid}) {
+		t.Fatal("retry capability requirement misreported")
+	}
+}
+
+func TestBuildJobSpecCarriesLogArchiveBounds(t *testing.T) {
+	d, service := jobDeployerFixture()
+	service.LogArchive = &config.JobLogArchiveConfig{MaxSizeMB: 128, Retain: 30}
+
+	spec, err := d.buildJobSpec("report", service)
+	if err != nil {
+		t.Fatalf("buildJobSpec: %v", err)
+	}
+	if spec.LogMaxBytes != 128<<20 || spec.LogRetainDays != 30 {
+		t.Fatalf("spec = %+v", spec)
+	}
+}
+
+func TestBuildJobSpecCarriesProjectNotifications(t *testing.T) {
+	d, service := jobDeployerFixture()
+	spec, err := d.buildJobSpec("report", service)
+	if err != nil {
+		t.Fatalf("buildJobSpec: %v", err)
+	}
+	if spec.Notifications != nil {
+		t.Fatalf("notifications without project config = %+v", spec.Notifications)
+	}
+
+	d.config.Notifications = &config.NotificationsConfig{Slack: "https://hooks.slack.com/services/T/B/x"}
+	spec, err = d.buildJobSpec("report", service)
+	if err != nil {
+		t.Fatalf("buildJobSpec: %v", err)
+	}
+	if spec.Notifications == nil || spec.Notifications.Slack != "https://hooks.slack.com/services/T/B/x" {
+		t.Fatalf("notifications = %+v", spec.Notifications)
+	}
+}
@@ -0,0 +1,90 @@
+package deployer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/redentordev/tako-cli/pkg/takoapi/events"
+	"github.com/redentordev/tako-cli/pkg/takod"
+	"github.com/redentordev/tako-cli/pkg/takodclient"
+)
+
+// ApplyLogShipping hands the environment's logging block to every target
+// node, each of which ships its own containers' logs (and, on proxy nodes,
+// the routes' access log lines). Without a logging block it clears any
+// earlier spec, skipping nodes too old to have shipped logs at all.
+func (d *Deployer) ApplyLogShipping() error {
+	targetServers, err := d.getTakodTargetServers()
+	if err != nil {
+		return fmt.Errorf("failed to get takod target servers: %w", err)
+	}
+	if len(targetServers) == 0 {
+		return nil
+	}
+	logging := d.config.Logging
+	if logging == nil {
+		return runTakodNodeActions(targetServers, func(serverName string) error {
+			client, err := d.getRuntimeClient(serverName)
+			if err != nil {
+				return err
+			}
+			var capabilityErr *takodclient.CapabilityRequiredError
+			if err := d.ensureTakodCapability(client, serverName, takod.CapabilityLogShippingV1, "log shipping"); errors.As(err, &capabilityErr) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			return d.applyNodeLogShipping(client, serverName, nil)
+		})
+	}
+
+	return runTakodJobApplyPhases(targetServers, func() error {
+		if err := d.preflightTakodCapability(targetServers, takod.CapabilityLogShippingV1, "log shipping"); err != nil {
+			return fmt.Errorf("logging requires log shipping support: %w", err)
+		}
+		return nil
+	}, func(serverName string) error {
+		client, err := d.getRuntimeClient(serverName)
+		if err != nil {
+			return err
+		}
+		spec := &takod.LoggingSpec{
+			AccessLogs: logging.AccessLogs,
+			BufferSize: logging.BufferSize,
+			Node:       serverName,
+		}
+		for _, sink := range logging.Sinks {
+			spec.Sinks = append(spec.Sinks, sink.SinkConfig())
+		}
+		return d.applyNodeLogShipping(client, serverName, spec)
+	})
+}
+
+func (d *Deployer) applyNodeLogShipping(client any, serverName string, spec *takod.LoggingSpec) error {
+	output, err := takodclient.RequestJSONWithContext(d.baseContext(), client, d.takodSocket(), "POST", takodclient.LoggingApplyEndpoint(), takod.LoggingApplyRequest{
+		Project:     d.config.Project.Name,
+		Environment: d.environment,
+		Spec:        spec,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply log shipping on %s: %w", serverName, err)
+	}
+	var response takod.LoggingApplyResponse
+	if err := json.Unmarshal([]byte(output), &response); err != nil {
+		return fmt.Errorf("failed to parse log shipping response from %s: %w", serverName, err)
+	}
+	if !response.Shipping {
+		return nil
+	}
+	d.emitEvent(events.Event{
+		Type:    events.TypeDeployLoggingApplied,
+		Phase:   events.PhaseDeploy,
+		Level:   events.LevelInfo,
+		Node:    serverName,
+		Message: fmt.Sprintf("  ✓ Log shipping on %s: %s\n", serverName, strings.Join(response.Sinks, ", ")),
+		Data:    map[string]any{"node": serverName, "sinks": response.Sinks},
+	})
+	return nil
+}
@@ -0,0 +1,58 @@
+package deployer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/redentordev/tako-cli/pkg/config"
+	"github.com/redentordev/tako-cli/pkg/takod"
+)
+
+type recordingTakodExecutor struct {
+	output string
+	inputs *[]string
+}
+
+func (f recordingTakodExecutor) ExecuteWithContext(ctx context.Context, cmd string) (string, error) {
+	return f.output, nil
+}
+
+func (f recordingTakodExecutor) ExecuteWithInput(ctx context.Context, cmd string, input io.Reader) (string, error) {
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return "", err
+	}
+	*f.inputs = append(*f.inputs, string(data))
+	return f.output, nil
+}
+
+func TestApplyNodeLogShippingSendsSpecAndEmitsSinks(t *testing.T) {
+	deploy := NewDeployer(nil, &config.Config{Project: config.ProjectConfig{Name: "demo"}}, "production", false)
+	var inputs []string
+	client := recordingTakodExecutor{output: `{"project":"demo","environment":"production","shipping":true,"sinks":["loki"]}`, inputs: &inputs}
+	logging := &config.LoggingConfig{AccessLogs: true, Sinks: []config.LogSinkConfig{{Type: "loki", URL: "https://loki.example.com/loki/api/v1/push", Labels: map[string]string{"team": "payments"}}}}
+	spec := &takod.LoggingSpec{AccessLogs: logging.AccessLogs, Node: "node-a"}
+	for _, sink := range logging.Sinks {
+		spec.Sinks = append(spec.Sinks, sink.SinkConfig())
+	}
+
+	if err := deploy.applyNodeLogShipping(client, "node-a", spec); err != nil {
+		t.Fatalf("applyNodeLogShipping returned error: %v", err)
+	}
+	if len(inputs) != 1 {
+		t.Fatalf("requests = %v", inputs)
+	}
+	var request takod.LoggingApplyRequest
+	if err := json.NewDecoder(strings.NewReader(inputs[0])).Decode(&request); err != nil {
+		t.Fatalf("decode request %q: %v", inputs[0], err)
+	}
+	if request.Project != "demo" || request.Environment != "production" || request.Spec == nil {
+		t.Fatalf("request = %+v", request)
+	}
+	if request.Spec.Node != "node-a" || !request.Spec.AccessLogs || request.Spec.Sinks[0].Labels["team"] != "payments" {
+		t.Fatalf("spec = %+v", request.Spec)
+	}
+}
@@ -0,0 +1,93 @@
+package deployer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/redentordev/tako-cli/pkg/takoapi/events"
+	"github.com/redentordev/tako-cli/pkg/takod"
+	"github.com/redentordev/tako-cli/pkg/takodclient"
+)
+
+// ApplyMetricsExporter hands the environment's metrics block to every target
+// node, each of which serves a Prometheus scrape target on its mesh address.
+// Nodes receive only the token's digest. Without a metrics block it clears
+// any earlier spec, skipping nodes too old to have served metrics at all.
+func (d *Deployer) ApplyMetricsExporter() error {
+	targetServers, err := d.getTakodTargetServers()
+	if err != nil {
+		return fmt.Errorf("failed to get takod target servers: %w", err)
+	}
+	if len(targetServers) == 0 {
+		return nil
+	}
+	metrics := d.config.Metrics
+	if metrics == nil {
+		return runTakodNodeActions(targetServers, func(serverName string) error {
+			client, err := d.getRuntimeClient(serverName)
+			if err != nil {
+				return err
+			}
+			var capabilityErr *takodclient.CapabilityRequiredError
+			if err := d.ensureTakodCapability(client, serverName, takod.CapabilityMetricsExporterV1, "metrics exporter"); errors.As(err, &capabilityErr) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			return d.applyNodeMetricsExporter(client, serverName, nil)
+		})
+	}
+
+	digest := sha256.Sum256([]byte(metrics.Token))
+	return runTakodJobApplyPhases(targetServers, func() error {
+		if err := d.preflightTakodCapability(targetServers, takod.CapabilityMetricsExporterV1, "metrics exporter"); err != nil {
+			return fmt.Errorf("metrics requires metrics exporter support: %w", err)
+		}
+		return nil
+	}, func(serverName string) error {
+		client, err := d.getRuntimeClient(serverName)
+		if err != nil {
+			return err
+		}
+		address, err := d.meshHostIPForServer(serverName)
+		if err != nil {
+			return err
+		}
+		return d.applyNodeMetricsExporter(client, serverName, &takod.MetricsExporterSpec{
+			Address:     address,
+			Port:        metrics.ListenPort(),
+			TokenSHA256: hex.EncodeToString(digest[:]),
+			Node:        serverName,
+		})
+	})
+}
+
+func (d *Deployer) applyNodeMetricsExporter(client any, serverName string, spec *takod.MetricsExporterSpec) error {
+	output, err := takodclient.RequestJSONWithContext(d.baseContext(), client, d.takodSocket(), "POST", takodclient.MetricsExporterApplyEndpoint(), takod.MetricsExporterApplyRequest{
+		Project:     d.config.Project.Name,
+		Environment: d.environment,
+		Spec:        spec,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply metrics exporter on %s: %w", serverName, err)
+	}
+	var response takod.MetricsExporterApplyResponse
+	if err := json.Unmarshal([]byte(output), &response); err != nil {
+		return fmt.Errorf("failed to parse metrics exporter response from %s: %w", serverName, err)
+	}
+	if !response.Serving {
+		return nil
+	}
+	d.emitEvent(events.Event{
+		Type:    events.TypeDeployMetricsApplied,
+		Phase:   events.PhaseDeploy,
+		Level:   events.LevelInfo,
+		Node:    serverName,
+		Message: fmt.Sprintf("  ✓ Metrics on %s: http://%s/metrics\n", serverName, response.Listen),
+		Data:    map[string]any{"node": serverName, "listen": response.Listen},
+	})
+	return nil
+}
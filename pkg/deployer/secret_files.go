@@ -0,0 +1,71 @@
+package deployer
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/redentordev/tako-cli/pkg/config"
+	"github.com/redentordev/tako-cli/pkg/secrets"
+	"github.com/redentordev/tako-cli/pkg/takod"
+)
+
+// SecretFilesPayload builds the request-scoped secret files takod writes
+// under /run/secrets for a files-delivery service. values maps file names to
+// their contents, as returned by secrets.Manager.SecretFiles.
+func SecretFilesPayload(serviceName string, service *config.ServiceConfig, values map[string]string) (*takod.SecretFilesSpec, error) {
+	owner := ""
+	if service.SecretsDelivery != nil {
+		owner = service.SecretsDelivery.Owner
+	}
+	if owner == "" {
+		if _, _, configured, err := config.ParseServiceFileOwner(service.User); err == nil && configured {
+			owner = service.User
+		}
+	}
+	uid, gid, configured, err := config.ParseServiceFileOwner(owner)
+	if err != nil {
+		return nil, fmt.Errorf("service %s secretsDelivery.owner: %w", serviceName, err)
+	}
+	spec := &takod.SecretFilesSpec{Mode: 0444, Files: make([]takod.SecretFile, 0, len(values))}
+	if configured {
+		spec.UID, spec.GID, spec.Mode = uid, gid, 0400
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		spec.Files = append(spec.Files, takod.SecretFile{Name: name, Data: []byte(values[name])})
+	}
+	return spec, nil
+}
+
+// SecretReloadPayload returns how takod should notify a service's replicas
+// after a rotation, or nil when none is configured.
+func SecretReloadPayload(service *config.ServiceConfig) *takod.SecretReloadSpec {
+	if !service.SecretFilesDelivery() || service.SecretsDelivery.Reload == nil {
+		return nil
+	}
+	reload := service.SecretsDelivery.Reload
+	spec := &takod.SecretReloadSpec{Signal: reload.Signal, Path: reload.Path, Port: reload.Port}
+	if spec.Path != "" && spec.Port == 0 {
+		spec.Port = service.Port
+	}
+	return spec
+}
+
+func (d *Deployer) buildTakodSecretFiles(serviceName string, service *config.ServiceConfig) (*takod.SecretFilesSpec, error) {
+	secretsMgr, err := secrets.NewManagerForConfig(d.config, d.environment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create secrets manager: %w", err)
+	}
+	values, err := secretsMgr.SecretFiles(service)
+	if err != nil {
+		return nil, err
+	}
+	if d.verbose {
+		d.printf("  ✓ %d secret file(s) for %s\n", len(values), config.SecretFilesTarget)
+	}
+	return SecretFilesPayload(serviceName, service, values)
+}
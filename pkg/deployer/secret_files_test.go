@@ -0,0 +1,43 @@
+package deployer
+
+import (
+	"testing"
+
+	"github.com/redentordev/tako-cli/pkg/config"
+	"github.com/redentordev/tako-cli/pkg/takod"
+)
+
+func TestSecretFilesPayloadOwnershipAndOrder(t *testing.T) {
+	values := map[string]string{"STRIPE": "sk", "DATABASE_URL": "postgres://db"}
+	service := &config.ServiceConfig{User: "1000:1001", Secrets: []string{"DATABASE_URL", "STRIPE:STRIPE_SECRET_KEY"}, SecretsDelivery: &config.SecretsDeliveryConfig{Mode: config.SecretsDeliveryFiles}}
+	spec, err := SecretFilesPayload("api", service, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.UID != 1000 || spec.GID != 1001 || spec.Mode != 0400 {
+		t.Fatalf("ownership = %d:%d %#o", spec.UID, spec.GID, spec.Mode)
+	}
+	if len(spec.Files) != 2 || spec.Files[0].Name != "DATABASE_URL" || string(spec.Files[1].Data) != "sk" {
+		t.Fatalf("files = %+v", spec.Files)
+	}
+
+	service.User = "app"
+	if spec, err = SecretFilesPayload("api", service, values); err != nil || spec.Mode != 0444 || spec.UID != 0 {
+		t.Fatalf("named user payload = %+v, %v", spec, err)
+	}
+	service.SecretsDelivery.Owner = "2000"
+	if spec, err = SecretFilesPayload("api", service, values); err != nil || spec.Mode != 0400 || spec.UID != 2000 {
+		t.Fatalf("owner payload = %+v, %v", spec, err)
+	}
+}
+
+func TestSecretReloadPayloadDefaultsToServicePort(t *testing.T) {
+	service := &config.ServiceConfig{Port: 8080, SecretsDelivery: &config.SecretsDeliveryConfig{Mode: config.SecretsDeliveryFiles, Reload: &config.SecretReloadConfig{Path: "/-/reload"}}}
+	if got := SecretReloadPayload(service); got == nil || *got != (takod.SecretReloadSpec{Path: "/-/reload", Port: 8080}) {
+		t.Fatalf("reload = %+v", got)
+	}
+	service.SecretsDelivery.Mode = config.SecretsDeliveryEnv
+	if got := SecretReloadPayload(service); got != nil {
+		t.Fatalf("env delivery reload = %+v", got)
+	}
+}
@@ -430,6 +430,16 @@ func (d *Deployer) deployServiceTakod(serviceName string, service *config.Servic
 					return fmt.Errorf("service %s uses container runtime controls: %w", serviceName, err)
 				}
 			}
+			if service.Deploy.Strategy == config.DeployStrategyCanary {
+				if err := d.preflightTakodCapability(targetServers, takod.CapabilityDeployCanaryV1, "canary deploys"); err != nil {
+					return fmt.Errorf("service %s uses deploy.strategy=canary: %w", serviceName, err)
+				}
+			}
+			if service.SecretFilesDelivery() {
+				if err := d.preflightTakodCapability(targetServers, takod.CapabilityServiceSecretFilesV1, "secret files delivery"); err != nil {
+					return fmt.Errorf("service %s uses secretsDelivery.mode=files: %w", serviceName, err)
+				}
+			}
 			return nil
 		},
 		Build: func() error {
@@ -491,7 +501,7 @@ func serviceNeedsRuntimeControlsCapability(service *config.ServiceConfig) bool {
 }
 
 func serviceNeedsTakodCapabilityPreflight(service *config.ServiceConfig) bool {
-	return serviceNeedsContainerArgvCapability(service) || serviceNeedsRuntimeControlsCapability(service) || (service != nil && len(service.Files) > 0)
+	return serviceNeedsContainerArgvCapability(service) || serviceNeedsRuntimeControlsCapability(service) || (service != nil && (len(service.Files) > 0 || service.Deploy.Strategy == config.DeployStrategyCanary || service.SecretFilesDelivery()))
 }
 
 func (d *Deployer) preflightTakodContainerArgv(serverNames []string) error {
@@ -1392,7 +1402,19 @@ func (d *Deployer) deployServiceToTakodNode(client any, serverName string, servi
 		return err
 	}
 
-	envFileContent, err := d.buildTakodEnvFileContent(service)
+	envService := service
+	var secretFiles *takod.SecretFilesSpec
+	if service.SecretFilesDelivery() && len(slots) > 0 {
+		// Files delivery keeps secrets out of the container environment.
+		withoutSecrets := *service
+		withoutSecrets.Secrets = nil
+		envService = &withoutSecrets
+		secretFiles, err = d.buildTakodSecretFiles(serviceName, service)
+		if err != nil {
+			return err
+		}
+	}
+	envFileContent, err := d.buildTakodEnvFileContent(envService)
 	if err != nil {
 		return err
 	}
@@ -1446,6 +1468,7 @@ func (d *Deployer) deployServiceToTakodNode(client any, serverName string, servi
 		ExtraHosts:         append([]string(nil), service.ExtraHosts...),
 		Ulimits:            copyServiceUlimits(service.Ulimits),
 		ShmSize:            service.ShmSize,
+		SecretFiles:        secretFiles,
 	}
 	if pullImage {
 		request.RegistryAuths = d.registryAuths()
@@ -1476,7 +1499,7 @@ func (d *Deployer) deployServiceToTakodNode(client any, serverName string, servi
 	if warmOnly {
 		return nil
 	}
-	return d.reconcileBackupScheduleViaTakod(client, serviceName, service, len(slots) > 0)
+	return d.reconcileBackupScheduleViaTakod(client, serverName, serviceName, service, len(slots) > 0)
 }
 
 func (d *Deployer) buildTakodNetworkAttachments(serviceName string, service *config.ServiceConfig) []takod.NetworkAttachmentSpec {
@@ -1569,7 +1592,7 @@ func effectiveDeployStrategy(service *config.ServiceConfig) string {
 }
 
 func deployStrategyUsesRevisionScopedContainers(strategy string) bool {
-	return strategy == config.DeployStrategyRolling || strategy == config.DeployStrategyBlueGreen
+	return strategy == config.DeployStrategyRolling || strategy == config.DeployStrategyBlueGreen || strategy == config.DeployStrategyCanary
 }
 
 func (d *Deployer) shouldPublishMeshUpstreams() (bool, error) {
@@ -1775,7 +1798,7 @@ func (d *Deployer) buildTakodMountSpecs(serviceName string, service *config.Serv
 	return mounts, externalVolumes, nil
 }
 
-func (d *Deployer) reconcileBackupScheduleViaTakod(client any, serviceName string, service *config.ServiceConfig, serviceAssignedToNode bool) error {
+func (d *Deployer) reconcileBackupScheduleViaTakod(client any, serverName string, serviceName string, service *config.ServiceConfig, serviceAssignedToNode bool) error {
 	if service.Backup == nil || !serviceAssignedToNode {
 		return d.deleteBackupScheduleViaTakod(client, serviceName)
 	}
@@ -1783,6 +1806,36 @@ func (d *Deployer) reconcileBackupScheduleViaTakod(client any, serviceName strin
 	if err != nil {
 		return err
 	}
+	if request.Mode != "" || request.PreBackup != "" || request.PostBackup != "" {
+		if err := d.ensureTakodCapability(client, serverName, takod.CapabilityBackupConsistentV1, "application-consistent backups (backup.mode, preBackup, postBackup)"); err != nil {
+			return err
+		}
+	}
+	if request.Storage != nil && request.Storage.Format == takod.BackupStorageFormatChunked {
+		if err := d.ensureTakodCapability(client, serverName, takod.CapabilityBackupChunkedV1, "encrypted chunked backups (backup.storage.format: chunked)"); err != nil {
+			return err
+		}
+	}
+	if request.Storage != nil && isBackupTargetProvider(request.Storage.Provider) {
+		if err := d.ensureTakodCapability(client, serverName, takod.CapabilityBackupTargetsV1, "sftp, filesystem, and peer backup storage (backup.storage.provider)"); err != nil {
+			return err
+		}
+	}
+	if request.Retention != nil {
+		if err := d.ensureTakodCapability(client, serverName, takod.CapabilityBackupRetentionV1, "bucketed backup retention (backup.retain: {hourly, daily, weekly, monthly, yearly})"); err != nil {
+			return err
+		}
+	}
+	if request.Verify != nil {
+		if err := d.ensureTakodCapability(client, serverName, takod.CapabilityBackupVerifyV1, "backup restore drills (backup.verify)"); err != nil {
+			return err
+		}
+	}
+	if request.PITR || request.Mode == takod.BackupModePgBaseBackup {
+		if err := d.ensureTakodCapability(client, serverName, takod.CapabilityBackupPITRV1, "point-in-time recovery (backup.pitr)"); err != nil {
+			return err
+		}
+	}
 	if _, err := takodclient.RequestJSON(client, d.takodSocket(), "PUT", "/v1/backup-schedule", request); err != nil {
 		return fmt.Errorf("takod backup schedule reconciliation failed: %w", err)
 	}
@@ -1816,8 +1869,29 @@ func (d *Deployer) buildTakodBackupScheduleRequest(serviceName string, service *
 		Environment:   d.environment,
 		Service:       serviceName,
 		Schedule:      service.Backup.Schedule,
-		RetentionDays: service.Backup.Retain,
+		RetentionDays: service.Backup.Retain.Days,
+		Retention:     takodBackupRetentionPolicy(service.Backup.Retain),
 		Storage:       takodBackupStorageConfig(service.Backup.Storage),
+		Mode:          service.Backup.Mode,
+		Database:      service.Backup.Database,
+		PreBackup:     service.Backup.PreBackup,
+		PostBackup:    service.Backup.PostBackup,
+		PITR:          service.Backup.PITR,
+	}
+	if verify := service.Backup.Verify; verify != nil {
+		request.Verify = &takod.BackupVerifySchedule{
+			Schedule: verify.Schedule,
+			Boot:     verify.Boot || verify.Check != "",
+			Check:    verify.Check,
+		}
+		if verify.Timeout != "" {
+			timeout, err := time.ParseDuration(verify.Timeout)
+			if err != nil {
+				return takod.BackupScheduleRequest{}, fmt.Errorf("service %s backup verify timeout: %w", serviceName, err)
+			}
+			request.Verify.TimeoutSeconds = int(timeout / time.Second)
+		}
+		request.Notifications = jobNotificationTargets(d.config.Notifications)
 	}
 	for _, volume := range volumes {
 		request.Volumes = append(request.Volumes, takod.BackupScheduleVolume{
@@ -1858,6 +1932,29 @@ func serviceBackupVolumeSources(service *config.ServiceConfig) []string {
 	return volumes
 }
 
+func takodBackupRetentionPolicy(retain config.BackupRetention) *takod.BackupRetentionPolicy {
+	if !retain.IsGFS() {
+		return nil
+	}
+	return &takod.BackupRetentionPolicy{
+		Hourly:  retain.Hourly,
+		Daily:   retain.Daily,
+		Weekly:  retain.Weekly,
+		Monthly: retain.Monthly,
+		Yearly:  retain.Yearly,
+	}
+}
+
+// isBackupTargetProvider reports whether provider needs a takod that speaks
+// CapabilityBackupTargetsV1 rather than the original S3 family.
+func isBackupTargetProvider(provider string) bool {
+	switch provider {
+	case config.BackupStorageProviderSFTP, config.BackupStorageProviderFilesystem, config.BackupStorageProviderPeer:
+		return true
+	}
+	return false
+}
+
 func takodBackupStorageConfig(storage *config.BackupStorageConfig) *takod.BackupStorageConfig {
 	if storage == nil {
 		return nil
@@ -1872,16 +1969,26 @@ func takodBackupStorageConfig(storage *config.BackupStorageConfig) *takod.Backup
 		SecretAccessKey: storage.SecretAccessKey,
 		SessionToken:    storage.SessionToken,
 		ForcePathStyle:  storage.ForcePathStyle,
+		Format:          storage.Format,
+		EncryptionKey:   storage.EncryptionKey,
+		Path:            storage.Path,
+		Host:            storage.Host,
+		Port:            storage.Port,
+		User:            storage.User,
+		Password:        storage.Password,
+		PrivateKey:      storage.PrivateKey,
+		HostKey:         storage.HostKey,
+		Node:            storage.Node,
 	}
 }
 
-func (d *Deployer) ensureTakodProxy(client any, networkName string, email string) error {
+func (d *Deployer) ensureTakodProxy(client any, networkName string, email string, rateLimit bool) error {
 	if email == "" {
 		email = "tako@redentor.dev"
 	}
-	_, err := takodclient.RequestJSON(client, d.takodSocket(), "POST", "/v1/proxy", takod.ReconcileProxyRequest{
+	_, err := takodclient.RequestJSONWithContext(d.traceContext(), client, d.takodSocket(), "POST", "/v1/proxy", takod.ReconcileProxyRequest{
 		Project: d.config.Project.Name, Environment: d.environment,
-		Network: networkName, Email: email,
+		Network: networkName, Email: email, RateLimit: rateLimit,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to reconcile takod proxy: %w", err)
@@ -1900,7 +2007,7 @@ func (d *Deployer) buildTakodEnvFileContentAndHash(service *config.ServiceConfig
 		return "", runInputValuesHash(nil), nil
 	}
 
-	secretsMgr, err := secrets.NewManager(d.environment)
+	secretsMgr, err := secrets.NewManagerForConfig(d.config, d.environment)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to create secrets manager: %w", err)
 	}
@@ -662,6 +662,9 @@ func TestRunStepServerRequiresLocalBuildImageOnTargetedNode(t *testing.T) {
 }
 
 func TestRunInputHashChangesWithResolvedEnvironment(t *testing.T) {
+	// Resolving the environment opens the secrets store under .tako in
+	// the working directory.
+	t.Chdir(t.TempDir())
 	deploy := &Deployer{environment: "production"}
 	first := &config.ServiceConfig{Env: map[string]string{"TOKEN": "first"}}
 	second := &config.ServiceConfig{Env: map[string]string{"TOKEN": "second"}}
@@ -1711,7 +1714,7 @@ func TestBuildTakodBackupScheduleRequestUsesConfiguredVolumesAndStorage(t *testi
 		Volumes: []string{"pgdata:/var/lib/postgresql/data", "/cache", "/host/uploads:/uploads"},
 		Backup: &config.BackupConfig{
 			Schedule: "0 2 * * *",
-			Retain:   14,
+			Retain:   config.BackupRetention{Days: 14},
 			Volumes:  []string{"pgdata"},
 			Storage: &config.BackupStorageConfig{
 				Provider:        config.BackupStorageProviderR2,
@@ -1754,7 +1757,7 @@ func TestBuildTakodBackupScheduleRequestDefaultsToBackupableServiceVolumes(t *te
 
 	request, err := deploy.buildTakodBackupScheduleRequest("app", &config.ServiceConfig{
 		Volumes: []string{"cache:/cache", "/data", "/host/uploads:/uploads", "broken:"},
-		Backup:  &config.BackupConfig{Schedule: "@daily", Retain: 7},
+		Backup:  &config.BackupConfig{Schedule: "@daily", Retain: config.BackupRetention{Days: 7}},
 	})
 	if err != nil {
 		t.Fatalf("buildTakodBackupScheduleRequest returned error: %v", err)
@@ -1767,6 +1770,55 @@ func TestBuildTakodBackupScheduleRequestDefaultsToBackupableServiceVolumes(t *te
 	}
 }
 
+func TestBuildTakodBackupScheduleRequestCarriesBackupMode(t *testing.T) {
+	deploy := &Deployer{
+		config:      &config.Config{Project: config.ProjectConfig{Name: "demo"}},
+		environment: "production",
+	}
+
+	request, err := deploy.buildTakodBackupScheduleRequest("mysql", &config.ServiceConfig{
+		Volumes: []string{"mysqldata:/var/lib/mysql"},
+		Backup:  &config.BackupConfig{Schedule: "@daily", Retain: config.BackupRetention{Days: 7}, Mode: config.BackupModeMySQLDump, Database: "shop", PostBackup: "echo done"},
+	})
+	if err != nil {
+		t.Fatalf("buildTakodBackupScheduleRequest returned error: %v", err)
+	}
+	if request.Mode != takod.BackupModeMySQLDump || request.Database != "shop" || request.PostBackup != "echo done" {
+		t.Fatalf("request = %#v, want mysqldump mode", request)
+	}
+	if len(request.Volumes) != 1 || request.Volumes[0].Volume != "mysqldata" {
+		t.Fatalf("request volumes = %#v", request.Volumes)
+	}
+}
+
+func TestBuildTakodBackupScheduleRequestCarriesVerifyDrill(t *testing.T) {
+	deploy := &Deployer{
+		config: &config.Config{
+			Project:       config.ProjectConfig{Name: "demo"},
+			Notifications: &config.NotificationsConfig{Slack: "https://hooks.slack.test/T/B/X"},
+		},
+		environment: "production",
+	}
+
+	request, err := deploy.buildTakodBackupScheduleRequest("postgres", &config.ServiceConfig{
+		Volumes: []string{"pgdata:/var/lib/postgresql/data"},
+		Backup: &config.BackupConfig{Schedule: "@daily", Verify: &config.BackupVerifyConfig{
+			Schedule: "0 5 * * 0",
+			Check:    "pg_isready",
+			Timeout:  "15m",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("buildTakodBackupScheduleRequest returned error: %v", err)
+	}
+	if request.Verify == nil || request.Verify.Schedule != "0 5 * * 0" || !request.Verify.Boot || request.Verify.Check != "pg_isready" || request.Verify.TimeoutSeconds != 900 {
+		t.Fatalf("verify = %#v, want weekly booted drill with a 15m timeout", request.Verify)
+	}
+	if request.Notifications == nil || request.Notifications.Slack == "" {
+		t.Fatalf("notifications = %#v, want failed drills routed to slack", request.Notifications)
+	}
+}
+
 func waitForTakodDeployStarts(t *testing.T, started <-chan string, count int) {
 	t.Helper()
 	seen := map[string]bool{}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/redentordev/tako-cli/pkg/config"
+	"github.com/redentordev/tako-cli/pkg/deployplan"
 	"github.com/redentordev/tako-cli/pkg/nodeidentity"
 	"github.com/redentordev/tako-cli/pkg/runtimeid"
 	"github.com/redentordev/tako-cli/pkg/takoapi/events"
@@ -40,6 +41,9 @@ type takodProxyReconcileTarget struct {
 
 type takodProxyRenderOptions struct {
 	ActiveRevisions map[string]string
+	// Canaries splits a service's traffic with a warm canary revision. A
+	// service absent from the map keeps whatever split is already published.
+	Canaries map[string]deployplan.CanaryTraffic
 }
 
 func (d *Deployer) ReconcileTakodProxy(services map[string]config.ServiceConfig) error {
@@ -54,6 +58,85 @@ func (d *Deployer) ReconcileTakodProxyWithActiveRevisions(services map[string]co
 	return d.reconcileTakodProxyWithOptions(services, takodProxyRenderOptions{ActiveRevisions: normalizedRevisions})
 }
 
+// ReconcileTakodProxyWithCanaries reconciles proxy routes like
+// ReconcileTakodProxyWithActiveRevisions, additionally weighting traffic
+// between each listed service's active revision and its canary revision.
+func (d *Deployer) ReconcileTakodProxyWithCanaries(services map[string]config.ServiceConfig, activeRevisions map[string]string, canaries map[string]deployplan.CanaryTraffic) error {
+	normalizedRevisions, err := normalizeTakodProxyActiveRevisions(services, activeRevisions)
+	if err != nil {
+		return err
+	}
+	normalizedCanaries, err := normalizeTakodProxyCanaries(services, normalizedRevisions, canaries)
+	if err != nil {
+		return err
+	}
+	return d.reconcileTakodProxyWithOptions(services, takodProxyRenderOptions{ActiveRevisions: normalizedRevisions, Canaries: normalizedCanaries})
+}
+
+// PublishedTakodProxyCanaries returns the canary splits currently published
+// for canary-strategy services, keyed by service name.
+func (d *Deployer) PublishedTakodProxyCanaries(services map[string]config.ServiceConfig) (map[string]deployplan.CanaryTraffic, error) {
+	proxyServers, err := d.getTakodProxyTargetServers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get takod proxy targets: %w", err)
+	}
+	published, err := d.readPublishedTakodProxyCanaries(services, proxyServers)
+	if err != nil {
+		return nil, err
+	}
+	canaries := make(map[string]deployplan.CanaryTraffic, len(published))
+	for serviceName, route := range published {
+		canaries[serviceName] = deployplan.CanaryTraffic{Revision: route.CanaryRevision, Percent: route.CanaryPercent}
+	}
+	return canaries, nil
+}
+
+// AnalyzeTakodProxyTraffic collects per-revision access log statistics for
+// serviceName from every proxy target. Counts and latency histograms are
+// summed across proxies, so percentiles describe all of the revision's
+// traffic rather than any one proxy's share of it.
+func (d *Deployer) AnalyzeTakodProxyTraffic(serviceName string, since time.Time) (map[string]takod.RevisionTrafficStats, error) {
+	proxyServers, err := d.getTakodProxyTargetServers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get takod proxy targets: %w", err)
+	}
+	var analyses []takod.ProxyTrafficAnalysis
+	for _, serverName := range proxyServers {
+		client, err := d.getRuntimeClient(serverName)
+		if err != nil {
+			return nil, err
+		}
+		if err := d.ensureTakodCapability(client, serverName, takod.CapabilityProxyAnalysisV1, "proxy traffic analysis"); err != nil {
+			return nil, err
+		}
+		output, err := takodclient.RequestJSON(client, d.takodSocket(), "GET", takodclient.ProxyAnalysisEndpoint(d.config.Project.Name, d.environment, serviceName, since), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to analyze proxy traffic on %s: %w", serverName, err)
+		}
+		var analysis takod.ProxyTrafficAnalysis
+		if err := json.Unmarshal([]byte(output), &analysis); err != nil {
+			return nil, fmt.Errorf("failed to parse proxy traffic analysis on %s: %w", serverName, err)
+		}
+		analyses = append(analyses, analysis)
+	}
+	return mergeTakodProxyTrafficAnalyses(analyses), nil
+}
+
+func mergeTakodProxyTrafficAnalyses(analyses []takod.ProxyTrafficAnalysis) map[string]takod.RevisionTrafficStats {
+	merged := make(map[string]takod.RevisionTrafficStats)
+	for _, analysis := range analyses {
+		for _, stats := range analysis.Revisions {
+			current, ok := merged[stats.Revision]
+			if !ok {
+				merged[stats.Revision] = stats
+				continue
+			}
+			merged[stats.Revision] = takod.MergeRevisionTrafficStats(current, stats)
+		}
+	}
+	return merged
+}
+
 // PreflightTakodProxyCapabilities verifies every proxy target understands all
 // route-manifest fields before an applying workflow mutates service state.
 func (d *Deployer) PreflightTakodProxyCapabilities(services map[string]config.ServiceConfig) error {
@@ -179,6 +262,9 @@ func (d *Deployer) reconcileTakodProxyWithOptions(services map[string]config.Ser
 	return runTakodProxyReconcile(
 		func() error { return d.PreflightTakodProxyCapabilities(services) },
 		func() error {
+			if err := d.carryForwardTakodProxyCanaries(services, proxyServers, &options); err != nil {
+				return err
+			}
 			configs := make(map[string][]byte, len(proxyServers))
 			hasPublic := make(map[string]bool, len(proxyServers))
 			// Render first so every worker allocation proof is collected before
@@ -221,6 +307,14 @@ func (d *Deployer) reconcileTakodProxyWithOptions(services map[string]config.Ser
 					return nil
 				}
 
+				rateLimit := proxyServicesUseRateLimit(services)
+				if rateLimit {
+					// Switch to the rate limit proxy build first: the manifest
+					// is validated against the running proxy when written.
+					if err := d.ensureTakodProxy(client, takodNetworkName(d.config.Project.Name, d.environment), firstProxyEmail(services), true); err != nil {
+						return fmt.Errorf("failed to reconcile proxy: %w", err)
+					}
+				}
 				acmeRequest, err := d.syncTakodProxyACMEForServices(client, serverName, services)
 				if err != nil {
 					return fmt.Errorf("failed to issue proxy DNS certificate: %w", err)
@@ -235,7 +329,7 @@ func (d *Deployer) reconcileTakodProxyWithOptions(services map[string]config.Ser
 				} else if err := d.finalizeTakodProxyACME(client, *acmeRequest); err != nil {
 					return fmt.Errorf("failed to finalize proxy ACME ownership: %w", err)
 				}
-				if err := d.ensureTakodProxy(client, takodNetworkName(d.config.Project.Name, d.environment), firstProxyEmail(services)); err != nil {
+				if err := d.ensureTakodProxy(client, takodNetworkName(d.config.Project.Name, d.environment), firstProxyEmail(services), rateLimit); err != nil {
 					return fmt.Errorf("failed to reconcile proxy: %w", err)
 				}
 				return nil
@@ -393,6 +487,136 @@ func normalizeTakodProxyActiveRevisions(services map[string]config.ServiceConfig
 	return normalized, nil
 }
 
+func normalizeTakodProxyCanaries(services map[string]config.ServiceConfig, activeRevisions map[string]string, canaries map[string]deployplan.CanaryTraffic) (map[string]deployplan.CanaryTraffic, error) {
+	if len(canaries) == 0 {
+		return nil, nil
+	}
+	normalized := make(map[string]deployplan.CanaryTraffic, len(canaries))
+	for serviceName, canary := range canaries {
+		service, ok := services[serviceName]
+		if !ok {
+			return nil, fmt.Errorf("canary references unknown service %q", serviceName)
+		}
+		if canary.Percent == 0 {
+			normalized[serviceName] = deployplan.CanaryTraffic{}
+			continue
+		}
+		if !deployplan.IsCanaryService(service) {
+			return nil, fmt.Errorf("service %s does not use deploy.strategy=canary", serviceName)
+		}
+		if canary.Percent < 1 || canary.Percent > 99 {
+			return nil, fmt.Errorf("canary traffic for service %s must be between 1 and 99 percent", serviceName)
+		}
+		canary.Revision = strings.TrimSpace(canary.Revision)
+		if !isSafeTakodProxyRevision(canary.Revision) {
+			return nil, fmt.Errorf("canary revision for service %s contains unsafe characters", serviceName)
+		}
+		if active := activeRevisions[serviceName]; active == "" || active == canary.Revision {
+			return nil, fmt.Errorf("canary revision for service %s must differ from a known active revision", serviceName)
+		}
+		normalized[serviceName] = canary
+	}
+	return normalized, nil
+}
+
+// carryForwardTakodProxyCanaries keeps a published canary split in place when
+// an unrelated reconcile rewrites the manifest, as long as the service still
+// routes its stable traffic to the same active revision.
+func (d *Deployer) carryForwardTakodProxyCanaries(services map[string]config.ServiceConfig, proxyServers []string, options *takodProxyRenderOptions) error {
+	pending := make(map[string]config.ServiceConfig)
+	for serviceName, service := range services {
+		if !deployplan.IsCanaryService(service) || !service.IsProxied() {
+			continue
+		}
+		if _, explicit := options.Canaries[serviceName]; explicit {
+			continue
+		}
+		if options.ActiveRevisions[serviceName] == "" {
+			continue
+		}
+		pending[serviceName] = service
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	published, err := d.readPublishedTakodProxyCanaries(pending, proxyServers)
+	if err != nil {
+		return err
+	}
+	for serviceName, route := range published {
+		if route.Revision != options.ActiveRevisions[serviceName] {
+			continue
+		}
+		if options.Canaries == nil {
+			options.Canaries = make(map[string]deployplan.CanaryTraffic)
+		}
+		options.Canaries[serviceName] = deployplan.CanaryTraffic{Revision: route.CanaryRevision, Percent: route.CanaryPercent}
+	}
+	return nil
+}
+
+func (d *Deployer) readPublishedTakodProxyCanaries(services map[string]config.ServiceConfig, proxyServers []string) (map[string]takod.ProxyRoute, error) {
+	published := make(map[string]takod.ProxyRoute)
+	for _, serverName := range proxyServers {
+		client, err := d.getRuntimeClient(serverName)
+		if err != nil {
+			return nil, err
+		}
+		output, err := takodclient.RequestJSON(client, d.takodSocket(), "GET", takodclient.ScopedProxyFileEndpoint(d.config.Project.Name, d.environment, d.takodProxyConfigFileName()), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read published proxy routes on %s: %w", serverName, err)
+		}
+		var response takod.ProxyFileResponse
+		if err := json.Unmarshal([]byte(output), &response); err != nil {
+			return nil, fmt.Errorf("failed to parse published proxy routes on %s: %w", serverName, err)
+		}
+		if strings.TrimSpace(response.Content) == "" {
+			continue
+		}
+		manifest, err := takod.ParseProxyRouteManifest(response.Content)
+		if err != nil {
+			return nil, fmt.Errorf("published proxy routes on %s are invalid: %w", serverName, err)
+		}
+		for _, route := range manifest.Routes {
+			if _, ok := services[route.Service]; !ok || route.CanaryRevision == "" {
+				continue
+			}
+			if _, seen := published[route.Service]; !seen {
+				published[route.Service] = route
+			}
+		}
+	}
+	return published, nil
+}
+
+// canaryUpstreamWeights returns per-upstream weights that give the canary
+// upstreams percent of the traffic in aggregate, reduced to lowest terms.
+func canaryUpstreamWeights(stableCount int, canaryCount int, percent int) []int {
+	if stableCount == 0 || canaryCount == 0 {
+		return nil
+	}
+	stableWeight := (100 - percent) * canaryCount
+	canaryWeight := percent * stableCount
+	divisor := gcd(stableWeight, canaryWeight)
+	stableWeight /= divisor
+	canaryWeight /= divisor
+	weights := make([]int, 0, stableCount+canaryCount)
+	for i := 0; i < stableCount; i++ {
+		weights = append(weights, stableWeight)
+	}
+	for i := 0; i < canaryCount; i++ {
+		weights = append(weights, canaryWeight)
+	}
+	return weights
+}
+
+func gcd(a int, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
 func isSafeTakodProxyRevision(value string) bool {
 	if len(value) == 0 || len(value) > 63 {
 		return false
@@ -528,18 +752,63 @@ func (d *Deployer) renderTakodProxyDynamicConfigForNodeWithOptions(services map[
 			}
 		}
 
+		var weights []int
+		canaryUpstreamCount := 0
+		canary := options.Canaries[serviceName]
+		if canary.Percent > 0 && revision != "" {
+			canaryUpstreams := make([]string, 0, len(assignments))
+			for _, assignment := range assignments {
+				url, err := d.takodProxyUpstreamURLForRevision(proxyServerName, assignment.ServerName, serviceName, canary.Revision, assignment.Slot, service.Port)
+				if err != nil {
+					return nil, false, err
+				}
+				if seenUpstreams[url] {
+					continue
+				}
+				seenUpstreams[url] = true
+				canaryUpstreams = append(canaryUpstreams, url)
+				if manifestVersion >= 2 {
+					proof, err := d.proxyDestinationProof(proxyServerName, assignment, serviceName, canary.Revision, service.Port, url)
+					if err != nil {
+						return nil, false, err
+					}
+					destinations = append(destinations, proof)
+				}
+			}
+			weights = canaryUpstreamWeights(len(upstreams), len(canaryUpstreams), canary.Percent)
+			canaryUpstreamCount = len(canaryUpstreams)
+			upstreams = append(upstreams, canaryUpstreams...)
+		}
+
 		route := takod.ProxyRoute{
 			Service:        serviceName,
 			Revision:       revision,
 			Domains:        domains,
 			RedirectFrom:   redirects,
 			Upstreams:      upstreams,
+			Weights:        weights,
 			HealthCheck:    proxyRouteHealthCheckForService(service),
 			Sticky:         service.LoadBalancer.Strategy == "sticky",
 			Visibility:     service.Proxy.EffectiveVisibility(),
 			AllowIPs:       append([]string(nil), service.Proxy.AllowIps...),
 			TrustedProxies: append([]string(nil), service.Proxy.TrustedProxies...),
 			Destinations:   destinations,
+			Paths:          append([]string(nil), service.Proxy.Paths...),
+			StripPrefix:    service.Proxy.StripPrefix,
+			MaxBodyBytes:   service.Proxy.MaxBodyBytes(),
+		}
+		if limit := service.Proxy.RateLimit; limit != nil {
+			route.RateLimit = &takod.ProxyRouteRateLimit{
+				Requests: limit.Requests,
+				Window:   limit.Window,
+				Header:   limit.KeyHeader(),
+				Paths:    append([]string(nil), limit.Paths...),
+			}
+		}
+		if len(weights) > 0 {
+			route.CanaryRevision = canary.Revision
+			route.CanaryPercent = canary.Percent
+			route.CanaryUpstreams = canaryUpstreamCount
 		}
 		if auth := service.Proxy.BasicAuth; auth != nil {
 			route.BasicAuth = &takod.ProxyRouteBasicAuth{
@@ -594,6 +863,51 @@ func proxyServicesUseTrustedProxies(services map[string]config.ServiceConfig) bo
 	return false
 }
 
+func proxyServicesUseCanary(services map[string]config.ServiceConfig) bool {
+	for _, service := range services {
+		if service.IsProxied() && deployplan.IsCanaryService(service) {
+			return true
+		}
+	}
+	return false
+}
+
+func proxyServicesUseAnalysis(services map[string]config.ServiceConfig) bool {
+	for _, service := range services {
+		if service.IsProxied() && service.Deploy.Analysis != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func proxyServicesUsePaths(services map[string]config.ServiceConfig) bool {
+	for _, service := range services {
+		if service.IsProxied() && len(service.Proxy.Paths) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func proxyServicesUseLimits(services map[string]config.ServiceConfig) bool {
+	for _, service := range services {
+		if service.IsProxied() && (service.Proxy.RateLimit != nil || service.Proxy.MaxBodySize != "") {
+			return true
+		}
+	}
+	return false
+}
+
+func proxyServicesUseRateLimit(services map[string]config.ServiceConfig) bool {
+	for _, service := range services {
+		if service.IsProxied() && service.Proxy.RateLimit != nil {
+			return true
+		}
+	}
+	return false
+}
+
 func proxyServicesUseACMEDNS(services map[string]config.ServiceConfig) bool {
 	for _, service := range services {
 		if service.Proxy == nil || !service.IsPublic() {
@@ -624,6 +938,18 @@ func takodProxyCapabilityRequirements(services map[string]config.ServiceConfig)
 	if proxyServicesUseACMEDNS(services) {
 		requirements = append(requirements, takodProxyCapabilityRequirement{Capability: takod.CapabilityAcmeDNSV1, Feature: "embedded ACME DNS-01 issuance"})
 	}
+	if proxyServicesUseCanary(services) {
+		requirements = append(requirements, takodProxyCapabilityRequirement{Capability: takod.CapabilityDeployCanaryV1, Feature: "weighted canary routes"})
+	}
+	if proxyServicesUseAnalysis(services) {
+		requirements = append(requirements, takodProxyCapabilityRequirement{Capability: takod.CapabilityProxyAnalysisV1, Feature: "proxy traffic analysis"})
+	}
+	if proxyServicesUsePaths(services) {
+		requirements = append(requirements, takodProxyCapabilityRequirement{Capability: takod.CapabilityProxyPathsV1, Feature: "path-based proxy routes"})
+	}
+	if proxyServicesUseLimits(services) {
+		requirements = append(requirements, takodProxyCapabilityRequirement{Capability: takod.CapabilityProxyLimitsV1, Feature: "proxy rate and body size limits"})
+	}
 	return requirements
 }
 
@@ -958,7 +1284,7 @@ func (d *Deployer) proxyDestinationProof(proxyServerName string, assignment tako
 }
 
 func (d *Deployer) writeTakodProxyConfig(client any, data []byte) error {
-	_, err := takodclient.RequestJSON(client, d.takodSocket(), "PUT", "/v1/proxy-file", takod.ProxyFileRequest{
+	_, err := takodclient.RequestJSONWithContext(d.traceContext(), client, d.takodSocket(), "PUT", "/v1/proxy-file", takod.ProxyFileRequest{
 		Name:    d.takodProxyConfigFileName(),
 		Content: string(data),
 	})
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/redentordev/tako-cli/pkg/config"
+	"github.com/redentordev/tako-cli/pkg/deployplan"
 	"github.com/redentordev/tako-cli/pkg/runtimeid"
 	"github.com/redentordev/tako-cli/pkg/takoapi/events"
 	"github.com/redentordev/tako-cli/pkg/takod"
@@ -167,6 +168,102 @@ func TestRenderTakodProxyDynamicConfigCanRouteActiveRevision(t *testing.T) {
 	}
 }
 
+func TestRenderTakodProxyDynamicConfigWeightsCanaryRevision(t *testing.T) {
+	deploy := testProxyDeployer()
+	deploy.meshPortAllocator = func(_ string, serviceName string, revision string, slot int, _ int) (int, error) {
+		switch revision {
+		case "rev-stable":
+			return 43000 + slot, nil
+		case "rev-canary":
+			return 44000 + slot, nil
+		}
+		return deploy.meshUpstreamPort(serviceName, slot)
+	}
+	services := deploy.config.Environments["production"].Services
+	web := services["web"]
+	web.Deploy.Strategy = config.DeployStrategyCanary
+	services["web"] = web
+
+	data, _, err := deploy.renderTakodProxyDynamicConfigForNodeWithOptions(services, "node-a", takodProxyRenderOptions{
+		ActiveRevisions: map[string]string{"web": "rev-stable"},
+		Canaries:        map[string]deployplan.CanaryTraffic{"web": {Revision: "rev-canary", Percent: 10}},
+	})
+	if err != nil {
+		t.Fatalf("renderTakodProxyDynamicConfigForNodeWithOptions returned error: %v", err)
+	}
+
+	route := onlyProxyRoute(t, parseProxyManifest(t, data))
+	if route.Revision != "rev-stable" || route.CanaryRevision != "rev-canary" || route.CanaryPercent != 10 {
+		t.Fatalf("route revisions = %q/%q at %d%%, want rev-stable/rev-canary at 10%%", route.Revision, route.CanaryRevision, route.CanaryPercent)
+	}
+	assertStringsEqual(t, route.Upstreams, []string{
+		"http://" + runtimeid.RevisionContainerAlias("demo", "production", "web", "rev-stable", 1) + ":3000",
+		"http://10.210.0.2:43002",
+		"http://" + runtimeid.RevisionContainerAlias("demo", "production", "web", "rev-canary", 1) + ":3000",
+		"http://10.210.0.2:44002",
+	})
+	if fmt.Sprint(route.Weights) != "[9 9 1 1]" {
+		t.Fatalf("weights = %v, want [9 9 1 1]", route.Weights)
+	}
+	if route.CanaryUpstreams != 2 {
+		t.Fatalf("canary upstreams = %d, want 2", route.CanaryUpstreams)
+	}
+}
+
+func TestMergeTakodProxyTrafficAnalysesMergesLatencyHistograms(t *testing.T) {
+	fast := takod.LatencyBucket{Bucket: 48, Count: 30, MaxMs: 10}
+	slow := takod.LatencyBucket{Bucket: 117, Count: 10, MaxMs: 300}
+	merged := mergeTakodProxyTrafficAnalyses([]takod.ProxyTrafficAnalysis{
+		{Service: "web", Revisions: []takod.RevisionTrafficStats{{Revision: "rev-canary", Requests: 30, Errors: 3, ErrorRate: 10, P50Ms: 10, P95Ms: 10, P99Ms: 10, Latency: []takod.LatencyBucket{fast}}}},
+		{Service: "web", Revisions: []takod.RevisionTrafficStats{{Revision: "rev-canary", Requests: 10, Errors: 1, ErrorRate: 10, P50Ms: 300, P95Ms: 300, P99Ms: 300, Latency: []takod.LatencyBucket{slow}}}},
+	})
+	got := merged["rev-canary"]
+	// The slowest proxy's p50 is 300ms, but three in four requests took 10ms.
+	if got.Requests != 40 || got.Errors != 4 || got.ErrorRate != 10 || got.P50Ms != 10 || got.P95Ms != 300 || got.P99Ms != 300 {
+		t.Fatalf("merged stats = %#v", got)
+	}
+}
+
+func TestCanaryUpstreamWeights(t *testing.T) {
+	tests := []struct {
+		stable, canary, percent int
+		want                    string
+	}{
+		{stable: 1, canary: 1, percent: 5, want: "[19 1]"},
+		{stable: 2, canary: 1, percent: 25, want: "[3 3 2]"},
+		{stable: 3, canary: 3, percent: 50, want: "[1 1 1 1 1 1]"},
+		{stable: 0, canary: 1, percent: 5, want: "[]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(canaryUpstreamWeights(tt.stable, tt.canary, tt.percent)); got != tt.want {
+			t.Fatalf("canaryUpstreamWeights(%d, %d, %d) = %s, want %s", tt.stable, tt.canary, tt.percent, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeTakodProxyCanariesRejectsInvalidSplits(t *testing.T) {
+	deploy := testProxyDeployer()
+	services := deploy.config.Environments["production"].Services
+	web := services["web"]
+	web.Deploy.Strategy = config.DeployStrategyCanary
+	services["web"] = web
+	active := map[string]string{"web": "rev-stable", "api": "rev-api"}
+
+	if _, err := normalizeTakodProxyCanaries(services, active, map[string]deployplan.CanaryTraffic{"web": {Revision: "rev-canary", Percent: 25}}); err != nil {
+		t.Fatalf("valid canary returned error: %v", err)
+	}
+	for name, canaries := range map[string]map[string]deployplan.CanaryTraffic{
+		"not canary strategy": {"api": {Revision: "rev-canary", Percent: 25}},
+		"full traffic":        {"web": {Revision: "rev-canary", Percent: 100}},
+		"same revision":       {"web": {Revision: "rev-stable", Percent: 25}},
+		"unsafe revision":     {"web": {Revision: "../x", Percent: 25}},
+	} {
+		if _, err := normalizeTakodProxyCanaries(services, active, canaries); err == nil {
+			t.Fatalf("%s: expected canary to be rejected", name)
+		}
+	}
+}
+
 func TestNormalizeTakodProxyActiveRevisions(t *testing.T) {
 	deploy := testProxyDeployer()
 	services := deploy.config.Environments["production"].Services
@@ -627,6 +724,31 @@ func TestRenderTakodProxyDynamicConfigCarriesAccessControls(t *testing.T) {
 	assertStringsEqual(t, route.TrustedProxies, []string{"10.0.0.0/8", "2001:db8::/32"})
 }
 
+func TestRenderTakodProxyDynamicConfigCarriesLimits(t *testing.T) {
+	deploy := testProxyDeployer()
+	services := deploy.config.Environments["production"].Services
+	web := services["web"]
+	web.Proxy = &config.ProxyConfig{
+		Domain:      "example.com",
+		MaxBodySize: "1mb",
+		RateLimit:   &config.ProxyRateLimitConfig{Requests: 10, Window: "1m0s", Key: "header:X-Api-Key", Paths: []string{"/login"}},
+	}
+	services["web"] = web
+
+	data, _, err := deploy.renderTakodProxyDynamicConfigForNode(services, "node-a")
+	if err != nil {
+		t.Fatalf("renderTakodProxyDynamicConfigForNode returned error: %v", err)
+	}
+	route := onlyProxyRoute(t, parseProxyManifest(t, data))
+	if route.MaxBodyBytes != 1<<20 {
+		t.Fatalf("maxBodyBytes = %d", route.MaxBodyBytes)
+	}
+	if limit := route.RateLimit; limit == nil || limit.Requests != 10 || limit.Window != "1m0s" || limit.Header != "X-Api-Key" {
+		t.Fatalf("rateLimit = %#v", route.RateLimit)
+	}
+	assertStringsEqual(t, route.RateLimit.Paths, []string{"/login"})
+}
+
 func TestProxyServicesUseTrustedProxiesOnlyForProxiedServices(t *testing.T) {
 	if proxyServicesUseTrustedProxies(map[string]config.ServiceConfig{
 		"web": {Port: 3000, Proxy: &config.ProxyConfig{Domain: "example.com", TrustedProxies: []string{"10.0.0.0/8"}}},
@@ -808,6 +930,37 @@ func TestACMEDNSCapabilityPreflightFailsBeforeMutation(t *testing.T) {
 	}
 }
 
+func TestPathRoutesRequireProxyPathsCapability(t *testing.T) {
+	services := map[string]config.ServiceConfig{
+		"web": {Port: 3000, Proxy: &config.ProxyConfig{Domain: "example.com"}},
+	}
+	if requirements := takodProxyCapabilityRequirements(services); len(requirements) != 0 {
+		t.Fatalf("whole-domain routes required %+v", requirements)
+	}
+	services["api"] = config.ServiceConfig{Port: 3000, Proxy: &config.ProxyConfig{Domain: "example.com", Paths: []string{"/api/*"}, StripPrefix: true}}
+	requirements := takodProxyCapabilityRequirements(services)
+	if len(requirements) != 1 || requirements[0].Capability != takod.CapabilityProxyPathsV1 {
+		t.Fatalf("requirements = %+v", requirements)
+	}
+}
+
+func TestProxyLimitsRequireProxyLimitsCapability(t *testing.T) {
+	services := map[string]config.ServiceConfig{
+		"web": {Port: 3000, Proxy: &config.ProxyConfig{Domain: "example.com", MaxBodySize: "10mb"}},
+	}
+	requirements := takodProxyCapabilityRequirements(services)
+	if len(requirements) != 1 || requirements[0].Capability != takod.CapabilityProxyLimitsV1 {
+		t.Fatalf("requirements = %+v", requirements)
+	}
+	if proxyServicesUseRateLimit(services) {
+		t.Fatal("body size limit alone must not switch the proxy image")
+	}
+	services["api"] = config.ServiceConfig{Port: 3000, Proxy: &config.ProxyConfig{Domain: "api.example.com", RateLimit: &config.ProxyRateLimitConfig{Requests: 5, Window: "1m0s", Key: config.ProxyRateLimitKeyIP}}}
+	if !proxyServicesUseRateLimit(services) {
+		t.Fatal("rate limited route did not request the rate limit proxy image")
+	}
+}
+
 func TestRemoteMeshCapabilityPreflightFailsBeforeMutation(t *testing.T) {
 	assignments := map[string][]takodAssignment{
 		"web": {{ServerName: "node-b", Slot: 1}},
@@ -0,0 +1,87 @@
+package deployer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/redentordev/tako-cli/pkg/takoapi/events"
+	"github.com/redentordev/tako-cli/pkg/takod"
+	"github.com/redentordev/tako-cli/pkg/takodclient"
+)
+
+// ApplyTracing hands the tracing block to every target node so takod
+// exports its spans for this environment's deploys. The engine applies it
+// before any image build, so the deploy doing the apply is already traced
+// end to end. Without the block it clears any earlier spec, skipping nodes
+// too old to have traced at all.
+func (d *Deployer) ApplyTracing() error {
+	targetServers, err := d.getTakodTargetServers()
+	if err != nil {
+		return fmt.Errorf("failed to get takod target servers: %w", err)
+	}
+	if len(targetServers) == 0 {
+		return nil
+	}
+	tracing := d.config.Tracing
+	if tracing == nil {
+		return runTakodNodeActions(targetServers, func(serverName string) error {
+			client, err := d.getRuntimeClient(serverName)
+			if err != nil {
+				return err
+			}
+			var capabilityErr *takodclient.CapabilityRequiredError
+			if err := d.ensureTakodCapability(client, serverName, takod.CapabilityTracingV1, "tracing"); errors.As(err, &capabilityErr) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			return d.applyNodeTracing(client, serverName, nil)
+		})
+	}
+
+	return runTakodJobApplyPhases(targetServers, func() error {
+		if err := d.preflightTakodCapability(targetServers, takod.CapabilityTracingV1, "tracing"); err != nil {
+			return fmt.Errorf("tracing requires takod trace export support: %w", err)
+		}
+		return nil
+	}, func(serverName string) error {
+		client, err := d.getRuntimeClient(serverName)
+		if err != nil {
+			return err
+		}
+		return d.applyNodeTracing(client, serverName, &takod.TracingSpec{
+			Endpoint: tracing.Endpoint,
+			Insecure: tracing.Insecure,
+			Headers:  tracing.Headers,
+			Node:     serverName,
+		})
+	})
+}
+
+func (d *Deployer) applyNodeTracing(client any, serverName string, spec *takod.TracingSpec) error {
+	output, err := takodclient.RequestJSONWithContext(d.baseContext(), client, d.takodSocket(), "POST", takodclient.TracingApplyEndpoint(), takod.TracingApplyRequest{
+		Project:     d.config.Project.Name,
+		Environment: d.environment,
+		Spec:        spec,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply tracing on %s: %w", serverName, err)
+	}
+	var response takod.TracingApplyResponse
+	if err := json.Unmarshal([]byte(output), &response); err != nil {
+		return fmt.Errorf("failed to parse tracing response from %s: %w", serverName, err)
+	}
+	if !response.Exporting {
+		return nil
+	}
+	d.emitEvent(events.Event{
+		Type:    events.TypeDeployTracingApplied,
+		Phase:   events.PhaseDeploy,
+		Level:   events.LevelInfo,
+		Node:    serverName,
+		Message: fmt.Sprintf("  ✓ Tracing on %s: exporting to %s\n", serverName, response.Endpoint),
+		Data:    map[string]any{"node": serverName, "endpoint": response.Endpoint},
+	})
+	return nil
+}
@@ -5,7 +5,8 @@ import (
 	"github.com/redentordev/tako-cli/pkg/reconcile"
 )
 
-// ProxyActiveRevisions returns active proxy revisions for rolling and blue-green services.
+// ProxyActiveRevisions returns active proxy revisions for rolling, blue-green,
+// and canary services.
 func ProxyActiveRevisions(
 	cfg *config.Config,
 	envName string,
@@ -20,13 +21,13 @@ func ProxyActiveRevisions(
 	revisions := make(map[string]string)
 	for serviceName, service := range services {
 		switch service.Deploy.Strategy {
-		case config.DeployStrategyRolling, config.DeployStrategyBlueGreen:
+		case config.DeployStrategyRolling, config.DeployStrategyBlueGreen, config.DeployStrategyCanary:
 		default:
 			continue
 		}
 
 		if _, deploying := servicesToDeploy[serviceName]; deploying {
-			if IsManualBlueGreenService(service) {
+			if IsWarmPromotionService(service) {
 				if actual := actualState[serviceName]; actual != nil && actual.CurrentRevision != "" {
 					revisions[serviceName] = actual.CurrentRevision
 					continue
@@ -57,7 +58,7 @@ func DeployedProxyActiveRevisions(servicesToDeploy map[string]config.ServiceConf
 	}
 	deployed := make(map[string]string)
 	for serviceName, service := range servicesToDeploy {
-		if IsManualBlueGreenService(service) {
+		if IsWarmPromotionService(service) {
 			continue
 		}
 		if revision := activeRevisions[serviceName]; revision != "" {
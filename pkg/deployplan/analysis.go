@@ -0,0 +1,80 @@
+package deployplan
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/config"
+	"github.com/redentordev/tako-cli/pkg/reconcile"
+	"github.com/redentordev/tako-cli/pkg/takod"
+)
+
+// RollbackTarget is the revision, and the image that produces it, a service
+// returns to when its promoted revision fails traffic analysis.
+type RollbackTarget struct {
+	Revision string
+	Image    string
+}
+
+// BlueGreenRollbackTargets returns the previously active revision of every
+// blue_green service with deploy.analysis whose promotion to keepRevisions
+// replaces a running revision. actualState must predate the promotion.
+func BlueGreenRollbackTargets(services map[string]config.ServiceConfig, keepRevisions map[string]string, actualState map[string]*reconcile.ActualService) map[string]RollbackTarget {
+	targets := make(map[string]RollbackTarget)
+	for serviceName, revision := range keepRevisions {
+		service, ok := services[serviceName]
+		if !ok || service.Deploy.Strategy != config.DeployStrategyBlueGreen || service.Deploy.Analysis == nil {
+			continue
+		}
+		actual := actualState[serviceName]
+		if actual == nil || actual.CurrentRevision == "" || actual.CurrentRevision == revision {
+			continue
+		}
+		image := strings.TrimSpace(actual.RevisionImages[actual.CurrentRevision])
+		if image == "" {
+			image = strings.TrimSpace(actual.Image)
+		}
+		if image == "" {
+			continue
+		}
+		targets[serviceName] = RollbackTarget{Revision: actual.CurrentRevision, Image: image}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	return targets
+}
+
+// EvaluateTrafficAnalysis checks a revision's observed proxy traffic against
+// deploy.analysis thresholds. Revisions that served fewer than the minimum
+// request count pass, since their statistics cannot support a rollback.
+func EvaluateTrafficAnalysis(analysis *config.DeployAnalysisConfig, stats takod.RevisionTrafficStats) error {
+	if analysis == nil || stats.Requests < analysis.EffectiveMinRequests() {
+		return nil
+	}
+	if analysis.MaxErrorRate != nil && stats.ErrorRate > *analysis.MaxErrorRate {
+		return fmt.Errorf("revision %s error rate %.2f%% exceeds %.2f%% over %d requests", stats.Revision, stats.ErrorRate, *analysis.MaxErrorRate, stats.Requests)
+	}
+	for _, threshold := range []struct {
+		name     string
+		limit    string
+		observed float64
+	}{
+		{name: "p95", limit: analysis.MaxP95Latency, observed: stats.P95Ms},
+		{name: "p99", limit: analysis.MaxP99Latency, observed: stats.P99Ms},
+	} {
+		if strings.TrimSpace(threshold.limit) == "" {
+			continue
+		}
+		limit, err := time.ParseDuration(strings.TrimSpace(threshold.limit))
+		if err != nil {
+			return fmt.Errorf("invalid %s latency threshold %q: %w", threshold.name, threshold.limit, err)
+		}
+		observed := time.Duration(threshold.observed * float64(time.Millisecond))
+		if observed > limit {
+			return fmt.Errorf("revision %s %s latency %s exceeds %s over %d requests", stats.Revision, threshold.name, observed, limit, stats.Requests)
+		}
+	}
+	return nil
+}
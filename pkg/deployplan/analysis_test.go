@@ -0,0 +1,67 @@
+package deployplan
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/redentordev/tako-cli/pkg/config"
+	"github.com/redentordev/tako-cli/pkg/reconcile"
+	"github.com/redentordev/tako-cli/pkg/takod"
+)
+
+func TestBlueGreenRollbackTargetsUsesPreviousRevisionImage(t *testing.T) {
+	maxErrorRate := 1.0
+	analyzed := config.ServiceConfig{Deploy: config.DeployConfig{Strategy: config.DeployStrategyBlueGreen, GracePeriod: "1m", Analysis: &config.DeployAnalysisConfig{MaxErrorRate: &maxErrorRate}}}
+	plain := config.ServiceConfig{Deploy: config.DeployConfig{Strategy: config.DeployStrategyBlueGreen, GracePeriod: "1m"}}
+	services := map[string]config.ServiceConfig{"web": analyzed, "api": plain, "new": analyzed, "same": analyzed}
+	keep := map[string]string{"web": "rev-new", "api": "rev-api-new", "new": "rev-first", "same": "rev-same"}
+	actual := map[string]*reconcile.ActualService{
+		"web":  {Name: "web", CurrentRevision: "rev-old", Image: "demo/web:v2", RevisionImages: map[string]string{"rev-old": "demo/web:v1"}},
+		"api":  {Name: "api", CurrentRevision: "rev-api-old", Image: "demo/api:v1"},
+		"same": {Name: "same", CurrentRevision: "rev-same", Image: "demo/same:v1"},
+	}
+
+	targets := BlueGreenRollbackTargets(services, keep, actual)
+	if len(targets) != 1 || targets["web"] != (RollbackTarget{Revision: "rev-old", Image: "demo/web:v1"}) {
+		t.Fatalf("targets = %#v, want only web rolling back to rev-old", targets)
+	}
+}
+
+func TestEvaluateTrafficAnalysis(t *testing.T) {
+	maxErrorRate := 2.0
+	analysis := &config.DeployAnalysisConfig{MaxErrorRate: &maxErrorRate, MaxP95Latency: "300ms", MaxP99Latency: "1s", MinRequests: 10}
+	healthy := takod.RevisionTrafficStats{Revision: "rev-new", Requests: 100, Errors: 1, ErrorRate: 1, P95Ms: 250, P99Ms: 900}
+	if err := EvaluateTrafficAnalysis(analysis, healthy); err != nil {
+		t.Fatalf("healthy revision returned error: %v", err)
+	}
+	if err := EvaluateTrafficAnalysis(nil, takod.RevisionTrafficStats{Requests: 100, ErrorRate: 100}); err != nil {
+		t.Fatalf("nil analysis returned error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(*takod.RevisionTrafficStats)
+		want   string
+	}{
+		{name: "too few requests", mutate: func(s *takod.RevisionTrafficStats) { s.Requests = 9; s.ErrorRate = 50 }},
+		{name: "error rate", mutate: func(s *takod.RevisionTrafficStats) { s.ErrorRate = 2.5 }, want: "error rate 2.50% exceeds 2.00%"},
+		{name: "p95", mutate: func(s *takod.RevisionTrafficStats) { s.P95Ms = 301 }, want: "p95 latency 301ms exceeds 300ms"},
+		{name: "p99", mutate: func(s *takod.RevisionTrafficStats) { s.P99Ms = 1500 }, want: "p99 latency 1.5s exceeds 1s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := healthy
+			tt.mutate(&stats)
+			err := EvaluateTrafficAnalysis(analysis, stats)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
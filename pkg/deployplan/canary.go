@@ -0,0 +1,106 @@
+package deployplan
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/redentordev/tako-cli/pkg/config"
+	"github.com/redentordev/tako-cli/pkg/reconcile"
+	"github.com/redentordev/tako-cli/pkg/takod"
+)
+
+// CanaryTraffic is the share of proxy traffic routed to a warm canary
+// revision beside the active revision. A zero Percent clears the split.
+type CanaryTraffic struct {
+	Revision string
+	Percent  int
+}
+
+// IsCanaryService reports whether a service uses the canary deploy strategy.
+func IsCanaryService(service config.ServiceConfig) bool {
+	return service.Deploy.Strategy == config.DeployStrategyCanary
+}
+
+// IsManualCanaryService reports whether canary steps advance only via promote.
+func IsManualCanaryService(service config.ServiceConfig) bool {
+	return IsCanaryService(service) && service.Deploy.Promotion == config.DeployPromotionManual
+}
+
+// IsWarmPromotionService reports whether a deploy warms the new revision
+// beside the active one instead of switching traffic immediately.
+func IsWarmPromotionService(service config.ServiceConfig) bool {
+	if IsManualBlueGreenService(service) {
+		return true
+	}
+	return IsCanaryService(service) && service.Deploy.Canary.EffectiveSteps()[0] < 100
+}
+
+// CanaryStartTraffic returns the first-step traffic split for canary
+// services whose deploy warms a new revision beside a running one.
+func CanaryStartTraffic(
+	cfg *config.Config,
+	envName string,
+	servicesToDeploy map[string]config.ServiceConfig,
+	imageRefs map[string]string,
+	actualState map[string]*reconcile.ActualService,
+) map[string]CanaryTraffic {
+	if cfg == nil || len(servicesToDeploy) == 0 {
+		return nil
+	}
+	canaries := make(map[string]CanaryTraffic)
+	for serviceName, service := range servicesToDeploy {
+		if !IsCanaryService(service) || !ShouldWarmManualPromotionService(serviceName, service, actualState) {
+			continue
+		}
+		imageRef := imageRefs[serviceName]
+		if imageRef == "" {
+			imageRef = ImageRef(cfg, envName, serviceName, service, "")
+		}
+		revision := ServiceRevisionID(cfg.Project.Name, envName, serviceName, imageRef, service)
+		if revision == actualState[serviceName].CurrentRevision {
+			continue
+		}
+		canaries[serviceName] = CanaryTraffic{Revision: revision, Percent: service.Deploy.Canary.EffectiveSteps()[0]}
+	}
+	if len(canaries) == 0 {
+		return nil
+	}
+	return canaries
+}
+
+// NextCanaryStep returns the first configured step above current, or 100
+// once the ramp is exhausted.
+func NextCanaryStep(service config.ServiceConfig, current int) int {
+	for _, step := range service.Deploy.Canary.EffectiveSteps() {
+		if step > current {
+			return step
+		}
+	}
+	return 100
+}
+
+// CanaryRevisionHealthy verifies a warm canary revision is still running
+// with enough healthy replicas to keep receiving traffic.
+func CanaryRevisionHealthy(actual *reconcile.ActualService, revision string, replicas int) error {
+	if actual == nil {
+		return fmt.Errorf("service is not deployed")
+	}
+	revision = strings.TrimSpace(revision)
+	found := false
+	for _, warming := range actual.WarmingRevisions {
+		if warming == revision {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("canary revision %s is no longer running", revision)
+	}
+	if actual.WarmingHealth == takod.HealthStateUnhealthy {
+		return fmt.Errorf("canary revision %s reports unhealthy containers", revision)
+	}
+	if replicas > 0 && len(actual.WarmingContainers) < replicas {
+		return fmt.Errorf("canary revision %s has %d of %d replicas running", revision, len(actual.WarmingContainers), replicas)
+	}
+	return nil
+}
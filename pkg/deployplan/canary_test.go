@@ -0,0 +1,118 @@
+package deployplan
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/redentordev/tako-cli/pkg/config"
+	"github.com/redentordev/tako-cli/pkg/reconcile"
+	"github.com/redentordev/tako-cli/pkg/takod"
+)
+
+func canaryTestService(promotion string) config.ServiceConfig {
+	return config.ServiceConfig{
+		Image:    "demo/web:v2",
+		Port:     3000,
+		Replicas: 2,
+		Proxy:    &config.ProxyConfig{Domain: "example.com"},
+		Deploy: config.DeployConfig{
+			Strategy:  config.DeployStrategyCanary,
+			Promotion: promotion,
+			Canary:    &config.DeployCanaryConfig{Steps: []int{10, 50, 100}, Hold: "1m"},
+		},
+	}
+}
+
+func TestCanaryStartTrafficWarmsNewRevisionBesideRunningOne(t *testing.T) {
+	cfg := &config.Config{Project: config.ProjectConfig{Name: "demo"}}
+	service := canaryTestService("")
+	services := map[string]config.ServiceConfig{"web": service}
+	actualState := map[string]*reconcile.ActualService{"web": {Name: "web", CurrentRevision: "rev-old"}}
+
+	if !ShouldWarmManualPromotionService("web", service, actualState) {
+		t.Fatal("canary deploy over a running revision should warm")
+	}
+	got := CanaryStartTraffic(cfg, "production", services, map[string]string{"web": "demo/web:v2"}, actualState)
+	want := ServiceRevisionID("demo", "production", "web", "demo/web:v2", service)
+	if got["web"].Revision != want || got["web"].Percent != 10 {
+		t.Fatalf("canary start = %#v, want %s at 10%%", got["web"], want)
+	}
+
+	active := ProxyActiveRevisions(cfg, "production", services, services, map[string]string{"web": "demo/web:v2"}, actualState)
+	if active["web"] != "rev-old" {
+		t.Fatalf("active revision = %q, want stable rev-old while canary ramps", active["web"])
+	}
+	if deployed := DeployedProxyActiveRevisions(services, active); deployed != nil {
+		t.Fatalf("deployed revisions = %#v, want none pruned while canary ramps", deployed)
+	}
+}
+
+func TestCanaryStartTrafficSkipsFirstDeploy(t *testing.T) {
+	cfg := &config.Config{Project: config.ProjectConfig{Name: "demo"}}
+	services := map[string]config.ServiceConfig{"web": canaryTestService("")}
+
+	if got := CanaryStartTraffic(cfg, "production", services, map[string]string{"web": "demo/web:v2"}, nil); got != nil {
+		t.Fatalf("first deploy canary = %#v, want nil", got)
+	}
+}
+
+func TestManualPromotionPendingServicesIncludesManualCanary(t *testing.T) {
+	services := map[string]config.ServiceConfig{
+		"web": canaryTestService(config.DeployPromotionManual),
+		"api": canaryTestService(""),
+	}
+	actualState := map[string]*reconcile.ActualService{
+		"web": {Name: "web", CurrentRevision: "rev-web"},
+		"api": {Name: "api", CurrentRevision: "rev-api"},
+	}
+
+	got := ManualPromotionPendingServices(services, actualState)
+	if len(got) != 1 || got[0] != "web" {
+		t.Fatalf("pending = %#v, want only manual canary web", got)
+	}
+}
+
+func TestNextCanaryStep(t *testing.T) {
+	service := canaryTestService("")
+	for current, want := range map[int]int{0: 10, 10: 50, 30: 50, 50: 100, 100: 100} {
+		if got := NextCanaryStep(service, current); got != want {
+			t.Fatalf("NextCanaryStep(%d) = %d, want %d", current, got, want)
+		}
+	}
+	service.Deploy.Canary = nil
+	if got := NextCanaryStep(service, 0); got != config.DefaultCanarySteps[0] {
+		t.Fatalf("default first step = %d, want %d", got, config.DefaultCanarySteps[0])
+	}
+}
+
+func TestCanaryRevisionHealthy(t *testing.T) {
+	actual := &reconcile.ActualService{
+		Name:              "web",
+		CurrentRevision:   "rev-old",
+		WarmingRevisions:  []string{"rev-new"},
+		WarmingContainers: []string{"c1", "c2"},
+	}
+	if err := CanaryRevisionHealthy(actual, "rev-new", 2); err != nil {
+		t.Fatalf("healthy canary returned error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(*reconcile.ActualService)
+		want   string
+	}{
+		{name: "gone", mutate: func(a *reconcile.ActualService) { a.WarmingRevisions = nil }, want: "no longer running"},
+		{name: "unhealthy", mutate: func(a *reconcile.ActualService) { a.WarmingHealth = takod.HealthStateUnhealthy }, want: "unhealthy"},
+		{name: "missing replicas", mutate: func(a *reconcile.ActualService) { a.WarmingContainers = []string{"c1"} }, want: "1 of 2 replicas"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			copy := *actual
+			tt.mutate(&copy)
+			err := CanaryRevisionHealthy(&copy, "rev-new", 2)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
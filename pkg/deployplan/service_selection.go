@@ -101,7 +101,7 @@ func BlueGreenPruneGracePeriod(services map[string]config.ServiceConfig, keepRev
 	var names []string
 	for serviceName := range keepRevisions {
 		service, ok := services[serviceName]
-		if !ok || service.Deploy.Strategy != config.DeployStrategyBlueGreen || strings.TrimSpace(service.Deploy.GracePeriod) == "" {
+		if !ok || (service.Deploy.Strategy != config.DeployStrategyBlueGreen && service.Deploy.Strategy != config.DeployStrategyCanary) || strings.TrimSpace(service.Deploy.GracePeriod) == "" {
 			continue
 		}
 		grace, err := time.ParseDuration(strings.TrimSpace(service.Deploy.GracePeriod))
@@ -132,18 +132,18 @@ func IsManualBlueGreenService(service config.ServiceConfig) bool {
 		service.Deploy.Promotion == config.DeployPromotionManual
 }
 
-// ManualPromotionPendingServices returns warm manual-promotion services awaiting promotion.
+// ManualPromotionPendingServices returns warm manual-promotion services
+// awaiting promotion, including manual canaries awaiting their next step.
 func ManualPromotionPendingServices(servicesToDeploy map[string]config.ServiceConfig, actualState map[string]*reconcile.ActualService) []string {
 	if len(servicesToDeploy) == 0 {
 		return nil
 	}
 	var pending []string
 	for serviceName, service := range servicesToDeploy {
-		if !IsManualBlueGreenService(service) {
+		if !IsManualBlueGreenService(service) && !IsManualCanaryService(service) {
 			continue
 		}
-		actual := actualState[serviceName]
-		if actual == nil || actual.CurrentRevision == "" {
+		if !ShouldWarmManualPromotionService(serviceName, service, actualState) {
 			continue
 		}
 		pending = append(pending, serviceName)
@@ -152,9 +152,10 @@ func ManualPromotionPendingServices(servicesToDeploy map[string]config.ServiceCo
 	return pending
 }
 
-// ShouldWarmManualPromotionService reports whether deploy should warm without promotion.
+// ShouldWarmManualPromotionService reports whether deploy should warm without
+// promotion: manual blue-green services and canaries with a running revision.
 func ShouldWarmManualPromotionService(serviceName string, service config.ServiceConfig, actualState map[string]*reconcile.ActualService) bool {
-	if !IsManualBlueGreenService(service) {
+	if !IsWarmPromotionService(service) {
 		return false
 	}
 	actual := actualState[serviceName]
@@ -1,7 +1,8 @@
 package engine
 
 // KindActionResult identifies the minimal acknowledgement document emitted
-// by node-fanout maintenance operations (maintenance, live, cleanup).
+// by node-fanout maintenance operations (maintenance, live, cleanup,
+// secrets rotate).
 const KindActionResult = "ActionResult"
 
 // Action identifiers carried in ActionResult.Action.
@@ -9,6 +10,7 @@ const (
 	ActionMaintenanceEnable  = "maintenance.enable"
 	ActionMaintenanceDisable = "maintenance.disable"
 	ActionCleanup            = "cleanup"
+	ActionSecretsRotate      = "secrets.rotate"
 )
 
 // Action outcomes.
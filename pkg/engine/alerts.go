@@ -0,0 +1,36 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/takod"
+)
+
+// KindAlertsResult identifies a serialized alerts document.
+const KindAlertsResult = "AlertsResult"
+
+// AlertsNodeResult is one node's view of the environment's alert rules.
+// Alerts and Silences reuse the takod /v1/alerts schema.
+type AlertsNodeResult struct {
+	Server   string               `json:"server"`
+	Host     string               `json:"host,omitempty"`
+	Alerts   []takod.AlertStatus  `json:"alerts"`
+	Silences []takod.AlertSilence `json:"silences"`
+	Error    string               `json:"error,omitempty"`
+}
+
+// AlertsResult is the serializable outcome of `tako alerts` and `tako
+// alerts silence`. Action is status, silence, or unsilence; Rule is empty
+// when a silence covers every rule. All nodes failing exits 1; a partial
+// result exits 6.
+type AlertsResult struct {
+	APIVersion  string             `json:"apiVersion"`
+	Kind        string             `json:"kind"`
+	Project     string             `json:"project"`
+	Environment string             `json:"environment"`
+	Action      string             `json:"action"`
+	Rule        string             `json:"rule,omitempty"`
+	Until       *time.Time         `json:"until,omitempty"`
+	Nodes       []AlertsNodeResult `json:"nodes"`
+	Error       string             `json:"error,omitempty"`
+}
@@ -0,0 +1,173 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/config"
+	"github.com/redentordev/tako-cli/pkg/deployplan"
+	"github.com/redentordev/tako-cli/pkg/takoapi/events"
+	"github.com/redentordev/tako-cli/pkg/takod"
+)
+
+// trafficAnalysisInterval is how often a grace period or canary hold checks
+// the traffic served so far, so a regression rolls back without waiting for
+// the window to end.
+var trafficAnalysisInterval = 30 * time.Second
+
+// TrafficAnalyzer reports per-revision proxy traffic for a service.
+type TrafficAnalyzer interface {
+	AnalyzeTakodProxyTraffic(serviceName string, since time.Time) (map[string]takod.RevisionTrafficStats, error)
+}
+
+// BlueGreenAnalysisOperations is the runtime surface needed to analyze a
+// promoted blue_green revision and roll it back during the grace period.
+type BlueGreenAnalysisOperations interface {
+	ProxyReconciler
+	RevisionPruner
+	TrafficAnalyzer
+	ActivateTakodServiceRevision(serviceName string, service *config.ServiceConfig, imageRef string) error
+}
+
+// AnalysisRollbackError reports services whose promoted revision breached
+// deploy.analysis thresholds and was rolled back.
+type AnalysisRollbackError struct {
+	Services []string
+	Err      error
+}
+
+func (e *AnalysisRollbackError) Error() string {
+	return fmt.Sprintf("traffic analysis rolled back %s: %v", strings.Join(e.Services, ", "), e.Err)
+}
+
+func (e *AnalysisRollbackError) Unwrap() error {
+	return e.Err
+}
+
+// AnalyzeRevisionTraffic checks the traffic revision served since the given
+// time against the service's deploy.analysis thresholds.
+func (e *Engine) AnalyzeRevisionTraffic(analyzer TrafficAnalyzer, serviceName string, service config.ServiceConfig, revision string, since time.Time) error {
+	return e.analyzeRevisionTraffic(analyzer, serviceName, service, revision, since, true)
+}
+
+// analyzeRevisionTraffic is AnalyzeRevisionTraffic for a check made while a
+// window is still open (final false): it reports only a threshold breach and
+// leaves an unreachable analyzer and the summary line to the final check.
+func (e *Engine) analyzeRevisionTraffic(analyzer TrafficAnalyzer, serviceName string, service config.ServiceConfig, revision string, since time.Time, final bool) error {
+	if service.Deploy.Analysis == nil {
+		return nil
+	}
+	stats, err := analyzer.AnalyzeTakodProxyTraffic(serviceName, since)
+	if err != nil {
+		if !final {
+			return nil
+		}
+		return fmt.Errorf("traffic analysis unavailable: %w", err)
+	}
+	revisionStats := stats[revision]
+	revisionStats.Revision = revision
+	if final {
+		e.info(events.TypeLogLine, events.PhaseDeploy, fmt.Sprintf("-> Traffic analysis for %s: %d requests, %.2f%% errors, p95 %.0fms, p99 %.0fms\n", serviceName, revisionStats.Requests, revisionStats.ErrorRate, revisionStats.P95Ms, revisionStats.P99Ms))
+	}
+	return deployplan.EvaluateTrafficAnalysis(service.Deploy.Analysis, revisionStats)
+}
+
+// watchTrafficWindow sleeps through window in trafficAnalysisInterval steps
+// and calls check after each one, reporting whether the window has ended.
+// It returns the first error check returns.
+func watchTrafficWindow(window time.Duration, sleep func(time.Duration), check func(final bool) error) error {
+	for waited := time.Duration(0); ; {
+		if step := min(trafficAnalysisInterval, window-waited); step > 0 {
+			sleep(step)
+			waited += step
+		}
+		final := waited >= window
+		if err := check(final); err != nil || final {
+			return err
+		}
+	}
+}
+
+// PruneRevisionsAfterAnalysis behaves like PruneRevisionsAfterGrace, but
+// analyzes the traffic each rollback-eligible service has served every
+// trafficAnalysisInterval during the grace period and once more at its end.
+// A service that breaches its thresholds is switched back to its rollback
+// target straight away, and that target is kept instead of the promoted
+// revision.
+func (e *Engine) PruneRevisionsAfterAnalysis(ops BlueGreenAnalysisOperations, services map[string]config.ServiceConfig, activeRevisions map[string]string, keepRevisions map[string]string, rollbacks map[string]deployplan.RollbackTarget, sleep func(time.Duration)) error {
+	if len(rollbacks) == 0 {
+		return e.PruneRevisionsAfterGrace(ops, services, keepRevisions, sleep)
+	}
+	since := time.Now()
+	grace, graceNames, err := deployplan.BlueGreenPruneGracePeriod(services, keepRevisions)
+	if err != nil {
+		return err
+	}
+	e.announceBlueGreenGrace(grace, graceNames)
+	if sleep == nil {
+		sleep = GraceSleep
+	}
+
+	keep := make(map[string]string, len(keepRevisions))
+	for serviceName, revision := range keepRevisions {
+		keep[serviceName] = revision
+	}
+	pending := make([]string, 0, len(rollbacks))
+	for serviceName := range rollbacks {
+		pending = append(pending, serviceName)
+	}
+	sort.Strings(pending)
+
+	var rolledBack []string
+	var causes []error
+	if err := watchTrafficWindow(grace, sleep, func(final bool) error {
+		healthy := pending[:0]
+		for _, serviceName := range pending {
+			cause := e.analyzeRevisionTraffic(ops, serviceName, services[serviceName], keep[serviceName], since, final)
+			if cause == nil {
+				healthy = append(healthy, serviceName)
+				continue
+			}
+			target := rollbacks[serviceName]
+			if err := e.rollbackBlueGreen(ops, services, activeRevisions, serviceName, target, cause); err != nil {
+				return err
+			}
+			keep[serviceName] = target.Revision
+			rolledBack = append(rolledBack, serviceName)
+			causes = append(causes, fmt.Errorf("%s: %w", serviceName, cause))
+		}
+		pending = healthy
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := ops.PruneTakodServiceRevisions(services, keep); err != nil {
+		return err
+	}
+	if len(rolledBack) > 0 {
+		return &AnalysisRollbackError{Services: rolledBack, Err: errors.Join(causes...)}
+	}
+	return nil
+}
+
+func (e *Engine) rollbackBlueGreen(ops BlueGreenAnalysisOperations, services map[string]config.ServiceConfig, activeRevisions map[string]string, serviceName string, target deployplan.RollbackTarget, cause error) error {
+	e.emit(events.Event{
+		Type:    events.TypeWarning,
+		Phase:   events.PhaseDeploy,
+		Level:   events.LevelWarn,
+		Service: serviceName,
+		Message: fmt.Sprintf("  ✗ %s failed traffic analysis: %v; rolling back to %s\n", serviceName, cause, target.Revision),
+	})
+	service := services[serviceName]
+	if err := ops.ActivateTakodServiceRevision(serviceName, &service, target.Image); err != nil {
+		return fmt.Errorf("%s failed traffic analysis (%v) and revision %s could not be reactivated: %w", serviceName, cause, target.Revision, err)
+	}
+	activeRevisions[serviceName] = target.Revision
+	if err := ReconcileProxy(ops, services, activeRevisions); err != nil {
+		return fmt.Errorf("%s failed traffic analysis (%v) and traffic could not be restored: %w", serviceName, cause, err)
+	}
+	return nil
+}
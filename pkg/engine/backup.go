@@ -12,6 +12,7 @@ const (
 	BackupActionRestore = "restore"
 	BackupActionDelete  = "delete"
 	BackupActionCleanup = "cleanup"
+	BackupActionVerify  = "verify"
 )
 
 // BackupNodeOutcome reports one node's backup-operation result. Backups
@@ -25,11 +26,15 @@ type BackupNodeOutcome struct {
 	Deleted int `json:"deleted,omitempty"`
 	// Skipped lists volumes not present on this node during create.
 	Skipped []string `json:"skipped,omitempty"`
-	Error   string   `json:"error,omitempty"`
+	// Verifications lists restore drill outcomes for the verify action.
+	Verifications []takod.BackupVerification `json:"verifications,omitempty"`
+	// Recovery describes the new volume written by a point-in-time restore.
+	Recovery *takod.PITRRestoreResponse `json:"recovery,omitempty"`
+	Error    string                     `json:"error,omitempty"`
 }
 
 // BackupResult is the serializable outcome of every `tako backup` action:
-// list, create (single volume or --all), restore, delete, and cleanup.
+// list, create (single volume or --all), restore, delete, cleanup, and verify.
 type BackupResult struct {
 	APIVersion  string              `json:"apiVersion"`
 	Kind        string              `json:"kind"`
@@ -0,0 +1,144 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/config"
+	"github.com/redentordev/tako-cli/pkg/deployplan"
+	"github.com/redentordev/tako-cli/pkg/reconcile"
+	"github.com/redentordev/tako-cli/pkg/takoapi/events"
+)
+
+// CanaryHoldSleep pauses between automatic canary steps; tests may replace it.
+var CanaryHoldSleep = time.Sleep
+
+// CanaryProxyReconciler reconciles proxy routes with weighted canary upstreams.
+type CanaryProxyReconciler interface {
+	ProxyReconciler
+	ReconcileTakodProxyWithCanaries(services map[string]config.ServiceConfig, activeRevisions map[string]string, canaries map[string]deployplan.CanaryTraffic) error
+}
+
+// ReconcileCanaryProxy reconciles proxy routes, splitting traffic for any
+// listed canaries and otherwise behaving like ReconcileProxy.
+func ReconcileCanaryProxy(deploy CanaryProxyReconciler, services map[string]config.ServiceConfig, activeRevisions map[string]string, canaries map[string]deployplan.CanaryTraffic) error {
+	if len(canaries) == 0 {
+		return ReconcileProxy(deploy, services, activeRevisions)
+	}
+	return deploy.ReconcileTakodProxyWithCanaries(services, activeRevisions, canaries)
+}
+
+// CanaryRampOperations is the runtime surface an automatic canary ramp drives.
+type CanaryRampOperations interface {
+	CanaryProxyReconciler
+	RevisionPruner
+	TrafficAnalyzer
+	ActivateTakodServiceRevision(serviceName string, service *config.ServiceConfig, imageRef string) error
+}
+
+// CanaryRamp describes one automatic canary ramp from its first step to full
+// promotion. ActualState reports the current runtime state for health checks.
+type CanaryRamp struct {
+	Services        map[string]config.ServiceConfig
+	ActiveRevisions map[string]string
+	ServiceName     string
+	Canary          deployplan.CanaryTraffic
+	Image           string
+	ActualState     func() (map[string]*reconcile.ActualService, error)
+	Sleep           func(time.Duration)
+}
+
+// RunCanaryRamp holds each canary step, checks the canary revision's health
+// and, with deploy.analysis, the traffic it served during the hold, then
+// shifts traffic to the next step until the canary is activated. Traffic is
+// also checked every trafficAnalysisInterval of a hold, so a breach ends it
+// early. A failed check returns all traffic to the stable revision and prunes
+// the canary.
+func (e *Engine) RunCanaryRamp(ops CanaryRampOperations, ramp CanaryRamp) error {
+	service := ramp.Services[ramp.ServiceName]
+	hold, err := time.ParseDuration(strings.TrimSpace(service.Deploy.Canary.Hold))
+	if err != nil {
+		return fmt.Errorf("service %s: invalid deploy.canary.hold: %w", ramp.ServiceName, err)
+	}
+	sleep := ramp.Sleep
+	if sleep == nil {
+		sleep = CanaryHoldSleep
+	}
+	stable := ramp.ActiveRevisions[ramp.ServiceName]
+	canary := ramp.Canary
+	for {
+		e.info(events.TypeLogLine, events.PhaseDeploy, fmt.Sprintf("-> Holding canary %s at %d%% for %s\n", ramp.ServiceName, canary.Percent, hold))
+		stepStarted := time.Now()
+		err := watchTrafficWindow(hold, sleep, func(final bool) error {
+			if final {
+				return nil
+			}
+			return e.analyzeRevisionTraffic(ops, ramp.ServiceName, service, canary.Revision, stepStarted, false)
+		})
+		if err == nil {
+			var actualState map[string]*reconcile.ActualService
+			actualState, err = ramp.ActualState()
+			if err == nil {
+				err = deployplan.CanaryRevisionHealthy(actualState[ramp.ServiceName], canary.Revision, service.Replicas)
+			}
+		}
+		if err == nil {
+			err = e.AnalyzeRevisionTraffic(ops, ramp.ServiceName, service, canary.Revision, stepStarted)
+		}
+		if err != nil {
+			return e.abortCanary(ops, ramp, stable, err)
+		}
+
+		next := deployplan.NextCanaryStep(service, canary.Percent)
+		if next < 100 {
+			canary.Percent = next
+			if err := ReconcileCanaryProxy(ops, ramp.Services, ramp.ActiveRevisions, map[string]deployplan.CanaryTraffic{ramp.ServiceName: canary}); err != nil {
+				return fmt.Errorf("failed to shift canary traffic for %s: %w", ramp.ServiceName, err)
+			}
+			e.info(events.TypeLogLine, events.PhaseDeploy, fmt.Sprintf("✓ Canary %s now receives %d%% of traffic\n", ramp.ServiceName, canary.Percent))
+			continue
+		}
+
+		if err := ops.ActivateTakodServiceRevision(ramp.ServiceName, &service, ramp.Image); err != nil {
+			return fmt.Errorf("failed to activate canary revision for %s: %w", ramp.ServiceName, err)
+		}
+		ramp.ActiveRevisions[ramp.ServiceName] = canary.Revision
+		if err := ReconcileCanaryProxy(ops, ramp.Services, ramp.ActiveRevisions, map[string]deployplan.CanaryTraffic{ramp.ServiceName: {}}); err != nil {
+			return fmt.Errorf("failed to promote canary proxy route for %s: %w", ramp.ServiceName, err)
+		}
+		e.info(events.TypeLogLine, events.PhaseDeploy, fmt.Sprintf("✓ Canary %s promoted to 100%% of traffic\n", ramp.ServiceName))
+		return e.PruneRevisionsAfterGrace(ops, map[string]config.ServiceConfig{ramp.ServiceName: service}, map[string]string{ramp.ServiceName: canary.Revision}, GraceSleep)
+	}
+}
+
+func (e *Engine) abortCanary(ops CanaryRampOperations, ramp CanaryRamp, stable string, cause error) error {
+	e.emit(events.Event{
+		Type:    events.TypeWarning,
+		Phase:   events.PhaseDeploy,
+		Level:   events.LevelWarn,
+		Service: ramp.ServiceName,
+		Message: fmt.Sprintf("  ✗ Canary %s failed: %v; returning traffic to %s\n", ramp.ServiceName, cause, stable),
+	})
+	if err := ReconcileCanaryProxy(ops, ramp.Services, ramp.ActiveRevisions, map[string]deployplan.CanaryTraffic{ramp.ServiceName: {}}); err != nil {
+		return fmt.Errorf("canary %s failed (%v) and traffic could not be restored: %w", ramp.ServiceName, cause, err)
+	}
+	service := ramp.Services[ramp.ServiceName]
+	if err := ops.PruneTakodServiceRevisions(map[string]config.ServiceConfig{ramp.ServiceName: service}, map[string]string{ramp.ServiceName: stable}); err != nil {
+		return fmt.Errorf("canary %s failed (%v) and its revision could not be pruned: %w", ramp.ServiceName, cause, err)
+	}
+	return fmt.Errorf("canary %s rolled back: %w", ramp.ServiceName, cause)
+}
+
+// automaticCanaryServices returns canary services that ramp without promote.
+func automaticCanaryServices(services map[string]config.ServiceConfig, canaries map[string]deployplan.CanaryTraffic) []string {
+	var names []string
+	for serviceName := range canaries {
+		if !deployplan.IsManualCanaryService(services[serviceName]) {
+			names = append(names, serviceName)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,251 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/config"
+	"github.com/redentordev/tako-cli/pkg/deployplan"
+	"github.com/redentordev/tako-cli/pkg/reconcile"
+	"github.com/redentordev/tako-cli/pkg/takod"
+)
+
+type recordingCanaryOps struct {
+	steps     []string
+	activated string
+	kept      map[string]string
+	traffic   map[string]takod.RevisionTrafficStats
+}
+
+func (o *recordingCanaryOps) ReconcileTakodProxy(services map[string]config.ServiceConfig) error {
+	o.steps = append(o.steps, "plain")
+	return nil
+}
+
+func (o *recordingCanaryOps) ReconcileTakodProxyWithActiveRevisions(services map[string]config.ServiceConfig, activeRevisions map[string]string) error {
+	o.steps = append(o.steps, "active="+activeRevisions["web"])
+	return nil
+}
+
+func (o *recordingCanaryOps) ReconcileTakodProxyWithCanaries(services map[string]config.ServiceConfig, activeRevisions map[string]string, canaries map[string]deployplan.CanaryTraffic) error {
+	if canaries["web"].Percent == 0 {
+		o.steps = append(o.steps, "active="+activeRevisions["web"])
+		return nil
+	}
+	o.steps = append(o.steps, fmt.Sprintf("active=%s canary=%s@%d", activeRevisions["web"], canaries["web"].Revision, canaries["web"].Percent))
+	return nil
+}
+
+func (o *recordingCanaryOps) PruneTakodServiceRevisions(services map[string]config.ServiceConfig, keepRevisions map[string]string) error {
+	o.kept = keepRevisions
+	return nil
+}
+
+func (o *recordingCanaryOps) ActivateTakodServiceRevision(serviceName string, service *config.ServiceConfig, imageRef string) error {
+	o.activated = imageRef
+	return nil
+}
+
+func (o *recordingCanaryOps) AnalyzeTakodProxyTraffic(serviceName string, since time.Time) (map[string]takod.RevisionTrafficStats, error) {
+	return o.traffic, nil
+}
+
+func canaryRampForTest(actual *reconcile.ActualService) CanaryRamp {
+	service := config.ServiceConfig{
+		Replicas: 1,
+		Deploy: config.DeployConfig{
+			Strategy: config.DeployStrategyCanary,
+			Canary:   &config.DeployCanaryConfig{Steps: []int{10, 50, 100}, Hold: "1m"},
+		},
+	}
+	return CanaryRamp{
+		Services:        map[string]config.ServiceConfig{"web": service},
+		ActiveRevisions: map[string]string{"web": "rev-old"},
+		ServiceName:     "web",
+		Canary:          deployplan.CanaryTraffic{Revision: "rev-new", Percent: 10},
+		Image:           "demo/web:v2",
+		ActualState: func() (map[string]*reconcile.ActualService, error) {
+			return map[string]*reconcile.ActualService{"web": actual}, nil
+		},
+		Sleep: func(time.Duration) {},
+	}
+}
+
+func TestRunCanaryRampPromotesThroughSteps(t *testing.T) {
+	ops := &recordingCanaryOps{}
+	actual := &reconcile.ActualService{Name: "web", WarmingRevisions: []string{"rev-new"}, WarmingContainers: []string{"c1"}}
+
+	if err := New(Options{}).RunCanaryRamp(ops, canaryRampForTest(actual)); err != nil {
+		t.Fatalf("RunCanaryRamp returned error: %v", err)
+	}
+	want := "active=rev-old canary=rev-new@50,active=rev-new"
+	if got := strings.Join(ops.steps, ","); got != want {
+		t.Fatalf("proxy steps = %s, want %s", got, want)
+	}
+	if ops.activated != "demo/web:v2" {
+		t.Fatalf("activated image = %q, want demo/web:v2", ops.activated)
+	}
+	if ops.kept["web"] != "rev-new" {
+		t.Fatalf("kept revision = %q, want rev-new", ops.kept["web"])
+	}
+}
+
+func TestRunCanaryRampRollsBackUnhealthyCanary(t *testing.T) {
+	ops := &recordingCanaryOps{}
+	actual := &reconcile.ActualService{Name: "web"}
+
+	err := New(Options{}).RunCanaryRamp(ops, canaryRampForTest(actual))
+	if err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("error = %v, want canary rollback", err)
+	}
+	if got := strings.Join(ops.steps, ","); got != "active=rev-old" {
+		t.Fatalf("proxy steps = %s, want traffic restored to rev-old", got)
+	}
+	if ops.activated != "" {
+		t.Fatalf("activated %q after failed canary", ops.activated)
+	}
+	if ops.kept["web"] != "rev-old" {
+		t.Fatalf("kept revision = %q, want rev-old", ops.kept["web"])
+	}
+}
+
+func TestRunCanaryRampRollsBackOnTrafficAnalysis(t *testing.T) {
+	maxErrorRate := 1.0
+	ops := &recordingCanaryOps{traffic: map[string]takod.RevisionTrafficStats{
+		"rev-new": {Revision: "rev-new", Requests: 200, Errors: 10, ErrorRate: 5},
+	}}
+	actual := &reconcile.ActualService{Name: "web", WarmingRevisions: []string{"rev-new"}, WarmingContainers: []string{"c1"}}
+	ramp := canaryRampForTest(actual)
+	web := ramp.Services["web"]
+	web.Deploy.Analysis = &config.DeployAnalysisConfig{MaxErrorRate: &maxErrorRate}
+	ramp.Services["web"] = web
+
+	err := New(Options{}).RunCanaryRamp(ops, ramp)
+	if err == nil || !strings.Contains(err.Error(), "rolled back") || !strings.Contains(err.Error(), "error rate 5.00%") {
+		t.Fatalf("error = %v, want analysis rollback", err)
+	}
+	if got := strings.Join(ops.steps, ","); got != "active=rev-old" {
+		t.Fatalf("proxy steps = %s, want traffic restored to rev-old", got)
+	}
+	if ops.kept["web"] != "rev-old" {
+		t.Fatalf("kept revision = %q, want rev-old", ops.kept["web"])
+	}
+}
+
+func TestPruneRevisionsAfterAnalysisRollsBackBlueGreen(t *testing.T) {
+	services := map[string]config.ServiceConfig{"web": {
+		Replicas: 1,
+		Deploy: config.DeployConfig{
+			Strategy:    config.DeployStrategyBlueGreen,
+			GracePeriod: "1m",
+			Analysis:    &config.DeployAnalysisConfig{MaxP99Latency: "500ms"},
+		},
+	}}
+	rollbacks := map[string]deployplan.RollbackTarget{"web": {Revision: "rev-old", Image: "demo/web:v1"}}
+
+	healthy := &recordingCanaryOps{traffic: map[string]takod.RevisionTrafficStats{"rev-new": {Requests: 100, P99Ms: 120}}}
+	active := map[string]string{"web": "rev-new"}
+	if err := New(Options{}).PruneRevisionsAfterAnalysis(healthy, services, active, map[string]string{"web": "rev-new"}, rollbacks, func(time.Duration) {}); err != nil {
+		t.Fatalf("healthy analysis returned error: %v", err)
+	}
+	if healthy.kept["web"] != "rev-new" || healthy.activated != "" {
+		t.Fatalf("healthy analysis kept %q and activated %q", healthy.kept["web"], healthy.activated)
+	}
+
+	slow := &recordingCanaryOps{traffic: map[string]takod.RevisionTrafficStats{"rev-new": {Requests: 100, P99Ms: 900}}}
+	active = map[string]string{"web": "rev-new"}
+	err := New(Options{}).PruneRevisionsAfterAnalysis(slow, services, active, map[string]string{"web": "rev-new"}, rollbacks, func(time.Duration) {})
+	var rollback *AnalysisRollbackError
+	if !errors.As(err, &rollback) || rollback.Services[0] != "web" {
+		t.Fatalf("error = %v, want analysis rollback", err)
+	}
+	if slow.activated != "demo/web:v1" || active["web"] != "rev-old" || slow.kept["web"] != "rev-old" {
+		t.Fatalf("rollback activated %q, active %q, kept %q", slow.activated, active["web"], slow.kept["web"])
+	}
+	if got := strings.Join(slow.steps, ","); got != "active=rev-old" {
+		t.Fatalf("proxy steps = %s, want traffic restored to rev-old", got)
+	}
+}
+
+// sequencedTrafficOps answers each traffic analysis with the next entry of
+// traffic, repeating the last one, and records how long the caller had slept
+// when it reactivated a revision.
+type sequencedTrafficOps struct {
+	recordingCanaryOps
+	traffic        []map[string]takod.RevisionTrafficStats
+	analyses       int
+	slept          time.Duration
+	activatedAfter time.Duration
+}
+
+func (o *sequencedTrafficOps) AnalyzeTakodProxyTraffic(serviceName string, since time.Time) (map[string]takod.RevisionTrafficStats, error) {
+	traffic := o.traffic[min(o.analyses, len(o.traffic)-1)]
+	o.analyses++
+	return traffic, nil
+}
+
+func (o *sequencedTrafficOps) ActivateTakodServiceRevision(serviceName string, service *config.ServiceConfig, imageRef string) error {
+	o.activatedAfter = o.slept
+	return o.recordingCanaryOps.ActivateTakodServiceRevision(serviceName, service, imageRef)
+}
+
+func (o *sequencedTrafficOps) sleep(duration time.Duration) {
+	o.slept += duration
+}
+
+func TestPruneRevisionsAfterAnalysisRollsBackDuringTheGracePeriod(t *testing.T) {
+	services := map[string]config.ServiceConfig{"web": {
+		Replicas: 1,
+		Deploy: config.DeployConfig{
+			Strategy:    config.DeployStrategyBlueGreen,
+			GracePeriod: "5m",
+			Analysis:    &config.DeployAnalysisConfig{MaxP99Latency: "500ms"},
+		},
+	}}
+	rollbacks := map[string]deployplan.RollbackTarget{"web": {Revision: "rev-old", Image: "demo/web:v1"}}
+	ops := &sequencedTrafficOps{traffic: []map[string]takod.RevisionTrafficStats{
+		{"rev-new": {Requests: 100, P99Ms: 120}},
+		{"rev-new": {Requests: 200, P99Ms: 900}},
+	}}
+	active := map[string]string{"web": "rev-new"}
+
+	err := New(Options{}).PruneRevisionsAfterAnalysis(ops, services, active, map[string]string{"web": "rev-new"}, rollbacks, ops.sleep)
+	var rollback *AnalysisRollbackError
+	if !errors.As(err, &rollback) || rollback.Services[0] != "web" {
+		t.Fatalf("error = %v, want analysis rollback", err)
+	}
+	if ops.activated != "demo/web:v1" || ops.activatedAfter != 2*trafficAnalysisInterval {
+		t.Fatalf("activated %q after %s, want demo/web:v1 after the second check", ops.activated, ops.activatedAfter)
+	}
+	if ops.analyses != 2 || ops.slept != 5*time.Minute || ops.kept["web"] != "rev-old" {
+		t.Fatalf("analyses = %d, slept %s, kept %q", ops.analyses, ops.slept, ops.kept["web"])
+	}
+}
+
+func TestRunCanaryRampEndsAHoldEarlyOnTrafficAnalysis(t *testing.T) {
+	maxErrorRate := 1.0
+	ops := &sequencedTrafficOps{traffic: []map[string]takod.RevisionTrafficStats{
+		{"rev-new": {Requests: 200, Errors: 10, ErrorRate: 5}},
+	}}
+	actual := &reconcile.ActualService{Name: "web", WarmingRevisions: []string{"rev-new"}, WarmingContainers: []string{"c1"}}
+	ramp := canaryRampForTest(actual)
+	web := ramp.Services["web"]
+	web.Deploy.Canary.Hold = "10m"
+	web.Deploy.Analysis = &config.DeployAnalysisConfig{MaxErrorRate: &maxErrorRate}
+	ramp.Services["web"] = web
+	ramp.Sleep = ops.sleep
+
+	err := New(Options{}).RunCanaryRamp(ops, ramp)
+	if err == nil || !strings.Contains(err.Error(), "rolled back") || !strings.Contains(err.Error(), "error rate 5.00%") {
+		t.Fatalf("error = %v, want analysis rollback", err)
+	}
+	if ops.slept != trafficAnalysisInterval || ops.analyses != 1 {
+		t.Fatalf("slept %s over %d analyses, want the hold cut short after the first check", ops.slept, ops.analyses)
+	}
+	if ops.kept["web"] != "rev-old" {
+		t.Fatalf("kept revision = %q, want rev-old", ops.kept["web"])
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/redentordev/tako-cli/pkg/takoapi/events"
 	"github.com/redentordev/tako-cli/pkg/takod"
 	"github.com/redentordev/tako-cli/pkg/takodstate"
+	"github.com/redentordev/tako-cli/pkg/telemetry"
 )
 
 // StateAutoSyncFunc refreshes local deployment state from the remote mesh
@@ -243,7 +244,7 @@ func (e *Engine) PlanDeploy(ctx context.Context, req DeployRequest) (*DeploySess
 			e.RegisterSecret(value)
 		}
 	}
-	e.registerServiceSecretValues(session.envName, allServices)
+	e.registerServiceSecretValues(cfg, session.envName, allServices)
 	for _, server := range cfg.Servers {
 		e.RegisterSecret(server.Password)
 	}
@@ -593,8 +594,17 @@ func (e *Engine) warnRetiredDeploymentServers(localStateMgr *localstate.Manager,
 }
 
 // Apply executes a planned deployment. The caller is responsible for
-// confirmation gating; Apply runs the plan unconditionally.
+// confirmation gating; Apply runs the plan unconditionally. The deploy is
+// one trace: a root span here, a span per service, and takod's spans on
+// each node beneath them.
 func (s *DeploySession) Apply(ctx context.Context) (*DeployResult, error) {
+	ctx, span := telemetry.TraceDeployment(ctx, s.cfg.Project.Name, s.envName)
+	result, err := s.apply(ctx)
+	telemetry.EndSpan(span, err)
+	return result, err
+}
+
+func (s *DeploySession) apply(ctx context.Context) (*DeployResult, error) {
 	if s.closed {
 		return nil, fmt.Errorf("deploy session is closed")
 	}
@@ -813,13 +823,27 @@ func (s *DeploySession) Apply(ctx context.Context) (*DeployResult, error) {
 		return nil, fmt.Errorf("failed to record started deployment state before applying mutations: %w", err)
 	}
 	e.debug(events.TypeLogLine, events.PhaseState, fmt.Sprintf("→ Recorded in-progress deployment state (%s)\n", deployment.ID))
-	if err := buildSharedImages(s.deployer, cfg, envName, s.buildTag, servicesToDeploy, req.SkipBuild); err != nil {
-		err = fmt.Errorf("%s", e.redactor.Redact(err.Error()))
+	if err := s.deployer.ApplyTracing(); err != nil {
+		e.emit(events.Event{Type: events.TypeDeployFailed, Phase: events.PhaseDeploy, Level: events.LevelError, Message: fmt.Sprintf("  ✗ tracing apply failed: %v\n", err)})
 		deploymentFailed = true
-		deploymentError = err
+		deploymentError = fmt.Errorf("tracing apply failed: %w", err)
 		deployment.Status = remotestate.StatusFailed
 		deployment.Error = err.Error()
 	}
+	if !deploymentFailed {
+		buildCtx, buildSpan := telemetry.StartSpan(ctx, "deploy.build")
+		s.deployer.SetBaseContext(buildCtx)
+		err := buildSharedImages(s.deployer, cfg, envName, s.buildTag, servicesToDeploy, req.SkipBuild)
+		s.deployer.SetBaseContext(ctx)
+		telemetry.EndSpan(buildSpan, err)
+		if err != nil {
+			err = fmt.Errorf("%s", e.redactor.Redact(err.Error()))
+			deploymentFailed = true
+			deploymentError = err
+			deployment.Status = remotestate.StatusFailed
+			deployment.Error = err.Error()
+		}
+	}
 
 	// Deploy each service through takod placement in dependency order.
 	for _, serviceName := range deploymentOrder {
@@ -865,7 +889,11 @@ func (s *DeploySession) Apply(ctx context.Context) (*DeployResult, error) {
 					}
 				}
 			}
+			serviceCtx, serviceSpan := telemetry.TraceDeploy(ctx, cfg.Project.Name, serviceName, envName)
+			s.deployer.SetBaseContext(serviceCtx)
 			runResult, runErr := s.deployer.RunDeployStepOnNodes(serviceName, &service, resolvedImage, pullImage, availableImageNodes)
+			s.deployer.SetBaseContext(ctx)
+			telemetry.EndSpan(serviceSpan, runErr)
 			outcome := runOutcome(runResult)
 			if runErr != nil {
 				result.Services = append(result.Services, ServiceOutcome{Name: serviceName, Image: resolvedImage, Action: OutcomeFailed, Error: runErr.Error(), Run: outcome})
@@ -891,6 +919,8 @@ func (s *DeploySession) Apply(ctx context.Context) (*DeployResult, error) {
 
 		warmed := deployplan.ShouldWarmManualPromotionService(serviceName, service, actualState)
 		deployErr := error(nil)
+		serviceCtx, serviceSpan := telemetry.TraceDeploy(ctx, cfg.Project.Name, serviceName, envName)
+		s.deployer.SetBaseContext(serviceCtx)
 		if service.SharedBuildHash != "" {
 			deployErr = s.deployer.DeployPreparedServiceTakod(serviceName, &service, fullImageName, warmed)
 		} else if warmed {
@@ -898,6 +928,8 @@ func (s *DeploySession) Apply(ctx context.Context) (*DeployResult, error) {
 		} else {
 			deployErr = s.deployer.DeployServiceTakod(serviceName, &service, fullImageName)
 		}
+		s.deployer.SetBaseContext(ctx)
+		telemetry.EndSpan(serviceSpan, deployErr)
 		if deployErr != nil {
 			e.emit(events.Event{
 				Type:    events.TypeDeployServiceFailed,
@@ -923,12 +955,16 @@ func (s *DeploySession) Apply(ctx context.Context) (*DeployResult, error) {
 			Data:    map[string]any{"image": fullImageName},
 		})
 		outcomeAction := OutcomeDeployed
-		if warmed {
+		if warmed && (!deployplan.IsCanaryService(service) || deployplan.IsManualCanaryService(service)) {
 			outcomeAction = OutcomeWarmed
 		}
 		result.Services = append(result.Services, ServiceOutcome{Name: serviceName, Image: fullImageName, Action: outcomeAction, Replicas: service.Replicas, Release: releaseOutcomeFor(s.deployer, serviceName)})
 
 		// Save service state.
+		secretRefs, err := historySecretRefs(envName, service)
+		if err != nil {
+			e.warn(events.PhaseState, fmt.Sprintf("  ⚠️  Could not record secret versions for %s: %v\n", serviceName, err))
+		}
 		deployment.Services[serviceName] = remotestate.ServiceState{
 			Name:             serviceName,
 			Image:            fullImageName,
@@ -936,6 +972,7 @@ func (s *DeploySession) Apply(ctx context.Context) (*DeployResult, error) {
 			SharedBuildHash:  service.SharedBuildHash,
 			FilesContentHash: service.FilesContentHash,
 			Files:            historyServiceFiles(service.Files),
+			Secrets:          secretRefs,
 			Port:             service.Port,
 			Replicas:         service.Replicas,
 			Env:              RedactedEnvKeys(service.Env),
@@ -958,6 +995,46 @@ func (s *DeploySession) Apply(ctx context.Context) (*DeployResult, error) {
 		}
 	}
 
+	if !deploymentFailed {
+		if err := s.deployer.ApplyLogShipping(); err != nil {
+			e.emit(events.Event{Type: events.TypeDeployFailed, Phase: events.PhaseDeploy, Level: events.LevelError, Message: fmt.Sprintf("  ✗ log shipping apply failed: %v\n", err)})
+			deploymentFailed = true
+			deploymentError = fmt.Errorf("log shipping apply failed: %w", err)
+			deployment.Status = remotestate.StatusFailed
+			deployment.Error = err.Error()
+		}
+	}
+
+	if !deploymentFailed {
+		if err := s.deployer.ApplyMetricsExporter(); err != nil {
+			e.emit(events.Event{Type: events.TypeDeployFailed, Phase: events.PhaseDeploy, Level: events.LevelError, Message: fmt.Sprintf("  ✗ metrics exporter apply failed: %v\n", err)})
+			deploymentFailed = true
+			deploymentError = fmt.Errorf("metrics exporter apply failed: %w", err)
+			deployment.Status = remotestate.StatusFailed
+			deployment.Error = err.Error()
+		}
+	}
+
+	if !deploymentFailed {
+		if err := s.deployer.ApplyAlertRules(); err != nil {
+			e.emit(events.Event{Type: events.TypeDeployFailed, Phase: events.PhaseDeploy, Level: events.LevelError, Message: fmt.Sprintf("  ✗ alert rules apply failed: %v\n", err)})
+			deploymentFailed = true
+			deploymentError = fmt.Errorf("alert rules apply failed: %w", err)
+			deployment.Status = remotestate.StatusFailed
+			deployment.Error = err.Error()
+		}
+	}
+
+	if !deploymentFailed {
+		if err := s.deployer.ApplyContainerEvents(); err != nil {
+			e.emit(events.Event{Type: events.TypeDeployFailed, Phase: events.PhaseDeploy, Level: events.LevelError, Message: fmt.Sprintf("  ✗ container events apply failed: %v\n", err)})
+			deploymentFailed = true
+			deploymentError = fmt.Errorf("container events apply failed: %w", err)
+			deployment.Status = remotestate.StatusFailed
+			deployment.Error = err.Error()
+		}
+	}
+
 	if !deploymentFailed {
 		if err := s.applyRemovals(plan); err != nil {
 			e.emit(events.Event{Type: events.TypeDeployServiceFailed, Phase: events.PhaseDeploy, Level: events.LevelError, Message: fmt.Sprintf("  ✗ service removal failed: %v\n", err)})
@@ -976,16 +1053,28 @@ func (s *DeploySession) Apply(ctx context.Context) (*DeployResult, error) {
 		}
 		manualPending = deployplan.ManualPromotionPendingServices(servicesToDeploy, actualState)
 		activeRevisions := deployplan.ProxyActiveRevisions(cfg, envName, proxyServices, servicesToDeploy, imageRefs, actualState)
-		if err := s.reconcileProxy(proxyServices, activeRevisions); err != nil {
+		canaries := deployplan.CanaryStartTraffic(cfg, envName, servicesToDeploy, imageRefs, actualState)
+		if err := ReconcileCanaryProxy(s.deployer, proxyServices, activeRevisions, canaries); err != nil {
 			e.emit(events.Event{Type: events.TypeDeployFailed, Phase: events.PhaseDeploy, Level: events.LevelError, Message: fmt.Sprintf("  ✗ proxy reconciliation failed: %v\n", err)})
 			deploymentFailed = true
 			deploymentError = fmt.Errorf("proxy reconciliation failed: %w", err)
 			deployment.Status = remotestate.StatusFailed
 			deployment.Error = err.Error()
-		} else if err := s.pruneRevisionsAfterGrace(proxyServices, deployplan.DeployedProxyActiveRevisions(servicesToDeploy, activeRevisions)); err != nil {
-			e.emit(events.Event{Type: events.TypeDeployFailed, Phase: events.PhaseDeploy, Level: events.LevelError, Message: fmt.Sprintf("  ✗ stale revision cleanup failed: %v\n", err)})
+		} else if err := s.pruneRevisionsAfterGrace(proxyServices, activeRevisions, deployplan.DeployedProxyActiveRevisions(servicesToDeploy, activeRevisions), actualState); err != nil {
+			stage := "stale revision cleanup failed"
+			var rollback *AnalysisRollbackError
+			if errors.As(err, &rollback) {
+				stage = "blue-green traffic analysis failed"
+			}
+			e.emit(events.Event{Type: events.TypeDeployFailed, Phase: events.PhaseDeploy, Level: events.LevelError, Message: fmt.Sprintf("  ✗ %s: %v\n", stage, err)})
+			deploymentFailed = true
+			deploymentError = fmt.Errorf("%s: %w", stage, err)
+			deployment.Status = remotestate.StatusFailed
+			deployment.Error = err.Error()
+		} else if err := s.rampCanaries(proxyServices, activeRevisions, canaries, imageRefs); err != nil {
+			e.emit(events.Event{Type: events.TypeDeployFailed, Phase: events.PhaseDeploy, Level: events.LevelError, Message: fmt.Sprintf("  ✗ canary rollout failed: %v\n", err)})
 			deploymentFailed = true
-			deploymentError = fmt.Errorf("stale revision cleanup failed: %w", err)
+			deploymentError = fmt.Errorf("canary rollout failed: %w", err)
 			deployment.Status = remotestate.StatusFailed
 			deployment.Error = err.Error()
 		} else if len(manualPending) > 0 {
@@ -1389,6 +1478,28 @@ type ProxyReconciler interface {
 	ReconcileTakodProxy(services map[string]config.ServiceConfig) error
 }
 
+// rampCanaries drives every automatic canary started by this deploy to full
+// promotion; manual canaries stay at their first step until promoted.
+func (s *DeploySession) rampCanaries(services map[string]config.ServiceConfig, activeRevisions map[string]string, canaries map[string]deployplan.CanaryTraffic, imageRefs map[string]string) error {
+	for _, serviceName := range automaticCanaryServices(services, canaries) {
+		err := s.engine.RunCanaryRamp(s.deployer, CanaryRamp{
+			Services:        services,
+			ActiveRevisions: activeRevisions,
+			ServiceName:     serviceName,
+			Canary:          canaries[serviceName],
+			Image:           imageRefs[serviceName],
+			ActualState: func() (map[string]*reconcile.ActualService, error) {
+				return reconcile.GatherActualStateFromServers(s.sshPool, s.cfg, s.envName, s.mutationServerNames, nil)
+			},
+		})
+		if err != nil {
+			return err
+		}
+		delete(canaries, serviceName)
+	}
+	return nil
+}
+
 func (s *DeploySession) reconcileProxy(services map[string]config.ServiceConfig, activeRevisions map[string]string) error {
 	return ReconcileProxy(s.deployer, services, activeRevisions)
 }
@@ -1406,8 +1517,9 @@ type RevisionPruner interface {
 	PruneTakodServiceRevisions(services map[string]config.ServiceConfig, keepRevisions map[string]string) error
 }
 
-func (s *DeploySession) pruneRevisionsAfterGrace(services map[string]config.ServiceConfig, keepRevisions map[string]string) error {
-	return s.engine.PruneRevisionsAfterGrace(s.deployer, services, keepRevisions, GraceSleep)
+func (s *DeploySession) pruneRevisionsAfterGrace(services map[string]config.ServiceConfig, activeRevisions map[string]string, keepRevisions map[string]string, actualState map[string]*reconcile.ActualService) error {
+	rollbacks := deployplan.BlueGreenRollbackTargets(services, keepRevisions, actualState)
+	return s.engine.PruneRevisionsAfterAnalysis(s.deployer, services, activeRevisions, keepRevisions, rollbacks, GraceSleep)
 }
 
 // PruneRevisionsAfterGrace prunes stale revisions after the blue-green grace
@@ -1416,16 +1528,29 @@ func (e *Engine) PruneRevisionsAfterGrace(pruner RevisionPruner, services map[st
 	if len(keepRevisions) == 0 {
 		return nil
 	}
+	if err := e.waitBlueGreenGrace(services, keepRevisions, sleep); err != nil {
+		return err
+	}
+	return pruner.PruneTakodServiceRevisions(services, keepRevisions)
+}
+
+func (e *Engine) waitBlueGreenGrace(services map[string]config.ServiceConfig, keepRevisions map[string]string, sleep func(time.Duration)) error {
 	grace, names, err := deployplan.BlueGreenPruneGracePeriod(services, keepRevisions)
 	if err != nil {
 		return err
 	}
 	if grace > 0 {
-		e.info(events.TypeLogLine, events.PhaseDeploy, fmt.Sprintf("\n-> Retaining previous blue-green revision for %s before pruning: %s\n", grace.Round(time.Millisecond), strings.Join(names, ", ")))
+		e.announceBlueGreenGrace(grace, names)
 		if sleep == nil {
 			sleep = GraceSleep
 		}
 		sleep(grace)
 	}
-	return pruner.PruneTakodServiceRevisions(services, keepRevisions)
+	return nil
+}
+
+func (e *Engine) announceBlueGreenGrace(grace time.Duration, names []string) {
+	if grace > 0 {
+		e.info(events.TypeLogLine, events.PhaseDeploy, fmt.Sprintf("\n-> Retaining previous blue-green revision for %s before pruning: %s\n", grace.Round(time.Millisecond), strings.Join(names, ", ")))
+	}
 }
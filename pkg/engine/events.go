@@ -0,0 +1,31 @@
+package engine
+
+import "github.com/redentordev/tako-cli/pkg/takod"
+
+// KindEventsResult identifies a serialized container events document.
+const KindEventsResult = "EventsResult"
+
+// EventsNodeResult is one node's container event history for the
+// environment. Services and Events reuse the takod /v1/events schema.
+type EventsNodeResult struct {
+	Server   string                           `json:"server"`
+	Host     string                           `json:"host,omitempty"`
+	Services []takod.ContainerServiceCounters `json:"services"`
+	Events   []takod.ContainerEvent           `json:"events"`
+	Error    string                           `json:"error,omitempty"`
+}
+
+// EventsResult is the serializable outcome of `tako events`: each node's
+// per-service crash, restart, and OOM kill counters and its most recent
+// container events, newest first. All nodes failing exits 1; a partial
+// result exits 6.
+type EventsResult struct {
+	APIVersion  string             `json:"apiVersion"`
+	Kind        string             `json:"kind"`
+	Project     string             `json:"project"`
+	Environment string             `json:"environment"`
+	Service     string             `json:"service,omitempty"`
+	Limit       int                `json:"limit"`
+	Nodes       []EventsNodeResult `json:"nodes"`
+	Error       string             `json:"error,omitempty"`
+}
@@ -2,6 +2,7 @@ package engine
 
 import (
 	"context"
+	"sort"
 	"strings"
 	"time"
 
@@ -57,6 +58,8 @@ type HistoryDeployment struct {
 	Duration        string                       `json:"duration,omitempty"`
 	Message         string                       `json:"message,omitempty"`
 	Error           string                       `json:"error,omitempty"`
+	// Secrets lists the secret versions the deployment's services read.
+	Secrets []remotestate.SecretRef `json:"secrets,omitempty"`
 }
 
 // History returns deployment history rows selected from the freshest reachable
@@ -133,7 +136,28 @@ func (e *Engine) History(ctx context.Context, req HistoryRequest) (*HistoryResul
 			Duration:        remotestate.FormatDuration(dep.Duration),
 			Message:         dep.GitCommitMsg,
 			Error:           dep.Error,
+			Secrets:         deploymentSecretRefs(dep),
 		})
 	}
 	return result, nil
 }
+
+// deploymentSecretRefs merges the per-service secret refs of a deployment.
+// Services deployed together read the same version of a shared key.
+func deploymentSecretRefs(dep *remotestate.DeploymentState) []remotestate.SecretRef {
+	byKey := make(map[string]remotestate.SecretRef)
+	for _, service := range dep.Services {
+		for _, ref := range service.Secrets {
+			byKey[ref.Key] = ref
+		}
+	}
+	if len(byKey) == 0 {
+		return nil
+	}
+	refs := make([]remotestate.SecretRef, 0, len(byKey))
+	for _, ref := range byKey {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Key < refs[j].Key })
+	return refs
+}
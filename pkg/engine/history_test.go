@@ -3,6 +3,7 @@ package engine
 import (
 	"context"
 	"errors"
+	"reflect"
 	"testing"
 	"time"
 
@@ -23,6 +24,10 @@ func TestHistoryBuildsResultAndFilterOptions(t *testing.T) {
 		GitCommitShort: "abc1234",
 		GitCommitMsg:   "fix issue",
 		Error:          "boom",
+		Services: map[string]remotestate.ServiceState{
+			"api":    {Secrets: []remotestate.SecretRef{{Key: "JWT_SECRET", Source: "common", Version: 1}, {Key: "DATABASE_URL", Source: "environment", Version: 3}}},
+			"worker": {Secrets: []remotestate.SecretRef{{Key: "DATABASE_URL", Source: "environment", Version: 3}}},
+		},
 	}}}
 
 	var gotHistory *remotestate.DeploymentHistory
@@ -68,6 +73,10 @@ func TestHistoryBuildsResultAndFilterOptions(t *testing.T) {
 	if dep.Version != "v42" || dep.Status != remotestate.StatusFailed || dep.DurationSeconds != 1.5 || dep.Duration != "1.5s" || dep.Message != "fix issue" || dep.Error != "boom" {
 		t.Fatalf("deployment detail fields = %#v", dep)
 	}
+	wantSecrets := []remotestate.SecretRef{{Key: "DATABASE_URL", Source: "environment", Version: 3}, {Key: "JWT_SECRET", Source: "common", Version: 1}}
+	if !reflect.DeepEqual(dep.Secrets, wantSecrets) {
+		t.Fatalf("deployment secrets = %#v, want %#v", dep.Secrets, wantSecrets)
+	}
 }
 
 func TestHistoryReturnsEmptyResultWhenNoSourceHistory(t *testing.T) {
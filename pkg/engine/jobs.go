@@ -23,6 +23,7 @@ const (
 	KindJobsResult       = "JobsResult"
 	KindJobRunsResult    = "JobRunsResult"
 	KindJobTriggerResult = "JobTriggerResult"
+	KindJobLogsResult    = "JobLogsResult"
 )
 
 // jobTriggerStreamGrace keeps the client-side deadline behind takod's
@@ -41,7 +42,9 @@ type JobRunsRequest struct {
 	Config      *config.Config
 	Environment string
 	Job         string
-	Server      string
+	// RunID narrows history to one workflow run, listed in execution order.
+	RunID  string
+	Server string
 }
 
 // JobTriggerRequest runs a scheduled job immediately on its owning node.
@@ -52,31 +55,52 @@ type JobTriggerRequest struct {
 	Server      string
 }
 
+// JobLogsRequest reads the archived full output of one job run.
+type JobLogsRequest struct {
+	Config      *config.Config
+	Environment string
+	Job         string
+	// RunID selects a workflow run ID or one attempt's container name; empty
+	// selects the newest run.
+	RunID string
+	// Follow keeps streaming a run in progress until it finishes.
+	Follow bool
+	Server string
+}
+
 // JobInfo is one scheduled job as reported by its owning node.
 type JobInfo struct {
-	Name           string      `json:"name"`
-	Server         string      `json:"server"`
-	Schedule       string      `json:"schedule"`
-	Timezone       string      `json:"timezone,omitempty"`
-	Image          string      `json:"image,omitempty"`
-	Command        []string    `json:"command,omitempty"`
-	TimeoutSeconds int         `json:"timeoutSeconds,omitempty"`
-	NextRun        *time.Time  `json:"nextRun,omitempty"`
-	LastRun        *JobRunInfo `json:"lastRun,omitempty"`
+	Name              string      `json:"name"`
+	Server            string      `json:"server"`
+	Schedule          string      `json:"schedule,omitempty"`
+	Timezone          string      `json:"timezone,omitempty"`
+	After             []string    `json:"after,omitempty"`
+	OnSuccess         []string    `json:"onSuccess,omitempty"`
+	OnFailure         []string    `json:"onFailure,omitempty"`
+	Image             string      `json:"image,omitempty"`
+	Command           []string    `json:"command,omitempty"`
+	TimeoutSeconds    int         `json:"timeoutSeconds,omitempty"`
+	Retries           int         `json:"retries,omitempty"`
+	ConcurrencyPolicy string      `json:"concurrencyPolicy,omitempty"`
+	NextRun           *time.Time  `json:"nextRun,omitempty"`
+	LastRun           *JobRunInfo `json:"lastRun,omitempty"`
 }
 
 // JobRunInfo is one recorded job run.
 type JobRunInfo struct {
-	Job        string    `json:"job"`
-	Server     string    `json:"server"`
-	Trigger    string    `json:"trigger"`
-	Container  string    `json:"container,omitempty"`
-	StartedAt  time.Time `json:"startedAt"`
-	FinishedAt time.Time `json:"finishedAt"`
-	DurationMs int64     `json:"durationMs"`
-	ExitCode   int       `json:"exitCode"`
-	Status     string    `json:"status"`
-	Output     string    `json:"output,omitempty"`
+	Job         string    `json:"job"`
+	Server      string    `json:"server"`
+	RunID       string    `json:"runId,omitempty"`
+	Trigger     string    `json:"trigger"`
+	Attempt     int       `json:"attempt,omitempty"`
+	MaxAttempts int       `json:"maxAttempts,omitempty"`
+	Container   string    `json:"container,omitempty"`
+	StartedAt   time.Time `json:"startedAt"`
+	FinishedAt  time.Time `json:"finishedAt"`
+	DurationMs  int64     `json:"durationMs"`
+	ExitCode    int       `json:"exitCode"`
+	Status      string    `json:"status"`
+	Output      string    `json:"output,omitempty"`
 }
 
 // JobsResult is the serializable outcome of `tako jobs`.
@@ -95,6 +119,7 @@ type JobRunsResult struct {
 	Project     string       `json:"project"`
 	Environment string       `json:"environment"`
 	Job         string       `json:"job,omitempty"`
+	RunID       string       `json:"runId,omitempty"`
 	Runs        []JobRunInfo `json:"runs"`
 }
 
@@ -114,6 +139,22 @@ type JobTriggerResult struct {
 	Error       string `json:"error,omitempty"`
 }
 
+// JobLogsResult is the serializable outcome of `tako jobs logs`; the output
+// itself streams as log line events.
+type JobLogsResult struct {
+	APIVersion  string `json:"apiVersion"`
+	Kind        string `json:"kind"`
+	Project     string `json:"project"`
+	Environment string `json:"environment"`
+	Job         string `json:"job"`
+	Server      string `json:"server"`
+	RunID       string `json:"runId,omitempty"`
+	Follow      bool   `json:"follow,omitempty"`
+	Lines       int    `json:"lines"`
+	DurationMs  int64  `json:"durationMs"`
+	Error       string `json:"error,omitempty"`
+}
+
 // Jobs lists the environment's scheduled jobs across its nodes.
 func (e *Engine) Jobs(ctx context.Context, req JobsRequest) (*JobsResult, error) {
 	cfg, envName, serverNames, err := e.resolveJobTargets(ctx, req.Config, req.Environment, req.Server)
@@ -154,6 +195,7 @@ func (e *Engine) JobRuns(ctx context.Context, req JobRunsRequest) (*JobRunsResul
 			return nil, err
 		}
 	}
+	runID := strings.TrimSpace(req.RunID)
 
 	var runs []JobRunInfo
 	for _, serverName := range serverNames {
@@ -174,10 +216,17 @@ func (e *Engine) JobRuns(ctx context.Context, req JobRunsRequest) (*JobRunsResul
 			return nil, fmt.Errorf("failed to parse job runs from node %s: %w", serverName, err)
 		}
 		for _, record := range response.Runs {
+			if runID != "" && record.RunID != runID {
+				continue
+			}
 			runs = append(runs, jobRunInfoFromRecord(record, serverName, e.redactor.Redact))
 		}
 	}
-	sort.SliceStable(runs, func(i, j int) bool { return runs[i].StartedAt.After(runs[j].StartedAt) })
+	if runID != "" {
+		sort.SliceStable(runs, func(i, j int) bool { return runs[i].StartedAt.Before(runs[j].StartedAt) })
+	} else {
+		sort.SliceStable(runs, func(i, j int) bool { return runs[i].StartedAt.After(runs[j].StartedAt) })
+	}
 
 	return &JobRunsResult{
 		APIVersion:  takoapi.APIVersionCurrent,
@@ -185,6 +234,7 @@ func (e *Engine) JobRuns(ctx context.Context, req JobRunsRequest) (*JobRunsResul
 		Project:     cfg.Project.Name,
 		Environment: envName,
 		Job:         job,
+		RunID:       runID,
 		Runs:        runs,
 	}, nil
 }
@@ -209,11 +259,7 @@ func (e *Engine) TriggerJob(ctx context.Context, req JobTriggerRequest) (*JobTri
 		return nil, err
 	}
 
-	timeoutSeconds := status.TimeoutSeconds
-	if timeoutSeconds <= 0 {
-		timeoutSeconds = 3600
-	}
-	timeout := time.Duration(timeoutSeconds) * time.Second
+	timeout := takod.JobRunBudget(status.TimeoutSeconds, status.Retries, status.RetryBackoffSeconds)
 
 	result := &JobTriggerResult{
 		APIVersion:  takoapi.APIVersionCurrent,
@@ -323,6 +369,86 @@ func (e *Engine) TriggerJob(ctx context.Context, req JobTriggerRequest) (*JobTri
 	return result, opErr
 }
 
+// JobLogs streams the full archived output of one job run from the node
+// holding the job, as log line events. With Follow, a run still in progress
+// streams until it finishes.
+func (e *Engine) JobLogs(ctx context.Context, req JobLogsRequest) (*JobLogsResult, error) {
+	cfg, envName, serverNames, err := e.resolveJobTargets(ctx, req.Config, req.Environment, req.Server)
+	if err != nil {
+		return nil, err
+	}
+	job := strings.TrimSpace(req.Job)
+	if job == "" {
+		return nil, invalidRequestf("logs requires a job name (tako jobs logs JOB)")
+	}
+	if err := e.requireJobService(cfg, envName, job); err != nil {
+		return nil, err
+	}
+	serverName, _, err := e.resolveJobOwner(ctx, cfg, envName, serverNames, job)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &JobLogsResult{
+		APIVersion:  takoapi.APIVersionCurrent,
+		Kind:        KindJobLogsResult,
+		Project:     cfg.Project.Name,
+		Environment: envName,
+		Job:         job,
+		Server:      serverName,
+		RunID:       strings.TrimSpace(req.RunID),
+		Follow:      req.Follow,
+	}
+
+	client, cleanup, err := connectRuntimeNode(ctx, cfg, serverName)
+	if err != nil {
+		return nil, &ConnectivityError{Err: fmt.Errorf("failed to connect to node %s: %w", serverName, err)}
+	}
+	defer cleanup()
+	if err := takodclient.RequireCapability(ctx, client, TakodSocketFromConfig(cfg), serverName, takod.CapabilityJobLogsV1, "job logs (tako jobs logs)"); err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	endpoint := takodclient.JobLogsEndpoint(cfg.Project.Name, envName, job, result.RunID, req.Follow)
+	reader, writer := io.Pipe()
+	streamDone := make(chan error, 1)
+	go func() {
+		err := takodclient.StreamOutputWithContext(ctx, client, TakodSocketFromConfig(cfg), endpoint, writer, writer)
+		if err != nil {
+			_ = writer.CloseWithError(err)
+		} else {
+			_ = writer.Close()
+		}
+		streamDone <- err
+	}()
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		e.emitLogLine(job, serverName, scanner.Text(), false)
+		result.Lines++
+	}
+	scanErr := scanner.Err()
+	streamErr := <-streamDone
+	result.DurationMs = time.Since(started).Milliseconds()
+
+	var opErr error
+	switch {
+	case streamErr != nil:
+		opErr = fmt.Errorf("failed to read job logs on node %s: %w", serverName, streamErr)
+	case scanErr != nil:
+		opErr = scanErr
+	}
+	if opErr != nil && ctx.Err() != nil {
+		opErr = ctx.Err()
+	}
+	if opErr != nil {
+		result.Error = opErr.Error()
+	}
+	return result, opErr
+}
+
 // resolveJobTargets validates the request shape shared by job operations and
 // registers node passwords and service secret values with the redactor:
 // job run output is arbitrary command output and may echo secrets.
@@ -347,7 +473,7 @@ func (e *Engine) resolveJobTargets(ctx context.Context, cfg *config.Config, envN
 		e.RegisterSecret(server.Password)
 	}
 	if services, err := cfg.GetServices(envName); err == nil {
-		e.registerServiceSecretValues(envName, services)
+		e.registerServiceSecretValues(cfg, envName, services)
 	}
 	return cfg, envName, serverNames, nil
 }
@@ -412,13 +538,18 @@ func (e *Engine) resolveJobOwner(ctx context.Context, cfg *config.Config, envNam
 
 func jobInfoFromStatus(status takod.JobStatus, serverName string, redact func(string) string) JobInfo {
 	info := JobInfo{
-		Name:           status.Name,
-		Server:         serverName,
-		Schedule:       status.Schedule,
-		Timezone:       status.Timezone,
-		Image:          status.Image,
-		Command:        append([]string(nil), status.Command...),
-		TimeoutSeconds: status.TimeoutSeconds,
+		Name:              status.Name,
+		Server:            serverName,
+		Schedule:          status.Schedule,
+		Timezone:          status.Timezone,
+		After:             append([]string(nil), status.After...),
+		OnSuccess:         append([]string(nil), status.OnSuccess...),
+		OnFailure:         append([]string(nil), status.OnFailure...),
+		Image:             status.Image,
+		Command:           append([]string(nil), status.Command...),
+		TimeoutSeconds:    status.TimeoutSeconds,
+		Retries:           status.Retries,
+		ConcurrencyPolicy: status.ConcurrencyPolicy,
 	}
 	if status.NextRun != nil {
 		nextRun := status.NextRun.UTC()
@@ -437,15 +568,18 @@ func jobRunInfoFromRecord(record takod.JobRunRecord, serverName string, redact f
 		output = redact(output)
 	}
 	return JobRunInfo{
-		Job:        record.Job,
-		Server:     serverName,
-		Trigger:    record.Trigger,
-		Container:  record.Container,
-		StartedAt:  record.StartedAt,
-		FinishedAt: record.FinishedAt,
-		DurationMs: record.DurationMs,
-		ExitCode:   record.ExitCode,
-		Status:     record.Status,
-		Output:     output,
+		Job:         record.Job,
+		Server:      serverName,
+		RunID:       record.RunID,
+		Trigger:     record.Trigger,
+		Attempt:     record.Attempt,
+		MaxAttempts: record.MaxAttempts,
+		Container:   record.Container,
+		StartedAt:   record.StartedAt,
+		FinishedAt:  record.FinishedAt,
+		DurationMs:  record.DurationMs,
+		ExitCode:    record.ExitCode,
+		Status:      record.Status,
+		Output:      output,
 	}
 }
@@ -35,6 +35,26 @@ type LogsRequest struct {
 	Server      string
 	Tail        int
 	Follow      bool
+	Query       LogsQuery
+}
+
+// LogsQuery narrows a logs request to a time range, one replica slot, and
+// matching lines. Any set field turns the request into a server-side query
+// whose entries merge across replicas and nodes in time order.
+type LogsQuery struct {
+	Since   time.Time         `json:"since,omitzero"`
+	Until   time.Time         `json:"until,omitzero"`
+	Replica int               `json:"replica,omitempty"`
+	Grep    []string          `json:"grep,omitempty"`
+	Exclude []string          `json:"exclude,omitempty"`
+	Level   string            `json:"level,omitempty"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// IsZero reports whether no query field is set.
+func (q LogsQuery) IsZero() bool {
+	return q.Since.IsZero() && q.Until.IsZero() && q.Replica == 0 &&
+		len(q.Grep) == 0 && len(q.Exclude) == 0 && q.Level == "" && len(q.Fields) == 0
 }
 
 // LogsNodeResult is the serializable outcome for one streamed takod node.
@@ -54,6 +74,8 @@ type LogsResult struct {
 	Service     string           `json:"service"`
 	Tail        int              `json:"tail"`
 	Follow      bool             `json:"follow"`
+	Query       *LogsQuery       `json:"query,omitempty"`
+	Lines       int              `json:"lines,omitempty"`
 	Status      string           `json:"status"`
 	Nodes       []LogsNodeResult `json:"nodes"`
 	StartedAt   time.Time        `json:"startedAt"`
@@ -96,6 +118,9 @@ func (e *Engine) StreamLogs(ctx context.Context, req LogsRequest) (*LogsResult,
 	if req.Tail < 0 {
 		return nil, invalidRequestf("tail cannot be negative")
 	}
+	if err := validateLogsQuery(req); err != nil {
+		return nil, err
+	}
 
 	cfg := req.Config
 	envName := req.Environment
@@ -108,6 +133,9 @@ func (e *Engine) StreamLogs(ctx context.Context, req LogsRequest) (*LogsResult,
 		return nil, invalidRequestf("service %s not found in environment %s", req.Service, envName)
 	}
 	if service.IsJob() {
+		if !req.Query.IsZero() {
+			return nil, invalidRequestf("log filters apply to service containers; read job run output with tako jobs logs %s", req.Service)
+		}
 		return e.streamJobLogs(ctx, req, cfg, envName)
 	}
 	if service.IsRun() {
@@ -142,6 +170,10 @@ func (e *Engine) StreamLogs(ctx context.Context, req LogsRequest) (*LogsResult,
 		Follow:      req.Follow,
 		StartedAt:   startedAt,
 	}
+	if !req.Query.IsZero() {
+		query := req.Query
+		result.Query = &query
+	}
 
 	banner := fmt.Sprintf("Streaming logs from %s on %d takod node(s)...\n\n", req.Service, len(servers))
 	e.emit(events.Event{
@@ -179,9 +211,18 @@ func (e *Engine) StreamLogs(ctx context.Context, req LogsRequest) (*LogsResult,
 		return nil, err
 	}
 	defer factory.CloseIdleConnections()
-	nodeResults := StreamLogNodesWith(ctx, servers, func(serverName string, server config.ServerConfig, prefix bool) error {
-		return e.streamLogsFromNode(ctx, factory, cfg, envName, serverName, server, req.Service, req.Tail, req.Follow, prefix)
-	})
+	var nodeResults []LogNodeResult
+	if req.Query.IsZero() {
+		nodeResults = StreamLogNodesWith(ctx, servers, func(serverName string, server config.ServerConfig, prefix bool) error {
+			return e.streamLogsFromNode(ctx, factory, cfg, envName, serverName, server, req.Service, req.Tail, req.Follow, prefix)
+		})
+	} else {
+		merged := &mergedLogEntries{}
+		nodeResults = StreamLogNodesWith(ctx, servers, func(serverName string, server config.ServerConfig, prefix bool) error {
+			return e.queryLogsFromNode(ctx, factory, cfg, envName, serverName, req, prefix, merged)
+		})
+		result.Lines = e.emitMergedLogEntries(req, merged, len(servers) > 1)
+	}
 	for _, nodeResult := range nodeResults {
 		result.Nodes = append(result.Nodes, logsNodeResultDocument(nodeResult))
 	}
@@ -229,7 +270,7 @@ func (e *Engine) StreamLogs(ctx context.Context, req LogsRequest) (*LogsResult,
 // containers, so logs are the recorded output of the most recent run.
 func (e *Engine) streamJobLogs(ctx context.Context, req LogsRequest, cfg *config.Config, envName string) (*LogsResult, error) {
 	if req.Follow {
-		return nil, invalidRequestf("jobs run on a schedule; --follow is not supported for job logs (see tako jobs runs %s)", req.Service)
+		return nil, invalidRequestf("jobs run on a schedule; follow a job run with tako jobs logs %s --follow", req.Service)
 	}
 
 	startedAt := time.Now()
@@ -0,0 +1,197 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/config"
+	"github.com/redentordev/tako-cli/pkg/nodeclient"
+	"github.com/redentordev/tako-cli/pkg/takoapi/events"
+	"github.com/redentordev/tako-cli/pkg/takod"
+	"github.com/redentordev/tako-cli/pkg/takodclient"
+)
+
+// defaultLogsTail mirrors takod's default when a request leaves Tail unset.
+const defaultLogsTail = 100
+
+func validateLogsQuery(req LogsRequest) error {
+	query := req.Query
+	if !query.Since.IsZero() && !query.Until.IsZero() && !query.Since.Before(query.Until) {
+		return invalidRequestf("--since must be before --until")
+	}
+	if req.Follow && !query.Until.IsZero() {
+		return invalidRequestf("--until cannot be combined with --follow")
+	}
+	if query.Replica < 0 {
+		return invalidRequestf("replica cannot be negative")
+	}
+	for _, pattern := range append(append([]string{}, query.Grep...), query.Exclude...) {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return invalidRequestf("invalid filter pattern %q: %v", pattern, err)
+		}
+	}
+	if query.Level != "" && !takod.IsLogLevel(query.Level) {
+		return invalidRequestf("unknown log level %q (use trace, debug, info, warn, error, or fatal)", query.Level)
+	}
+	if req.Tail > takod.MaxLogTail {
+		return invalidRequestf("tail cannot exceed %d", takod.MaxLogTail)
+	}
+	return nil
+}
+
+// mergedLogEntry is one queried entry tagged with the node it came from.
+type mergedLogEntry struct {
+	server string
+	entry  takod.LogEntry
+}
+
+// mergedLogEntries gathers every node's query backlog so it can be merged in
+// time order, and counts lines emitted while following.
+type mergedLogEntries struct {
+	mu      sync.Mutex
+	entries []mergedLogEntry
+	emitted int
+}
+
+func (m *mergedLogEntries) add(server string, entry takod.LogEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, mergedLogEntry{server: server, entry: entry})
+}
+
+// emitMergedLogEntries emits the gathered backlog in time order, trimmed to
+// the request's tail, and returns how many lines the request emitted.
+func (e *Engine) emitMergedLogEntries(req LogsRequest, merged *mergedLogEntries, prefix bool) int {
+	merged.mu.Lock()
+	defer merged.mu.Unlock()
+	entries := merged.entries
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].entry.Time.Before(entries[j].entry.Time)
+	})
+	tail := req.Tail
+	if tail == 0 {
+		tail = defaultLogsTail
+	}
+	if len(entries) > tail {
+		entries = entries[len(entries)-tail:]
+	}
+	for _, item := range entries {
+		e.emitLogEntry(req.Service, item.server, item.entry, prefix)
+	}
+	return merged.emitted + len(entries)
+}
+
+func (e *Engine) emitLogEntry(service string, serverName string, entry takod.LogEntry, prefix bool) {
+	data := map[string]any{
+		"service":   service,
+		"node":      serverName,
+		"data":      entry.Line,
+		"time":      entry.Time.Format(time.RFC3339Nano),
+		"container": entry.Container,
+	}
+	if entry.Replica > 0 {
+		data["replica"] = entry.Replica
+	}
+	e.emit(events.Event{
+		Type:    events.TypeLogLine,
+		Phase:   events.PhaseLogs,
+		Level:   events.LevelInfo,
+		Service: service,
+		Node:    serverName,
+		Message: formatLogLineMessage(serverName, entry.Line, prefix),
+		Data:    data,
+	})
+}
+
+// queryLogsFromNode runs a log query against one node. Without Follow the
+// node's entries are gathered for the cross-node merge; with Follow they are
+// emitted as they arrive.
+func (e *Engine) queryLogsFromNode(
+	ctx context.Context,
+	factory *nodeclient.Factory,
+	cfg *config.Config,
+	envName string,
+	serverName string,
+	req LogsRequest,
+	prefix bool,
+	merged *mergedLogEntries,
+) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	client, _, err := factory.Client(ctx, serverName)
+	if err != nil {
+		return fmt.Errorf("failed to connect to node %s: %w", serverName, err)
+	}
+	socket := TakodSocketFromConfig(cfg)
+	if err := takodclient.RequireCapability(ctx, client, socket, serverName, takod.CapabilityLogsQueryV1, "log queries (--since, --until, --replica, --grep, --exclude, --level, --field)"); err != nil {
+		return err
+	}
+
+	query := req.Query
+	endpoint := takodclient.LogsQueryEndpoint(cfg.Project.Name, envName, req.Service, req.Tail, req.Follow, takodclient.LogsQuery{
+		Since:   query.Since,
+		Until:   query.Until,
+		Replica: query.Replica,
+		Grep:    query.Grep,
+		Exclude: query.Exclude,
+		Level:   query.Level,
+		Fields:  query.Fields,
+	})
+	reader, writer := io.Pipe()
+	streamDone := make(chan error, 1)
+	go func() {
+		err := takodclient.StreamOutputWithContext(ctx, client, socket, endpoint, writer, writer)
+		if err != nil {
+			_ = writer.CloseWithError(err)
+		} else {
+			_ = writer.Close()
+		}
+		streamDone <- err
+	}()
+
+	var decodeErr error
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 2*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry takod.LogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			decodeErr = fmt.Errorf("node %s returned an invalid log entry: %w", serverName, err)
+			break
+		}
+		if req.Follow {
+			e.emitLogEntry(req.Service, serverName, entry, prefix)
+			merged.mu.Lock()
+			merged.emitted++
+			merged.mu.Unlock()
+			continue
+		}
+		merged.add(serverName, entry)
+	}
+	scanErr := scanner.Err()
+	if decodeErr != nil {
+		_ = reader.CloseWithError(decodeErr)
+	}
+	streamErr := <-streamDone
+	switch {
+	case decodeErr != nil:
+		return decodeErr
+	case streamErr != nil:
+		return streamErr
+	case scanErr != nil:
+		return scanErr
+	}
+	return ctx.Err()
+}
@@ -3,6 +3,7 @@ package engine
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
@@ -11,6 +12,7 @@ import (
 	"github.com/redentordev/tako-cli/pkg/config"
 	"github.com/redentordev/tako-cli/pkg/takoapi"
 	"github.com/redentordev/tako-cli/pkg/takoapi/events"
+	"github.com/redentordev/tako-cli/pkg/takod"
 )
 
 func TestStreamLogNodesWithRunsConcurrentlyAndKeepsSortedOrder(t *testing.T) {
@@ -88,6 +90,51 @@ func TestEmitLogLineUsesStructuredEventAndHistoricalMessage(t *testing.T) {
 	}
 }
 
+func TestEmitMergedLogEntriesOrdersAcrossNodesAndTrimsTail(t *testing.T) {
+	sink := &events.BufferSink{}
+	eng := New(Options{Sink: sink})
+	base := time.Date(2026, 7, 6, 12, 0, 0, 0, time.UTC)
+	merged := &mergedLogEntries{}
+	merged.add("node-b", takod.LogEntry{Time: base.Add(3 * time.Second), Container: "web_2", Replica: 2, Line: "third"})
+	merged.add("node-a", takod.LogEntry{Time: base.Add(time.Second), Container: "web_1", Replica: 1, Line: "first"})
+	merged.add("node-a", takod.LogEntry{Time: base.Add(4 * time.Second), Container: "web_1", Replica: 1, Line: "fourth"})
+	merged.add("node-b", takod.LogEntry{Time: base.Add(2 * time.Second), Container: "web_2", Replica: 2, Line: "second"})
+
+	lines := eng.emitMergedLogEntries(LogsRequest{Service: "web", Tail: 3}, merged, true)
+
+	if lines != 3 {
+		t.Fatalf("lines = %d, want 3", lines)
+	}
+	var messages []string
+	for _, event := range sink.Events() {
+		messages = append(messages, event.Message)
+	}
+	want := []string{"[node-b] second\n", "[node-b] third\n", "[node-a] fourth\n"}
+	if strings.Join(messages, "") != strings.Join(want, "") {
+		t.Fatalf("messages = %q, want %q", messages, want)
+	}
+	last := sink.Events()[2]
+	if last.Data["replica"] != 1 || last.Data["container"] != "web_1" || last.Data["time"] != "2026-07-06T12:00:04Z" {
+		t.Fatalf("unexpected entry data: %#v", last.Data)
+	}
+}
+
+func TestValidateLogsQueryRejectsBadFilters(t *testing.T) {
+	now := time.Now()
+	for name, req := range map[string]LogsRequest{
+		"range":        {Query: LogsQuery{Since: now, Until: now.Add(-time.Hour)}},
+		"follow until": {Follow: true, Query: LogsQuery{Until: now}},
+		"pattern":      {Query: LogsQuery{Grep: []string{"("}}},
+		"level":        {Query: LogsQuery{Level: "loud"}},
+		"tail":         {Tail: 20000, Query: LogsQuery{Level: "error"}},
+	} {
+		var invalid *InvalidRequestError
+		if err := validateLogsQuery(req); !errors.As(err, &invalid) {
+			t.Fatalf("%s: err = %v, want invalid request", name, err)
+		}
+	}
+}
+
 func TestLogsResultJSONShape(t *testing.T) {
 	result := LogsResult{
 		APIVersion:  takoapi.APIVersionCurrent,
@@ -0,0 +1,37 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/takod"
+)
+
+// KindMetricsHistoryResult identifies a serialized metrics history document.
+const KindMetricsHistoryResult = "MetricsHistoryResult"
+
+// MetricsHistoryNode is one node's recorded usage. Series reuses the takod
+// /v1/metrics/history schema: a `node` series plus one `service` series per
+// service with replicas on the node, each a list of averaged points.
+type MetricsHistoryNode struct {
+	Server      string                       `json:"server"`
+	Host        string                       `json:"host,omitempty"`
+	StepSeconds int64                        `json:"stepSeconds,omitempty"`
+	Series      []takod.MetricsHistorySeries `json:"series,omitempty"`
+	Error       string                       `json:"error,omitempty"`
+}
+
+// MetricsHistoryResult is the serializable outcome of `tako metrics
+// --since`. All nodes failing exits 1; a partial read exits 6.
+type MetricsHistoryResult struct {
+	APIVersion  string `json:"apiVersion"`
+	Kind        string `json:"kind"`
+	Project     string `json:"project"`
+	Environment string `json:"environment"`
+	// Server and Service are the --server and --service filters when set.
+	Server  string               `json:"server,omitempty"`
+	Service string               `json:"service,omitempty"`
+	Since   time.Time            `json:"since"`
+	Until   time.Time            `json:"until"`
+	Nodes   []MetricsHistoryNode `json:"nodes"`
+	Error   string               `json:"error,omitempty"`
+}
@@ -2,6 +2,7 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
@@ -53,11 +54,16 @@ type PromoteResult struct {
 	Status      takoapi.DeploymentStatus `json:"status"`
 	StartedAt   time.Time                `json:"startedAt"`
 	Duration    float64                  `json:"durationSeconds"`
+	// CanaryPercent is the traffic share a canary revision now receives when
+	// promotion advanced it to an intermediate step rather than activating it.
+	CanaryPercent int `json:"canaryPercent,omitempty"`
 }
 
 // Promote switches proxy routes to a warmed blue-green revision, prunes stale
-// revisions, and persists the promoted state. Promotion has no interactive
-// confirmation, so it runs as a single method rather than a plan/apply session.
+// revisions, and persists the promoted state. Canary revisions first advance
+// through their configured traffic steps, one step per call. Promotion has no
+// interactive confirmation, so it runs as a single method rather than a
+// plan/apply session.
 func (e *Engine) Promote(ctx context.Context, req PromoteRequest) (*PromoteResult, error) {
 	if req.Config == nil {
 		return nil, invalidRequestf("promote request requires a loaded config")
@@ -83,10 +89,11 @@ func (e *Engine) Promote(ctx context.Context, req PromoteRequest) (*PromoteResul
 	if !ok {
 		return nil, invalidRequestf("service %s not found in environment %s", serviceName, envName)
 	}
-	if service.Deploy.Strategy != config.DeployStrategyBlueGreen {
-		return nil, invalidRequestf("service %s does not use deploy.strategy=blue_green", serviceName)
+	isCanary := deployplan.IsCanaryService(service)
+	if service.Deploy.Strategy != config.DeployStrategyBlueGreen && !isCanary {
+		return nil, invalidRequestf("service %s does not use deploy.strategy=blue_green or canary", serviceName)
 	}
-	if service.Deploy.Promotion != config.DeployPromotionManual {
+	if !isCanary && service.Deploy.Promotion != config.DeployPromotionManual {
 		return nil, invalidRequestf("service %s does not use deploy.promotion=manual", serviceName)
 	}
 
@@ -210,6 +217,12 @@ func (e *Engine) Promote(ctx context.Context, req PromoteRequest) (*PromoteResul
 	if err := deploy.PreflightAssignmentMutations(map[string]config.ServiceConfig{serviceName: service}); err != nil {
 		return nil, err
 	}
+	if isCanary {
+		result, advanced, err := e.advanceCanary(deploy, cfg, envName, services, actualState, serviceName, targetRevision, targetImage)
+		if err != nil || advanced {
+			return result, err
+		}
+	}
 	intentImageRefs := deployplan.MergeRuntimeImageRefs(cfg, envName, services, nil, actualState)
 	intentImageRefs[serviceName] = targetImage
 	if err := PersistTakodDesiredIntentWithPlacementBaseline(sshPool, cfg, envName, serverNames, "promote", services, intentImageRefs, deploy.ResolvedAssignments(), nil, priorDesired, optionalPromoteGitInfo(), "recorded stable placement before promotion mutation", req.Verbose); err != nil {
@@ -254,7 +267,12 @@ func (e *Engine) Promote(ctx context.Context, req PromoteRequest) (*PromoteResul
 	if err := ReconcileProxy(deploy, services, activeRevisions); err != nil {
 		return nil, fmt.Errorf("failed to promote proxy route: %w", err)
 	}
-	if err := e.PruneRevisionsAfterGrace(deploy, map[string]config.ServiceConfig{serviceName: service}, map[string]string{serviceName: targetRevision}, GraceSleep); err != nil {
+	rollbacks := deployplan.BlueGreenRollbackTargets(map[string]config.ServiceConfig{serviceName: service}, map[string]string{serviceName: targetRevision}, actualState)
+	if err := e.PruneRevisionsAfterAnalysis(deploy, services, activeRevisions, map[string]string{serviceName: targetRevision}, rollbacks, GraceSleep); err != nil {
+		var rollback *AnalysisRollbackError
+		if errors.As(err, &rollback) {
+			return nil, fmt.Errorf("promotion rolled back: %w", err)
+		}
 		return nil, fmt.Errorf("proxy promoted but failed to prune stale revisions: %w", err)
 	}
 
@@ -318,6 +336,57 @@ func (e *Engine) Promote(ctx context.Context, req PromoteRequest) (*PromoteResul
 	return result, nil
 }
 
+// advanceCanary moves a canary revision to its next traffic step. It reports
+// advanced=false when the next step is 100%, leaving full activation to the
+// regular promotion path.
+func (e *Engine) advanceCanary(
+	deploy *deployer.Deployer,
+	cfg *config.Config,
+	envName string,
+	services map[string]config.ServiceConfig,
+	actualState map[string]*reconcile.ActualService,
+	serviceName string,
+	targetRevision string,
+	targetImage string,
+) (*PromoteResult, bool, error) {
+	service := services[serviceName]
+	published, err := deploy.PublishedTakodProxyCanaries(map[string]config.ServiceConfig{serviceName: service})
+	if err != nil {
+		return nil, false, fmt.Errorf("cannot promote %s: %w", serviceName, err)
+	}
+	current := 0
+	if canary := published[serviceName]; canary.Revision == targetRevision {
+		current = canary.Percent
+	}
+	next := deployplan.NextCanaryStep(service, current)
+	if next >= 100 {
+		return nil, false, nil
+	}
+	if err := deployplan.CanaryRevisionHealthy(actualState[serviceName], targetRevision, service.Replicas); err != nil {
+		return nil, false, fmt.Errorf("cannot promote %s: %w", serviceName, err)
+	}
+	startTime := time.Now()
+	activeRevisions := deployplan.ProxyActiveRevisions(cfg, envName, services, nil, nil, actualState)
+	canaries := map[string]deployplan.CanaryTraffic{serviceName: {Revision: targetRevision, Percent: next}}
+	if err := ReconcileCanaryProxy(deploy, services, activeRevisions, canaries); err != nil {
+		return nil, false, fmt.Errorf("failed to shift canary traffic: %w", err)
+	}
+	e.info(events.TypeDeploySucceeded, events.PhaseDeploy, fmt.Sprintf("\n✓ Canary %s now receives %d%% of traffic\n  Advance again with: tako promote %s -e %s\n", serviceName, next, serviceName, envName))
+	return &PromoteResult{
+		APIVersion:    takoapi.APIVersionCurrent,
+		Kind:          KindPromoteResult,
+		Project:       cfg.Project.Name,
+		Environment:   envName,
+		Service:       serviceName,
+		Revision:      targetRevision,
+		Image:         targetImage,
+		Status:        takoapi.DeploymentStatus(remotestate.StatusWarmed),
+		StartedAt:     startTime,
+		Duration:      time.Since(startTime).Seconds(),
+		CanaryPercent: next,
+	}, true, nil
+}
+
 func promotionTargetImage(cfg *config.Config, envName string, serviceName string, service config.ServiceConfig, actual *reconcile.ActualService, targetRevision string) (string, error) {
 	if actual == nil {
 		return "", fmt.Errorf("service is not deployed")
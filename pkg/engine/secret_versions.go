@@ -0,0 +1,24 @@
+package engine
+
+import (
+	remotestate "github.com/redentordev/tako-cli/internal/state"
+	"github.com/redentordev/tako-cli/pkg/config"
+	"github.com/redentordev/tako-cli/pkg/secrets"
+)
+
+// historySecretRefs records which secret versions a service's deploy read so
+// a deployment record can be traced back through tako secrets history.
+func historySecretRefs(envName string, service config.ServiceConfig) ([]remotestate.SecretRef, error) {
+	if len(service.Secrets) == 0 {
+		return nil, nil
+	}
+	versions, err := secrets.DeployedVersions(envName, secrets.ServiceSecretKeys(service.Secrets))
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]remotestate.SecretRef, 0, len(versions))
+	for _, version := range versions {
+		refs = append(refs, remotestate.SecretRef{Key: version.Key, Source: version.Source, Version: version.Version})
+	}
+	return refs, nil
+}
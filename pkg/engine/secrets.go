@@ -1,5 +1,7 @@
 package engine
 
+import "time"
+
 // Kinds of serialized secrets result documents.
 const (
 	KindSecretsListResult     = "SecretsListResult"
@@ -30,3 +32,28 @@ type SecretsValidateResult struct {
 	Required    []string `json:"required"`
 	Missing     []string `json:"missing,omitempty"`
 }
+
+// KindSecretsHistoryResult identifies a serialized `tako secrets history`
+// result document.
+const KindSecretsHistoryResult = "SecretsHistoryResult"
+
+// SecretsHistoryResult is the serializable outcome of `tako secrets history`:
+// the recorded versions of one key. Versions carry a value fingerprint, never
+// the value.
+type SecretsHistoryResult struct {
+	APIVersion  string                  `json:"apiVersion"`
+	Kind        string                  `json:"kind"`
+	Environment string                  `json:"environment"`
+	Key         string                  `json:"key"`
+	Versions    []SecretsHistoryVersion `json:"versions"`
+}
+
+// SecretsHistoryVersion is one recorded change in a SecretsHistoryResult.
+type SecretsHistoryVersion struct {
+	Version      int       `json:"version"`
+	Action       string    `json:"action"`
+	Fingerprint  string    `json:"fingerprint,omitempty"`
+	Author       string    `json:"author"`
+	Timestamp    time.Time `json:"timestamp"`
+	RestoredFrom int       `json:"restoredFrom,omitempty"`
+}
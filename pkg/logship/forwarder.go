@@ -0,0 +1,220 @@
+package logship
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Forwarder defaults.
+const (
+	DefaultBufferSize    = 10000
+	defaultBatchSize     = 500
+	defaultFlushInterval = time.Second
+	// defaultDropAfter is how long a sink may fail continuously before a
+	// full buffer drops its oldest entries instead of blocking producers,
+	// so one dead sink cannot stall the others forever.
+	defaultDropAfter  = 5 * time.Minute
+	minRetryBackoff   = time.Second
+	maxRetryBackoff   = 30 * time.Second
+	finalFlushTimeout = 5 * time.Second
+	sendTimeout       = sinkRequestTimeout
+)
+
+// ForwarderStats is a point-in-time view of one sink's pipeline.
+type ForwarderStats struct {
+	Sink         string    `json:"sink"`
+	Type         string    `json:"type"`
+	Queued       int       `json:"queued"`
+	Shipped      uint64    `json:"shipped"`
+	Dropped      uint64    `json:"dropped"`
+	LastShipped  time.Time `json:"lastShipped,omitzero"`
+	FailingSince time.Time `json:"failingSince,omitzero"`
+	LastError    string    `json:"lastError,omitempty"`
+}
+
+// Forwarder buffers entries for one sink and ships them in batches. While
+// the buffer is full Enqueue blocks, pushing backpressure onto the reader;
+// once the sink has failed for longer than the drop window, a full buffer
+// sheds its oldest entries instead and counts them as dropped.
+type Forwarder struct {
+	sink          Sink
+	config        SinkConfig
+	capacity      int
+	batchSize     int
+	flushInterval time.Duration
+	dropAfter     time.Duration
+	now           func() time.Time
+
+	mu           sync.Mutex
+	queue        []Entry
+	space        chan struct{}
+	ready        chan struct{}
+	shipped      uint64
+	dropped      uint64
+	lastShipped  time.Time
+	failingSince time.Time
+	lastErr      string
+}
+
+// NewForwarder returns a forwarder holding up to capacity entries for sink;
+// capacity <= 0 uses DefaultBufferSize.
+func NewForwarder(config SinkConfig, sink Sink, capacity int) *Forwarder {
+	if capacity <= 0 {
+		capacity = DefaultBufferSize
+	}
+	return &Forwarder{
+		sink:          sink,
+		config:        config,
+		capacity:      capacity,
+		batchSize:     min(defaultBatchSize, capacity),
+		flushInterval: defaultFlushInterval,
+		dropAfter:     defaultDropAfter,
+		now:           time.Now,
+		space:         make(chan struct{}),
+		ready:         make(chan struct{}, 1),
+	}
+}
+
+// Enqueue adds entry to the buffer, waiting for room while the sink is
+// healthy but behind.
+func (f *Forwarder) Enqueue(ctx context.Context, entry Entry) error {
+	for {
+		f.mu.Lock()
+		if len(f.queue) < f.capacity {
+			f.queue = append(f.queue, entry)
+			full := len(f.queue) >= f.batchSize
+			f.mu.Unlock()
+			if full {
+				f.wake()
+			}
+			return nil
+		}
+		if !f.failingSince.IsZero() && f.now().Sub(f.failingSince) >= f.dropAfter {
+			f.queue = append(f.queue[1:], entry)
+			f.dropped++
+			f.mu.Unlock()
+			return nil
+		}
+		space := f.space
+		f.mu.Unlock()
+		select {
+		case <-space:
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Run ships batches until ctx ends, then makes one last bounded attempt to
+// flush what is buffered and closes the sink.
+func (f *Forwarder) Run(ctx context.Context) {
+	defer f.sink.Close()
+	backoff := time.Duration(0)
+	timer := time.NewTimer(f.flushInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), finalFlushTimeout)
+			for f.Stats().Queued > 0 {
+				if err := f.flush(flushCtx); err != nil {
+					break
+				}
+			}
+			cancel()
+			return
+		case <-f.ready:
+			if backoff > 0 {
+				continue
+			}
+		case <-timer.C:
+		}
+		err := f.flush(ctx)
+		for err == nil && f.pendingBatch() {
+			err = f.flush(ctx)
+		}
+		switch {
+		case err != nil && ctx.Err() == nil:
+			backoff = min(max(backoff*2, minRetryBackoff), maxRetryBackoff)
+		default:
+			backoff = 0
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(max(backoff, f.flushInterval))
+	}
+}
+
+// flush sends up to one batch; entries leave the buffer only once the sink
+// accepts them.
+func (f *Forwarder) flush(ctx context.Context) error {
+	f.mu.Lock()
+	if len(f.queue) == 0 {
+		f.mu.Unlock()
+		return nil
+	}
+	batch := append([]Entry(nil), f.queue[:min(len(f.queue), f.batchSize)]...)
+	droppedBefore := f.dropped
+	f.mu.Unlock()
+
+	sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+	err := f.sink.Send(sendCtx, batch)
+	cancel()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err != nil {
+		if f.failingSince.IsZero() {
+			f.failingSince = f.now()
+		}
+		f.lastErr = err.Error()
+		return err
+	}
+	// Drops while the batch was in flight already removed its oldest
+	// entries from the queue and counted them as dropped; only the rest
+	// count as shipped.
+	sent := max(len(batch)-int(f.dropped-droppedBefore), 0)
+	f.queue = append(f.queue[:0], f.queue[min(sent, len(f.queue)):]...)
+	f.shipped += uint64(sent)
+	f.lastShipped = f.now()
+	f.failingSince = time.Time{}
+	f.lastErr = ""
+	close(f.space)
+	f.space = make(chan struct{})
+	return nil
+}
+
+func (f *Forwarder) pendingBatch() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.queue) >= f.batchSize
+}
+
+func (f *Forwarder) wake() {
+	select {
+	case f.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Stats reports the forwarder's counters.
+func (f *Forwarder) Stats() ForwarderStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return ForwarderStats{
+		Sink:         f.config.DisplayName(),
+		Type:         f.config.Type,
+		Queued:       len(f.queue),
+		Shipped:      f.shipped,
+		Dropped:      f.dropped,
+		LastShipped:  f.lastShipped,
+		FailingSince: f.failingSince,
+		LastError:    f.lastErr,
+	}
+}
@@ -0,0 +1,140 @@
+package logship
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSinkErrorBody bounds how much of a failed response is quoted in errors.
+const maxSinkErrorBody = 512
+
+// lokiSink pushes entries to Loki's /loki/api/v1/push JSON API, one stream
+// per distinct label set.
+type lokiSink struct {
+	config SinkConfig
+	client *http.Client
+}
+
+type lokiPush struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func (s *lokiSink) Send(ctx context.Context, entries []Entry) error {
+	streams := map[string]*lokiStream{}
+	var keys []string
+	for _, entry := range entries {
+		labels := entryLabels(s.config, entry)
+		key := labelSetKey(labels)
+		stream, ok := streams[key]
+		if !ok {
+			stream = &lokiStream{Stream: labels}
+			streams[key] = stream
+			keys = append(keys, key)
+		}
+		stream.Values = append(stream.Values, [2]string{strconv.FormatInt(entry.Time.UnixNano(), 10), entry.Line})
+	}
+	sort.Strings(keys)
+	push := lokiPush{Streams: make([]lokiStream, 0, len(keys))}
+	for _, key := range keys {
+		stream := streams[key]
+		sort.SliceStable(stream.Values, func(i, j int) bool {
+			left, _ := strconv.ParseInt(stream.Values[i][0], 10, 64)
+			right, _ := strconv.ParseInt(stream.Values[j][0], 10, 64)
+			return left < right
+		})
+		push.Streams = append(push.Streams, *stream)
+	}
+	body, err := json.Marshal(push)
+	if err != nil {
+		return err
+	}
+	return postSinkBody(ctx, s.client, s.config, "application/json", body)
+}
+
+func (s *lokiSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// httpSink posts entries as NDJSON, one object per line.
+type httpSink struct {
+	config SinkConfig
+	client *http.Client
+}
+
+type httpSinkEntry struct {
+	Time   string            `json:"time"`
+	Line   string            `json:"line"`
+	Labels map[string]string `json:"labels"`
+}
+
+func (s *httpSink) Send(ctx context.Context, entries []Entry) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, entry := range entries {
+		if err := encoder.Encode(httpSinkEntry{
+			Time:   entry.Time.UTC().Format(time.RFC3339Nano),
+			Line:   entry.Line,
+			Labels: entryLabels(s.config, entry),
+		}); err != nil {
+			return err
+		}
+	}
+	return postSinkBody(ctx, s.client, s.config, "application/x-ndjson", body.Bytes())
+}
+
+func (s *httpSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+func postSinkBody(ctx context.Context, client *http.Client, config SinkConfig, contentType string, body []byte) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", contentType)
+	for name, value := range config.Headers {
+		request.Header.Set(name, value)
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("%s sink request failed: %w", config.Type, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(response.Body, maxSinkErrorBody))
+		return fmt.Errorf("%s sink returned %s: %s", config.Type, response.Status, strings.TrimSpace(string(detail)))
+	}
+	_, _ = io.Copy(io.Discard, response.Body)
+	return nil
+}
+
+func labelSetKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString(key)
+		b.WriteByte(0)
+		b.WriteString(labels[key])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
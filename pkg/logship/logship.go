@@ -0,0 +1,180 @@
+// Package logship forwards log entries to external sinks: the Loki push
+// API, RFC 5424 syslog over TCP or TLS, and HTTP endpoints that accept
+// NDJSON. takod feeds it container and proxy access logs; a Forwarder per
+// sink buffers entries and applies backpressure when a sink falls behind.
+package logship
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Sink types.
+const (
+	SinkLoki   = "loki"
+	SinkSyslog = "syslog"
+	SinkHTTP   = "http"
+)
+
+// Label keys takod sets on every shipped entry.
+const (
+	LabelProject     = "project"
+	LabelEnvironment = "environment"
+	LabelService     = "service"
+	LabelRevision    = "revision"
+	LabelReplica     = "replica"
+	LabelNode        = "node"
+	LabelSource      = "source"
+)
+
+// Values of LabelSource.
+const (
+	SourceContainer = "container"
+	SourceAccess    = "access"
+)
+
+const (
+	sinkRequestTimeout = 30 * time.Second
+	maxSinkLabels      = 16
+	maxSinkHeaders     = 16
+)
+
+var (
+	labelNamePattern  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,31}$`)
+	headerNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]{0,63}$`)
+	reservedLabels    = map[string]bool{
+		LabelProject: true, LabelEnvironment: true, LabelService: true, LabelRevision: true,
+		LabelReplica: true, LabelNode: true, LabelSource: true,
+	}
+)
+
+// Entry is one log line and the labels identifying where it came from.
+type Entry struct {
+	Time   time.Time
+	Line   string
+	Labels map[string]string
+}
+
+// SinkConfig describes one log destination. URL applies to loki and http
+// sinks; Address, TLS, and Facility to syslog. Labels are static labels
+// added to every entry; Headers ride every loki and http request.
+type SinkConfig struct {
+	Name     string            `json:"name,omitempty"`
+	Type     string            `json:"type"`
+	URL      string            `json:"url,omitempty"`
+	Address  string            `json:"address,omitempty"`
+	TLS      bool              `json:"tls,omitempty"`
+	Facility string            `json:"facility,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+}
+
+// DisplayName is the sink's name, or its type when unnamed.
+func (c SinkConfig) DisplayName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Type
+}
+
+// Sink delivers batches of entries to one destination.
+type Sink interface {
+	Send(ctx context.Context, entries []Entry) error
+	Close() error
+}
+
+// ValidateSinkConfig checks a sink's shape without contacting it.
+func ValidateSinkConfig(c SinkConfig) error {
+	switch c.Type {
+	case SinkLoki, SinkHTTP:
+		parsed, err := url.Parse(c.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || hasControlChars(c.URL) {
+			return fmt.Errorf("%s sink url must be an http(s) URL", c.Type)
+		}
+		if c.Address != "" || c.TLS || c.Facility != "" {
+			return fmt.Errorf("%s sink does not accept address, tls, or facility", c.Type)
+		}
+	case SinkSyslog:
+		host, port, err := net.SplitHostPort(c.Address)
+		if err != nil || host == "" || port == "" || hasControlChars(c.Address) {
+			return fmt.Errorf("syslog sink address must be host:port")
+		}
+		if c.URL != "" || len(c.Headers) > 0 {
+			return fmt.Errorf("syslog sink does not accept url or headers")
+		}
+		if _, err := syslogFacility(c.Facility); err != nil {
+			return err
+		}
+	case "":
+		return fmt.Errorf("sink type is required (loki, syslog, or http)")
+	default:
+		return fmt.Errorf("unknown sink type %q (use loki, syslog, or http)", c.Type)
+	}
+	if len(c.Labels) > maxSinkLabels {
+		return fmt.Errorf("at most %d sink labels are allowed", maxSinkLabels)
+	}
+	for name, value := range c.Labels {
+		if !labelNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid sink label name %q", name)
+		}
+		if reservedLabels[name] {
+			return fmt.Errorf("sink label %q is set by tako", name)
+		}
+		if value == "" || hasControlChars(value) {
+			return fmt.Errorf("sink label %q needs a printable value", name)
+		}
+	}
+	if len(c.Headers) > maxSinkHeaders {
+		return fmt.Errorf("at most %d sink headers are allowed", maxSinkHeaders)
+	}
+	for name, value := range c.Headers {
+		if !headerNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid sink header name %q", name)
+		}
+		if hasControlChars(value) {
+			return fmt.Errorf("sink header %q contains control characters", name)
+		}
+	}
+	return nil
+}
+
+// NewSink validates c and returns its sink. Network connections open on
+// the first Send.
+func NewSink(c SinkConfig) (Sink, error) {
+	if err := ValidateSinkConfig(c); err != nil {
+		return nil, err
+	}
+	switch c.Type {
+	case SinkLoki:
+		return &lokiSink{config: c, client: &http.Client{Timeout: sinkRequestTimeout}}, nil
+	case SinkHTTP:
+		return &httpSink{config: c, client: &http.Client{Timeout: sinkRequestTimeout}}, nil
+	default:
+		facility, _ := syslogFacility(c.Facility)
+		return &syslogSink{config: c, facility: facility}, nil
+	}
+}
+
+// entryLabels merges a sink's static labels under the entry's own.
+func entryLabels(c SinkConfig, entry Entry) map[string]string {
+	labels := make(map[string]string, len(entry.Labels)+len(c.Labels))
+	for key, value := range c.Labels {
+		labels[key] = value
+	}
+	for key, value := range entry.Labels {
+		if value != "" {
+			labels[key] = value
+		}
+	}
+	return labels
+}
+
+func hasControlChars(value string) bool {
+	return strings.IndexFunc(value, func(r rune) bool { return r < 0x20 || r == 0x7f }) >= 0
+}
@@ -0,0 +1,300 @@
+package logship
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testEntries() []Entry {
+	base := time.Date(2026, 7, 6, 12, 0, 0, 0, time.UTC)
+	return []Entry{
+		{Time: base.Add(2 * time.Second), Line: "second", Labels: map[string]string{LabelProject: "demo", LabelService: "web", LabelReplica: "1", LabelSource: SourceContainer}},
+		{Time: base.Add(time.Second), Line: "first", Labels: map[string]string{LabelProject: "demo", LabelService: "web", LabelReplica: "1", LabelSource: SourceContainer}},
+		{Time: base.Add(3 * time.Second), Line: `GET / 200`, Labels: map[string]string{LabelProject: "demo", LabelService: "web", LabelSource: SourceAccess}},
+	}
+}
+
+func TestLokiSinkGroupsStreamsByLabelSet(t *testing.T) {
+	var push lokiPush
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("X-Scope-OrgID")
+		if err := json.NewDecoder(r.Body).Decode(&push); err != nil {
+			t.Errorf("decode push: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink, err := NewSink(SinkConfig{Type: SinkLoki, URL: server.URL + "/loki/api/v1/push", Headers: map[string]string{"X-Scope-OrgID": "team-a"}, Labels: map[string]string{"team": "payments"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Send(context.Background(), testEntries()); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+
+	if auth != "team-a" || len(push.Streams) != 2 {
+		t.Fatalf("auth = %q, streams = %+v", auth, push.Streams)
+	}
+	for _, stream := range push.Streams {
+		if stream.Stream["team"] != "payments" {
+			t.Fatalf("static label missing from %+v", stream.Stream)
+		}
+		if stream.Stream[LabelSource] == SourceContainer {
+			if len(stream.Values) != 2 || stream.Values[0][1] != "first" || stream.Values[0][0] != strconv.FormatInt(time.Date(2026, 7, 6, 12, 0, 1, 0, time.UTC).UnixNano(), 10) {
+				t.Fatalf("container stream values = %v", stream.Values)
+			}
+		}
+	}
+}
+
+func TestHTTPSinkPostsNDJSONAndReportsErrors(t *testing.T) {
+	var lines []httpSinkEntry
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("content type = %q", r.Header.Get("Content-Type"))
+		}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var line httpSinkEntry
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+				t.Errorf("bad line %q", scanner.Text())
+			}
+			lines = append(lines, line)
+		}
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, "quota exceeded")
+	}))
+	defer server.Close()
+
+	sink, err := NewSink(SinkConfig{Type: SinkHTTP, URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Send(context.Background(), testEntries()); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	if len(lines) != 3 || lines[0].Line != "second" || lines[0].Time != "2026-07-06T12:00:02Z" || lines[2].Labels[LabelSource] != SourceAccess {
+		t.Fatalf("lines = %+v", lines)
+	}
+
+	status = http.StatusTooManyRequests
+	if err := sink.Send(context.Background(), testEntries()); err == nil || !strings.Contains(err.Error(), "quota exceeded") {
+		t.Fatalf("expected sink error, got %v", err)
+	}
+}
+
+func TestSyslogSinkWritesOctetCountedRFC5424(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		var messages []string
+		for len(messages) < 2 {
+			length, err := reader.ReadString(' ')
+			if err != nil {
+				break
+			}
+			size, _ := strconv.Atoi(strings.TrimSpace(length))
+			message := make([]byte, size)
+			if _, err := io.ReadFull(reader, message); err != nil {
+				break
+			}
+			messages = append(messages, string(message))
+		}
+		received <- messages
+	}()
+
+	sink, err := NewSink(SinkConfig{Type: SinkSyslog, Address: listener.Addr().String(), Facility: "local0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	entry := Entry{Time: time.Date(2026, 7, 6, 12, 0, 0, 0, time.UTC), Line: "boom", Labels: map[string]string{
+		LabelProject: "demo", LabelService: "web", LabelReplica: "2", LabelNode: "node-a", LabelSource: SourceContainer, LabelRevision: `a"b]`,
+	}}
+	if err := sink.Send(context.Background(), []Entry{entry, entry}); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+
+	select {
+	case messages := <-received:
+		want := `<134>1 2026-07-06T12:00:00.000000Z node-a web 2 container [tako@32473 node="node-a" project="demo" replica="2" revision="a\"b\]" service="web" source="container"] boom`
+		if len(messages) != 2 || messages[0] != want {
+			t.Fatalf("messages = %q, want %q", messages, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for syslog messages")
+	}
+}
+
+func TestValidateSinkConfigRejectsBadSinks(t *testing.T) {
+	for name, config := range map[string]SinkConfig{
+		"type":           {Type: "kafka"},
+		"loki url":       {Type: SinkLoki, URL: "loki:3100"},
+		"http address":   {Type: SinkHTTP, URL: "https://logs.example.com", Address: "x:1"},
+		"syslog address": {Type: SinkSyslog, Address: "logs.example.com"},
+		"facility":       {Type: SinkSyslog, Address: "logs.example.com:6514", Facility: "local9"},
+		"label name":     {Type: SinkHTTP, URL: "https://logs.example.com", Labels: map[string]string{"bad-name": "x"}},
+		"reserved label": {Type: SinkHTTP, URL: "https://logs.example.com", Labels: map[string]string{LabelService: "x"}},
+		"header":         {Type: SinkHTTP, URL: "https://logs.example.com", Headers: map[string]string{"Authorization": "Bearer a\nb"}},
+	} {
+		if err := ValidateSinkConfig(config); err == nil {
+			t.Fatalf("%s: config accepted", name)
+		}
+	}
+}
+
+func TestSyslogFormatKeepsStructuredDataNamesValid(t *testing.T) {
+	sink := &syslogSink{facility: 3}
+	entry := Entry{Time: time.Date(2026, 7, 6, 12, 0, 0, 0, time.UTC), Line: "boom", Labels: map[string]string{
+		`team="ops] x=`:         "web",
+		strings.Repeat("k", 40): "long",
+	}}
+	got := sink.format(entry)
+	want := `[tako@32473 ` + strings.Repeat("k", 32) + `="long" team__ops__x_="web"] boom`
+	if !strings.HasSuffix(got, want) {
+		t.Fatalf("frame = %q, want suffix %q", got, want)
+	}
+}
+
+type scriptedSink struct {
+	mu      sync.Mutex
+	fail    bool
+	onSend  func()
+	batches [][]Entry
+}
+
+func (s *scriptedSink) Send(ctx context.Context, entries []Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return errors.New("sink down")
+	}
+	if s.onSend != nil {
+		s.onSend()
+	}
+	s.batches = append(s.batches, entries)
+	return nil
+}
+
+func (s *scriptedSink) Close() error { return nil }
+
+func TestForwarderBlocksWhenFullThenDropsOldestFromFailingSink(t *testing.T) {
+	sink := &scriptedSink{fail: true}
+	forwarder := NewForwarder(SinkConfig{Type: SinkHTTP}, sink, 2)
+	now := time.Date(2026, 7, 6, 12, 0, 0, 0, time.UTC)
+	forwarder.now = func() time.Time { return now }
+	ctx := context.Background()
+	for _, line := range []string{"a", "b"} {
+		if err := forwarder.Enqueue(ctx, Entry{Line: line}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := forwarder.flush(ctx); err == nil {
+		t.Fatal("flush against a failing sink succeeded")
+	}
+
+	blocked, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := forwarder.Enqueue(blocked, Entry{Line: "c"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("full buffer should apply backpressure, got %v", err)
+	}
+
+	now = now.Add(defaultDropAfter)
+	if err := forwarder.Enqueue(ctx, Entry{Line: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	stats := forwarder.Stats()
+	if stats.Dropped != 1 || stats.Queued != 2 || stats.LastError != "sink down" {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	sink.mu.Lock()
+	sink.fail = false
+	sink.mu.Unlock()
+	if err := forwarder.flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	stats = forwarder.Stats()
+	if stats.Shipped != 2 || stats.Queued != 0 || !stats.FailingSince.IsZero() {
+		t.Fatalf("stats after recovery = %+v", stats)
+	}
+	if got := sink.batches[0]; got[0].Line != "b" || got[1].Line != "c" {
+		t.Fatalf("shipped %+v, want the newest entries", got)
+	}
+}
+
+func TestForwarderCountsEntriesDroppedInFlightOnlyAsDropped(t *testing.T) {
+	sink := &scriptedSink{fail: true}
+	forwarder := NewForwarder(SinkConfig{Type: SinkHTTP}, sink, 2)
+	now := time.Date(2026, 7, 6, 12, 0, 0, 0, time.UTC)
+	forwarder.now = func() time.Time { return now }
+	ctx := context.Background()
+	for _, line := range []string{"a", "b"} {
+		if err := forwarder.Enqueue(ctx, Entry{Line: line}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := forwarder.flush(ctx); err == nil {
+		t.Fatal("flush against a failing sink succeeded")
+	}
+	now = now.Add(defaultDropAfter)
+	sink.fail = false
+	sink.onSend = func() {
+		if err := forwarder.Enqueue(ctx, Entry{Line: "c"}); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := forwarder.flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	stats := forwarder.Stats()
+	if stats.Shipped != 1 || stats.Dropped != 1 || stats.Queued != 1 {
+		t.Fatalf("stats = %+v, want one shipped, one dropped in flight, and c queued", stats)
+	}
+}
+
+func TestForwarderFlushesBufferedEntriesOnShutdown(t *testing.T) {
+	sink := &scriptedSink{}
+	forwarder := NewForwarder(SinkConfig{Type: SinkHTTP}, sink, 0)
+	forwarder.flushInterval = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		forwarder.Run(ctx)
+		close(done)
+	}()
+	for i := 0; i < 3; i++ {
+		if err := forwarder.Enqueue(ctx, Entry{Line: strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cancel()
+	<-done
+	if stats := forwarder.Stats(); stats.Shipped != 3 || stats.Queued != 0 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
@@ -0,0 +1,183 @@
+package logship
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	syslogDialTimeout  = 10 * time.Second
+	syslogWriteTimeout = 30 * time.Second
+	// syslogSeverityInfo is RFC 5424 severity 6, Informational.
+	syslogSeverityInfo = 6
+	// syslogStructuredDataID names tako's structured data element; 32473 is
+	// the private enterprise number RFC 5612 reserves for documentation.
+	syslogStructuredDataID = "tako@32473"
+	defaultSyslogFacility  = "daemon"
+)
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+func syslogFacility(name string) (int, error) {
+	if name == "" {
+		name = defaultSyslogFacility
+	}
+	facility, ok := syslogFacilities[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown syslog facility %q", name)
+	}
+	return facility, nil
+}
+
+// syslogSink writes RFC 5424 messages over TCP, optionally TLS (RFC 5425),
+// with RFC 6587 octet-counting framing. A failed write drops the
+// connection; the next batch reconnects.
+type syslogSink struct {
+	config   SinkConfig
+	facility int
+	conn     net.Conn
+}
+
+func (s *syslogSink) Send(ctx context.Context, entries []Entry) error {
+	var frames bytes.Buffer
+	for _, entry := range entries {
+		message := s.format(entry)
+		frames.WriteString(strconv.Itoa(len(message)))
+		frames.WriteByte(' ')
+		frames.WriteString(message)
+	}
+	if s.conn == nil {
+		conn, err := s.dial(ctx)
+		if err != nil {
+			return fmt.Errorf("syslog sink connect failed: %w", err)
+		}
+		s.conn = conn
+	}
+	deadline := time.Now().Add(syslogWriteTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	_ = s.conn.SetWriteDeadline(deadline)
+	if _, err := s.conn.Write(frames.Bytes()); err != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+		return fmt.Errorf("syslog sink write failed: %w", err)
+	}
+	return nil
+}
+
+func (s *syslogSink) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: syslogDialTimeout}
+	if !s.config.TLS {
+		return dialer.DialContext(ctx, "tcp", s.config.Address)
+	}
+	host, _, _ := net.SplitHostPort(s.config.Address)
+	tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}}
+	return tlsDialer.DialContext(ctx, "tcp", s.config.Address)
+}
+
+func (s *syslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// format renders one RFC 5424 message: the node as HOSTNAME, the service
+// as APP-NAME, the replica as PROCID, the source as MSGID, and every label
+// as structured data.
+func (s *syslogSink) format(entry Entry) string {
+	labels := entryLabels(s.config, entry)
+	hostname := syslogHeaderField(labels[LabelNode], 255)
+	if hostname == "-" {
+		if name, err := os.Hostname(); err == nil {
+			hostname = syslogHeaderField(name, 255)
+		}
+	}
+	appName := syslogHeaderField(labels[LabelService], 48)
+	if appName == "-" {
+		appName = "tako"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %s %s ",
+		s.facility*8+syslogSeverityInfo,
+		entry.Time.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		hostname,
+		appName,
+		syslogHeaderField(labels[LabelReplica], 128),
+		syslogHeaderField(labels[LabelSource], 32),
+	)
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	b.WriteString("[" + syslogStructuredDataID)
+	for _, key := range keys {
+		b.WriteString(" " + syslogParamName(key) + "=\"" + syslogParamValue(labels[key]) + "\"")
+	}
+	b.WriteString("] ")
+	b.WriteString(strings.TrimRight(entry.Line, "\r\n"))
+	return b.String()
+}
+
+// syslogHeaderField renders a header field as printable US-ASCII without
+// spaces, or the NILVALUE when empty.
+func syslogHeaderField(value string, limit int) string {
+	var b strings.Builder
+	for _, r := range value {
+		if b.Len() >= limit {
+			break
+		}
+		if r > 32 && r < 127 {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "-"
+	}
+	return b.String()
+}
+
+// syslogParamName renders a label key as an RFC 5424 SD-NAME: at most 32
+// printable US-ASCII characters other than '=', space, ']', and '"'. Sink
+// labels are validated stricter than this; it guards the frame regardless.
+func syslogParamName(key string) string {
+	var b strings.Builder
+	for _, r := range key {
+		if b.Len() >= 32 {
+			break
+		}
+		if r > 32 && r < 127 && r != '=' && r != ']' && r != '"' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+func syslogParamValue(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+	return replacer.Replace(value)
+}
@@ -13,17 +13,18 @@ import (
 type EventType string
 
 const (
-	EventDeployStarted    EventType = "deploy_started"
-	EventDeploySucceeded  EventType = "deploy_succeeded"
-	EventDeployFailed     EventType = "deploy_failed"
-	EventRollbackStarted  EventType = "rollback_started"
-	EventRollbackDone     EventType = "rollback_done"
-	EventServiceDown      EventType = "service_down"
-	EventServiceUp        EventType = "service_up"
-	EventServiceRestarted EventType = "service_restarted"
-	EventDriftDetected    EventType = "drift_detected"
-	EventBackupCompleted  EventType = "backup_completed"
-	EventBackupFailed     EventType = "backup_failed"
+	EventDeployStarted      EventType = "deploy_started"
+	EventDeploySucceeded    EventType = "deploy_succeeded"
+	EventDeployFailed       EventType = "deploy_failed"
+	EventRollbackStarted    EventType = "rollback_started"
+	EventRollbackDone       EventType = "rollback_done"
+	EventServiceDown        EventType = "service_down"
+	EventServiceUp          EventType = "service_up"
+	EventServiceRestarted   EventType = "service_restarted"
+	EventDriftDetected      EventType = "drift_detected"
+	EventBackupCompleted    EventType = "backup_completed"
+	EventBackupFailed       EventType = "backup_failed"
+	EventBackupVerifyFailed EventType = "backup_verify_failed"
 	// Resource alerts
 	EventHighCPU        EventType = "high_cpu"
 	EventHighMemory     EventType = "high_memory"
@@ -44,6 +45,10 @@ const (
 	// Scaling events
 	EventScaleUp   EventType = "scale_up"
 	EventScaleDown EventType = "scale_down"
+	// Scheduled job alerts
+	EventJobFailed         EventType = "job_failed"
+	EventJobRecovered      EventType = "job_recovered"
+	EventJobMissedSchedule EventType = "job_missed_schedule"
 )
 
 // Event represents a notification event
@@ -279,15 +284,15 @@ func (n *Notifier) postJSON(url string, payload interface{}) error {
 func (n *Notifier) getEventColor(eventType EventType) string {
 	switch eventType {
 	case EventDeploySucceeded, EventServiceUp, EventBackupCompleted, EventRollbackDone,
-		EventHealthCheckRecovered, EventResourceNormal, EventSSLRenewed, EventSSLIssued:
+		EventHealthCheckRecovered, EventResourceNormal, EventSSLRenewed, EventSSLIssued, EventJobRecovered:
 		return "#36a64f" // Green
-	case EventDeployFailed, EventServiceDown, EventBackupFailed,
-		EventContainerOOM, EventContainerCrashLoop, EventSSLExpired, EventSSLFailed:
+	case EventDeployFailed, EventServiceDown, EventBackupFailed, EventBackupVerifyFailed,
+		EventContainerOOM, EventContainerCrashLoop, EventSSLExpired, EventSSLFailed, EventJobFailed:
 		return "#dc3545" // Red
 	case EventDeployStarted, EventRollbackStarted, EventScaleUp, EventScaleDown, EventServiceRestarted, EventSSLPending:
 		return "#007bff" // Blue
 	case EventDriftDetected, EventHighCPU, EventHighMemory, EventHighDisk,
-		EventHealthCheckFailed, EventSSLExpiringSoon:
+		EventHealthCheckFailed, EventSSLExpiringSoon, EventJobMissedSchedule:
 		return "#ffc107" // Yellow/Warning
 	default:
 		return "#6c757d" // Gray
@@ -298,15 +303,15 @@ func (n *Notifier) getEventColor(eventType EventType) string {
 func (n *Notifier) getEventColorInt(eventType EventType) int {
 	switch eventType {
 	case EventDeploySucceeded, EventServiceUp, EventBackupCompleted, EventRollbackDone,
-		EventHealthCheckRecovered, EventResourceNormal, EventSSLRenewed, EventSSLIssued:
+		EventHealthCheckRecovered, EventResourceNormal, EventSSLRenewed, EventSSLIssued, EventJobRecovered:
 		return 0x36a64f // Green
-	case EventDeployFailed, EventServiceDown, EventBackupFailed,
-		EventContainerOOM, EventContainerCrashLoop, EventSSLExpired, EventSSLFailed:
+	case EventDeployFailed, EventServiceDown, EventBackupFailed, EventBackupVerifyFailed,
+		EventContainerOOM, EventContainerCrashLoop, EventSSLExpired, EventSSLFailed, EventJobFailed:
 		return 0xdc3545 // Red
 	case EventDeployStarted, EventRollbackStarted, EventScaleUp, EventScaleDown, EventServiceRestarted, EventSSLPending:
 		return 0x007bff // Blue
 	case EventDriftDetected, EventHighCPU, EventHighMemory, EventHighDisk,
-		EventHealthCheckFailed, EventSSLExpiringSoon:
+		EventHealthCheckFailed, EventSSLExpiringSoon, EventJobMissedSchedule:
 		return 0xffc107 // Yellow
 	default:
 		return 0x6c757d // Gray
@@ -338,6 +343,8 @@ func (n *Notifier) getEventEmoji(eventType EventType) string {
 		return "💾"
 	case EventBackupFailed:
 		return "⚠️"
+	case EventBackupVerifyFailed:
+		return "🧪"
 	case EventHighCPU:
 		return "🔥"
 	case EventHighMemory:
@@ -370,6 +377,12 @@ func (n *Notifier) getEventEmoji(eventType EventType) string {
 		return "📈"
 	case EventScaleDown:
 		return "📉"
+	case EventJobFailed:
+		return "❌"
+	case EventJobRecovered:
+		return "✅"
+	case EventJobMissedSchedule:
+		return "⏰"
 	default:
 		return "📢"
 	}
@@ -400,6 +413,8 @@ func (n *Notifier) getEventTitle(eventType EventType) string {
 		return "Backup Completed"
 	case EventBackupFailed:
 		return "Backup Failed"
+	case EventBackupVerifyFailed:
+		return "Backup Restore Drill Failed"
 	case EventHighCPU:
 		return "High CPU Usage Alert"
 	case EventHighMemory:
@@ -432,6 +447,12 @@ func (n *Notifier) getEventTitle(eventType EventType) string {
 		return "Service Scaled Up"
 	case EventScaleDown:
 		return "Service Scaled Down"
+	case EventJobFailed:
+		return "Scheduled Job Failed"
+	case EventJobRecovered:
+		return "Scheduled Job Recovered"
+	case EventJobMissedSchedule:
+		return "Scheduled Job Missed Its Schedule"
 	default:
 		return "Tako Notification"
 	}
@@ -540,6 +561,24 @@ func HighDiskEvent(project, env string, diskPercent float64, threshold float64,
 	}
 }
 
+// ResourceNormalEvent creates an event for resource usage back under its
+// alert threshold
+func ResourceNormalEvent(project, env, service string, metric string, value float64, threshold float64) Event {
+	return Event{
+		Type:        EventResourceNormal,
+		Project:     project,
+		Environment: env,
+		Service:     service,
+		Message:     fmt.Sprintf("%s back to %.1f (threshold: %.1f)", metric, value, threshold),
+		Details: map[string]string{
+			"metric":    metric,
+			"value":     fmt.Sprintf("%.1f", value),
+			"threshold": fmt.Sprintf("%.1f", threshold),
+		},
+		Timestamp: time.Now(),
+	}
+}
+
 // HealthCheckFailedEvent creates a health check failure event
 func HealthCheckFailedEvent(project, env, service string, endpoint string, statusCode int, err error) Event {
 	errMsg := ""
@@ -649,6 +688,106 @@ func ScaleEvent(project, env, service string, fromReplicas, toReplicas int) Even
 	}
 }
 
+// JobFailedEvent creates a scheduled job failure event for a run that
+// failed or timed out after all of its attempts
+func JobFailedEvent(project, env, job, runID, status string, exitCode int, attempts int) Event {
+	return Event{
+		Type:        EventJobFailed,
+		Project:     project,
+		Environment: env,
+		Service:     job,
+		Message:     fmt.Sprintf("Job `%s` %s (exit %d); see `tako jobs logs %s --run %s`", job, status, exitCode, job, runID),
+		Error:       fmt.Sprintf("run %s %s with exit code %d", runID, status, exitCode),
+		Details: map[string]string{
+			"run_id":    runID,
+			"status":    status,
+			"exit_code": fmt.Sprintf("%d", exitCode),
+			"attempts":  fmt.Sprintf("%d", attempts),
+		},
+		Timestamp: time.Now(),
+	}
+}
+
+// JobRecoveredEvent creates a scheduled job recovery event for the first
+// successful run after a failure or a missed schedule
+func JobRecoveredEvent(project, env, job, runID string, reason string) Event {
+	return Event{
+		Type:        EventJobRecovered,
+		Project:     project,
+		Environment: env,
+		Service:     job,
+		Message:     fmt.Sprintf("Job `%s` succeeded again after %s", job, reason),
+		Details: map[string]string{
+			"run_id": runID,
+		},
+		Timestamp: time.Now(),
+	}
+}
+
+// JobMissedScheduleEvent creates a dead man's switch event for a scheduled
+// job that did not run when its schedule expected it to
+func JobMissedScheduleEvent(project, env, job, schedule string, expected time.Time, lastRun time.Time) Event {
+	last := "never"
+	if !lastRun.IsZero() {
+		last = lastRun.UTC().Format(time.RFC3339)
+	}
+	return Event{
+		Type:        EventJobMissedSchedule,
+		Project:     project,
+		Environment: env,
+		Service:     job,
+		Message:     fmt.Sprintf("Job `%s` (`%s`) was expected to run at %s but has not run since %s", job, schedule, expected.UTC().Format(time.RFC3339), last),
+		Details: map[string]string{
+			"schedule":     schedule,
+			"expected_run": expected.UTC().Format(time.RFC3339),
+			"last_run":     last,
+		},
+		Timestamp: time.Now(),
+	}
+}
+
+// BackupVerifyFailedEvent creates an event for a restore drill that could
+// not bring a backup back to a usable state, or that could not run at all
+// when backupID is empty
+func BackupVerifyFailedEvent(project, env, service, volume, backupID, reason string) Event {
+	message := fmt.Sprintf("Restore drill of backup `%s` for volume `%s` failed; see `tako backup verify --volume %s`", backupID, volume, volume)
+	if backupID == "" {
+		message = fmt.Sprintf("Restore drill for volume `%s` could not run; see `tako backup verify --volume %s`", volume, volume)
+	}
+	return Event{
+		Type:        EventBackupVerifyFailed,
+		Project:     project,
+		Environment: env,
+		Service:     service,
+		Message:     message,
+		Error:       reason,
+		Details: map[string]string{
+			"volume":    volume,
+			"backup_id": backupID,
+		},
+		Timestamp: time.Now(),
+	}
+}
+
+// WALArchiveBehindEvent creates an event for point-in-time recovery WAL that
+// is piling up in the database's data directory instead of reaching backup
+// storage
+func WALArchiveBehindEvent(project, env, service, volume string, spooledBytes int64, reason string) Event {
+	return Event{
+		Type:        EventBackupFailed,
+		Project:     project,
+		Environment: env,
+		Service:     service,
+		Message:     fmt.Sprintf("WAL for volume `%s` is not reaching backup storage; %d MiB is waiting in the data directory", volume, spooledBytes>>20),
+		Error:       reason,
+		Details: map[string]string{
+			"volume":        volume,
+			"spooled_bytes": fmt.Sprintf("%d", spooledBytes),
+		},
+		Timestamp: time.Now(),
+	}
+}
+
 // ServiceDownEvent creates a service down event
 func ServiceDownEvent(project, env, service string, err error) Event {
 	return Event{
@@ -769,9 +769,9 @@ func (e AgentOperationExecutor) Plan(spec OperationSpec) (OperationPlan, error)
 		}
 		plan.DiskGrowth = len(desired.Containers) > 0 || len(desired.Files) > 0
 		plan.ReplaySafe = true
-	case "/v1/service-files", "/v1/proxy-file", "/v1/state", "/v1/env-bundle", "/v1/backup-schedule", "/v1/metadata":
+	case "/v1/service-files", "/v1/proxy-file", "/v1/state", "/v1/env-bundle", "/v1/shared-secrets", "/v1/backup-schedule", "/v1/metadata":
 		plan.ReplaySafe = payload.Method == http.MethodPut
-	case "/v1/proxy", "/v1/mesh/apply", "/v1/jobs/apply":
+	case "/v1/proxy", "/v1/mesh/apply", "/v1/jobs/apply", "/v1/metrics/exporter", "/v1/alerts/apply", "/v1/tracing/apply", "/v1/events/apply":
 		plan.ReplaySafe = payload.Method == http.MethodPost
 	case "/v1/images/build", "/v1/images/import":
 		plan.Build = true
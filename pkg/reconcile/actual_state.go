@@ -139,6 +139,7 @@ func AggregateActualStateByServer(actualByServer map[string]map[string]*ActualSe
 				existing.DeployStrategy = mergeOptionalLabel(existing.DeployStrategy, serviceState.DeployStrategy)
 				existing.ActiveContainers = append(existing.ActiveContainers, serviceState.ActiveContainers...)
 				existing.WarmingContainers = append(existing.WarmingContainers, serviceState.WarmingContainers...)
+				existing.WarmingHealth = takod.MergeHealthStates(existing.WarmingHealth, serviceState.WarmingHealth)
 				continue
 			}
 			actualServices[serviceName] = cloneActualService(serviceState)
@@ -205,6 +206,7 @@ func gatherActualStateFromTakodWith(client any, socket string, project string, e
 			DeployStrategy:    service.DeployStrategy,
 			ActiveContainers:  append([]string(nil), service.ActiveContainers...),
 			WarmingContainers: append([]string(nil), service.WarmingContainers...),
+			WarmingHealth:     service.WarmingHealth,
 			ConfigSnapshot: &config.ServiceConfig{
 				Image:      service.Image,
 				Persistent: service.Persistent,
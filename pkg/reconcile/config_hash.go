@@ -21,51 +21,59 @@ const SlotLabel = "tako.slot"
 const ActiveLabel = "tako.active"
 
 type safeServiceConfigFingerprint struct {
-	Kind             string                         `json:"kind,omitempty"`
-	Schedule         string                         `json:"schedule,omitempty"`
-	Timezone         string                         `json:"timezone,omitempty"`
-	Timeout          string                         `json:"timeout,omitempty"`
-	Build            string                         `json:"build,omitempty"`
-	BuildArgs        map[string]string              `json:"buildArgs,omitempty"`
-	BuildTarget      string                         `json:"buildTarget,omitempty"`
-	Dockerfile       string                         `json:"dockerfile,omitempty"`
-	Image            string                         `json:"image,omitempty"`
-	ImageFrom        string                         `json:"imageFrom,omitempty"`
-	SharedBuildHash  string                         `json:"sharedBuildHash,omitempty"`
-	Port             int                            `json:"port,omitempty"`
-	Ports            []string                       `json:"ports,omitempty"`
-	Command          any                            `json:"command,omitempty"`
-	Entrypoint       any                            `json:"entrypoint,omitempty"`
-	Labels           map[string]string              `json:"labels,omitempty"`
-	Replicas         int                            `json:"replicas,omitempty"`
-	Restart          string                         `json:"restart,omitempty"`
-	EnvKeys          []string                       `json:"envKeys,omitempty"`
-	EnvFile          string                         `json:"envFile,omitempty"`
-	EnvFiles         []string                       `json:"envFiles,omitempty"`
-	RunInputHash     string                         `json:"runInputHash,omitempty"`
-	User             string                         `json:"user,omitempty"`
-	WorkingDir       string                         `json:"workingDir,omitempty"`
-	StopGracePeriod  string                         `json:"stopGracePeriod,omitempty"`
-	Init             bool                           `json:"init,omitempty"`
-	ExtraHosts       []string                       `json:"extraHosts,omitempty"`
-	Ulimits          map[string]config.UlimitConfig `json:"ulimits,omitempty"`
-	ShmSize          string                         `json:"shmSize,omitempty"`
-	Secrets          []string                       `json:"secrets,omitempty"`
-	Volumes          []string                       `json:"volumes,omitempty"`
-	Files            []serviceFileFingerprint       `json:"files,omitempty"`
-	FilesContentHash string                         `json:"filesContentHash,omitempty"`
-	Persistent       bool                           `json:"persistent,omitempty"`
-	Proxy            *config.ProxyConfig            `json:"proxy,omitempty"`
-	LoadBalancer     config.LoadBalancerConfig      `json:"loadBalancer,omitempty"`
-	HealthCheck      config.HealthCheckConfig       `json:"healthCheck,omitempty"`
-	Deploy           config.DeployConfig            `json:"deploy,omitempty"`
-	Backup           *backupFingerprint             `json:"backup,omitempty"`
-	Monitoring       *monitoringFingerprint         `json:"monitoring,omitempty"`
-	Export           bool                           `json:"export,omitempty"`
-	Imports          []string                       `json:"imports,omitempty"`
-	Placement        *config.PlacementConfig        `json:"placement,omitempty"`
-	DependsOn        []string                       `json:"dependsOn,omitempty"`
-	Resources        *config.ResourceLimitsConfig   `json:"resources,omitempty"`
+	Kind              string                         `json:"kind,omitempty"`
+	Schedule          string                         `json:"schedule,omitempty"`
+	Timezone          string                         `json:"timezone,omitempty"`
+	Timeout           string                         `json:"timeout,omitempty"`
+	After             []string                       `json:"after,omitempty"`
+	OnSuccess         []string                       `json:"onSuccess,omitempty"`
+	OnFailure         []string                       `json:"onFailure,omitempty"`
+	Retries           int                            `json:"retries,omitempty"`
+	RetryBackoff      string                         `json:"retryBackoff,omitempty"`
+	ConcurrencyPolicy string                         `json:"concurrencyPolicy,omitempty"`
+	LogArchive        *config.JobLogArchiveConfig    `json:"logArchive,omitempty"`
+	Build             string                         `json:"build,omitempty"`
+	BuildArgs         map[string]string              `json:"buildArgs,omitempty"`
+	BuildTarget       string                         `json:"buildTarget,omitempty"`
+	Dockerfile        string                         `json:"dockerfile,omitempty"`
+	Image             string                         `json:"image,omitempty"`
+	ImageFrom         string                         `json:"imageFrom,omitempty"`
+	SharedBuildHash   string                         `json:"sharedBuildHash,omitempty"`
+	Port              int                            `json:"port,omitempty"`
+	Ports             []string                       `json:"ports,omitempty"`
+	Command           any                            `json:"command,omitempty"`
+	Entrypoint        any                            `json:"entrypoint,omitempty"`
+	Labels            map[string]string              `json:"labels,omitempty"`
+	Replicas          int                            `json:"replicas,omitempty"`
+	Restart           string                         `json:"restart,omitempty"`
+	EnvKeys           []string                       `json:"envKeys,omitempty"`
+	EnvFile           string                         `json:"envFile,omitempty"`
+	EnvFiles          []string                       `json:"envFiles,omitempty"`
+	RunInputHash      string                         `json:"runInputHash,omitempty"`
+	User              string                         `json:"user,omitempty"`
+	WorkingDir        string                         `json:"workingDir,omitempty"`
+	StopGracePeriod   string                         `json:"stopGracePeriod,omitempty"`
+	Init              bool                           `json:"init,omitempty"`
+	ExtraHosts        []string                       `json:"extraHosts,omitempty"`
+	Ulimits           map[string]config.UlimitConfig `json:"ulimits,omitempty"`
+	ShmSize           string                         `json:"shmSize,omitempty"`
+	Secrets           []string                       `json:"secrets,omitempty"`
+	SecretsDelivery   *config.SecretsDeliveryConfig  `json:"secretsDelivery,omitempty"`
+	Volumes           []string                       `json:"volumes,omitempty"`
+	Files             []serviceFileFingerprint       `json:"files,omitempty"`
+	FilesContentHash  string                         `json:"filesContentHash,omitempty"`
+	Persistent        bool                           `json:"persistent,omitempty"`
+	Proxy             *config.ProxyConfig            `json:"proxy,omitempty"`
+	LoadBalancer      config.LoadBalancerConfig      `json:"loadBalancer,omitempty"`
+	HealthCheck       config.HealthCheckConfig       `json:"healthCheck,omitempty"`
+	Deploy            config.DeployConfig            `json:"deploy,omitempty"`
+	Backup            *backupFingerprint             `json:"backup,omitempty"`
+	Monitoring        *monitoringFingerprint         `json:"monitoring,omitempty"`
+	Export            bool                           `json:"export,omitempty"`
+	Imports           []string                       `json:"imports,omitempty"`
+	Placement         *config.PlacementConfig        `json:"placement,omitempty"`
+	DependsOn         []string                       `json:"dependsOn,omitempty"`
+	Resources         *config.ResourceLimitsConfig   `json:"resources,omitempty"`
 }
 
 type serviceFileFingerprint struct {
@@ -83,7 +91,7 @@ type monitoringFingerprint struct {
 
 type backupFingerprint struct {
 	Schedule string                    `json:"schedule,omitempty"`
-	Retain   int                       `json:"retain,omitempty"`
+	Retain   any                       `json:"retain,omitempty"`
 	Volumes  []string                  `json:"volumes,omitempty"`
 	Storage  *backupStorageFingerprint `json:"storage,omitempty"`
 }
@@ -98,55 +106,73 @@ type backupStorageFingerprint struct {
 	SecretAccessKeyConfigured bool   `json:"secretAccessKeyConfigured,omitempty"`
 	SessionTokenConfigured    bool   `json:"sessionTokenConfigured,omitempty"`
 	ForcePathStyle            bool   `json:"forcePathStyle,omitempty"`
+	Format                    string `json:"format,omitempty"`
+	EncryptionKeyConfigured   bool   `json:"encryptionKeyConfigured,omitempty"`
+	Path                      string `json:"path,omitempty"`
+	Host                      string `json:"host,omitempty"`
+	Port                      int    `json:"port,omitempty"`
+	User                      string `json:"user,omitempty"`
+	PasswordConfigured        bool   `json:"passwordConfigured,omitempty"`
+	PrivateKeyConfigured      bool   `json:"privateKeyConfigured,omitempty"`
+	HostKey                   string `json:"hostKey,omitempty"`
+	Node                      string `json:"node,omitempty"`
 }
 
 func SafeServiceConfigHash(service config.ServiceConfig) (string, bool) {
 	fingerprint := safeServiceConfigFingerprint{
-		Kind:             service.Kind,
-		Schedule:         service.Schedule,
-		Timezone:         service.Timezone,
-		Timeout:          service.Timeout,
-		Build:            service.Build,
-		BuildArgs:        cloneStringMap(service.BuildArgs),
-		BuildTarget:      service.BuildTarget,
-		Dockerfile:       service.Dockerfile,
-		Image:            service.Image,
-		ImageFrom:        service.ImageFrom,
-		SharedBuildHash:  service.SharedBuildHash,
-		Port:             service.Port,
-		Ports:            sortedStrings(service.Ports),
-		Command:          stringOrListFingerprint(service.Command),
-		Entrypoint:       stringOrListFingerprint(service.Entrypoint),
-		Labels:           cloneStringMap(service.Labels),
-		Replicas:         service.Replicas,
-		Restart:          service.Restart,
-		EnvKeys:          sortedMapKeys(service.Env),
-		EnvFile:          service.EnvFile,
-		EnvFiles:         append([]string(nil), service.EnvFiles...),
-		RunInputHash:     service.RunInputHash,
-		User:             service.User,
-		WorkingDir:       service.WorkingDir,
-		StopGracePeriod:  service.StopGracePeriod,
-		Init:             service.Init,
-		ExtraHosts:       sortedStrings(service.ExtraHosts),
-		Ulimits:          cloneUlimits(service.Ulimits),
-		ShmSize:          service.ShmSize,
-		Secrets:          sortedStrings(service.Secrets),
-		Volumes:          sortedStrings(service.Volumes),
-		Files:            serviceFilesFingerprint(service.Files),
-		FilesContentHash: service.FilesContentHash,
-		Persistent:       service.Persistent,
-		Proxy:            service.Proxy,
-		LoadBalancer:     service.LoadBalancer,
-		HealthCheck:      service.HealthCheck,
-		Deploy:           service.Deploy,
-		Backup:           cloneBackupFingerprint(service.Backup),
-		Monitoring:       cloneMonitoringFingerprint(service.Monitoring),
-		Export:           service.Export,
-		Imports:          sortedStrings(service.Imports),
-		Placement:        clonePlacement(service.Placement),
-		DependsOn:        sortedStrings(service.DependsOn),
-		Resources:        cloneResourcesFingerprint(service.Resources),
+		Kind:              service.Kind,
+		Schedule:          service.Schedule,
+		Timezone:          service.Timezone,
+		Timeout:           service.Timeout,
+		After:             append([]string(nil), service.After...),
+		OnSuccess:         append([]string(nil), service.OnSuccess...),
+		OnFailure:         append([]string(nil), service.OnFailure...),
+		Retries:           service.Retries,
+		RetryBackoff:      service.RetryBackoff,
+		ConcurrencyPolicy: service.ConcurrencyPolicy,
+		LogArchive:        service.LogArchive,
+		Build:             service.Build,
+		BuildArgs:         cloneStringMap(service.BuildArgs),
+		BuildTarget:       service.BuildTarget,
+		Dockerfile:        service.Dockerfile,
+		Image:             service.Image,
+		ImageFrom:         service.ImageFrom,
+		SharedBuildHash:   service.SharedBuildHash,
+		Port:              service.Port,
+		Ports:             sortedStrings(service.Ports),
+		Command:           stringOrListFingerprint(service.Command),
+		Entrypoint:        stringOrListFingerprint(service.Entrypoint),
+		Labels:            cloneStringMap(service.Labels),
+		Replicas:          service.Replicas,
+		Restart:           service.Restart,
+		EnvKeys:           sortedMapKeys(service.Env),
+		EnvFile:           service.EnvFile,
+		EnvFiles:          append([]string(nil), service.EnvFiles...),
+		RunInputHash:      service.RunInputHash,
+		User:              service.User,
+		WorkingDir:        service.WorkingDir,
+		StopGracePeriod:   service.StopGracePeriod,
+		Init:              service.Init,
+		ExtraHosts:        sortedStrings(service.ExtraHosts),
+		Ulimits:           cloneUlimits(service.Ulimits),
+		ShmSize:           service.ShmSize,
+		Secrets:           sortedStrings(service.Secrets),
+		SecretsDelivery:   cloneSecretsDelivery(service.SecretsDelivery),
+		Volumes:           sortedStrings(service.Volumes),
+		Files:             serviceFilesFingerprint(service.Files),
+		FilesContentHash:  service.FilesContentHash,
+		Persistent:        service.Persistent,
+		Proxy:             service.Proxy,
+		LoadBalancer:      service.LoadBalancer,
+		HealthCheck:       service.HealthCheck,
+		Deploy:            service.Deploy,
+		Backup:            cloneBackupFingerprint(service.Backup),
+		Monitoring:        cloneMonitoringFingerprint(service.Monitoring),
+		Export:            service.Export,
+		Imports:           sortedStrings(service.Imports),
+		Placement:         clonePlacement(service.Placement),
+		DependsOn:         sortedStrings(service.DependsOn),
+		Resources:         cloneResourcesFingerprint(service.Resources),
 	}
 	data, err := json.Marshal(fingerprint)
 	if err != nil {
@@ -194,12 +220,24 @@ func cloneBackupFingerprint(backup *config.BackupConfig) *backupFingerprint {
 	}
 	return &backupFingerprint{
 		Schedule: backup.Schedule,
-		Retain:   backup.Retain,
+		Retain:   backupRetentionFingerprint(backup.Retain),
 		Volumes:  sortedStrings(backup.Volumes),
 		Storage:  cloneBackupStorageFingerprint(backup.Storage),
 	}
 }
 
+// backupRetentionFingerprint keeps a flat day count hashed as the plain
+// number it always was.
+func backupRetentionFingerprint(retain config.BackupRetention) any {
+	if retain.IsGFS() {
+		return retain
+	}
+	if retain.Days == 0 {
+		return nil
+	}
+	return retain.Days
+}
+
 func cloneBackupStorageFingerprint(storage *config.BackupStorageConfig) *backupStorageFingerprint {
 	if storage == nil {
 		return nil
@@ -214,6 +252,16 @@ func cloneBackupStorageFingerprint(storage *config.BackupStorageConfig) *backupS
 		SecretAccessKeyConfigured: strings.TrimSpace(storage.SecretAccessKey) != "",
 		SessionTokenConfigured:    strings.TrimSpace(storage.SessionToken) != "",
 		ForcePathStyle:            storage.ForcePathStyle,
+		Format:                    storage.Format,
+		EncryptionKeyConfigured:   strings.TrimSpace(storage.EncryptionKey) != "",
+		Path:                      storage.Path,
+		Host:                      storage.Host,
+		Port:                      storage.Port,
+		User:                      storage.User,
+		PasswordConfigured:        storage.Password != "",
+		PrivateKeyConfigured:      strings.TrimSpace(storage.PrivateKey) != "",
+		HostKey:                   storage.HostKey,
+		Node:                      storage.Node,
 	}
 }
 
@@ -258,6 +306,15 @@ func cloneResourcesFingerprint(resources *config.ResourceLimitsConfig) *config.R
 	return &clone
 }
 
+// cloneSecretsDelivery keeps what shapes the containers. Env delivery hashes
+// like an unset block, and reload settings only matter to a later rotation.
+func cloneSecretsDelivery(delivery *config.SecretsDeliveryConfig) *config.SecretsDeliveryConfig {
+	if delivery == nil || delivery.Mode != config.SecretsDeliveryFiles {
+		return nil
+	}
+	return &config.SecretsDeliveryConfig{Mode: delivery.Mode, Owner: delivery.Owner}
+}
+
 func clonePlacement(placement *config.PlacementConfig) *config.PlacementConfig {
 	if placement == nil {
 		return nil
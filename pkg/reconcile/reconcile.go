@@ -229,6 +229,7 @@ type ActualService struct {
 	DeployStrategy    string
 	ActiveContainers  []string
 	WarmingContainers []string
+	WarmingHealth     string                // Worst docker health state of warming containers
 	ConfigSnapshot    *config.ServiceConfig // Last deployed config
 }
 
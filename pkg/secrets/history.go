@@ -0,0 +1,300 @@
+package secrets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/crypto"
+)
+
+// Secret history actions.
+const (
+	SecretActionBaseline = "baseline" // value found in the secrets file before history began
+	SecretActionSet      = "set"
+	SecretActionDelete   = "delete"
+	SecretActionRollback = "rollback"
+)
+
+// Sources reported by DeployedVersions.
+const (
+	SecretSourceEnvironment = "environment"
+	SecretSourceCommon      = "common"
+	SecretSourceProvider    = "provider"
+)
+
+// maxSecretVersions bounds the versions kept per key. Version numbers keep
+// counting after older entries are dropped.
+const maxSecretVersions = 100
+
+var (
+	secretHistoryNow = time.Now
+	secretAuthor     = currentSecretAuthor
+)
+
+// SecretVersion is one recorded change to a secret. Value is kept only in the
+// encrypted history file so rollback can restore it; History strips it.
+type SecretVersion struct {
+	Version      int       `json:"version"`
+	Action       string    `json:"action"`
+	SHA256       string    `json:"sha256,omitempty"`
+	Value        string    `json:"value,omitempty"`
+	Author       string    `json:"author"`
+	Timestamp    time.Time `json:"timestamp"`
+	RestoredFrom int       `json:"restoredFrom,omitempty"`
+}
+
+// SecretVersionRef names the secret version a deploy used. Version is 0 when
+// the value has no matching history entry (edited by hand, or sourced from
+// the environment's secretProvider).
+type SecretVersionRef struct {
+	Key     string `json:"key"`
+	Source  string `json:"source"`
+	Version int    `json:"version,omitempty"`
+}
+
+type secretHistoryFile struct {
+	Keys map[string][]SecretVersion `json:"keys"`
+}
+
+func currentSecretAuthor() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		name = u.Username
+	}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		name += "@" + hostname
+	}
+	return name
+}
+
+func secretValueHash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeSecretEnvironment(environment string) string {
+	if environment == "" {
+		return "common"
+	}
+	return environment
+}
+
+func (m *Manager) secretsFilePath(environment string) string {
+	if environment == "common" {
+		return filepath.Join(m.basePath, "secrets")
+	}
+	return filepath.Join(m.basePath, fmt.Sprintf("secrets.%s", environment))
+}
+
+// historyFilePath keeps history beside the secrets file it describes so it is
+// covered by the same .gitignore entry and `tako env push`.
+func (m *Manager) historyFilePath(environment string) string {
+	return filepath.Join(m.basePath, fmt.Sprintf("secrets.%s.history", environment))
+}
+
+func (m *Manager) readHistory(environment string) (*secretHistoryFile, error) {
+	history := &secretHistoryFile{Keys: make(map[string][]SecretVersion)}
+	encryptor, err := crypto.NewEncryptorFromKeyFile(crypto.GetProjectKeyPath("."))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load encryption key: %w", err)
+	}
+	data, err := encryptor.ReadEncryptedFile(m.historyFilePath(environment))
+	if errors.Is(err, os.ErrNotExist) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret history: %w", err)
+	}
+	if err := json.Unmarshal(data, history); err != nil {
+		return nil, fmt.Errorf("failed to parse secret history: %w", err)
+	}
+	if history.Keys == nil {
+		history.Keys = make(map[string][]SecretVersion)
+	}
+	return history, nil
+}
+
+func (m *Manager) writeHistory(environment string, history *secretHistoryFile) error {
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+	encryptor, err := crypto.NewEncryptorFromKeyFile(crypto.GetProjectKeyPath("."))
+	if err != nil {
+		return fmt.Errorf("failed to load encryption key: %w", err)
+	}
+	return encryptor.WriteEncryptedFile(m.historyFilePath(environment), data, 0600)
+}
+
+// recordVersion appends a version for key. previous is the value the secrets
+// file held before the change; when the key has no history yet it is recorded
+// first as a baseline so the pre-history value can still be restored.
+func (m *Manager) recordVersion(environment, key, action, value string, previous *string, restoredFrom int) error {
+	history, err := m.readHistory(environment)
+	if err != nil {
+		return err
+	}
+	versions := history.Keys[key]
+	next := 1
+	if len(versions) > 0 {
+		next = versions[len(versions)-1].Version + 1
+	}
+	now := secretHistoryNow().UTC()
+	if len(versions) == 0 && previous != nil {
+		versions = append(versions, SecretVersion{Version: next, Action: SecretActionBaseline, SHA256: secretValueHash(*previous), Value: *previous, Author: "unknown", Timestamp: now})
+		next++
+	}
+	entry := SecretVersion{Version: next, Action: action, Author: secretAuthor(), Timestamp: now, RestoredFrom: restoredFrom}
+	if action != SecretActionDelete {
+		entry.SHA256 = secretValueHash(value)
+		entry.Value = value
+	}
+	versions = append(versions, entry)
+	if len(versions) > maxSecretVersions {
+		versions = versions[len(versions)-maxSecretVersions:]
+	}
+	history.Keys[key] = versions
+	if err := m.writeHistory(environment, history); err != nil {
+		return fmt.Errorf("failed to record secret history: %w", err)
+	}
+	return nil
+}
+
+// History returns the recorded versions of key in environment's secrets file,
+// oldest first, without values.
+func (m *Manager) History(key, environment string) ([]SecretVersion, error) {
+	history, err := m.readHistory(normalizeSecretEnvironment(environment))
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]SecretVersion, 0, len(history.Keys[key]))
+	for _, version := range history.Keys[key] {
+		version.Value = ""
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+// Rollback restores key in environment to the value recorded as version to
+// and records the restore as a new version.
+func (m *Manager) Rollback(key, environment string, to int) (*SecretVersion, error) {
+	environment = normalizeSecretEnvironment(environment)
+	history, err := m.readHistory(environment)
+	if err != nil {
+		return nil, err
+	}
+	versions := history.Keys[key]
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("secret '%s' has no recorded history in %s", key, environment)
+	}
+	var target *SecretVersion
+	for i := range versions {
+		if versions[i].Version == to {
+			target = &versions[i]
+			break
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("secret '%s' has no version %d in %s (retained: %d-%d)", key, to, environment, versions[0].Version, versions[len(versions)-1].Version)
+	}
+	if target.Action == SecretActionDelete {
+		return nil, fmt.Errorf("version %d of secret '%s' is a deletion; use tako secrets delete instead", to, key)
+	}
+	if target.Version == versions[len(versions)-1].Version {
+		return nil, fmt.Errorf("secret '%s' is already at version %d", key, to)
+	}
+	if err := m.store(key, target.Value, environment, SecretActionRollback, to); err != nil {
+		return nil, err
+	}
+	latest, err := m.History(key, environment)
+	if err != nil || len(latest) == 0 {
+		return nil, err
+	}
+	return &latest[len(latest)-1], nil
+}
+
+// DeployedVersions reports which recorded version of each key a deploy to
+// environment reads, following the same precedence as loadSecrets: the
+// environment file, then the common file, then the secretProvider. It reads
+// the files directly, without running command substitutions.
+func DeployedVersions(environment string, keys []string) ([]SecretVersionRef, error) {
+	m := &Manager{environment: environment, basePath: ".tako"}
+	return m.deployedVersions(keys)
+}
+
+func (m *Manager) deployedVersions(keys []string) ([]SecretVersionRef, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	type layer struct {
+		source      string
+		environment string
+	}
+	layers := []layer{}
+	if m.environment != "" && m.environment != "common" {
+		layers = append(layers, layer{SecretSourceEnvironment, m.environment})
+	}
+	layers = append(layers, layer{SecretSourceCommon, "common"})
+
+	values := make([]map[string]string, len(layers))
+	histories := make([]*secretHistoryFile, len(layers))
+	for i, l := range layers {
+		file, err := readSecretsFile(m.secretsFilePath(l.environment))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		values[i] = file
+		if histories[i], err = m.readHistory(l.environment); err != nil {
+			return nil, err
+		}
+	}
+
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+	refs := make([]SecretVersionRef, 0, len(sorted))
+	for i, key := range sorted {
+		if i > 0 && sorted[i-1] == key {
+			continue
+		}
+		ref := SecretVersionRef{Key: key, Source: SecretSourceProvider}
+		for j, l := range layers {
+			value, ok := values[j][key]
+			if !ok {
+				continue
+			}
+			ref.Source = l.source
+			if versions := histories[j].Keys[key]; len(versions) > 0 {
+				latest := versions[len(versions)-1]
+				if latest.Action != SecretActionDelete && latest.SHA256 == secretValueHash(value) {
+					ref.Version = latest.Version
+				}
+			}
+			break
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// ServiceSecretKeys returns the secret keys a service reads, resolving
+// CONTAINER_VAR:SECRET_KEY aliases.
+func ServiceSecretKeys(secretRefs []string) []string {
+	keys := make([]string, 0, len(secretRefs))
+	for _, ref := range secretRefs {
+		if _, key, aliased := strings.Cut(ref, ":"); aliased {
+			keys = append(keys, key)
+		} else {
+			keys = append(keys, ref)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,126 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/crypto"
+)
+
+func useFixedSecretAuthor(t *testing.T) {
+	t.Helper()
+	oldNow, oldAuthor := secretHistoryNow, secretAuthor
+	secretHistoryNow = func() time.Time { return time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC) }
+	secretAuthor = func() string { return "ana@laptop" }
+	t.Cleanup(func() { secretHistoryNow, secretAuthor = oldNow, oldAuthor })
+}
+
+func TestManagerRecordsHistoryAndRollsBack(t *testing.T) {
+	withTempWorkingDir(t)
+	useFixedSecretAuthor(t)
+	if err := os.MkdirAll(".tako", 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(".tako", "secrets.production"), []byte("API_KEY=before-history\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	mgr, err := NewManager("production")
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	for _, value := range []string{"v2-key", "v2-key", "v3-key"} {
+		if err := mgr.Set("API_KEY", value, "production"); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	if err := mgr.Delete("API_KEY", "production"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	history, err := mgr.History("API_KEY", "production")
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	var actions []string
+	for _, version := range history {
+		actions = append(actions, version.Action)
+		if version.Value != "" {
+			t.Fatalf("History leaked value for version %d", version.Version)
+		}
+	}
+	if want := []string{SecretActionBaseline, SecretActionSet, SecretActionSet, SecretActionDelete}; !reflect.DeepEqual(actions, want) {
+		t.Fatalf("actions = %v, want %v", actions, want)
+	}
+	if history[1].SHA256 != secretValueHash("v2-key") || history[1].Author != "ana@laptop" || history[3].SHA256 != "" {
+		t.Fatalf("history = %+v", history)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(".tako", "secrets.production.history"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !crypto.IsEncrypted(raw) || strings.Contains(string(raw), "v2-key") {
+		t.Fatal("secret history must be encrypted at rest")
+	}
+
+	if _, err := mgr.Rollback("API_KEY", "production", 4); err == nil || !strings.Contains(err.Error(), "deletion") {
+		t.Fatalf("rollback to deletion error = %v", err)
+	}
+	if _, err := mgr.Rollback("API_KEY", "production", 9); err == nil || !strings.Contains(err.Error(), "no version 9") {
+		t.Fatalf("rollback to unknown version error = %v", err)
+	}
+	restored, err := mgr.Rollback("API_KEY", "production", 1)
+	if err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if restored.Version != 5 || restored.Action != SecretActionRollback || restored.RestoredFrom != 1 {
+		t.Fatalf("restored = %+v", restored)
+	}
+	if got, err := mgr.Get("API_KEY"); err != nil || got != "before-history" {
+		t.Fatalf("API_KEY = %q, %v", got, err)
+	}
+}
+
+func TestDeployedVersionsFollowsSecretPrecedence(t *testing.T) {
+	withTempWorkingDir(t)
+	useFixedSecretAuthor(t)
+	mgr, err := NewManager("production")
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	for _, set := range []struct{ key, value, env string }{
+		{"DATABASE_URL", "postgres://one", "production"},
+		{"DATABASE_URL", "postgres://two", "production"},
+		{"JWT_SECRET", "shared", ""},
+		{"HAND_EDITED", "recorded", "production"},
+	} {
+		if err := mgr.Set(set.key, set.value, set.env); err != nil {
+			t.Fatalf("Set %s: %v", set.key, err)
+		}
+	}
+	// A hand edit bypasses the history, so the deploy cannot name a version.
+	if err := mgr.writeSecrets(filepath.Join(".tako", "secrets.production"), map[string]string{
+		"DATABASE_URL": "postgres://two",
+		"HAND_EDITED":  "edited",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	refs, err := DeployedVersions("production", ServiceSecretKeys([]string{"DATABASE_URL", "JWT:JWT_SECRET", "HAND_EDITED", "STRIPE_KEY", "DATABASE_URL"}))
+	if err != nil {
+		t.Fatalf("DeployedVersions: %v", err)
+	}
+	want := []SecretVersionRef{
+		{Key: "DATABASE_URL", Source: SecretSourceEnvironment, Version: 2},
+		{Key: "HAND_EDITED", Source: SecretSourceEnvironment},
+		{Key: "JWT_SECRET", Source: SecretSourceCommon, Version: 1},
+		{Key: "STRIPE_KEY", Source: SecretSourceProvider},
+	}
+	if !reflect.DeepEqual(refs, want) {
+		t.Fatalf("refs = %+v, want %+v", refs, want)
+	}
+}
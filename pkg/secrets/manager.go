@@ -42,6 +42,9 @@ type Manager struct {
 	basePath    string
 	secrets     map[string]string
 	redactor    *Redactor
+	// providerSecrets are values read from the environment's secretProvider;
+	// the .tako/secrets files are layered over them.
+	providerSecrets map[string]string
 }
 
 // NewManager creates a new secrets manager for the given environment
@@ -55,34 +58,120 @@ func NewManager(environment string) (*Manager, error) {
 	// Create redactor
 	m.redactor = NewRedactor()
 
-	// Ensure directory exists with proper permissions
-	if err := os.MkdirAll(m.basePath, 0700); err != nil {
-		return nil, fmt.Errorf("failed to create secrets directory: %w", err)
+	if err := ensureSecretsDir(m.basePath); err != nil {
+		return nil, err
+	}
+
+	// Load secrets
+	if err := m.loadSecrets(); err != nil {
+		return nil, fmt.Errorf("failed to load secrets: %w", err)
+	}
+
+	return m, nil
+}
+
+// ensureSecretsDir creates basePath with proper permissions and a .gitignore
+// that keeps secrets out of version control.
+func ensureSecretsDir(basePath string) error {
+	if err := os.MkdirAll(basePath, 0700); err != nil {
+		return fmt.Errorf("failed to create secrets directory: %w", err)
 	}
 
 	// Create .gitignore if it doesn't exist
-	gitignorePath := filepath.Join(m.basePath, ".gitignore")
+	gitignorePath := filepath.Join(basePath, ".gitignore")
 	if _, err := os.Stat(gitignorePath); os.IsNotExist(err) {
 		if err := fileutil.WriteFileAtomic(gitignorePath, []byte(GitignoreContent), 0644); err != nil {
-			return nil, fmt.Errorf("failed to create .gitignore: %w", err)
+			return fmt.Errorf("failed to create .gitignore: %w", err)
 		}
 	}
+	return nil
+}
 
-	// Load secrets
-	if err := m.loadSecrets(); err != nil {
-		return nil, fmt.Errorf("failed to load secrets: %w", err)
+// NewManagerForConfig creates a secrets manager that also reads the
+// environment's secretProvider when tako.yaml configures one. Provider values
+// form the base layer; keys set in the .tako/secrets files override them.
+func NewManagerForConfig(cfg *config.Config, environment string) (*Manager, error) {
+	m, err := NewManager(environment)
+	if err != nil {
+		return nil, err
+	}
+	providerConfig := cfg.EnvironmentSecretProvider(environment)
+	if providerConfig == nil {
+		return m, nil
+	}
+	names, byName := m.providerSecretNames(cfg, environment, providerConfig)
+	if byName && len(names) == 0 {
+		return m, nil
+	}
+	provider, err := ProviderFromConfig(providerConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.LoadProvider(context.Background(), provider, names); err != nil {
+		return nil, err
 	}
-
 	return m, nil
 }
 
+// LoadProvider fetches names (or everything, when names is empty) from
+// provider and layers the .tako/secrets files back over the result.
+func (m *Manager) LoadProvider(ctx context.Context, provider SecretProvider, names []string) error {
+	values, err := provider.Fetch(ctx, names)
+	if err != nil {
+		return fmt.Errorf("failed to read secrets from %s: %w", provider.Name(), err)
+	}
+	m.mu.Lock()
+	m.providerSecrets = values
+	m.mu.Unlock()
+	return m.loadSecrets()
+}
+
+// providerSecretNames lists the secrets to ask a name-keyed provider for:
+// every key the environment's services reference that the .tako/secrets
+// files do not already set. byName is false for path-keyed providers, which
+// return their whole secret and get no names.
+func (m *Manager) providerSecretNames(cfg *config.Config, environment string, provider *config.SecretProviderConfig) (names []string, byName bool) {
+	switch normalizeProvider(provider.Provider) {
+	case ProviderGCP, ProviderAWSSecretsManager:
+	case ProviderAWSSSM:
+		if provider.Path != "" {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	services, err := cfg.GetServices(environment)
+	if err != nil {
+		return nil, true
+	}
+	seen := map[string]bool{}
+	for _, service := range services {
+		for _, ref := range service.Secrets {
+			key := ref
+			if _, alias, ok := strings.Cut(ref, ":"); ok {
+				key = alias
+			}
+			if seen[key] || m.Has(key) {
+				continue
+			}
+			seen[key] = true
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+	return names, true
+}
+
 // loadSecrets loads secrets from files (common first, then environment-specific)
 func (m *Manager) loadSecrets() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Clear existing secrets
-	m.secrets = make(map[string]string)
+	// Start from the provider's values; files override them.
+	m.secrets = make(map[string]string, len(m.providerSecrets))
+	for key, value := range m.providerSecrets {
+		m.secrets[key] = value
+	}
 
 	// Load common secrets first
 	commonPath := filepath.Join(m.basePath, "secrets")
@@ -218,19 +307,13 @@ func (m *Manager) Has(key string) bool {
 	return exists
 }
 
-// Set sets a secret value
+// Set sets a secret value and records the change in the secret history.
 func (m *Manager) Set(key, value string, environment string) error {
-	if environment == "" {
-		environment = "common"
-	}
+	return m.store(key, value, normalizeSecretEnvironment(environment), SecretActionSet, 0)
+}
 
-	// Determine file path
-	var path string
-	if environment == "common" {
-		path = filepath.Join(m.basePath, "secrets")
-	} else {
-		path = filepath.Join(m.basePath, fmt.Sprintf("secrets.%s", environment))
-	}
+func (m *Manager) store(key, value, environment, action string, restoredFrom int) error {
+	path := m.secretsFilePath(environment)
 
 	// Load existing secrets from the target file. Files may be encrypted from
 	// previous writes or plaintext placeholders from `tako secrets init`.
@@ -244,12 +327,22 @@ func (m *Manager) Set(key, value string, environment string) error {
 	}
 
 	// Update the value
+	previous, hadPrevious := existing[key]
 	existing[key] = value
 
 	// Write back to file
 	if err := m.writeSecrets(path, existing); err != nil {
 		return fmt.Errorf("failed to save secret: %w", err)
 	}
+	if !hadPrevious || previous != value || action == SecretActionRollback {
+		var prior *string
+		if hadPrevious {
+			prior = &previous
+		}
+		if err := m.recordVersion(environment, key, action, value, prior, restoredFrom); err != nil {
+			return err
+		}
+	}
 
 	// Reload secrets
 	return m.loadSecrets()
@@ -300,19 +393,10 @@ func (m *Manager) List() []string {
 	return keys
 }
 
-// Delete removes a secret
+// Delete removes a secret and records the deletion in the secret history.
 func (m *Manager) Delete(key string, environment string) error {
-	if environment == "" {
-		environment = "common"
-	}
-
-	// Determine file path
-	var path string
-	if environment == "common" {
-		path = filepath.Join(m.basePath, "secrets")
-	} else {
-		path = filepath.Join(m.basePath, fmt.Sprintf("secrets.%s", environment))
-	}
+	environment = normalizeSecretEnvironment(environment)
+	path := m.secretsFilePath(environment)
 
 	// Load existing secrets from the target file. Files may be encrypted from
 	// previous writes or plaintext placeholders from `tako secrets init`.
@@ -326,12 +410,18 @@ func (m *Manager) Delete(key string, environment string) error {
 	}
 
 	// Delete the key
+	previous, hadPrevious := existing[key]
 	delete(existing, key)
 
 	// Write back
 	if err := m.writeSecrets(path, existing); err != nil {
 		return fmt.Errorf("failed to delete secret: %w", err)
 	}
+	if hadPrevious {
+		if err := m.recordVersion(environment, key, SecretActionDelete, "", &previous, 0); err != nil {
+			return err
+		}
+	}
 
 	// Reload secrets
 	return m.loadSecrets()
@@ -509,6 +599,20 @@ func (m *Manager) CreateEnvFile(service *config.ServiceConfig) (*EnvFile, error)
 	return envFile, nil
 }
 
+// SecretFiles resolves the secrets a service receives through files delivery,
+// keyed by file name under /run/secrets.
+func (m *Manager) SecretFiles(service *config.ServiceConfig) (map[string]string, error) {
+	files := make(map[string]string, len(service.Secrets))
+	for name, key := range service.SecretFileNames() {
+		value, err := m.Get(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve secret '%s': %w", key, err)
+		}
+		files[name] = value
+	}
+	return files, nil
+}
+
 // ValidateRequired checks if all required secrets are present
 func (m *Manager) ValidateRequired(required []string) error {
 	m.mu.RLock()
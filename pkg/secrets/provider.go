@@ -3,6 +3,7 @@ package secrets
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -14,6 +15,11 @@ import (
 const (
 	ProviderAWSSSM            = "aws-ssm"
 	ProviderAWSSecretsManager = "aws-secrets-manager"
+	ProviderVault             = "vault"
+	ProviderOnePassword       = "1password"
+	ProviderDoppler           = "doppler"
+	ProviderGCP               = "gcp-secret-manager"
+	ProviderSOPS              = "sops"
 
 	awsSSMMaxNamesPerRequest = 10
 )
@@ -23,35 +29,151 @@ var (
 	providerCommandTimeout = 2 * time.Minute
 )
 
+var (
+	// ErrSecretNotFound reports a secret, path, or item the provider does
+	// not have.
+	ErrSecretNotFound = errors.New("secret not found")
+	// ErrProviderAuth reports missing or rejected provider credentials.
+	ErrProviderAuth = errors.New("secret provider rejected the credentials")
+	// ErrProviderUnavailable reports a provider that could not be reached
+	// or failed on its side; retrying later may succeed.
+	ErrProviderUnavailable = errors.New("secret provider unavailable")
+)
+
+// ProviderError is returned by every SecretProvider so callers can tell a
+// missing secret from bad credentials or an outage with errors.Is.
+type ProviderError struct {
+	Provider string
+	// Secret names the secret, path, or item involved, when there is one.
+	Secret string
+	// Status is the HTTP status of API providers, or 0.
+	Status int
+	Err    error
+}
+
+func (e *ProviderError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Provider)
+	if e.Secret != "" {
+		fmt.Fprintf(&b, " %s", e.Secret)
+	}
+	if e.Status != 0 {
+		fmt.Fprintf(&b, " (HTTP %d)", e.Status)
+	}
+	fmt.Fprintf(&b, ": %v", e.Err)
+	return b.String()
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// SecretProvider reads secret values from an external store. Fetch returns
+// values keyed by secret name; with no names it returns everything the
+// provider's configured path holds, when the store can list it.
+type SecretProvider interface {
+	Name() string
+	Fetch(ctx context.Context, names []string) (map[string]string, error)
+}
+
 type ProviderOptions struct {
 	Profile string
 	Region  string
 	Path    string
 	From    string
+	// Address is the Vault or 1Password Connect URL, or an API endpoint
+	// override for Doppler and GCP.
+	Address   string
+	Mount     string
+	Project   string
+	Version   string
+	Namespace string
+	// TokenEnv names the environment variable holding the provider token
+	// instead of the provider's usual one.
+	TokenEnv string
 }
 
-func FetchProviderSecrets(ctx context.Context, provider string, names []string, options ProviderOptions) (map[string]string, error) {
+// NewProvider returns the named provider configured by options.
+func NewProvider(provider string, options ProviderOptions) (SecretProvider, error) {
 	switch normalizeProvider(provider) {
 	case ProviderAWSSSM:
-		return fetchAWSSSMSecrets(ctx, names, options)
+		return awsSSMProvider{options: options}, nil
 	case ProviderAWSSecretsManager:
-		return fetchAWSSecretsManagerSecrets(ctx, names, options)
+		return awsSecretsManagerProvider{options: options}, nil
+	case ProviderVault:
+		return newVaultProvider(options)
+	case ProviderOnePassword:
+		return newOnePasswordProvider(options)
+	case ProviderDoppler:
+		return newDopplerProvider(options)
+	case ProviderGCP:
+		return newGCPProvider(options)
+	case ProviderSOPS:
+		return newSOPSProvider(options)
 	default:
 		return nil, fmt.Errorf("unsupported secrets provider %q", provider)
 	}
 }
 
+func FetchProviderSecrets(ctx context.Context, provider string, names []string, options ProviderOptions) (map[string]string, error) {
+	p, err := NewProvider(provider, options)
+	if err != nil {
+		return nil, err
+	}
+	return p.Fetch(ctx, names)
+}
+
 func normalizeProvider(provider string) string {
 	switch strings.ToLower(strings.TrimSpace(provider)) {
 	case "aws-ssm", "ssm", "aws_ssm", "aws-ssm-parameter-store", "aws_ssm_parameter_store":
 		return ProviderAWSSSM
 	case "aws-secrets-manager", "aws_secrets_manager", "secretsmanager", "aws-sm":
 		return ProviderAWSSecretsManager
+	case "vault", "hashicorp-vault", "vault-kv":
+		return ProviderVault
+	case "1password", "onepassword", "op", "1password-connect":
+		return ProviderOnePassword
+	case "gcp-secret-manager", "gcp", "google-secret-manager", "gcp_secret_manager":
+		return ProviderGCP
 	default:
 		return strings.ToLower(strings.TrimSpace(provider))
 	}
 }
 
+// providerToken reads the provider token from options.TokenEnv or the
+// provider's usual environment variable.
+func providerToken(provider string, options ProviderOptions, defaultEnv string) (string, error) {
+	name := options.TokenEnv
+	if name == "" {
+		name = defaultEnv
+	}
+	token := strings.TrimSpace(os.Getenv(name))
+	if token == "" {
+		return "", &ProviderError{Provider: provider, Err: fmt.Errorf("%w: set %s", ErrProviderAuth, name)}
+	}
+	return token, nil
+}
+
+type awsSSMProvider struct {
+	options ProviderOptions
+}
+
+func (p awsSSMProvider) Name() string { return ProviderAWSSSM }
+
+func (p awsSSMProvider) Fetch(ctx context.Context, names []string) (map[string]string, error) {
+	return fetchAWSSSMSecrets(ctx, names, p.options)
+}
+
+type awsSecretsManagerProvider struct {
+	options ProviderOptions
+}
+
+func (p awsSecretsManagerProvider) Name() string { return ProviderAWSSecretsManager }
+
+func (p awsSecretsManagerProvider) Fetch(ctx context.Context, names []string) (map[string]string, error) {
+	return fetchAWSSecretsManagerSecrets(ctx, names, p.options)
+}
+
 func fetchAWSSSMSecrets(ctx context.Context, names []string, options ProviderOptions) (map[string]string, error) {
 	if strings.TrimSpace(options.Path) != "" {
 		return fetchAWSSSMSecretsByPath(ctx, options)
@@ -0,0 +1,120 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/config"
+)
+
+// DefaultProviderCacheTTL is how long fetched values are reused when the
+// environment's secretProvider sets no cacheTTL.
+const DefaultProviderCacheTTL = 5 * time.Minute
+
+var (
+	sharedProvidersMu sync.Mutex
+	sharedProviders   = map[string]SecretProvider{}
+)
+
+// cachedProvider remembers each Fetch result for ttl so a deploy that builds
+// many env files asks the store once. Failures are never cached.
+type cachedProvider struct {
+	provider SecretProvider
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cachedFetch
+}
+
+type cachedFetch struct {
+	values  map[string]string
+	expires time.Time
+}
+
+// NewCachedProvider wraps provider so identical fetches within ttl are
+// answered from memory.
+func NewCachedProvider(provider SecretProvider, ttl time.Duration) SecretProvider {
+	if ttl <= 0 {
+		return provider
+	}
+	return &cachedProvider{provider: provider, ttl: ttl, now: time.Now, entries: map[string]cachedFetch{}}
+}
+
+func (p *cachedProvider) Name() string { return p.provider.Name() }
+
+func (p *cachedProvider) Fetch(ctx context.Context, names []string) (map[string]string, error) {
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+	key := strings.Join(sorted, "\x00")
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if entry, ok := p.entries[key]; ok && p.now().Before(entry.expires) {
+		return copySecretValues(entry.values), nil
+	}
+	values, err := p.provider.Fetch(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	p.entries[key] = cachedFetch{values: copySecretValues(values), expires: p.now().Add(p.ttl)}
+	return values, nil
+}
+
+func copySecretValues(values map[string]string) map[string]string {
+	out := make(map[string]string, len(values))
+	for key, value := range values {
+		out[key] = value
+	}
+	return out
+}
+
+// ProviderOptionsFromConfig converts an environment's secretProvider block.
+func ProviderOptionsFromConfig(cfg *config.SecretProviderConfig) ProviderOptions {
+	return ProviderOptions{
+		Profile:   cfg.Profile,
+		Region:    cfg.Region,
+		Path:      cfg.Path,
+		Address:   cfg.Address,
+		Mount:     cfg.Mount,
+		Project:   cfg.Project,
+		Version:   cfg.Version,
+		Namespace: cfg.Namespace,
+		TokenEnv:  cfg.TokenEnv,
+	}
+}
+
+// ProviderFromConfig returns the cached provider for an environment's
+// secretProvider block. Blocks with the same settings share one cache for
+// the life of the process.
+func ProviderFromConfig(cfg *config.SecretProviderConfig) (SecretProvider, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	ttl := DefaultProviderCacheTTL
+	if cfg.CacheTTL != "" {
+		parsed, err := time.ParseDuration(cfg.CacheTTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secretProvider.cacheTTL: %w", err)
+		}
+		ttl = parsed
+	}
+	key := fmt.Sprintf("%#v", *cfg)
+
+	sharedProvidersMu.Lock()
+	defer sharedProvidersMu.Unlock()
+	if provider, ok := sharedProviders[key]; ok {
+		return provider, nil
+	}
+	provider, err := NewProvider(cfg.Provider, ProviderOptionsFromConfig(cfg))
+	if err != nil {
+		return nil, err
+	}
+	provider = NewCachedProvider(provider, ttl)
+	sharedProviders[key] = provider
+	return provider, nil
+}
@@ -0,0 +1,109 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/config"
+)
+
+type countingProvider struct {
+	calls  int
+	values map[string]string
+	err    error
+}
+
+func (p *countingProvider) Name() string { return "counting" }
+
+func (p *countingProvider) Fetch(ctx context.Context, names []string) (map[string]string, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return copySecretValues(p.values), nil
+}
+
+func TestCachedProviderReusesFetchesUntilExpiry(t *testing.T) {
+	inner := &countingProvider{values: map[string]string{"A": "1"}}
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	provider := NewCachedProvider(inner, time.Minute).(*cachedProvider)
+	provider.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		got, err := provider.Fetch(context.Background(), []string{"A"})
+		if err != nil || got["A"] != "1" {
+			t.Fatalf("Fetch = %#v, %v", got, err)
+		}
+		got["A"] = "mutated"
+	}
+	if inner.calls != 1 {
+		t.Fatalf("inner calls = %d, want 1", inner.calls)
+	}
+	if _, err := provider.Fetch(context.Background(), nil); err != nil || inner.calls != 2 {
+		t.Fatalf("different names should fetch again: calls=%d err=%v", inner.calls, err)
+	}
+	now = now.Add(2 * time.Minute)
+	inner.err = errors.New("down")
+	if _, err := provider.Fetch(context.Background(), []string{"A"}); err == nil || inner.calls != 3 {
+		t.Fatalf("expired entry should refetch: calls=%d err=%v", inner.calls, err)
+	}
+	inner.err = nil
+	if _, err := provider.Fetch(context.Background(), []string{"A"}); err != nil || inner.calls != 4 {
+		t.Fatalf("failures must not be cached: calls=%d err=%v", inner.calls, err)
+	}
+}
+
+func TestNewManagerForConfigLayersFilesOverProvider(t *testing.T) {
+	withTempWorkingDir(t)
+	t.Setenv("VAULT_TOKEN", "s.test-token")
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte(`{"data":{"data":{"DATABASE_URL":"from-vault","JWT_SECRET":"vault-jwt"}}}`))
+	}))
+	defer server.Close()
+
+	local, err := NewManager("production")
+	if err != nil {
+		t.Fatalf("NewManager returned error: %v", err)
+	}
+	if err := local.Set("JWT_SECRET", "local-jwt", "production"); err != nil {
+		t.Fatalf("Set returned error: %v", err)
+	}
+
+	cfg := &config.Config{Environments: map[string]config.EnvironmentConfig{
+		"production": {SecretProvider: &config.SecretProviderConfig{Provider: config.SecretProviderVault, Address: server.URL, Path: "myapp/production-layering"}},
+	}}
+	for i := 0; i < 2; i++ {
+		mgr, err := NewManagerForConfig(cfg, "production")
+		if err != nil {
+			t.Fatalf("NewManagerForConfig returned error: %v", err)
+		}
+		if got, _ := mgr.Get("DATABASE_URL"); got != "from-vault" {
+			t.Fatalf("DATABASE_URL = %q, want the provider value", got)
+		}
+		if got, _ := mgr.Get("JWT_SECRET"); got != "local-jwt" {
+			t.Fatalf("JWT_SECRET = %q, want the local file to win", got)
+		}
+		if err := mgr.Set("EXTRA", "x", "production"); err != nil {
+			t.Fatalf("Set returned error: %v", err)
+		}
+		if got, _ := mgr.Get("DATABASE_URL"); got != "from-vault" {
+			t.Fatalf("DATABASE_URL after Set = %q, want provider values kept", got)
+		}
+	}
+	if requests.Load() != 1 {
+		t.Fatalf("vault requests = %d, want one cached fetch", requests.Load())
+	}
+
+	cfg.Environments["production"].SecretProvider.Path = "myapp/other"
+	server.Close()
+	if _, err := NewManagerForConfig(cfg, "production"); !errors.Is(err, ErrProviderUnavailable) {
+		t.Fatalf("unreachable provider error = %v, want ErrProviderUnavailable", err)
+	}
+}
@@ -0,0 +1,64 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const dopplerAPIAddress = "https://api.doppler.com"
+
+// dopplerProvider downloads a Doppler config's secrets. Path is
+// "project/config"; a service token already scoped to one config may leave
+// it empty.
+type dopplerProvider struct {
+	address string
+	project string
+	config  string
+	options ProviderOptions
+}
+
+func newDopplerProvider(options ProviderOptions) (SecretProvider, error) {
+	provider := dopplerProvider{address: providerEndpoint(options, dopplerAPIAddress), options: options}
+	if path := strings.Trim(strings.TrimSpace(options.Path), "/"); path != "" {
+		project, config, ok := strings.Cut(path, "/")
+		if !ok || project == "" || config == "" {
+			return nil, fmt.Errorf("doppler path must be project/config, got %q", path)
+		}
+		provider.project = project
+		provider.config = config
+	}
+	return provider, nil
+}
+
+func (p dopplerProvider) Name() string { return ProviderDoppler }
+
+func (p dopplerProvider) Fetch(ctx context.Context, names []string) (map[string]string, error) {
+	token, err := providerToken(ProviderDoppler, p.options, "DOPPLER_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"format": {"json"}, "include_dynamic_secrets": {"false"}}
+	source := "service token config"
+	if p.project != "" {
+		query.Set("project", p.project)
+		query.Set("config", p.config)
+		source = p.project + "/" + p.config
+	}
+	endpoint := p.address + "/v3/configs/config/secrets/download?" + query.Encode()
+
+	var response map[string]any
+	if err := providerGetJSON(ctx, ProviderDoppler, source, endpoint, map[string]string{"Authorization": "Bearer " + token}, &response); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(response))
+	for key, value := range response {
+		// Doppler adds its own metadata keys to every download.
+		if strings.HasPrefix(key, "DOPPLER_") {
+			continue
+		}
+		values[key] = stringifySecretValue(value)
+	}
+	return selectSecretNames(ProviderDoppler, source, values, names)
+}
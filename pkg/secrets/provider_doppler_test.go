@@ -0,0 +1,34 @@
+package secrets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDopplerProviderDownloadsConfigSecrets(t *testing.T) {
+	t.Setenv("DOPPLER_TOKEN", "dp.st.test")
+	var gotPath, gotProject, gotConfig, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth = r.URL.Path, r.Header.Get("Authorization")
+		gotProject, gotConfig = r.URL.Query().Get("project"), r.URL.Query().Get("config")
+		_, _ = w.Write([]byte(`{"API_KEY":"abc","DOPPLER_PROJECT":"myapp","DOPPLER_CONFIG":"prd","DOPPLER_ENVIRONMENT":"prd"}`))
+	}))
+	defer server.Close()
+
+	got, err := FetchProviderSecrets(context.Background(), "doppler", nil, ProviderOptions{Address: server.URL, Path: "myapp/prd"})
+	if err != nil {
+		t.Fatalf("FetchProviderSecrets returned error: %v", err)
+	}
+	if len(got) != 1 || got["API_KEY"] != "abc" {
+		t.Fatalf("secrets = %#v, want Doppler metadata keys dropped", got)
+	}
+	if gotPath != "/v3/configs/config/secrets/download" || gotProject != "myapp" || gotConfig != "prd" || gotAuth != "Bearer dp.st.test" {
+		t.Fatalf("request path=%q project=%q config=%q auth=%q", gotPath, gotProject, gotConfig, gotAuth)
+	}
+
+	if _, err := NewProvider("doppler", ProviderOptions{Path: "myapp"}); err == nil {
+		t.Fatal("NewProvider accepted a path without a config")
+	}
+}
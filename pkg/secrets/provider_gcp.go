@@ -0,0 +1,118 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const gcpSecretManagerAddress = "https://secretmanager.googleapis.com"
+
+// gcpProvider reads Google Cloud Secret Manager secrets by ID. With no names
+// it reads every secret in the project.
+type gcpProvider struct {
+	address string
+	project string
+	version string
+	options ProviderOptions
+}
+
+func newGCPProvider(options ProviderOptions) (SecretProvider, error) {
+	project := strings.TrimSpace(options.Project)
+	if project == "" {
+		project = strings.TrimSpace(os.Getenv("GOOGLE_CLOUD_PROJECT"))
+	}
+	if project == "" {
+		return nil, fmt.Errorf("gcp project is required; pass --project or set GOOGLE_CLOUD_PROJECT")
+	}
+	version := strings.TrimSpace(options.Version)
+	if version == "" {
+		version = "latest"
+	}
+	return gcpProvider{address: providerEndpoint(options, gcpSecretManagerAddress), project: project, version: version, options: options}, nil
+}
+
+func (p gcpProvider) Name() string { return ProviderGCP }
+
+func (p gcpProvider) Fetch(ctx context.Context, names []string) (map[string]string, error) {
+	token, err := p.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	headers := map[string]string{"Authorization": "Bearer " + token}
+
+	names = prefixedSecretNames(names, "")
+	if len(names) == 0 {
+		names, err = p.listSecrets(ctx, headers)
+		if err != nil {
+			return nil, err
+		}
+	}
+	results := make(map[string]string, len(names))
+	for _, name := range names {
+		endpoint := fmt.Sprintf("%s/v1/projects/%s/secrets/%s/versions/%s:access", p.address, url.PathEscape(p.project), url.PathEscape(name), url.PathEscape(p.version))
+		var response struct {
+			Payload struct {
+				Data string `json:"data"`
+			} `json:"payload"`
+		}
+		if err := providerGetJSON(ctx, ProviderGCP, name, endpoint, headers, &response); err != nil {
+			return nil, err
+		}
+		value, err := base64.StdEncoding.DecodeString(response.Payload.Data)
+		if err != nil {
+			return nil, &ProviderError{Provider: ProviderGCP, Secret: name, Err: fmt.Errorf("invalid payload encoding: %w", err)}
+		}
+		results[name] = string(value)
+	}
+	return results, nil
+}
+
+func (p gcpProvider) listSecrets(ctx context.Context, headers map[string]string) ([]string, error) {
+	prefix := "projects/" + p.project + "/secrets/"
+	var names []string
+	pageToken := ""
+	for {
+		query := url.Values{"pageSize": {"250"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		endpoint := fmt.Sprintf("%s/v1/projects/%s/secrets?%s", p.address, url.PathEscape(p.project), query.Encode())
+		var response struct {
+			Secrets []struct {
+				Name string `json:"name"`
+			} `json:"secrets"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := providerGetJSON(ctx, ProviderGCP, "projects/"+p.project, endpoint, headers, &response); err != nil {
+			return nil, err
+		}
+		for _, secret := range response.Secrets {
+			names = append(names, strings.TrimPrefix(secret.Name, prefix))
+		}
+		if response.NextPageToken == "" {
+			return names, nil
+		}
+		pageToken = response.NextPageToken
+	}
+}
+
+// accessToken reads GOOGLE_OAUTH_ACCESS_TOKEN (or TokenEnv) and falls back
+// to the gcloud CLI's application credentials.
+func (p gcpProvider) accessToken(ctx context.Context) (string, error) {
+	token, err := providerToken(ProviderGCP, p.options, "GOOGLE_OAUTH_ACCESS_TOKEN")
+	if err == nil || p.options.TokenEnv != "" {
+		return token, err
+	}
+	output, cmdErr := runProviderCommand(ctx, "gcloud", "auth", "print-access-token")
+	if cmdErr != nil {
+		return "", &ProviderError{Provider: ProviderGCP, Err: fmt.Errorf("%w: set GOOGLE_OAUTH_ACCESS_TOKEN or log in with gcloud: %v", ErrProviderAuth, cmdErr)}
+	}
+	if token = strings.TrimSpace(output); token == "" {
+		return "", &ProviderError{Provider: ProviderGCP, Err: fmt.Errorf("%w: gcloud printed no access token", ErrProviderAuth)}
+	}
+	return token, nil
+}
@@ -0,0 +1,62 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGCPProviderAccessesSecretVersions(t *testing.T) {
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "ya29.test")
+	var accessed []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ya29.test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/v1/projects/acme/secrets" && r.URL.Query().Get("pageToken") == "":
+			_, _ = w.Write([]byte(`{"secrets":[{"name":"projects/acme/secrets/DATABASE_URL"}],"nextPageToken":"page-2"}`))
+		case r.URL.Path == "/v1/projects/acme/secrets":
+			_, _ = w.Write([]byte(`{"secrets":[{"name":"projects/acme/secrets/JWT_SECRET"}]}`))
+		case strings.HasSuffix(r.URL.Path, ":access"):
+			accessed = append(accessed, strings.TrimPrefix(r.URL.Path, "/v1/projects/acme/secrets/"))
+			name := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/projects/acme/secrets/"), "/")[0]
+			if name == "MISSING" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			payload := base64.StdEncoding.EncodeToString([]byte("value-" + name))
+			_, _ = w.Write([]byte(`{"name":"` + name + `","payload":{"data":"` + payload + `"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	got, err := FetchProviderSecrets(context.Background(), "gcp", []string{"DATABASE_URL"}, ProviderOptions{Address: server.URL, Project: "acme", Version: "7"})
+	if err != nil {
+		t.Fatalf("FetchProviderSecrets returned error: %v", err)
+	}
+	if got["DATABASE_URL"] != "value-DATABASE_URL" || len(accessed) != 1 || accessed[0] != "DATABASE_URL/versions/7:access" {
+		t.Fatalf("secrets = %#v accessed = %v", got, accessed)
+	}
+
+	accessed = nil
+	got, err = FetchProviderSecrets(context.Background(), "gcp-secret-manager", nil, ProviderOptions{Address: server.URL, Project: "acme"})
+	if err != nil {
+		t.Fatalf("FetchProviderSecrets without names returned error: %v", err)
+	}
+	if len(got) != 2 || got["JWT_SECRET"] != "value-JWT_SECRET" || accessed[0] != "DATABASE_URL/versions/latest:access" {
+		t.Fatalf("listed secrets = %#v accessed = %v", got, accessed)
+	}
+
+	_, err = FetchProviderSecrets(context.Background(), "gcp", []string{"MISSING"}, ProviderOptions{Address: server.URL, Project: "acme"})
+	if !errors.Is(err, ErrSecretNotFound) || !strings.Contains(err.Error(), "MISSING") {
+		t.Fatalf("missing secret error = %v, want ErrSecretNotFound", err)
+	}
+}
@@ -0,0 +1,120 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	maxProviderResponseBytes = 8 << 20
+	maxProviderErrorBytes    = 512
+)
+
+var providerHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// providerGetJSON GETs url and decodes the JSON body into out. Failures come
+// back as *ProviderError wrapping ErrSecretNotFound for 404,
+// ErrProviderAuth for 401/403, and ErrProviderUnavailable for transport
+// errors, 429, and 5xx.
+func providerGetJSON(ctx context.Context, provider string, secret string, url string, headers map[string]string, out any) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return &ProviderError{Provider: provider, Secret: secret, Err: err}
+	}
+	req.Header.Set("Accept", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := providerHTTPClient.Do(req)
+	if err != nil {
+		return &ProviderError{Provider: provider, Secret: secret, Err: fmt.Errorf("%w: %v", ErrProviderUnavailable, err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, maxProviderErrorBytes))
+		return &ProviderError{Provider: provider, Secret: secret, Status: resp.StatusCode, Err: providerStatusError(resp.StatusCode, detail)}
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxProviderResponseBytes+1))
+	if err != nil {
+		return &ProviderError{Provider: provider, Secret: secret, Err: fmt.Errorf("%w: %v", ErrProviderUnavailable, err)}
+	}
+	if len(data) > maxProviderResponseBytes {
+		return &ProviderError{Provider: provider, Secret: secret, Err: fmt.Errorf("response is larger than %d bytes", maxProviderResponseBytes)}
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return &ProviderError{Provider: provider, Secret: secret, Err: fmt.Errorf("failed to parse response: %w", err)}
+	}
+	return nil
+}
+
+func providerStatusError(status int, detail []byte) error {
+	var kind error
+	switch {
+	case status == http.StatusNotFound:
+		kind = ErrSecretNotFound
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		kind = ErrProviderAuth
+	case status == http.StatusTooManyRequests || status >= 500:
+		kind = ErrProviderUnavailable
+	default:
+		kind = errors.New(http.StatusText(status))
+	}
+	message := strings.Join(strings.Fields(string(detail)), " ")
+	if message == "" {
+		return kind
+	}
+	return fmt.Errorf("%w: %s", kind, message)
+}
+
+// providerEndpoint returns the configured address, or the provider's public
+// API when none is set.
+func providerEndpoint(options ProviderOptions, fallback string) string {
+	if address := strings.TrimRight(strings.TrimSpace(options.Address), "/"); address != "" {
+		return address
+	}
+	return fallback
+}
+
+// stringifySecretValue renders a non-string JSON value the way
+// flattenSecretValue does.
+func stringifySecretValue(value any) string {
+	if str, ok := value.(string); ok {
+		return str
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+// selectSecretNames keeps only the requested names, reporting the first one
+// the provider did not return. With no names every value is kept.
+func selectSecretNames(provider string, source string, values map[string]string, names []string) (map[string]string, error) {
+	if len(names) == 0 {
+		return values, nil
+	}
+	selected := make(map[string]string, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		value, ok := values[name]
+		if !ok {
+			return nil, &ProviderError{Provider: provider, Secret: source, Err: fmt.Errorf("%w: no key %s", ErrSecretNotFound, name)}
+		}
+		selected[name] = value
+	}
+	return selected, nil
+}
@@ -0,0 +1,140 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// onePasswordProvider reads the fields of one 1Password item through a
+// 1Password Connect server. Path is "vault/item" by name or ID; field labels
+// become secret names. Names may also be full "vault/item/field" references,
+// which need no Path.
+type onePasswordProvider struct {
+	address string
+	path    string
+	options ProviderOptions
+}
+
+type onePasswordItem struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Fields []struct {
+		ID      string `json:"id"`
+		Label   string `json:"label"`
+		Value   string `json:"value"`
+		Purpose string `json:"purpose"`
+	} `json:"fields"`
+}
+
+func newOnePasswordProvider(options ProviderOptions) (SecretProvider, error) {
+	address := strings.TrimRight(strings.TrimSpace(options.Address), "/")
+	if address == "" {
+		address = strings.TrimRight(strings.TrimSpace(os.Getenv("OP_CONNECT_HOST")), "/")
+	}
+	if address == "" {
+		return nil, fmt.Errorf("1password Connect address is required; pass --address or set OP_CONNECT_HOST")
+	}
+	return onePasswordProvider{address: address, path: strings.Trim(strings.TrimSpace(options.Path), "/"), options: options}, nil
+}
+
+func (p onePasswordProvider) Name() string { return ProviderOnePassword }
+
+func (p onePasswordProvider) Fetch(ctx context.Context, names []string) (map[string]string, error) {
+	token, err := providerToken(ProviderOnePassword, p.options, "OP_CONNECT_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+	headers := map[string]string{"Authorization": "Bearer " + token}
+
+	if p.path != "" {
+		vault, item, ok := strings.Cut(p.path, "/")
+		if !ok || vault == "" || item == "" {
+			return nil, fmt.Errorf("1password path must be vault/item, got %q", p.path)
+		}
+		values, err := p.itemFields(ctx, headers, vault, item)
+		if err != nil {
+			return nil, err
+		}
+		return selectSecretNames(ProviderOnePassword, p.path, values, names)
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("provide 1Password vault/item/field references or --path vault/item")
+	}
+	items := map[string]map[string]string{}
+	results := make(map[string]string, len(names))
+	for _, name := range names {
+		name = strings.Trim(strings.TrimSpace(name), "/")
+		parts := strings.SplitN(name, "/", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("1password reference %q must be vault/item/field", name)
+		}
+		itemPath := parts[0] + "/" + parts[1]
+		fields, ok := items[itemPath]
+		if !ok {
+			fields, err = p.itemFields(ctx, headers, parts[0], parts[1])
+			if err != nil {
+				return nil, err
+			}
+			items[itemPath] = fields
+		}
+		value, ok := fields[parts[2]]
+		if !ok {
+			return nil, &ProviderError{Provider: ProviderOnePassword, Secret: itemPath, Err: fmt.Errorf("%w: no field %s", ErrSecretNotFound, parts[2])}
+		}
+		results[name] = value
+	}
+	return results, nil
+}
+
+// itemFields returns an item's fields by label. Vault and item are looked
+// up by name first and used as IDs when no name matches.
+func (p onePasswordProvider) itemFields(ctx context.Context, headers map[string]string, vault string, item string) (map[string]string, error) {
+	source := vault + "/" + item
+	vaultID, err := p.lookupID(ctx, headers, source, p.address+"/v1/vaults", "name", vault)
+	if err != nil {
+		return nil, err
+	}
+	itemsURL := fmt.Sprintf("%s/v1/vaults/%s/items", p.address, url.PathEscape(vaultID))
+	itemID, err := p.lookupID(ctx, headers, source, itemsURL, "title", item)
+	if err != nil {
+		return nil, err
+	}
+	var response onePasswordItem
+	if err := providerGetJSON(ctx, ProviderOnePassword, source, itemsURL+"/"+url.PathEscape(itemID), headers, &response); err != nil {
+		return nil, err
+	}
+	fields := make(map[string]string, len(response.Fields))
+	for _, field := range response.Fields {
+		label := field.Label
+		if label == "" {
+			label = field.ID
+		}
+		if label == "" || field.Value == "" {
+			continue
+		}
+		fields[label] = field.Value
+	}
+	return fields, nil
+}
+
+func (p onePasswordProvider) lookupID(ctx context.Context, headers map[string]string, source string, listURL string, attribute string, value string) (string, error) {
+	filter := fmt.Sprintf("%s eq %q", attribute, value)
+	var matches []struct {
+		ID string `json:"id"`
+	}
+	if err := providerGetJSON(ctx, ProviderOnePassword, source, listURL+"?filter="+url.QueryEscape(filter), headers, &matches); err != nil {
+		return "", err
+	}
+	switch len(matches) {
+	case 0:
+		return value, nil
+	case 1:
+		return matches[0].ID, nil
+	default:
+		return "", &ProviderError{Provider: ProviderOnePassword, Secret: source, Err: fmt.Errorf("%d entries are named %q; use its ID", len(matches), value)}
+	}
+}
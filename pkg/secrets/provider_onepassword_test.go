@@ -0,0 +1,74 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func fakeOnePasswordConnect(t *testing.T) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer connect-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/vaults":
+			if r.URL.Query().Get("filter") == `name eq "Production"` {
+				_, _ = w.Write([]byte(`[{"id":"vault123","name":"Production"}]`))
+				return
+			}
+			_, _ = w.Write([]byte(`[]`))
+		case "/v1/vaults/vault123/items":
+			if r.URL.Query().Get("filter") == `title eq "myapp"` {
+				_, _ = w.Write([]byte(`[{"id":"item456","title":"myapp"}]`))
+				return
+			}
+			_, _ = w.Write([]byte(`[]`))
+		case "/v1/vaults/vault123/items/item456":
+			_, _ = w.Write([]byte(`{"id":"item456","title":"myapp","fields":[
+				{"id":"notesPlain","label":"notesPlain","purpose":"NOTES","value":""},
+				{"id":"a1","label":"DATABASE_URL","value":"postgres://app@db/app"},
+				{"id":"a2","label":"JWT_SECRET","value":"jwt"}
+			]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestOnePasswordProviderReadsItemFields(t *testing.T) {
+	t.Setenv("OP_CONNECT_TOKEN", "connect-token")
+	server := fakeOnePasswordConnect(t)
+	defer server.Close()
+
+	got, err := FetchProviderSecrets(context.Background(), "1password", nil, ProviderOptions{Address: server.URL, Path: "Production/myapp"})
+	if err != nil {
+		t.Fatalf("FetchProviderSecrets returned error: %v", err)
+	}
+	if len(got) != 2 || got["DATABASE_URL"] != "postgres://app@db/app" || got["JWT_SECRET"] != "jwt" {
+		t.Fatalf("secrets = %#v", got)
+	}
+
+	got, err = FetchProviderSecrets(context.Background(), "op", []string{"Production/myapp/JWT_SECRET"}, ProviderOptions{Address: server.URL})
+	if err != nil || len(got) != 1 || got["Production/myapp/JWT_SECRET"] != "jwt" {
+		t.Fatalf("field reference = %#v, %v", got, err)
+	}
+
+	_, err = FetchProviderSecrets(context.Background(), "1password", []string{"Production/myapp/NOPE"}, ProviderOptions{Address: server.URL})
+	if !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("missing field error = %v, want ErrSecretNotFound", err)
+	}
+	_, err = FetchProviderSecrets(context.Background(), "1password", nil, ProviderOptions{Address: server.URL, Path: "Staging/myapp"})
+	if !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("missing vault error = %v, want ErrSecretNotFound", err)
+	}
+
+	t.Setenv("OP_CONNECT_TOKEN", "wrong")
+	if _, err := FetchProviderSecrets(context.Background(), "1password", nil, ProviderOptions{Address: server.URL, Path: "Production/myapp"}); !errors.Is(err, ErrProviderAuth) {
+		t.Fatalf("bad token error = %v, want ErrProviderAuth", err)
+	}
+}
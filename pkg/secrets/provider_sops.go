@@ -0,0 +1,70 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// sopsProvider decrypts a SOPS-encrypted file kept in the repository with
+// the sops CLI, which resolves the age, PGP, or cloud KMS keys itself.
+// Nested keys are joined with "/", as AWS JSON secrets are.
+type sopsProvider struct {
+	path string
+}
+
+func newSOPSProvider(options ProviderOptions) (SecretProvider, error) {
+	path := strings.TrimSpace(options.Path)
+	if path == "" {
+		return nil, fmt.Errorf("sops file is required; pass --path")
+	}
+	return sopsProvider{path: path}, nil
+}
+
+func (p sopsProvider) Name() string { return ProviderSOPS }
+
+func (p sopsProvider) Fetch(ctx context.Context, names []string) (map[string]string, error) {
+	if _, err := os.Stat(p.path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, &ProviderError{Provider: ProviderSOPS, Secret: p.path, Err: ErrSecretNotFound}
+		}
+		return nil, &ProviderError{Provider: ProviderSOPS, Secret: p.path, Err: err}
+	}
+	output, err := runProviderCommand(ctx, "sops", "--decrypt", "--output-type", "json", p.path)
+	if err != nil {
+		return nil, &ProviderError{Provider: ProviderSOPS, Secret: p.path, Err: err}
+	}
+	var document map[string]any
+	if err := json.Unmarshal([]byte(output), &document); err != nil {
+		return nil, &ProviderError{Provider: ProviderSOPS, Secret: p.path, Err: fmt.Errorf("failed to parse decrypted file: %w", err)}
+	}
+	values := make(map[string]string)
+	flattenSOPSDocument(values, "", document)
+	return selectSecretNames(ProviderSOPS, p.path, values, names)
+}
+
+func flattenSOPSDocument(values map[string]string, prefix string, document map[string]any) {
+	keys := make([]string, 0, len(document))
+	for key := range document {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		// The sops metadata block only matters to sops itself.
+		if prefix == "" && key == "sops" {
+			continue
+		}
+		name := key
+		if prefix != "" {
+			name = prefix + "/" + key
+		}
+		if nested, ok := document[key].(map[string]any); ok {
+			flattenSOPSDocument(values, name, nested)
+			continue
+		}
+		values[name] = stringifySecretValue(document[key])
+	}
+}
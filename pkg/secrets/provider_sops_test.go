@@ -0,0 +1,35 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSOPSProviderDecryptsAndFlattensFile(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "commands.log")
+	restore := useFakeProviderCommand(t, logPath)
+	defer restore()
+	t.Setenv("TAKO_FAKE_SOPS_OUTPUT", `{"DATABASE_URL":"postgres://app@db/app","smtp":{"user":"mailer","port":587}}`)
+	path := filepath.Join(t.TempDir(), "secrets.production.enc.yaml")
+	if err := os.WriteFile(path, []byte("DATABASE_URL: ENC[AES256_GCM,data:...]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := FetchProviderSecrets(context.Background(), "sops", nil, ProviderOptions{Path: path})
+	if err != nil {
+		t.Fatalf("FetchProviderSecrets returned error: %v", err)
+	}
+	if len(got) != 3 || got["DATABASE_URL"] != "postgres://app@db/app" || got["smtp/user"] != "mailer" || got["smtp/port"] != "587" {
+		t.Fatalf("secrets = %#v", got)
+	}
+	if entries := readProviderCommandLog(t, logPath); len(entries) != 1 || entries[0] != "sops --decrypt --output-type json "+path {
+		t.Fatalf("commands = %#v", entries)
+	}
+
+	if _, err := FetchProviderSecrets(context.Background(), "sops", nil, ProviderOptions{Path: path + ".missing"}); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("missing file error = %v, want ErrSecretNotFound", err)
+	}
+}
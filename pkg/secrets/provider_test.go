@@ -121,6 +121,10 @@ func TestProviderCommandHelper(t *testing.T) {
 		_, _ = file.WriteString(entry + "\n")
 		_ = file.Close()
 	}
+	if command == "sops" {
+		_, _ = os.Stdout.WriteString(os.Getenv("TAKO_FAKE_SOPS_OUTPUT"))
+		os.Exit(0)
+	}
 	if command != "aws" || len(commandArgs) < 2 {
 		os.Exit(2)
 	}
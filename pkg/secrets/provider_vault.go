@@ -0,0 +1,99 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const defaultVaultMount = "secret"
+
+// vaultProvider reads one HashiCorp Vault KV v2 secret; its keys become
+// secret names.
+type vaultProvider struct {
+	address   string
+	mount     string
+	path      string
+	version   string
+	namespace string
+	options   ProviderOptions
+}
+
+func newVaultProvider(options ProviderOptions) (SecretProvider, error) {
+	address := strings.TrimRight(strings.TrimSpace(options.Address), "/")
+	if address == "" {
+		address = strings.TrimRight(strings.TrimSpace(os.Getenv("VAULT_ADDR")), "/")
+	}
+	if address == "" {
+		return nil, fmt.Errorf("vault address is required; pass --address or set VAULT_ADDR")
+	}
+	path := strings.Trim(strings.TrimSpace(options.Path), "/")
+	if path == "" {
+		return nil, fmt.Errorf("vault secret path is required; pass --path")
+	}
+	mount := strings.Trim(strings.TrimSpace(options.Mount), "/")
+	if mount == "" {
+		mount = defaultVaultMount
+	}
+	namespace := strings.TrimSpace(options.Namespace)
+	if namespace == "" {
+		namespace = strings.TrimSpace(os.Getenv("VAULT_NAMESPACE"))
+	}
+	version := strings.TrimSpace(options.Version)
+	if version == "latest" {
+		version = ""
+	}
+	return vaultProvider{address: address, mount: mount, path: path, version: version, namespace: namespace, options: options}, nil
+}
+
+func (p vaultProvider) Name() string { return ProviderVault }
+
+func (p vaultProvider) Fetch(ctx context.Context, names []string) (map[string]string, error) {
+	token, err := providerToken(ProviderVault, p.options, "VAULT_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+	endpoint := fmt.Sprintf("%s/v1/%s/data/%s", p.address, escapeURLPath(p.mount), escapeURLPath(p.path))
+	if p.version != "" {
+		endpoint += "?version=" + url.QueryEscape(p.version)
+	}
+	headers := map[string]string{"X-Vault-Token": token}
+	if p.namespace != "" {
+		headers["X-Vault-Namespace"] = p.namespace
+	}
+
+	source := p.mount + "/" + p.path
+	var response struct {
+		Data struct {
+			Data     map[string]any `json:"data"`
+			Metadata struct {
+				Version      int    `json:"version"`
+				DeletionTime string `json:"deletion_time"`
+				Destroyed    bool   `json:"destroyed"`
+			} `json:"metadata"`
+		} `json:"data"`
+	}
+	if err := providerGetJSON(ctx, ProviderVault, source, endpoint, headers, &response); err != nil {
+		return nil, err
+	}
+	// A deleted or destroyed version answers with null data.
+	if response.Data.Data == nil {
+		return nil, &ProviderError{Provider: ProviderVault, Secret: source, Err: fmt.Errorf("%w: version %d is deleted", ErrSecretNotFound, response.Data.Metadata.Version)}
+	}
+	values := make(map[string]string, len(response.Data.Data))
+	for key, value := range response.Data.Data {
+		values[key] = stringifySecretValue(value)
+	}
+	return selectSecretNames(ProviderVault, source, values, names)
+}
+
+// escapeURLPath escapes each segment of a slash-separated path.
+func escapeURLPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
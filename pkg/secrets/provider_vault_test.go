@@ -0,0 +1,75 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestVaultProviderReadsKVv2Secret(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "s.test-token")
+	var gotPath, gotQuery, gotToken, gotNamespace string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotQuery = r.URL.Path, r.URL.RawQuery
+		gotToken, gotNamespace = r.Header.Get("X-Vault-Token"), r.Header.Get("X-Vault-Namespace")
+		_, _ = w.Write([]byte(`{"data":{"data":{"DATABASE_URL":"postgres://app@db/app","PORT":5432},"metadata":{"version":3}}}`))
+	}))
+	defer server.Close()
+
+	provider, err := NewProvider("vault", ProviderOptions{Address: server.URL + "/", Mount: "kv", Path: "/myapp/production", Version: "3", Namespace: "team-a"})
+	if err != nil {
+		t.Fatalf("NewProvider returned error: %v", err)
+	}
+	got, err := provider.Fetch(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if got["DATABASE_URL"] != "postgres://app@db/app" || got["PORT"] != "5432" || len(got) != 2 {
+		t.Fatalf("secrets = %#v", got)
+	}
+	if gotPath != "/v1/kv/data/myapp/production" || gotQuery != "version=3" || gotToken != "s.test-token" || gotNamespace != "team-a" {
+		t.Fatalf("request path=%q query=%q token=%q namespace=%q", gotPath, gotQuery, gotToken, gotNamespace)
+	}
+
+	got, err = provider.Fetch(context.Background(), []string{"PORT"})
+	if err != nil || len(got) != 1 || got["PORT"] != "5432" {
+		t.Fatalf("Fetch(PORT) = %#v, %v", got, err)
+	}
+	if _, err := provider.Fetch(context.Background(), []string{"MISSING"}); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("Fetch(MISSING) error = %v, want ErrSecretNotFound", err)
+	}
+}
+
+func TestVaultProviderTypesErrors(t *testing.T) {
+	status := http.StatusNotFound
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+	}))
+	defer server.Close()
+
+	provider, err := NewProvider("vault", ProviderOptions{Address: server.URL, Path: "myapp", TokenEnv: "TEAM_VAULT_TOKEN"})
+	if err != nil {
+		t.Fatalf("NewProvider returned error: %v", err)
+	}
+	if _, err := provider.Fetch(context.Background(), nil); !errors.Is(err, ErrProviderAuth) || !strings.Contains(err.Error(), "TEAM_VAULT_TOKEN") {
+		t.Fatalf("Fetch without token error = %v, want ErrProviderAuth naming TEAM_VAULT_TOKEN", err)
+	}
+	t.Setenv("TEAM_VAULT_TOKEN", "s.test-token")
+
+	for code, want := range map[int]error{
+		http.StatusNotFound:           ErrSecretNotFound,
+		http.StatusForbidden:          ErrProviderAuth,
+		http.StatusServiceUnavailable: ErrProviderUnavailable,
+	} {
+		status = code
+		_, err := provider.Fetch(context.Background(), nil)
+		var providerErr *ProviderError
+		if !errors.Is(err, want) || !errors.As(err, &providerErr) || providerErr.Status != code || providerErr.Secret != "secret/myapp" {
+			t.Fatalf("HTTP %d error = %#v, want %v", code, err, want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/redentordev/tako-cli/pkg/crypto"
+)
+
+// SharedBundle is what the shared secrets store holds for one environment:
+// its secrets and history files plus the common ones, decrypted from the local
+// project key so every machine that pulls re-encrypts them with its own.
+type SharedBundle struct {
+	Environment string            `json:"environment"`
+	Files       map[string]string `json:"files"` // file name under .tako -> decrypted content
+}
+
+// SharedImport reports what ImportSharedBundle did with each file
+type SharedImport struct {
+	Written   []string
+	Unchanged []string
+	// Conflicts are local files that differ from the bundle. Nothing is
+	// written while any remain unless the import is forced.
+	Conflicts []string
+}
+
+// sharedBundleFiles lists the files under .tako a bundle for environment may
+// carry.
+func sharedBundleFiles(environment string) []string {
+	m := &Manager{basePath: ""}
+	files := []string{m.secretsFilePath("common"), m.historyFilePath("common")}
+	if environment != "" && environment != "common" {
+		files = append(files, m.secretsFilePath(environment), m.historyFilePath(environment))
+	}
+	return files
+}
+
+// ExportSharedBundle reads environment's secrets and history files, and the
+// common ones, from .tako.
+func ExportSharedBundle(environment string) (*SharedBundle, error) {
+	encryptor, err := crypto.NewEncryptorFromKeyFile(crypto.GetProjectKeyPath("."))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load encryption key: %w", err)
+	}
+	bundle := &SharedBundle{Environment: environment, Files: make(map[string]string)}
+	for _, name := range sharedBundleFiles(environment) {
+		data, err := encryptor.ReadEncryptedFile(filepath.Join(".tako", name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read .tako/%s: %w", name, err)
+		}
+		bundle.Files[name] = string(data)
+	}
+	if len(bundle.Files) == 0 {
+		return nil, fmt.Errorf("no secrets found for %s (run tako secrets set first)", environment)
+	}
+	return bundle, nil
+}
+
+// SealSharedBundle encrypts bundle so only recipients can open it
+func SealSharedBundle(bundle *SharedBundle, recipients []crypto.Recipient) ([]byte, error) {
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode shared secrets: %w", err)
+	}
+	return crypto.SealEnvelope(data, recipients)
+}
+
+// OpenSharedBundle decrypts a sealed bundle with identity
+func OpenSharedBundle(data []byte, identity crypto.Identity) (*SharedBundle, error) {
+	plaintext, err := crypto.OpenEnvelope(data, identity)
+	if err != nil {
+		return nil, err
+	}
+	var bundle SharedBundle
+	if err := json.Unmarshal(plaintext, &bundle); err != nil {
+		return nil, fmt.Errorf("failed to parse shared secrets: %w", err)
+	}
+	return &bundle, nil
+}
+
+// ImportSharedBundle writes bundle's files into .tako, encrypted with the
+// local project key. Unless force is set it writes nothing while a local file
+// differs from the bundle.
+func ImportSharedBundle(bundle *SharedBundle, environment string, force bool) (*SharedImport, error) {
+	if bundle.Environment != environment {
+		return nil, fmt.Errorf("shared secrets are for environment %q, not %q", bundle.Environment, environment)
+	}
+	allowed := make(map[string]bool)
+	for _, name := range sharedBundleFiles(environment) {
+		allowed[name] = true
+	}
+	names := make([]string, 0, len(bundle.Files))
+	for name := range bundle.Files {
+		if !allowed[name] {
+			return nil, fmt.Errorf("shared secrets contain unexpected file %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if err := ensureSecretsDir(".tako"); err != nil {
+		return nil, err
+	}
+	encryptor, err := crypto.NewEncryptorFromKeyFile(crypto.GetProjectKeyPath("."))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load encryption key: %w", err)
+	}
+	result := &SharedImport{}
+	var pending []string
+	for _, name := range names {
+		local, err := encryptor.ReadEncryptedFile(filepath.Join(".tako", name))
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			pending = append(pending, name)
+		case err != nil:
+			return nil, fmt.Errorf("failed to read .tako/%s: %w", name, err)
+		case string(local) == bundle.Files[name]:
+			result.Unchanged = append(result.Unchanged, name)
+		default:
+			result.Conflicts = append(result.Conflicts, name)
+			pending = append(pending, name)
+		}
+	}
+	if len(result.Conflicts) > 0 && !force {
+		return result, nil
+	}
+	for _, name := range pending {
+		if err := encryptor.WriteEncryptedFile(filepath.Join(".tako", name), []byte(bundle.Files[name]), 0600); err != nil {
+			return result, fmt.Errorf("failed to write .tako/%s: %w", name, err)
+		}
+		result.Written = append(result.Written, name)
+	}
+	return result, nil
+}
@@ -0,0 +1,133 @@
+package secrets
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/redentordev/tako-cli/pkg/crypto"
+	"golang.org/x/crypto/ssh"
+)
+
+func testSharedIdentity(t *testing.T) (crypto.Recipient, crypto.Identity) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPublic, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient, err := crypto.ParseRecipient(string(ssh.MarshalAuthorizedKey(sshPublic)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(private, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity, err := crypto.ParseIdentity(pem.EncodeToMemory(block), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return recipient, identity
+}
+
+func TestSharedBundleMovesSecretsBetweenProjectKeys(t *testing.T) {
+	withTempWorkingDir(t)
+	useFixedSecretAuthor(t)
+	mgr, err := NewManager("production")
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	for _, set := range []struct{ key, value, env string }{
+		{"DATABASE_URL", "postgres://prod", "production"},
+		{"JWT_SECRET", "shared-jwt", ""},
+		{"STAGING_ONLY", "not-shared", "staging"},
+	} {
+		if err := mgr.Set(set.key, set.value, set.env); err != nil {
+			t.Fatalf("Set %s: %v", set.key, err)
+		}
+	}
+
+	ana, anaIdentity := testSharedIdentity(t)
+	_, benIdentity := testSharedIdentity(t)
+	bundle, err := ExportSharedBundle("production")
+	if err != nil {
+		t.Fatalf("ExportSharedBundle: %v", err)
+	}
+	names := make([]string, 0, len(bundle.Files))
+	for name := range bundle.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if want := []string{"secrets", "secrets.common.history", "secrets.production", "secrets.production.history"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("bundle files = %v, want %v", names, want)
+	}
+	sealed, err := SealSharedBundle(bundle, []crypto.Recipient{ana})
+	if err != nil {
+		t.Fatalf("SealSharedBundle: %v", err)
+	}
+	if strings.Contains(string(sealed), "postgres://prod") {
+		t.Fatal("sealed bundle carries cleartext")
+	}
+	if _, err := OpenSharedBundle(sealed, benIdentity); err != crypto.ErrNotRecipient {
+		t.Fatalf("non-recipient open error = %v", err)
+	}
+
+	// A new machine has its own project key.
+	withTempWorkingDir(t)
+	opened, err := OpenSharedBundle(sealed, anaIdentity)
+	if err != nil {
+		t.Fatalf("OpenSharedBundle: %v", err)
+	}
+	result, err := ImportSharedBundle(opened, "production", false)
+	if err != nil {
+		t.Fatalf("ImportSharedBundle: %v", err)
+	}
+	if len(result.Written) != 4 || len(result.Conflicts) != 0 {
+		t.Fatalf("import = %+v", result)
+	}
+	pulled, err := NewManager("production")
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	if got, _ := pulled.Get("DATABASE_URL"); got != "postgres://prod" {
+		t.Fatalf("DATABASE_URL = %q", got)
+	}
+	if history, err := pulled.History("DATABASE_URL", "production"); err != nil || len(history) != 1 {
+		t.Fatalf("history = %+v, %v", history, err)
+	}
+
+	if err := pulled.Set("DATABASE_URL", "postgres://local-edit", "production"); err != nil {
+		t.Fatal(err)
+	}
+	result, err = ImportSharedBundle(opened, "production", false)
+	if err != nil {
+		t.Fatalf("ImportSharedBundle: %v", err)
+	}
+	if want := []string{"secrets.production", "secrets.production.history"}; !reflect.DeepEqual(result.Conflicts, want) || len(result.Written) != 0 {
+		t.Fatalf("conflicting import = %+v", result)
+	}
+	if result, err = ImportSharedBundle(opened, "production", true); err != nil || len(result.Written) != 2 {
+		t.Fatalf("forced import = %+v, %v", result, err)
+	}
+
+	if _, err := ImportSharedBundle(opened, "staging", false); err == nil {
+		t.Fatal("bundle for production imported into staging")
+	}
+	opened.Files["../../etc/passwd"] = "x"
+	if _, err := ImportSharedBundle(opened, "production", true); err == nil {
+		t.Fatal("unexpected bundle file accepted")
+	}
+	if _, err := os.Stat(filepath.Join(".tako", "secrets.staging")); !os.IsNotExist(err) {
+		t.Fatalf("staging secrets leaked into production bundle: %v", err)
+	}
+}
@@ -0,0 +1,712 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"path"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SFTP protocol version 3 (draft-ietf-secsh-filexfer-02), which every
+// OpenSSH-compatible server speaks.
+const (
+	sftpProtocolVersion = 3
+
+	sftpPacketInit     = 1
+	sftpPacketVersion  = 2
+	sftpPacketOpen     = 3
+	sftpPacketClose    = 4
+	sftpPacketRead     = 5
+	sftpPacketWrite    = 6
+	sftpPacketOpenDir  = 11
+	sftpPacketReadDir  = 12
+	sftpPacketRemove   = 13
+	sftpPacketMkdir    = 14
+	sftpPacketStat     = 17
+	sftpPacketRename   = 18
+	sftpPacketStatus   = 101
+	sftpPacketHandle   = 102
+	sftpPacketData     = 103
+	sftpPacketName     = 104
+	sftpPacketAttrs    = 105
+	sftpPacketExtended = 200
+
+	sftpStatusOK         = 0
+	sftpStatusEOF        = 1
+	sftpStatusNoSuchFile = 2
+
+	sftpOpenRead     = 0x01
+	sftpOpenWrite    = 0x02
+	sftpOpenCreate   = 0x08
+	sftpOpenTruncate = 0x10
+
+	sftpAttrSize        = 0x00000001
+	sftpAttrUIDGID      = 0x00000002
+	sftpAttrPermissions = 0x00000004
+	sftpAttrTimes       = 0x00000008
+	sftpAttrExtended    = 0x80000000
+
+	sftpChunkSize     = 32 * 1024
+	sftpMaxPacketSize = 256 * 1024
+	sftpPosixRename   = "posix-rename@openssh.com"
+	// sftpMaxInFlight is how many reads or writes a transfer keeps
+	// outstanding, so a file streams at the link's bandwidth instead of one
+	// chunk per round trip.
+	sftpMaxInFlight = 64
+)
+
+// SFTPFileInfo is the subset of SFTP attributes Tako uses.
+type SFTPFileInfo struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+}
+
+// SFTPStatusError is a failed request reported by the server. A missing
+// file matches fs.ErrNotExist.
+type SFTPStatusError struct {
+	Code    uint32
+	Message string
+}
+
+func (e *SFTPStatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("sftp: request failed with status %d", e.Code)
+	}
+	return "sftp: " + e.Message
+}
+
+func (e *SFTPStatusError) Is(target error) bool {
+	return target == fs.ErrNotExist && e.Code == sftpStatusNoSuchFile
+}
+
+// SFTPClient runs one operation at a time over an SFTP subsystem channel.
+// Uploads and downloads pipeline their writes and reads within that.
+type SFTPClient struct {
+	session     *ssh.Session
+	reader      io.Reader
+	writer      io.WriteCloser
+	mu          sync.Mutex
+	nextID      uint32
+	posixRename bool
+}
+
+// NewClientWithKeyData creates a client from in-memory credentials that
+// accepts only hostKey, an authorized_keys line such as "ssh-ed25519 AAAA...".
+// Daemons use it where there is no operator key file or known_hosts.
+func NewClientWithKeyData(host string, port int, user string, privateKey string, password string, hostKey string) (*Client, error) {
+	pinned, _, _, _, err := ssh.ParseAuthorizedKey([]byte(hostKey))
+	if err != nil {
+		return nil, fmt.Errorf("SSH host key is invalid: %w", err)
+	}
+	var authMethods []ssh.AuthMethod
+	if privateKey != "" {
+		signer, err := parsePrivateKey([]byte(privateKey))
+		if err != nil {
+			return nil, err
+		}
+		authMethods = append(authMethods, ssh.PublicKeys(signer))
+	}
+	if password != "" {
+		authMethods = append(authMethods, ssh.Password(password))
+	}
+	if len(authMethods) == 0 {
+		return nil, fmt.Errorf("no valid authentication method provided (need either SSH key or password)")
+	}
+	if port == 0 {
+		port = 22
+	}
+	return &Client{
+		config: &ssh.ClientConfig{
+			User:            user,
+			Auth:            authMethods,
+			HostKeyCallback: ssh.FixedHostKey(pinned),
+			Timeout:         connectTimeout(),
+			ClientVersion:   "SSH-2.0-Tako-CLI",
+		},
+		host: host,
+		port: port,
+	}, nil
+}
+
+// NewSFTP opens an SFTP session on the connection.
+func (c *Client) NewSFTP(ctx context.Context) (*SFTPClient, error) {
+	conn, err := c.getConnectionContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	session, err := conn.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	writer, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	reader, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("failed to start sftp subsystem: %w", err)
+	}
+	client, err := newSFTPClient(reader, writer)
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	client.session = session
+	return client, nil
+}
+
+func newSFTPClient(reader io.Reader, writer io.WriteCloser) (*SFTPClient, error) {
+	client := &SFTPClient{reader: reader, writer: writer}
+	var init sftpBuffer
+	init.byte(sftpPacketInit)
+	init.uint32(sftpProtocolVersion)
+	if err := client.writePacket(init.bytes()); err != nil {
+		return nil, fmt.Errorf("sftp handshake failed: %w", err)
+	}
+	packet, err := client.readPacket()
+	if err != nil {
+		return nil, fmt.Errorf("sftp handshake failed: %w", err)
+	}
+	response := sftpReader{data: packet}
+	if response.byte() != sftpPacketVersion {
+		return nil, fmt.Errorf("sftp handshake failed: unexpected response")
+	}
+	if version := response.uint32(); version < sftpProtocolVersion {
+		return nil, fmt.Errorf("sftp server speaks version %d, need %d", version, sftpProtocolVersion)
+	}
+	for response.remaining() > 0 {
+		name, data := response.string(), response.string()
+		if response.err != nil {
+			break
+		}
+		if name == sftpPosixRename && data == "1" {
+			client.posixRename = true
+		}
+	}
+	return client, nil
+}
+
+// Close ends the SFTP session.
+func (s *SFTPClient) Close() error {
+	err := s.writer.Close()
+	if s.session != nil {
+		_ = s.session.Close()
+	}
+	return err
+}
+
+// Stat returns a file's attributes, following symlinks.
+func (s *SFTPClient) Stat(name string) (*SFTPFileInfo, error) {
+	var request sftpBuffer
+	request.string(name)
+	response, err := s.request(sftpPacketStat, request.bytes(), sftpPacketAttrs)
+	if err != nil {
+		return nil, err
+	}
+	info := response.attrs()
+	info.Name = path.Base(name)
+	return &info, response.err
+}
+
+// ReadDir lists a directory without its . and .. entries.
+func (s *SFTPClient) ReadDir(name string) ([]SFTPFileInfo, error) {
+	handle, err := s.openHandle(sftpPacketOpenDir, func(request *sftpBuffer) { request.string(name) })
+	if err != nil {
+		return nil, err
+	}
+	defer s.closeHandle(handle)
+	var entries []SFTPFileInfo
+	for {
+		var request sftpBuffer
+		request.string(handle)
+		response, err := s.request(sftpPacketReadDir, request.bytes(), sftpPacketName)
+		if isSFTPStatus(err, sftpStatusEOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		for count := response.uint32(); count > 0 && response.err == nil; count-- {
+			fileName := response.string()
+			_ = response.string() // longname
+			info := response.attrs()
+			if fileName == "." || fileName == ".." {
+				continue
+			}
+			info.Name = fileName
+			entries = append(entries, info)
+		}
+		if response.err != nil {
+			return nil, response.err
+		}
+	}
+}
+
+// Mkdir creates one directory.
+func (s *SFTPClient) Mkdir(name string) error {
+	var request sftpBuffer
+	request.string(name)
+	request.uint32(sftpAttrPermissions)
+	request.uint32(0750)
+	_, err := s.request(sftpPacketMkdir, request.bytes(), sftpPacketStatus)
+	return err
+}
+
+// MkdirAll creates a directory and any missing parents.
+func (s *SFTPClient) MkdirAll(name string) error {
+	name = path.Clean(name)
+	if name == "." || name == "/" {
+		return nil
+	}
+	if info, err := s.Stat(name); err == nil {
+		if !info.IsDir {
+			return fmt.Errorf("sftp: %s is not a directory", name)
+		}
+		return nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := s.MkdirAll(path.Dir(name)); err != nil {
+		return err
+	}
+	if err := s.Mkdir(name); err != nil {
+		if info, statErr := s.Stat(name); statErr == nil && info.IsDir {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// Remove deletes a file.
+func (s *SFTPClient) Remove(name string) error {
+	var request sftpBuffer
+	request.string(name)
+	_, err := s.request(sftpPacketRemove, request.bytes(), sftpPacketStatus)
+	return err
+}
+
+// Rename moves oldName over newName, replacing it. Servers without the
+// OpenSSH posix-rename extension refuse to overwrite, so the target is removed
+// first there.
+func (s *SFTPClient) Rename(oldName, newName string) error {
+	var request sftpBuffer
+	if s.posixRename {
+		request.string(sftpPosixRename)
+		request.string(oldName)
+		request.string(newName)
+		_, err := s.request(sftpPacketExtended, request.bytes(), sftpPacketStatus)
+		return err
+	}
+	if err := s.Remove(newName); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	request.string(oldName)
+	request.string(newName)
+	_, err := s.request(sftpPacketRename, request.bytes(), sftpPacketStatus)
+	return err
+}
+
+// Upload writes body to name, truncating any existing file.
+func (s *SFTPClient) Upload(ctx context.Context, name string, body io.Reader) error {
+	handle, err := s.openHandle(sftpPacketOpen, func(request *sftpBuffer) {
+		request.string(name)
+		request.uint32(sftpOpenWrite | sftpOpenCreate | sftpOpenTruncate)
+		request.uint32(sftpAttrPermissions)
+		request.uint32(0600)
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.writeAll(ctx, handle, body); err != nil {
+		_ = s.closeHandle(handle)
+		return err
+	}
+	return s.closeHandle(handle)
+}
+
+// writeAll streams body to handle with up to sftpMaxInFlight writes
+// outstanding. After a failure it stops sending and drains the writes still
+// in flight, so the channel stays in step for the next request.
+func (s *SFTPClient) writeAll(ctx context.Context, handle string, body io.Reader) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending := make(map[uint32]bool)
+	buffer := make([]byte, sftpChunkSize)
+	var offset uint64
+	var failed error
+	done := false
+	for {
+		for !done && failed == nil && len(pending) < sftpMaxInFlight {
+			if err := ctx.Err(); err != nil {
+				failed = err
+				break
+			}
+			n, readErr := io.ReadFull(body, buffer)
+			if n > 0 {
+				var request sftpBuffer
+				request.string(handle)
+				request.uint64(offset)
+				request.string(string(buffer[:n]))
+				id, err := s.send(sftpPacketWrite, request.bytes())
+				if err != nil {
+					return err
+				}
+				pending[id] = true
+				offset += uint64(n)
+			}
+			if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+				done = true
+			} else if readErr != nil {
+				failed = readErr
+			}
+		}
+		if len(pending) == 0 {
+			return failed
+		}
+		id, responseType, response, err := s.receive()
+		if err != nil {
+			return err
+		}
+		if !pending[id] {
+			return fmt.Errorf("sftp: response does not match request")
+		}
+		delete(pending, id)
+		if err := sftpResult(responseType, response, sftpPacketStatus); err != nil && failed == nil {
+			failed = err
+		}
+	}
+}
+
+// Download copies name to destination and returns the bytes written.
+func (s *SFTPClient) Download(ctx context.Context, name string, destination io.Writer) (int64, error) {
+	handle, err := s.openHandle(sftpPacketOpen, func(request *sftpBuffer) {
+		request.string(name)
+		request.uint32(sftpOpenRead)
+		request.uint32(0)
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer s.closeHandle(handle)
+	return s.readAll(ctx, handle, destination)
+}
+
+// sftpRead is one outstanding read of length bytes at offset.
+type sftpRead struct {
+	offset uint64
+	length uint32
+}
+
+// readAll copies handle to destination with up to sftpMaxInFlight reads
+// outstanding. Data is written in offset order whatever order the server
+// answers in, and a short read asks again for the rest of its chunk. After
+// a failure it drains the reads still in flight before returning.
+func (s *SFTPClient) readAll(ctx context.Context, handle string, destination io.Writer) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending := make(map[uint32]sftpRead)
+	received := make(map[uint64][]byte)
+	send := func(offset uint64, length uint32) error {
+		var request sftpBuffer
+		request.string(handle)
+		request.uint64(offset)
+		request.uint32(length)
+		id, err := s.send(sftpPacketRead, request.bytes())
+		if err != nil {
+			return err
+		}
+		pending[id] = sftpRead{offset: offset, length: length}
+		return nil
+	}
+	// end is the lowest offset the server reported end of file at.
+	end := uint64(math.MaxUint64)
+	var next, written uint64
+	var failed error
+	for {
+		for next < end && failed == nil && len(pending) < sftpMaxInFlight {
+			if err := ctx.Err(); err != nil {
+				failed = err
+				break
+			}
+			if err := send(next, sftpChunkSize); err != nil {
+				return int64(written), err
+			}
+			next += sftpChunkSize
+		}
+		if len(pending) == 0 {
+			return int64(written), failed
+		}
+		id, responseType, response, err := s.receive()
+		if err != nil {
+			return int64(written), err
+		}
+		read, ok := pending[id]
+		if !ok {
+			return int64(written), fmt.Errorf("sftp: response does not match request")
+		}
+		delete(pending, id)
+		var data string
+		err = sftpResult(responseType, response, sftpPacketData)
+		if err == nil {
+			data = response.string()
+			err = response.err
+		}
+		if err == nil && len(data) > int(read.length) {
+			err = fmt.Errorf("sftp: read returned more data than requested")
+		}
+		if isSFTPStatus(err, sftpStatusEOF) || (err == nil && len(data) == 0) {
+			end = min(end, read.offset)
+			continue
+		}
+		if err != nil || failed != nil {
+			if failed == nil {
+				failed = err
+			}
+			continue
+		}
+		if rest := read.offset + uint64(len(data)); len(data) < int(read.length) && rest < end {
+			if err := send(rest, read.length-uint32(len(data))); err != nil {
+				return int64(written), err
+			}
+		}
+		received[read.offset] = []byte(data)
+		for chunk, ok := received[written]; ok; chunk, ok = received[written] {
+			delete(received, written)
+			if _, err := destination.Write(chunk); err != nil {
+				failed = err
+				break
+			}
+			written += uint64(len(chunk))
+		}
+	}
+}
+
+func (s *SFTPClient) openHandle(packetType byte, build func(*sftpBuffer)) (string, error) {
+	var request sftpBuffer
+	build(&request)
+	response, err := s.request(packetType, request.bytes(), sftpPacketHandle)
+	if err != nil {
+		return "", err
+	}
+	handle := response.string()
+	return handle, response.err
+}
+
+func (s *SFTPClient) closeHandle(handle string) error {
+	var request sftpBuffer
+	request.string(handle)
+	_, err := s.request(sftpPacketClose, request.bytes(), sftpPacketStatus)
+	return err
+}
+
+// request sends one packet and returns the body of the expected response
+// type. A status response is an error unless it is the expected type and OK.
+func (s *SFTPClient) request(packetType byte, payload []byte, want byte) (*sftpReader, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, err := s.send(packetType, payload)
+	if err != nil {
+		return nil, err
+	}
+	responseID, responseType, response, err := s.receive()
+	if err != nil {
+		return nil, err
+	}
+	if responseID != id {
+		return nil, fmt.Errorf("sftp: response does not match request")
+	}
+	if err := sftpResult(responseType, response, want); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// send writes one request and returns its ID. Callers hold s.mu.
+func (s *SFTPClient) send(packetType byte, payload []byte) (uint32, error) {
+	s.nextID++
+	id := s.nextID
+	var packet sftpBuffer
+	packet.byte(packetType)
+	packet.uint32(id)
+	packet.raw(payload)
+	return id, s.writePacket(packet.bytes())
+}
+
+// receive reads the next response and returns the ID of the request it
+// answers, its type, and its body. Callers hold s.mu.
+func (s *SFTPClient) receive() (uint32, byte, *sftpReader, error) {
+	data, err := s.readPacket()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	response := &sftpReader{data: data}
+	responseType := response.byte()
+	id := response.uint32()
+	if response.err != nil {
+		return 0, 0, nil, fmt.Errorf("sftp: malformed response")
+	}
+	return id, responseType, response, nil
+}
+
+// sftpResult checks a response against the type the request expects. A
+// status response is an error unless it is the expected type and OK.
+func sftpResult(responseType byte, response *sftpReader, want byte) error {
+	if responseType == sftpPacketStatus {
+		code := response.uint32()
+		message := response.string()
+		if code == sftpStatusOK && want == sftpPacketStatus {
+			return nil
+		}
+		return &SFTPStatusError{Code: code, Message: message}
+	}
+	if responseType != want {
+		return fmt.Errorf("sftp: unexpected response type %d", responseType)
+	}
+	return nil
+}
+
+func (s *SFTPClient) writePacket(packet []byte) error {
+	frame := make([]byte, 4+len(packet))
+	binary.BigEndian.PutUint32(frame, uint32(len(packet)))
+	copy(frame[4:], packet)
+	_, err := s.writer.Write(frame)
+	return err
+}
+
+func (s *SFTPClient) readPacket() ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(s.reader, header[:]); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:])
+	if length == 0 || length > sftpMaxPacketSize {
+		return nil, fmt.Errorf("sftp: invalid packet length %d", length)
+	}
+	packet := make([]byte, length)
+	if _, err := io.ReadFull(s.reader, packet); err != nil {
+		return nil, err
+	}
+	return packet, nil
+}
+
+func isSFTPStatus(err error, code uint32) bool {
+	var status *SFTPStatusError
+	return errors.As(err, &status) && status.Code == code
+}
+
+type sftpBuffer struct {
+	buffer bytes.Buffer
+}
+
+func (b *sftpBuffer) byte(value byte) { b.buffer.WriteByte(value) }
+
+func (b *sftpBuffer) uint32(value uint32) {
+	_ = binary.Write(&b.buffer, binary.BigEndian, value)
+}
+
+func (b *sftpBuffer) uint64(value uint64) {
+	_ = binary.Write(&b.buffer, binary.BigEndian, value)
+}
+
+func (b *sftpBuffer) string(value string) {
+	b.uint32(uint32(len(value)))
+	b.buffer.WriteString(value)
+}
+
+func (b *sftpBuffer) raw(value []byte) { b.buffer.Write(value) }
+
+func (b *sftpBuffer) bytes() []byte { return b.buffer.Bytes() }
+
+// sftpReader decodes a packet, recording the first short read in err.
+type sftpReader struct {
+	data []byte
+	err  error
+}
+
+var errSFTPShortPacket = errors.New("sftp: short packet")
+
+func (r *sftpReader) remaining() int { return len(r.data) }
+
+func (r *sftpReader) take(n int) []byte {
+	if r.err != nil || n < 0 || len(r.data) < n {
+		r.err = errSFTPShortPacket
+		return nil
+	}
+	value := r.data[:n]
+	r.data = r.data[n:]
+	return value
+}
+
+func (r *sftpReader) byte() byte {
+	if value := r.take(1); value != nil {
+		return value[0]
+	}
+	return 0
+}
+
+func (r *sftpReader) uint32() uint32 {
+	if value := r.take(4); value != nil {
+		return binary.BigEndian.Uint32(value)
+	}
+	return 0
+}
+
+func (r *sftpReader) uint64() uint64 {
+	if value := r.take(8); value != nil {
+		return binary.BigEndian.Uint64(value)
+	}
+	return 0
+}
+
+func (r *sftpReader) string() string {
+	length := r.uint32()
+	if length > sftpMaxPacketSize {
+		r.err = errSFTPShortPacket
+		return ""
+	}
+	return string(r.take(int(length)))
+}
+
+func (r *sftpReader) attrs() SFTPFileInfo {
+	var info SFTPFileInfo
+	flags := r.uint32()
+	if flags&sftpAttrSize != 0 {
+		info.Size = int64(r.uint64())
+	}
+	if flags&sftpAttrUIDGID != 0 {
+		r.uint32()
+		r.uint32()
+	}
+	if flags&sftpAttrPermissions != 0 {
+		info.IsDir = r.uint32()&0170000 == 0040000
+	}
+	if flags&sftpAttrTimes != 0 {
+		r.uint32() // atime
+		info.ModTime = time.Unix(int64(r.uint32()), 0).UTC()
+	}
+	if flags&sftpAttrExtended != 0 {
+		for count := r.uint32(); count > 0 && r.err == nil; count-- {
+			r.string()
+			r.string()
+		}
+	}
+	return info
+}
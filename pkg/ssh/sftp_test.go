@@ -0,0 +1,380 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSFTPServer serves the SFTP v3 subset SFTPClient uses from memory.
+type fakeSFTPServer struct {
+	files       map[string][]byte
+	dirs        map[string]bool
+	handles     map[string]string
+	listed      map[string]bool
+	posixRename bool
+	// reorder answers each batch of reads and writes newest first, and
+	// maxRead caps how much one read returns.
+	reorder bool
+	maxRead int
+	// maxInFlight is the most reads or writes the client had outstanding.
+	maxInFlight int
+}
+
+func startFakeSFTP(t *testing.T, posixRename bool, options ...func(*fakeSFTPServer)) (*SFTPClient, *fakeSFTPServer) {
+	t.Helper()
+	server := &fakeSFTPServer{
+		files:       make(map[string][]byte),
+		dirs:        map[string]bool{"/": true},
+		handles:     make(map[string]string),
+		listed:      make(map[string]bool),
+		posixRename: posixRename,
+	}
+	for _, option := range options {
+		option(server)
+	}
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+	go server.serve(serverReader, serverWriter)
+	client, err := newSFTPClient(clientReader, clientWriter)
+	if err != nil {
+		t.Fatalf("newSFTPClient returned error: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client, server
+}
+
+// serve reads requests ahead and writes replies from their own goroutine,
+// as an SSH channel's window buffers both, so a client with requests in
+// flight never blocks it. Replies to reads and writes are held until the
+// client stops sending, which shows how many it pipelined.
+func (f *fakeSFTPServer) serve(reader io.ReadCloser, writer io.WriteCloser) {
+	connection := &SFTPClient{reader: reader, writer: writer}
+	packets := make(chan []byte, 2*sftpMaxInFlight)
+	go func() {
+		defer close(packets)
+		for {
+			packet, err := connection.readPacket()
+			if err != nil {
+				return
+			}
+			packets <- packet
+		}
+	}()
+	replies := make(chan []byte, 2*sftpMaxInFlight)
+	go func() {
+		defer writer.Close()
+		for reply := range replies {
+			_ = connection.writePacket(reply)
+		}
+	}()
+	defer close(replies)
+
+	var held [][]byte
+	flush := func() {
+		f.maxInFlight = max(f.maxInFlight, len(held))
+		if f.reorder {
+			slices.Reverse(held)
+		}
+		for _, reply := range held {
+			replies <- reply
+		}
+		held = nil
+	}
+	for {
+		var packet []byte
+		var ok bool
+		select {
+		case packet, ok = <-packets:
+		case <-time.After(20 * time.Millisecond):
+			flush()
+			packet, ok = <-packets
+		}
+		if !ok {
+			return
+		}
+		request := &sftpReader{data: packet}
+		packetType := request.byte()
+		if packetType == sftpPacketInit {
+			var response sftpBuffer
+			response.byte(sftpPacketVersion)
+			response.uint32(sftpProtocolVersion)
+			if f.posixRename {
+				response.string(sftpPosixRename)
+				response.string("1")
+			}
+			replies <- response.bytes()
+			continue
+		}
+		id := request.uint32()
+		reply := f.handle(packetType, id, request)
+		if packetType == sftpPacketRead || packetType == sftpPacketWrite {
+			held = append(held, reply)
+			continue
+		}
+		flush()
+		replies <- reply
+	}
+}
+
+func (f *fakeSFTPServer) handle(packetType byte, id uint32, request *sftpReader) []byte {
+	status := func(code uint32) []byte {
+		var response sftpBuffer
+		response.byte(sftpPacketStatus)
+		response.uint32(id)
+		response.uint32(code)
+		response.string("")
+		response.string("")
+		return response.bytes()
+	}
+	handle := func(value string) []byte {
+		var response sftpBuffer
+		response.byte(sftpPacketHandle)
+		response.uint32(id)
+		response.string(value)
+		return response.bytes()
+	}
+	attrs := func(response *sftpBuffer, name string) {
+		if f.dirs[name] {
+			response.uint32(sftpAttrPermissions)
+			response.uint32(0040755)
+			return
+		}
+		response.uint32(sftpAttrSize | sftpAttrTimes)
+		response.uint64(uint64(len(f.files[name])))
+		response.uint32(1700000000)
+		response.uint32(1700000000)
+	}
+	switch packetType {
+	case sftpPacketStat:
+		name := request.string()
+		if _, ok := f.files[name]; !ok && !f.dirs[name] {
+			return status(sftpStatusNoSuchFile)
+		}
+		var response sftpBuffer
+		response.byte(sftpPacketAttrs)
+		response.uint32(id)
+		attrs(&response, name)
+		return response.bytes()
+	case sftpPacketOpen:
+		name := request.string()
+		flags := request.uint32()
+		if !f.dirs[path.Dir(name)] {
+			return status(sftpStatusNoSuchFile)
+		}
+		if flags&sftpOpenCreate != 0 {
+			f.files[name] = nil
+		} else if _, ok := f.files[name]; !ok {
+			return status(sftpStatusNoSuchFile)
+		}
+		f.handles[name] = name
+		return handle(name)
+	case sftpPacketOpenDir:
+		name := request.string()
+		if !f.dirs[name] {
+			return status(sftpStatusNoSuchFile)
+		}
+		f.handles[name] = name
+		delete(f.listed, name)
+		return handle(name)
+	case sftpPacketReadDir:
+		name := f.handles[request.string()]
+		if f.listed[name] {
+			return status(sftpStatusEOF)
+		}
+		f.listed[name] = true
+		var children []string
+		for child := range f.files {
+			if path.Dir(child) == name {
+				children = append(children, child)
+			}
+		}
+		for child := range f.dirs {
+			if child != name && path.Dir(child) == name {
+				children = append(children, child)
+			}
+		}
+		sort.Strings(children)
+		var response sftpBuffer
+		response.byte(sftpPacketName)
+		response.uint32(id)
+		response.uint32(uint32(len(children) + 1))
+		response.string(".")
+		response.string(".")
+		response.uint32(0)
+		for _, child := range children {
+			response.string(path.Base(child))
+			response.string(path.Base(child))
+			attrs(&response, child)
+		}
+		return response.bytes()
+	case sftpPacketWrite:
+		name := f.handles[request.string()]
+		offset := request.uint64()
+		data := request.string()
+		if int(offset) != len(f.files[name]) {
+			return status(4)
+		}
+		f.files[name] = append(f.files[name], data...)
+		return status(sftpStatusOK)
+	case sftpPacketRead:
+		name := f.handles[request.string()]
+		offset := int(request.uint64())
+		length := int(request.uint32())
+		content := f.files[name]
+		if offset >= len(content) {
+			return status(sftpStatusEOF)
+		}
+		if f.maxRead > 0 && length > f.maxRead {
+			length = f.maxRead
+		}
+		end := offset + length
+		if end > len(content) {
+			end = len(content)
+		}
+		var response sftpBuffer
+		response.byte(sftpPacketData)
+		response.uint32(id)
+		response.string(string(content[offset:end]))
+		return response.bytes()
+	case sftpPacketClose:
+		delete(f.handles, request.string())
+		return status(sftpStatusOK)
+	case sftpPacketMkdir:
+		name := request.string()
+		if !f.dirs[path.Dir(name)] || f.dirs[name] {
+			return status(4)
+		}
+		f.dirs[name] = true
+		return status(sftpStatusOK)
+	case sftpPacketRemove:
+		name := request.string()
+		if _, ok := f.files[name]; !ok {
+			return status(sftpStatusNoSuchFile)
+		}
+		delete(f.files, name)
+		return status(sftpStatusOK)
+	case sftpPacketRename, sftpPacketExtended:
+		if packetType == sftpPacketExtended && request.string() != sftpPosixRename {
+			return status(4)
+		}
+		oldName, newName := request.string(), request.string()
+		if _, exists := f.files[newName]; exists && packetType == sftpPacketRename {
+			return status(4)
+		}
+		content, ok := f.files[oldName]
+		if !ok {
+			return status(sftpStatusNoSuchFile)
+		}
+		delete(f.files, oldName)
+		f.files[newName] = content
+		return status(sftpStatusOK)
+	}
+	return status(4)
+}
+
+func TestSFTPClientUploadsListsDownloadsAndRemoves(t *testing.T) {
+	for _, posixRename := range []bool{true, false} {
+		client, server := startFakeSFTP(t, posixRename)
+		ctx := context.Background()
+		content := bytes.Repeat([]byte("tako"), sftpChunkSize/2+7)
+
+		if err := client.MkdirAll("/backups/demo/production"); err != nil {
+			t.Fatalf("MkdirAll returned error: %v", err)
+		}
+		if err := client.Upload(ctx, "/backups/demo/production/data.partial", bytes.NewReader(content)); err != nil {
+			t.Fatalf("Upload returned error: %v", err)
+		}
+		server.files["/backups/demo/production/data.tar.gz"] = []byte("stale")
+		if err := client.Rename("/backups/demo/production/data.partial", "/backups/demo/production/data.tar.gz"); err != nil {
+			t.Fatalf("Rename(posixRename=%v) returned error: %v", posixRename, err)
+		}
+
+		info, err := client.Stat("/backups/demo/production/data.tar.gz")
+		if err != nil || info.Size != int64(len(content)) || info.IsDir || info.ModTime.Unix() != 1700000000 {
+			t.Fatalf("Stat = %+v, %v", info, err)
+		}
+		entries, err := client.ReadDir("/backups/demo")
+		if err != nil || len(entries) != 1 || entries[0].Name != "production" || !entries[0].IsDir {
+			t.Fatalf("ReadDir = %+v, %v", entries, err)
+		}
+		var downloaded bytes.Buffer
+		written, err := client.Download(ctx, "/backups/demo/production/data.tar.gz", &downloaded)
+		if err != nil || written != int64(len(content)) || !bytes.Equal(downloaded.Bytes(), content) {
+			t.Fatalf("Download wrote %d bytes, %v", written, err)
+		}
+
+		if err := client.Remove("/backups/demo/production/data.tar.gz"); err != nil {
+			t.Fatalf("Remove returned error: %v", err)
+		}
+		if _, err := client.Stat("/backups/demo/production/data.tar.gz"); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("Stat after Remove = %v, want fs.ErrNotExist", err)
+		}
+	}
+}
+
+func TestSFTPClientPipelinesTransfersAndReordersReads(t *testing.T) {
+	client, server := startFakeSFTP(t, true, func(server *fakeSFTPServer) {
+		server.reorder = true
+		server.maxRead = sftpChunkSize/3 + 1
+	})
+	ctx := context.Background()
+	content := make([]byte, sftpChunkSize*(sftpMaxInFlight+5)+123)
+	for i := range content {
+		content[i] = byte(i * 7 / 5)
+	}
+
+	if err := client.Upload(ctx, "/data.tar.gz", bytes.NewReader(content)); err != nil {
+		t.Fatalf("Upload returned error: %v", err)
+	}
+	if !bytes.Equal(server.files["/data.tar.gz"], content) {
+		t.Fatalf("uploaded %d bytes differ from the %d sent", len(server.files["/data.tar.gz"]), len(content))
+	}
+	if server.maxInFlight < 2 {
+		t.Fatalf("client kept %d writes in flight, want pipelined writes", server.maxInFlight)
+	}
+
+	var downloaded bytes.Buffer
+	written, err := client.Download(ctx, "/data.tar.gz", &downloaded)
+	if err != nil || written != int64(len(content)) || !bytes.Equal(downloaded.Bytes(), content) {
+		t.Fatalf("Download wrote %d of %d bytes in order, %v", written, len(content), err)
+	}
+
+	// A failed destination stops the download, and the reads still in flight
+	// are drained so the next request gets its own response.
+	if _, err := client.Download(ctx, "/data.tar.gz", failingWriter{}); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("Download into a failing writer = %v", err)
+	}
+	if info, err := client.Stat("/data.tar.gz"); err != nil || info.Size != int64(len(content)) {
+		t.Fatalf("Stat after a failed download = %+v, %v", info, err)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestNewClientWithKeyDataRequiresPinnedHostKeyAndCredentials(t *testing.T) {
+	if _, err := NewClientWithKeyData("backup.example.com", 22, "tako", "", "secret", "not a key"); err == nil || !strings.Contains(err.Error(), "host key is invalid") {
+		t.Fatalf("invalid host key = %v", err)
+	}
+	hostKey := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIHc6X4dZk2mFJ8nV6W0o5P5d6t1iVtPGx2Y3dHo7y4mS"
+	if _, err := NewClientWithKeyData("backup.example.com", 22, "tako", "", "", hostKey); err == nil || !strings.Contains(err.Error(), "no valid authentication") {
+		t.Fatalf("missing credentials = %v", err)
+	}
+	client, err := NewClientWithKeyData("backup.example.com", 0, "tako", "", "secret", hostKey)
+	if err != nil {
+		t.Fatalf("NewClientWithKeyData returned error: %v", err)
+	}
+	if client.Port() != 22 {
+		t.Fatalf("port = %d, want default 22", client.Port())
+	}
+}
@@ -121,6 +121,27 @@ const (
 	// reconciliation during a deploy.
 	TypeDeployJobsApplied = "deploy.jobs.applied"
 
+	// TypeDeployLoggingApplied reports one node's log shipping sinks after a
+	// deploy applied (or cleared) the environment's logging block.
+	TypeDeployLoggingApplied = "deploy.logging.applied"
+
+	// TypeDeployMetricsApplied reports the address one node serves its
+	// Prometheus scrape target on after a deploy applied the metrics block.
+	TypeDeployMetricsApplied = "deploy.metrics.applied"
+
+	// TypeDeployAlertsApplied reports how many alert rules one node
+	// evaluates after a deploy applied the alerts block.
+	TypeDeployAlertsApplied = "deploy.alerts.applied"
+
+	// TypeDeployTracingApplied reports the collector one node exports its
+	// deploy spans to after a deploy applied the tracing block.
+	TypeDeployTracingApplied = "deploy.tracing.applied"
+
+	// TypeDeployContainerEventsApplied reports one node notifying the
+	// environment's OOM kills and crash loops after a deploy applied the
+	// notifications block.
+	TypeDeployContainerEventsApplied = "deploy.events.applied"
+
 	// TypeImagePullAuthFailed marks an image pull/build that failed due to
 	// registry credentials, distinct from image-not-found, so control
 	// planes can prompt for credential rotation.
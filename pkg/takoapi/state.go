@@ -116,6 +116,7 @@ type DesiredServiceDocument struct {
 	Ulimits         map[string]UlimitDocument   `json:"ulimits,omitempty"`
 	ShmSize         string                      `json:"shmSize,omitempty"`
 	SecretRefs      []string                    `json:"secretRefs,omitempty"`
+	SecretsDelivery json.RawMessage             `json:"secretsDelivery,omitempty"`
 	DependsOn       []string                    `json:"dependsOn,omitempty"`
 	HealthCheck     json.RawMessage             `json:"healthCheck,omitempty"`
 	DeployStrategy  string                      `json:"deployStrategy,omitempty"`
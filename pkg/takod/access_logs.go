@@ -44,8 +44,8 @@ func validateAccessLogTail(tail int) error {
 	if tail < 0 {
 		return fmt.Errorf("tail cannot be negative")
 	}
-	if tail > maxLogTail {
-		return fmt.Errorf("tail cannot exceed %d", maxLogTail)
+	if tail > MaxLogTail {
+		return fmt.Errorf("tail cannot exceed %d", MaxLogTail)
 	}
 	return nil
 }
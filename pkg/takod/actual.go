@@ -40,6 +40,9 @@ type ActualService struct {
 	// Empty when no active container defines a health check, or when the
 	// reporting node agent predates health capture.
 	Health string `json:"health,omitempty"`
+	// WarmingHealth aggregates the same state across warming containers so
+	// canary and manual promotion can judge a revision before it is active.
+	WarmingHealth string `json:"warmingHealth,omitempty"`
 }
 
 // Docker health-check states surfaced in actual state and status rows.
@@ -157,6 +160,7 @@ func ParseActualState(project string, environment string, dockerPSOutput string)
 				existing.WarmingContainers = append(existing.WarmingContainers, containerID)
 				existing.PreviousRevision = mergeOptionalLabel(existing.PreviousRevision, revision)
 				existing.WarmingRevisions = appendUniqueRevision(existing.WarmingRevisions, revision)
+				existing.WarmingHealth = MergeHealthStates(existing.WarmingHealth, health)
 			}
 			continue
 		}
@@ -180,6 +184,7 @@ func ParseActualState(project string, environment string, dockerPSOutput string)
 			actual.WarmingContainers = []string{containerID}
 			actual.PreviousRevision = revision
 			actual.WarmingRevisions = appendUniqueRevision(actual.WarmingRevisions, revision)
+			actual.WarmingHealth = health
 		}
 		response.Services[serviceName] = actual
 	}
@@ -199,8 +204,10 @@ func finalizeActualServiceRevisionStates(services map[string]*ActualService) {
 		service.CurrentRevision = service.WarmingRevisions[0]
 		service.PreviousRevision = ""
 		service.ActiveContainers = append([]string(nil), service.WarmingContainers...)
+		service.Health = MergeHealthStates(service.Health, service.WarmingHealth)
 		service.WarmingContainers = nil
 		service.WarmingRevisions = nil
+		service.WarmingHealth = ""
 	}
 }
 
@@ -0,0 +1,673 @@
+package takod
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/notification"
+)
+
+const alertsDirName = "alerts"
+
+// Alert rule metrics. Node rules read the node monitor's sample; service
+// rules read the service's replicas on this node, summed.
+const (
+	AlertMetricCPUPercent    = "cpu_percent"
+	AlertMetricMemoryPercent = "memory_percent"
+	AlertMetricMemoryBytes   = "memory_bytes"
+	AlertMetricDiskPercent   = "disk_percent"
+	AlertMetricLoad1         = "load1"
+)
+
+const (
+	AlertSeverityInfo     = "info"
+	AlertSeverityWarning  = "warning"
+	AlertSeverityCritical = "critical"
+)
+
+const (
+	AlertStateOK      = "ok"
+	AlertStatePending = "pending"
+	AlertStateFiring  = "firing"
+)
+
+// Observation keys that carry context for notifications rather than a rule
+// metric.
+const (
+	alertObservedMemoryUsed  = "memory_used_bytes"
+	alertObservedMemoryLimit = "memory_limit_bytes"
+	alertObservedDiskUsed    = "disk_used_bytes"
+	alertObservedDiskTotal   = "disk_total_bytes"
+)
+
+// IsAlertMetric reports whether metric is one takod evaluates; node-only
+// metrics are rejected for service rules by validateAlertRule.
+func IsAlertMetric(metric string) bool {
+	switch metric {
+	case AlertMetricCPUPercent, AlertMetricMemoryPercent, AlertMetricMemoryBytes, AlertMetricDiskPercent, AlertMetricLoad1:
+		return true
+	}
+	return false
+}
+
+// IsNodeOnlyAlertMetric reports whether metric only exists for the node.
+func IsNodeOnlyAlertMetric(metric string) bool {
+	return metric == AlertMetricDiskPercent || metric == AlertMetricLoad1
+}
+
+func IsAlertSeverity(severity string) bool {
+	return severity == AlertSeverityInfo || severity == AlertSeverityWarning || severity == AlertSeverityCritical
+}
+
+// AlertRule fires once Metric has stayed at or above Threshold for
+// DurationSeconds, and resolves once it drops below. Notifications are the
+// rule's routed webhooks; like job notifications they never leave the node
+// through status responses.
+type AlertRule struct {
+	Name            string            `json:"name"`
+	Metric          string            `json:"metric"`
+	Service         string            `json:"service,omitempty"`
+	Threshold       float64           `json:"threshold"`
+	DurationSeconds int64             `json:"durationSeconds,omitempty"`
+	Severity        string            `json:"severity"`
+	Notifications   *JobNotifications `json:"notifications,omitempty"`
+}
+
+// AlertSpec is one environment's rules on one node. Node is the name the
+// deployer knows this server by, used in notifications.
+type AlertSpec struct {
+	Node  string      `json:"node,omitempty"`
+	Rules []AlertRule `json:"rules"`
+}
+
+// AlertsApplyRequest replaces an environment's alert rules; a nil Spec
+// removes them along with their silences.
+type AlertsApplyRequest struct {
+	Project     string     `json:"project"`
+	Environment string     `json:"environment"`
+	Spec        *AlertSpec `json:"spec,omitempty"`
+}
+
+type AlertsApplyResponse struct {
+	Project     string `json:"project"`
+	Environment string `json:"environment"`
+	Rules       int    `json:"rules"`
+}
+
+// AlertSilence suppresses notifications for Rule, or every rule of the
+// environment when Rule is empty, until Until.
+type AlertSilence struct {
+	Rule    string    `json:"rule,omitempty"`
+	Until   time.Time `json:"until"`
+	Comment string    `json:"comment,omitempty"`
+}
+
+// AlertSilenceRequest adds a silence, or with Remove clears the silences
+// for Rule (every silence when Rule is empty).
+type AlertSilenceRequest struct {
+	Project     string    `json:"project"`
+	Environment string    `json:"environment"`
+	Rule        string    `json:"rule,omitempty"`
+	Until       time.Time `json:"until,omitempty"`
+	Comment     string    `json:"comment,omitempty"`
+	Remove      bool      `json:"remove,omitempty"`
+}
+
+// AlertStatus is one rule's evaluation state on this node.
+type AlertStatus struct {
+	Rule            string     `json:"rule"`
+	Metric          string     `json:"metric"`
+	Service         string     `json:"service,omitempty"`
+	Threshold       float64    `json:"threshold"`
+	DurationSeconds int64      `json:"durationSeconds,omitempty"`
+	Severity        string     `json:"severity"`
+	State           string     `json:"state"`
+	Since           *time.Time `json:"since,omitempty"`
+	Value           *float64   `json:"value,omitempty"`
+	SilencedUntil   *time.Time `json:"silencedUntil,omitempty"`
+}
+
+type AlertsResponse struct {
+	Project     string         `json:"project"`
+	Environment string         `json:"environment"`
+	Node        string         `json:"node,omitempty"`
+	Alerts      []AlertStatus  `json:"alerts"`
+	Silences    []AlertSilence `json:"silences"`
+}
+
+// alertState is one rule's evaluation state. Notified records whether the
+// firing notification went out, so a resolve is only sent after one and a
+// silence that ends while the rule still fires sends it late.
+type alertState struct {
+	State    string    `json:"state"`
+	Since    time.Time `json:"since,omitempty"`
+	Value    *float64  `json:"value,omitempty"`
+	Notified bool      `json:"notified,omitempty"`
+}
+
+// alertEnvironment is the persisted document for one environment.
+type alertEnvironment struct {
+	Spec     AlertSpec             `json:"spec"`
+	Silences []AlertSilence        `json:"silences,omitempty"`
+	States   map[string]alertState `json:"states,omitempty"`
+}
+
+// metricsObservation is one sample handed from MetricsHistory to the alert
+// evaluator. A nil map means that source could not be read, so rules on it
+// keep their state; a service without containers here is absent from a
+// non-nil services map and reads as below every threshold.
+type metricsObservation struct {
+	at       time.Time
+	node     map[string]float64
+	services map[proxyRouteKey]map[string]float64
+}
+
+// AlertEvaluator evaluates each environment's alert rules against every
+// metrics sample this node records, mirroring LogShipper for persistence:
+// rules, silences, and states live as JSON under the data dir, so a restart
+// neither forgets a firing alert nor notifies it twice.
+type AlertEvaluator struct {
+	dataDir string
+	notify  func(targets JobNotifications, event notification.Event) error
+	now     func() time.Time
+
+	mu           sync.Mutex
+	environments map[string]*alertEnvironment
+	loaded       bool
+	sends        sync.WaitGroup
+}
+
+func NewAlertEvaluator(dataDir string) *AlertEvaluator {
+	return &AlertEvaluator{
+		dataDir:      dataDir,
+		notify:       deliverJobNotification,
+		now:          time.Now,
+		environments: map[string]*alertEnvironment{},
+	}
+}
+
+func validateAlertRule(rule AlertRule) error {
+	if !isSafeServiceName(rule.Name) {
+		return fmt.Errorf("invalid alert rule name %q", rule.Name)
+	}
+	if !IsAlertMetric(rule.Metric) {
+		return fmt.Errorf("alert %s: unknown metric %q", rule.Name, rule.Metric)
+	}
+	if rule.Service != "" {
+		if !isSafeServiceName(rule.Service) {
+			return fmt.Errorf("alert %s: invalid service name", rule.Name)
+		}
+		if IsNodeOnlyAlertMetric(rule.Metric) {
+			return fmt.Errorf("alert %s: %s is a node metric and cannot be scoped to a service", rule.Name, rule.Metric)
+		}
+	}
+	if math.IsNaN(rule.Threshold) || math.IsInf(rule.Threshold, 0) || rule.Threshold < 0 {
+		return fmt.Errorf("alert %s: threshold must be a non-negative number", rule.Name)
+	}
+	if rule.DurationSeconds < 0 {
+		return fmt.Errorf("alert %s: duration cannot be negative", rule.Name)
+	}
+	if !IsAlertSeverity(rule.Severity) {
+		return fmt.Errorf("alert %s: severity must be info, warning, or critical", rule.Name)
+	}
+	if err := validateJobNotifications(rule.Notifications); err != nil {
+		return fmt.Errorf("alert %s: %w", rule.Name, err)
+	}
+	return nil
+}
+
+func validateAlertSpec(spec *AlertSpec) error {
+	if len(spec.Node) > 255 || hasControlChars(spec.Node) {
+		return fmt.Errorf("invalid node name")
+	}
+	seen := map[string]bool{}
+	for _, rule := range spec.Rules {
+		if err := validateAlertRule(rule); err != nil {
+			return err
+		}
+		if seen[rule.Name] {
+			return fmt.Errorf("duplicate alert rule %s", rule.Name)
+		}
+		seen[rule.Name] = true
+	}
+	return nil
+}
+
+// Apply replaces one environment's rules. States of rules whose definition
+// is unchanged carry over, so redeploying does not re-notify firing alerts.
+func (a *AlertEvaluator) Apply(request AlertsApplyRequest) (*AlertsApplyResponse, error) {
+	if !isSafeProjectName(request.Project) {
+		return nil, fmt.Errorf("invalid project name")
+	}
+	if !isSafeRuntimeName(request.Environment) {
+		return nil, fmt.Errorf("invalid environment name")
+	}
+	response := &AlertsApplyResponse{Project: request.Project, Environment: request.Environment}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.loadLocked(); err != nil {
+		return nil, err
+	}
+	key := logShippingKey(request.Project, request.Environment)
+	if request.Spec == nil || len(request.Spec.Rules) == 0 {
+		delete(a.environments, key)
+		if err := os.Remove(a.specPath(request.Project, request.Environment)); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove alert rules: %w", err)
+		}
+		_ = os.Remove(filepath.Join(a.dataDir, alertsDirName, request.Project))
+		return response, nil
+	}
+	spec := *request.Spec
+	if err := validateAlertSpec(&spec); err != nil {
+		return nil, err
+	}
+	next := &alertEnvironment{Spec: spec, States: map[string]alertState{}}
+	if existing, ok := a.environments[key]; ok {
+		next.Silences = existing.Silences
+		previous := map[string]AlertRule{}
+		for _, rule := range existing.Spec.Rules {
+			previous[rule.Name] = rule
+		}
+		for _, rule := range spec.Rules {
+			if state, ok := existing.States[rule.Name]; ok && sameAlertCondition(previous[rule.Name], rule) {
+				next.States[rule.Name] = state
+			}
+		}
+	}
+	if err := a.persistLocked(request.Project, request.Environment, next); err != nil {
+		return nil, err
+	}
+	a.environments[key] = next
+	response.Rules = len(spec.Rules)
+	return response, nil
+}
+
+// sameAlertCondition reports whether two rules watch the same condition;
+// severity and routing changes keep the rule's state.
+func sameAlertCondition(a AlertRule, b AlertRule) bool {
+	return a.Metric == b.Metric && a.Service == b.Service && a.Threshold == b.Threshold && a.DurationSeconds == b.DurationSeconds
+}
+
+// Silence adds or removes silences for one environment.
+func (a *AlertEvaluator) Silence(request AlertSilenceRequest) (*AlertsResponse, error) {
+	if !isSafeProjectName(request.Project) {
+		return nil, fmt.Errorf("invalid project name")
+	}
+	if !isSafeRuntimeName(request.Environment) {
+		return nil, fmt.Errorf("invalid environment name")
+	}
+	if len(request.Comment) > 512 || hasControlChars(request.Comment) {
+		return nil, fmt.Errorf("silence comment must be at most 512 printable characters")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.loadLocked(); err != nil {
+		return nil, err
+	}
+	environment, ok := a.environments[logShippingKey(request.Project, request.Environment)]
+	if !ok {
+		return nil, fmt.Errorf("no alert rules are deployed for %s/%s on this node", request.Project, request.Environment)
+	}
+	if request.Rule != "" && !environment.hasRule(request.Rule) {
+		return nil, fmt.Errorf("unknown alert rule %q", request.Rule)
+	}
+	now := a.now()
+	if request.Remove {
+		kept := environment.Silences[:0]
+		for _, silence := range environment.Silences {
+			if request.Rule != "" && silence.Rule != request.Rule {
+				kept = append(kept, silence)
+			}
+		}
+		environment.Silences = kept
+	} else {
+		if !request.Until.After(now) {
+			return nil, fmt.Errorf("silence must end in the future")
+		}
+		environment.Silences = append(environment.Silences, AlertSilence{Rule: request.Rule, Until: request.Until.UTC(), Comment: request.Comment})
+	}
+	if err := a.persistLocked(request.Project, request.Environment, environment); err != nil {
+		return nil, err
+	}
+	return a.statusLocked(request.Project, request.Environment, environment, now), nil
+}
+
+// Status returns the rules, states, and active silences of one environment.
+func (a *AlertEvaluator) Status(project string, environment string) (*AlertsResponse, error) {
+	if !isSafeProjectName(project) {
+		return nil, fmt.Errorf("invalid project name")
+	}
+	if !isSafeRuntimeName(environment) {
+		return nil, fmt.Errorf("invalid environment name")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.loadLocked(); err != nil {
+		return nil, err
+	}
+	current, ok := a.environments[logShippingKey(project, environment)]
+	if !ok {
+		return &AlertsResponse{Project: project, Environment: environment, Alerts: []AlertStatus{}, Silences: []AlertSilence{}}, nil
+	}
+	return a.statusLocked(project, environment, current, a.now()), nil
+}
+
+func (a *AlertEvaluator) statusLocked(project string, environment string, current *alertEnvironment, now time.Time) *AlertsResponse {
+	response := &AlertsResponse{Project: project, Environment: environment, Node: current.Spec.Node, Alerts: []AlertStatus{}, Silences: []AlertSilence{}}
+	for _, rule := range current.Spec.Rules {
+		state := current.States[rule.Name]
+		status := AlertStatus{
+			Rule:            rule.Name,
+			Metric:          rule.Metric,
+			Service:         rule.Service,
+			Threshold:       rule.Threshold,
+			DurationSeconds: rule.DurationSeconds,
+			Severity:        rule.Severity,
+			State:           AlertStateOK,
+			Value:           state.Value,
+		}
+		if state.State != "" {
+			status.State = state.State
+		}
+		if status.State != AlertStateOK && !state.Since.IsZero() {
+			since := state.Since
+			status.Since = &since
+		}
+		if until, ok := current.silencedUntil(rule.Name, now); ok {
+			status.SilencedUntil = &until
+		}
+		response.Alerts = append(response.Alerts, status)
+	}
+	for _, silence := range current.Silences {
+		if silence.Until.After(now) {
+			response.Silences = append(response.Silences, silence)
+		}
+	}
+	return response
+}
+
+// RemoveProject drops a project's rules (one environment, or all when
+// environment is empty).
+func (a *AlertEvaluator) RemoveProject(project string, environment string) error {
+	if !isSafeProjectName(project) {
+		return fmt.Errorf("invalid project name")
+	}
+	if environment != "" {
+		_, err := a.Apply(AlertsApplyRequest{Project: project, Environment: environment})
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for key := range a.environments {
+		if strings.HasPrefix(key, project+"/") {
+			delete(a.environments, key)
+		}
+	}
+	if err := os.RemoveAll(filepath.Join(a.dataDir, alertsDirName, project)); err != nil {
+		return fmt.Errorf("failed to remove alert rules: %w", err)
+	}
+	return nil
+}
+
+// Evaluate advances every rule against one observation and sends the
+// resulting notifications in the background.
+func (a *AlertEvaluator) Evaluate(observation metricsObservation) {
+	type pending struct {
+		targets JobNotifications
+		event   notification.Event
+	}
+	var sends []pending
+	a.mu.Lock()
+	if err := a.loadLocked(); err != nil {
+		a.mu.Unlock()
+		fmt.Fprintf(os.Stderr, "takod alerts: %v\n", err)
+		return
+	}
+	keys := make([]string, 0, len(a.environments))
+	for key := range a.environments {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		environment := a.environments[key]
+		project, envName, _ := strings.Cut(key, "/")
+		changed := environment.pruneSilences(observation.at)
+		for _, rule := range environment.Spec.Rules {
+			values := observation.node
+			if rule.Service != "" {
+				if observation.services == nil {
+					continue
+				}
+				values = observation.services[proxyRouteKey{project: project, environment: envName, service: rule.Service}]
+			} else if values == nil {
+				continue
+			}
+			state := environment.States[rule.Name]
+			next, event := advanceAlertState(project, envName, environment.Spec.Node, rule, state, values, observation.at, environment.silenced(rule.Name, observation.at))
+			if environment.States == nil {
+				environment.States = map[string]alertState{}
+			}
+			environment.States[rule.Name] = next
+			changed = changed || next.State != state.State || next.Notified != state.Notified
+			if event != nil && rule.Notifications != nil {
+				sends = append(sends, pending{targets: *rule.Notifications, event: *event})
+			}
+		}
+		if changed {
+			if err := a.persistLocked(project, envName, environment); err != nil {
+				fmt.Fprintf(os.Stderr, "takod alerts: %v\n", err)
+			}
+		}
+	}
+	a.mu.Unlock()
+
+	for _, send := range sends {
+		a.sends.Add(1)
+		go func(send pending) {
+			defer a.sends.Done()
+			if err := a.notify(send.targets, send.event); err != nil {
+				fmt.Fprintf(os.Stderr, "takod alert %s/%s %s failed to notify: %v\n", send.event.Project, send.event.Environment, send.event.Details["alert"], err)
+			}
+		}(send)
+	}
+}
+
+// advanceAlertState applies one observation to a rule's state and returns
+// the notification the transition calls for, if any.
+func advanceAlertState(project string, environment string, node string, rule AlertRule, state alertState, values map[string]float64, at time.Time, silenced bool) (alertState, *notification.Event) {
+	value, ok := values[rule.Metric]
+	if !ok {
+		// No sample, such as a service without a running replica, says
+		// nothing about the threshold: keep the state instead of resolving.
+		state.Value = nil
+		return state, nil
+	}
+	observed := value
+	state.Value = &observed
+	if value >= rule.Threshold {
+		if state.State == "" || state.State == AlertStateOK {
+			state.State = AlertStatePending
+			state.Since = at
+		}
+		if state.State == AlertStatePending && at.Sub(state.Since) >= time.Duration(rule.DurationSeconds)*time.Second {
+			state.State = AlertStateFiring
+		}
+		if state.State == AlertStateFiring && !state.Notified && !silenced {
+			state.Notified = true
+			event := alertFiringEvent(project, environment, node, rule, value, values)
+			return state, &event
+		}
+		return state, nil
+	}
+	wasNotified := state.Notified
+	state = alertState{State: AlertStateOK, Value: state.Value}
+	if wasNotified && !silenced {
+		event := notification.ResourceNormalEvent(project, environment, rule.Service, rule.Metric, value, rule.Threshold)
+		decorateAlertEvent(&event, node, rule, "resolved")
+		return state, &event
+	}
+	return state, nil
+}
+
+// alertFiringEvent builds the resource alert matching the rule's metric.
+func alertFiringEvent(project string, environment string, node string, rule AlertRule, value float64, values map[string]float64) notification.Event {
+	const mb = 1024 * 1024
+	const gb = 1024 * 1024 * 1024
+	var event notification.Event
+	switch rule.Metric {
+	case AlertMetricCPUPercent:
+		event = notification.HighCPUEvent(project, environment, rule.Service, value, rule.Threshold)
+	case AlertMetricMemoryPercent:
+		event = notification.HighMemoryEvent(project, environment, rule.Service, value, rule.Threshold, int64(values[alertObservedMemoryUsed]/mb), int64(values[alertObservedMemoryLimit]/mb))
+	case AlertMetricDiskPercent:
+		event = notification.HighDiskEvent(project, environment, value, rule.Threshold, int64(values[alertObservedDiskUsed]/gb), int64(values[alertObservedDiskTotal]/gb))
+	case AlertMetricMemoryBytes:
+		event = notification.Event{
+			Type:        notification.EventHighMemory,
+			Project:     project,
+			Environment: environment,
+			Service:     rule.Service,
+			Message:     fmt.Sprintf("Memory usage at %dMB (threshold: %dMB)", int64(value/mb), int64(rule.Threshold/mb)),
+			Details:     map[string]string{"used_mb": fmt.Sprintf("%d", int64(value/mb)), "threshold": fmt.Sprintf("%.0f", rule.Threshold)},
+			Timestamp:   time.Now(),
+		}
+	default:
+		event = notification.Event{
+			Type:        notification.EventHighCPU,
+			Project:     project,
+			Environment: environment,
+			Service:     rule.Service,
+			Message:     fmt.Sprintf("Load average at %.2f (threshold: %.2f)", value, rule.Threshold),
+			Details:     map[string]string{"load1": fmt.Sprintf("%.2f", value), "threshold": fmt.Sprintf("%.2f", rule.Threshold)},
+			Timestamp:   time.Now(),
+		}
+	}
+	decorateAlertEvent(&event, node, rule, "firing")
+	return event
+}
+
+func decorateAlertEvent(event *notification.Event, node string, rule AlertRule, status string) {
+	if event.Details == nil {
+		event.Details = map[string]string{}
+	}
+	event.Details["alert"] = rule.Name
+	event.Details["metric"] = rule.Metric
+	event.Details["severity"] = rule.Severity
+	event.Details["status"] = status
+	where := ""
+	if node != "" {
+		event.Details["node"] = node
+		where = " on " + node
+	}
+	event.Message = fmt.Sprintf("[%s] %s %s%s: %s", strings.ToUpper(rule.Severity), rule.Name, status, where, event.Message)
+}
+
+func (e *alertEnvironment) hasRule(name string) bool {
+	for _, rule := range e.Spec.Rules {
+		if rule.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// silencedUntil returns the latest end of the silences covering rule.
+func (e *alertEnvironment) silencedUntil(rule string, now time.Time) (time.Time, bool) {
+	var until time.Time
+	for _, silence := range e.Silences {
+		if (silence.Rule == "" || silence.Rule == rule) && silence.Until.After(now) && silence.Until.After(until) {
+			until = silence.Until
+		}
+	}
+	return until, !until.IsZero()
+}
+
+func (e *alertEnvironment) silenced(rule string, now time.Time) bool {
+	_, ok := e.silencedUntil(rule, now)
+	return ok
+}
+
+// pruneSilences drops expired silences and reports whether any were.
+func (e *alertEnvironment) pruneSilences(now time.Time) bool {
+	kept := e.Silences[:0]
+	for _, silence := range e.Silences {
+		if silence.Until.After(now) {
+			kept = append(kept, silence)
+		}
+	}
+	pruned := len(kept) != len(e.Silences)
+	e.Silences = kept
+	return pruned
+}
+
+// loadLocked reads persisted environments once. Callers hold a.mu.
+func (a *AlertEvaluator) loadLocked() error {
+	if a.loaded {
+		return nil
+	}
+	root := filepath.Join(a.dataDir, alertsDirName)
+	projects, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		a.loaded = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read alert rules: %w", err)
+	}
+	for _, project := range projects {
+		if !project.IsDir() || !isSafeProjectName(project.Name()) {
+			continue
+		}
+		environments, err := os.ReadDir(filepath.Join(root, project.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read alert rules: %w", err)
+		}
+		for _, entry := range environments {
+			name, ok := strings.CutSuffix(entry.Name(), ".json")
+			if entry.IsDir() || !ok || !isSafeRuntimeName(name) {
+				continue
+			}
+			path := filepath.Join(root, project.Name(), entry.Name())
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read alert rules: %w", err)
+			}
+			var environment alertEnvironment
+			if err := json.Unmarshal(data, &environment); err != nil {
+				return fmt.Errorf("failed to parse alert rules %s: %w", path, err)
+			}
+			if err := validateAlertSpec(&environment.Spec); err != nil {
+				return fmt.Errorf("invalid alert rules %s: %w", path, err)
+			}
+			a.environments[logShippingKey(project.Name(), name)] = &environment
+		}
+	}
+	a.loaded = true
+	return nil
+}
+
+func (a *AlertEvaluator) specPath(project string, environment string) string {
+	return filepath.Join(a.dataDir, alertsDirName, project, environment+".json")
+}
+
+func (a *AlertEvaluator) persistLocked(project string, environment string, current *alertEnvironment) error {
+	path := a.specPath(project, environment)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create alerts directory: %w", err)
+	}
+	data, err := json.MarshalIndent(current, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode alert rules: %w", err)
+	}
+	if err := writeFileAtomic(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write alert rules: %w", err)
+	}
+	return nil
+}
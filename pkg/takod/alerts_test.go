@@ -0,0 +1,221 @@
+package takod
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/notification"
+)
+
+type recordedAlertNotifications struct {
+	mu     sync.Mutex
+	events []notification.Event
+}
+
+func (r *recordedAlertNotifications) notify(_ JobNotifications, event notification.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *recordedAlertNotifications) take() []notification.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := r.events
+	r.events = nil
+	return events
+}
+
+func newTestAlertEvaluator(t *testing.T, dataDir string, now *time.Time) (*AlertEvaluator, *recordedAlertNotifications) {
+	t.Helper()
+	recorded := &recordedAlertNotifications{}
+	evaluator := NewAlertEvaluator(dataDir)
+	evaluator.notify = recorded.notify
+	evaluator.now = func() time.Time { return *now }
+	return evaluator, recorded
+}
+
+func testAlertSpec() *AlertSpec {
+	return &AlertSpec{Node: "node-a", Rules: []AlertRule{
+		{Name: "api-memory", Metric: AlertMetricMemoryPercent, Service: "api", Threshold: 90, DurationSeconds: 120, Severity: AlertSeverityCritical, Notifications: &JobNotifications{Webhook: "https://hooks.example.com/tako"}},
+		{Name: "node-disk", Metric: AlertMetricDiskPercent, Threshold: 80, Severity: AlertSeverityWarning, Notifications: &JobNotifications{Webhook: "https://hooks.example.com/tako"}},
+	}}
+}
+
+func apiMemoryObservation(at time.Time, percent float64) metricsObservation {
+	return metricsObservation{
+		at:   at,
+		node: map[string]float64{AlertMetricDiskPercent: 50},
+		services: map[proxyRouteKey]map[string]float64{
+			{project: "shop", environment: "production", service: "api"}: {AlertMetricMemoryPercent: percent, alertObservedMemoryUsed: percent * 1024 * 1024, alertObservedMemoryLimit: 100 * 1024 * 1024},
+		},
+	}
+}
+
+func evaluateAlerts(evaluator *AlertEvaluator, observation metricsObservation) {
+	evaluator.Evaluate(observation)
+	evaluator.sends.Wait()
+}
+
+func TestAlertEvaluatorFiresOnceAfterDurationAndResolves(t *testing.T) {
+	now := time.Date(2026, 7, 6, 12, 0, 0, 0, time.UTC)
+	evaluator, recorded := newTestAlertEvaluator(t, t.TempDir(), &now)
+	if _, err := evaluator.Apply(AlertsApplyRequest{Project: "shop", Environment: "production", Spec: testAlertSpec()}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	for minute := 0; minute <= 3; minute++ {
+		evaluateAlerts(evaluator, apiMemoryObservation(now.Add(time.Duration(minute)*time.Minute), 95))
+		events := recorded.take()
+		switch {
+		case minute < 2 && len(events) != 0:
+			t.Fatalf("minute %d: alert fired before its duration: %+v", minute, events)
+		case minute == 2 && (len(events) != 1 || events[0].Type != notification.EventHighMemory || events[0].Details["alert"] != "api-memory" || events[0].Details["severity"] != AlertSeverityCritical || events[0].Details["node"] != "node-a"):
+			t.Fatalf("minute 2: events = %+v", events)
+		case minute == 3 && len(events) != 0:
+			t.Fatalf("firing alert notified twice: %+v", events)
+		}
+	}
+	status, err := evaluator.Status("shop", "production")
+	if err != nil || status.Alerts[0].State != AlertStateFiring || *status.Alerts[0].Value != 95 || status.Alerts[1].State != AlertStateOK {
+		t.Fatalf("status = %+v, %v", status, err)
+	}
+
+	evaluateAlerts(evaluator, apiMemoryObservation(now.Add(4*time.Minute), 40))
+	events := recorded.take()
+	if len(events) != 1 || events[0].Type != notification.EventResourceNormal || !strings.Contains(events[0].Message, "api-memory resolved on node-a") {
+		t.Fatalf("resolve events = %+v", events)
+	}
+}
+
+func TestAlertEvaluatorKeepsStateWhileMetricIsMissing(t *testing.T) {
+	now := time.Date(2026, 7, 6, 12, 0, 0, 0, time.UTC)
+	evaluator, recorded := newTestAlertEvaluator(t, t.TempDir(), &now)
+	if _, err := evaluator.Apply(AlertsApplyRequest{Project: "shop", Environment: "production", Spec: testAlertSpec()}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	for minute := 0; minute <= 2; minute++ {
+		evaluateAlerts(evaluator, apiMemoryObservation(now.Add(time.Duration(minute)*time.Minute), 95))
+	}
+	if events := recorded.take(); len(events) != 1 || events[0].Type != notification.EventHighMemory {
+		t.Fatalf("firing events = %+v", events)
+	}
+
+	// The api replica is gone for a while: no sample for its memory.
+	for minute := 3; minute <= 5; minute++ {
+		evaluateAlerts(evaluator, metricsObservation{at: now.Add(time.Duration(minute) * time.Minute), node: map[string]float64{AlertMetricDiskPercent: 50}, services: map[proxyRouteKey]map[string]float64{}})
+	}
+	if events := recorded.take(); len(events) != 0 {
+		t.Fatalf("missing metric notified: %+v", events)
+	}
+	status, err := evaluator.Status("shop", "production")
+	if err != nil || status.Alerts[0].State != AlertStateFiring || status.Alerts[0].Value != nil {
+		t.Fatalf("status without a sample = %+v, %v", status, err)
+	}
+
+	evaluateAlerts(evaluator, apiMemoryObservation(now.Add(6*time.Minute), 40))
+	events := recorded.take()
+	if len(events) != 1 || events[0].Type != notification.EventResourceNormal || !strings.Contains(events[0].Message, "40") {
+		t.Fatalf("resolve events = %+v", events)
+	}
+}
+
+func TestAlertEvaluatorSilencesAndNotifiesWhenSilenceEnds(t *testing.T) {
+	now := time.Date(2026, 7, 6, 12, 0, 0, 0, time.UTC)
+	evaluator, recorded := newTestAlertEvaluator(t, t.TempDir(), &now)
+	if _, err := evaluator.Apply(AlertsApplyRequest{Project: "shop", Environment: "production", Spec: testAlertSpec()}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	response, err := evaluator.Silence(AlertSilenceRequest{Project: "shop", Environment: "production", Rule: "node-disk", Until: now.Add(time.Hour), Comment: "resizing volume"})
+	if err != nil {
+		t.Fatalf("Silence: %v", err)
+	}
+	if len(response.Silences) != 1 || response.Alerts[1].SilencedUntil == nil || response.Alerts[0].SilencedUntil != nil {
+		t.Fatalf("silence response = %+v", response)
+	}
+	if _, err := evaluator.Silence(AlertSilenceRequest{Project: "shop", Environment: "production", Rule: "missing", Until: now.Add(time.Hour)}); err == nil {
+		t.Fatal("silence for an unknown rule accepted")
+	}
+
+	full := metricsObservation{at: now, node: map[string]float64{AlertMetricDiskPercent: 93}, services: map[proxyRouteKey]map[string]float64{}}
+	evaluateAlerts(evaluator, full)
+	if events := recorded.take(); len(events) != 0 {
+		t.Fatalf("silenced rule notified: %+v", events)
+	}
+
+	full.at = now.Add(61 * time.Minute)
+	evaluateAlerts(evaluator, full)
+	events := recorded.take()
+	if len(events) != 1 || events[0].Type != notification.EventHighDisk || events[0].Details["alert"] != "node-disk" {
+		t.Fatalf("events after silence ended = %+v", events)
+	}
+	if status, _ := evaluator.Status("shop", "production"); len(status.Silences) != 0 {
+		t.Fatalf("expired silence still listed: %+v", status.Silences)
+	}
+}
+
+func TestAlertEvaluatorKeepsStateAcrossRedeployAndRestart(t *testing.T) {
+	dataDir := t.TempDir()
+	now := time.Date(2026, 7, 6, 12, 0, 0, 0, time.UTC)
+	evaluator, recorded := newTestAlertEvaluator(t, dataDir, &now)
+	if _, err := evaluator.Apply(AlertsApplyRequest{Project: "shop", Environment: "production", Spec: testAlertSpec()}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	evaluateAlerts(evaluator, apiMemoryObservation(now, 95))
+	evaluateAlerts(evaluator, apiMemoryObservation(now.Add(2*time.Minute), 95))
+	if events := recorded.take(); len(events) != 1 {
+		t.Fatalf("events = %+v", events)
+	}
+
+	redeployed := testAlertSpec()
+	redeployed.Rules[0].Severity = AlertSeverityWarning
+	if _, err := evaluator.Apply(AlertsApplyRequest{Project: "shop", Environment: "production", Spec: redeployed}); err != nil {
+		t.Fatalf("redeploy Apply: %v", err)
+	}
+	restarted, restartedRecorded := newTestAlertEvaluator(t, dataDir, &now)
+	evaluateAlerts(restarted, apiMemoryObservation(now.Add(3*time.Minute), 95))
+	if events := restartedRecorded.take(); len(events) != 0 {
+		t.Fatalf("restart re-notified a firing alert: %+v", events)
+	}
+
+	if err := restarted.RemoveProject("shop", "production"); err != nil {
+		t.Fatalf("RemoveProject: %v", err)
+	}
+	if status, err := restarted.Status("shop", "production"); err != nil || len(status.Alerts) != 0 {
+		t.Fatalf("status after remove = %+v, %v", status, err)
+	}
+}
+
+func TestMetricsHistoryHandsSamplesToObserver(t *testing.T) {
+	clock := &fakeMetricsHistoryClock{now: time.Date(2026, 7, 6, 12, 0, 0, 0, time.UTC)}
+	history := newTestMetricsHistory(t, clock)
+	var observed metricsObservation
+	history.observe = func(observation metricsObservation) { observed = observation }
+	if err := history.Sample(context.Background()); err != nil {
+		t.Fatalf("Sample: %v", err)
+	}
+	api := observed.services[proxyRouteKey{project: "shop", environment: "production", service: "api"}]
+	if observed.node[AlertMetricCPUPercent] != 40 || observed.node[AlertMetricMemoryPercent] != 50 {
+		t.Fatalf("node observation = %+v", observed.node)
+	}
+	if percent := api[AlertMetricMemoryPercent]; percent < 7.3 || percent > 7.4 {
+		t.Fatalf("api memory percent = %v (%+v)", percent, api)
+	}
+}
+
+func TestValidateAlertSpecRejectsInvalidRules(t *testing.T) {
+	for name, rule := range map[string]AlertRule{
+		"metric":   {Name: "a", Metric: "latency", Threshold: 1, Severity: AlertSeverityInfo},
+		"service":  {Name: "a", Metric: AlertMetricLoad1, Service: "api", Threshold: 1, Severity: AlertSeverityInfo},
+		"severity": {Name: "a", Metric: AlertMetricCPUPercent, Threshold: 1, Severity: "page"},
+		"route":    {Name: "a", Metric: AlertMetricCPUPercent, Threshold: 1, Severity: AlertSeverityInfo, Notifications: &JobNotifications{Slack: "ftp://hooks"}},
+	} {
+		if err := validateAlertSpec(&AlertSpec{Rules: []AlertRule{rule}}); err == nil {
+			t.Fatalf("%s: rule accepted", name)
+		}
+	}
+}
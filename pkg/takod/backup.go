@@ -29,6 +29,21 @@ type BackupRequest struct {
 	BackupID       string               `json:"backupId,omitempty"`
 	RetentionDays  int                  `json:"retentionDays,omitempty"`
 	Storage        *BackupStorageConfig `json:"storage,omitempty"`
+	// Service names the container dumps and hooks run in; its first running
+	// replica is used.
+	Service    string `json:"service,omitempty"`
+	Mode       string `json:"mode,omitempty"`
+	Database   string `json:"database,omitempty"`
+	PreBackup  string `json:"preBackup,omitempty"`
+	PostBackup string `json:"postBackup,omitempty"`
+	// FromStorage lets restore download the backup from Storage when it is
+	// no longer on the node.
+	FromStorage bool `json:"fromStorage,omitempty"`
+	// Retention replaces RetentionDays with grandfather-father-son buckets.
+	Retention *BackupRetentionPolicy `json:"retention,omitempty"`
+	// PITR turns on WAL archiving in the service's Postgres before a
+	// pg_basebackup, and prunes archived WAL no remaining base backup needs.
+	PITR bool `json:"pitr,omitempty"`
 }
 
 type BackupInfo struct {
@@ -39,8 +54,11 @@ type BackupInfo struct {
 	CreatedAt   time.Time         `json:"createdAt"`
 	Path        string            `json:"path"`
 	Compression string            `json:"compression"`
+	Mode        string            `json:"mode,omitempty"`
 	Remote      *BackupRemoteInfo `json:"remote,omitempty"`
 	Warnings    []string          `json:"warnings,omitempty"`
+	// Verification is the latest restore drill of this backup, if any.
+	Verification *BackupVerification `json:"verification,omitempty"`
 }
 
 type BackupListResponse struct {
@@ -56,6 +74,11 @@ type BackupRemoteInfo struct {
 	Bucket   string `json:"bucket"`
 	Key      string `json:"key"`
 	Endpoint string `json:"endpoint,omitempty"`
+	// Format is "chunked" when Key names an encrypted snapshot; Chunks counts
+	// its chunks and NewChunks those this upload had to send.
+	Format    string `json:"format,omitempty"`
+	Chunks    int    `json:"chunks,omitempty"`
+	NewChunks int    `json:"newChunks,omitempty"`
 }
 
 type BackupStorageConfig struct {
@@ -68,6 +91,22 @@ type BackupStorageConfig struct {
 	SecretAccessKey string `json:"secretAccessKey,omitempty"`
 	SessionToken    string `json:"sessionToken,omitempty"`
 	ForcePathStyle  bool   `json:"forcePathStyle,omitempty"`
+	// Format selects archive uploads or the encrypted, deduplicated chunked
+	// repository; EncryptionKey is the chunk passphrase.
+	Format        string `json:"format,omitempty"`
+	EncryptionKey string `json:"encryptionKey,omitempty"`
+	// Path is the mounted directory for filesystem storage or the remote
+	// directory for sftp. Host, Port, User, Password, PrivateKey, and HostKey
+	// reach an sftp server; HostKey pins its authorized_keys-form public key.
+	Path       string `json:"path,omitempty"`
+	Host       string `json:"host,omitempty"`
+	Port       int    `json:"port,omitempty"`
+	User       string `json:"user,omitempty"`
+	Password   string `json:"password,omitempty"`
+	PrivateKey string `json:"privateKey,omitempty"`
+	HostKey    string `json:"hostKey,omitempty"`
+	// Node names the cluster node that receives peer replicas over the mesh.
+	Node string `json:"node,omitempty"`
 }
 
 func CreateVolumeBackup(ctx context.Context, req BackupRequest) (*BackupInfo, error) {
@@ -87,24 +126,53 @@ func CreateVolumeBackup(ctx context.Context, req BackupRequest) (*BackupInfo, er
 		return nil, fmt.Errorf("volume %s does not exist", fullVolumeName)
 	}
 
-	backupID := backupIDForRequest(req, time.Now())
-	backupFile := backupFileName(req.Volume, backupID)
-	if _, err := runDocker(
-		ctx,
-		"run", "--rm",
-		"-v", fullVolumeName+":/source:ro",
-		"-v", backupPath+":/backup",
-		backupImage,
-		"tar", "-czf", "/backup/"+backupFile, "-C", "/source", ".",
-	); err != nil {
-		return nil, fmt.Errorf("failed to create backup: %w", err)
+	container := ""
+	if backupNeedsServiceContainer(req) {
+		resolved, err := resolveBackupContainer(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		container = resolved
 	}
 
+	backupID := backupIDForRequest(req, time.Now())
+	mode := normalizeBackupMode(req.Mode)
+	backupFile := backupArtifactFileName(req.Volume, backupID, mode)
 	path := filepath.Join(backupPath, backupFile)
+	var archiveWarnings []string
+	if req.PITR {
+		configured, err := ensurePostgresWALArchiving(ctx, container)
+		if err != nil {
+			return nil, err
+		}
+		archiveWarnings = configured
+	}
+	warnings, err := runBackupHooks(ctx, req, container, func() error {
+		if isDatabaseDumpMode(mode) {
+			return writeDatabaseDump(ctx, req, mode, container, path)
+		}
+		if _, err := runDocker(
+			ctx,
+			"run", "--rm",
+			"-v", fullVolumeName+":/source:ro",
+			"-v", backupPath+":/backup",
+			backupImage,
+			"tar", "-czf", "/backup/"+backupFile, "-C", "/source", ".",
+		); err != nil {
+			return fmt.Errorf("failed to create backup: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	info, err := backupInfoFromPath(backupPath, path)
 	if err != nil {
 		return nil, err
 	}
+	info.Warnings = append(info.Warnings, archiveWarnings...)
+	info.Warnings = append(info.Warnings, warnings...)
 	if req.Storage != nil {
 		remote, err := UploadBackupObject(ctx, *req.Storage, BackupObject{
 			Project:     req.Project,
@@ -119,16 +187,22 @@ func CreateVolumeBackup(ctx context.Context, req BackupRequest) (*BackupInfo, er
 			return &info, nil
 		}
 		info.Remote = remote
-		if req.RetentionDays > 0 {
+		if req.RetentionDays > 0 || req.Retention != nil {
 			if err := CleanupBackupObjects(ctx, *req.Storage, BackupObjectRetention{
 				Project:       req.Project,
 				Environment:   req.Environment,
 				Volume:        req.Volume,
 				RetentionDays: req.RetentionDays,
+				Policy:        req.Retention,
 			}); err != nil {
 				info.Warnings = append(info.Warnings, fmt.Sprintf("remote backup retention cleanup failed: %v", err))
 			}
 		}
+		if req.PITR {
+			if err := pruneWALArchive(ctx, *req.Storage, req.Project, req.Environment, req.Volume); err != nil {
+				info.Warnings = append(info.Warnings, fmt.Sprintf("WAL archive cleanup failed: %v", err))
+			}
+		}
 	}
 	return &info, nil
 }
@@ -141,14 +215,30 @@ func RestoreVolumeBackup(ctx context.Context, req BackupRequest) error {
 		return err
 	}
 	backupPath := backupDirectory(req)
-	backupFile := backupFileName(req.Volume, req.BackupID)
-	backupFullPath := filepath.Join(backupPath, backupFile)
-	if _, err := os.Stat(backupFullPath); err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("backup not found: %s", backupFullPath)
+	backupFullPath, format, err := findBackupArtifact(backupPath, req.Volume, req.BackupID)
+	if err != nil && req.FromStorage && req.Storage != nil {
+		if _, fetchErr := fetchBackupFromStorage(ctx, req); fetchErr != nil {
+			return fmt.Errorf("%v; fetching from object storage failed: %w", err, fetchErr)
 		}
-		return fmt.Errorf("failed to inspect backup: %w", err)
+		backupFullPath, format, err = findBackupArtifact(backupPath, req.Volume, req.BackupID)
+	}
+	if err != nil {
+		return err
 	}
+	if format.mode == BackupModePgBaseBackup {
+		return fmt.Errorf("a pg_basebackup backup restores into a new volume; use tako backup restore --volume %s --at <time>", req.Volume)
+	}
+	if isDatabaseDumpMode(format.mode) {
+		if req.Service == "" {
+			return fmt.Errorf("service is required to restore a %s backup", format.mode)
+		}
+		container, err := resolveBackupContainer(ctx, req)
+		if err != nil {
+			return err
+		}
+		return restoreDatabaseDump(ctx, req, format.mode, container, backupFullPath)
+	}
+	backupFile := filepath.Base(backupFullPath)
 
 	fullVolumeName := fullBackupVolumeName(req)
 	if _, err := runDocker(ctx, "volume", "inspect", fullVolumeName); err != nil {
@@ -202,6 +292,7 @@ func ListVolumeBackups(ctx context.Context, req BackupRequest) (*BackupListRespo
 		if req.Volume != "" && info.Volume != req.Volume {
 			continue
 		}
+		info.Verification = readBackupVerification(req, info.Volume, info.ID)
 		backups = append(backups, info)
 	}
 	sort.Slice(backups, func(i, j int) bool {
@@ -220,10 +311,13 @@ func DeleteVolumeBackup(ctx context.Context, req BackupRequest) error {
 	if err := ctx.Err(); err != nil {
 		return err
 	}
-	path := filepath.Join(backupDirectory(req), backupFileName(req.Volume, req.BackupID))
-	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to delete backup: %w", err)
+	for _, format := range backupArtifactFormats {
+		path := filepath.Join(backupDirectory(req), backupArtifactFileName(req.Volume, req.BackupID, format.mode))
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete backup: %w", err)
+		}
 	}
+	removeBackupVerification(req, req.Volume, req.BackupID)
 	return nil
 }
 
@@ -246,8 +340,8 @@ func CleanupOldBackups(ctx context.Context, req BackupRequest) (*BackupCleanupRe
 		return nil, fmt.Errorf("failed to list backups: %w", err)
 	}
 
-	cutoff := time.Now().UTC().AddDate(0, 0, -req.RetentionDays)
-	deleted := 0
+	var backups []BackupInfo
+	candidates := make(map[string][]retentionCandidate)
 	for _, entry := range entries {
 		if entry.IsDir() {
 			continue
@@ -256,15 +350,33 @@ func CleanupOldBackups(ctx context.Context, req BackupRequest) (*BackupCleanupRe
 		if err != nil {
 			continue
 		}
-		if info.CreatedAt.IsZero() || info.CreatedAt.After(cutoff) {
+		if req.Volume != "" && info.Volume != req.Volume {
 			continue
 		}
-		if req.Volume != "" && info.Volume != req.Volume {
+		backups = append(backups, info)
+		candidates[info.Volume] = append(candidates[info.Volume], retentionCandidate{ID: info.ID, CreatedAt: info.CreatedAt})
+	}
+	kept := make(map[string]map[string]bool)
+	if req.Retention != nil {
+		for volume, volumeCandidates := range candidates {
+			kept[volume] = retainedBackupIDs(volumeCandidates, *req.Retention)
+		}
+	}
+
+	cutoff := time.Now().UTC().AddDate(0, 0, -req.RetentionDays)
+	deleted := 0
+	for _, info := range backups {
+		if req.Retention != nil {
+			if kept[info.Volume][info.ID] {
+				continue
+			}
+		} else if info.CreatedAt.IsZero() || info.CreatedAt.After(cutoff) {
 			continue
 		}
 		if err := os.Remove(info.Path); err != nil {
 			return nil, fmt.Errorf("failed to delete old backup %s: %w", info.Path, err)
 		}
+		removeBackupVerification(req, info.Volume, info.ID)
 		deleted++
 	}
 	return &BackupCleanupResponse{Deleted: deleted}, nil
@@ -293,12 +405,18 @@ func validateBackupRequest(req BackupRequest, requireVolume bool, requireBackupI
 	if req.RetentionDays < 0 {
 		return fmt.Errorf("retentionDays cannot be negative")
 	}
+	if err := validateBackupRetentionPolicy(req.Retention); err != nil {
+		return err
+	}
 	if req.Storage != nil {
 		if err := ValidateBackupStorage(*req.Storage); err != nil {
 			return err
 		}
 	}
-	return nil
+	if req.Service != "" && !isSafeServiceName(req.Service) {
+		return fmt.Errorf("invalid service name")
+	}
+	return validateBackupMode(req)
 }
 
 func backupIDForRequest(req BackupRequest, now time.Time) string {
@@ -320,7 +438,7 @@ func fullBackupVolumeName(req BackupRequest) string {
 }
 
 func backupFileName(volume string, backupID string) string {
-	return volume + "_" + backupID + ".tar.gz"
+	return backupArtifactFileName(volume, backupID, BackupModeVolume)
 }
 
 func restoreVolumeScript(backupFile string) string {
@@ -335,18 +453,22 @@ if [ ! -f "$backupPath" ]; then
   echo "backup file not found" >&2
   exit 1
 fi
-tar -tzf "$backupPath" | awk '
+tar -tzf "$backupPath" | `+backupArchiveEntryCheck+`
+find /target -mindepth 1 -maxdepth 1 -exec rm -rf -- {} \;
+tar -xzf "$backupPath" -C /target
+`, quotedBackup)
+}
+
+// backupArchiveEntryCheck reads a tar listing and fails on absolute or
+// parent-relative entries before anything is extracted.
+const backupArchiveEntryCheck = `awk '
 BEGIN { bad = 0 }
 /^\/|(^|\/)\.\.(\/|$)/ {
   print "unsafe backup entry: " $0 > "/dev/stderr"
   bad = 1
 }
 END { exit bad }
-'
-find /target -mindepth 1 -maxdepth 1 -exec rm -rf -- {} \;
-tar -xzf "$backupPath" -C /target
-`, quotedBackup)
-}
+'`
 
 func shellQuote(value string) string {
 	if value == "" {
@@ -357,18 +479,9 @@ func shellQuote(value string) string {
 
 func backupInfoFromPath(root string, path string) (BackupInfo, error) {
 	filename := filepath.Base(path)
-	if !strings.HasSuffix(filename, ".tar.gz") {
-		return BackupInfo{}, fmt.Errorf("not a backup file")
-	}
-	base := strings.TrimSuffix(filename, ".tar.gz")
-	separator := strings.LastIndex(base, "_")
-	if separator <= 0 || separator == len(base)-1 {
-		return BackupInfo{}, fmt.Errorf("invalid backup filename")
-	}
-	volume := base[:separator]
-	backupID := base[separator+1:]
-	if !isSafeBackupVolume(volume) || !isSafeBackupID(backupID) {
-		return BackupInfo{}, fmt.Errorf("invalid backup filename")
+	volume, backupID, format, err := parseBackupFileName(filename)
+	if err != nil {
+		return BackupInfo{}, err
 	}
 	info, err := os.Stat(path)
 	if err != nil {
@@ -381,7 +494,8 @@ func backupInfoFromPath(root string, path string) (BackupInfo, error) {
 		Size:        info.Size(),
 		CreatedAt:   createdAt.UTC(),
 		Path:        filepath.Join(root, filename),
-		Compression: "gzip",
+		Compression: format.compression,
+		Mode:        format.mode,
 	}, nil
 }
 
@@ -406,6 +520,25 @@ func isSafeBackupID(value string) bool {
 	return err == nil
 }
 
+// parseBackupFileName splits a <volume>_<id><suffix> artifact name.
+func parseBackupFileName(filename string) (string, string, backupArtifactFormat, error) {
+	format, ok := backupArtifactFormatForFile(filename)
+	if !ok {
+		return "", "", backupArtifactFormat{}, fmt.Errorf("not a backup file")
+	}
+	base := strings.TrimSuffix(filename, format.suffix)
+	separator := strings.LastIndex(base, "_")
+	if separator <= 0 || separator == len(base)-1 {
+		return "", "", backupArtifactFormat{}, fmt.Errorf("invalid backup filename")
+	}
+	volume := base[:separator]
+	backupID := base[separator+1:]
+	if !isSafeBackupVolume(volume) || !isSafeBackupID(backupID) {
+		return "", "", backupArtifactFormat{}, fmt.Errorf("invalid backup filename")
+	}
+	return volume, backupID, format, nil
+}
+
 func backupIDTimestamp(value string) (time.Time, error) {
 	const layout = "20060102-150405"
 	if len(value) != len(layout) {
@@ -0,0 +1,829 @@
+package takod
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/redentordev/tako-cli/pkg/crypto"
+)
+
+// Chunked backups store each volume as a content-addressed repository next to
+// its archive keys:
+//
+//	<prefix>/<project>/<env>/<volume>/.tako-chunks/config      key derivation parameters
+//	<prefix>/<project>/<env>/<volume>/.tako-chunks/data/ab/<id> encrypted chunks
+//	<prefix>/<project>/<env>/<volume>/<archive name>.snapshot  encrypted chunk list
+//
+// A repository belongs to one volume so retention for one volume never
+// collects chunks of another.
+const (
+	chunkRepositoryDir     = ".tako-chunks"
+	chunkSnapshotSuffix    = ".snapshot"
+	chunkRepositoryVersion = 1
+	chunkSnapshotVersion   = 1
+
+	backupChunkMinSize = 512 << 10
+	backupChunkMaxSize = 4 << 20
+	// A boundary is found when the top 20 bits of the gear hash are zero, so
+	// chunks average about 1 MiB past the minimum.
+	backupChunkBoundaryMask = uint64(1<<20-1) << 44
+
+	// Unreferenced chunks younger than this may belong to an upload that has
+	// not written its snapshot yet.
+	chunkGCGracePeriod = 24 * time.Hour
+
+	maxBackupStoreObjectBytes = 64 << 20
+	chunkRepositoryCheck      = "tako chunk repository"
+)
+
+var errBackupObjectNotFound = errors.New("backup object not found")
+
+var (
+	newBackupObjectStore      = openBackupObjectStore
+	uploadChunkedBackupWith   = uploadChunkedBackup
+	cleanupChunkedBackupsWith = cleanupChunkedBackups
+)
+
+// backupChunkGear is the fixed gear table for content-defined chunking.
+var backupChunkGear = func() (table [256]uint64) {
+	for i := range table {
+		sum := sha256.Sum256([]byte{'t', 'a', 'k', 'o', byte(i)})
+		table[i] = binary.LittleEndian.Uint64(sum[:8])
+	}
+	return table
+}()
+
+type backupStoreObject struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// backupObjectStore is the small object API chunked backups need. Get returns
+// errBackupObjectNotFound for a missing key.
+type backupObjectStore interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Put(ctx context.Context, key string, data []byte) error
+	List(ctx context.Context, prefix string) ([]backupStoreObject, error)
+	Delete(ctx context.Context, keys []string) error
+	Close() error
+}
+
+type chunkRepositoryConfig struct {
+	Version int                   `json:"version"`
+	KDF     crypto.ChunkKeyParams `json:"kdf"`
+	Check   string                `json:"check"` // Base64 sealed check value proving the passphrase
+}
+
+type chunkSnapshot struct {
+	Version   int       `json:"version"`
+	FileName  string    `json:"fileName"`
+	Gzip      bool      `json:"gzip"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	Chunks    []string  `json:"chunks"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type chunkRepository struct {
+	store backupObjectStore
+	root  string
+	keys  *crypto.ChunkKeys
+}
+
+func chunkRepositoryRoot(prefix string, project string, environment string, volume string) string {
+	return joinObjectKey(cleanObjectKeyPrefix(prefix), project, environment, volume, chunkRepositoryDir) + "/"
+}
+
+func isChunkRepositoryKey(key string) bool {
+	return strings.Contains("/"+key, "/"+chunkRepositoryDir+"/")
+}
+
+func chunkSnapshotKey(prefix string, object BackupObject) string {
+	return backupObjectKey(prefix, object) + chunkSnapshotSuffix
+}
+
+// openChunkRepository loads the volume repository, creating it on first use.
+// A passphrase that cannot open the check value is rejected before any chunk
+// is written, so one repository never mixes keys.
+func openChunkRepository(ctx context.Context, store backupObjectStore, passphrase string, root string, create bool) (*chunkRepository, error) {
+	configKey := root + "config"
+	data, err := store.Get(ctx, configKey)
+	if errors.Is(err, errBackupObjectNotFound) {
+		if !create {
+			return nil, fmt.Errorf("chunk repository %s does not exist", root)
+		}
+		return initChunkRepository(ctx, store, passphrase, root)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk repository config: %w", err)
+	}
+	var cfg chunkRepositoryConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid chunk repository config: %w", err)
+	}
+	if cfg.Version != chunkRepositoryVersion {
+		return nil, fmt.Errorf("unsupported chunk repository version %d", cfg.Version)
+	}
+	keys, err := crypto.DeriveChunkKeys(passphrase, cfg.KDF)
+	if err != nil {
+		return nil, err
+	}
+	check, err := base64.StdEncoding.DecodeString(cfg.Check)
+	if err != nil {
+		return nil, fmt.Errorf("invalid chunk repository check value")
+	}
+	if plaintext, err := keys.Open(configKey, check); err != nil || string(plaintext) != chunkRepositoryCheck {
+		return nil, fmt.Errorf("backup storage encryptionKey does not match chunk repository %s", root)
+	}
+	return &chunkRepository{store: store, root: root, keys: keys}, nil
+}
+
+func initChunkRepository(ctx context.Context, store backupObjectStore, passphrase string, root string) (*chunkRepository, error) {
+	params, err := crypto.NewChunkKeyParams()
+	if err != nil {
+		return nil, err
+	}
+	keys, err := crypto.DeriveChunkKeys(passphrase, params)
+	if err != nil {
+		return nil, err
+	}
+	configKey := root + "config"
+	check, err := keys.Seal(configKey, []byte(chunkRepositoryCheck))
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(chunkRepositoryConfig{
+		Version: chunkRepositoryVersion,
+		KDF:     params,
+		Check:   base64.StdEncoding.EncodeToString(check),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Put(ctx, configKey, data); err != nil {
+		return nil, fmt.Errorf("failed to create chunk repository: %w", err)
+	}
+	return &chunkRepository{store: store, root: root, keys: keys}, nil
+}
+
+func (r *chunkRepository) chunkKey(id string) string {
+	return r.root + "data/" + id[:2] + "/" + id
+}
+
+// chunkIDs lists the chunks already stored with their object metadata.
+func (r *chunkRepository) chunkIDs(ctx context.Context) (map[string]backupStoreObject, error) {
+	objects, err := r.store.List(ctx, r.root+"data/")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backup chunks: %w", err)
+	}
+	ids := make(map[string]backupStoreObject, len(objects))
+	for _, object := range objects {
+		ids[path.Base(object.Key)] = object
+	}
+	return ids, nil
+}
+
+// snapshotKeys lists the keys of the volume's stored snapshots.
+func (r *chunkRepository) snapshotKeys(ctx context.Context) ([]string, error) {
+	volumeDir := strings.TrimSuffix(r.root, chunkRepositoryDir+"/")
+	objects, err := r.store.List(ctx, volumeDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backup snapshots: %w", err)
+	}
+	var keys []string
+	for _, object := range objects {
+		if strings.HasSuffix(object.Key, chunkSnapshotSuffix) && !isChunkRepositoryKey(object.Key) {
+			keys = append(keys, object.Key)
+		}
+	}
+	return keys, nil
+}
+
+// committedChunks returns the chunks referenced by the volume's stored
+// snapshots. A snapshot that cannot be read references nothing, so its
+// chunks are uploaded again rather than trusted to survive collection.
+func (r *chunkRepository) committedChunks(ctx context.Context) (map[string]bool, error) {
+	keys, err := r.snapshotKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	committed := make(map[string]bool)
+	for _, key := range keys {
+		snapshot, err := r.getSnapshot(ctx, key)
+		if err != nil {
+			continue
+		}
+		for _, id := range snapshot.Chunks {
+			committed[id] = true
+		}
+	}
+	return committed, nil
+}
+
+func (r *chunkRepository) putChunk(ctx context.Context, id string, plaintext []byte) error {
+	compressed, err := deflateBackupObject(plaintext)
+	if err != nil {
+		return err
+	}
+	sealed, err := r.keys.Seal(id, compressed)
+	if err != nil {
+		return err
+	}
+	if err := r.store.Put(ctx, r.chunkKey(id), sealed); err != nil {
+		return fmt.Errorf("failed to upload backup chunk: %w", err)
+	}
+	return nil
+}
+
+func (r *chunkRepository) getChunk(ctx context.Context, id string) ([]byte, error) {
+	if !isChunkID(id) {
+		return nil, fmt.Errorf("invalid backup chunk id")
+	}
+	sealed, err := r.store.Get(ctx, r.chunkKey(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download backup chunk %s: %w", id, err)
+	}
+	compressed, err := r.keys.Open(id, sealed)
+	if err != nil {
+		return nil, fmt.Errorf("backup chunk %s: %w", id, err)
+	}
+	plaintext, err := inflateBackupObject(compressed, backupChunkMaxSize)
+	if err != nil {
+		return nil, fmt.Errorf("backup chunk %s: %w", id, err)
+	}
+	if len(plaintext) > backupChunkMaxSize || r.keys.ID(plaintext) != id {
+		return nil, fmt.Errorf("backup chunk %s failed verification", id)
+	}
+	return plaintext, nil
+}
+
+func deflateBackupObject(plaintext []byte) ([]byte, error) {
+	var compressed bytes.Buffer
+	writer, err := flate.NewWriter(&compressed, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
+
+// inflateBackupObject reads at most limit+1 bytes so callers can reject an
+// object that decompresses past its limit.
+func inflateBackupObject(compressed []byte, limit int64) ([]byte, error) {
+	return io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(compressed)), limit+1))
+}
+
+func (r *chunkRepository) putSnapshot(ctx context.Context, key string, snapshot chunkSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	sealed, err := r.keys.Seal(path.Base(key), data)
+	if err != nil {
+		return err
+	}
+	if err := r.store.Put(ctx, key, sealed); err != nil {
+		return fmt.Errorf("failed to upload backup snapshot: %w", err)
+	}
+	return nil
+}
+
+func (r *chunkRepository) getSnapshot(ctx context.Context, key string) (*chunkSnapshot, error) {
+	sealed, err := r.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := r.keys.Open(path.Base(key), sealed)
+	if err != nil {
+		return nil, fmt.Errorf("backup snapshot %s: %w", path.Base(key), err)
+	}
+	var snapshot chunkSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("invalid backup snapshot %s: %w", path.Base(key), err)
+	}
+	if snapshot.Version != chunkSnapshotVersion {
+		return nil, fmt.Errorf("unsupported backup snapshot version %d", snapshot.Version)
+	}
+	return &snapshot, nil
+}
+
+// uploadChunkedBackup splits the artifact into content-defined chunks and
+// uploads only the chunks the volume repository does not already hold.
+// Gzipped archives are chunked decompressed, since a small change shifts
+// every compressed byte after it.
+func uploadChunkedBackup(ctx context.Context, storage BackupStorageConfig, object BackupObject) (*BackupRemoteInfo, error) {
+	store, err := newBackupObjectStore(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	repo, err := openChunkRepository(ctx, store, storage.EncryptionKey, chunkRepositoryRoot(storage.Prefix, object.Project, object.Environment, object.Volume), true)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := repo.chunkIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	committed, err := repo.committedChunks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	source, gzipped, closeSource, err := openChunkSource(object.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer closeSource()
+
+	hash := sha256.New()
+	chunker := newBackupChunker(io.TeeReader(source, hash))
+	snapshot := chunkSnapshot{
+		Version:   chunkSnapshotVersion,
+		FileName:  backupObjectFileName(object),
+		Gzip:      gzipped,
+		CreatedAt: object.CreatedAt.UTC(),
+	}
+	newChunks := 0
+	reused := make(map[string]bool)
+	for {
+		chunk, err := chunker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read backup for upload: %w", err)
+		}
+		id := repo.keys.ID(chunk)
+		snapshot.Chunks = append(snapshot.Chunks, id)
+		snapshot.Size += int64(len(chunk))
+		// A stored chunk no snapshot references may be an orphan that a
+		// concurrent collection is about to delete once it is past the grace
+		// period. Uploading it again restarts that period for this snapshot.
+		if _, ok := existing[id]; ok && committed[id] {
+			reused[id] = true
+			continue
+		}
+		if err := repo.putChunk(ctx, id, chunk); err != nil {
+			return nil, err
+		}
+		existing[id] = backupStoreObject{}
+		committed[id] = true
+		newChunks++
+	}
+	snapshot.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	key := chunkSnapshotKey(storage.Prefix, object)
+	if err := repo.putSnapshot(ctx, key, snapshot); err != nil {
+		return nil, err
+	}
+	resent, err := resendCollectedChunks(ctx, repo, object.Path, reused)
+	if err != nil {
+		return nil, err
+	}
+	newChunks += resent
+	return &BackupRemoteInfo{
+		Provider:  storage.Provider,
+		Bucket:    storage.Bucket,
+		Key:       key,
+		Endpoint:  backupStorageLocation(storage),
+		Format:    BackupStorageFormatChunked,
+		Chunks:    len(snapshot.Chunks),
+		NewChunks: newChunks,
+	}, nil
+}
+
+// openChunkSource opens a backup artifact for chunking, decompressing gzipped
+// archives. The returned func closes it.
+func openChunkSource(artifactPath string) (io.Reader, bool, func(), error) {
+	file, err := os.Open(artifactPath)
+	if err != nil {
+		return nil, false, nil, fmt.Errorf("failed to open backup for upload: %w", err)
+	}
+	if format, ok := backupArtifactFormatForFile(path.Base(artifactPath)); !ok || format.compression != "gzip" {
+		return file, false, func() { _ = file.Close() }, nil
+	}
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, false, nil, fmt.Errorf("failed to read backup archive: %w", err)
+	}
+	return reader, true, func() {
+		_ = reader.Close()
+		_ = file.Close()
+	}, nil
+}
+
+// resendCollectedChunks uploads again the reused chunks that are gone once
+// the snapshot is committed. A collection that expired the only snapshots
+// referencing them while the upload ran could not see the new snapshot yet
+// and deleted them as garbage; now that it is stored they are safe.
+func resendCollectedChunks(ctx context.Context, repo *chunkRepository, artifactPath string, reused map[string]bool) (int, error) {
+	if len(reused) == 0 {
+		return 0, nil
+	}
+	stored, err := repo.chunkIDs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	missing := make(map[string]bool)
+	for id := range reused {
+		if _, ok := stored[id]; !ok {
+			missing[id] = true
+		}
+	}
+	if len(missing) == 0 {
+		return 0, nil
+	}
+	source, _, closeSource, err := openChunkSource(artifactPath)
+	if err != nil {
+		return 0, err
+	}
+	defer closeSource()
+	chunker := newBackupChunker(source)
+	resent := 0
+	for len(missing) > 0 {
+		chunk, err := chunker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return resent, fmt.Errorf("failed to read backup for upload: %w", err)
+		}
+		id := repo.keys.ID(chunk)
+		if !missing[id] {
+			continue
+		}
+		if err := repo.putChunk(ctx, id, chunk); err != nil {
+			return resent, err
+		}
+		delete(missing, id)
+		resent++
+	}
+	if len(missing) > 0 {
+		return resent, fmt.Errorf("backup changed during upload; %d collected chunks could not be sent again", len(missing))
+	}
+	return resent, nil
+}
+
+// cleanupChunkedBackups expires snapshots by retention and then deletes chunks no
+// surviving snapshot references. A snapshot that cannot be read stops chunk
+// collection for its volume rather than risk deleting data it needs.
+func cleanupChunkedBackups(ctx context.Context, storage BackupStorageConfig, retention BackupObjectRetention) error {
+	store, err := newBackupObjectStore(ctx, storage)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	objects, err := store.List(ctx, backupObjectRetentionPrefix(storage.Prefix, retention))
+	if err != nil {
+		return fmt.Errorf("failed to list object backups: %w", err)
+	}
+	expired := expiredBackupObjectKeys(objects, retention, time.Now().UTC())
+	expiredKeys := make(map[string]bool, len(expired))
+	for _, key := range expired {
+		expiredKeys[key] = true
+	}
+
+	snapshots := make(map[string][]string)
+	repositories := make(map[string]bool)
+	for _, object := range objects {
+		if index := strings.Index(object.Key, "/"+chunkRepositoryDir+"/"); index >= 0 {
+			repositories[object.Key[:index+len(chunkRepositoryDir)+2]] = true
+			continue
+		}
+		if !expiredKeys[object.Key] && strings.HasSuffix(object.Key, chunkSnapshotSuffix) {
+			root := path.Dir(object.Key) + "/" + chunkRepositoryDir + "/"
+			snapshots[root] = append(snapshots[root], object.Key)
+		}
+	}
+	if err := store.Delete(ctx, expired); err != nil {
+		return fmt.Errorf("failed to delete old object backups: %w", err)
+	}
+
+	for root := range repositories {
+		if err := collectChunkGarbage(ctx, store, storage.EncryptionKey, root, snapshots[root]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func collectChunkGarbage(ctx context.Context, store backupObjectStore, passphrase string, root string, snapshotKeys []string) error {
+	repo, err := openChunkRepository(ctx, store, passphrase, root, false)
+	if err != nil {
+		return err
+	}
+	referenced := make(map[string]bool)
+	read := make(map[string]bool, len(snapshotKeys))
+	readSnapshots := func(keys []string) error {
+		for _, key := range keys {
+			if read[key] {
+				continue
+			}
+			read[key] = true
+			snapshot, err := repo.getSnapshot(ctx, key)
+			if err != nil {
+				return fmt.Errorf("chunk collection skipped for %s: %w", root, err)
+			}
+			for _, id := range snapshot.Chunks {
+				referenced[id] = true
+			}
+		}
+		return nil
+	}
+	if err := readSnapshots(snapshotKeys); err != nil {
+		return err
+	}
+	chunks, err := repo.chunkIDs(ctx)
+	if err != nil {
+		return err
+	}
+	// An upload reusing chunks of a snapshot expired in this pass may have
+	// committed since the listing above, so list again right before deleting.
+	current, err := repo.snapshotKeys(ctx)
+	if err != nil {
+		return err
+	}
+	if err := readSnapshots(current); err != nil {
+		return err
+	}
+	graceCutoff := time.Now().UTC().Add(-chunkGCGracePeriod)
+	var unused []string
+	for id, object := range chunks {
+		if referenced[id] || object.LastModified.After(graceCutoff) {
+			continue
+		}
+		unused = append(unused, object.Key)
+	}
+	if err := store.Delete(ctx, unused); err != nil {
+		return fmt.Errorf("failed to delete unreferenced backup chunks: %w", err)
+	}
+	return nil
+}
+
+// fetchChunkedBackup reassembles the snapshot for req into dir,
+// re-compressing archives that were chunked decompressed.
+func fetchChunkedBackup(ctx context.Context, req BackupRequest, dir string) (string, error) {
+	storage := normalizeBackupStorage(*req.Storage)
+	store, err := newBackupObjectStore(ctx, storage)
+	if err != nil {
+		return "", err
+	}
+	defer store.Close()
+	repo, err := openChunkRepository(ctx, store, storage.EncryptionKey, chunkRepositoryRoot(storage.Prefix, req.Project, req.Environment, req.Volume), false)
+	if err != nil {
+		return "", err
+	}
+	for _, format := range backupArtifactFormats {
+		fileName := backupArtifactFileName(req.Volume, req.BackupID, format.mode)
+		key := chunkSnapshotKey(storage.Prefix, BackupObject{
+			Project:     req.Project,
+			Environment: req.Environment,
+			Volume:      req.Volume,
+			BackupID:    req.BackupID,
+			Path:        fileName,
+		})
+		snapshot, err := repo.getSnapshot(ctx, key)
+		if errors.Is(err, errBackupObjectNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if snapshot.FileName != fileName {
+			return "", fmt.Errorf("backup snapshot %s names unexpected file %q", path.Base(key), snapshot.FileName)
+		}
+		destination := filepath.Join(dir, fileName)
+		if err := writeChunkedSnapshot(ctx, repo, snapshot, destination); err != nil {
+			return "", err
+		}
+		return destination, nil
+	}
+	return "", fmt.Errorf("backup %s not found in object storage", req.BackupID)
+}
+
+func writeChunkedSnapshot(ctx context.Context, repo *chunkRepository, snapshot *chunkSnapshot, destination string) error {
+	partial := destination + ".partial"
+	file, err := os.OpenFile(partial, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create restored backup: %w", err)
+	}
+	failed := true
+	defer func() {
+		_ = file.Close()
+		if failed {
+			_ = os.Remove(partial)
+		}
+	}()
+	var sink io.Writer = file
+	var compressor *gzip.Writer
+	if snapshot.Gzip {
+		compressor = gzip.NewWriter(file)
+		sink = compressor
+	}
+	hash := sha256.New()
+	var size int64
+	for _, id := range snapshot.Chunks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		chunk, err := repo.getChunk(ctx, id)
+		if err != nil {
+			return err
+		}
+		hash.Write(chunk)
+		size += int64(len(chunk))
+		if _, err := sink.Write(chunk); err != nil {
+			return fmt.Errorf("failed to write restored backup: %w", err)
+		}
+	}
+	if size != snapshot.Size || hex.EncodeToString(hash.Sum(nil)) != snapshot.SHA256 {
+		return fmt.Errorf("restored backup does not match its snapshot checksum")
+	}
+	if compressor != nil {
+		if err := compressor.Close(); err != nil {
+			return err
+		}
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(partial, destination); err != nil {
+		return err
+	}
+	failed = false
+	return nil
+}
+
+func isChunkID(id string) bool {
+	if len(id) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// backupChunker cuts a stream at content-defined boundaries with a gear
+// rolling hash, so an insert only changes the chunks around it.
+type backupChunker struct {
+	reader io.Reader
+	buf    []byte
+	start  int
+	end    int
+	eof    bool
+}
+
+func newBackupChunker(reader io.Reader) *backupChunker {
+	return &backupChunker{reader: reader, buf: make([]byte, 2*backupChunkMaxSize)}
+}
+
+// Next returns the next chunk. The slice is only valid until the next call.
+func (c *backupChunker) Next() ([]byte, error) {
+	if c.end-c.start < backupChunkMaxSize && !c.eof {
+		copy(c.buf, c.buf[c.start:c.end])
+		c.end -= c.start
+		c.start = 0
+		for c.end < len(c.buf) && !c.eof {
+			n, err := c.reader.Read(c.buf[c.end:])
+			c.end += n
+			if err == io.EOF {
+				c.eof = true
+			} else if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if c.start == c.end {
+		return nil, io.EOF
+	}
+	size := backupChunkBoundary(c.buf[c.start:c.end])
+	chunk := c.buf[c.start : c.start+size]
+	c.start += size
+	return chunk, nil
+}
+
+func backupChunkBoundary(data []byte) int {
+	if len(data) <= backupChunkMinSize {
+		return len(data)
+	}
+	limit := min(len(data), backupChunkMaxSize)
+	var hash uint64
+	for i := backupChunkMinSize; i < limit; i++ {
+		hash = hash<<1 + backupChunkGear[data[i]]
+		if hash&backupChunkBoundaryMask == 0 {
+			return i + 1
+		}
+	}
+	return limit
+}
+
+type s3BackupObjectStore struct {
+	client *s3.Client
+	bucket string
+}
+
+func newS3BackupObjectStore(ctx context.Context, storage BackupStorageConfig) (backupObjectStore, error) {
+	client, err := backupS3Client(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+	return &s3BackupObjectStore{client: client, bucket: storage.Bucket}, nil
+}
+
+func (s *s3BackupObjectStore) Get(ctx context.Context, key string) ([]byte, error) {
+	response, err := s.client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(key)})
+	if err != nil {
+		var missing *types.NoSuchKey
+		if errors.As(err, &missing) {
+			return nil, errBackupObjectNotFound
+		}
+		return nil, err
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(response.Body, maxBackupStoreObjectBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxBackupStoreObjectBytes {
+		return nil, fmt.Errorf("backup object %s is too large", key)
+	}
+	return data, nil
+}
+
+func (s *s3BackupObjectStore) Put(ctx context.Context, key string, data []byte) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/octet-stream"),
+	})
+	return err
+}
+
+func (s *s3BackupObjectStore) List(ctx context.Context, prefix string) ([]backupStoreObject, error) {
+	var objects []backupStoreObject
+	var continuation *string
+	for {
+		response, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            aws.String(s.bucket),
+			Prefix:            aws.String(prefix),
+			ContinuationToken: continuation,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range response.Contents {
+			if object.Key == nil || object.LastModified == nil {
+				continue
+			}
+			entry := backupStoreObject{Key: *object.Key, LastModified: object.LastModified.UTC()}
+			if object.Size != nil {
+				entry.Size = *object.Size
+			}
+			objects = append(objects, entry)
+		}
+		if response.IsTruncated == nil || !*response.IsTruncated {
+			return objects, nil
+		}
+		continuation = response.NextContinuationToken
+	}
+}
+
+func (s *s3BackupObjectStore) Delete(ctx context.Context, keys []string) error {
+	objects := make([]types.ObjectIdentifier, 0, len(keys))
+	for _, key := range keys {
+		objects = append(objects, types.ObjectIdentifier{Key: aws.String(key)})
+	}
+	return deleteBackupObjectBatch(ctx, s.client, s.bucket, objects)
+}
+
+func (s *s3BackupObjectStore) Close() error { return nil }
@@ -0,0 +1,477 @@
+package takod
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryBackupObject struct {
+	data     []byte
+	modified time.Time
+}
+
+type memoryBackupStore struct {
+	mu      sync.Mutex
+	objects map[string]memoryBackupObject
+}
+
+func (s *memoryBackupStore) Get(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	object, ok := s.objects[key]
+	if !ok {
+		return nil, errBackupObjectNotFound
+	}
+	return append([]byte(nil), object.data...), nil
+}
+
+func (s *memoryBackupStore) Put(_ context.Context, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = memoryBackupObject{data: append([]byte(nil), data...), modified: time.Now().UTC()}
+	return nil
+}
+
+func (s *memoryBackupStore) List(_ context.Context, prefix string) ([]backupStoreObject, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var objects []backupStoreObject
+	for key, object := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, backupStoreObject{Key: key, Size: int64(len(object.data)), LastModified: object.modified})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func (s *memoryBackupStore) Delete(_ context.Context, keys []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		delete(s.objects, key)
+	}
+	return nil
+}
+
+func (s *memoryBackupStore) Close() error { return nil }
+
+func (s *memoryBackupStore) age(match func(string) bool, by time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, object := range s.objects {
+		if match(key) {
+			object.modified = object.modified.Add(-by)
+			s.objects[key] = object
+		}
+	}
+}
+
+// interleavedBackupStore runs before ahead of each Put and List, so a test
+// can slip another operation between the steps of an upload or collection.
+type interleavedBackupStore struct {
+	*memoryBackupStore
+	before func(op string, key string)
+}
+
+func (s *interleavedBackupStore) Put(ctx context.Context, key string, data []byte) error {
+	s.before("put", key)
+	return s.memoryBackupStore.Put(ctx, key, data)
+}
+
+func (s *interleavedBackupStore) List(ctx context.Context, prefix string) ([]backupStoreObject, error) {
+	s.before("list", prefix)
+	return s.memoryBackupStore.List(ctx, prefix)
+}
+
+func useInterleavedBackupStore(t *testing.T, store *memoryBackupStore, before func(op string, key string)) {
+	t.Helper()
+	previous := newBackupObjectStore
+	newBackupObjectStore = func(context.Context, BackupStorageConfig) (backupObjectStore, error) {
+		return &interleavedBackupStore{memoryBackupStore: store, before: before}, nil
+	}
+	t.Cleanup(func() { newBackupObjectStore = previous })
+}
+
+func useMemoryBackupStore(t *testing.T) *memoryBackupStore {
+	t.Helper()
+	store := &memoryBackupStore{objects: make(map[string]memoryBackupObject)}
+	previous := newBackupObjectStore
+	newBackupObjectStore = func(context.Context, BackupStorageConfig) (backupObjectStore, error) {
+		return store, nil
+	}
+	t.Cleanup(func() { newBackupObjectStore = previous })
+	return store
+}
+
+func chunkedTestStorage() BackupStorageConfig {
+	return BackupStorageConfig{
+		Provider:        BackupStorageProviderS3,
+		Bucket:          "backups",
+		Region:          "us-east-1",
+		Prefix:          "apps",
+		AccessKeyID:     "access",
+		SecretAccessKey: "secret",
+		Format:          BackupStorageFormatChunked,
+		EncryptionKey:   "correct horse battery staple",
+	}
+}
+
+func writeTestBackupArchive(t *testing.T, dir string, backupID string, content []byte) BackupObject {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, backupFileName("data", backupID))
+	var archive bytes.Buffer
+	writer := gzip.NewWriter(&archive)
+	if _, err := writer.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, archive.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return BackupObject{Project: "demo", Environment: "production", Volume: "data", BackupID: backupID, Path: path, CreatedAt: time.Now().UTC()}
+}
+
+func readTestBackupArchive(t *testing.T, path string) []byte {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func chunkedTestVolume(size int) []byte {
+	content := make([]byte, size)
+	rand.New(rand.NewSource(42)).Read(content)
+	copy(content, "CLEARTEXT-MARKER")
+	return content
+}
+
+func TestUploadChunkedBackupSendsOnlyChangedChunksEncrypted(t *testing.T) {
+	store := useMemoryBackupStore(t)
+	dir := t.TempDir()
+	content := chunkedTestVolume(8 << 20)
+
+	first, err := UploadBackupObject(context.Background(), chunkedTestStorage(), writeTestBackupArchive(t, dir, "20261016-020000", content))
+	if err != nil {
+		t.Fatalf("first upload returned error: %v", err)
+	}
+	if first.Format != BackupStorageFormatChunked || first.Chunks < 2 || first.NewChunks != first.Chunks {
+		t.Fatalf("first upload = %+v", first)
+	}
+	if first.Key != "apps/demo/production/data/data_20261016-020000.tar.gz.snapshot" {
+		t.Fatalf("snapshot key = %q", first.Key)
+	}
+
+	changed := append([]byte("prepended bytes shift every offset"), content...)
+	copy(changed[len(changed)-10:], "tail-edit!")
+	second, err := UploadBackupObject(context.Background(), chunkedTestStorage(), writeTestBackupArchive(t, dir, "20261017-020000", changed))
+	if err != nil {
+		t.Fatalf("second upload returned error: %v", err)
+	}
+	if second.NewChunks == 0 || second.NewChunks > 2 || second.NewChunks >= second.Chunks {
+		t.Fatalf("second upload sent %d of %d chunks, want only the edited ones", second.NewChunks, second.Chunks)
+	}
+
+	for key, object := range store.objects {
+		if bytes.Contains(object.data, []byte("CLEARTEXT-MARKER")) || bytes.Contains(object.data, content[1<<20:1<<20+64]) {
+			t.Fatalf("object %s holds cleartext volume data", key)
+		}
+	}
+
+	wrongKey := chunkedTestStorage()
+	wrongKey.EncryptionKey = "a different passphrase entirely"
+	if _, err := UploadBackupObject(context.Background(), wrongKey, writeTestBackupArchive(t, dir, "20261018-020000", content)); err == nil || !strings.Contains(err.Error(), "does not match chunk repository") {
+		t.Fatalf("upload with wrong key = %v", err)
+	}
+}
+
+func TestRestoreVolumeBackupFetchesChunkedSnapshotFromStorage(t *testing.T) {
+	t.Cleanup(useTempBackupRoot(t))
+	t.Cleanup(useFakeCommands(t, filepath.Join(t.TempDir(), "commands.log")))
+	useMemoryBackupStore(t)
+	storage := chunkedTestStorage()
+	request := BackupRequest{Project: "demo", Environment: "production", Volume: "data", BackupID: "20261016-020000", Storage: &storage}
+	content := chunkedTestVolume(3 << 20)
+	object := writeTestBackupArchive(t, backupDirectory(request), request.BackupID, content)
+	if _, err := UploadBackupObject(context.Background(), storage, object); err != nil {
+		t.Fatalf("upload returned error: %v", err)
+	}
+	if err := os.Remove(object.Path); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RestoreVolumeBackup(context.Background(), request); err == nil || !strings.Contains(err.Error(), "backup not found") {
+		t.Fatalf("restore without --from-storage = %v", err)
+	}
+	request.FromStorage = true
+	if err := RestoreVolumeBackup(context.Background(), request); err != nil {
+		t.Fatalf("RestoreVolumeBackup returned error: %v", err)
+	}
+	if restored := readTestBackupArchive(t, object.Path); !bytes.Equal(restored, content) {
+		t.Fatalf("restored archive differs from the uploaded volume (%d bytes, want %d)", len(restored), len(content))
+	}
+
+	request.BackupID = "20261017-020000"
+	if err := RestoreVolumeBackup(context.Background(), request); err == nil || !strings.Contains(err.Error(), "not found in object storage") {
+		t.Fatalf("restore of unknown backup = %v", err)
+	}
+}
+
+func TestCleanupChunkedBackupsCollectsOnlyUnreferencedChunks(t *testing.T) {
+	store := useMemoryBackupStore(t)
+	dir := t.TempDir()
+	storage := chunkedTestStorage()
+	old := chunkedTestVolume(4 << 20)
+	current := chunkedTestVolume(4 << 20)
+	rand.New(rand.NewSource(7)).Read(current[2<<20:])
+
+	if _, err := UploadBackupObject(context.Background(), storage, writeTestBackupArchive(t, dir, "20261001-020000", old)); err != nil {
+		t.Fatal(err)
+	}
+	store.age(func(string) bool { return true }, 10*24*time.Hour)
+	if _, err := UploadBackupObject(context.Background(), storage, writeTestBackupArchive(t, dir, "20261016-020000", current)); err != nil {
+		t.Fatal(err)
+	}
+	chunksBefore, _ := store.List(context.Background(), "apps/demo/production/data/.tako-chunks/data/")
+
+	err := CleanupBackupObjects(context.Background(), storage, BackupObjectRetention{Project: "demo", Environment: "production", Volume: "data", RetentionDays: 7})
+	if err != nil {
+		t.Fatalf("CleanupBackupObjects returned error: %v", err)
+	}
+	if _, ok := store.objects["apps/demo/production/data/data_20261001-020000.tar.gz.snapshot"]; ok {
+		t.Fatal("expired snapshot survived cleanup")
+	}
+	if _, ok := store.objects["apps/demo/production/data/.tako-chunks/config"]; !ok {
+		t.Fatal("cleanup deleted the repository config")
+	}
+	chunksAfter, _ := store.List(context.Background(), "apps/demo/production/data/.tako-chunks/data/")
+	if len(chunksAfter) == 0 || len(chunksAfter) >= len(chunksBefore) {
+		t.Fatalf("chunks before=%d after=%d, want only the expired snapshot's unique chunks collected", len(chunksBefore), len(chunksAfter))
+	}
+
+	request := BackupRequest{Project: "demo", Environment: "production", Volume: "data", BackupID: "20261016-020000", Storage: &storage}
+	t.Cleanup(useTempBackupRoot(t))
+	if _, err := fetchBackupFromStorage(context.Background(), request); err != nil {
+		t.Fatalf("surviving snapshot is no longer restorable: %v", err)
+	}
+}
+
+func TestUploadChunkedBackupResendsOrphanedChunksBeforeConcurrentCollection(t *testing.T) {
+	store := useMemoryBackupStore(t)
+	dir := t.TempDir()
+	storage := chunkedTestStorage()
+	content := chunkedTestVolume(3 << 20)
+
+	// An upload that never wrote its snapshot leaves chunks no snapshot
+	// references, and they age past the grace period.
+	first, err := UploadBackupObject(context.Background(), storage, writeTestBackupArchive(t, dir, "20261001-020000", content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(store.objects, first.Key)
+	store.age(func(string) bool { return true }, 10*24*time.Hour)
+
+	second, err := UploadBackupObject(context.Background(), storage, writeTestBackupArchive(t, dir, "20261016-020000", content))
+	if err != nil {
+		t.Fatalf("upload returned error: %v", err)
+	}
+	if second.NewChunks != second.Chunks {
+		t.Fatalf("upload sent %d of %d chunks, want orphaned chunks sent again", second.NewChunks, second.Chunks)
+	}
+
+	// A collection that listed snapshots before this upload committed sees
+	// none of its chunks referenced.
+	if err := collectChunkGarbage(context.Background(), store, storage.EncryptionKey, "apps/demo/production/data/.tako-chunks/", nil); err != nil {
+		t.Fatalf("collectChunkGarbage returned error: %v", err)
+	}
+	request := BackupRequest{Project: "demo", Environment: "production", Volume: "data", BackupID: "20261016-020000", Storage: &storage}
+	t.Cleanup(useTempBackupRoot(t))
+	if _, err := fetchBackupFromStorage(context.Background(), request); err != nil {
+		t.Fatalf("snapshot lost chunks to a concurrent collection: %v", err)
+	}
+
+	third, err := UploadBackupObject(context.Background(), storage, writeTestBackupArchive(t, dir, "20261017-020000", content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.NewChunks != 0 {
+		t.Fatalf("upload sent %d chunks a stored snapshot already references", third.NewChunks)
+	}
+}
+
+func TestUploadChunkedBackupResendsReusedChunksCollectedBeforeItCommits(t *testing.T) {
+	store := useMemoryBackupStore(t)
+	dir := t.TempDir()
+	storage := chunkedTestStorage()
+	content := chunkedTestVolume(3 << 20)
+	if _, err := UploadBackupObject(context.Background(), storage, writeTestBackupArchive(t, dir, "20261001-020000", content)); err != nil {
+		t.Fatal(err)
+	}
+	store.age(func(string) bool { return true }, 10*24*time.Hour)
+
+	// The upload reuses every chunk of the old snapshot, and retention
+	// expires that snapshot and collects its chunks before the upload
+	// commits its own.
+	next := writeTestBackupArchive(t, dir, "20261016-020000", content)
+	collecting := false
+	useInterleavedBackupStore(t, store, func(op string, key string) {
+		if op != "put" || key != chunkSnapshotKey(storage.Prefix, next) || collecting {
+			return
+		}
+		collecting = true
+		if err := CleanupBackupObjects(context.Background(), storage, BackupObjectRetention{Project: "demo", Environment: "production", Volume: "data", RetentionDays: 7}); err != nil {
+			t.Fatalf("CleanupBackupObjects returned error: %v", err)
+		}
+		if chunks, _ := store.List(context.Background(), "apps/demo/production/data/.tako-chunks/data/"); len(chunks) != 0 {
+			t.Fatalf("collection kept %d chunks, want the expired snapshot's chunks collected", len(chunks))
+		}
+	})
+	info, err := UploadBackupObject(context.Background(), storage, next)
+	if err != nil {
+		t.Fatalf("upload returned error: %v", err)
+	}
+	if !collecting || info.NewChunks != info.Chunks {
+		t.Fatalf("upload sent %d of %d chunks after collection ran=%v, want the collected chunks sent again", info.NewChunks, info.Chunks, collecting)
+	}
+	request := BackupRequest{Project: "demo", Environment: "production", Volume: "data", BackupID: "20261016-020000", Storage: &storage}
+	t.Cleanup(useTempBackupRoot(t))
+	if _, err := fetchBackupFromStorage(context.Background(), request); err != nil {
+		t.Fatalf("snapshot lost chunks to a concurrent collection: %v", err)
+	}
+}
+
+func TestCollectChunkGarbageRereadsSnapshotsCommittedDuringCollection(t *testing.T) {
+	store := useMemoryBackupStore(t)
+	dir := t.TempDir()
+	storage := chunkedTestStorage()
+	content := chunkedTestVolume(3 << 20)
+	if _, err := UploadBackupObject(context.Background(), storage, writeTestBackupArchive(t, dir, "20261001-020000", content)); err != nil {
+		t.Fatal(err)
+	}
+	store.age(func(string) bool { return true }, 10*24*time.Hour)
+	info, err := UploadBackupObject(context.Background(), storage, writeTestBackupArchive(t, dir, "20261016-020000", content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.NewChunks != 0 {
+		t.Fatalf("upload sent %d chunks, want every chunk reused", info.NewChunks)
+	}
+
+	// The new snapshot lands after the collection read the surviving
+	// snapshots but before it deletes anything.
+	pending := store.objects[info.Key]
+	delete(store.objects, info.Key)
+	useInterleavedBackupStore(t, store, func(op string, key string) {
+		if op == "list" && strings.HasSuffix(key, "/.tako-chunks/data/") && pending.data != nil {
+			store.mu.Lock()
+			store.objects[info.Key] = pending
+			store.mu.Unlock()
+			pending = memoryBackupObject{}
+		}
+	})
+	if err := CleanupBackupObjects(context.Background(), storage, BackupObjectRetention{Project: "demo", Environment: "production", Volume: "data", RetentionDays: 7}); err != nil {
+		t.Fatalf("CleanupBackupObjects returned error: %v", err)
+	}
+	if chunks, _ := store.List(context.Background(), "apps/demo/production/data/.tako-chunks/data/"); len(chunks) != info.Chunks {
+		t.Fatalf("collection left %d of %d chunks the new snapshot references", len(chunks), info.Chunks)
+	}
+	request := BackupRequest{Project: "demo", Environment: "production", Volume: "data", BackupID: "20261016-020000", Storage: &storage}
+	t.Cleanup(useTempBackupRoot(t))
+	if _, err := fetchBackupFromStorage(context.Background(), request); err != nil {
+		t.Fatalf("snapshot committed during collection is not restorable: %v", err)
+	}
+}
+
+func TestBackupChunkerBoundariesSurviveAnInsert(t *testing.T) {
+	content := chunkedTestVolume(12 << 20)
+	chunks := func(data []byte) []string {
+		t.Helper()
+		chunker := newBackupChunker(bytes.NewReader(data))
+		var sums []string
+		for {
+			chunk, err := chunker.Next()
+			if err == io.EOF {
+				return sums
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(chunk) > backupChunkMaxSize {
+				t.Fatalf("chunk of %d bytes exceeds the maximum", len(chunk))
+			}
+			sum := sha256.Sum256(chunk)
+			sums = append(sums, hex.EncodeToString(sum[:]))
+		}
+	}
+
+	before := chunks(content)
+	if again := chunks(content); strings.Join(again, ",") != strings.Join(before, ",") {
+		t.Fatal("chunking the same content twice cut different boundaries")
+	}
+	if len(before) < 4 {
+		t.Fatalf("12 MiB cut into %d chunks, want content-defined boundaries", len(before))
+	}
+	edited := append(append(append([]byte(nil), content[:6<<20]...), "inserted bytes"...), content[6<<20:]...)
+	after := chunks(edited)
+	unchanged := make(map[string]bool, len(before))
+	for _, sum := range before {
+		unchanged[sum] = true
+	}
+	changed := 0
+	for _, sum := range after {
+		if !unchanged[sum] {
+			changed++
+		}
+	}
+	if changed == 0 || changed > 2 {
+		t.Fatalf("an insert changed %d of %d chunks, want only the chunks around it", changed, len(after))
+	}
+	if after[0] != before[0] || after[len(after)-1] != before[len(before)-1] {
+		t.Fatal("an insert in the middle moved the first or last chunk boundary")
+	}
+}
+
+func TestValidateBackupStorageRequiresEncryptionKeyForChunks(t *testing.T) {
+	storage := chunkedTestStorage()
+	storage.EncryptionKey = "short"
+	if err := ValidateBackupStorage(storage); err == nil || !strings.Contains(err.Error(), "at least 16 characters") {
+		t.Fatalf("short key = %v", err)
+	}
+	storage = chunkedTestStorage()
+	storage.Format = "archive"
+	if err := ValidateBackupStorage(storage); err == nil || !strings.Contains(err.Error(), "requires the chunked format") {
+		t.Fatalf("key on archive storage = %v", err)
+	}
+	storage.Format = "tarball"
+	if err := ValidateBackupStorage(storage); err == nil || !strings.Contains(err.Error(), "format must be archive or chunked") {
+		t.Fatalf("unknown format = %v", err)
+	}
+}
@@ -0,0 +1,379 @@
+package takod
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Backup modes. A volume backup tars the Docker volume from a helper
+// container; the database modes run the engine's own dump tool inside the
+// service container so the artifact is consistent while the database keeps
+// writing. Hook backups tar the volume between the service's preBackup and
+// postBackup commands. pg_basebackup copies the whole Postgres cluster and is
+// the base that point-in-time recovery replays archived WAL onto.
+const (
+	BackupModeVolume       = "volume"
+	BackupModePgDump       = "pg_dump"
+	BackupModeMySQLDump    = "mysqldump"
+	BackupModeRedisBGSave  = "redis-bgsave"
+	BackupModeHook         = "hook"
+	BackupModePgBaseBackup = "pg_basebackup"
+
+	maxBackupHookBytes = 4096
+)
+
+var backupDatabasePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_$-]{0,63}$`)
+
+// backupArtifactFormat names the on-disk artifact each mode produces. The
+// suffix is the only record of the format, so listing, retention, restore,
+// and object storage keys all derive the mode from the file name.
+type backupArtifactFormat struct {
+	mode        string
+	suffix      string
+	compression string
+}
+
+var backupArtifactFormats = []backupArtifactFormat{
+	{mode: BackupModeVolume, suffix: ".tar.gz", compression: "gzip"},
+	{mode: BackupModePgDump, suffix: ".pgdump", compression: "zlib"},
+	{mode: BackupModeMySQLDump, suffix: ".sql.gz", compression: "gzip"},
+	{mode: BackupModeRedisBGSave, suffix: ".rdb", compression: "lzf"},
+	{mode: BackupModePgBaseBackup, suffix: ".pgbase.tgz", compression: "gzip"},
+}
+
+func normalizeBackupMode(mode string) string {
+	mode = strings.TrimSpace(mode)
+	if mode == "" {
+		return BackupModeVolume
+	}
+	return mode
+}
+
+func isDatabaseDumpMode(mode string) bool {
+	switch mode {
+	case BackupModePgDump, BackupModeMySQLDump, BackupModeRedisBGSave, BackupModePgBaseBackup:
+		return true
+	}
+	return false
+}
+
+func validateBackupMode(req BackupRequest) error {
+	mode := normalizeBackupMode(req.Mode)
+	switch mode {
+	case BackupModeVolume, BackupModePgDump, BackupModeMySQLDump, BackupModeRedisBGSave, BackupModeHook, BackupModePgBaseBackup:
+	default:
+		return fmt.Errorf("unsupported backup mode %q", req.Mode)
+	}
+	if req.Database != "" {
+		if mode != BackupModePgDump && mode != BackupModeMySQLDump {
+			return fmt.Errorf("database requires backup mode pg_dump or mysqldump")
+		}
+		if !backupDatabasePattern.MatchString(req.Database) {
+			return fmt.Errorf("invalid backup database name")
+		}
+	}
+	if len(req.PreBackup) > maxBackupHookBytes || len(req.PostBackup) > maxBackupHookBytes {
+		return fmt.Errorf("backup hooks must be %d bytes or less", maxBackupHookBytes)
+	}
+	if mode == BackupModeHook && strings.TrimSpace(req.PreBackup) == "" && strings.TrimSpace(req.PostBackup) == "" {
+		return fmt.Errorf("backup mode hook requires preBackup or postBackup")
+	}
+	if backupNeedsServiceContainer(req) && req.Service == "" {
+		return fmt.Errorf("service is required for backup mode %s", mode)
+	}
+	if req.PITR {
+		if mode != BackupModePgBaseBackup {
+			return fmt.Errorf("pitr requires backup mode pg_basebackup")
+		}
+		if req.Storage == nil {
+			return fmt.Errorf("pitr requires backup storage to archive WAL into")
+		}
+	}
+	return nil
+}
+
+func backupNeedsServiceContainer(req BackupRequest) bool {
+	return isDatabaseDumpMode(normalizeBackupMode(req.Mode)) || strings.TrimSpace(req.PreBackup) != "" || strings.TrimSpace(req.PostBackup) != ""
+}
+
+func backupArtifactFormatForMode(mode string) backupArtifactFormat {
+	for _, format := range backupArtifactFormats {
+		if format.mode == mode {
+			return format
+		}
+	}
+	return backupArtifactFormats[0]
+}
+
+func backupArtifactFileName(volume string, backupID string, mode string) string {
+	return volume + "_" + backupID + backupArtifactFormatForMode(mode).suffix
+}
+
+func backupArtifactFormatForFile(filename string) (backupArtifactFormat, bool) {
+	for _, format := range backupArtifactFormats {
+		if strings.HasSuffix(filename, format.suffix) {
+			return format, true
+		}
+	}
+	return backupArtifactFormat{}, false
+}
+
+// findBackupArtifact locates a backup by volume and ID whatever mode wrote it.
+func findBackupArtifact(dir string, volume string, backupID string) (string, backupArtifactFormat, error) {
+	for _, format := range backupArtifactFormats {
+		path := filepath.Join(dir, volume+"_"+backupID+format.suffix)
+		if _, err := os.Stat(path); err == nil {
+			return path, format, nil
+		} else if !os.IsNotExist(err) {
+			return "", backupArtifactFormat{}, fmt.Errorf("failed to inspect backup: %w", err)
+		}
+	}
+	return "", backupArtifactFormat{}, fmt.Errorf("backup not found: %s", filepath.Join(dir, backupFileName(volume, backupID)))
+}
+
+func resolveBackupContainer(ctx context.Context, req BackupRequest) (string, error) {
+	return resolveExecContainer(ctx, ExecRequest{Project: req.Project, Environment: req.Environment, Service: req.Service})
+}
+
+// runBackupHooks runs preBackup, then the backup, then postBackup. postBackup
+// runs whenever preBackup was attempted so a failed backup still thaws a
+// frozen filesystem; its own failure is reported as a warning because the
+// artifact is already complete.
+func runBackupHooks(ctx context.Context, req BackupRequest, container string, backup func() error) ([]string, error) {
+	pre := strings.TrimSpace(req.PreBackup)
+	post := strings.TrimSpace(req.PostBackup)
+	var backupErr error
+	if pre != "" {
+		if output, err := runDocker(ctx, "exec", container, "sh", "-c", pre); err != nil {
+			backupErr = fmt.Errorf("preBackup hook failed: %w, output: %s", err, strings.TrimSpace(output))
+		}
+	}
+	if backupErr == nil {
+		backupErr = backup()
+	}
+	var warnings []string
+	if post != "" {
+		if output, err := runDocker(ctx, "exec", container, "sh", "-c", post); err != nil {
+			if backupErr != nil {
+				return nil, fmt.Errorf("%w; postBackup hook failed: %v, output: %s", backupErr, err, strings.TrimSpace(output))
+			}
+			warnings = append(warnings, fmt.Sprintf("postBackup hook failed: %v, output: %s", err, strings.TrimSpace(output)))
+		}
+	}
+	if backupErr != nil {
+		return nil, backupErr
+	}
+	return warnings, nil
+}
+
+// writeDatabaseDump streams the engine's dump from the service container into
+// path. The artifact appears only once the dump exits cleanly.
+func writeDatabaseDump(ctx context.Context, req BackupRequest, mode string, container string, path string) error {
+	partial := path + ".partial"
+	file, err := os.OpenFile(partial, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer os.Remove(partial)
+
+	var script string
+	var output io.Writer = file
+	var compressor *gzip.Writer
+	switch mode {
+	case BackupModePgDump:
+		script = pgDumpScript(req.Database)
+	case BackupModeMySQLDump:
+		script = mysqlDumpScript(req.Database)
+		compressor = gzip.NewWriter(file)
+		output = compressor
+	case BackupModeRedisBGSave:
+		script = redisDumpScript()
+	case BackupModePgBaseBackup:
+		script = pgBaseBackupScript()
+		compressor = gzip.NewWriter(file)
+		output = compressor
+	default:
+		file.Close()
+		return fmt.Errorf("unsupported database dump mode %q", mode)
+	}
+	if err := runDockerStream(ctx, nil, output, "exec", container, "sh", "-c", script); err != nil {
+		file.Close()
+		return fmt.Errorf("%s failed in %s: %w", mode, container, err)
+	}
+	if compressor != nil {
+		if err := compressor.Close(); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to compress backup: %w", err)
+		}
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync backup: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close backup: %w", err)
+	}
+	if err := os.Rename(partial, path); err != nil {
+		return fmt.Errorf("failed to finalize backup: %w", err)
+	}
+	return nil
+}
+
+// restoreDatabaseDump feeds a dump back through the engine's client inside the
+// running service container.
+func restoreDatabaseDump(ctx context.Context, req BackupRequest, mode string, container string, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer file.Close()
+
+	var input io.Reader = file
+	var script string
+	switch mode {
+	case BackupModePgDump:
+		script = pgRestoreScript(req.Database)
+	case BackupModeMySQLDump:
+		reader, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("failed to read backup: %w", err)
+		}
+		defer reader.Close()
+		input = reader
+		script = mysqlRestoreScript()
+	case BackupModeRedisBGSave:
+		script = redisRestoreScript()
+	default:
+		return fmt.Errorf("unsupported database dump mode %q", mode)
+	}
+	if err := runDockerStream(ctx, input, io.Discard, "exec", "-i", container, "sh", "-c", script); err != nil {
+		return fmt.Errorf("failed to restore %s backup in %s: %w", mode, container, err)
+	}
+	if mode == BackupModeRedisBGSave {
+		// Redis only reads its RDB file at startup. The restore script turned
+		// off save points so the shutdown cannot overwrite the restored file.
+		if output, err := runDocker(ctx, "restart", container); err != nil {
+			return fmt.Errorf("failed to restart %s after restore: %w, output: %s", container, err, strings.TrimSpace(output))
+		}
+	}
+	return nil
+}
+
+func runDockerStream(ctx context.Context, stdin io.Reader, stdout io.Writer, args ...string) error {
+	cmd := dockerCommandContext(ctx, "docker", args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	stderr := newCappedOutputBuffer(defaultCommandOutputMaxBytes)
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return fmt.Errorf("%w, output: %s", err, message)
+		}
+		return err
+	}
+	return nil
+}
+
+// The dump scripts read credentials from the official images' environment so
+// a service that boots from POSTGRES_PASSWORD or MYSQL_ROOT_PASSWORD needs no
+// extra backup configuration.
+
+func pgDumpScript(database string) string {
+	return pgScriptPrelude(database) + `exec pg_dump --format=custom --username="$user" --dbname="$database"
+`
+}
+
+func pgRestoreScript(database string) string {
+	return pgScriptPrelude(database) + `exec pg_restore --clean --if-exists --single-transaction --username="$user" --dbname="$database"
+`
+}
+
+// pgBaseBackupScript streams the cluster as one tar. WAL needed to make the
+// copy consistent is fetched into it, so the base boots on its own.
+func pgBaseBackupScript() string {
+	return pgScriptPrelude("") + `exec pg_basebackup --username="$user" --pgdata=- --format=tar --wal-method=fetch --checkpoint=fast
+`
+}
+
+func pgScriptPrelude(database string) string {
+	databaseValue := `"${POSTGRES_DB:-$user}"`
+	if database != "" {
+		databaseValue = shellQuote(database)
+	}
+	return `set -eu
+if [ -z "${PGPASSWORD:-}" ] && [ -n "${POSTGRES_PASSWORD:-}" ]; then
+  export PGPASSWORD="$POSTGRES_PASSWORD"
+fi
+user="${PGUSER:-${POSTGRES_USER:-postgres}}"
+database=` + databaseValue + `
+`
+}
+
+func mysqlDumpScript(database string) string {
+	selection := "--all-databases"
+	if database != "" {
+		selection = "--databases " + shellQuote(database)
+	}
+	return mysqlScriptPrelude("mysqldump", "mariadb-dump") + `exec "$client" --user=root --single-transaction --quick --routines --triggers --events ` + selection + `
+`
+}
+
+func mysqlRestoreScript() string {
+	return mysqlScriptPrelude("mysql", "mariadb") + `exec "$client" --user=root
+`
+}
+
+func mysqlScriptPrelude(client string, fallback string) string {
+	return `set -eu
+if [ -z "${MYSQL_PWD:-}" ]; then
+  MYSQL_PWD="${MYSQL_ROOT_PASSWORD:-${MARIADB_ROOT_PASSWORD:-}}"
+  export MYSQL_PWD
+fi
+client=` + client + `
+if ! command -v "$client" >/dev/null 2>&1; then
+  client=` + fallback + `
+fi
+`
+}
+
+const redisScriptPrelude = `set -eu
+if [ -z "${REDISCLI_AUTH:-}" ] && [ -n "${REDIS_PASSWORD:-}" ]; then
+  export REDISCLI_AUTH="$REDIS_PASSWORD"
+fi
+dir=$(redis-cli CONFIG GET dir | sed -n 2p)
+file=$(redis-cli CONFIG GET dbfilename | sed -n 2p)
+`
+
+func redisDumpScript() string {
+	return redisScriptPrelude + `case "$(redis-cli BGSAVE)" in
+  *started*) ;;
+  *) echo "redis BGSAVE was not started" >&2; exit 1 ;;
+esac
+while redis-cli INFO persistence | grep -q '^rdb_bgsave_in_progress:1'; do
+  sleep 1
+done
+if ! redis-cli INFO persistence | grep -q '^rdb_last_bgsave_status:ok'; then
+  echo "redis BGSAVE failed" >&2
+  exit 1
+fi
+exec cat "$dir/$file"
+`
+}
+
+func redisRestoreScript() string {
+	return redisScriptPrelude + `if redis-cli CONFIG GET appendonly | sed -n 2p | grep -q '^yes'; then
+  echo "redis appendonly is enabled; an RDB snapshot would be ignored at startup" >&2
+  exit 1
+fi
+redis-cli CONFIG SET save "" >/dev/null
+cat > "$dir/.tako-restore.rdb"
+chown "$(stat -c %u:%g "$dir")" "$dir/.tako-restore.rdb" 2>/dev/null || true
+mv "$dir/.tako-restore.rdb" "$dir/$file"
+`
+}
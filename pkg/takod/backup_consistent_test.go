@@ -0,0 +1,212 @@
+package takod
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func useFakeBackupContainer(t *testing.T) string {
+	t.Helper()
+	t.Cleanup(useTempBackupRoot(t))
+	logPath := filepath.Join(t.TempDir(), "commands.log")
+	t.Cleanup(useFakeCommands(t, logPath))
+	t.Setenv("TAKO_FAKE_PS_OUTPUT", "demo_production_postgres_2\ndemo_production_postgres_1\n")
+	return logPath
+}
+
+func commandIndex(t *testing.T, commands []string, prefix string) int {
+	t.Helper()
+	for index, command := range commands {
+		if strings.HasPrefix(command, prefix) {
+			return index
+		}
+	}
+	t.Fatalf("no command starting with %q in:\n%s", prefix, strings.Join(commands, "\n"))
+	return -1
+}
+
+func TestCreateVolumeBackupRunsPgDumpBetweenHooks(t *testing.T) {
+	logPath := useFakeBackupContainer(t)
+	t.Setenv("TAKO_FAKE_DOCKER_EXEC_OUTPUT", "PGDMP-custom")
+
+	info, err := CreateVolumeBackup(context.Background(), BackupRequest{
+		Project:     "demo",
+		Environment: "production",
+		Volume:      "pgdata",
+		BackupID:    "20261016-020000",
+		Service:     "postgres",
+		Mode:        BackupModePgDump,
+		Database:    "app",
+		PreBackup:   "psql -c CHECKPOINT",
+		PostBackup:  "echo done",
+	})
+	if err != nil {
+		t.Fatalf("CreateVolumeBackup returned error: %v", err)
+	}
+	if info.Mode != BackupModePgDump || !strings.HasSuffix(info.Path, "pgdata_20261016-020000.pgdump") {
+		t.Fatalf("info = %+v", info)
+	}
+	data, err := os.ReadFile(info.Path)
+	if err != nil || !strings.HasPrefix(string(data), "PGDMP-custom") {
+		t.Fatalf("dump artifact = %q, %v", data, err)
+	}
+
+	commands := readCommandLog(t, logPath)
+	pre := commandIndex(t, commands, "docker exec demo_production_postgres_1 sh -c psql -c CHECKPOINT")
+	dump := commandIndex(t, commands, "docker exec demo_production_postgres_1 sh -c set -eu")
+	post := commandIndex(t, commands, "docker exec demo_production_postgres_1 sh -c echo done")
+	if !(pre < dump && dump < post) {
+		t.Fatalf("hooks out of order (pre=%d dump=%d post=%d):\n%s", pre, dump, post, strings.Join(commands, "\n"))
+	}
+	// Scripts span several log lines; the lines after the exec hold the body.
+	script := strings.Join(commands[dump:post], "\n")
+	if !strings.Contains(script, "database='app'") || !strings.Contains(script, "pg_dump --format=custom") {
+		t.Fatalf("dump script:\n%s", script)
+	}
+	for _, command := range commands {
+		if strings.HasPrefix(command, "docker run") {
+			t.Fatalf("database dump also tarred the volume: %q", command)
+		}
+	}
+}
+
+func TestCreateVolumeBackupCompressesMySQLDump(t *testing.T) {
+	useFakeBackupContainer(t)
+	t.Setenv("TAKO_FAKE_DOCKER_EXEC_OUTPUT", "CREATE TABLE t (id int);")
+
+	info, err := CreateVolumeBackup(context.Background(), BackupRequest{
+		Project: "demo", Environment: "production", Volume: "mysql", BackupID: "20261016-020000",
+		Service: "postgres", Mode: BackupModeMySQLDump,
+	})
+	if err != nil {
+		t.Fatalf("CreateVolumeBackup returned error: %v", err)
+	}
+	if info.Compression != "gzip" || !strings.HasSuffix(info.Path, ".sql.gz") {
+		t.Fatalf("info = %+v", info)
+	}
+	file, err := os.Open(info.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil || !strings.HasPrefix(string(data), "CREATE TABLE") {
+		t.Fatalf("decompressed dump = %q, %v", data, err)
+	}
+}
+
+func TestCreateVolumeBackupSkipsArchiveWhenPreBackupFails(t *testing.T) {
+	logPath := useFakeBackupContainer(t)
+	t.Setenv("TAKO_FAKE_DOCKER_EXEC_ERROR", "freeze failed")
+
+	_, err := CreateVolumeBackup(context.Background(), BackupRequest{
+		Project: "demo", Environment: "production", Volume: "data", BackupID: "20261016-020000",
+		Service: "postgres", Mode: BackupModeHook, PreBackup: "freeze", PostBackup: "thaw",
+	})
+	if err == nil || !strings.Contains(err.Error(), "preBackup hook failed") || !strings.Contains(err.Error(), "postBackup hook failed") {
+		t.Fatalf("error = %v, want both hook failures", err)
+	}
+	commands := readCommandLog(t, logPath)
+	commandIndex(t, commands, "docker exec demo_production_postgres_1 sh -c thaw")
+	for _, command := range commands {
+		if strings.HasPrefix(command, "docker run") {
+			t.Fatalf("volume archived after preBackup failed: %q", command)
+		}
+	}
+	response, err := ListVolumeBackups(context.Background(), BackupRequest{Project: "demo", Environment: "production"})
+	if err != nil || len(response.Backups) != 0 {
+		t.Fatalf("backups after failed hook = %+v, %v", response, err)
+	}
+}
+
+func TestRestoreVolumeBackupReplaysDumpInServiceContainer(t *testing.T) {
+	logPath := useFakeBackupContainer(t)
+	request := BackupRequest{Project: "demo", Environment: "production", Volume: "pgdata", BackupID: "20261016-020000"}
+	dir := backupDirectory(request)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, backupArtifactFileName(request.Volume, request.BackupID, BackupModePgDump)), []byte("PGDMP"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RestoreVolumeBackup(context.Background(), request); err == nil || !strings.Contains(err.Error(), "service is required") {
+		t.Fatalf("restore without service = %v", err)
+	}
+	request.Service = "postgres"
+	if err := RestoreVolumeBackup(context.Background(), request); err != nil {
+		t.Fatalf("RestoreVolumeBackup returned error: %v", err)
+	}
+	commands := readCommandLog(t, logPath)
+	restore := commandIndex(t, commands, "docker exec -i demo_production_postgres_1 sh -c")
+	if script := strings.Join(commands[restore:], "\n"); !strings.Contains(script, "pg_restore --clean --if-exists") {
+		t.Fatalf("restore script:\n%s", script)
+	}
+}
+
+func TestBackupArtifactsKeepTheirFormatAcrossListingAndStorage(t *testing.T) {
+	t.Cleanup(useTempBackupRoot(t))
+	request := BackupRequest{Project: "demo", Environment: "production"}
+	dir := backupDirectory(request)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"cache_20261016-020000.rdb", "pgdata_20261016-020000.pgdump", "pgdata_20261016-030000.pgdump.partial"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	response, err := ListVolumeBackups(context.Background(), request)
+	if err != nil {
+		t.Fatalf("ListVolumeBackups returned error: %v", err)
+	}
+	if len(response.Backups) != 2 || response.Backups[0].Mode != BackupModeRedisBGSave || response.Backups[1].Mode != BackupModePgDump {
+		t.Fatalf("backups = %+v", response.Backups)
+	}
+
+	key := backupObjectKey("apps", BackupObject{Project: "demo", Environment: "production", Volume: "pgdata", BackupID: "20261016-020000", Path: response.Backups[1].Path})
+	if key != "apps/demo/production/pgdata/pgdata_20261016-020000.pgdump" {
+		t.Fatalf("object key = %q", key)
+	}
+
+	if err := DeleteVolumeBackup(context.Background(), BackupRequest{Project: "demo", Environment: "production", Volume: "pgdata", BackupID: "20261016-020000"}); err != nil {
+		t.Fatalf("DeleteVolumeBackup returned error: %v", err)
+	}
+	if _, err := os.Stat(response.Backups[1].Path); !os.IsNotExist(err) {
+		t.Fatalf("dump survived delete: %v", err)
+	}
+}
+
+func TestValidateBackupRequestRejectsInvalidModes(t *testing.T) {
+	valid := BackupRequest{Project: "demo", Environment: "production", Volume: "pgdata", Service: "postgres"}
+	cases := []struct {
+		name    string
+		mutate  func(*BackupRequest)
+		wantErr string
+	}{
+		{"unknown mode", func(r *BackupRequest) { r.Mode = "snapshot" }, "unsupported backup mode"},
+		{"dump without service", func(r *BackupRequest) { r.Mode = BackupModePgDump; r.Service = "" }, "service is required"},
+		{"hook without commands", func(r *BackupRequest) { r.Mode = BackupModeHook }, "requires preBackup or postBackup"},
+		{"database injection", func(r *BackupRequest) { r.Mode = BackupModeMySQLDump; r.Database = "app'; rm -rf /" }, "invalid backup database"},
+		{"unsafe service", func(r *BackupRequest) { r.Service = "../postgres"; r.PreBackup = "true" }, "invalid service name"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := valid
+			tc.mutate(&request)
+			err := validateBackupRequest(request, true, false)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("error = %v, want substring %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,479 @@
+package takod
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/nodeidentity"
+)
+
+// Peer backup storage replicates objects to another enrolled node over the
+// WireGuard mesh. Every request is signed with the sender's allocation key and
+// names the receiving node, and the receiver only accepts senders from its
+// cluster inventory calling from their own mesh address. Each sender's
+// replicas live in their own directory on the receiver.
+const (
+	backupPeerPort            = 7947
+	backupPeerSignatureWindow = 5 * time.Minute
+	backupPeerRetryInterval   = 30 * time.Second
+	backupPeerDir             = "peers"
+	backupPeerSignatureDomain = "tako-backup-peer-v1"
+
+	backupPeerObjectPath  = "/v1/backup-peer/object"
+	backupPeerObjectsPath = "/v1/backup-peer/objects"
+	backupPeerDeletePath  = "/v1/backup-peer/delete"
+
+	backupPeerNodeHeader      = "X-Tako-Peer-Node"
+	backupPeerTimestampHeader = "X-Tako-Peer-Timestamp"
+	backupPeerSHA256Header    = "X-Tako-Peer-Content-Sha256"
+	backupPeerSignatureHeader = "X-Tako-Peer-Signature"
+	backupPeerMaxDeleteKeys   = 1000
+)
+
+var (
+	backupPeerURL = func(meshIP string) string {
+		return "http://" + net.JoinHostPort(meshIP, strconv.Itoa(backupPeerPort))
+	}
+	backupPeerHTTPClient = &http.Client{}
+)
+
+// activeBackupPeers is this node's enrolled identity while the server runs.
+// Peer storage signs with it and resolves peers in the inventory it names.
+var activeBackupPeers struct {
+	sync.RWMutex
+	installation  *nodeidentity.Installation
+	inventoryPath string
+}
+
+func activateBackupPeers(installation *nodeidentity.Installation, inventoryPath string) func() {
+	activeBackupPeers.Lock()
+	activeBackupPeers.installation = installation
+	activeBackupPeers.inventoryPath = inventoryPath
+	activeBackupPeers.Unlock()
+	return func() {
+		activeBackupPeers.Lock()
+		if activeBackupPeers.installation == installation {
+			activeBackupPeers.installation = nil
+			activeBackupPeers.inventoryPath = ""
+		}
+		activeBackupPeers.Unlock()
+	}
+}
+
+func readBackupPeerInventory(installation *nodeidentity.Installation, inventoryPath string) (*nodeidentity.ClusterInventory, error) {
+	inventory, err := nodeidentity.ReadInventory(inventoryPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cluster inventory for peer backups: %w", err)
+	}
+	if inventory.ClusterID != installation.ClusterID {
+		return nil, fmt.Errorf("cluster inventory does not belong to this node's cluster")
+	}
+	return inventory, nil
+}
+
+// findBackupPeer matches a node by name or ID, skipping removed nodes.
+func findBackupPeer(inventory *nodeidentity.ClusterInventory, node string) (nodeidentity.InventoryNode, bool) {
+	for _, candidate := range inventory.Nodes {
+		if (candidate.NodeName == node || candidate.NodeID == node) && !inventory.IsTombstoned(candidate.NodeID) {
+			return candidate, true
+		}
+	}
+	return nodeidentity.InventoryNode{}, false
+}
+
+func backupPeerMessage(clusterID, sender, receiver, method, route, key string, timestamp int64, contentSHA256 string) []byte {
+	return []byte(strings.Join([]string{
+		backupPeerSignatureDomain, clusterID, sender, receiver, method, route, key,
+		strconv.FormatInt(timestamp, 10), contentSHA256,
+	}, "\n"))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+type peerBackupObjectStore struct {
+	installation *nodeidentity.Installation
+	peer         nodeidentity.InventoryNode
+	baseURL      string
+}
+
+func newPeerBackupObjectStore(node string) (*peerBackupObjectStore, error) {
+	activeBackupPeers.RLock()
+	installation, inventoryPath := activeBackupPeers.installation, activeBackupPeers.inventoryPath
+	activeBackupPeers.RUnlock()
+	if installation == nil {
+		return nil, fmt.Errorf("peer backup storage requires an enrolled node")
+	}
+	inventory, err := readBackupPeerInventory(installation, inventoryPath)
+	if err != nil {
+		return nil, err
+	}
+	peer, ok := findBackupPeer(inventory, node)
+	if !ok {
+		return nil, fmt.Errorf("backup peer %s is not an active cluster node", node)
+	}
+	if peer.NodeID == installation.NodeID {
+		return nil, fmt.Errorf("backup peer %s is this node; replicate to another node", node)
+	}
+	if peer.MeshIP == "" {
+		return nil, fmt.Errorf("backup peer %s has no mesh address", node)
+	}
+	return &peerBackupObjectStore{installation: installation, peer: peer, baseURL: backupPeerURL(peer.MeshIP)}, nil
+}
+
+// do sends one signed request. A 404 becomes errBackupObjectNotFound.
+func (s *peerBackupObjectStore) do(ctx context.Context, method, route, key string, body io.Reader, size int64, contentSHA256 string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, method, s.baseURL+route+"?key="+url.QueryEscape(key), body)
+	if err != nil {
+		return nil, err
+	}
+	request.ContentLength = size
+	timestamp := time.Now().Unix()
+	signature, err := s.installation.SignAllocation(backupPeerMessage(s.installation.ClusterID, s.installation.NodeID, s.peer.NodeID, method, route, key, timestamp, contentSHA256))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set(backupPeerNodeHeader, s.installation.NodeID)
+	request.Header.Set(backupPeerTimestampHeader, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(backupPeerSHA256Header, contentSHA256)
+	request.Header.Set(backupPeerSignatureHeader, signature)
+	response, err := backupPeerHTTPClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("backup peer %s is unreachable over the mesh: %w", s.peer.NodeName, err)
+	}
+	if response.StatusCode == http.StatusNotFound {
+		response.Body.Close()
+		return nil, errBackupObjectNotFound
+	}
+	if response.StatusCode >= 300 {
+		defer response.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+		return nil, fmt.Errorf("backup peer %s refused %s: %s", s.peer.NodeName, method, strings.TrimSpace(string(message)))
+	}
+	return response, nil
+}
+
+func (s *peerBackupObjectStore) Get(ctx context.Context, key string) ([]byte, error) {
+	response, err := s.do(ctx, http.MethodGet, backupPeerObjectPath, key, nil, 0, sha256Hex(nil))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(response.Body, maxBackupStoreObjectBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxBackupStoreObjectBytes {
+		return nil, fmt.Errorf("backup object %s is too large", key)
+	}
+	return data, nil
+}
+
+func (s *peerBackupObjectStore) Put(ctx context.Context, key string, data []byte) error {
+	response, err := s.do(ctx, http.MethodPut, backupPeerObjectPath, key, bytes.NewReader(data), int64(len(data)), sha256Hex(data))
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+func (s *peerBackupObjectStore) List(ctx context.Context, prefix string) ([]backupStoreObject, error) {
+	response, err := s.do(ctx, http.MethodGet, backupPeerObjectsPath, prefix, nil, 0, sha256Hex(nil))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	var objects []backupStoreObject
+	if err := json.NewDecoder(response.Body).Decode(&objects); err != nil {
+		return nil, fmt.Errorf("invalid backup peer listing: %w", err)
+	}
+	return objects, nil
+}
+
+func (s *peerBackupObjectStore) Delete(ctx context.Context, keys []string) error {
+	for len(keys) > 0 {
+		batch := keys
+		if len(batch) > backupPeerMaxDeleteKeys {
+			batch = batch[:backupPeerMaxDeleteKeys]
+		}
+		keys = keys[len(batch):]
+		data, err := json.Marshal(batch)
+		if err != nil {
+			return err
+		}
+		response, err := s.do(ctx, http.MethodPost, backupPeerDeletePath, "", bytes.NewReader(data), int64(len(data)), sha256Hex(data))
+		if err != nil {
+			return err
+		}
+		response.Body.Close()
+	}
+	return nil
+}
+
+func (s *peerBackupObjectStore) Stat(ctx context.Context, key string) (*BackupObjectInfo, error) {
+	response, err := s.do(ctx, http.MethodHead, backupPeerObjectPath, key, nil, 0, sha256Hex(nil))
+	if err != nil {
+		return nil, err
+	}
+	response.Body.Close()
+	info := &BackupObjectInfo{Size: response.ContentLength}
+	if modified, err := http.ParseTime(response.Header.Get("Last-Modified")); err == nil {
+		info.LastModified = modified.UTC()
+	}
+	return info, nil
+}
+
+func (s *peerBackupObjectStore) Download(ctx context.Context, key string, destination io.Writer) (int64, error) {
+	response, err := s.do(ctx, http.MethodGet, backupPeerObjectPath, key, nil, 0, sha256Hex(nil))
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	return io.Copy(destination, response.Body)
+}
+
+// Upload hashes the archive first so the signature covers its content; the
+// receiver discards a body that does not match.
+func (s *peerBackupObjectStore) Upload(ctx context.Context, key string, localPath string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open backup for upload: %w", err)
+	}
+	defer file.Close()
+	digest := sha256.New()
+	size, err := io.Copy(digest, file)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	response, err := s.do(ctx, http.MethodPut, backupPeerObjectPath, key, file, size, hex.EncodeToString(digest.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+func (s *peerBackupObjectStore) Close() error { return nil }
+
+// backupPeerHandler serves replicas to the cluster nodes that sent them.
+type backupPeerHandler struct {
+	installation  *nodeidentity.Installation
+	inventoryPath string
+	root          string
+	admit         func() error
+	now           func() time.Time
+}
+
+func (h *backupPeerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case backupPeerObjectPath, backupPeerObjectsPath, backupPeerDeletePath:
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	key := r.URL.Query().Get("key")
+	sender, err := h.authenticate(r, key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	store := &filesystemBackupObjectStore{root: filepath.Join(h.root, sender)}
+	switch {
+	case r.URL.Path == backupPeerObjectPath && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		h.serveObject(w, r, store, key)
+	case r.URL.Path == backupPeerObjectPath && r.Method == http.MethodPut:
+		h.receiveObject(w, r, store, key)
+	case r.URL.Path == backupPeerObjectsPath && r.Method == http.MethodGet:
+		objects, err := store.List(r.Context(), key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if objects == nil {
+			objects = []backupStoreObject{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(objects)
+	case r.URL.Path == backupPeerDeletePath && r.Method == http.MethodPost:
+		var keys []string
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxBackupStoreObjectBytes)).Decode(&keys); err != nil || len(keys) > backupPeerMaxDeleteKeys {
+			http.Error(w, "invalid delete request", http.StatusBadRequest)
+			return
+		}
+		if err := store.Delete(r.Context(), keys); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// authenticate returns the sending node ID once the request is proven to
+// come from a current cluster member, addressed to this node, recently.
+func (h *backupPeerHandler) authenticate(r *http.Request, key string) (string, error) {
+	sender := r.Header.Get(backupPeerNodeHeader)
+	timestamp, err := strconv.ParseInt(r.Header.Get(backupPeerTimestampHeader), 10, 64)
+	if err != nil || sender == "" {
+		return "", fmt.Errorf("backup peer request is not signed")
+	}
+	if age := h.now().Sub(time.Unix(timestamp, 0)); age > backupPeerSignatureWindow || age < -backupPeerSignatureWindow {
+		return "", fmt.Errorf("backup peer request signature has expired")
+	}
+	inventory, err := readBackupPeerInventory(h.installation, h.inventoryPath)
+	if err != nil {
+		return "", err
+	}
+	node, ok := findBackupPeer(inventory, sender)
+	if !ok || node.NodeID != sender || sender == h.installation.NodeID {
+		return "", fmt.Errorf("backup peer %s is not an active cluster node", sender)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil || host != node.MeshIP {
+		return "", fmt.Errorf("backup peer %s must call from its mesh address", sender)
+	}
+	message := backupPeerMessage(h.installation.ClusterID, sender, h.installation.NodeID, r.Method, r.URL.Path, key, timestamp, r.Header.Get(backupPeerSHA256Header))
+	if err := nodeidentity.VerifyAllocationSignature(node.AllocationPublicKey, message, r.Header.Get(backupPeerSignatureHeader)); err != nil {
+		return "", fmt.Errorf("backup peer request signature is invalid")
+	}
+	if r.Method != http.MethodPut && r.Method != http.MethodPost && r.Header.Get(backupPeerSHA256Header) != sha256Hex(nil) {
+		return "", fmt.Errorf("backup peer request signature is invalid")
+	}
+	return sender, nil
+}
+
+func (h *backupPeerHandler) serveObject(w http.ResponseWriter, r *http.Request, store *filesystemBackupObjectStore, key string) {
+	filePath, err := store.path(key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	file, err := os.Open(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	w.Header().Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = io.Copy(w, file)
+}
+
+func (h *backupPeerHandler) receiveObject(w http.ResponseWriter, r *http.Request, store *filesystemBackupObjectStore, key string) {
+	if r.ContentLength < 0 {
+		http.Error(w, "content length is required", http.StatusLengthRequired)
+		return
+	}
+	if h.admit != nil {
+		if err := h.admit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInsufficientStorage)
+			return
+		}
+	}
+	digest := sha256.New()
+	body := io.TeeReader(io.LimitReader(r.Body, r.ContentLength), digest)
+	err := store.write(key, body, func() error { return verifyBackupPeerContent(digest, r.Header.Get(backupPeerSHA256Header)) })
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func verifyBackupPeerContent(digest hash.Hash, want string) error {
+	if hex.EncodeToString(digest.Sum(nil)) != want {
+		return fmt.Errorf("backup peer upload does not match its signed digest")
+	}
+	return nil
+}
+
+// runBackupPeerListener serves peer replicas on this node's mesh address,
+// retrying until the mesh interface is up.
+func (s *Server) runBackupPeerListener(ctx context.Context) {
+	handler := &backupPeerHandler{
+		installation:  s.installation,
+		inventoryPath: s.inventoryFile,
+		root:          filepath.Join(backupRootDir, backupPeerDir),
+		admit:         func() error { return s.checkFreeDisk(0, backupRootDir) },
+		now:           time.Now,
+	}
+	for {
+		err := serveBackupPeers(ctx, handler)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "takod backup peer listener: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backupPeerRetryInterval):
+		}
+	}
+}
+
+func serveBackupPeers(ctx context.Context, handler *backupPeerHandler) error {
+	inventory, err := readBackupPeerInventory(handler.installation, handler.inventoryPath)
+	if err != nil {
+		return err
+	}
+	node, ok := inventory.Node(handler.installation.NodeID)
+	if !ok || node.MeshIP == "" {
+		return fmt.Errorf("this node has no mesh address yet")
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(node.MeshIP, strconv.Itoa(backupPeerPort)))
+	if err != nil {
+		return err
+	}
+	server := newTakodHTTPServer(handler)
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = server.Shutdown(shutdownCtx)
+		case <-stopped:
+		}
+	}()
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
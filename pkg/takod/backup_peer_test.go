@@ -0,0 +1,152 @@
+package takod
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/nodeidentity"
+)
+
+type backupPeerTransport struct {
+	handler    http.Handler
+	remoteAddr string
+}
+
+func (t backupPeerTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	request.RemoteAddr = t.remoteAddr
+	recorder := httptest.NewRecorder()
+	t.handler.ServeHTTP(recorder, request)
+	return recorder.Result(), nil
+}
+
+func writeBackupPeerInventory(t *testing.T, nodes ...*nodeidentity.Installation) string {
+	t.Helper()
+	now := time.Now().UTC()
+	meshKeys := []string{"AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=", "AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI="}
+	inventory := nodeidentity.ClusterInventory{
+		APIVersion: nodeidentity.InventoryAPIVersion, Kind: nodeidentity.InventoryKind, ClusterID: nodes[0].ClusterID,
+		Generation: 1, ControllerNodeID: nodes[0].NodeID, MeshCIDR: "10.42.0.0/24", UpdatedAt: now,
+	}
+	for index, node := range nodes {
+		meshID, _ := nodeidentity.MeshCredentialID(meshKeys[index])
+		roles := []string{nodeidentity.RoleWorker}
+		if index == 0 {
+			roles = []string{nodeidentity.RoleControlPlane, nodeidentity.RoleWorker}
+		}
+		inventory.Nodes = append(inventory.Nodes, nodeidentity.InventoryNode{
+			NodeID: node.NodeID, NodeName: node.NodeName, Lifecycle: nodeidentity.NodeLifecycleSchedulable, Roles: roles, Schedulable: true,
+			MeshIP: "10.42.0." + string(rune('1'+index)), MeshEndpoint: node.NodeName + ".example", MeshCredentialID: meshID, MeshPublicKey: meshKeys[index],
+			MeshCredentialStatus: nodeidentity.MeshCredentialActive, AllocationPublicKey: node.AllocationPublicKey, JoinedAt: now, UpdatedAt: now,
+		})
+	}
+	path := filepath.Join(t.TempDir(), "inventory.json")
+	if err := nodeidentity.CreateInventory(path, inventory); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPeerBackupStorageReplicatesOverSignedMeshRequests(t *testing.T) {
+	sender, err := nodeidentity.New("11111111-1111-4111-8111-111111111111", "22222222-2222-4222-8222-222222222222", "node-a", []string{nodeidentity.RoleControlPlane, nodeidentity.RoleWorker}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver, err := nodeidentity.New(sender.ClusterID, "33333333-3333-4333-8333-333333333333", "node-b", []string{nodeidentity.RoleWorker}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	inventoryPath := writeBackupPeerInventory(t, sender, receiver)
+	root := t.TempDir()
+	handler := &backupPeerHandler{installation: receiver, inventoryPath: inventoryPath, root: root, now: time.Now}
+	previousClient := backupPeerHTTPClient
+	backupPeerHTTPClient = &http.Client{Transport: backupPeerTransport{handler: handler, remoteAddr: "10.42.0.1:41000"}}
+	t.Cleanup(func() { backupPeerHTTPClient = previousClient })
+	t.Cleanup(activateBackupPeers(sender, inventoryPath))
+
+	storage := BackupStorageConfig{Provider: BackupStorageProviderPeer, Node: "node-b", Prefix: "apps"}
+	content := []byte("volume contents")
+	backupID := time.Now().UTC().Format("20060102-150405")
+	remote, err := UploadBackupObject(context.Background(), storage, writeTestBackupArchive(t, t.TempDir(), backupID, content))
+	if err != nil {
+		t.Fatalf("UploadBackupObject returned error: %v", err)
+	}
+	if remote.Endpoint != "node-b" {
+		t.Fatalf("remote = %+v", remote)
+	}
+	if _, err := os.Stat(filepath.Join(root, sender.NodeID, filepath.FromSlash(remote.Key))); err != nil {
+		t.Fatalf("replica is not stored under the sender's directory: %v", err)
+	}
+
+	t.Cleanup(useTempBackupRoot(t))
+	request := BackupRequest{Project: "demo", Environment: "production", Volume: "data", BackupID: backupID, Storage: &storage}
+	if _, err := fetchBackupFromStorage(context.Background(), request); err != nil {
+		t.Fatalf("fetchBackupFromStorage returned error: %v", err)
+	}
+	if restored := readTestBackupArchive(t, filepath.Join(backupDirectory(request), backupFileName("data", backupID))); !bytes.Equal(restored, content) {
+		t.Fatalf("fetched archive = %q, want %q", restored, content)
+	}
+
+	store, err := newPeerBackupObjectStore("node-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects, err := store.List(context.Background(), "apps/demo/")
+	if err != nil || len(objects) != 1 || objects[0].Key != remote.Key {
+		t.Fatalf("List = %+v, %v", objects, err)
+	}
+	if err := store.Delete(context.Background(), []string{remote.Key}); err != nil {
+		t.Fatalf("Delete returned error: %v", err)
+	}
+	if _, err := store.Stat(context.Background(), remote.Key); err != errBackupObjectNotFound {
+		t.Fatalf("Stat after Delete = %v, want errBackupObjectNotFound", err)
+	}
+	if _, err := newPeerBackupObjectStore("node-a"); err == nil || !strings.Contains(err.Error(), "is this node") {
+		t.Fatalf("self peer = %v", err)
+	}
+}
+
+func TestBackupPeerHandlerRejectsUntrustedRequests(t *testing.T) {
+	sender, _ := nodeidentity.New("11111111-1111-4111-8111-111111111111", "22222222-2222-4222-8222-222222222222", "node-a", []string{nodeidentity.RoleWorker}, time.Now())
+	receiver, _ := nodeidentity.New(sender.ClusterID, "33333333-3333-4333-8333-333333333333", "node-b", []string{nodeidentity.RoleWorker}, time.Now())
+	inventoryPath := writeBackupPeerInventory(t, sender, receiver)
+	handler := &backupPeerHandler{installation: receiver, inventoryPath: inventoryPath, root: t.TempDir(), now: time.Now}
+	t.Cleanup(activateBackupPeers(sender, inventoryPath))
+	previousClient := backupPeerHTTPClient
+	t.Cleanup(func() { backupPeerHTTPClient = previousClient })
+	store, err := newPeerBackupObjectStore("node-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backupPeerHTTPClient = &http.Client{Transport: backupPeerTransport{handler: handler, remoteAddr: "10.42.0.9:41000"}}
+	if err := store.Put(context.Background(), "apps/key", []byte("data")); err == nil || !strings.Contains(err.Error(), "mesh address") {
+		t.Fatalf("request from a foreign address = %v", err)
+	}
+
+	backupPeerHTTPClient = &http.Client{Transport: backupPeerTransport{handler: handler, remoteAddr: "10.42.0.1:41000"}}
+	handler.now = func() time.Time { return time.Now().Add(time.Hour) }
+	if err := store.Put(context.Background(), "apps/key", []byte("data")); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Fatalf("stale request = %v", err)
+	}
+	handler.now = time.Now
+
+	forged := httptest.NewRequest(http.MethodPut, backupPeerObjectPath+"?key=apps/key", strings.NewReader("data"))
+	forged.RemoteAddr = "10.42.0.1:41000"
+	forged.Header.Set(backupPeerNodeHeader, sender.NodeID)
+	forged.Header.Set(backupPeerTimestampHeader, strconv.FormatInt(time.Now().Unix(), 10))
+	forged.Header.Set(backupPeerSHA256Header, sha256Hex([]byte("data")))
+	forged.Header.Set(backupPeerSignatureHeader, "forged")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, forged)
+	if recorder.Code != http.StatusForbidden || !strings.Contains(recorder.Body.String(), "signature is invalid") {
+		t.Fatalf("forged signature = %d %s", recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,741 @@
+package takod
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Point-in-time recovery keeps pg_basebackup artifacts next to the WAL the
+// service archived since, under the same storage prefix:
+//
+//	<prefix>/<project>/<env>/<volume>/<volume>_<id>.pgbase.tgz  base backups
+//	<prefix>/<project>/<env>/<volume>/.tako-wal/<segment>       archived WAL
+//
+// Postgres copies each finished segment into a spool directory inside its
+// data directory; takod moves the spool into storage between base backups.
+// A chunked storage target keeps base backups as chunk snapshots and seals
+// each archived WAL file with the volume's chunk repository keys, so only
+// archive storage holds WAL in plaintext.
+const (
+	walArchiveDir     = ".tako-wal"
+	postgresWALSpool  = "pg_tako_wal"
+	walArchiveTimeout = "60s"
+
+	defaultPITRRestoreTimeout = time.Hour
+	maxPITRRestoreTimeout     = 24 * time.Hour
+	pitrRestartTimeout        = 2 * time.Minute
+
+	// Segments are selected by when they reached storage, which trails the
+	// records they hold by archive_timeout plus the ship interval. The slack
+	// keeps a lagging shipper from cutting off WAL written before the target.
+	pitrWALSelectionSlack = time.Hour
+
+	// walSpoolAlertBytes is how much WAL may wait in the spool before the
+	// shipper is reported as falling behind: 64 default 16 MiB segments,
+	// about an hour of archive_timeout switches on an idle database.
+	walSpoolAlertBytes = 1 << 30
+
+	pitrRecoveryBegin = "# tako point-in-time recovery"
+	pitrRecoveryEnd   = "# end tako point-in-time recovery"
+)
+
+// postgresWALArchiveCommand runs in the data directory. It writes under a
+// temporary name so the shipper never uploads a partial segment, and leaves
+// an already spooled segment alone because Postgres may archive one twice.
+const postgresWALArchiveCommand = `mkdir -p ` + postgresWALSpool + ` && { test -f ` + postgresWALSpool + `/%f || { cp %p ` + postgresWALSpool + `/%f.tmp && mv ` + postgresWALSpool + `/%f.tmp ` + postgresWALSpool + `/%f; }; }`
+
+var (
+	// walShipInterval paces WAL shipping; walSwitchSettle is how long a
+	// restore waits for the archiver after forcing a segment switch. Tests
+	// shorten both.
+	walShipInterval = 30 * time.Second
+	walSwitchSettle = 2 * time.Second
+
+	walFileNamePattern    = regexp.MustCompile(`^([0-9A-F]{24}(\.[0-9A-F]{8}\.backup|\.partial)?|[0-9A-F]{8}\.history)$`)
+	walSegmentNamePattern = regexp.MustCompile(`^[0-9A-F]{24}$`)
+)
+
+// PITRRestoreRequest recovers a Postgres service's volume as of Target into
+// TargetVolume, a new Docker volume the service can then be pointed at.
+type PITRRestoreRequest struct {
+	Project      string `json:"project"`
+	Environment  string `json:"environment"`
+	Service      string `json:"service"`
+	Volume       string `json:"volume"`
+	DockerVolume string `json:"dockerVolume,omitempty"`
+	TargetVolume string `json:"targetVolume"`
+	// Target is the moment to recover to; the newest base backup taken
+	// before it is replayed forward through archived WAL.
+	Target         time.Time            `json:"target"`
+	Storage        *BackupStorageConfig `json:"storage"`
+	TimeoutSeconds int                  `json:"timeoutSeconds,omitempty"`
+}
+
+// PITRRestoreResponse describes a finished recovery.
+type PITRRestoreResponse struct {
+	Volume       string    `json:"volume"`
+	BaseBackupID string    `json:"baseBackupId"`
+	Target       time.Time `json:"target"`
+	WALSegments  int       `json:"walSegments"`
+	Warnings     []string  `json:"warnings,omitempty"`
+}
+
+// ensurePostgresWALArchiving points archive_command at the spool directory.
+// archive_mode and wal_level only change on restart, so a service that had
+// archiving off is restarted once and reported in the returned warnings.
+func ensurePostgresWALArchiving(ctx context.Context, container string) ([]string, error) {
+	output, err := runDocker(ctx, "exec", container, "sh", "-c", postgresWALArchivingScript())
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure WAL archiving in %s: %w, output: %s", container, err, strings.TrimSpace(output))
+	}
+	restart := false
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "restart" {
+			restart = true
+		}
+	}
+	if !restart {
+		return nil, nil
+	}
+	if output, err := runDocker(ctx, "restart", container); err != nil {
+		return nil, fmt.Errorf("failed to restart %s to enable WAL archiving: %w, output: %s", container, err, strings.TrimSpace(output))
+	}
+	readyCtx, cancel := context.WithTimeout(ctx, pitrRestartTimeout)
+	defer cancel()
+	if err := retryUntilDeadline(readyCtx, func() error {
+		output, err := runDocker(readyCtx, "exec", container, "sh", "-c", pgReadyScript())
+		if err != nil {
+			return fmt.Errorf("%w, output: %s", err, strings.TrimSpace(output))
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("%s did not accept connections after enabling WAL archiving: %w", container, err)
+	}
+	return []string{fmt.Sprintf("enabled WAL archiving; restarted %s once so archive_mode takes effect", container)}, nil
+}
+
+// shipPostgresWAL uploads every spooled WAL file of req.Service to
+// req.Storage and removes it from the spool once stored. It returns how many
+// files were shipped and how many bytes of WAL are still spooled; nothing is
+// known to be spooled when the spool could not be listed.
+func shipPostgresWAL(ctx context.Context, req BackupRequest) (int, int64, error) {
+	container, err := resolveBackupContainer(ctx, req)
+	if err != nil {
+		return 0, 0, err
+	}
+	output, err := runDocker(ctx, "exec", container, "sh", "-c", postgresWALSpoolScript())
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list spooled WAL in %s: %w, output: %s", container, err, strings.TrimSpace(output))
+	}
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	spool := strings.TrimSpace(lines[0])
+	if !path.IsAbs(spool) || path.Base(spool) != postgresWALSpool || hasControlChars(spool) {
+		return 0, 0, fmt.Errorf("unexpected WAL spool directory %q in %s", spool, container)
+	}
+	type spooledFile struct {
+		name string
+		size int64
+	}
+	var files []spooledFile
+	for _, line := range lines[1:] {
+		sizeField, name, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok || !walFileNamePattern.MatchString(name) {
+			continue
+		}
+		size, err := strconv.ParseInt(sizeField, 10, 64)
+		if err != nil {
+			continue
+		}
+		files = append(files, spooledFile{name: name, size: size})
+	}
+	if len(files) == 0 {
+		return 0, 0, nil
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+	var spooled int64
+	for _, file := range files {
+		spooled += file.size
+	}
+
+	storage := normalizeBackupStorage(*req.Storage)
+	store, err := newBackupObjectStore(ctx, storage)
+	if err != nil {
+		return 0, spooled, err
+	}
+	defer store.Close()
+	var repo *chunkRepository
+	if storage.Format == BackupStorageFormatChunked {
+		repo, err = openChunkRepository(ctx, store, storage.EncryptionKey, chunkRepositoryRoot(storage.Prefix, req.Project, req.Environment, req.Volume), true)
+		if err != nil {
+			return 0, spooled, err
+		}
+	}
+	shipped := 0
+	for _, file := range files {
+		if file.size > maxBackupStoreObjectBytes {
+			return shipped, spooled, fmt.Errorf("WAL file %s is larger than %d bytes", file.name, maxBackupStoreObjectBytes)
+		}
+		var buffer bytes.Buffer
+		if err := runDockerStream(ctx, nil, &buffer, "exec", container, "cat", spool+"/"+file.name); err != nil {
+			return shipped, spooled, fmt.Errorf("failed to read WAL file %s: %w", file.name, err)
+		}
+		data := buffer.Bytes()
+		if repo != nil {
+			if data, err = sealWALFile(repo, file.name, data); err != nil {
+				return shipped, spooled, fmt.Errorf("failed to encrypt WAL file %s: %w", file.name, err)
+			}
+		}
+		if err := store.Put(ctx, walArchiveKey(storage.Prefix, req.Project, req.Environment, req.Volume, file.name), data); err != nil {
+			return shipped, spooled, fmt.Errorf("failed to upload WAL file %s: %w", file.name, err)
+		}
+		if output, err := runDocker(ctx, "exec", container, "rm", "-f", spool+"/"+file.name); err != nil {
+			return shipped, spooled, fmt.Errorf("failed to remove shipped WAL file %s: %w, output: %s", file.name, err, strings.TrimSpace(output))
+		}
+		shipped++
+		spooled -= file.size
+	}
+	return shipped, spooled, nil
+}
+
+// sealWALFile compresses a WAL file and encrypts it with the volume's chunk
+// repository keys, bound to its name so one archived file cannot stand in
+// for another.
+func sealWALFile(repo *chunkRepository, name string, data []byte) ([]byte, error) {
+	compressed, err := deflateBackupObject(data)
+	if err != nil {
+		return nil, err
+	}
+	return repo.keys.Seal(walArchiveDir+"/"+name, compressed)
+}
+
+func openWALFile(repo *chunkRepository, name string, sealed []byte) ([]byte, error) {
+	compressed, err := repo.keys.Open(walArchiveDir+"/"+name, sealed)
+	if err != nil {
+		return nil, err
+	}
+	data, err := inflateBackupObject(compressed, maxBackupStoreObjectBytes)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxBackupStoreObjectBytes {
+		return nil, fmt.Errorf("WAL file %s is larger than %d bytes", name, maxBackupStoreObjectBytes)
+	}
+	return data, nil
+}
+
+// flushPostgresWAL closes the current WAL segment and ships it so a recovery
+// target of a moment ago is covered by storage.
+func flushPostgresWAL(ctx context.Context, req BackupRequest, container string) error {
+	if output, err := runDocker(ctx, "exec", container, "sh", "-c", postgresSQLScript("SELECT pg_switch_wal()")); err != nil {
+		return fmt.Errorf("failed to switch WAL segment: %w, output: %s", err, strings.TrimSpace(output))
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(walSwitchSettle):
+	}
+	_, _, err := shipPostgresWAL(ctx, req)
+	return err
+}
+
+// pruneWALArchive deletes archived WAL older than the oldest base backup left
+// in storage; nothing can be replayed onto a base that no longer exists.
+// Timeline history files are always kept.
+func pruneWALArchive(ctx context.Context, storage BackupStorageConfig, project string, environment string, volume string) error {
+	store, err := newBackupObjectStore(ctx, normalizeBackupStorage(storage))
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	objects, err := store.List(ctx, backupObjectRetentionPrefix(storage.Prefix, BackupObjectRetention{Project: project, Environment: environment, Volume: volume}))
+	if err != nil {
+		return fmt.Errorf("failed to list archived WAL: %w", err)
+	}
+	return store.Delete(ctx, expiredWALArchiveKeys(objects))
+}
+
+func expiredWALArchiveKeys(objects []backupStoreObject) []string {
+	var oldest time.Time
+	for _, object := range objects {
+		if createdAt, ok := baseBackupObjectTime(object.Key); ok && (oldest.IsZero() || createdAt.Before(oldest)) {
+			oldest = createdAt
+		}
+	}
+	if oldest.IsZero() {
+		return nil
+	}
+	var expired []string
+	for _, object := range objects {
+		if !isWALArchiveKey(object.Key) || strings.HasSuffix(object.Key, ".history") {
+			continue
+		}
+		if object.LastModified.Before(oldest) {
+			expired = append(expired, object.Key)
+		}
+	}
+	sort.Strings(expired)
+	return expired
+}
+
+// RestorePointInTime replays the newest base backup before req.Target and the
+// WAL archived after it into a new volume. A copy of the service boots on the
+// volume without network access, recovers to the target, promotes, and is
+// shut down cleanly; the service itself and its volume are never touched.
+func RestorePointInTime(ctx context.Context, req PITRRestoreRequest) (response *PITRRestoreResponse, err error) {
+	if err := validatePITRRestoreRequest(req); err != nil {
+		return nil, err
+	}
+	timeout := defaultPITRRestoreTimeout
+	if req.TimeoutSeconds > 0 {
+		timeout = time.Duration(req.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if _, inspectErr := runDocker(ctx, "volume", "inspect", req.TargetVolume); inspectErr == nil {
+		return nil, fmt.Errorf("volume %s already exists; recover into a new volume", req.TargetVolume)
+	}
+
+	storage := normalizeBackupStorage(*req.Storage)
+	backupReq := BackupRequest{
+		Project:      req.Project,
+		Environment:  req.Environment,
+		Volume:       req.Volume,
+		DockerVolume: req.DockerVolume,
+		Service:      req.Service,
+		Storage:      &storage,
+	}
+	container, err := resolveBackupContainer(ctx, backupReq)
+	if err != nil {
+		return nil, err
+	}
+	dataDir, err := postgresDataSubdir(ctx, container, fullBackupVolumeName(backupReq))
+	if err != nil {
+		return nil, err
+	}
+	response = &PITRRestoreResponse{Volume: req.TargetVolume, Target: req.Target.UTC()}
+	if err := flushPostgresWAL(ctx, backupReq, container); err != nil {
+		response.Warnings = append(response.Warnings, fmt.Sprintf("could not archive the newest WAL before recovery: %v", err))
+	}
+
+	store, err := newBackupObjectStore(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	objects, err := store.List(ctx, backupObjectRetentionPrefix(storage.Prefix, BackupObjectRetention{Project: req.Project, Environment: req.Environment, Volume: req.Volume}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backup storage: %w", err)
+	}
+	baseID, err := selectPITRBaseBackup(objects, req.Target)
+	if err != nil {
+		return nil, err
+	}
+	backupReq.BackupID = baseID
+	basePath, _, err := findBackupArtifact(backupDirectory(backupReq), req.Volume, baseID)
+	if err != nil {
+		if basePath, err = fetchBackupFromStorage(ctx, backupReq); err != nil {
+			return nil, err
+		}
+	}
+	start, err := readBaseBackupStartSegment(basePath)
+	if err != nil {
+		return nil, err
+	}
+	segments := selectPITRWALSegments(objects, start, req.Target)
+	response.BaseBackupID = baseID
+	response.WALSegments = len(segments)
+	var repo *chunkRepository
+	if storage.Format == BackupStorageFormatChunked {
+		repo, err = openChunkRepository(ctx, store, storage.EncryptionKey, chunkRepositoryRoot(storage.Prefix, req.Project, req.Environment, req.Volume), false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	staging, err := os.MkdirTemp(backupDirectory(backupReq), ".pitr-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create WAL staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+	// The recovering server reads the staging directory as its own user.
+	if err := os.Chmod(staging, 0755); err != nil {
+		return nil, err
+	}
+	for _, key := range segments {
+		data, err := store.Get(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download WAL %s: %w", path.Base(key), err)
+		}
+		if repo != nil {
+			if data, err = openWALFile(repo, path.Base(key), data); err != nil {
+				return nil, fmt.Errorf("archived WAL %s: %w", path.Base(key), err)
+			}
+		}
+		if err := os.WriteFile(filepath.Join(staging, path.Base(key)), data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to stage WAL %s: %w", path.Base(key), err)
+		}
+	}
+
+	if err := ensureDockerVolume(ctx, req.Project, req.Environment, "", req.TargetVolume); err != nil {
+		return nil, fmt.Errorf("failed to create volume %s: %w", req.TargetVolume, err)
+	}
+	defer func() {
+		if err != nil {
+			cleanupCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			_, _ = runDocker(cleanupCtx, "volume", "rm", "-f", req.TargetVolume)
+		}
+	}()
+	if err := extractBaseBackup(ctx, basePath, req.TargetVolume, dataDir, pitrRecoverySettings(req.Target)); err != nil {
+		return nil, err
+	}
+	recovering, err := bootVerificationContainer(ctx, backupReq, req.TargetVolume, fmt.Sprintf("pitr-%d", time.Now().UnixNano()), staging+":/tako-wal:ro")
+	if recovering != "" {
+		defer func() {
+			cleanupCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			_, _ = runDocker(cleanupCtx, "rm", "-f", recovering)
+		}()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := waitForPostgresRecovery(ctx, recovering); err != nil {
+		return nil, err
+	}
+	if output, err := runDocker(ctx, "stop", recovering); err != nil {
+		return nil, fmt.Errorf("failed to stop the recovered database: %w, output: %s", err, strings.TrimSpace(output))
+	}
+	if output, err := runDocker(
+		ctx,
+		"run", "--rm",
+		"--network", "none",
+		"-v", req.TargetVolume+":/target",
+		backupImage,
+		"sh", "-c", pitrFinalizeScript(dataDir),
+	); err != nil {
+		return nil, fmt.Errorf("failed to clear recovery settings: %w, output: %s", err, strings.TrimSpace(output))
+	}
+	return response, nil
+}
+
+// extractBaseBackup unpacks a pg_basebackup artifact into dataDir on an empty
+// volume, owned by the user the cluster was backed up as. recovery, when set,
+// is appended to postgresql.auto.conf and arms recovery.signal.
+func extractBaseBackup(ctx context.Context, backupPath string, volume string, dataDir string, recovery string) error {
+	if output, err := runDocker(
+		ctx,
+		"run", "--rm",
+		"--network", "none",
+		"-v", volume+":/target",
+		"-v", filepath.Dir(backupPath)+":/backup:ro",
+		backupImage,
+		"sh", "-c", baseBackupExtractScript(filepath.Base(backupPath), dataDir, recovery),
+	); err != nil {
+		return fmt.Errorf("base backup did not extract: %w, output: %s", err, strings.TrimSpace(output))
+	}
+	return nil
+}
+
+// postgresDataSubdir returns where the cluster lives inside its volume:
+// "." when the volume is mounted at data_directory, or the relative path
+// when PGDATA is a subdirectory of the mount.
+func postgresDataSubdir(ctx context.Context, container string, dockerVolume string) (string, error) {
+	mount, err := verificationMountTarget(ctx, container, dockerVolume)
+	if err != nil {
+		return "", err
+	}
+	output, err := runDocker(ctx, "exec", container, "sh", "-c", postgresSQLScript("SHOW data_directory"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read data_directory from %s: %w, output: %s", container, err, strings.TrimSpace(output))
+	}
+	dataDir := path.Clean(strings.TrimSpace(output))
+	mount = path.Clean(mount)
+	if dataDir == mount {
+		return ".", nil
+	}
+	relative, ok := strings.CutPrefix(dataDir, mount+"/")
+	if !ok || !path.IsAbs(dataDir) || hasControlChars(relative) {
+		return "", fmt.Errorf("%s keeps its data directory %s outside volume %s", container, dataDir, dockerVolume)
+	}
+	return relative, nil
+}
+
+func waitForPostgresRecovery(ctx context.Context, container string) error {
+	for {
+		running, err := runDocker(ctx, "inspect", "--format", "{{.State.Running}}", container)
+		if err == nil && strings.TrimSpace(running) != "true" {
+			logs, _ := runDocker(ctx, "logs", "--tail", "20", container)
+			return fmt.Errorf("postgres stopped before reaching the recovery target: %s", strings.TrimSpace(logs))
+		}
+		output, err := runDocker(ctx, "exec", container, "sh", "-c", postgresSQLScript("SELECT pg_is_in_recovery()"))
+		if err == nil && strings.TrimSpace(output) == "f" {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("recovery did not reach the target before the timeout")
+		case <-time.After(backupVerifyRetryInterval):
+		}
+	}
+}
+
+// selectPITRBaseBackup picks the newest pg_basebackup in storage taken at or
+// before target.
+func selectPITRBaseBackup(objects []backupStoreObject, target time.Time) (string, error) {
+	var chosen string
+	var chosenAt time.Time
+	for _, object := range objects {
+		createdAt, ok := baseBackupObjectTime(object.Key)
+		if !ok || createdAt.After(target) {
+			continue
+		}
+		if chosen == "" || createdAt.After(chosenAt) {
+			_, backupID, _, _ := parseBackupFileName(baseBackupObjectName(object.Key))
+			chosen, chosenAt = backupID, createdAt
+		}
+	}
+	if chosen == "" {
+		return "", fmt.Errorf("no pg_basebackup backup in storage was taken before %s", target.UTC().Format(time.RFC3339))
+	}
+	return chosen, nil
+}
+
+// selectPITRWALSegments returns the archived WAL a recovery from the base
+// starting at segment start may read: every timeline history file and each
+// later segment that reached storage before the target plus the slack.
+// Postgres asks for segments one at a time and stops at the target, so an
+// extra segment costs only its download.
+func selectPITRWALSegments(objects []backupStoreObject, start string, target time.Time) []string {
+	cutoff := target.Add(pitrWALSelectionSlack)
+	var keys []string
+	for _, object := range objects {
+		if !isWALArchiveKey(object.Key) {
+			continue
+		}
+		name := path.Base(object.Key)
+		switch {
+		case strings.HasSuffix(name, ".history") && walFileNamePattern.MatchString(name):
+			keys = append(keys, object.Key)
+		case walSegmentNamePattern.MatchString(name) && name[:8] >= start[:8] && name[8:] >= start[8:] && !object.LastModified.After(cutoff):
+			keys = append(keys, object.Key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// readBaseBackupStartSegment reads the WAL segment recovery starts from out
+// of the base backup's backup_label.
+func readBaseBackupStartSegment(backupPath string) (string, error) {
+	file, err := os.Open(backupPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open base backup: %w", err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return "", fmt.Errorf("base backup is not valid gzip: %w", err)
+	}
+	defer gz.Close()
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return "", fmt.Errorf("base backup has no backup_label")
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read base backup: %w", err)
+		}
+		if path.Clean(header.Name) != "backup_label" {
+			continue
+		}
+		label, err := io.ReadAll(io.LimitReader(archive, 64<<10))
+		if err != nil {
+			return "", fmt.Errorf("failed to read backup_label: %w", err)
+		}
+		for _, line := range strings.Split(string(label), "\n") {
+			if !strings.HasPrefix(line, "START WAL LOCATION:") {
+				continue
+			}
+			_, rest, ok := strings.Cut(line, "(file ")
+			segment, _, closed := strings.Cut(rest, ")")
+			if ok && closed && walSegmentNamePattern.MatchString(segment) {
+				return segment, nil
+			}
+		}
+		return "", fmt.Errorf("backup_label has no START WAL LOCATION")
+	}
+}
+
+func baseBackupObjectTime(key string) (time.Time, bool) {
+	if isWALArchiveKey(key) || isChunkRepositoryKey(key) {
+		return time.Time{}, false
+	}
+	_, backupID, format, err := parseBackupFileName(baseBackupObjectName(key))
+	if err != nil || format.mode != BackupModePgBaseBackup {
+		return time.Time{}, false
+	}
+	createdAt, err := backupIDTimestamp(backupID)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return createdAt, true
+}
+
+// baseBackupObjectName returns the artifact file name a storage key holds,
+// looking through the snapshot suffix of a chunked target.
+func baseBackupObjectName(key string) string {
+	return strings.TrimSuffix(path.Base(key), chunkSnapshotSuffix)
+}
+
+func walArchiveKey(prefix string, project string, environment string, volume string, name string) string {
+	return joinObjectKey(cleanObjectKeyPrefix(prefix), project, environment, volume, walArchiveDir, name)
+}
+
+func isWALArchiveKey(key string) bool {
+	return strings.Contains("/"+key, "/"+walArchiveDir+"/")
+}
+
+func postgresSQLScript(query string) string {
+	return pgScriptPrelude("") + `exec psql -X -q -A -t -v ON_ERROR_STOP=1 --username="$user" --dbname="$database" -c ` + shellQuote(query) + `
+`
+}
+
+func pgReadyScript() string {
+	return pgScriptPrelude("") + `exec pg_isready --username="$user" --dbname="$database"
+`
+}
+
+func postgresWALArchivingScript() string {
+	return pgScriptPrelude("") + `sql() {
+  psql -X -q -A -t -v ON_ERROR_STOP=1 --username="$user" --dbname="$database" -c "$1"
+}
+restart=
+if [ "$(sql 'SHOW wal_level')" = minimal ]; then
+  sql "ALTER SYSTEM SET wal_level = 'replica'"
+  restart=1
+fi
+if [ "$(sql 'SHOW archive_mode')" = off ]; then
+  sql "ALTER SYSTEM SET archive_mode = 'on'"
+  restart=1
+fi
+sql "ALTER SYSTEM SET archive_command = '` + postgresWALArchiveCommand + `'"
+sql "ALTER SYSTEM SET archive_timeout = '` + walArchiveTimeout + `'"
+sql 'SELECT pg_reload_conf()' >/dev/null
+if [ -n "$restart" ]; then
+  echo restart
+fi
+`
+}
+
+// postgresWALSpoolScript prints the spool directory, then "<size> <name>"
+// for each file in it.
+func postgresWALSpoolScript() string {
+	return pgScriptPrelude("") + `dir="$(psql -X -q -A -t -v ON_ERROR_STOP=1 --username="$user" --dbname="$database" -c 'SHOW data_directory')/` + postgresWALSpool + `"
+echo "$dir"
+if [ -d "$dir" ]; then
+  cd "$dir"
+  for file in *; do
+    if [ -f "$file" ]; then
+      stat -c '%s %n' "$file"
+    fi
+  done
+fi
+`
+}
+
+func pitrRecoverySettings(target time.Time) string {
+	return pitrRecoveryBegin + `
+restore_command = 'cp /tako-wal/%f %p'
+recovery_target_time = '` + target.UTC().Format("2006-01-02 15:04:05.999999-07") + `'
+recovery_target_action = 'promote'
+` + pitrRecoveryEnd + `
+`
+}
+
+func baseBackupExtractScript(backupFile string, dataDir string, recovery string) string {
+	script := `set -eu
+backupPath=/backup/` + shellQuote(backupFile) + `
+data=/target/` + shellQuote(dataDir) + `
+if [ -n "$(ls -A /target)" ]; then
+  echo "restore target volume is not empty" >&2
+  exit 1
+fi
+tar -tzf "$backupPath" | ` + backupArchiveEntryCheck + `
+mkdir -p "$data"
+tar -xzf "$backupPath" -C "$data"
+rm -rf "$data/` + postgresWALSpool + `"
+`
+	if recovery != "" {
+		script += `cat >> "$data/postgresql.auto.conf" <<'TAKO_RECOVERY'
+` + recovery + `TAKO_RECOVERY
+touch "$data/recovery.signal"
+`
+	}
+	return script + `owner=$(stat -c %u:%g "$data/PG_VERSION")
+chown -R "$owner" /target
+chmod 0700 "$data"
+`
+}
+
+func pitrFinalizeScript(dataDir string) string {
+	return `set -eu
+data=/target/` + shellQuote(dataDir) + `
+owner=$(stat -c %u:%g "$data/PG_VERSION")
+sed -i '/^` + pitrRecoveryBegin + `$/,/^` + pitrRecoveryEnd + `$/d' "$data/postgresql.auto.conf"
+chown "$owner" "$data/postgresql.auto.conf"
+chmod 0600 "$data/postgresql.auto.conf"
+rm -f "$data/recovery.signal"
+`
+}
+
+func validatePITRRestoreRequest(req PITRRestoreRequest) error {
+	backupReq := BackupRequest{
+		Project:      req.Project,
+		Environment:  req.Environment,
+		Volume:       req.Volume,
+		DockerVolume: req.DockerVolume,
+		Service:      req.Service,
+	}
+	if err := validateBackupRequest(backupReq, true, false); err != nil {
+		return err
+	}
+	if req.Service == "" {
+		return fmt.Errorf("service is required for point-in-time recovery")
+	}
+	if !isSafeDockerVolumeName(req.TargetVolume) {
+		return fmt.Errorf("invalid target volume name")
+	}
+	if req.TargetVolume == fullBackupVolumeName(backupReq) {
+		return fmt.Errorf("point-in-time recovery writes a new volume; choose a target other than %s", req.TargetVolume)
+	}
+	if req.Target.IsZero() {
+		return fmt.Errorf("recovery target time is required")
+	}
+	if req.Target.After(time.Now().Add(time.Minute)) {
+		return fmt.Errorf("recovery target %s is in the future", req.Target.UTC().Format(time.RFC3339))
+	}
+	if req.Storage == nil {
+		return fmt.Errorf("backup storage is required for point-in-time recovery")
+	}
+	if err := ValidateBackupStorage(*req.Storage); err != nil {
+		return err
+	}
+	if req.TimeoutSeconds < 0 || time.Duration(req.TimeoutSeconds)*time.Second > maxPITRRestoreTimeout {
+		return fmt.Errorf("recovery timeout must be at most %s", maxPITRRestoreTimeout)
+	}
+	return nil
+}
@@ -0,0 +1,335 @@
+package takod
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/notification"
+)
+
+func TestCreateVolumeBackupEnablesWALArchivingForPITR(t *testing.T) {
+	logPath := useFakeBackupContainer(t)
+	t.Setenv("TAKO_FAKE_DOCKER_EXEC_OUTPUT", "restart\n")
+	root := t.TempDir()
+	storage := BackupStorageConfig{Provider: BackupStorageProviderFilesystem, Path: root, Prefix: "apps"}
+
+	info, err := CreateVolumeBackup(context.Background(), BackupRequest{
+		Project: "demo", Environment: "production", Volume: "pgdata", BackupID: "20261016-020000",
+		Service: "postgres", Mode: BackupModePgBaseBackup, PITR: true, Storage: &storage,
+	})
+	if err != nil {
+		t.Fatalf("CreateVolumeBackup returned error: %v", err)
+	}
+	if info.Mode != BackupModePgBaseBackup || !strings.HasSuffix(info.Path, "pgdata_20261016-020000.pgbase.tgz") {
+		t.Fatalf("info = %+v", info)
+	}
+	if len(info.Warnings) != 1 || !strings.Contains(info.Warnings[0], "restarted demo_production_postgres_1 once") {
+		t.Fatalf("warnings = %v", info.Warnings)
+	}
+	if info.Remote == nil || info.Remote.Key != "apps/demo/production/pgdata/pgdata_20261016-020000.pgbase.tgz" {
+		t.Fatalf("remote = %+v", info.Remote)
+	}
+
+	commands := readCommandLog(t, logPath)
+	configure := commandIndex(t, commands, "docker exec demo_production_postgres_1 sh -c set -eu")
+	restart := commandIndex(t, commands, "docker restart demo_production_postgres_1")
+	if script := strings.Join(commands[configure:restart], "\n"); !strings.Contains(script, "ALTER SYSTEM SET archive_command = 'mkdir -p pg_tako_wal") || !strings.Contains(script, "archive_mode = 'on'") {
+		t.Fatalf("archiving script:\n%s", script)
+	}
+	if script := strings.Join(commands[restart:], "\n"); !strings.Contains(script, "pg_isready") || !strings.Contains(script, "pg_basebackup --username=\"$user\" --pgdata=- --format=tar --wal-method=fetch") {
+		t.Fatalf("commands after restart:\n%s", script)
+	}
+}
+
+func TestValidateBackupRequestRequiresStorageForPITR(t *testing.T) {
+	valid := BackupRequest{Project: "demo", Environment: "production", Volume: "pgdata", Service: "postgres", Mode: BackupModePgBaseBackup, PITR: true}
+	for _, tc := range []struct {
+		mutate  func(*BackupRequest)
+		wantErr string
+	}{
+		{func(r *BackupRequest) { r.Mode = BackupModePgDump }, "pitr requires backup mode pg_basebackup"},
+		{func(r *BackupRequest) {}, "pitr requires backup storage"},
+	} {
+		request := valid
+		tc.mutate(&request)
+		if err := validateBackupRequest(request, true, false); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Fatalf("error = %v, want substring %q", err, tc.wantErr)
+		}
+	}
+	valid.Storage = &BackupStorageConfig{Provider: BackupStorageProviderFilesystem, Path: "/mnt/nas", Format: BackupStorageFormatChunked, EncryptionKey: "correct horse battery staple"}
+	if err := validateBackupRequest(valid, true, false); err != nil {
+		t.Fatalf("pitr with chunked storage = %v", err)
+	}
+}
+
+func TestRestoreVolumeBackupRefusesBaseBackupInPlace(t *testing.T) {
+	useFakeBackupContainer(t)
+	request := BackupRequest{Project: "demo", Environment: "production", Volume: "pgdata", BackupID: "20261016-020000", Service: "postgres"}
+	if err := os.MkdirAll(backupDirectory(request), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(backupDirectory(request), backupArtifactFileName("pgdata", request.BackupID, BackupModePgBaseBackup)), []byte("base"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := RestoreVolumeBackup(context.Background(), request); err == nil || !strings.Contains(err.Error(), "tako backup restore --volume pgdata --at") {
+		t.Fatalf("in-place base backup restore = %v", err)
+	}
+}
+
+func TestShipPostgresWALUploadsAndRemovesSpooledFiles(t *testing.T) {
+	logPath := useFakeBackupContainer(t)
+	// The fake answers every exec alike, so the listing doubles as the
+	// content cat returns for each segment.
+	listing := "/var/lib/postgresql/data/pg_tako_wal\n16777216 000000010000000000000003\n41 00000002.history\n16777216 000000010000000000000004.tmp\n"
+	t.Setenv("TAKO_FAKE_DOCKER_EXEC_OUTPUT", listing)
+	root := t.TempDir()
+	storage := BackupStorageConfig{Provider: BackupStorageProviderFilesystem, Path: root, Prefix: "apps"}
+
+	shipped, spooled, err := shipPostgresWAL(context.Background(), BackupRequest{Project: "demo", Environment: "production", Service: "postgres", Volume: "pgdata", Storage: &storage})
+	if err != nil || shipped != 2 || spooled != 0 {
+		t.Fatalf("shipPostgresWAL = %d, %d, %v", shipped, spooled, err)
+	}
+	for _, name := range []string{"000000010000000000000003", "00000002.history"} {
+		data, err := os.ReadFile(filepath.Join(root, "apps/demo/production/pgdata/.tako-wal", name))
+		if err != nil || string(data) != listing {
+			t.Fatalf("archived %s = %q, %v", name, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "apps/demo/production/pgdata/.tako-wal/000000010000000000000004.tmp")); !os.IsNotExist(err) {
+		t.Fatalf("in-progress segment was shipped: %v", err)
+	}
+	commandIndex(t, readCommandLog(t, logPath), "docker exec demo_production_postgres_1 rm -f /var/lib/postgresql/data/pg_tako_wal/000000010000000000000003")
+}
+
+func TestShipPostgresWALEncryptsWALForChunkedStorage(t *testing.T) {
+	useFakeBackupContainer(t)
+	listing := "/var/lib/postgresql/data/pg_tako_wal\n16777216 000000010000000000000003\n"
+	t.Setenv("TAKO_FAKE_DOCKER_EXEC_OUTPUT", listing)
+	store := useMemoryBackupStore(t)
+	storage := chunkedTestStorage()
+
+	shipped, spooled, err := shipPostgresWAL(context.Background(), BackupRequest{Project: "demo", Environment: "production", Service: "postgres", Volume: "pgdata", Storage: &storage})
+	if err != nil || shipped != 1 || spooled != 0 {
+		t.Fatalf("shipPostgresWAL = %d, %d, %v", shipped, spooled, err)
+	}
+	sealed, err := store.Get(context.Background(), "apps/demo/production/pgdata/.tako-wal/000000010000000000000003")
+	if err != nil {
+		t.Fatalf("archived WAL: %v", err)
+	}
+	if strings.Contains(string(sealed), "pg_tako_wal") {
+		t.Fatalf("archived WAL is plaintext: %q", sealed)
+	}
+	repo, err := openChunkRepository(context.Background(), store, storage.EncryptionKey, chunkRepositoryRoot(storage.Prefix, "demo", "production", "pgdata"), false)
+	if err != nil {
+		t.Fatalf("openChunkRepository: %v", err)
+	}
+	if data, err := openWALFile(repo, "000000010000000000000003", sealed); err != nil || string(data) != listing {
+		t.Fatalf("openWALFile = %q, %v", data, err)
+	}
+	if _, err := openWALFile(repo, "000000010000000000000004", sealed); err == nil {
+		t.Fatal("archived WAL opened under another segment name")
+	}
+}
+
+func TestWALShipperAlertsOnceWhenTheSpoolBacksUp(t *testing.T) {
+	useFakeBackupContainer(t)
+	listing := "/var/lib/postgresql/data/pg_tako_wal\n"
+	for segment := 0; segment < 64; segment++ {
+		listing += fmt.Sprintf("16777216 0000000100000000000000%02X\n", segment)
+	}
+	t.Setenv("TAKO_FAKE_DOCKER_EXEC_OUTPUT", listing)
+	store := &memoryBackupStore{objects: make(map[string]memoryBackupObject)}
+	reachable := false
+	previous := newBackupObjectStore
+	newBackupObjectStore = func(context.Context, BackupStorageConfig) (backupObjectStore, error) {
+		if !reachable {
+			return nil, errors.New("bucket unreachable")
+		}
+		return store, nil
+	}
+	t.Cleanup(func() { newBackupObjectStore = previous })
+
+	scheduler := NewBackupScheduler(t.TempDir())
+	var events []notification.Event
+	scheduler.notify = func(targets JobNotifications, event notification.Event) error {
+		events = append(events, event)
+		return nil
+	}
+	request := BackupScheduleRequest{
+		Project:       "demo",
+		Environment:   "production",
+		Service:       "postgres",
+		Schedule:      "@daily",
+		Volumes:       []BackupScheduleVolume{{Volume: "pgdata"}},
+		Storage:       &BackupStorageConfig{Provider: BackupStorageProviderFilesystem, Path: t.TempDir()},
+		PITR:          true,
+		Notifications: &JobNotifications{Slack: "https://hooks.slack.test/T/B/X"},
+	}
+	scheduler.pitr[backupScheduleKey(request)] = request
+
+	scheduler.shipScheduledWAL(context.Background())
+	scheduler.shipScheduledWAL(context.Background())
+	if len(events) != 1 || events[0].Type != notification.EventBackupFailed || events[0].Details["volume"] != "pgdata" || events[0].Details["spooled_bytes"] != "1073741824" || !strings.Contains(events[0].Error, "bucket unreachable") {
+		t.Fatalf("events = %+v, want one backlog alert", events)
+	}
+
+	reachable = true
+	scheduler.shipScheduledWAL(context.Background())
+	reachable = false
+	scheduler.shipScheduledWAL(context.Background())
+	if len(events) != 2 || !strings.Contains(events[1].Message, "1024 MiB is waiting") {
+		t.Fatalf("events = %+v, want a second alert after the spool drained and backed up again", events)
+	}
+}
+
+func TestPITRSelectsChunkedBaseBackups(t *testing.T) {
+	prefix := "apps/demo/production/pgdata/"
+	objects := []backupStoreObject{
+		{Key: prefix + ".tako-chunks/config", LastModified: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+		{Key: prefix + "pgdata_20261015-020000.pgbase.tgz.snapshot", LastModified: time.Date(2026, 10, 15, 2, 1, 0, 0, time.UTC)},
+		{Key: prefix + ".tako-wal/000000010000000000000010", LastModified: time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)},
+		{Key: prefix + ".tako-wal/000000010000000000000020", LastModified: time.Date(2026, 10, 15, 3, 0, 0, 0, time.UTC)},
+	}
+	if baseID, err := selectPITRBaseBackup(objects, time.Date(2026, 10, 15, 14, 0, 0, 0, time.UTC)); err != nil || baseID != "20261015-020000" {
+		t.Fatalf("selectPITRBaseBackup = %q, %v", baseID, err)
+	}
+	if got := expiredWALArchiveKeys(objects); !reflect.DeepEqual(got, []string{prefix + ".tako-wal/000000010000000000000010"}) {
+		t.Fatalf("expiredWALArchiveKeys = %v", got)
+	}
+}
+
+func TestPITRSelectsBaseBackupAndWAL(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	prefix := "apps/demo/production/pgdata/"
+	objects := []backupStoreObject{
+		{Key: prefix + "pgdata_20261014-020000.pgbase.tgz", LastModified: at("2026-10-14T02:01:00Z")},
+		{Key: prefix + "pgdata_20261015-020000.pgbase.tgz", LastModified: at("2026-10-15T02:01:00Z")},
+		{Key: prefix + "pgdata_20261016-020000.pgbase.tgz", LastModified: at("2026-10-16T02:01:00Z")},
+		{Key: prefix + "pgdata_20261015-030000.pgdump", LastModified: at("2026-10-15T03:01:00Z")},
+		{Key: prefix + ".tako-wal/00000001.history", LastModified: at("2026-10-01T00:00:00Z")},
+		{Key: prefix + ".tako-wal/000000010000000000000010", LastModified: at("2026-10-13T12:00:00Z")},
+		{Key: prefix + ".tako-wal/000000010000000000000020", LastModified: at("2026-10-15T02:00:30Z")},
+		{Key: prefix + ".tako-wal/000000010000000000000021", LastModified: at("2026-10-15T14:02:00Z")},
+		{Key: prefix + ".tako-wal/000000010000000000000022", LastModified: at("2026-10-15T14:30:00Z")},
+		{Key: prefix + ".tako-wal/000000010000000000000023", LastModified: at("2026-10-15T16:00:00Z")},
+	}
+
+	target := at("2026-10-15T14:03:00Z")
+	baseID, err := selectPITRBaseBackup(objects, target)
+	if err != nil || baseID != "20261015-020000" {
+		t.Fatalf("selectPITRBaseBackup = %q, %v", baseID, err)
+	}
+	if _, err := selectPITRBaseBackup(objects, at("2026-10-13T00:00:00Z")); err == nil {
+		t.Fatal("selected a base backup taken after the target")
+	}
+	want := []string{
+		prefix + ".tako-wal/00000001.history",
+		prefix + ".tako-wal/000000010000000000000020",
+		prefix + ".tako-wal/000000010000000000000021",
+		prefix + ".tako-wal/000000010000000000000022",
+	}
+	if got := selectPITRWALSegments(objects, "000000010000000000000020", target); !reflect.DeepEqual(got, want) {
+		t.Fatalf("selectPITRWALSegments = %v, want %v", got, want)
+	}
+
+	if got := expiredWALArchiveKeys(objects); !reflect.DeepEqual(got, []string{prefix + ".tako-wal/000000010000000000000010"}) {
+		t.Fatalf("expiredWALArchiveKeys = %v", got)
+	}
+	for _, key := range expiredBackupObjectKeys(objects, BackupObjectRetention{RetentionDays: 1}, at("2026-10-16T12:00:00Z")) {
+		if isWALArchiveKey(key) {
+			t.Fatalf("backup retention expired archived WAL %s", key)
+		}
+	}
+}
+
+func TestReadBaseBackupStartSegment(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pgdata_20261015-020000.pgbase.tgz")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(file)
+	archive := tar.NewWriter(gz)
+	label := "START WAL LOCATION: 0/20000028 (file 000000010000000000000020)\nCHECKPOINT LOCATION: 0/20000060\n"
+	for name, content := range map[string]string{"PG_VERSION": "16\n", "backup_label": label} {
+		if err := archive.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := archive.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if segment, err := readBaseBackupStartSegment(path); err != nil || segment != "000000010000000000000020" {
+		t.Fatalf("readBaseBackupStartSegment = %q, %v", segment, err)
+	}
+}
+
+func TestValidatePITRRestoreRequest(t *testing.T) {
+	storage := &BackupStorageConfig{Provider: BackupStorageProviderFilesystem, Path: "/mnt/nas"}
+	valid := PITRRestoreRequest{
+		Project: "demo", Environment: "production", Service: "postgres", Volume: "pgdata",
+		TargetVolume: "tako_demo_production_pgdata-pitr", Target: time.Now().Add(-time.Hour), Storage: storage,
+	}
+	if err := validatePITRRestoreRequest(valid); err != nil {
+		t.Fatalf("validatePITRRestoreRequest returned error: %v", err)
+	}
+	chunked := valid
+	chunked.Storage = &BackupStorageConfig{Provider: BackupStorageProviderFilesystem, Path: "/mnt/nas", Format: BackupStorageFormatChunked, EncryptionKey: "correct horse battery staple"}
+	if err := validatePITRRestoreRequest(chunked); err != nil {
+		t.Fatalf("validatePITRRestoreRequest with chunked storage returned error: %v", err)
+	}
+	for _, tc := range []struct {
+		mutate  func(*PITRRestoreRequest)
+		wantErr string
+	}{
+		{func(r *PITRRestoreRequest) { r.Service = "" }, "service is required"},
+		{func(r *PITRRestoreRequest) { r.TargetVolume = "../data" }, "invalid target volume"},
+		{func(r *PITRRestoreRequest) { r.DockerVolume = "pgdata-live"; r.TargetVolume = "pgdata-live" }, "writes a new volume"},
+		{func(r *PITRRestoreRequest) { r.Target = time.Time{} }, "target time is required"},
+		{func(r *PITRRestoreRequest) { r.Target = time.Now().Add(time.Hour) }, "in the future"},
+		{func(r *PITRRestoreRequest) { r.Storage = nil }, "backup storage is required"},
+		{func(r *PITRRestoreRequest) { r.TimeoutSeconds = int((25 * time.Hour).Seconds()) }, "at most"},
+	} {
+		request := valid
+		tc.mutate(&request)
+		if err := validatePITRRestoreRequest(request); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Fatalf("error = %v, want substring %q", err, tc.wantErr)
+		}
+	}
+}
+
+func TestRestorePointInTimeRefusesExistingVolume(t *testing.T) {
+	useFakeBackupContainer(t)
+	_, err := RestorePointInTime(context.Background(), PITRRestoreRequest{
+		Project: "demo", Environment: "production", Service: "postgres", Volume: "pgdata",
+		TargetVolume: "tako_demo_production_pgdata-pitr", Target: time.Now().Add(-time.Hour),
+		Storage: &BackupStorageConfig{Provider: BackupStorageProviderFilesystem, Path: t.TempDir()},
+	})
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("RestorePointInTime into an existing volume = %v", err)
+	}
+}
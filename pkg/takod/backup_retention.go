@@ -0,0 +1,164 @@
+package takod
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxBackupRetentionBucket bounds each bucket; a year of hourlies is 8784.
+const maxBackupRetentionBucket = 10000
+
+// BackupRetentionPolicy keeps grandfather-father-son restore points: the
+// newest backup in each of the last Hourly hours, Daily days, Weekly ISO
+// weeks, Monthly months, and Yearly years that have one. A backup kept by any
+// bucket survives, and the newest backup is always kept. Periods are UTC.
+type BackupRetentionPolicy struct {
+	Hourly  int `json:"hourly,omitempty"`
+	Daily   int `json:"daily,omitempty"`
+	Weekly  int `json:"weekly,omitempty"`
+	Monthly int `json:"monthly,omitempty"`
+	Yearly  int `json:"yearly,omitempty"`
+}
+
+type retentionBucket struct {
+	name   string
+	count  int
+	period func(time.Time) string
+}
+
+func (p BackupRetentionPolicy) buckets() []retentionBucket {
+	return []retentionBucket{
+		{"hourly", p.Hourly, func(t time.Time) string { return t.Format("2006010215") }},
+		{"daily", p.Daily, func(t time.Time) string { return t.Format("20060102") }},
+		{"weekly", p.Weekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{"monthly", p.Monthly, func(t time.Time) string { return t.Format("200601") }},
+		{"yearly", p.Yearly, func(t time.Time) string { return t.Format("2006") }},
+	}
+}
+
+func validateBackupRetentionPolicy(policy *BackupRetentionPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	kept := 0
+	for _, bucket := range policy.buckets() {
+		if bucket.count < 0 || bucket.count > maxBackupRetentionBucket {
+			return fmt.Errorf("retention %s must be between 0 and %d", bucket.name, maxBackupRetentionBucket)
+		}
+		kept += bucket.count
+	}
+	if kept == 0 {
+		return fmt.Errorf("retention policy must keep at least one hourly, daily, weekly, monthly, or yearly backup")
+	}
+	return nil
+}
+
+// retentionCandidate is one backup of a volume. Backups without a timestamp
+// are always kept.
+type retentionCandidate struct {
+	ID        string
+	CreatedAt time.Time
+}
+
+// retainedBackupIDs returns the IDs of one volume's backups the policy keeps.
+func retainedBackupIDs(candidates []retentionCandidate, policy BackupRetentionPolicy) map[string]bool {
+	sorted := append([]retentionCandidate(nil), candidates...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].CreatedAt.Equal(sorted[j].CreatedAt) {
+			return sorted[i].ID > sorted[j].ID
+		}
+		return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+	})
+
+	keep := make(map[string]bool)
+	var dated []retentionCandidate
+	for _, candidate := range sorted {
+		if candidate.CreatedAt.IsZero() {
+			keep[candidate.ID] = true
+			continue
+		}
+		dated = append(dated, candidate)
+	}
+	if len(dated) == 0 {
+		return keep
+	}
+	keep[dated[0].ID] = true
+	for _, bucket := range policy.buckets() {
+		if bucket.count <= 0 {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, candidate := range dated {
+			period := bucket.period(candidate.CreatedAt.UTC())
+			if seen[period] {
+				continue
+			}
+			if len(seen) == bucket.count {
+				break
+			}
+			seen[period] = true
+			keep[candidate.ID] = true
+		}
+	}
+	return keep
+}
+
+// expiredBackupObjectKeys returns the stored backups retention removes:
+// those last modified before RetentionDays, or with a policy, those no bucket
+// keeps. Chunk repository objects and archived WAL are never returned, and
+// with a policy an object whose name is not a backup is left alone.
+func expiredBackupObjectKeys(objects []backupStoreObject, retention BackupObjectRetention, now time.Time) []string {
+	var expired []string
+	if retention.Policy == nil {
+		cutoff := now.AddDate(0, 0, -retention.RetentionDays)
+		for _, object := range objects {
+			if isChunkRepositoryKey(object.Key) || isWALArchiveKey(object.Key) || object.LastModified.After(cutoff) {
+				continue
+			}
+			expired = append(expired, object.Key)
+		}
+		return expired
+	}
+
+	type volumeObjects struct {
+		candidates []retentionCandidate
+		keys       map[string][]string
+	}
+	volumes := make(map[string]*volumeObjects)
+	for _, object := range objects {
+		if isChunkRepositoryKey(object.Key) || isWALArchiveKey(object.Key) {
+			continue
+		}
+		volume, backupID, _, err := parseBackupFileName(strings.TrimSuffix(path.Base(object.Key), chunkSnapshotSuffix))
+		if err != nil {
+			continue
+		}
+		group := path.Dir(object.Key) + "/" + volume
+		entry := volumes[group]
+		if entry == nil {
+			entry = &volumeObjects{keys: make(map[string][]string)}
+			volumes[group] = entry
+		}
+		if _, ok := entry.keys[backupID]; !ok {
+			createdAt, _ := backupIDTimestamp(backupID)
+			entry.candidates = append(entry.candidates, retentionCandidate{ID: backupID, CreatedAt: createdAt})
+		}
+		entry.keys[backupID] = append(entry.keys[backupID], object.Key)
+	}
+	for _, entry := range volumes {
+		keep := retainedBackupIDs(entry.candidates, *retention.Policy)
+		for backupID, keys := range entry.keys {
+			if !keep[backupID] {
+				expired = append(expired, keys...)
+			}
+		}
+	}
+	sort.Strings(expired)
+	return expired
+}
@@ -0,0 +1,136 @@
+package takod
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func hourlyRetentionCandidates(newest time.Time, count int) []retentionCandidate {
+	candidates := make([]retentionCandidate, 0, count)
+	for i := 0; i < count; i++ {
+		createdAt := newest.Add(-time.Duration(i) * time.Hour)
+		candidates = append(candidates, retentionCandidate{ID: createdAt.Format("20060102-150405"), CreatedAt: createdAt})
+	}
+	return candidates
+}
+
+func TestRetainedBackupIDsKeepsNewestBackupPerBucketPeriod(t *testing.T) {
+	newest := time.Date(2026, 10, 16, 23, 0, 0, 0, time.UTC)
+	candidates := hourlyRetentionCandidates(newest, 24*400)
+
+	keep := retainedBackupIDs(candidates, BackupRetentionPolicy{Hourly: 24, Daily: 14, Weekly: 8, Monthly: 12, Yearly: 2})
+
+	for _, want := range []string{
+		"20261016-230000", // newest
+		"20261016-000000", // the 24th hourly
+		"20261003-230000", // the 14th daily
+		"20260830-230000", // the 8th weekly (Sunday closing ISO week 35)
+		"20251130-230000", // the 12th monthly
+		"20251231-230000", // the 2nd yearly
+	} {
+		if !keep[want] {
+			t.Fatalf("retention dropped %s", want)
+		}
+	}
+	for _, drop := range []string{"20261015-220000", "20261002-230000", "20251031-230000", "20250930-230000"} {
+		if keep[drop] {
+			t.Fatalf("retention kept %s", drop)
+		}
+	}
+	// Buckets overlap, so the union never exceeds the sum of their slots.
+	if len(keep) > 24+14+8+12+2 {
+		t.Fatalf("kept %d backups, want at most one per bucket slot", len(keep))
+	}
+
+	keep = retainedBackupIDs([]retentionCandidate{
+		{ID: "20240101-020000", CreatedAt: time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC)},
+		{ID: "legacy"},
+	}, BackupRetentionPolicy{Yearly: 1})
+	if !keep["20240101-020000"] || !keep["legacy"] {
+		t.Fatalf("keep = %v, want the newest and undated backups kept", keep)
+	}
+}
+
+func TestCleanupOldBackupsAppliesRetentionPolicyPerVolume(t *testing.T) {
+	t.Cleanup(useTempBackupRoot(t))
+	request := BackupRequest{Project: "demo", Environment: "production"}
+	dir := backupDirectory(request)
+	var data []string
+	for _, id := range []string{"20261016-020000", "20261015-020000", "20261014-020000", "20260901-020000", "20250601-020000"} {
+		data = append(data, writeTestBackupArchive(t, dir, id, []byte(id)).Path)
+	}
+	if err := os.WriteFile(filepath.Join(dir, backupFileName("cache", "20250101-020000")), []byte("cache"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := CleanupOldBackups(context.Background(), BackupRequest{
+		Project:     "demo",
+		Environment: "production",
+		Volume:      "data",
+		Retention:   &BackupRetentionPolicy{Daily: 2, Monthly: 2, Yearly: 2},
+	})
+	if err != nil {
+		t.Fatalf("CleanupOldBackups returned error: %v", err)
+	}
+	if response.Deleted != 1 {
+		t.Fatalf("deleted %d backups, want only the second daily of the same month", response.Deleted)
+	}
+	for index, path := range data {
+		_, err := os.Stat(path)
+		if removed := os.IsNotExist(err); removed != (index == 2) {
+			t.Fatalf("%s removed = %v", filepath.Base(path), removed)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, backupFileName("cache", "20250101-020000"))); err != nil {
+		t.Fatalf("cleanup of data touched another volume: %v", err)
+	}
+}
+
+func TestCleanupBackupObjectsAppliesRetentionPolicyToArchives(t *testing.T) {
+	store := useMemoryBackupStore(t)
+	storage := chunkedTestStorage()
+	storage.Format = ""
+	storage.EncryptionKey = ""
+	for _, key := range []string{
+		"apps/demo/production/data/data_20261016-020000.tar.gz",
+		"apps/demo/production/data/data_20261015-020000.tar.gz",
+		"apps/demo/production/data/data_20250601-020000.tar.gz",
+		"apps/demo/production/data/notes.txt",
+		"apps/demo/production/pgdata/pgdata_20261015-020000.pgdump",
+	} {
+		if err := store.Put(context.Background(), key, []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.age(func(string) bool { return true }, 5*365*24*time.Hour)
+
+	err := CleanupBackupObjects(context.Background(), storage, BackupObjectRetention{
+		Project:     "demo",
+		Environment: "production",
+		Policy:      &BackupRetentionPolicy{Daily: 1, Yearly: 2},
+	})
+	if err != nil {
+		t.Fatalf("CleanupBackupObjects returned error: %v", err)
+	}
+	var keys []string
+	for key := range store.objects {
+		keys = append(keys, key)
+	}
+	got := strings.Join(keys, "\n")
+	for _, want := range []string{"data_20261016-020000", "data_20250601-020000", "notes.txt", "pgdata_20261015-020000"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("retention removed %s; left:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "/data_20261015-020000") {
+		t.Fatalf("retention kept a second daily of 2026; left:\n%s", got)
+	}
+
+	if err := CleanupBackupObjects(context.Background(), storage, BackupObjectRetention{Project: "demo", Environment: "production", Policy: &BackupRetentionPolicy{}}); err == nil || !strings.Contains(err.Error(), "at least one") {
+		t.Fatalf("empty policy = %v", err)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/redentordev/tako-cli/pkg/notification"
 	"github.com/redentordev/tako-cli/pkg/recovery"
 	"github.com/robfig/cron/v3"
 )
@@ -23,6 +24,26 @@ type BackupScheduleRequest struct {
 	RetentionDays int                    `json:"retentionDays,omitempty"`
 	Volumes       []BackupScheduleVolume `json:"volumes"`
 	Storage       *BackupStorageConfig   `json:"storage,omitempty"`
+	Mode          string                 `json:"mode,omitempty"`
+	Database      string                 `json:"database,omitempty"`
+	PreBackup     string                 `json:"preBackup,omitempty"`
+	PostBackup    string                 `json:"postBackup,omitempty"`
+	// Verify schedules restore drills of each volume's newest backup;
+	// Notifications receives an alert when a drill fails.
+	Verify        *BackupVerifySchedule `json:"verify,omitempty"`
+	Notifications *JobNotifications     `json:"notifications,omitempty"`
+	// Retention replaces RetentionDays with grandfather-father-son buckets.
+	Retention *BackupRetentionPolicy `json:"retention,omitempty"`
+	// PITR takes pg_basebackup base backups on Schedule and ships the WAL
+	// archived between them to Storage.
+	PITR bool `json:"pitr,omitempty"`
+}
+
+type BackupVerifySchedule struct {
+	Schedule       string `json:"schedule"`
+	Boot           bool   `json:"boot,omitempty"`
+	Check          string `json:"check,omitempty"`
+	TimeoutSeconds int    `json:"timeoutSeconds,omitempty"`
 }
 
 type BackupScheduleVolume struct {
@@ -44,10 +65,14 @@ type BackupScheduler struct {
 	parser  cron.Parser
 	cron    *cron.Cron
 	admit   func(...string) error
+	notify  func(targets JobNotifications, event notification.Event) error
 
 	mu      sync.Mutex
 	entries map[string]cron.EntryID
 	running map[string]bool
+	pitr    map[string]BackupScheduleRequest
+	// walBehind marks PITR schedules already alerted for a WAL backlog.
+	walBehind map[string]bool
 }
 
 func NewBackupScheduler(dataDir string) *BackupScheduler {
@@ -56,8 +81,12 @@ func NewBackupScheduler(dataDir string) *BackupScheduler {
 		dataDir: dataDir,
 		parser:  parser,
 		cron:    cron.New(cron.WithParser(parser), cron.WithChain(cron.Recover(cron.DefaultLogger))),
+		notify:  deliverJobNotification,
 		entries: map[string]cron.EntryID{},
 		running: map[string]bool{},
+		pitr:    map[string]BackupScheduleRequest{},
+
+		walBehind: map[string]bool{},
 	}
 }
 
@@ -69,6 +98,7 @@ func (s *BackupScheduler) Run(ctx context.Context) {
 		fmt.Fprintf(os.Stderr, "takod backup scheduler failed to load schedules: %v\n", err)
 	}
 	s.cron.Start()
+	go s.runWALShipper(ctx)
 	<-ctx.Done()
 	stopCtx := s.cron.Stop()
 	select {
@@ -123,10 +153,14 @@ func (s *BackupScheduler) Delete(ctx context.Context, project string, environmen
 		return nil, err
 	}
 	s.mu.Lock()
-	if entryID, ok := s.entries[backupScheduleKey(request)]; ok {
-		s.cron.Remove(entryID)
-		delete(s.entries, backupScheduleKey(request))
+	for _, key := range []string{backupScheduleKey(request), backupVerifyScheduleKey(request)} {
+		if entryID, ok := s.entries[key]; ok {
+			s.cron.Remove(entryID)
+			delete(s.entries, key)
+		}
 	}
+	delete(s.pitr, backupScheduleKey(request))
+	delete(s.walBehind, backupScheduleKey(request))
 	s.mu.Unlock()
 	if err := os.Remove(backupSchedulePath(s.dataDir, request)); err != nil && !os.IsNotExist(err) {
 		return nil, fmt.Errorf("failed to remove backup schedule: %w", err)
@@ -192,9 +226,12 @@ func (s *BackupScheduler) persistSchedule(request BackupScheduleRequest) error {
 
 func (s *BackupScheduler) scheduleLocked(request BackupScheduleRequest) error {
 	key := backupScheduleKey(request)
-	if entryID, ok := s.entries[key]; ok {
-		s.cron.Remove(entryID)
-		delete(s.entries, key)
+	verifyKey := backupVerifyScheduleKey(request)
+	for _, existing := range []string{key, verifyKey} {
+		if entryID, ok := s.entries[existing]; ok {
+			s.cron.Remove(entryID)
+			delete(s.entries, existing)
+		}
 	}
 	entryID, err := s.cron.AddFunc(request.Schedule, func() {
 		s.runScheduledBackup(request)
@@ -203,9 +240,77 @@ func (s *BackupScheduler) scheduleLocked(request BackupScheduleRequest) error {
 		return fmt.Errorf("failed to schedule backup: %w", err)
 	}
 	s.entries[key] = entryID
+	if request.PITR {
+		s.pitr[key] = request
+	} else {
+		delete(s.pitr, key)
+	}
+	if request.Verify == nil {
+		return nil
+	}
+	verifyID, err := s.cron.AddFunc(request.Verify.Schedule, func() {
+		s.runScheduledVerify(request)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to schedule backup verification: %w", err)
+	}
+	s.entries[verifyKey] = verifyID
 	return nil
 }
 
+// runScheduledVerify drills the newest backup of every scheduled volume,
+// from the off-node copy when the schedule stores one, and alerts on each
+// failed drill. A volume with no backup to drill alerts the same way.
+func (s *BackupScheduler) runScheduledVerify(request BackupScheduleRequest) {
+	key := backupVerifyScheduleKey(request)
+	s.mu.Lock()
+	if s.running[key] {
+		s.mu.Unlock()
+		fmt.Fprintf(os.Stderr, "takod backup verification skipped overlapping run: %s\n", key)
+		return
+	}
+	s.running[key] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, key)
+		s.mu.Unlock()
+	}()
+	for _, volume := range request.Volumes {
+		if s.admit != nil {
+			if err := s.admit(backupRootDir); err != nil {
+				fmt.Fprintf(os.Stderr, "takod backup verification denied by resource admission for %s volume %s: %v\n", key, volume.Volume, err)
+				continue
+			}
+		}
+		verification, err := VerifyVolumeBackup(context.Background(), BackupVerifyRequest{
+			Project:        request.Project,
+			Environment:    request.Environment,
+			Service:        request.Service,
+			Volume:         volume.Volume,
+			DockerVolume:   volume.DockerVolume,
+			Database:       request.Database,
+			Boot:           request.Verify.Boot,
+			Check:          request.Verify.Check,
+			TimeoutSeconds: request.Verify.TimeoutSeconds,
+			Storage:        request.Storage,
+		})
+		if err != nil {
+			verification = &BackupVerification{Volume: volume.Volume, Status: BackupVerifyFailed, Error: err.Error()}
+		}
+		if verification.Status == BackupVerifyPassed {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "takod backup verification failed for %s volume %s backup %s: %s\n", key, volume.Volume, verification.BackupID, verification.Error)
+		if request.Notifications != nil && s.notify != nil {
+			event := notification.BackupVerifyFailedEvent(request.Project, request.Environment, request.Service, volume.Volume, verification.BackupID, verification.Error)
+			if err := s.notify(*request.Notifications, event); err != nil {
+				fmt.Fprintf(os.Stderr, "takod backup verification alert failed for %s: %v\n", key, err)
+			}
+		}
+	}
+}
+
 func (s *BackupScheduler) runScheduledBackup(request BackupScheduleRequest) {
 	key := backupScheduleKey(request)
 	unlock, err := recovery.AcquireMutationLock(s.dataDir)
@@ -245,7 +350,14 @@ func (s *BackupScheduler) runScheduledBackup(request BackupScheduleRequest) {
 			ExternalVolume: volume.ExternalVolume,
 			BackupID:       backupID,
 			RetentionDays:  request.RetentionDays,
+			Retention:      request.Retention,
 			Storage:        request.Storage,
+			Service:        request.Service,
+			Mode:           request.Mode,
+			Database:       request.Database,
+			PreBackup:      request.PreBackup,
+			PostBackup:     request.PostBackup,
+			PITR:           request.PITR,
 		})
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "takod scheduled backup failed for %s/%s/%s volume %s: %v\n", request.Project, request.Environment, request.Service, volume.Volume, err)
@@ -255,7 +367,7 @@ func (s *BackupScheduler) runScheduledBackup(request BackupScheduleRequest) {
 				fmt.Fprintf(os.Stderr, "takod scheduled backup warning for %s/%s/%s volume %s: %s\n", request.Project, request.Environment, request.Service, volume.Volume, warning)
 			}
 		}
-		if request.RetentionDays <= 0 {
+		if request.RetentionDays <= 0 && request.Retention == nil {
 			continue
 		}
 		if _, err := CleanupOldBackups(ctx, BackupRequest{
@@ -263,6 +375,7 @@ func (s *BackupScheduler) runScheduledBackup(request BackupScheduleRequest) {
 			Environment:   request.Environment,
 			Volume:        volume.Volume,
 			RetentionDays: request.RetentionDays,
+			Retention:     request.Retention,
 		}); err != nil {
 			fmt.Fprintf(os.Stderr, "takod scheduled backup cleanup failed for %s/%s/%s volume %s: %v\n", request.Project, request.Environment, request.Service, volume.Volume, err)
 		}
@@ -270,7 +383,11 @@ func (s *BackupScheduler) runScheduledBackup(request BackupScheduleRequest) {
 }
 
 func normalizeBackupScheduleRequest(request BackupScheduleRequest) BackupScheduleRequest {
-	request.RetentionDays = normalizeRetentionDays(request.RetentionDays)
+	if request.Retention == nil {
+		request.RetentionDays = normalizeRetentionDays(request.RetentionDays)
+	} else {
+		request.RetentionDays = 0
+	}
 	if request.Storage != nil {
 		normalized := normalizeBackupStorage(*request.Storage)
 		request.Storage = &normalized
@@ -301,6 +418,20 @@ func validateBackupScheduleRequest(request BackupScheduleRequest) error {
 	if len(request.Volumes) == 0 {
 		return fmt.Errorf("at least one backup volume is required")
 	}
+	if err := validateBackupMode(BackupRequest{
+		Service:    request.Service,
+		Mode:       request.Mode,
+		Database:   request.Database,
+		PreBackup:  request.PreBackup,
+		PostBackup: request.PostBackup,
+		Storage:    request.Storage,
+		PITR:       request.PITR,
+	}); err != nil {
+		return err
+	}
+	if isDatabaseDumpMode(normalizeBackupMode(request.Mode)) && len(request.Volumes) != 1 {
+		return fmt.Errorf("backup mode %s requires exactly one volume", request.Mode)
+	}
 	for _, volume := range request.Volumes {
 		if !isSafeBackupVolume(volume.Volume) {
 			return fmt.Errorf("invalid backup volume")
@@ -314,7 +445,98 @@ func validateBackupScheduleRequest(request BackupScheduleRequest) error {
 			return err
 		}
 	}
-	return nil
+	if request.Verify != nil {
+		if _, err := parser.Parse(request.Verify.Schedule); err != nil {
+			return fmt.Errorf("invalid backup verification schedule: %w", err)
+		}
+		if err := validateBackupVerifyRequest(BackupVerifyRequest{
+			Project:        request.Project,
+			Environment:    request.Environment,
+			Service:        request.Service,
+			Volume:         request.Volumes[0].Volume,
+			Boot:           request.Verify.Boot,
+			Check:          request.Verify.Check,
+			TimeoutSeconds: request.Verify.TimeoutSeconds,
+		}); err != nil {
+			return err
+		}
+	}
+	if err := validateJobNotifications(request.Notifications); err != nil {
+		return err
+	}
+	return validateBackupRetentionPolicy(request.Retention)
+}
+
+// runWALShipper moves archived WAL of every PITR schedule into storage so the
+// recovery point trails writes by about archive_timeout plus the interval.
+func (s *BackupScheduler) runWALShipper(ctx context.Context) {
+	ticker := time.NewTicker(walShipInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.shipScheduledWAL(ctx)
+		}
+	}
+}
+
+func (s *BackupScheduler) shipScheduledWAL(ctx context.Context) {
+	s.mu.Lock()
+	requests := make([]BackupScheduleRequest, 0, len(s.pitr))
+	for _, request := range s.pitr {
+		requests = append(requests, request)
+	}
+	s.mu.Unlock()
+	for _, request := range requests {
+		volume := request.Volumes[0].Volume
+		_, spooled, err := shipPostgresWAL(ctx, BackupRequest{
+			Project:     request.Project,
+			Environment: request.Environment,
+			Service:     request.Service,
+			Volume:      volume,
+			Storage:     request.Storage,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "takod WAL shipping failed for %s: %v\n", backupScheduleKey(request), err)
+			if spooled == 0 {
+				continue
+			}
+		}
+		s.noteWALBacklog(request, volume, spooled, err)
+	}
+}
+
+// noteWALBacklog alerts once when WAL the shipper could not move piles up
+// past walSpoolAlertBytes in the database's data directory, where it fills
+// the same disk as the database, and rearms when the spool drains.
+func (s *BackupScheduler) noteWALBacklog(request BackupScheduleRequest, volume string, spooled int64, cause error) {
+	key := backupScheduleKey(request)
+	behind := spooled >= walSpoolAlertBytes
+	s.mu.Lock()
+	alerted := s.walBehind[key]
+	if behind {
+		s.walBehind[key] = true
+	} else {
+		delete(s.walBehind, key)
+	}
+	s.mu.Unlock()
+	if !behind || alerted {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "takod WAL shipping is behind for %s: %d bytes spooled\n", key, spooled)
+	if request.Notifications == nil || s.notify == nil {
+		return
+	}
+	reason := ""
+	if cause != nil {
+		reason = cause.Error()
+	}
+	event := notification.WALArchiveBehindEvent(request.Project, request.Environment, request.Service, volume, spooled, reason)
+	if err := s.notify(*request.Notifications, event); err != nil {
+		fmt.Fprintf(os.Stderr, "takod WAL backlog alert failed for %s: %v\n", key, err)
+	}
 }
 
 func normalizeRetentionDays(retentionDays int) int {
@@ -331,3 +553,7 @@ func backupSchedulePath(dataDir string, request BackupScheduleRequest) string {
 func backupScheduleKey(request BackupScheduleRequest) string {
 	return request.Project + "/" + request.Environment + "/" + request.Service
 }
+
+func backupVerifyScheduleKey(request BackupScheduleRequest) string {
+	return backupScheduleKey(request) + "#verify"
+}
@@ -127,3 +127,18 @@ func testBackupScheduleRequest() BackupScheduleRequest {
 		},
 	}
 }
+
+func TestBackupSchedulerRejectsDumpModeAcrossVolumes(t *testing.T) {
+	scheduler := NewBackupScheduler(t.TempDir())
+	request := testBackupScheduleRequest()
+	request.Mode = BackupModePgDump
+	request.Volumes = append(request.Volumes, BackupScheduleVolume{Volume: "extra", DockerVolume: "demo_production_extra"})
+
+	if _, err := scheduler.Upsert(context.Background(), request); err == nil || !strings.Contains(err.Error(), "exactly one volume") {
+		t.Fatalf("Upsert error = %v, want single-volume guidance", err)
+	}
+	request.Volumes = request.Volumes[:1]
+	if _, err := scheduler.Upsert(context.Background(), request); err != nil {
+		t.Fatalf("Upsert returned error: %v", err)
+	}
+}
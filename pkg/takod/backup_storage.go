@@ -4,11 +4,14 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/url"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -23,6 +26,14 @@ const (
 	BackupStorageProviderS3           = "s3"
 	BackupStorageProviderR2           = "r2"
 	BackupStorageProviderS3Compatible = "s3-compatible"
+	BackupStorageProviderSFTP         = "sftp"
+	BackupStorageProviderFilesystem   = "filesystem"
+	BackupStorageProviderPeer         = "peer"
+
+	BackupStorageFormatArchive = "archive"
+	BackupStorageFormatChunked = "chunked"
+
+	minBackupEncryptionKeyLength = 16
 )
 
 type BackupObject struct {
@@ -39,6 +50,9 @@ type BackupObjectRetention struct {
 	Environment   string
 	Volume        string
 	RetentionDays int
+	// Policy replaces RetentionDays with grandfather-father-son buckets
+	// keyed on each backup's ID timestamp.
+	Policy *BackupRetentionPolicy
 }
 
 type BackupObjectInfo struct {
@@ -48,7 +62,7 @@ type BackupObjectInfo struct {
 
 var (
 	uploadBackupObjectS3With = uploadBackupObjectS3
-	cleanupBackupObjectsWith = cleanupBackupObjectsS3
+	cleanupBackupObjectsWith = cleanupBackupObjectsInStore
 	downloadBackupObjectWith = downloadBackupObjectS3
 )
 
@@ -70,6 +84,9 @@ func DownloadBackupObjectExact(ctx context.Context, storage BackupStorageConfig,
 	if expectedSize <= 0 {
 		return fmt.Errorf("backup download expected size is invalid")
 	}
+	if !isS3BackupStorage(storage.Provider) {
+		return downloadBackupObjectFromStore(ctx, storage, key, destination, expectedSize)
+	}
 	return downloadBackupObjectWith(ctx, storage, key, destination, expectedSize)
 }
 
@@ -82,6 +99,9 @@ func InspectBackupObject(ctx context.Context, storage BackupStorageConfig, key s
 	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "..") || hasControlChars(key) {
 		return nil, fmt.Errorf("backup object key is invalid")
 	}
+	if !isS3BackupStorage(storage.Provider) {
+		return inspectBackupObjectInStore(ctx, storage, key)
+	}
 	client, err := backupS3Client(ctx, storage)
 	if err != nil {
 		return nil, err
@@ -111,6 +131,9 @@ func HashBackupObjectExact(ctx context.Context, storage BackupStorageConfig, key
 	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "..") || hasControlChars(key) || expectedSize <= 0 {
 		return "", fmt.Errorf("backup object key or expected size is invalid")
 	}
+	if !isS3BackupStorage(storage.Provider) {
+		return hashBackupObjectInStore(ctx, storage, key, expectedSize)
+	}
 	client, err := backupS3Client(ctx, storage)
 	if err != nil {
 		return "", err
@@ -142,6 +165,12 @@ func UploadBackupObject(ctx context.Context, storage BackupStorageConfig, object
 	if err := validateBackupObject(object); err != nil {
 		return nil, err
 	}
+	if storage.Format == BackupStorageFormatChunked {
+		return uploadChunkedBackupWith(ctx, storage, object)
+	}
+	if !isS3BackupStorage(storage.Provider) {
+		return uploadBackupObjectToStore(ctx, storage, object)
+	}
 	return uploadBackupObjectS3With(ctx, storage, object)
 }
 
@@ -150,9 +179,12 @@ func CleanupBackupObjects(ctx context.Context, storage BackupStorageConfig, rete
 	if err := ValidateBackupStorage(storage); err != nil {
 		return err
 	}
-	if retention.RetentionDays <= 0 {
+	if retention.RetentionDays <= 0 && retention.Policy == nil {
 		return nil
 	}
+	if err := validateBackupRetentionPolicy(retention.Policy); err != nil {
+		return err
+	}
 	if !isSafeProjectName(retention.Project) {
 		return fmt.Errorf("invalid project name")
 	}
@@ -162,16 +194,57 @@ func CleanupBackupObjects(ctx context.Context, storage BackupStorageConfig, rete
 	if retention.Volume != "" && !isSafeBackupVolume(retention.Volume) {
 		return fmt.Errorf("invalid volume name")
 	}
+	if storage.Format == BackupStorageFormatChunked {
+		return cleanupChunkedBackupsWith(ctx, storage, retention)
+	}
 	return cleanupBackupObjectsWith(ctx, storage, retention)
 }
 
 func ValidateBackupStorage(storage BackupStorageConfig) error {
 	storage = normalizeBackupStorage(storage)
+	var err error
 	switch storage.Provider {
 	case BackupStorageProviderS3, BackupStorageProviderR2, BackupStorageProviderS3Compatible:
+		err = validateS3BackupStorage(storage)
+	case BackupStorageProviderFilesystem:
+		err = validateFilesystemBackupStorage(storage)
+	case BackupStorageProviderSFTP:
+		err = validateSFTPBackupStorage(storage)
+	case BackupStorageProviderPeer:
+		err = validatePeerBackupStorage(storage)
+	default:
+		return fmt.Errorf("backup storage provider must be s3, r2, s3-compatible, sftp, filesystem, or peer")
+	}
+	if err != nil {
+		return err
+	}
+	for label, value := range map[string]string{
+		"backup storage prefix":        storage.Prefix,
+		"backup storage encryptionKey": storage.EncryptionKey,
+	} {
+		if hasControlChars(value) {
+			return fmt.Errorf("%s contains unsupported characters", label)
+		}
+	}
+	if strings.Contains(storage.Prefix, "..") {
+		return fmt.Errorf("backup storage prefix must not contain '..'")
+	}
+	switch storage.Format {
+	case "":
+		if storage.EncryptionKey != "" {
+			return fmt.Errorf("backup storage encryptionKey requires the chunked format")
+		}
+	case BackupStorageFormatChunked:
+		if len(storage.EncryptionKey) < minBackupEncryptionKeyLength {
+			return fmt.Errorf("backup storage encryptionKey must be at least %d characters for chunked backups", minBackupEncryptionKeyLength)
+		}
 	default:
-		return fmt.Errorf("backup storage provider must be s3, r2, or s3-compatible")
+		return fmt.Errorf("backup storage format must be archive or chunked")
 	}
+	return nil
+}
+
+func validateS3BackupStorage(storage BackupStorageConfig) error {
 	if storage.Bucket == "" {
 		return fmt.Errorf("backup storage bucket is required")
 	}
@@ -206,14 +279,52 @@ func ValidateBackupStorage(storage BackupStorageConfig) error {
 		"backup storage accessKeyId":     storage.AccessKeyID,
 		"backup storage secretAccessKey": storage.SecretAccessKey,
 		"backup storage sessionToken":    storage.SessionToken,
-		"backup storage prefix":          storage.Prefix,
 	} {
 		if hasControlChars(value) {
 			return fmt.Errorf("%s contains unsupported characters", label)
 		}
 	}
-	if strings.Contains(storage.Prefix, "..") {
-		return fmt.Errorf("backup storage prefix must not contain '..'")
+	return nil
+}
+
+func validateFilesystemBackupStorage(storage BackupStorageConfig) error {
+	if storage.Path == "" {
+		return fmt.Errorf("backup storage path is required for filesystem")
+	}
+	if !filepath.IsAbs(storage.Path) || filepath.Clean(storage.Path) != storage.Path || storage.Path == "/" || hasControlChars(storage.Path) {
+		return fmt.Errorf("backup storage path must be a clean absolute directory")
+	}
+	return nil
+}
+
+func validateSFTPBackupStorage(storage BackupStorageConfig) error {
+	if net.ParseIP(storage.Host) == nil && !isSafeRuntimeHost(storage.Host) {
+		return fmt.Errorf("backup storage host is required for sftp")
+	}
+	if storage.Port < 0 || storage.Port > 65535 {
+		return fmt.Errorf("backup storage port must be between 1 and 65535")
+	}
+	if storage.User == "" || hasControlChars(storage.User) || strings.ContainsAny(storage.User, " @:") {
+		return fmt.Errorf("backup storage user is required for sftp")
+	}
+	if storage.PrivateKey == "" && storage.Password == "" {
+		return fmt.Errorf("backup storage privateKey or password is required for sftp")
+	}
+	if storage.HostKey == "" || hasControlChars(storage.HostKey) {
+		return fmt.Errorf("backup storage hostKey is required for sftp")
+	}
+	if hasControlChars(storage.Password) || strings.Contains(storage.Path, "..") || hasControlChars(storage.Path) {
+		return fmt.Errorf("backup storage sftp settings contain unsupported characters")
+	}
+	return nil
+}
+
+func validatePeerBackupStorage(storage BackupStorageConfig) error {
+	if storage.Node == "" {
+		return fmt.Errorf("backup storage node is required for peer")
+	}
+	if !isSafeRuntimeName(storage.Node) {
+		return fmt.Errorf("backup storage node is invalid")
 	}
 	return nil
 }
@@ -233,6 +344,22 @@ func normalizeBackupStorage(storage BackupStorageConfig) BackupStorageConfig {
 	storage.AccessKeyID = strings.TrimSpace(storage.AccessKeyID)
 	storage.SecretAccessKey = strings.TrimSpace(storage.SecretAccessKey)
 	storage.SessionToken = strings.TrimSpace(storage.SessionToken)
+	storage.Format = strings.TrimSpace(storage.Format)
+	if storage.Format == BackupStorageFormatArchive {
+		storage.Format = ""
+	}
+	storage.EncryptionKey = strings.TrimSpace(storage.EncryptionKey)
+	storage.Path = strings.TrimSpace(storage.Path)
+	if storage.Provider == BackupStorageProviderSFTP {
+		storage.Path = strings.TrimRight(storage.Path, "/")
+		if storage.Port == 0 {
+			storage.Port = 22
+		}
+	}
+	storage.Host = strings.TrimSpace(storage.Host)
+	storage.User = strings.TrimSpace(storage.User)
+	storage.HostKey = strings.TrimSpace(storage.HostKey)
+	storage.Node = strings.TrimSpace(storage.Node)
 	return storage
 }
 
@@ -268,6 +395,9 @@ func uploadBackupObjectS3(ctx context.Context, storage BackupStorageConfig, obje
 
 	key := backupObjectKey(storage.Prefix, object)
 	contentType := "application/gzip"
+	if format, ok := backupArtifactFormatForFile(object.Path); ok && format.compression != "gzip" {
+		contentType = "application/octet-stream"
+	}
 	if strings.HasSuffix(object.Path, ".tako-recovery") {
 		contentType = "application/octet-stream"
 	}
@@ -339,42 +469,20 @@ func downloadBackupObjectS3(ctx context.Context, storage BackupStorageConfig, ke
 	return nil
 }
 
-func cleanupBackupObjectsS3(ctx context.Context, storage BackupStorageConfig, retention BackupObjectRetention) error {
-	client, err := backupS3Client(ctx, storage)
+func cleanupBackupObjectsInStore(ctx context.Context, storage BackupStorageConfig, retention BackupObjectRetention) error {
+	store, err := newBackupObjectStore(ctx, storage)
 	if err != nil {
 		return err
 	}
-	prefix := backupObjectRetentionPrefix(storage.Prefix, retention)
-	cutoff := time.Now().UTC().AddDate(0, 0, -retention.RetentionDays)
-	var continuation *string
-	for {
-		response, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
-			Bucket:            aws.String(storage.Bucket),
-			Prefix:            aws.String(prefix),
-			ContinuationToken: continuation,
-		})
-		if err != nil {
-			return fmt.Errorf("failed to list object backups: %w", err)
-		}
-
-		var expired []types.ObjectIdentifier
-		for _, object := range response.Contents {
-			if object.Key == nil || object.LastModified == nil {
-				continue
-			}
-			if object.LastModified.UTC().After(cutoff) {
-				continue
-			}
-			expired = append(expired, types.ObjectIdentifier{Key: object.Key})
-		}
-		if err := deleteBackupObjectBatch(ctx, client, storage.Bucket, expired); err != nil {
-			return err
-		}
-		if response.IsTruncated == nil || !*response.IsTruncated {
-			return nil
-		}
-		continuation = response.NextContinuationToken
+	defer store.Close()
+	objects, err := store.List(ctx, backupObjectRetentionPrefix(storage.Prefix, retention))
+	if err != nil {
+		return fmt.Errorf("failed to list object backups: %w", err)
 	}
+	if err := store.Delete(ctx, expiredBackupObjectKeys(objects, retention, time.Now().UTC())); err != nil {
+		return fmt.Errorf("failed to delete old object backups: %w", err)
+	}
+	return nil
 }
 
 func deleteBackupObjectBatch(ctx context.Context, client *s3.Client, bucket string, objects []types.ObjectIdentifier) error {
@@ -422,11 +530,20 @@ func backupObjectKey(prefix string, object BackupObject) string {
 		object.Project,
 		object.Environment,
 		object.Volume,
-		backupFileName(object.Volume, object.BackupID),
+		backupObjectFileName(object),
 	}
 	return joinObjectKey(parts...)
 }
 
+// backupObjectFileName keeps a database dump's suffix in its object key;
+// everything else, including recovery bundles, is filed under the archive name.
+func backupObjectFileName(object BackupObject) string {
+	if format, ok := backupArtifactFormatForFile(path.Base(object.Path)); ok {
+		return backupArtifactFileName(object.Volume, object.BackupID, format.mode)
+	}
+	return backupFileName(object.Volume, object.BackupID)
+}
+
 func backupObjectRetentionPrefix(prefix string, retention BackupObjectRetention) string {
 	parts := []string{
 		cleanObjectKeyPrefix(prefix),
@@ -462,3 +579,53 @@ func joinObjectKey(parts ...string) string {
 	}
 	return path.Join(cleaned...)
 }
+
+// fetchBackupFromStorage downloads req.BackupID for req.Volume into the local
+// backup directory so a node that lost its local copy can still restore it.
+func fetchBackupFromStorage(ctx context.Context, req BackupRequest) (string, error) {
+	return fetchBackupFromStorageInto(ctx, req, backupDirectory(req))
+}
+
+// fetchBackupFromStorageInto downloads req.BackupID for req.Volume into dir.
+func fetchBackupFromStorageInto(ctx context.Context, req BackupRequest, dir string) (string, error) {
+	storage := normalizeBackupStorage(*req.Storage)
+	if err := ValidateBackupStorage(storage); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	if storage.Format == BackupStorageFormatChunked {
+		return fetchChunkedBackup(ctx, req, dir)
+	}
+	for _, format := range backupArtifactFormats {
+		fileName := backupArtifactFileName(req.Volume, req.BackupID, format.mode)
+		key := backupObjectKey(storage.Prefix, BackupObject{
+			Project:     req.Project,
+			Environment: req.Environment,
+			Volume:      req.Volume,
+			BackupID:    req.BackupID,
+			Path:        fileName,
+		})
+		info, err := InspectBackupObject(ctx, storage, key)
+		if err != nil {
+			var missing *types.NotFound
+			if errors.As(err, &missing) || errors.Is(err, errBackupObjectNotFound) {
+				continue
+			}
+			return "", err
+		}
+		destination := filepath.Join(dir, fileName)
+		partial := destination + ".partial"
+		_ = os.Remove(partial)
+		if err := DownloadBackupObjectExact(ctx, storage, key, partial, info.Size); err != nil {
+			return "", err
+		}
+		if err := os.Rename(partial, destination); err != nil {
+			_ = os.Remove(partial)
+			return "", err
+		}
+		return destination, nil
+	}
+	return "", fmt.Errorf("backup %s not found in object storage", req.BackupID)
+}
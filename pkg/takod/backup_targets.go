@@ -0,0 +1,530 @@
+package takod
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	takossh "github.com/redentordev/tako-cli/pkg/ssh"
+)
+
+// backupArchiveStore streams whole archives, which can be far larger than the
+// objects a backupObjectStore holds in memory. Every provider except the S3
+// family, which keeps its own upload metadata, goes through it.
+type backupArchiveStore interface {
+	backupObjectStore
+	// Stat returns errBackupObjectNotFound for a missing key.
+	Stat(ctx context.Context, key string) (*BackupObjectInfo, error)
+	Download(ctx context.Context, key string, destination io.Writer) (int64, error)
+	Upload(ctx context.Context, key string, localPath string) error
+}
+
+var newBackupArchiveStore = openBackupArchiveStore
+
+func isS3BackupStorage(provider string) bool {
+	switch provider {
+	case BackupStorageProviderS3, BackupStorageProviderR2, BackupStorageProviderS3Compatible:
+		return true
+	}
+	return false
+}
+
+func openBackupObjectStore(ctx context.Context, storage BackupStorageConfig) (backupObjectStore, error) {
+	if isS3BackupStorage(storage.Provider) {
+		return newS3BackupObjectStore(ctx, storage)
+	}
+	return newBackupArchiveStore(ctx, storage)
+}
+
+func openBackupArchiveStore(ctx context.Context, storage BackupStorageConfig) (backupArchiveStore, error) {
+	switch storage.Provider {
+	case BackupStorageProviderFilesystem:
+		return newFilesystemBackupObjectStore(storage.Path)
+	case BackupStorageProviderSFTP:
+		return newSFTPBackupObjectStore(ctx, storage)
+	case BackupStorageProviderPeer:
+		return newPeerBackupObjectStore(storage.Node)
+	}
+	return nil, fmt.Errorf("backup storage provider %s does not support archive streaming", storage.Provider)
+}
+
+// backupStorageLocation names where a non-S3 target keeps objects, shown in
+// place of a bucket.
+func backupStorageLocation(storage BackupStorageConfig) string {
+	switch storage.Provider {
+	case BackupStorageProviderFilesystem:
+		return storage.Path
+	case BackupStorageProviderSFTP:
+		return fmt.Sprintf("%s@%s:%d/%s", storage.User, storage.Host, storage.Port, strings.TrimPrefix(storage.Path, "/"))
+	case BackupStorageProviderPeer:
+		return storage.Node
+	}
+	return storage.Endpoint
+}
+
+func uploadBackupObjectToStore(ctx context.Context, storage BackupStorageConfig, object BackupObject) (*BackupRemoteInfo, error) {
+	store, err := newBackupArchiveStore(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	key := backupObjectKey(storage.Prefix, object)
+	if err := store.Upload(ctx, key, object.Path); err != nil {
+		return nil, fmt.Errorf("failed to upload backup to %s storage: %w", storage.Provider, err)
+	}
+	return &BackupRemoteInfo{
+		Provider: storage.Provider,
+		Key:      key,
+		Endpoint: backupStorageLocation(storage),
+	}, nil
+}
+
+func inspectBackupObjectInStore(ctx context.Context, storage BackupStorageConfig, key string) (*BackupObjectInfo, error) {
+	store, err := newBackupArchiveStore(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	info, err := store.Stat(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect backup object: %w", err)
+	}
+	return info, nil
+}
+
+func hashBackupObjectInStore(ctx context.Context, storage BackupStorageConfig, key string, expectedSize int64) (string, error) {
+	store, err := newBackupArchiveStore(ctx, storage)
+	if err != nil {
+		return "", err
+	}
+	defer store.Close()
+	info, err := store.Stat(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect backup object for hashing: %w", err)
+	}
+	if info.Size != expectedSize {
+		return "", fmt.Errorf("backup object size does not match evidence")
+	}
+	hash := sha256.New()
+	written, err := store.Download(ctx, key, &limitedWriter{writer: hash, remaining: expectedSize})
+	if err != nil {
+		return "", err
+	}
+	if written != expectedSize {
+		return "", fmt.Errorf("backup object body size does not match evidence")
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func downloadBackupObjectFromStore(ctx context.Context, storage BackupStorageConfig, key, destination string, expectedSize int64) error {
+	store, err := newBackupArchiveStore(ctx, storage)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	info, err := store.Stat(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to download backup object: %w", err)
+	}
+	if expectedSize > 0 && info.Size != expectedSize {
+		return fmt.Errorf("downloaded backup object size does not match expected upload")
+	}
+	file, err := os.OpenFile(destination, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	failed := true
+	defer func() {
+		_ = file.Close()
+		if failed {
+			_ = os.Remove(destination)
+		}
+	}()
+	var writer io.Writer = file
+	if expectedSize > 0 {
+		writer = &limitedWriter{writer: file, remaining: expectedSize}
+	}
+	written, err := store.Download(ctx, key, writer)
+	if err != nil {
+		return fmt.Errorf("download backup object body: %w", err)
+	}
+	if expectedSize > 0 && written != expectedSize {
+		return fmt.Errorf("downloaded backup object body size does not match expected upload")
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	failed = false
+	return nil
+}
+
+// limitedWriter fails once more than remaining bytes arrive, bounding disk
+// use when a target serves an object larger than it reported.
+type limitedWriter struct {
+	writer    io.Writer
+	remaining int64
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > w.remaining {
+		return 0, fmt.Errorf("backup object is larger than expected")
+	}
+	n, err := w.writer.Write(p)
+	w.remaining -= int64(n)
+	return n, err
+}
+
+// filesystemBackupObjectStore keeps objects as files under root, a mounted
+// NAS path or a peer's replica directory.
+type filesystemBackupObjectStore struct {
+	root string
+}
+
+// newFilesystemBackupObjectStore refuses a root that does not exist, so an
+// unmounted NAS fails the backup instead of filling the node's own disk.
+func newFilesystemBackupObjectStore(root string) (*filesystemBackupObjectStore, error) {
+	info, err := os.Stat(root)
+	if err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("backup storage path %s is not a mounted directory", root)
+	}
+	return &filesystemBackupObjectStore{root: root}, nil
+}
+
+func (s *filesystemBackupObjectStore) path(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "..") || hasControlChars(key) {
+		return "", fmt.Errorf("backup object key is invalid")
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+func (s *filesystemBackupObjectStore) Get(_ context.Context, key string) ([]byte, error) {
+	filePath, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errBackupObjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxBackupStoreObjectBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxBackupStoreObjectBytes {
+		return nil, fmt.Errorf("backup object %s is too large", key)
+	}
+	return data, nil
+}
+
+func (s *filesystemBackupObjectStore) Put(_ context.Context, key string, data []byte) error {
+	filePath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0750); err != nil {
+		return err
+	}
+	return writeFileAtomic(filePath, data, 0600)
+}
+
+// List skips dot files, which are uploads still being written.
+func (s *filesystemBackupObjectStore) List(_ context.Context, prefix string) ([]backupStoreObject, error) {
+	if strings.HasPrefix(prefix, "/") || strings.Contains(prefix, "..") || hasControlChars(prefix) {
+		return nil, fmt.Errorf("backup object prefix is invalid")
+	}
+	dir := s.root
+	if index := strings.LastIndex(prefix, "/"); index > 0 {
+		dir = filepath.Join(s.root, filepath.FromSlash(prefix[:index]))
+	}
+	var objects []backupStoreObject
+	err := filepath.WalkDir(dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || !entry.Type().IsRegular() {
+			return nil
+		}
+		relative, err := filepath.Rel(s.root, filePath)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relative)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, backupStoreObject{Key: key, Size: info.Size(), LastModified: info.ModTime().UTC()})
+		return nil
+	})
+	return objects, err
+}
+
+func (s *filesystemBackupObjectStore) Delete(_ context.Context, keys []string) error {
+	for _, key := range keys {
+		filePath, err := s.path(key)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(filePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *filesystemBackupObjectStore) Stat(_ context.Context, key string) (*BackupObjectInfo, error) {
+	filePath, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errBackupObjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &BackupObjectInfo{Size: info.Size(), LastModified: info.ModTime().UTC()}, nil
+}
+
+func (s *filesystemBackupObjectStore) Download(_ context.Context, key string, destination io.Writer) (int64, error) {
+	filePath, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+	file, err := os.Open(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, errBackupObjectNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return io.Copy(destination, file)
+}
+
+func (s *filesystemBackupObjectStore) Upload(_ context.Context, key string, localPath string) error {
+	source, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open backup for upload: %w", err)
+	}
+	defer source.Close()
+	return s.write(key, source, nil)
+}
+
+// write publishes body under key through a dot-prefixed temporary file.
+// verify, when set, runs after the body is synced and can reject it.
+func (s *filesystemBackupObjectStore) write(key string, body io.Reader, verify func() error) error {
+	filePath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0750); err != nil {
+		return err
+	}
+	temporary, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	published := false
+	defer func() {
+		if !published {
+			_ = temporary.Close()
+			_ = os.Remove(temporary.Name())
+		}
+	}()
+	if _, err := io.Copy(temporary, body); err != nil {
+		return err
+	}
+	if err := temporary.Chmod(0600); err != nil {
+		return err
+	}
+	if err := temporary.Sync(); err != nil {
+		return err
+	}
+	if err := temporary.Close(); err != nil {
+		return err
+	}
+	if verify != nil {
+		if err := verify(); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(temporary.Name(), filePath); err != nil {
+		return err
+	}
+	published = true
+	return syncDirectory(filepath.Dir(filePath))
+}
+
+func (s *filesystemBackupObjectStore) Close() error { return nil }
+
+// sftpBackupObjectStore keeps objects under root on an SFTP server, pinned to
+// the configured host key.
+type sftpBackupObjectStore struct {
+	client *takossh.Client
+	sftp   *takossh.SFTPClient
+	root   string
+}
+
+func newSFTPBackupObjectStore(ctx context.Context, storage BackupStorageConfig) (*sftpBackupObjectStore, error) {
+	client, err := takossh.NewClientWithKeyData(storage.Host, storage.Port, storage.User, storage.PrivateKey, storage.Password, storage.HostKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure sftp backup storage: %w", err)
+	}
+	session, err := client.NewSFTP(ctx)
+	if err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to connect to sftp backup storage: %w", err)
+	}
+	return &sftpBackupObjectStore{client: client, sftp: session, root: storage.Path}, nil
+}
+
+func (s *sftpBackupObjectStore) path(key string) string {
+	if s.root == "" {
+		return key
+	}
+	return path.Join(s.root, key)
+}
+
+func (s *sftpBackupObjectStore) Get(ctx context.Context, key string) ([]byte, error) {
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size > maxBackupStoreObjectBytes {
+		return nil, fmt.Errorf("backup object %s is too large", key)
+	}
+	var data bytes.Buffer
+	if _, err := s.Download(ctx, key, &limitedWriter{writer: &data, remaining: maxBackupStoreObjectBytes}); err != nil {
+		return nil, err
+	}
+	return data.Bytes(), nil
+}
+
+func (s *sftpBackupObjectStore) Put(ctx context.Context, key string, data []byte) error {
+	return s.upload(ctx, key, bytes.NewReader(data))
+}
+
+func (s *sftpBackupObjectStore) upload(ctx context.Context, key string, body io.Reader) error {
+	remotePath := s.path(key)
+	if err := s.sftp.MkdirAll(path.Dir(remotePath)); err != nil {
+		return err
+	}
+	temporary := path.Join(path.Dir(remotePath), "."+path.Base(remotePath)+".tmp")
+	if err := s.sftp.Upload(ctx, temporary, body); err != nil {
+		_ = s.sftp.Remove(temporary)
+		return err
+	}
+	return s.sftp.Rename(temporary, remotePath)
+}
+
+// List walks the directory holding prefix, skipping in-flight dot files.
+func (s *sftpBackupObjectStore) List(_ context.Context, prefix string) ([]backupStoreObject, error) {
+	dir := ""
+	if index := strings.LastIndex(prefix, "/"); index > 0 {
+		dir = prefix[:index]
+	}
+	var objects []backupStoreObject
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		remoteDir := s.path(dir)
+		if remoteDir == "" {
+			remoteDir = "."
+		}
+		entries, err := s.sftp.ReadDir(remoteDir)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Name, ".") {
+				continue
+			}
+			key := joinObjectKey(dir, entry.Name)
+			if entry.IsDir {
+				if err := walk(key); err != nil {
+					return err
+				}
+				continue
+			}
+			if strings.HasPrefix(key, prefix) {
+				objects = append(objects, backupStoreObject{Key: key, Size: entry.Size, LastModified: entry.ModTime})
+			}
+		}
+		return nil
+	}
+	if err := walk(dir); err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+func (s *sftpBackupObjectStore) Delete(_ context.Context, keys []string) error {
+	for _, key := range keys {
+		if err := s.sftp.Remove(s.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sftpBackupObjectStore) Stat(_ context.Context, key string) (*BackupObjectInfo, error) {
+	info, err := s.sftp.Stat(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errBackupObjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &BackupObjectInfo{Size: info.Size, LastModified: info.ModTime}, nil
+}
+
+func (s *sftpBackupObjectStore) Download(ctx context.Context, key string, destination io.Writer) (int64, error) {
+	written, err := s.sftp.Download(ctx, s.path(key), destination)
+	if errors.Is(err, fs.ErrNotExist) {
+		return written, errBackupObjectNotFound
+	}
+	return written, err
+}
+
+func (s *sftpBackupObjectStore) Upload(ctx context.Context, key string, localPath string) error {
+	source, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open backup for upload: %w", err)
+	}
+	defer source.Close()
+	return s.upload(ctx, key, source)
+}
+
+func (s *sftpBackupObjectStore) Close() error {
+	err := s.sftp.Close()
+	if closeErr := s.client.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
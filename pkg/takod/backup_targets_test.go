@@ -0,0 +1,118 @@
+package takod
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateBackupStorageChecksTargetProviders(t *testing.T) {
+	hostKey := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIHc6X4dZk2mFJ8nV6W0o5P5d6t1iVtPGx2Y3dHo7y4mS"
+	valid := []BackupStorageConfig{
+		{Provider: BackupStorageProviderFilesystem, Path: "/mnt/nas/backups"},
+		{Provider: BackupStorageProviderSFTP, Host: "backup.example.com", User: "tako", Password: "secret", HostKey: hostKey, Path: "/srv/backups"},
+		{Provider: BackupStorageProviderSFTP, Host: "10.0.0.5", Port: 2222, User: "tako", PrivateKey: "key", HostKey: hostKey},
+		{Provider: BackupStorageProviderPeer, Node: "worker-2", Format: BackupStorageFormatChunked, EncryptionKey: "correct horse battery staple"},
+	}
+	for _, storage := range valid {
+		if err := ValidateBackupStorage(storage); err != nil {
+			t.Fatalf("ValidateBackupStorage(%+v) returned error: %v", storage, err)
+		}
+	}
+	if got := normalizeBackupStorage(valid[1]); got.Port != 22 {
+		t.Fatalf("sftp port = %d, want default 22", got.Port)
+	}
+
+	for _, tt := range []struct {
+		storage BackupStorageConfig
+		want    string
+	}{
+		{BackupStorageConfig{Provider: BackupStorageProviderFilesystem}, "path is required"},
+		{BackupStorageConfig{Provider: BackupStorageProviderFilesystem, Path: "mnt/nas"}, "clean absolute directory"},
+		{BackupStorageConfig{Provider: BackupStorageProviderFilesystem, Path: "/mnt/../etc"}, "clean absolute directory"},
+		{BackupStorageConfig{Provider: BackupStorageProviderFilesystem, Path: "/"}, "clean absolute directory"},
+		{BackupStorageConfig{Provider: BackupStorageProviderSFTP, User: "tako", Password: "secret", HostKey: hostKey}, "host is required"},
+		{BackupStorageConfig{Provider: BackupStorageProviderSFTP, Host: "backup.example.com", Password: "secret", HostKey: hostKey}, "user is required"},
+		{BackupStorageConfig{Provider: BackupStorageProviderSFTP, Host: "backup.example.com", User: "tako", HostKey: hostKey}, "privateKey or password"},
+		{BackupStorageConfig{Provider: BackupStorageProviderSFTP, Host: "backup.example.com", User: "tako", Password: "secret"}, "hostKey is required"},
+		{BackupStorageConfig{Provider: BackupStorageProviderSFTP, Host: "backup.example.com", User: "tako", Password: "secret", HostKey: hostKey, Port: 70000}, "port must be"},
+		{BackupStorageConfig{Provider: BackupStorageProviderPeer}, "node is required"},
+		{BackupStorageConfig{Provider: BackupStorageProviderPeer, Node: "../worker"}, "node is invalid"},
+		{BackupStorageConfig{Provider: "ftp"}, "provider must be"},
+	} {
+		if err := ValidateBackupStorage(tt.storage); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("ValidateBackupStorage(%+v) = %v, want %q", tt.storage, err, tt.want)
+		}
+	}
+}
+
+func TestFilesystemBackupStorageUploadsFetchesAndExpires(t *testing.T) {
+	root := t.TempDir()
+	storage := BackupStorageConfig{Provider: BackupStorageProviderFilesystem, Path: root, Prefix: "apps"}
+	dir := t.TempDir()
+	content := []byte("volume contents")
+
+	oldID := time.Now().UTC().AddDate(0, 0, -30).Format("20060102-150405")
+	remote, err := UploadBackupObject(context.Background(), storage, writeTestBackupArchive(t, dir, oldID, content))
+	if err != nil {
+		t.Fatalf("UploadBackupObject returned error: %v", err)
+	}
+	if remote.Provider != BackupStorageProviderFilesystem || remote.Endpoint != root || remote.Key != "apps/demo/production/data/"+backupFileName("data", oldID) {
+		t.Fatalf("remote = %+v", remote)
+	}
+	oldPath := filepath.Join(root, filepath.FromSlash(remote.Key))
+	if err := os.Chtimes(oldPath, time.Now().AddDate(0, 0, -30), time.Now().AddDate(0, 0, -30)); err != nil {
+		t.Fatal(err)
+	}
+	currentID := time.Now().UTC().Format("20060102-150405")
+	if _, err := UploadBackupObject(context.Background(), storage, writeTestBackupArchive(t, dir, currentID, content)); err != nil {
+		t.Fatalf("UploadBackupObject returned error: %v", err)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(root, "apps/demo/production/data/.*")); len(matches) != 0 {
+		t.Fatalf("temporary files left behind: %v", matches)
+	}
+
+	if err := CleanupBackupObjects(context.Background(), storage, BackupObjectRetention{Project: "demo", Environment: "production", Volume: "data", RetentionDays: 7}); err != nil {
+		t.Fatalf("CleanupBackupObjects returned error: %v", err)
+	}
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Fatalf("expired backup survived cleanup: %v", err)
+	}
+
+	t.Cleanup(useTempBackupRoot(t))
+	request := BackupRequest{Project: "demo", Environment: "production", Volume: "data", BackupID: currentID, Storage: &storage}
+	if _, err := fetchBackupFromStorage(context.Background(), request); err != nil {
+		t.Fatalf("fetchBackupFromStorage returned error: %v", err)
+	}
+	if restored := readTestBackupArchive(t, filepath.Join(backupDirectory(request), backupFileName("data", currentID))); !bytes.Equal(restored, content) {
+		t.Fatalf("fetched archive = %q, want %q", restored, content)
+	}
+	request.BackupID = oldID
+	if _, err := fetchBackupFromStorage(context.Background(), request); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("fetch of expired backup = %v", err)
+	}
+
+	storage.Path = filepath.Join(root, "unmounted")
+	if _, err := UploadBackupObject(context.Background(), storage, writeTestBackupArchive(t, dir, currentID, content)); err == nil || !strings.Contains(err.Error(), "not a mounted directory") {
+		t.Fatalf("upload to missing mount = %v", err)
+	}
+}
+
+func TestFilesystemBackupStoreRejectsEscapingKeys(t *testing.T) {
+	store, err := newFilesystemBackupObjectStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"", "/etc/passwd", "apps/../../etc/passwd", "apps/\x00"} {
+		if err := store.Put(context.Background(), key, []byte("x")); err == nil {
+			t.Fatalf("Put(%q) succeeded", key)
+		}
+	}
+	if _, err := store.List(context.Background(), "../"); err == nil {
+		t.Fatal("List escaped the storage root")
+	}
+}
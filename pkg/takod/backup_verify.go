@@ -0,0 +1,482 @@
+package takod
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	BackupVerifyPassed = "passed"
+	BackupVerifyFailed = "failed"
+
+	backupVerifyDirName        = ".verifications"
+	defaultBackupVerifyTimeout = 10 * time.Minute
+	maxBackupVerifyTimeout     = 6 * time.Hour
+	maxBackupVerifyCheckBytes  = 4096
+)
+
+// backupVerifyRetryInterval paces dump replays and checks while the booted
+// service starts up; tests shorten it.
+var backupVerifyRetryInterval = 5 * time.Second
+
+// BackupVerifyRequest asks for a restore drill of one volume's backup.
+// BackupID defaults to the newest local backup of Volume.
+type BackupVerifyRequest struct {
+	Project      string `json:"project"`
+	Environment  string `json:"environment"`
+	Service      string `json:"service,omitempty"`
+	Volume       string `json:"volume"`
+	DockerVolume string `json:"dockerVolume,omitempty"`
+	BackupID     string `json:"backupId,omitempty"`
+	Database     string `json:"database,omitempty"`
+	// Boot starts the running service's image and configuration against the
+	// restored volume with networking disabled; Check, when set, must then
+	// succeed inside it before TimeoutSeconds.
+	Boot           bool   `json:"boot,omitempty"`
+	Check          string `json:"check,omitempty"`
+	TimeoutSeconds int    `json:"timeoutSeconds,omitempty"`
+	// Storage, when set, drills the off-node copy: the backup is downloaded
+	// from it rather than read from the node's backup directory.
+	Storage *BackupStorageConfig `json:"storage,omitempty"`
+}
+
+// BackupVerification records the outcome of one restore drill. It is kept
+// next to the backup and reported by backup listings.
+type BackupVerification struct {
+	BackupID   string    `json:"backupId"`
+	Volume     string    `json:"volume"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	Booted     bool      `json:"booted,omitempty"`
+	Checked    bool      `json:"checked,omitempty"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+}
+
+// VerifyVolumeBackup restores a backup into a throwaway volume and records
+// whether it passed. Archives must extract cleanly and dumps must parse;
+// with Boot the service image must start on the restored data, replay a
+// dump, and pass Check. Only request errors and a missing backup are
+// returned as errors: a failed drill is a recorded verification, and so is
+// an off-node copy that is missing or does not download.
+func VerifyVolumeBackup(ctx context.Context, req BackupVerifyRequest) (*BackupVerification, error) {
+	if err := validateBackupVerifyRequest(req); err != nil {
+		return nil, err
+	}
+	backupReq := BackupRequest{
+		Project:      req.Project,
+		Environment:  req.Environment,
+		Volume:       req.Volume,
+		DockerVolume: req.DockerVolume,
+		BackupID:     req.BackupID,
+		Service:      req.Service,
+		Database:     req.Database,
+	}
+	if backupReq.BackupID == "" {
+		latest, err := latestVolumeBackup(ctx, backupReq)
+		if err != nil {
+			return nil, err
+		}
+		backupReq.BackupID = latest
+	}
+	path, format, release, artifactErr := backupVerifyArtifact(ctx, req, backupReq)
+	if artifactErr != nil && req.Storage == nil {
+		return nil, artifactErr
+	}
+
+	timeout := defaultBackupVerifyTimeout
+	if req.TimeoutSeconds > 0 {
+		timeout = time.Duration(req.TimeoutSeconds) * time.Second
+	}
+	drillCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	verification := &BackupVerification{
+		BackupID:  backupReq.BackupID,
+		Volume:    req.Volume,
+		Status:    BackupVerifyPassed,
+		StartedAt: time.Now().UTC(),
+	}
+	drillErr := artifactErr
+	if drillErr != nil {
+		drillErr = fmt.Errorf("off-node copy did not download: %w", drillErr)
+	} else {
+		defer release()
+		drillErr = runBackupDrill(drillCtx, req, backupReq, path, format, verification)
+	}
+	if drillErr != nil {
+		verification.Status = BackupVerifyFailed
+		verification.Error = drillErr.Error()
+	}
+	verification.FinishedAt = time.Now().UTC()
+	if err := writeBackupVerification(backupReq, verification); err != nil {
+		return nil, err
+	}
+	return verification, nil
+}
+
+// backupVerifyArtifact finds the backup a drill restores: the node's copy,
+// or with Storage a fresh download of the off-node copy into a scratch
+// directory that release removes.
+func backupVerifyArtifact(ctx context.Context, req BackupVerifyRequest, backupReq BackupRequest) (string, backupArtifactFormat, func(), error) {
+	if req.Storage == nil {
+		path, format, err := findBackupArtifact(backupDirectory(backupReq), req.Volume, backupReq.BackupID)
+		return path, format, func() {}, err
+	}
+	parent := filepath.Join(backupDirectory(backupReq), backupVerifyDirName)
+	if err := os.MkdirAll(parent, 0750); err != nil {
+		return "", backupArtifactFormat{}, nil, fmt.Errorf("failed to create verification directory: %w", err)
+	}
+	dir, err := os.MkdirTemp(parent, "storage-")
+	if err != nil {
+		return "", backupArtifactFormat{}, nil, fmt.Errorf("failed to create verification directory: %w", err)
+	}
+	release := func() { _ = os.RemoveAll(dir) }
+	fetchReq := backupReq
+	fetchReq.Storage = req.Storage
+	if _, err := fetchBackupFromStorageInto(ctx, fetchReq, dir); err != nil {
+		release()
+		return "", backupArtifactFormat{}, nil, err
+	}
+	path, format, err := findBackupArtifact(dir, req.Volume, backupReq.BackupID)
+	if err != nil {
+		release()
+		return "", backupArtifactFormat{}, nil, err
+	}
+	return path, format, release, nil
+}
+
+func runBackupDrill(ctx context.Context, req BackupVerifyRequest, backupReq BackupRequest, path string, format backupArtifactFormat, verification *BackupVerification) error {
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+	scratchVolume := "tako_verify_" + req.Project + "_" + req.Environment + "_" + req.Volume + "_" + suffix
+	if !isSafeDockerVolumeName(scratchVolume) {
+		return fmt.Errorf("verification volume name is too long")
+	}
+	if _, err := runDocker(ctx, "volume", "create", "--label", "tako.verify=true", scratchVolume); err != nil {
+		return fmt.Errorf("failed to create verification volume: %w", err)
+	}
+	defer func() {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		_, _ = runDocker(cleanupCtx, "volume", "rm", "-f", scratchVolume)
+	}()
+
+	dump := isDatabaseDumpMode(format.mode)
+	if format.mode == BackupModePgBaseBackup {
+		// A base backup is the cluster itself: it boots from the volume
+		// rather than replaying into a running service.
+		dump = false
+		if err := checkDatabaseDump(path, format.mode); err != nil {
+			return err
+		}
+		if req.Boot {
+			if err := extractVerificationBaseBackup(ctx, backupReq, path, scratchVolume); err != nil {
+				return err
+			}
+		}
+	} else if dump {
+		if err := checkDatabaseDump(path, format.mode); err != nil {
+			return err
+		}
+	} else if _, err := runDocker(
+		ctx,
+		"run", "--rm",
+		"--network", "none",
+		"-v", scratchVolume+":/target",
+		"-v", filepath.Dir(path)+":/backup:ro",
+		backupImage,
+		"sh", "-c", restoreVolumeScript(filepath.Base(path)),
+	); err != nil {
+		return fmt.Errorf("backup did not restore: %w", err)
+	}
+	if !req.Boot {
+		return nil
+	}
+
+	container, err := bootVerificationContainer(ctx, backupReq, scratchVolume, suffix)
+	if container != "" {
+		defer func() {
+			cleanupCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			_, _ = runDocker(cleanupCtx, "rm", "-f", container)
+		}()
+	}
+	if err != nil {
+		return err
+	}
+	verification.Booted = true
+	if dump {
+		if err := retryUntilDeadline(ctx, func() error {
+			return restoreDatabaseDump(ctx, backupReq, format.mode, container, path)
+		}); err != nil {
+			return fmt.Errorf("dump did not replay into the booted service: %w", err)
+		}
+	}
+	if req.Check == "" {
+		output, err := runDocker(ctx, "inspect", "--format", "{{.State.Running}}", container)
+		if err != nil || strings.TrimSpace(output) != "true" {
+			return fmt.Errorf("service exited after booting on the restored volume")
+		}
+		return nil
+	}
+	if err := retryUntilDeadline(ctx, func() error {
+		output, err := runDocker(ctx, "exec", container, "sh", "-c", req.Check)
+		if err != nil {
+			return fmt.Errorf("%w, output: %s", err, strings.TrimSpace(output))
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("check failed: %w", err)
+	}
+	verification.Checked = true
+	return nil
+}
+
+func extractVerificationBaseBackup(ctx context.Context, req BackupRequest, path string, scratchVolume string) error {
+	source, err := resolveBackupContainer(ctx, req)
+	if err != nil {
+		return err
+	}
+	dataDir, err := postgresDataSubdir(ctx, source, fullBackupVolumeName(req))
+	if err != nil {
+		return err
+	}
+	if err := extractBaseBackup(ctx, path, scratchVolume, dataDir, ""); err != nil {
+		return fmt.Errorf("backup did not restore: %w", err)
+	}
+	return nil
+}
+
+// bootVerificationContainer starts a copy of the service's running replica
+// (image, command, and environment) on the scratch volume. It has no network
+// so a restored app cannot reach real peers, queues, or customers.
+// extraMounts are added as further -v bind specs.
+func bootVerificationContainer(ctx context.Context, req BackupRequest, scratchVolume string, suffix string, extraMounts ...string) (string, error) {
+	if req.Service == "" {
+		return "", fmt.Errorf("service is required to boot a verification")
+	}
+	source, err := resolveBackupContainer(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	image, err := runDocker(ctx, "inspect", "--format", "{{.Config.Image}}", source)
+	if err != nil || strings.TrimSpace(image) == "" {
+		return "", fmt.Errorf("failed to inspect %s image: %w", source, err)
+	}
+	target, err := verificationMountTarget(ctx, source, fullBackupVolumeName(req))
+	if err != nil {
+		return "", err
+	}
+	var env, entrypoint, command []string
+	for _, field := range []struct {
+		format string
+		into   *[]string
+	}{
+		{"{{json .Config.Env}}", &env},
+		{"{{json .Config.Entrypoint}}", &entrypoint},
+		{"{{json .Config.Cmd}}", &command},
+	} {
+		output, err := runDocker(ctx, "inspect", "--format", field.format, source)
+		if err != nil {
+			return "", fmt.Errorf("failed to inspect %s: %w", source, err)
+		}
+		if output = strings.TrimSpace(output); output != "" && output != "null" {
+			if err := json.Unmarshal([]byte(output), field.into); err != nil {
+				return "", fmt.Errorf("failed to parse %s configuration: %w", source, err)
+			}
+		}
+	}
+
+	envFile, err := os.CreateTemp("", "tako-verify-env-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(envFile.Name())
+	for _, entry := range env {
+		if strings.ContainsAny(entry, "\r\n") {
+			continue
+		}
+		if _, err := envFile.WriteString(entry + "\n"); err != nil {
+			_ = envFile.Close()
+			return "", err
+		}
+	}
+	if err := envFile.Close(); err != nil {
+		return "", err
+	}
+
+	container := "tako-verify-" + req.Project + "-" + req.Environment + "-" + req.Service + "-" + suffix
+	args := []string{
+		"run", "-d",
+		"--name", container,
+		"--label", "tako.verify=true",
+		"--network", "none",
+		"--env-file", envFile.Name(),
+		"-v", scratchVolume + ":" + target,
+	}
+	for _, mount := range extraMounts {
+		args = append(args, "-v", mount)
+	}
+	if len(entrypoint) > 0 {
+		args = append(args, "--entrypoint", entrypoint[0])
+	}
+	args = append(args, strings.TrimSpace(image))
+	if len(entrypoint) > 1 {
+		args = append(args, entrypoint[1:]...)
+	}
+	args = append(args, command...)
+	if output, err := runDocker(ctx, args...); err != nil {
+		return container, fmt.Errorf("service did not start on the restored volume: %w, output: %s", err, strings.TrimSpace(output))
+	}
+	return container, nil
+}
+
+func verificationMountTarget(ctx context.Context, container string, dockerVolume string) (string, error) {
+	output, err := runDocker(ctx, "inspect", "--format", "{{json .Mounts}}", container)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect %s mounts: %w", container, err)
+	}
+	var mounts []struct {
+		Name        string `json:"Name"`
+		Destination string `json:"Destination"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &mounts); err != nil {
+		return "", fmt.Errorf("failed to parse %s mounts: %w", container, err)
+	}
+	for _, mount := range mounts {
+		if mount.Name == dockerVolume && strings.HasPrefix(mount.Destination, "/") {
+			return mount.Destination, nil
+		}
+	}
+	return "", fmt.Errorf("%s does not mount volume %s", container, dockerVolume)
+}
+
+// checkDatabaseDump proves a dump artifact is intact without a database:
+// custom-format and RDB files carry a magic header, and gzip verifies its
+// own checksum when read to the end.
+func checkDatabaseDump(path string, mode string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer file.Close()
+	magic := map[string]string{BackupModePgDump: "PGDMP", BackupModeRedisBGSave: "REDIS"}[mode]
+	if magic != "" {
+		header := make([]byte, len(magic))
+		if _, err := io.ReadFull(file, header); err != nil || !bytes.Equal(header, []byte(magic)) {
+			return fmt.Errorf("%s backup is not a valid dump (missing %s header)", mode, magic)
+		}
+		return nil
+	}
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("%s backup is not valid gzip: %w", mode, err)
+	}
+	defer reader.Close()
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return fmt.Errorf("%s backup is corrupt: %w", mode, err)
+	}
+	return nil
+}
+
+func retryUntilDeadline(ctx context.Context, attempt func() error) error {
+	for {
+		err := attempt()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backupVerifyRetryInterval):
+		}
+	}
+}
+
+func latestVolumeBackup(ctx context.Context, req BackupRequest) (string, error) {
+	response, err := ListVolumeBackups(ctx, BackupRequest{Project: req.Project, Environment: req.Environment, Volume: req.Volume})
+	if err != nil {
+		return "", err
+	}
+	if len(response.Backups) == 0 {
+		return "", fmt.Errorf("no backup of volume %s to verify", req.Volume)
+	}
+	return response.Backups[0].ID, nil
+}
+
+func backupVerificationPath(req BackupRequest, volume string, backupID string) string {
+	return filepath.Join(backupDirectory(req), backupVerifyDirName, volume+"_"+backupID+".json")
+}
+
+func writeBackupVerification(req BackupRequest, verification *BackupVerification) error {
+	path := backupVerificationPath(req, verification.Volume, verification.BackupID)
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("failed to create verification directory: %w", err)
+	}
+	data, err := json.MarshalIndent(verification, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to record verification: %w", err)
+	}
+	return nil
+}
+
+func readBackupVerification(req BackupRequest, volume string, backupID string) *BackupVerification {
+	data, err := os.ReadFile(backupVerificationPath(req, volume, backupID))
+	if err != nil {
+		return nil
+	}
+	var verification BackupVerification
+	if err := json.Unmarshal(data, &verification); err != nil {
+		return nil
+	}
+	return &verification
+}
+
+func removeBackupVerification(req BackupRequest, volume string, backupID string) {
+	_ = os.Remove(backupVerificationPath(req, volume, backupID))
+}
+
+func validateBackupVerifyRequest(req BackupVerifyRequest) error {
+	if err := validateBackupRequest(BackupRequest{
+		Project:      req.Project,
+		Environment:  req.Environment,
+		Volume:       req.Volume,
+		DockerVolume: req.DockerVolume,
+		BackupID:     req.BackupID,
+		Service:      req.Service,
+	}, true, false); err != nil {
+		return err
+	}
+	if req.Database != "" && !backupDatabasePattern.MatchString(req.Database) {
+		return fmt.Errorf("invalid backup database")
+	}
+	if req.Boot && req.Service == "" {
+		return fmt.Errorf("service is required to boot a verification")
+	}
+	if req.Check != "" && !req.Boot {
+		return fmt.Errorf("a verification check requires boot")
+	}
+	if len(req.Check) > maxBackupVerifyCheckBytes {
+		return fmt.Errorf("verification check must be %d bytes or less", maxBackupVerifyCheckBytes)
+	}
+	if req.TimeoutSeconds < 0 || time.Duration(req.TimeoutSeconds)*time.Second > maxBackupVerifyTimeout {
+		return fmt.Errorf("verification timeout must be at most %s", maxBackupVerifyTimeout)
+	}
+	if req.Storage != nil {
+		if err := ValidateBackupStorage(normalizeBackupStorage(*req.Storage)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package takod
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/notification"
+)
+
+func useFastBackupVerifyRetries(t *testing.T) {
+	t.Helper()
+	previous := backupVerifyRetryInterval
+	backupVerifyRetryInterval = 10 * time.Millisecond
+	t.Cleanup(func() { backupVerifyRetryInterval = previous })
+}
+
+func TestVerifyVolumeBackupRestoresNewestArchiveIntoScratchVolume(t *testing.T) {
+	t.Cleanup(useTempBackupRoot(t))
+	logPath := filepath.Join(t.TempDir(), "commands.log")
+	t.Cleanup(useFakeCommands(t, logPath))
+	request := BackupRequest{Project: "demo", Environment: "production"}
+	writeTestBackupArchive(t, backupDirectory(request), "20261015-020000", []byte("old"))
+	writeTestBackupArchive(t, backupDirectory(request), "20261016-020000", []byte("new"))
+
+	verification, err := VerifyVolumeBackup(context.Background(), BackupVerifyRequest{Project: "demo", Environment: "production", Volume: "data"})
+	if err != nil {
+		t.Fatalf("VerifyVolumeBackup returned error: %v", err)
+	}
+	if verification.BackupID != "20261016-020000" || verification.Status != BackupVerifyPassed || verification.Booted {
+		t.Fatalf("verification = %+v, want a passed restore-only drill of the newest backup", verification)
+	}
+
+	commands := readCommandLog(t, logPath)
+	create := commandIndex(t, commands, "docker volume create --label tako.verify=true tako_verify_demo_production_data_")
+	restore := commandIndex(t, commands, "docker run --rm --network none -v tako_verify_demo_production_data_")
+	remove := commandIndex(t, commands, "docker volume rm -f tako_verify_demo_production_data_")
+	if !(create < restore && restore < remove) {
+		t.Fatalf("drill commands out of order:\n%s", strings.Join(commands, "\n"))
+	}
+	for _, command := range commands {
+		if strings.Contains(command, "demo_production_data ") || strings.HasSuffix(command, "demo_production_data") {
+			t.Fatalf("drill touched the live volume: %s", command)
+		}
+	}
+
+	listed, err := ListVolumeBackups(context.Background(), BackupRequest{Project: "demo", Environment: "production", Volume: "data"})
+	if err != nil {
+		t.Fatalf("ListVolumeBackups returned error: %v", err)
+	}
+	if len(listed.Backups) != 2 || listed.Backups[0].Verification == nil || listed.Backups[0].Verification.Status != BackupVerifyPassed || listed.Backups[1].Verification != nil {
+		t.Fatalf("listed backups = %+v, want the drill recorded on the newest only", listed.Backups)
+	}
+
+	if err := DeleteVolumeBackup(context.Background(), BackupRequest{Project: "demo", Environment: "production", Volume: "data", BackupID: "20261016-020000"}); err != nil {
+		t.Fatalf("DeleteVolumeBackup returned error: %v", err)
+	}
+	if _, err := os.Stat(backupVerificationPath(request, "data", "20261016-020000")); !os.IsNotExist(err) {
+		t.Fatalf("verification record survived its backup: %v", err)
+	}
+}
+
+func TestVerifyVolumeBackupRecordsCorruptDumpAsFailed(t *testing.T) {
+	t.Cleanup(useTempBackupRoot(t))
+	logPath := filepath.Join(t.TempDir(), "commands.log")
+	t.Cleanup(useFakeCommands(t, logPath))
+	request := BackupRequest{Project: "demo", Environment: "production"}
+	if err := os.MkdirAll(backupDirectory(request), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(backupDirectory(request), "pgdata_20261016-020000.pgdump"), []byte("truncated"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	verification, err := VerifyVolumeBackup(context.Background(), BackupVerifyRequest{Project: "demo", Environment: "production", Volume: "pgdata"})
+	if err != nil {
+		t.Fatalf("VerifyVolumeBackup returned error: %v", err)
+	}
+	if verification.Status != BackupVerifyFailed || !strings.Contains(verification.Error, "missing PGDMP header") {
+		t.Fatalf("verification = %+v, want a failed drill naming the bad header", verification)
+	}
+	if recorded := readBackupVerification(request, "pgdata", "20261016-020000"); recorded == nil || recorded.Status != BackupVerifyFailed {
+		t.Fatalf("recorded verification = %+v", recorded)
+	}
+
+	if _, err := VerifyVolumeBackup(context.Background(), BackupVerifyRequest{Project: "demo", Environment: "production", Volume: "data"}); err == nil || !strings.Contains(err.Error(), "no backup of volume data") {
+		t.Fatalf("verify without backups = %v", err)
+	}
+}
+
+func TestVerifyVolumeBackupBootsServiceImageAndRunsCheck(t *testing.T) {
+	logPath := useFakeBackupContainer(t)
+	useFastBackupVerifyRetries(t)
+	t.Setenv("TAKO_FAKE_INSPECT_IMAGE", "postgres:16")
+	t.Setenv("TAKO_FAKE_INSPECT_MOUNTS", `[{"Name":"demo_production_pgdata","Destination":"/var/lib/postgresql/data"}]`)
+	t.Setenv("TAKO_FAKE_INSPECT_ENV", `["POSTGRES_PASSWORD=secret"]`)
+	writeTestBackupArchive(t, backupDirectory(BackupRequest{Project: "demo", Environment: "production"}), "20261016-020000", []byte("pgdata"))
+	request := BackupVerifyRequest{
+		Project:      "demo",
+		Environment:  "production",
+		Service:      "postgres",
+		Volume:       "data",
+		DockerVolume: "demo_production_pgdata",
+		Boot:         true,
+		Check:        "pg_isready",
+	}
+
+	verification, err := VerifyVolumeBackup(context.Background(), request)
+	if err != nil {
+		t.Fatalf("VerifyVolumeBackup returned error: %v", err)
+	}
+	if verification.Status != BackupVerifyPassed || !verification.Booted || !verification.Checked {
+		t.Fatalf("verification = %+v, want a booted and checked drill", verification)
+	}
+	commands := readCommandLog(t, logPath)
+	boot := commandIndex(t, commands, "docker run -d --name tako-verify-demo-production-postgres-")
+	if !strings.Contains(commands[boot], "--network none") || !strings.Contains(commands[boot], ":/var/lib/postgresql/data postgres:16") {
+		t.Fatalf("boot command = %s", commands[boot])
+	}
+	check := commandIndex(t, commands, "docker exec tako-verify-demo-production-postgres-")
+	cleanup := commandIndex(t, commands, "docker rm -f tako-verify-demo-production-postgres-")
+	if !(boot < check && check < cleanup) || !strings.HasSuffix(commands[check], "sh -c pg_isready") {
+		t.Fatalf("boot/check commands out of order:\n%s", strings.Join(commands, "\n"))
+	}
+
+	t.Setenv("TAKO_FAKE_DOCKER_EXEC_ERROR", "no response")
+	request.TimeoutSeconds = 1
+	verification, err = VerifyVolumeBackup(context.Background(), request)
+	if err != nil {
+		t.Fatalf("VerifyVolumeBackup returned error: %v", err)
+	}
+	if verification.Status != BackupVerifyFailed || !verification.Booted || verification.Checked || !strings.Contains(verification.Error, "check failed") {
+		t.Fatalf("verification = %+v, want a failed check after booting", verification)
+	}
+}
+
+func TestScheduledBackupVerifyNotifiesOnFailedDrill(t *testing.T) {
+	t.Cleanup(useTempBackupRoot(t))
+	t.Cleanup(useFakeCommands(t, filepath.Join(t.TempDir(), "commands.log")))
+	request := BackupRequest{Project: "demo", Environment: "production"}
+	if err := os.MkdirAll(backupDirectory(request), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(backupDirectory(request), "cache_20261016-020000.rdb"), []byte("not redis"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	scheduler := NewBackupScheduler(t.TempDir())
+	var events []notification.Event
+	scheduler.notify = func(targets JobNotifications, event notification.Event) error {
+		if targets.Slack == "" {
+			t.Fatalf("targets = %+v", targets)
+		}
+		events = append(events, event)
+		return nil
+	}
+	scheduler.runScheduledVerify(BackupScheduleRequest{
+		Project:       "demo",
+		Environment:   "production",
+		Service:       "redis",
+		Schedule:      "@daily",
+		Volumes:       []BackupScheduleVolume{{Volume: "cache"}, {Volume: "sessions"}},
+		Verify:        &BackupVerifySchedule{Schedule: "@weekly"},
+		Notifications: &JobNotifications{Slack: "https://hooks.slack.test/T/B/X"},
+	})
+
+	if len(events) != 2 || events[0].Type != notification.EventBackupVerifyFailed || events[0].Details["volume"] != "cache" || !strings.Contains(events[0].Error, "missing REDIS header") {
+		t.Fatalf("events = %+v, want a failed-drill alert for cache", events)
+	}
+	if events[1].Type != notification.EventBackupVerifyFailed || events[1].Details["volume"] != "sessions" || !strings.Contains(events[1].Error, "no backup of volume sessions") || !strings.Contains(events[1].Message, "could not run") {
+		t.Fatalf("events = %+v, want an alert for sessions, which has no backup to drill", events)
+	}
+}
+
+func TestVerifyVolumeBackupDrillsTheOffNodeCopy(t *testing.T) {
+	t.Cleanup(useTempBackupRoot(t))
+	logPath := filepath.Join(t.TempDir(), "commands.log")
+	t.Cleanup(useFakeCommands(t, logPath))
+	store := useMemoryBackupStore(t)
+	storage := chunkedTestStorage()
+	request := BackupRequest{Project: "demo", Environment: "production"}
+	local := filepath.Join(backupDirectory(request), "pgdata_20261016-020000.pgdump")
+	if err := os.MkdirAll(filepath.Dir(local), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(local, []byte("PGDMP custom dump"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	info, err := UploadBackupObject(context.Background(), storage, BackupObject{Project: "demo", Environment: "production", Volume: "pgdata", BackupID: "20261016-020000", Path: local, CreatedAt: time.Now().UTC()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The node's copy rots after upload; the off-node copy is what a drill
+	// with storage must prove.
+	if err := os.WriteFile(local, []byte("truncated"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	verify := BackupVerifyRequest{Project: "demo", Environment: "production", Volume: "pgdata", Storage: &storage}
+
+	verification, err := VerifyVolumeBackup(context.Background(), verify)
+	if err != nil {
+		t.Fatalf("VerifyVolumeBackup returned error: %v", err)
+	}
+	if verification.Status != BackupVerifyPassed || verification.BackupID != "20261016-020000" {
+		t.Fatalf("verification = %+v, want the intact off-node copy to pass", verification)
+	}
+	if entries, err := os.ReadDir(filepath.Join(backupDirectory(request), backupVerifyDirName)); err != nil || len(entries) != 1 {
+		t.Fatalf("verification directory = %v, %v; want only the record, the download removed", entries, err)
+	}
+	if data, err := os.ReadFile(local); err != nil || string(data) != "truncated" {
+		t.Fatalf("drill replaced the node's copy: %q, %v", data, err)
+	}
+
+	delete(store.objects, info.Key)
+	verification, err = VerifyVolumeBackup(context.Background(), verify)
+	if err != nil {
+		t.Fatalf("VerifyVolumeBackup returned error: %v", err)
+	}
+	if verification.Status != BackupVerifyFailed || !strings.Contains(verification.Error, "off-node copy did not download") {
+		t.Fatalf("verification = %+v, want a failed drill for the missing off-node copy", verification)
+	}
+	if recorded := readBackupVerification(request, "pgdata", "20261016-020000"); recorded == nil || recorded.Status != BackupVerifyFailed {
+		t.Fatalf("recorded verification = %+v", recorded)
+	}
+}
+
+func TestValidateBackupScheduleRequestChecksVerify(t *testing.T) {
+	request := BackupScheduleRequest{
+		Project:     "demo",
+		Environment: "production",
+		Service:     "postgres",
+		Schedule:    "@daily",
+		Volumes:     []BackupScheduleVolume{{Volume: "pgdata", DockerVolume: "demo_production_pgdata"}},
+		Verify:      &BackupVerifySchedule{Schedule: "every sunday"},
+	}
+	if err := validateBackupScheduleRequest(request); err == nil || !strings.Contains(err.Error(), "invalid backup verification schedule") {
+		t.Fatalf("bad verify schedule = %v", err)
+	}
+	request.Verify = &BackupVerifySchedule{Schedule: "0 5 * * 0", Check: "pg_isready"}
+	if err := validateBackupScheduleRequest(request); err == nil || !strings.Contains(err.Error(), "requires boot") {
+		t.Fatalf("check without boot = %v", err)
+	}
+	request.Verify.Boot = true
+	if err := validateBackupScheduleRequest(request); err != nil {
+		t.Fatalf("valid verify schedule returned error: %v", err)
+	}
+}
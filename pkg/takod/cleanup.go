@@ -164,6 +164,7 @@ func CleanupProject(ctx context.Context, req CleanupRequest) (*CleanupResponse,
 			filesPath = filepath.Join(filesPath, req.Environment)
 		}
 		removeFixedPath(filesPath, "operator files", warn)
+		removeProjectSecretFiles(req.Project, req.Environment, warn)
 	}
 	if req.RemoveTakodState {
 		cleanupTakodState(req.Project, req.Environment, warn)
@@ -0,0 +1,641 @@
+package takod
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/notification"
+)
+
+const (
+	containerEventsDirName = "container-events"
+	// MaxContainerEventHistory caps the events kept per environment; the
+	// oldest are dropped first.
+	MaxContainerEventHistory = 500
+	// DefaultContainerEventsLimit is how many events /v1/events returns
+	// when the request sets no limit.
+	DefaultContainerEventsLimit = 50
+	// ContainerCrashLoopCrashes crashes of one service within
+	// ContainerCrashLoopWindow make a crash loop.
+	ContainerCrashLoopCrashes = 3
+	ContainerCrashLoopWindow  = 10 * time.Minute
+	// containerEventsRetryInterval paces reconnecting to the docker event
+	// stream after it ends.
+	containerEventsRetryInterval = 5 * time.Second
+	// maxDockerEventLineBytes bounds one JSON event; labels make them
+	// larger than log lines but never this large.
+	maxDockerEventLineBytes = 1 << 20
+)
+
+const (
+	// ContainerEventCrash is a container that exited without takod or an
+	// operator stopping it.
+	ContainerEventCrash = "crash"
+	// ContainerEventOOM is the kernel OOM killer ending a container.
+	ContainerEventOOM = "oom"
+	// ContainerEventRestart is docker's restart policy starting a crashed
+	// container again.
+	ContainerEventRestart = "restart"
+	// ContainerEventCrashLoop marks a service reaching
+	// ContainerCrashLoopCrashes crashes within ContainerCrashLoopWindow.
+	ContainerEventCrashLoop = "crash_loop"
+)
+
+// ContainerEvent is one recorded lifecycle event of a service container.
+type ContainerEvent struct {
+	Time      time.Time `json:"time"`
+	Kind      string    `json:"kind"`
+	Service   string    `json:"service"`
+	Container string    `json:"container,omitempty"`
+	ExitCode  *int      `json:"exitCode,omitempty"`
+	Message   string    `json:"message,omitempty"`
+}
+
+// ContainerServiceCounters totals what happened to one service's containers
+// on this node since its history began. CrashLooping reports whether the
+// service is crashing often enough to count as a crash loop right now.
+type ContainerServiceCounters struct {
+	Service      string     `json:"service"`
+	Crashes      int        `json:"crashes"`
+	Restarts     int        `json:"restarts"`
+	OOMKills     int        `json:"oomKills"`
+	LastEventAt  *time.Time `json:"lastEventAt,omitempty"`
+	CrashLooping bool       `json:"crashLooping"`
+}
+
+// ContainerEventsSpec routes an environment's OOM and crash-loop
+// notifications. Node is the name the deployer knows this server by, added
+// to every notification.
+type ContainerEventsSpec struct {
+	Node          string            `json:"node,omitempty"`
+	Notifications *JobNotifications `json:"notifications,omitempty"`
+}
+
+// ContainerEventsApplyRequest replaces an environment's notification spec;
+// a nil Spec stops notifying. History is recorded either way.
+type ContainerEventsApplyRequest struct {
+	Project     string               `json:"project"`
+	Environment string               `json:"environment"`
+	Spec        *ContainerEventsSpec `json:"spec,omitempty"`
+}
+
+type ContainerEventsApplyResponse struct {
+	Project     string `json:"project"`
+	Environment string `json:"environment"`
+	Notifying   bool   `json:"notifying"`
+}
+
+// ContainerEventsRequest selects an environment's recorded events, newest
+// first, optionally narrowed to one service.
+type ContainerEventsRequest struct {
+	Project     string `json:"project"`
+	Environment string `json:"environment"`
+	Service     string `json:"service,omitempty"`
+	Limit       int    `json:"limit,omitempty"`
+}
+
+type ContainerEventsResponse struct {
+	Project     string                     `json:"project"`
+	Environment string                     `json:"environment"`
+	Node        string                     `json:"node,omitempty"`
+	Services    []ContainerServiceCounters `json:"services"`
+	Events      []ContainerEvent           `json:"events"`
+}
+
+// containerEventsEnvironment is one environment's persisted spec, counters,
+// and history, oldest event first.
+type containerEventsEnvironment struct {
+	Spec     *ContainerEventsSpec                 `json:"spec,omitempty"`
+	Counters map[string]*ContainerServiceCounters `json:"counters"`
+	Events   []ContainerEvent                     `json:"events"`
+}
+
+// dockerContainerEvent is the part of a `docker events` JSON line takod
+// reads. Container labels arrive among the actor's attributes.
+type dockerContainerEvent struct {
+	Action string `json:"Action"`
+	Actor  struct {
+		ID         string            `json:"ID"`
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+	TimeNano int64 `json:"timeNano"`
+}
+
+// ContainerEvents follows the docker event stream for takod's service
+// containers, counting crashes, restarts, and OOM kills per service and
+// keeping a bounded history per environment under the data dir. OOM kills
+// and crash loops notify the environment's targets.
+type ContainerEvents struct {
+	dataDir string
+	follow  func(ctx context.Context, since time.Time, visit func(line string) error) error
+	notify  func(targets JobNotifications, event notification.Event) error
+	now     func() time.Time
+
+	mu           sync.Mutex
+	environments map[string]*containerEventsEnvironment
+	loaded       bool
+	lastSeen     time.Time
+	// stopping holds containers takod or an operator sent a stop signal,
+	// whose next die is not a crash; oomKilled holds containers the OOM
+	// killer ended; crashed holds containers whose next start is a restart.
+	stopping  map[string]bool
+	oomKilled map[string]bool
+	crashed   map[string]bool
+	sends     sync.WaitGroup
+}
+
+func NewContainerEvents(dataDir string) *ContainerEvents {
+	return &ContainerEvents{
+		dataDir:      dataDir,
+		follow:       followDockerContainerEvents,
+		notify:       deliverJobNotification,
+		now:          time.Now,
+		environments: map[string]*containerEventsEnvironment{},
+		stopping:     map[string]bool{},
+		oomKilled:    map[string]bool{},
+		crashed:      map[string]bool{},
+	}
+}
+
+// Run follows docker events until ctx ends, reconnecting after the stream
+// breaks and replaying what was missed since the last event seen.
+func (c *ContainerEvents) Run(ctx context.Context) {
+	for {
+		c.mu.Lock()
+		since := c.lastSeen
+		c.mu.Unlock()
+		err := c.follow(ctx, since, func(line string) error {
+			c.Observe(line)
+			return nil
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "takod container events: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(containerEventsRetryInterval):
+		}
+	}
+}
+
+// followDockerContainerEvents streams takod container lifecycle events as
+// JSON lines, starting at since when it is set.
+func followDockerContainerEvents(ctx context.Context, since time.Time, visit func(line string) error) error {
+	args := []string{"events", "--format", "{{json .}}",
+		"--filter", "type=container",
+		"--filter", "label=tako.runtime=takod",
+		"--filter", "event=start",
+		"--filter", "event=kill",
+		"--filter", "event=die",
+		"--filter", "event=oom",
+		"--filter", "event=destroy",
+	}
+	if !since.IsZero() {
+		args = append(args, "--since", fmt.Sprintf("%d.%09d", since.Unix(), since.Nanosecond()))
+	}
+	cmd := dockerCommandContext(ctx, "docker", args...)
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to follow docker events: %w", err)
+	}
+	go func() {
+		_ = writer.CloseWithError(cmd.Wait())
+	}()
+	defer reader.Close()
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxDockerEventLineBytes)
+	for scanner.Scan() {
+		if err := visit(scanner.Text()); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("docker events stream ended: %w", err)
+	}
+	return fmt.Errorf("docker events stream ended")
+}
+
+func validateContainerEventsSpec(spec *ContainerEventsSpec) error {
+	if len(spec.Node) > 255 || hasControlChars(spec.Node) {
+		return fmt.Errorf("invalid node name")
+	}
+	return validateJobNotifications(spec.Notifications)
+}
+
+// Apply replaces one environment's notification spec, keeping its history.
+func (c *ContainerEvents) Apply(request ContainerEventsApplyRequest) (*ContainerEventsApplyResponse, error) {
+	if !isSafeProjectName(request.Project) {
+		return nil, fmt.Errorf("invalid project name")
+	}
+	if !isSafeRuntimeName(request.Environment) {
+		return nil, fmt.Errorf("invalid environment name")
+	}
+	if request.Spec != nil {
+		if err := validateContainerEventsSpec(request.Spec); err != nil {
+			return nil, err
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.loadLocked(); err != nil {
+		return nil, err
+	}
+	response := &ContainerEventsApplyResponse{Project: request.Project, Environment: request.Environment}
+	key := logShippingKey(request.Project, request.Environment)
+	environment, ok := c.environments[key]
+	if !ok {
+		if request.Spec == nil {
+			return response, nil
+		}
+		environment = &containerEventsEnvironment{Counters: map[string]*ContainerServiceCounters{}}
+	}
+	environment.Spec = request.Spec
+	if err := c.persistLocked(request.Project, request.Environment, environment); err != nil {
+		return nil, err
+	}
+	c.environments[key] = environment
+	response.Notifying = request.Spec != nil && request.Spec.Notifications != nil
+	return response, nil
+}
+
+// Events returns one environment's per-service counters and its most
+// recent events, newest first.
+func (c *ContainerEvents) Events(request ContainerEventsRequest) (*ContainerEventsResponse, error) {
+	if !isSafeProjectName(request.Project) {
+		return nil, fmt.Errorf("invalid project name")
+	}
+	if !isSafeRuntimeName(request.Environment) {
+		return nil, fmt.Errorf("invalid environment name")
+	}
+	if request.Service != "" && !isSafeServiceName(request.Service) {
+		return nil, fmt.Errorf("invalid service name")
+	}
+	if request.Limit < 0 || request.Limit > MaxContainerEventHistory {
+		return nil, fmt.Errorf("limit must be between 1 and %d", MaxContainerEventHistory)
+	}
+	limit := request.Limit
+	if limit == 0 {
+		limit = DefaultContainerEventsLimit
+	}
+	response := &ContainerEventsResponse{Project: request.Project, Environment: request.Environment, Services: []ContainerServiceCounters{}, Events: []ContainerEvent{}}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.loadLocked(); err != nil {
+		return nil, err
+	}
+	environment, ok := c.environments[logShippingKey(request.Project, request.Environment)]
+	if !ok {
+		return response, nil
+	}
+	if environment.Spec != nil {
+		response.Node = environment.Spec.Node
+	}
+	now := c.now()
+	for _, counters := range environment.Counters {
+		if request.Service != "" && counters.Service != request.Service {
+			continue
+		}
+		current := *counters
+		current.CrashLooping = environment.recentCrashes(counters.Service, now) >= ContainerCrashLoopCrashes
+		response.Services = append(response.Services, current)
+	}
+	sort.Slice(response.Services, func(i, j int) bool { return response.Services[i].Service < response.Services[j].Service })
+	for index := len(environment.Events) - 1; index >= 0 && len(response.Events) < limit; index-- {
+		event := environment.Events[index]
+		if request.Service == "" || event.Service == request.Service {
+			response.Events = append(response.Events, event)
+		}
+	}
+	return response, nil
+}
+
+// RemoveProject drops a project's history and spec (one environment, or all
+// when environment is empty).
+func (c *ContainerEvents) RemoveProject(project string, environment string) error {
+	if !isSafeProjectName(project) {
+		return fmt.Errorf("invalid project name")
+	}
+	if environment != "" && !isSafeRuntimeName(environment) {
+		return fmt.Errorf("invalid environment name")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if environment != "" {
+		delete(c.environments, logShippingKey(project, environment))
+		if err := os.Remove(c.historyPath(project, environment)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove container event history: %w", err)
+		}
+		_ = os.Remove(filepath.Join(c.dataDir, containerEventsDirName, project))
+		return nil
+	}
+	for key := range c.environments {
+		if strings.HasPrefix(key, project+"/") {
+			delete(c.environments, key)
+		}
+	}
+	if err := os.RemoveAll(filepath.Join(c.dataDir, containerEventsDirName, project)); err != nil {
+		return fmt.Errorf("failed to remove container event history: %w", err)
+	}
+	return nil
+}
+
+// containerStopSignals are the signals docker stop and docker kill end a
+// container with: SIGTERM and SIGKILL, and the SIGINT and SIGQUIT images
+// commonly set as STOPSIGNAL. Other kills, such as the SIGHUP of a secret
+// reload, leave the container running, so its next die is still a crash.
+var containerStopSignals = map[string]bool{"2": true, "3": true, "9": true, "15": true, "SIGINT": true, "SIGQUIT": true, "SIGKILL": true, "SIGTERM": true}
+
+func isContainerStopSignal(signal string) bool {
+	signal = strings.ToUpper(strings.TrimSpace(signal))
+	if signal == "" {
+		return true
+	}
+	if _, err := strconv.Atoi(signal); err != nil && !strings.HasPrefix(signal, "SIG") {
+		signal = "SIG" + signal
+	}
+	return containerStopSignals[signal]
+}
+
+// Observe handles one line of the docker event stream. Lines that are not
+// service container events are ignored.
+func (c *ContainerEvents) Observe(line string) {
+	var event dockerContainerEvent
+	if json.Unmarshal([]byte(line), &event) != nil || event.Actor.ID == "" {
+		return
+	}
+	attributes := event.Actor.Attributes
+	project, environmentName, service := attributes["tako.project"], attributes["tako.environment"], attributes["tako.service"]
+	if attributes["tako.role"] != "" || !isSafeProjectName(project) || !isSafeRuntimeName(environmentName) || !isSafeServiceName(service) {
+		return
+	}
+	at := c.now().UTC()
+	if event.TimeNano > 0 {
+		at = time.Unix(0, event.TimeNano).UTC()
+	}
+	id := event.Actor.ID
+
+	type pending struct {
+		targets JobNotifications
+		event   notification.Event
+	}
+	var sends []pending
+	c.mu.Lock()
+	if event.TimeNano > 0 {
+		// A reconnect replays from the last event seen, inclusive.
+		if !c.lastSeen.IsZero() && !at.After(c.lastSeen) {
+			c.mu.Unlock()
+			return
+		}
+		c.lastSeen = at
+	}
+	if err := c.loadLocked(); err != nil {
+		c.mu.Unlock()
+		fmt.Fprintf(os.Stderr, "takod container events: %v\n", err)
+		return
+	}
+	key := logShippingKey(project, environmentName)
+	environment := c.environments[key]
+	if environment == nil {
+		environment = &containerEventsEnvironment{Counters: map[string]*ContainerServiceCounters{}}
+	}
+	var recorded []ContainerEvent
+	switch event.Action {
+	case "kill":
+		if isContainerStopSignal(attributes["signal"]) {
+			c.stopping[id] = true
+		}
+	case "destroy":
+		delete(c.stopping, id)
+		delete(c.oomKilled, id)
+		delete(c.crashed, id)
+	case "start":
+		delete(c.stopping, id)
+		if c.crashed[id] {
+			delete(c.crashed, id)
+			recorded = append(recorded, ContainerEvent{Time: at, Kind: ContainerEventRestart, Service: service, Container: shortContainerID(id)})
+		}
+	case "oom":
+		c.oomKilled[id] = true
+		recorded = append(recorded, ContainerEvent{Time: at, Kind: ContainerEventOOM, Service: service, Container: shortContainerID(id), Message: "killed by the OOM killer"})
+	case "die":
+		oomKilled := c.oomKilled[id]
+		delete(c.oomKilled, id)
+		if c.stopping[id] {
+			delete(c.stopping, id)
+			break
+		}
+		crash := ContainerEvent{Time: at, Kind: ContainerEventCrash, Service: service, Container: shortContainerID(id)}
+		if code, err := strconv.Atoi(attributes["exitCode"]); err == nil {
+			crash.ExitCode = &code
+			crash.Message = fmt.Sprintf("exited with code %d", code)
+		}
+		if oomKilled {
+			crash.Message = "killed by the OOM killer"
+		}
+		c.crashed[id] = true
+		recorded = append(recorded, crash)
+	}
+	node := ""
+	var targets *JobNotifications
+	if environment.Spec != nil {
+		node, targets = environment.Spec.Node, environment.Spec.Notifications
+	}
+	for _, entry := range recorded {
+		environment.record(entry)
+		switch entry.Kind {
+		case ContainerEventOOM:
+			if targets != nil {
+				sends = append(sends, pending{targets: *targets, event: containerNotification(notification.ContainerOOMEvent(project, environmentName, service, entry.Container), node)})
+			}
+		case ContainerEventCrash:
+			crashes := environment.recentCrashes(service, at)
+			if crashes < ContainerCrashLoopCrashes || environment.recentCrashLoop(service, at) {
+				continue
+			}
+			loop := ContainerEvent{Time: at, Kind: ContainerEventCrashLoop, Service: service, Message: fmt.Sprintf("%d crashes within %s", crashes, ContainerCrashLoopWindow)}
+			environment.record(loop)
+			recorded = append(recorded, loop)
+			if targets != nil {
+				sends = append(sends, pending{targets: *targets, event: containerNotification(notification.ContainerCrashLoopEvent(project, environmentName, service, crashes, entry.Message), node)})
+			}
+		}
+	}
+	if len(recorded) > 0 {
+		c.environments[key] = environment
+		if err := c.persistLocked(project, environmentName, environment); err != nil {
+			fmt.Fprintf(os.Stderr, "takod container events: %v\n", err)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, send := range sends {
+		c.sends.Add(1)
+		go func(send pending) {
+			defer c.sends.Done()
+			if err := c.notify(send.targets, send.event); err != nil {
+				fmt.Fprintf(os.Stderr, "takod container event %s/%s %s failed to notify: %v\n", send.event.Project, send.event.Environment, send.event.Type, err)
+			}
+		}(send)
+	}
+}
+
+// containerNotification names the node the event happened on.
+func containerNotification(event notification.Event, node string) notification.Event {
+	if node == "" {
+		return event
+	}
+	if event.Details == nil {
+		event.Details = map[string]string{}
+	}
+	event.Details["node"] = node
+	event.Message += " on " + node
+	return event
+}
+
+func shortContainerID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// record appends entry to the history, dropping the oldest events past
+// MaxContainerEventHistory, and counts it.
+func (e *containerEventsEnvironment) record(entry ContainerEvent) {
+	e.Events = append(e.Events, entry)
+	if overflow := len(e.Events) - MaxContainerEventHistory; overflow > 0 {
+		e.Events = append(e.Events[:0:0], e.Events[overflow:]...)
+	}
+	if e.Counters == nil {
+		e.Counters = map[string]*ContainerServiceCounters{}
+	}
+	counters := e.Counters[entry.Service]
+	if counters == nil {
+		counters = &ContainerServiceCounters{Service: entry.Service}
+		e.Counters[entry.Service] = counters
+	}
+	switch entry.Kind {
+	case ContainerEventCrash:
+		counters.Crashes++
+	case ContainerEventRestart:
+		counters.Restarts++
+	case ContainerEventOOM:
+		counters.OOMKills++
+	}
+	at := entry.Time
+	counters.LastEventAt = &at
+}
+
+// recentCrashes counts service's crashes within ContainerCrashLoopWindow
+// before now.
+func (e *containerEventsEnvironment) recentCrashes(service string, now time.Time) int {
+	crashes := 0
+	for index := len(e.Events) - 1; index >= 0; index-- {
+		event := e.Events[index]
+		if now.Sub(event.Time) > ContainerCrashLoopWindow {
+			break
+		}
+		if event.Service == service && event.Kind == ContainerEventCrash {
+			crashes++
+		}
+	}
+	return crashes
+}
+
+// recentCrashLoop reports whether service already entered a crash loop
+// within the window, so one loop notifies once and a service that keeps
+// crashing notifies again once per window.
+func (e *containerEventsEnvironment) recentCrashLoop(service string, now time.Time) bool {
+	for index := len(e.Events) - 1; index >= 0; index-- {
+		event := e.Events[index]
+		if now.Sub(event.Time) > ContainerCrashLoopWindow {
+			return false
+		}
+		if event.Service == service && event.Kind == ContainerEventCrashLoop {
+			return true
+		}
+	}
+	return false
+}
+
+// loadLocked reads persisted environments once. Callers hold c.mu.
+func (c *ContainerEvents) loadLocked() error {
+	if c.loaded {
+		return nil
+	}
+	root := filepath.Join(c.dataDir, containerEventsDirName)
+	projects, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		c.loaded = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read container event history: %w", err)
+	}
+	for _, project := range projects {
+		if !project.IsDir() || !isSafeProjectName(project.Name()) {
+			continue
+		}
+		environments, err := os.ReadDir(filepath.Join(root, project.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read container event history: %w", err)
+		}
+		for _, entry := range environments {
+			name, ok := strings.CutSuffix(entry.Name(), ".json")
+			if entry.IsDir() || !ok || !isSafeRuntimeName(name) {
+				continue
+			}
+			path := filepath.Join(root, project.Name(), entry.Name())
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read container event history: %w", err)
+			}
+			var environment containerEventsEnvironment
+			if err := json.Unmarshal(data, &environment); err != nil {
+				return fmt.Errorf("failed to parse container event history %s: %w", path, err)
+			}
+			if environment.Spec != nil {
+				if err := validateContainerEventsSpec(environment.Spec); err != nil {
+					return fmt.Errorf("invalid container event history %s: %w", path, err)
+				}
+			}
+			c.environments[logShippingKey(project.Name(), name)] = &environment
+		}
+	}
+	c.loaded = true
+	return nil
+}
+
+func (c *ContainerEvents) historyPath(project string, environment string) string {
+	return filepath.Join(c.dataDir, containerEventsDirName, project, environment+".json")
+}
+
+func (c *ContainerEvents) persistLocked(project string, environment string, current *containerEventsEnvironment) error {
+	path := c.historyPath(project, environment)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create container events directory: %w", err)
+	}
+	data, err := json.MarshalIndent(current, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode container event history: %w", err)
+	}
+	if err := writeFileAtomic(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write container event history: %w", err)
+	}
+	return nil
+}
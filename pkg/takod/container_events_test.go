@@ -0,0 +1,223 @@
+package takod
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/notification"
+)
+
+func newTestContainerEvents(t *testing.T, dataDir string) (*ContainerEvents, *recordedAlertNotifications) {
+	t.Helper()
+	recorded := &recordedAlertNotifications{}
+	events := NewContainerEvents(dataDir)
+	events.notify = recorded.notify
+	events.now = func() time.Time { return time.Date(2026, 7, 6, 12, 30, 0, 0, time.UTC) }
+	return events, recorded
+}
+
+func dockerEventLine(action string, id string, service string, at time.Time, extra string) string {
+	return fmt.Sprintf(`{"Type":"container","Action":%q,"Actor":{"ID":%q,"Attributes":{"tako.project":"shop","tako.environment":"production","tako.service":%q,"tako.runtime":"takod"%s}},"time":%d,"timeNano":%d}`,
+		action, id, service, extra, at.Unix(), at.UnixNano())
+}
+
+func observeContainerEvents(events *ContainerEvents, lines ...string) {
+	for _, line := range lines {
+		events.Observe(line)
+	}
+	events.sends.Wait()
+}
+
+func TestContainerEventsCountsCrashesAndNotifiesCrashLoopOnce(t *testing.T) {
+	events, recorded := newTestContainerEvents(t, t.TempDir())
+	if _, err := events.Apply(ContainerEventsApplyRequest{Project: "shop", Environment: "production", Spec: &ContainerEventsSpec{Node: "node-a", Notifications: &JobNotifications{Webhook: "https://hooks.example.com/tako"}}}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	start := time.Date(2026, 7, 6, 12, 20, 0, 0, time.UTC)
+	api := "a1b2c3d4e5f60718293a4b5c6d7e8f90"
+	var lines []string
+	for crash := 0; crash < 4; crash++ {
+		at := start.Add(time.Duration(crash) * time.Minute)
+		lines = append(lines,
+			dockerEventLine("die", api, "api", at, `,"exitCode":"1"`),
+			dockerEventLine("start", api, "api", at.Add(time.Second), ""))
+	}
+	observeContainerEvents(events, lines...)
+
+	sent := recorded.take()
+	if len(sent) != 1 || sent[0].Type != notification.EventContainerCrashLoop || sent[0].Details["restart_count"] != "3" || sent[0].Details["node"] != "node-a" || sent[0].Error != "exited with code 1" {
+		t.Fatalf("notifications = %+v", sent)
+	}
+	response, err := events.Events(ContainerEventsRequest{Project: "shop", Environment: "production"})
+	if err != nil {
+		t.Fatalf("Events: %v", err)
+	}
+	if len(response.Services) != 1 {
+		t.Fatalf("services = %+v", response.Services)
+	}
+	counters := response.Services[0]
+	if counters.Service != "api" || counters.Crashes != 4 || counters.Restarts != 4 || counters.OOMKills != 0 || !counters.CrashLooping {
+		t.Fatalf("counters = %+v", counters)
+	}
+	kinds := []string{}
+	for _, event := range response.Events {
+		kinds = append(kinds, event.Kind)
+	}
+	if got := strings.Join(kinds, ","); got != "restart,crash,restart,crash_loop,crash,restart,crash,restart,crash" {
+		t.Fatalf("event kinds newest first = %s", got)
+	}
+	if response.Events[1].ExitCode == nil || *response.Events[1].ExitCode != 1 || response.Events[1].Container != api[:12] || response.Node != "node-a" {
+		t.Fatalf("crash event = %+v, node %q", response.Events[1], response.Node)
+	}
+}
+
+func TestContainerEventsRecordsOOMKillsAndIgnoresIntentionalStops(t *testing.T) {
+	events, recorded := newTestContainerEvents(t, t.TempDir())
+	if _, err := events.Apply(ContainerEventsApplyRequest{Project: "shop", Environment: "production", Spec: &ContainerEventsSpec{Notifications: &JobNotifications{Webhook: "https://hooks.example.com/tako"}}}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	at := time.Date(2026, 7, 6, 12, 25, 0, 0, time.UTC)
+	observeContainerEvents(events,
+		dockerEventLine("kill", "web-old", "web", at, `,"signal":"15"`),
+		dockerEventLine("die", "web-old", "web", at.Add(time.Second), `,"exitCode":"143"`),
+		dockerEventLine("die", "job-run", "nightly", at.Add(2*time.Second), `,"exitCode":"1","tako.role":"job"`),
+		dockerEventLine("oom", "worker-1", "worker", at.Add(3*time.Second), ""),
+		dockerEventLine("die", "worker-1", "worker", at.Add(3*time.Second+time.Millisecond), `,"exitCode":"137"`),
+		`not json`,
+	)
+
+	sent := recorded.take()
+	if len(sent) != 1 || sent[0].Type != notification.EventContainerOOM || sent[0].Service != "worker" || sent[0].Details["container_id"] != "worker-1" {
+		t.Fatalf("notifications = %+v", sent)
+	}
+	response, err := events.Events(ContainerEventsRequest{Project: "shop", Environment: "production", Service: "worker", Limit: 1})
+	if err != nil {
+		t.Fatalf("Events: %v", err)
+	}
+	if len(response.Services) != 1 || response.Services[0].OOMKills != 1 || response.Services[0].Crashes != 1 || response.Services[0].CrashLooping {
+		t.Fatalf("services = %+v", response.Services)
+	}
+	if len(response.Events) != 1 || response.Events[0].Kind != ContainerEventCrash || response.Events[0].Message != "killed by the OOM killer" {
+		t.Fatalf("events = %+v", response.Events)
+	}
+	all, err := events.Events(ContainerEventsRequest{Project: "shop", Environment: "production"})
+	if err != nil {
+		t.Fatalf("Events: %v", err)
+	}
+	for _, counters := range all.Services {
+		if counters.Service == "web" || counters.Service == "nightly" {
+			t.Fatalf("intentional stop or job run recorded: %+v", all.Services)
+		}
+	}
+}
+
+func TestContainerEventsCountsCrashAfterSignalReload(t *testing.T) {
+	events, _ := newTestContainerEvents(t, t.TempDir())
+	at := time.Date(2026, 7, 6, 12, 25, 0, 0, time.UTC)
+	observeContainerEvents(events,
+		dockerEventLine("kill", "api-1", "api", at, `,"signal":"1"`),
+		dockerEventLine("die", "api-1", "api", at.Add(time.Minute), `,"exitCode":"2"`),
+		dockerEventLine("start", "api-1", "api", at.Add(time.Minute+time.Second), ""),
+		dockerEventLine("kill", "api-1", "api", at.Add(2*time.Minute), `,"signal":"SIGKILL"`),
+		dockerEventLine("die", "api-1", "api", at.Add(2*time.Minute+time.Second), `,"exitCode":"137"`),
+	)
+
+	response, err := events.Events(ContainerEventsRequest{Project: "shop", Environment: "production"})
+	if err != nil {
+		t.Fatalf("Events: %v", err)
+	}
+	if len(response.Services) != 1 || response.Services[0].Crashes != 1 || response.Services[0].Restarts != 1 {
+		t.Fatalf("services = %+v, want the crash after a reload signal counted and the stop ignored", response.Services)
+	}
+	if response.Events[1].Kind != ContainerEventCrash || response.Events[1].ExitCode == nil || *response.Events[1].ExitCode != 2 {
+		t.Fatalf("events = %+v", response.Events)
+	}
+}
+
+func TestContainerEventsPersistHistoryAndSkipReplayedEvents(t *testing.T) {
+	dataDir := t.TempDir()
+	events, _ := newTestContainerEvents(t, dataDir)
+	at := time.Date(2026, 7, 6, 12, 0, 0, 0, time.UTC)
+	crash := dockerEventLine("die", "api-1", "api", at, `,"exitCode":"2"`)
+	observeContainerEvents(events, crash, crash)
+	for index := 1; index <= MaxContainerEventHistory; index++ {
+		events.Observe(dockerEventLine("die", fmt.Sprintf("api-%d", index+1), "api", at.Add(time.Duration(index)*time.Hour), `,"exitCode":"2"`))
+	}
+
+	path := filepath.Join(dataDir, containerEventsDirName, "shop", "production.json")
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("history file = %v, %v", info, err)
+	}
+	restarted, _ := newTestContainerEvents(t, dataDir)
+	response, err := restarted.Events(ContainerEventsRequest{Project: "shop", Environment: "production", Limit: MaxContainerEventHistory})
+	if err != nil {
+		t.Fatalf("Events: %v", err)
+	}
+	if len(response.Events) != MaxContainerEventHistory || response.Events[len(response.Events)-1].Container != "api-2" {
+		t.Fatalf("history kept %d events, oldest %+v", len(response.Events), response.Events[len(response.Events)-1])
+	}
+	if response.Services[0].Crashes != MaxContainerEventHistory+1 {
+		t.Fatalf("crashes = %d, want the replayed line counted once", response.Services[0].Crashes)
+	}
+
+	if err := restarted.RemoveProject("shop", ""); err != nil {
+		t.Fatalf("RemoveProject: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("history file after removal: %v", err)
+	}
+	response, err = restarted.Events(ContainerEventsRequest{Project: "shop", Environment: "production"})
+	if err != nil || len(response.Events) != 0 || len(response.Services) != 0 {
+		t.Fatalf("after removal = %+v, %v", response, err)
+	}
+}
+
+func TestContainerEventsRejectsInvalidRequests(t *testing.T) {
+	events, _ := newTestContainerEvents(t, t.TempDir())
+	if _, err := events.Apply(ContainerEventsApplyRequest{Project: "shop", Environment: "production", Spec: &ContainerEventsSpec{Node: "node\na"}}); err == nil {
+		t.Fatal("Apply accepted a node name with control characters")
+	}
+	if _, err := events.Apply(ContainerEventsApplyRequest{Project: "shop", Environment: "production", Spec: &ContainerEventsSpec{Notifications: &JobNotifications{Webhook: "ftp://hooks.example.com"}}}); err == nil {
+		t.Fatal("Apply accepted a non-HTTP webhook")
+	}
+	for name, request := range map[string]ContainerEventsRequest{
+		"project": {Project: "../shop", Environment: "production"},
+		"service": {Project: "shop", Environment: "production", Service: "api/../x"},
+		"limit":   {Project: "shop", Environment: "production", Limit: MaxContainerEventHistory + 1},
+	} {
+		if _, err := events.Events(request); err == nil {
+			t.Errorf("%s: Events accepted %+v", name, request)
+		}
+	}
+}
+
+func TestFollowDockerContainerEventsResumesSinceLastEvent(t *testing.T) {
+	old := dockerCommandContext
+	t.Cleanup(func() { dockerCommandContext = old })
+	var calls [][]string
+	dockerCommandContext = func(ctx context.Context, _ string, args ...string) *exec.Cmd {
+		calls = append(calls, args)
+		return exec.CommandContext(ctx, "sh", "-c", "printf '%s\\n' 'line one' 'line two'")
+	}
+	var lines []string
+	err := followDockerContainerEvents(context.Background(), time.Unix(1783339200, 5), func(line string) error {
+		lines = append(lines, line)
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "stream ended") {
+		t.Fatalf("follow error = %v, want the ended stream reported", err)
+	}
+	if strings.Join(lines, "|") != "line one|line two" {
+		t.Fatalf("lines = %q", lines)
+	}
+	args := strings.Join(calls[0], " ")
+	if !strings.Contains(args, "--filter label=tako.runtime=takod") || !strings.Contains(args, "--filter event=oom") || !strings.HasSuffix(args, "--since 1783339200.000000005") {
+		t.Fatalf("docker args = %s", args)
+	}
+}
@@ -28,13 +28,23 @@ type EnvBundleResponse struct {
 }
 
 func ReadEnvBundle(ctx context.Context, dataDir string, req EnvBundleRequest) (*EnvBundleResponse, error) {
+	return readBundle(ctx, dataDir, req, envBundlePath)
+}
+
+func WriteEnvBundle(ctx context.Context, dataDir string, req EnvBundleRequest) (*EnvBundleResponse, error) {
+	return writeBundle(ctx, dataDir, req, envBundlePath)
+}
+
+// readBundle and writeBundle store opaque client-encrypted content at the path
+// pathFor picks; the environment bundle and shared secrets share them.
+func readBundle(ctx context.Context, dataDir string, req EnvBundleRequest, pathFor func(string, EnvBundleRequest) (string, error)) (*EnvBundleResponse, error) {
 	if err := validateEnvBundleRequest(req, false); err != nil {
 		return nil, err
 	}
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
-	path, err := envBundlePath(dataDir, req)
+	path, err := pathFor(dataDir, req)
 	if err != nil {
 		return nil, err
 	}
@@ -53,7 +63,7 @@ func ReadEnvBundle(ctx context.Context, dataDir string, req EnvBundleRequest) (*
 	return response, nil
 }
 
-func WriteEnvBundle(ctx context.Context, dataDir string, req EnvBundleRequest) (*EnvBundleResponse, error) {
+func writeBundle(ctx context.Context, dataDir string, req EnvBundleRequest, pathFor func(string, EnvBundleRequest) (string, error)) (*EnvBundleResponse, error) {
 	if err := validateEnvBundleRequest(req, true); err != nil {
 		return nil, err
 	}
@@ -74,7 +84,7 @@ func WriteEnvBundle(ctx context.Context, dataDir string, req EnvBundleRequest) (
 	if err != nil {
 		return nil, fmt.Errorf("failed to encode environment bundle envelope: %w", err)
 	}
-	path, err := envBundlePath(dataDir, req)
+	path, err := pathFor(dataDir, req)
 	if err != nil {
 		return nil, err
 	}
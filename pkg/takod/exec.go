@@ -11,6 +11,8 @@ import (
 	"time"
 
 	"github.com/redentordev/tako-cli/pkg/config"
+	"github.com/redentordev/tako-cli/pkg/telemetry"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Exec modes: attach runs the command inside a running replica via
@@ -242,7 +244,10 @@ func prepareExecRun(ctx context.Context, req ExecRequest) (*execRun, error) {
 			image = resolved
 		}
 		if req.PullImage {
-			if output, err := runDockerWithAuth(ctx, req.RegistryAuths, "pull", image); err != nil {
+			pullCtx, span := startTakodSpan(ctx, "image.pull", attribute.String("image", image))
+			output, err := runDockerWithAuth(pullCtx, req.RegistryAuths, "pull", image)
+			telemetry.EndSpan(span, err)
+			if err != nil {
 				return nil, fmt.Errorf("failed to pull image %s: %w: %s", image, err, annotateRegistryAuthFailure(strings.TrimSpace(output)))
 			}
 		}
@@ -944,7 +944,7 @@ func validateFencedRequestQuery(r *http.Request, fence nodeidentity.OperationFen
 		return fmt.Errorf("request environment is outside the controller operation fence")
 	}
 	switch r.URL.Path {
-	case "/v1/images/build", "/v1/images/import", "/v1/proxy-file", "/v1/certs", "/v1/acme-dns", "/v1/backups", "/v1/backups/restore", "/v1/backups/cleanup", "/v1/backup-schedule":
+	case "/v1/images/build", "/v1/images/import", "/v1/proxy-file", "/v1/certs", "/v1/acme-dns", "/v1/backups", "/v1/backups/restore", "/v1/backups/cleanup", "/v1/backups/verify", "/v1/backups/pitr-restore", "/v1/backup-schedule":
 		// Body-scoped forms are validated after decoding. Query-scoped forms
 		// must carry both dimensions so opaque identifiers cannot cross fences.
 		if (r.Method == http.MethodDelete || r.URL.Path == "/v1/images/build" || r.URL.Path == "/v1/images/import") && (project == "" || environment == "") {
@@ -33,6 +33,8 @@ func validateEndpointPayloadScope(value any, fence nodeidentity.OperationFence)
 		return check(req.Project, req.Environment)
 	case *ServiceFilesCheckRequest:
 		return check(req.Project, req.Environment)
+	case *SecretRotateRequest:
+		return check(req.Project, req.Environment)
 	case *CleanupRequest:
 		return check(req.Project, req.Environment)
 	case *StateDocumentRequest:
@@ -43,6 +45,10 @@ func validateEndpointPayloadScope(value any, fence nodeidentity.OperationFence)
 		return check(req.Project, req.Environment)
 	case *BackupScheduleRequest:
 		return check(req.Project, req.Environment)
+	case *BackupVerifyRequest:
+		return check(req.Project, req.Environment)
+	case *PITRRestoreRequest:
+		return check(req.Project, req.Environment)
 	case *ACMEDNSReconcileRequest:
 		return check(req.Project, req.Environment)
 	case *PortAllocationRequest:
@@ -61,6 +67,16 @@ func validateEndpointPayloadScope(value any, fence nodeidentity.OperationFence)
 		return nil
 	case *JobTriggerRequest:
 		return check(req.Project, req.Environment)
+	case *MetricsExporterApplyRequest:
+		return check(req.Project, req.Environment)
+	case *AlertsApplyRequest:
+		return check(req.Project, req.Environment)
+	case *AlertSilenceRequest:
+		return check(req.Project, req.Environment)
+	case *TracingApplyRequest:
+		return check(req.Project, req.Environment)
+	case *ContainerEventsApplyRequest:
+		return check(req.Project, req.Environment)
 	case *ProxyFileRequest:
 		manifest, err := ParseProxyRouteManifest(req.Content)
 		if err != nil {
@@ -0,0 +1,201 @@
+package takod
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/notification"
+)
+
+const (
+	// jobMissedScheduleGrace is how late a scheduled run may start before
+	// the job counts as having missed its schedule; it absorbs snapshot lock
+	// waits and cron's minute granularity.
+	jobMissedScheduleGrace = 15 * time.Minute
+	// jobMissedScheduleCheckInterval is how often scheduled jobs are checked
+	// against their expected next run.
+	jobMissedScheduleCheckInterval = time.Minute
+)
+
+// JobNotifications carries the project's notification webhooks to the node
+// owning a job. Runs fire with no CLI attached, so takod delivers job alerts
+// itself; like EnvFileContent the URLs never leave the node through list
+// responses.
+type JobNotifications struct {
+	Slack   string `json:"slack,omitempty"`
+	Discord string `json:"discord,omitempty"`
+	Webhook string `json:"webhook,omitempty"`
+}
+
+func validateJobNotifications(targets *JobNotifications) error {
+	if targets == nil {
+		return nil
+	}
+	for _, target := range []struct{ name, value string }{
+		{"slack", targets.Slack},
+		{"discord", targets.Discord},
+		{"webhook", targets.Webhook},
+	} {
+		if target.value == "" {
+			continue
+		}
+		parsed, err := url.Parse(target.value)
+		if err != nil || hasControlChars(target.value) || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			return fmt.Errorf("notifications.%s must be an http(s) URL", target.name)
+		}
+	}
+	return nil
+}
+
+// deliverJobNotification is the production notification seam.
+func deliverJobNotification(targets JobNotifications, event notification.Event) error {
+	return notification.NewNotifier(notification.NotifierConfig{
+		SlackWebhook:   targets.Slack,
+		DiscordWebhook: targets.Discord,
+		Webhook:        targets.Webhook,
+	}, false).Notify(event)
+}
+
+// sendJobAlert delivers event to the job's notification targets in the
+// background so a slow webhook never holds a run slot.
+func (s *JobScheduler) sendJobAlert(spec JobSpec, event notification.Event) {
+	if spec.Notifications == nil || s.notify == nil {
+		return
+	}
+	targets := *spec.Notifications
+	s.alerts.Add(1)
+	go func() {
+		defer s.alerts.Done()
+		if err := s.notify(targets, event); err != nil {
+			fmt.Fprintf(os.Stderr, "takod job %s alert failed: %v\n", jobKey(spec.Project, spec.Environment, spec.Name), err)
+		}
+	}()
+}
+
+// alertJobRun compares a finished run with the job's previous run and
+// alerts on state changes only: JobFailed when a healthy job fails or times
+// out, JobRecovered when a failing job, or one that missed its schedule,
+// succeeds again. Replaced runs change nothing.
+func (s *JobScheduler) alertJobRun(spec JobSpec, record JobRunRecord) {
+	if record.Status == JobRunStatusReplaced || record.Status == JobRunStatusSkipped {
+		return
+	}
+	key := jobKey(spec.Project, spec.Environment, spec.Name)
+	s.mu.Lock()
+	_, wasMissed := s.missed[key]
+	delete(s.missed, key)
+	s.mu.Unlock()
+	if spec.Notifications == nil {
+		return
+	}
+
+	wasFailing := false
+	if previous := s.previousJobRun(spec, record); previous != nil {
+		wasFailing = jobRunFailed(previous.Status)
+	}
+	switch {
+	case jobRunFailed(record.Status) && !wasFailing:
+		attempts := record.Attempt
+		if attempts == 0 {
+			attempts = 1
+		}
+		s.sendJobAlert(spec, notification.JobFailedEvent(spec.Project, spec.Environment, spec.Name, record.RunID, record.Status, record.ExitCode, attempts))
+	case record.Status == JobRunStatusSucceeded && wasFailing:
+		s.sendJobAlert(spec, notification.JobRecoveredEvent(spec.Project, spec.Environment, spec.Name, record.RunID, "failing"))
+	case record.Status == JobRunStatusSucceeded && wasMissed:
+		s.sendJobAlert(spec, notification.JobRecoveredEvent(spec.Project, spec.Environment, spec.Name, record.RunID, "missing its schedule"))
+	}
+}
+
+// previousJobRun is the final attempt of the run before record, ignoring
+// runs that were skipped or replaced.
+func (s *JobScheduler) previousJobRun(spec JobSpec, record JobRunRecord) *JobRunRecord {
+	records, err := s.readRunRecords(spec.Project, spec.Environment, spec.Name)
+	if err != nil {
+		return nil
+	}
+	for i := range records {
+		candidate := records[i]
+		if candidate.Container == record.Container || (record.RunID != "" && candidate.RunID == record.RunID) {
+			continue
+		}
+		if candidate.Status == JobRunStatusSkipped || candidate.Status == JobRunStatusReplaced {
+			continue
+		}
+		return &candidate
+	}
+	return nil
+}
+
+func jobRunFailed(status string) bool {
+	return status == JobRunStatusFailed || status == JobRunStatusTimeout
+}
+
+// watchMissedSchedules runs the dead man's switch until ctx ends.
+func (s *JobScheduler) watchMissedSchedules(ctx context.Context) {
+	ticker := time.NewTicker(jobMissedScheduleCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.checkMissedSchedules(now)
+		}
+	}
+}
+
+// checkMissedSchedules alerts once for every scheduled job whose expected
+// next run, counted from its last run or its last registration, is more
+// than jobMissedScheduleGrace overdue while nothing is running.
+func (s *JobScheduler) checkMissedSchedules(now time.Time) {
+	type candidate struct {
+		spec       JobSpec
+		registered time.Time
+		alerted    time.Time
+	}
+	var candidates []candidate
+	s.mu.Lock()
+	for key, spec := range s.specs {
+		if spec.IsDependent() || spec.Notifications == nil || len(s.running[key]) > 0 {
+			continue
+		}
+		candidates = append(candidates, candidate{spec: spec, registered: s.registered[key], alerted: s.missed[key]})
+	}
+	s.mu.Unlock()
+
+	for _, item := range candidates {
+		spec := item.spec
+		schedule, err := s.parser.Parse(jobCronSpec(spec))
+		if err != nil {
+			continue
+		}
+		var lastRun time.Time
+		if last := s.lastRunRecord(spec.Project, spec.Environment, spec.Name); last != nil {
+			lastRun = last.StartedAt
+		}
+		since := lastRun
+		if item.registered.After(since) {
+			since = item.registered
+		}
+		if since.IsZero() {
+			continue
+		}
+		expected := schedule.Next(since)
+		if expected.IsZero() || !now.After(expected.Add(jobMissedScheduleGrace)) || expected.Equal(item.alerted) {
+			continue
+		}
+		key := jobKey(spec.Project, spec.Environment, spec.Name)
+		s.mu.Lock()
+		if len(s.running[key]) > 0 {
+			s.mu.Unlock()
+			continue
+		}
+		s.missed[key] = expected
+		s.mu.Unlock()
+		s.sendJobAlert(spec, notification.JobMissedScheduleEvent(spec.Project, spec.Environment, spec.Name, spec.Schedule, expected, lastRun))
+	}
+}
@@ -0,0 +1,168 @@
+package takod
+
+import (
+	"context"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/notification"
+)
+
+type recordedAlerts struct {
+	mu     sync.Mutex
+	events []notification.Event
+}
+
+func (r *recordedAlerts) notify(targets JobNotifications, event notification.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *recordedAlerts) types() []notification.EventType {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var types []notification.EventType
+	for _, event := range r.events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func alertingJobSpecFixture() JobSpec {
+	spec := validJobSpecFixture()
+	spec.Notifications = &JobNotifications{Webhook: "https://hooks.example.com/tako"}
+	return spec
+}
+
+func TestJobAlertsFireOnFailureAndRecoveryTransitions(t *testing.T) {
+	scheduler := newTestJobScheduler(t)
+	alerts := &recordedAlerts{}
+	scheduler.notify = alerts.notify
+	exitCodes := []int{0, 1, 1, 0, 0}
+	scheduler.runJob = func(ctx context.Context, spec JobSpec, container string, output io.Writer) (int, error) {
+		code := exitCodes[0]
+		exitCodes = exitCodes[1:]
+		return code, nil
+	}
+	spec := alertingJobSpecFixture()
+
+	var failed JobRunRecord
+	for i := 0; i < 5; i++ {
+		record := scheduler.executeJob(context.Background(), spec, JobTriggerSchedule, nil)
+		if i == 1 {
+			failed = record
+		}
+		scheduler.alerts.Wait()
+	}
+
+	want := []notification.EventType{notification.EventJobFailed, notification.EventJobRecovered}
+	if got := alerts.types(); !slices.Equal(got, want) {
+		t.Fatalf("alerts = %v, want %v", got, want)
+	}
+	first := alerts.events[0]
+	if first.Service != "report" || first.Details["run_id"] != failed.RunID || !strings.Contains(first.Message, "tako jobs logs report --run "+failed.RunID) {
+		t.Fatalf("failure alert = %+v", first)
+	}
+}
+
+func TestJobAlertsNeedNotificationTargets(t *testing.T) {
+	scheduler := newTestJobScheduler(t)
+	alerts := &recordedAlerts{}
+	scheduler.notify = alerts.notify
+	scheduler.runJob = func(ctx context.Context, spec JobSpec, container string, output io.Writer) (int, error) {
+		return 1, nil
+	}
+
+	scheduler.executeJob(context.Background(), validJobSpecFixture(), JobTriggerSchedule, nil)
+	scheduler.alerts.Wait()
+
+	if got := alerts.types(); len(got) != 0 {
+		t.Fatalf("alerts without targets = %v", got)
+	}
+}
+
+func TestMissedScheduleAlertsOnceUntilJobRunsAgain(t *testing.T) {
+	scheduler := newTestJobScheduler(t)
+	alerts := &recordedAlerts{}
+	scheduler.notify = alerts.notify
+	spec := alertingJobSpecFixture()
+	spec.Schedule = "@hourly"
+	if _, err := scheduler.Apply(context.Background(), JobsApplyRequest{Project: "demo", Environment: "production", Jobs: []JobSpec{spec}}); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	key := jobKey("demo", "production", "report")
+	now := time.Now()
+	scheduler.mu.Lock()
+	scheduler.registered[key] = now.Add(-3 * time.Hour)
+	scheduler.mu.Unlock()
+
+	scheduler.checkMissedSchedules(now)
+	scheduler.checkMissedSchedules(now.Add(time.Minute))
+	scheduler.alerts.Wait()
+	if got := alerts.types(); !slices.Equal(got, []notification.EventType{notification.EventJobMissedSchedule}) {
+		t.Fatalf("alerts = %v", got)
+	}
+	if alerts.events[0].Details["last_run"] != "never" {
+		t.Fatalf("missed alert = %+v", alerts.events[0])
+	}
+
+	scheduler.runScheduledJob(key)
+	scheduler.alerts.Wait()
+	want := []notification.EventType{notification.EventJobMissedSchedule, notification.EventJobRecovered}
+	if got := alerts.types(); !slices.Equal(got, want) {
+		t.Fatalf("alerts = %v, want %v", got, want)
+	}
+	scheduler.checkMissedSchedules(time.Now())
+	scheduler.alerts.Wait()
+	if got := alerts.types(); len(got) != 2 {
+		t.Fatalf("alerts after a fresh run = %v", got)
+	}
+}
+
+func TestMissedScheduleSkipsRunningAndDependentJobs(t *testing.T) {
+	scheduler := newTestJobScheduler(t)
+	alerts := &recordedAlerts{}
+	scheduler.notify = alerts.notify
+	root := alertingJobSpecFixture()
+	root.Schedule = "@hourly"
+	dependent := alertingJobSpecFixture()
+	dependent.Name = "publish"
+	dependent.Schedule = ""
+	dependent.After = []string{"report"}
+	if _, err := scheduler.Apply(context.Background(), JobsApplyRequest{Project: "demo", Environment: "production", Jobs: []JobSpec{root, dependent}}); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	key := jobKey("demo", "production", "report")
+	now := time.Now()
+	scheduler.mu.Lock()
+	scheduler.registered[key] = now.Add(-3 * time.Hour)
+	scheduler.registered[jobKey("demo", "production", "publish")] = now.Add(-3 * time.Hour)
+	scheduler.running[key] = []*jobRunSlot{{}}
+	scheduler.mu.Unlock()
+
+	scheduler.checkMissedSchedules(now)
+	scheduler.alerts.Wait()
+	if got := alerts.types(); len(got) != 0 {
+		t.Fatalf("alerts = %v", got)
+	}
+}
+
+func TestValidateJobSpecRejectsBadNotificationTargets(t *testing.T) {
+	for _, targets := range []JobNotifications{
+		{Slack: "hooks.slack.com/services/x"},
+		{Discord: "ftp://discord.example.com/x"},
+		{Webhook: "https://hooks.example.com/\nx"},
+	} {
+		spec := validJobSpecFixture()
+		spec.Notifications = &targets
+		if err := validateJobSpec(&spec); err == nil {
+			t.Fatalf("targets %+v accepted", targets)
+		}
+	}
+}
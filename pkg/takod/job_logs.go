@@ -0,0 +1,337 @@
+package takod
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// jobLogsDirName holds the full per-attempt output archive, one file per
+// run container under project/environment/job.
+const jobLogsDirName = "job-logs"
+
+const (
+	// defaultJobLogMaxBytes caps one attempt's archived output when the spec
+	// sets no cap.
+	defaultJobLogMaxBytes = 64 << 20
+	// maxJobLogMaxBytes bounds the cap a job spec may request.
+	maxJobLogMaxBytes = 1 << 30
+	// defaultJobLogRetainDays keeps archived output for two weeks unless the
+	// spec says otherwise; files are also dropped once their run record
+	// falls out of the bounded history.
+	defaultJobLogRetainDays = 14
+	// maxJobLogRetainDays bounds the retention a job spec may request.
+	maxJobLogRetainDays = 365
+	// jobLogFollowInterval is how often a followed run's archive is polled
+	// for new output.
+	jobLogFollowInterval = 500 * time.Millisecond
+)
+
+// jobLogArchive writes one attempt's complete output to disk up to a size
+// cap. Write never fails so an archive problem cannot fail the job; output
+// past the cap is dropped after a single notice line.
+type jobLogArchive struct {
+	file      *os.File
+	limit     int64
+	written   int64
+	truncated bool
+}
+
+func openJobLogArchive(path string, limit int64) (*jobLogArchive, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create job log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open job log: %w", err)
+	}
+	if limit <= 0 {
+		limit = defaultJobLogMaxBytes
+	}
+	return &jobLogArchive{file: file, limit: limit}, nil
+}
+
+func (a *jobLogArchive) Write(p []byte) (int, error) {
+	if a.truncated {
+		return len(p), nil
+	}
+	chunk := p
+	if remaining := a.limit - a.written; int64(len(chunk)) > remaining {
+		chunk = chunk[:remaining]
+		a.truncated = true
+	}
+	n, _ := a.file.Write(chunk)
+	a.written += int64(n)
+	if a.truncated {
+		fmt.Fprintf(a.file, "\n... job log archive truncated at %d byte(s); later output was not kept\n", a.limit)
+	}
+	return len(p), nil
+}
+
+func (a *jobLogArchive) Close() error {
+	return a.file.Close()
+}
+
+// jobLogArchiveFor opens the archive for one attempt's container, or returns
+// nil after logging when the file cannot be created.
+func (s *JobScheduler) jobLogArchiveFor(spec JobSpec, container string) *jobLogArchive {
+	archive, err := openJobLogArchive(jobLogPath(s.dataDir, spec.Project, spec.Environment, spec.Name, container), spec.LogMaxBytes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "takod job scheduler: %v\n", err)
+		return nil
+	}
+	return archive
+}
+
+// pruneJobLogs drops archived attempts whose run record fell out of the
+// bounded history or that are older than the spec's retention. Attempts
+// still in progress are kept.
+func (s *JobScheduler) pruneJobLogs(spec JobSpec) {
+	dir := jobLogsDir(s.dataDir, spec.Project, spec.Environment, spec.Name)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	keep := map[string]bool{}
+	records, err := s.readRunRecords(spec.Project, spec.Environment, spec.Name)
+	if err != nil {
+		return
+	}
+	for _, record := range records {
+		if record.Container != "" {
+			keep[record.Container] = true
+		}
+	}
+	s.mu.Lock()
+	for _, slot := range s.running[jobKey(spec.Project, spec.Environment, spec.Name)] {
+		if slot.container != "" {
+			keep[slot.container] = true
+		}
+	}
+	s.mu.Unlock()
+
+	retainDays := spec.LogRetainDays
+	if retainDays <= 0 {
+		retainDays = defaultJobLogRetainDays
+	}
+	cutoff := time.Now().Add(-time.Duration(retainDays) * 24 * time.Hour)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".log" {
+			continue
+		}
+		container := strings.TrimSuffix(name, ".log")
+		if keep[container] {
+			info, err := entry.Info()
+			if err != nil || !info.ModTime().Before(cutoff) {
+				continue
+			}
+		}
+		_ = os.Remove(filepath.Join(dir, name))
+	}
+}
+
+// jobLogSegment is one archived attempt of a selected run, in run order.
+type jobLogSegment struct {
+	container   string
+	attempt     int
+	maxAttempts int
+}
+
+// resolveJobLogRun maps the requested run (a workflow run ID, a run
+// container name, or empty for the newest run) to a run ID and an optional
+// single container.
+func (s *JobScheduler) resolveJobLogRun(project string, environment string, job string, run string) (string, string, error) {
+	key := jobKey(project, environment, job)
+	s.mu.Lock()
+	_, scheduled := s.specs[key]
+	var activeRunID string
+	for _, slot := range s.running[key] {
+		if run == "" && slot.runID != "" {
+			activeRunID = slot.runID
+		}
+		if run != "" && slot.container == run {
+			s.mu.Unlock()
+			return slot.runID, run, nil
+		}
+	}
+	s.mu.Unlock()
+	if run == "" && activeRunID != "" {
+		return activeRunID, "", nil
+	}
+
+	records, err := s.readRunRecords(project, environment, job)
+	if err != nil {
+		return "", "", err
+	}
+	if !scheduled && len(records) == 0 {
+		return "", "", fmt.Errorf("job %s is not scheduled for %s/%s on this node", job, project, environment)
+	}
+	for _, record := range records {
+		if record.Container == "" {
+			continue
+		}
+		switch {
+		case run == "":
+			if record.RunID == "" {
+				return "", record.Container, nil
+			}
+			return record.RunID, "", nil
+		case record.RunID == run:
+			return run, "", nil
+		case record.Container == run:
+			return record.RunID, run, nil
+		}
+	}
+	if run == "" {
+		return "", "", fmt.Errorf("job %s has no archived runs", job)
+	}
+	return "", "", fmt.Errorf("job %s has no archived run %s", job, run)
+}
+
+// jobLogSegments lists the archived attempts of the selected run and
+// whether any of them is still running. Activity is sampled before the
+// records are read so a run reported finished has its output complete.
+func (s *JobScheduler) jobLogSegments(project string, environment string, job string, runID string, container string) ([]jobLogSegment, bool, error) {
+	matches := func(candidateRunID string, candidateContainer string) bool {
+		if container != "" {
+			return candidateContainer == container
+		}
+		return runID != "" && candidateRunID == runID
+	}
+	var activeSegments []jobLogSegment
+	s.mu.Lock()
+	for _, slot := range s.running[jobKey(project, environment, job)] {
+		if container == "" && slot.runID == runID && runID != "" {
+			if slot.container != "" {
+				activeSegments = append(activeSegments, jobLogSegment{container: slot.container, attempt: slot.attempt, maxAttempts: slot.maxAttempts})
+			} else {
+				activeSegments = append(activeSegments, jobLogSegment{})
+			}
+			continue
+		}
+		if slot.container != "" && matches(slot.runID, slot.container) {
+			activeSegments = append(activeSegments, jobLogSegment{container: slot.container, attempt: slot.attempt, maxAttempts: slot.maxAttempts})
+		}
+	}
+	s.mu.Unlock()
+
+	records, err := s.readRunRecords(project, environment, job)
+	if err != nil {
+		return nil, false, err
+	}
+	var segments []jobLogSegment
+	seen := map[string]bool{}
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if record.Container == "" || !matches(record.RunID, record.Container) || seen[record.Container] {
+			continue
+		}
+		seen[record.Container] = true
+		segments = append(segments, jobLogSegment{container: record.Container, attempt: record.Attempt, maxAttempts: record.MaxAttempts})
+	}
+	for _, segment := range activeSegments {
+		if segment.container == "" || seen[segment.container] {
+			continue
+		}
+		seen[segment.container] = true
+		segments = append(segments, segment)
+	}
+	return segments, len(activeSegments) > 0, nil
+}
+
+// StreamLogs writes the archived output of one job run to w: every attempt
+// of a workflow run ID, one attempt by container name, or the newest run
+// when run is empty. Attempts of a job with retries are headed by their
+// attempt number. With follow, output of a run still in progress streams
+// until the run finishes or ctx ends. Selection errors surface before any
+// bytes are written.
+func (s *JobScheduler) StreamLogs(ctx context.Context, project string, environment string, job string, run string, follow bool, w io.Writer) error {
+	if s == nil {
+		return fmt.Errorf("job scheduler is not initialized")
+	}
+	if !isSafeProjectName(project) {
+		return fmt.Errorf("invalid project name")
+	}
+	if !isSafeRuntimeName(environment) {
+		return fmt.Errorf("invalid environment name")
+	}
+	if !isSafeServiceName(job) {
+		return fmt.Errorf("invalid job name")
+	}
+	if len(run) > 256 || hasControlChars(run) {
+		return fmt.Errorf("invalid run")
+	}
+	runID, container, err := s.resolveJobLogRun(project, environment, job, run)
+	if err != nil {
+		return err
+	}
+
+	offsets := map[string]int64{}
+	for {
+		segments, active, err := s.jobLogSegments(project, environment, job, runID, container)
+		if err != nil {
+			return err
+		}
+		for _, segment := range segments {
+			offset, started := offsets[segment.container]
+			if !started && segment.maxAttempts > 0 {
+				if _, err := fmt.Fprintf(w, "--- attempt %d of %d (%s) ---\n", segment.attempt, segment.maxAttempts, segment.container); err != nil {
+					return err
+				}
+			}
+			copied, err := copyJobLogFrom(jobLogPath(s.dataDir, project, environment, job, segment.container), offset, w)
+			offsets[segment.container] = offset + copied
+			if err != nil {
+				return err
+			}
+		}
+		if !follow || !active {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(jobLogFollowInterval):
+		}
+	}
+}
+
+// copyJobLogFrom copies an archive from offset to its current end. A missing
+// file (pruned, or never written) copies nothing.
+func copyJobLogFrom(path string, offset int64, w io.Writer) (int64, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open job log: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to read job log: %w", err)
+	}
+	return io.Copy(w, file)
+}
+
+func validateJobLogArchive(spec JobSpec) error {
+	if spec.LogMaxBytes < 0 || spec.LogMaxBytes > maxJobLogMaxBytes {
+		return fmt.Errorf("logMaxBytes must be between 0 and %d", maxJobLogMaxBytes)
+	}
+	if spec.LogRetainDays < 0 || spec.LogRetainDays > maxJobLogRetainDays {
+		return fmt.Errorf("logRetainDays must be between 0 and %d", maxJobLogRetainDays)
+	}
+	return nil
+}
+
+func jobLogsDir(dataDir string, project string, environment string, name string) string {
+	return filepath.Join(dataDir, jobLogsDirName, project, environment, name)
+}
+
+func jobLogPath(dataDir string, project string, environment string, name string, container string) string {
+	return filepath.Join(jobLogsDir(dataDir, project, environment, name), container+".log")
+}
@@ -0,0 +1,206 @@
+package takod
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExecuteJobArchivesFullOutput(t *testing.T) {
+	scheduler := newTestJobScheduler(t)
+	full := strings.Repeat("report line\n", 4096)
+	scheduler.runJob = func(ctx context.Context, spec JobSpec, container string, output io.Writer) (int, error) {
+		_, _ = io.WriteString(output, full)
+		return 0, nil
+	}
+	spec := validJobSpecFixture()
+
+	record := scheduler.executeJob(context.Background(), spec, JobTriggerManual, nil)
+	if record.LogBytes != int64(len(full)) || record.LogTruncated {
+		t.Fatalf("record = %+v", record)
+	}
+	if len(record.Output) >= len(full) {
+		t.Fatalf("run record kept %d bytes of output, want a bounded tail", len(record.Output))
+	}
+	info, err := os.Stat(jobLogPath(scheduler.dataDir, "demo", "production", "report", record.Container))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("archive stat = %v, err %v", info, err)
+	}
+
+	var out bytes.Buffer
+	if err := scheduler.StreamLogs(context.Background(), "demo", "production", "report", "", false, &out); err != nil {
+		t.Fatalf("stream logs: %v", err)
+	}
+	if out.String() != full {
+		t.Fatalf("streamed %d bytes, want %d", out.Len(), len(full))
+	}
+
+	if _, err := scheduler.RemoveProject("demo", "production"); err != nil {
+		t.Fatalf("remove project: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(scheduler.dataDir, jobLogsDirName, "demo", "production")); !os.IsNotExist(err) {
+		t.Fatalf("job logs survived project removal: %v", err)
+	}
+}
+
+func TestJobLogArchiveStopsAtCap(t *testing.T) {
+	scheduler := newTestJobScheduler(t)
+	scheduler.runJob = func(ctx context.Context, spec JobSpec, container string, output io.Writer) (int, error) {
+		_, _ = io.WriteString(output, "0123456789")
+		_, _ = io.WriteString(output, "abcdef\n")
+		return 0, nil
+	}
+	spec := validJobSpecFixture()
+	spec.LogMaxBytes = 12
+
+	record := scheduler.executeJob(context.Background(), spec, JobTriggerManual, nil)
+	if record.LogBytes != 12 || !record.LogTruncated {
+		t.Fatalf("record = %+v", record)
+	}
+	if !strings.Contains(record.Output, "abcdef") {
+		t.Fatalf("run record output lost past the archive cap: %q", record.Output)
+	}
+	data, err := os.ReadFile(jobLogPath(scheduler.dataDir, "demo", "production", "report", record.Container))
+	if err != nil {
+		t.Fatalf("read archive: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "0123456789ab\n... job log archive truncated at 12 byte(s)") || strings.Contains(string(data), "cdef") {
+		t.Fatalf("archive = %q", data)
+	}
+}
+
+func TestStreamLogsSelectsRunAttempts(t *testing.T) {
+	scheduler := newTestJobScheduler(t)
+	calls := 0
+	scheduler.runJob = func(ctx context.Context, spec JobSpec, container string, output io.Writer) (int, error) {
+		calls++
+		if calls == 1 {
+			_, _ = io.WriteString(output, "attempt one failed\n")
+			return 1, nil
+		}
+		_, _ = io.WriteString(output, "attempt two ok\n")
+		return 0, nil
+	}
+	scheduler.sleep = func(context.Context, time.Duration) error { return nil }
+	spec := validJobSpecFixture()
+	spec.Retries = 1
+
+	record := scheduler.executeJob(context.Background(), spec, JobTriggerManual, nil)
+	later := scheduler.executeJob(context.Background(), spec, JobTriggerManual, nil)
+
+	var out bytes.Buffer
+	if err := scheduler.StreamLogs(context.Background(), "demo", "production", "report", record.RunID, false, &out); err != nil {
+		t.Fatalf("stream logs: %v", err)
+	}
+	first := strings.Index(out.String(), "--- attempt 1 of 2")
+	second := strings.Index(out.String(), "--- attempt 2 of 2")
+	if first < 0 || second < first || !strings.Contains(out.String(), "attempt one failed") || !strings.Contains(out.String(), "attempt two ok") {
+		t.Fatalf("run logs = %q", out.String())
+	}
+
+	out.Reset()
+	if err := scheduler.StreamLogs(context.Background(), "demo", "production", "report", later.Container, false, &out); err != nil {
+		t.Fatalf("stream logs by container: %v", err)
+	}
+	if strings.Count(out.String(), "--- attempt") != 1 || !strings.Contains(out.String(), later.Container) {
+		t.Fatalf("container logs = %q", out.String())
+	}
+
+	if err := scheduler.StreamLogs(context.Background(), "demo", "production", "report", "run-missing", false, io.Discard); err == nil || !strings.Contains(err.Error(), "no archived run") {
+		t.Fatalf("unknown run err = %v", err)
+	}
+}
+
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestStreamLogsFollowsRunInProgress(t *testing.T) {
+	scheduler := newTestJobScheduler(t)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	scheduler.runJob = func(ctx context.Context, spec JobSpec, container string, output io.Writer) (int, error) {
+		_, _ = io.WriteString(output, "first\n")
+		close(started)
+		<-release
+		_, _ = io.WriteString(output, "second\n")
+		return 0, nil
+	}
+	spec := validJobSpecFixture()
+	done := make(chan JobRunRecord, 1)
+	go func() { done <- scheduler.executeJob(context.Background(), spec, JobTriggerManual, nil) }()
+	<-started
+
+	var out lockedBuffer
+	streamed := make(chan error, 1)
+	go func() {
+		streamed <- scheduler.StreamLogs(context.Background(), "demo", "production", "report", "", true, &out)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(out.String(), "first") {
+		if time.Now().After(deadline) {
+			t.Fatalf("follow never streamed the running attempt: %q", out.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(release)
+	<-done
+
+	select {
+	case err := <-streamed:
+		if err != nil {
+			t.Fatalf("stream logs: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("follow did not end after the run finished")
+	}
+	if out.String() != "first\nsecond\n" {
+		t.Fatalf("followed logs = %q", out.String())
+	}
+}
+
+func TestPruneJobLogsDropsUnrecordedAndExpiredArchives(t *testing.T) {
+	scheduler := newTestJobScheduler(t)
+	spec := validJobSpecFixture()
+	spec.LogRetainDays = 1
+	old := scheduler.executeJob(context.Background(), spec, JobTriggerManual, nil)
+	oldPath := jobLogPath(scheduler.dataDir, "demo", "production", "report", old.Container)
+	expired := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(oldPath, expired, expired); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	orphan := jobLogPath(scheduler.dataDir, "demo", "production", "report", "tako_demo_production_report_job_1")
+	if err := os.WriteFile(orphan, []byte("orphan\n"), 0600); err != nil {
+		t.Fatalf("write orphan: %v", err)
+	}
+
+	current := scheduler.executeJob(context.Background(), spec, JobTriggerManual, nil)
+
+	for _, path := range []string{oldPath, orphan} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("%s survived pruning: %v", filepath.Base(path), err)
+		}
+	}
+	if _, err := os.Stat(jobLogPath(scheduler.dataDir, "demo", "production", "report", current.Container)); err != nil {
+		t.Fatalf("current archive pruned: %v", err)
+	}
+}
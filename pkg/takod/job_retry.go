@@ -0,0 +1,140 @@
+package takod
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Job concurrency policies, mirroring Kubernetes CronJobs: forbid skips a
+// run while another is in progress, replace stops the running run in favor
+// of the new one, and allow runs them side by side.
+const (
+	JobConcurrencyForbid  = "forbid"
+	JobConcurrencyReplace = "replace"
+	JobConcurrencyAllow   = "allow"
+)
+
+const (
+	// maxJobRetries bounds the retries a job spec may request.
+	maxJobRetries = 10
+	// defaultJobRetryBackoffSeconds is the first retry delay when the spec
+	// sets none; each further retry doubles it.
+	defaultJobRetryBackoffSeconds = 10
+	// maxJobRetryBackoff caps the delay between two attempts.
+	maxJobRetryBackoff = 15 * time.Minute
+)
+
+// jobRunSlot is one reserved run of a job. cancel is set once the run
+// starts; a replacing run marks the slot replaced and cancels it. runID and
+// the current attempt's container let log followers find output that has no
+// run record yet.
+type jobRunSlot struct {
+	cancel      context.CancelFunc
+	replaced    bool
+	runID       string
+	container   string
+	attempt     int
+	maxAttempts int
+}
+
+// reserveJobRunLocked applies the concurrency policy to a new run of key and
+// returns its slot, or nil when the policy forbids the run. Callers hold
+// s.mu.
+func (s *JobScheduler) reserveJobRunLocked(key string, policy string) *jobRunSlot {
+	active := s.running[key]
+	switch policy {
+	case JobConcurrencyAllow:
+	case JobConcurrencyReplace:
+		for _, slot := range active {
+			slot.replaced = true
+			if slot.cancel != nil {
+				slot.cancel()
+			}
+		}
+	default:
+		if len(active) > 0 {
+			return nil
+		}
+	}
+	slot := &jobRunSlot{}
+	s.running[key] = append(active, slot)
+	return slot
+}
+
+// releaseJobRunLocked drops a finished run's slot. Callers hold s.mu.
+func (s *JobScheduler) releaseJobRunLocked(key string, slot *jobRunSlot) {
+	active := s.running[key]
+	for i, candidate := range active {
+		if candidate == slot {
+			active = append(active[:i], active[i+1:]...)
+			break
+		}
+	}
+	if len(active) == 0 {
+		delete(s.running, key)
+		return
+	}
+	s.running[key] = active
+}
+
+func (s *JobScheduler) jobRunReplaced(slot *jobRunSlot) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slot.replaced
+}
+
+func validateJobRetryPolicy(spec JobSpec) error {
+	if spec.Retries < 0 || spec.Retries > maxJobRetries {
+		return fmt.Errorf("retries must be between 0 and %d", maxJobRetries)
+	}
+	if spec.RetryBackoffSeconds < 0 || time.Duration(spec.RetryBackoffSeconds)*time.Second > maxJobRetryBackoff {
+		return fmt.Errorf("retryBackoffSeconds must be between 0 and %d", int(maxJobRetryBackoff/time.Second))
+	}
+	switch spec.ConcurrencyPolicy {
+	case "", JobConcurrencyForbid, JobConcurrencyReplace, JobConcurrencyAllow:
+		return nil
+	default:
+		return fmt.Errorf("concurrencyPolicy must be forbid, replace, or allow")
+	}
+}
+
+// jobRetryDelay is the exponential backoff before the attempt after the
+// given one: the base delay doubles per retry, capped at maxJobRetryBackoff.
+func jobRetryDelay(backoffSeconds int, attempt int) time.Duration {
+	if backoffSeconds <= 0 {
+		backoffSeconds = defaultJobRetryBackoffSeconds
+	}
+	delay := time.Duration(backoffSeconds) * time.Second
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= maxJobRetryBackoff {
+			return maxJobRetryBackoff
+		}
+	}
+	return delay
+}
+
+// JobRunBudget bounds how long one run of a job may take across all of its
+// attempts and the backoff between them.
+func JobRunBudget(timeoutSeconds int, retries int, backoffSeconds int) time.Duration {
+	if timeoutSeconds <= 0 {
+		timeoutSeconds = defaultJobTimeoutSeconds
+	}
+	budget := time.Duration(retries+1) * time.Duration(timeoutSeconds) * time.Second
+	for attempt := 1; attempt <= retries; attempt++ {
+		budget += jobRetryDelay(backoffSeconds, attempt)
+	}
+	return budget
+}
+
+func sleepJobRetry(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
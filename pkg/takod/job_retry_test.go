@@ -0,0 +1,161 @@
+package takod
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobRetryDelayDoublesAndCaps(t *testing.T) {
+	cases := []struct {
+		backoff int
+		attempt int
+		want    time.Duration
+	}{
+		{0, 1, 10 * time.Second},
+		{0, 2, 20 * time.Second},
+		{30, 3, 2 * time.Minute},
+		{600, 4, maxJobRetryBackoff},
+	}
+	for _, tc := range cases {
+		if got := jobRetryDelay(tc.backoff, tc.attempt); got != tc.want {
+			t.Fatalf("jobRetryDelay(%d, %d) = %s, want %s", tc.backoff, tc.attempt, got, tc.want)
+		}
+	}
+	if got := JobRunBudget(60, 2, 30); got != 3*time.Minute+90*time.Second {
+		t.Fatalf("JobRunBudget = %s", got)
+	}
+}
+
+func TestValidateJobSpecRejectsBadRetryPolicy(t *testing.T) {
+	cases := []struct {
+		name   string
+		mutate func(*JobSpec)
+	}{
+		{"negative retries", func(s *JobSpec) { s.Retries = -1 }},
+		{"excessive retries", func(s *JobSpec) { s.Retries = maxJobRetries + 1 }},
+		{"negative backoff", func(s *JobSpec) { s.RetryBackoffSeconds = -1 }},
+		{"excessive backoff", func(s *JobSpec) { s.RetryBackoffSeconds = int(maxJobRetryBackoff/time.Second) + 1 }},
+		{"unknown policy", func(s *JobSpec) { s.ConcurrencyPolicy = "queue" }},
+	}
+	for _, tc := range cases {
+		spec := validJobSpecFixture()
+		tc.mutate(&spec)
+		if err := validateJobSpec(&spec); err == nil {
+			t.Fatalf("%s: spec accepted", tc.name)
+		}
+	}
+}
+
+func TestExecuteJobRetriesWithBackoffAndRecordsAttempts(t *testing.T) {
+	scheduler := newTestJobScheduler(t)
+	calls := 0
+	scheduler.runJob = func(ctx context.Context, spec JobSpec, container string, output io.Writer) (int, error) {
+		calls++
+		if calls < 3 {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	var delays []time.Duration
+	scheduler.sleep = func(ctx context.Context, delay time.Duration) error {
+		delays = append(delays, delay)
+		return nil
+	}
+	spec := validJobSpecFixture()
+	spec.Retries = 3
+	spec.RetryBackoffSeconds = 5
+
+	var stream bytes.Buffer
+	record := scheduler.executeJob(context.Background(), spec, JobTriggerManual, &stream)
+	if record.Status != JobRunStatusSucceeded || record.Attempt != 3 || record.MaxAttempts != 4 {
+		t.Fatalf("record = %+v", record)
+	}
+	if len(delays) != 2 || delays[0] != 5*time.Second || delays[1] != 10*time.Second {
+		t.Fatalf("delays = %v", delays)
+	}
+	if strings.Count(stream.String(), ExecExitMarker) != 1 || !strings.Contains(stream.String(), "retrying in 5s") {
+		t.Fatalf("stream = %q", stream.String())
+	}
+	runs, err := scheduler.Runs("demo", "production", "report")
+	if err != nil || len(runs) != 3 {
+		t.Fatalf("runs = %+v, err %v", runs, err)
+	}
+	for _, run := range runs {
+		if run.RunID != record.RunID {
+			t.Fatalf("attempt %d has run ID %q, want %q", run.Attempt, run.RunID, record.RunID)
+		}
+	}
+}
+
+func TestExecuteJobStopsAfterLastAttempt(t *testing.T) {
+	scheduler := newTestJobScheduler(t)
+	calls := 0
+	scheduler.runJob = func(ctx context.Context, spec JobSpec, container string, output io.Writer) (int, error) {
+		calls++
+		return 2, nil
+	}
+	scheduler.sleep = func(context.Context, time.Duration) error { return nil }
+	spec := validJobSpecFixture()
+	spec.Retries = 1
+
+	record := scheduler.executeJob(context.Background(), spec, JobTriggerSchedule, nil)
+	if calls != 2 || record.Status != JobRunStatusFailed || record.Attempt != 2 || record.ExitCode != 2 {
+		t.Fatalf("calls = %d, record = %+v", calls, record)
+	}
+}
+
+func TestConcurrencyPolicyAllowRunsSideBySide(t *testing.T) {
+	scheduler := newTestJobScheduler(t)
+	spec := validJobSpecFixture()
+	spec.ConcurrencyPolicy = JobConcurrencyAllow
+	key := jobKey(spec.Project, spec.Environment, spec.Name)
+	scheduler.mu.Lock()
+	scheduler.running[key] = []*jobRunSlot{{}}
+	scheduler.mu.Unlock()
+
+	record := scheduler.executeJob(context.Background(), spec, JobTriggerSchedule, nil)
+	if record.Status != JobRunStatusSucceeded {
+		t.Fatalf("record = %+v", record)
+	}
+	scheduler.mu.Lock()
+	active := len(scheduler.running[key])
+	scheduler.mu.Unlock()
+	if active != 1 {
+		t.Fatalf("active runs after release = %d", active)
+	}
+}
+
+func TestConcurrencyPolicyReplaceStopsRunningRun(t *testing.T) {
+	scheduler := newTestJobScheduler(t)
+	started := make(chan struct{}, 1)
+	scheduler.runJob = func(ctx context.Context, spec JobSpec, container string, output io.Writer) (int, error) {
+		if strings.Contains(spec.Command[len(spec.Command)-1], "block") {
+			started <- struct{}{}
+			<-ctx.Done()
+			return 137, nil
+		}
+		return 0, nil
+	}
+	blocking := validJobSpecFixture()
+	blocking.ConcurrencyPolicy = JobConcurrencyReplace
+	blocking.Command = []string{"sh", "-c", "block"}
+	blocking.Retries = 2
+	done := make(chan JobRunRecord, 1)
+	go func() { done <- scheduler.executeJob(context.Background(), blocking, JobTriggerSchedule, nil) }()
+	<-started
+
+	replacement := validJobSpecFixture()
+	replacement.ConcurrencyPolicy = JobConcurrencyReplace
+	record := scheduler.executeJob(context.Background(), replacement, JobTriggerManual, nil)
+	if record.Status != JobRunStatusSucceeded {
+		t.Fatalf("replacement record = %+v", record)
+	}
+	replaced := <-done
+	if replaced.Status != JobRunStatusReplaced || replaced.Attempt != 1 || !strings.Contains(replaced.Output, "replaced by a newer run") {
+		t.Fatalf("replaced record = %+v", replaced)
+	}
+}
@@ -0,0 +1,261 @@
+package takod
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// IsDependent reports whether the job runs inside its upstream jobs'
+// workflow run instead of on its own schedule.
+func (spec JobSpec) IsDependent() bool {
+	return len(spec.After) > 0 || len(spec.OnSuccess) > 0 || len(spec.OnFailure) > 0
+}
+
+// Upstreams returns every upstream job name across After, OnSuccess, and
+// OnFailure.
+func (spec JobSpec) Upstreams() []string {
+	upstreams := make([]string, 0, len(spec.After)+len(spec.OnSuccess)+len(spec.OnFailure))
+	upstreams = append(upstreams, spec.After...)
+	upstreams = append(upstreams, spec.OnSuccess...)
+	return append(upstreams, spec.OnFailure...)
+}
+
+// newJobRunID returns the ID shared by every job run in one workflow run.
+// It sorts by start time and stays unique across concurrent roots.
+func newJobRunID(now time.Time) string {
+	random := make([]byte, 4)
+	if _, err := rand.Read(random); err != nil {
+		return fmt.Sprintf("run-%s-%08x", now.UTC().Format("20060102T150405Z"), uint32(now.UnixNano()))
+	}
+	return "run-" + now.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(random)
+}
+
+func validateJobUpstreams(spec JobSpec) error {
+	seen := map[string]bool{}
+	for _, upstream := range spec.Upstreams() {
+		if !isSafeServiceName(upstream) {
+			return fmt.Errorf("invalid upstream job name %q", upstream)
+		}
+		if upstream == spec.Name {
+			return fmt.Errorf("job cannot depend on itself")
+		}
+		if seen[upstream] {
+			return fmt.Errorf("upstream job %s is listed more than once", upstream)
+		}
+		seen[upstream] = true
+	}
+	return nil
+}
+
+// pruneJobsWithMissingUpstreams drops dependents whose upstream is absent
+// from the applied set (typically an upstream skipped for lacking an image),
+// cascading to their own dependents, and returns a warning per dropped job.
+func pruneJobsWithMissingUpstreams(desired map[string]JobSpec) []string {
+	var warnings []string
+	for {
+		var missing []string
+		reasons := map[string]string{}
+		for name, spec := range desired {
+			for _, upstream := range spec.Upstreams() {
+				if _, ok := desired[upstream]; !ok {
+					missing = append(missing, name)
+					reasons[name] = upstream
+					break
+				}
+			}
+		}
+		if len(missing) == 0 {
+			return warnings
+		}
+		sort.Strings(missing)
+		for _, name := range missing {
+			delete(desired, name)
+			warnings = append(warnings, fmt.Sprintf("job %s skipped: upstream job %s is not scheduled on this node", name, reasons[name]))
+		}
+	}
+}
+
+// validateJobGraph rejects dependency cycles and dependents whose ancestry
+// does not lead back to exactly one scheduled job, which owns the workflow
+// run they join.
+func validateJobGraph(desired map[string]JobSpec) error {
+	names := make([]string, 0, len(desired))
+	for name := range desired {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	roots := map[string]map[string]bool{}
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("job dependency cycle: %s", strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		spec := desired[name]
+		reached := map[string]bool{}
+		if !spec.IsDependent() {
+			reached[name] = true
+		}
+		for _, upstream := range spec.Upstreams() {
+			if _, ok := desired[upstream]; !ok {
+				return fmt.Errorf("job %s depends on unknown job %s", name, upstream)
+			}
+			if err := visit(upstream, append(path, name)); err != nil {
+				return err
+			}
+			for root := range roots[upstream] {
+				reached[root] = true
+			}
+		}
+		state[name] = visited
+		roots[name] = reached
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		if len(roots[name]) > 1 {
+			scheduled := make([]string, 0, len(roots[name]))
+			for root := range roots[name] {
+				scheduled = append(scheduled, root)
+			}
+			sort.Strings(scheduled)
+			return fmt.Errorf("job %s depends on several scheduled jobs (%s); a workflow must start from a single scheduled job", name, strings.Join(scheduled, ", "))
+		}
+	}
+	return nil
+}
+
+// downstreamJobs returns the jobs reachable from root through dependency
+// edges in dependency order, breaking ties by name.
+func (s *JobScheduler) downstreamJobs(root JobSpec) []JobSpec {
+	s.mu.Lock()
+	dependents := map[string][]string{}
+	specs := map[string]JobSpec{}
+	for _, spec := range s.specs {
+		if spec.Project != root.Project || spec.Environment != root.Environment {
+			continue
+		}
+		specs[spec.Name] = spec
+		for _, upstream := range spec.Upstreams() {
+			dependents[upstream] = append(dependents[upstream], spec.Name)
+		}
+	}
+	s.mu.Unlock()
+
+	reached := map[string]bool{}
+	queue := []string{root.Name}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, dependent := range dependents[name] {
+			if !reached[dependent] {
+				reached[dependent] = true
+				queue = append(queue, dependent)
+			}
+		}
+	}
+
+	pending := map[string]int{}
+	for name := range reached {
+		for _, upstream := range specs[name].Upstreams() {
+			if reached[upstream] {
+				pending[name]++
+			}
+		}
+	}
+	var ready []string
+	for name := range reached {
+		if pending[name] == 0 {
+			ready = append(ready, name)
+		}
+	}
+	var ordered []JobSpec
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		name := ready[0]
+		ready = ready[1:]
+		ordered = append(ordered, specs[name])
+		for _, dependent := range dependents[name] {
+			if !reached[dependent] {
+				continue
+			}
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+	return ordered
+}
+
+// runDownstreamJobs continues the workflow run rootRecord started with the
+// root's downstream jobs: each runs once the statuses of its upstream runs
+// meet its conditions, and is recorded as skipped otherwise.
+func (s *JobScheduler) runDownstreamJobs(ctx context.Context, downstream []JobSpec, root string, rootRecord JobRunRecord) {
+	statuses := map[string]string{root: rootRecord.Status}
+	for _, spec := range downstream {
+		key := jobKey(spec.Project, spec.Environment, spec.Name)
+		if reason := unmetJobConditions(spec, statuses); reason != "" {
+			record := s.recordSkippedJob(spec, JobTriggerDependency, rootRecord.RunID, reason)
+			statuses[spec.Name] = record.Status
+			continue
+		}
+		s.mu.Lock()
+		slot := s.reserveJobRunLocked(key, spec.ConcurrencyPolicy)
+		s.mu.Unlock()
+		if slot == nil {
+			record := s.recordSkippedJob(spec, JobTriggerDependency, rootRecord.RunID, jobSkippedOverlapOutput)
+			statuses[spec.Name] = record.Status
+			continue
+		}
+		record := s.executeReservedJob(ctx, spec, JobTriggerDependency, rootRecord.RunID, slot, nil)
+		statuses[spec.Name] = record.Status
+		if record.Status != JobRunStatusSucceeded {
+			fmt.Fprintf(os.Stderr, "takod job %s in workflow run %s finished %s (exit %d)\n", key, rootRecord.RunID, record.Status, record.ExitCode)
+		}
+	}
+}
+
+// unmetJobConditions explains why spec cannot run given the statuses of the
+// workflow run so far, or returns "" when every condition holds.
+func unmetJobConditions(spec JobSpec, statuses map[string]string) string {
+	for _, upstream := range spec.After {
+		switch statuses[upstream] {
+		case JobRunStatusSucceeded, JobRunStatusFailed, JobRunStatusTimeout:
+		default:
+			return fmt.Sprintf("skipped: upstream job %s did not run", upstream)
+		}
+	}
+	for _, upstream := range spec.OnSuccess {
+		if statuses[upstream] != JobRunStatusSucceeded {
+			return fmt.Sprintf("skipped: upstream job %s did not succeed", upstream)
+		}
+	}
+	for _, upstream := range spec.OnFailure {
+		switch statuses[upstream] {
+		case JobRunStatusFailed, JobRunStatusTimeout:
+		default:
+			return fmt.Sprintf("skipped: upstream job %s did not fail", upstream)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,207 @@
+package takod
+
+import (
+	"context"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func workflowJobFixture(name string, mutate func(*JobSpec)) JobSpec {
+	spec := validJobSpecFixture()
+	spec.Name = name
+	if mutate != nil {
+		spec.Schedule = ""
+		mutate(&spec)
+	}
+	return spec
+}
+
+func etlWorkflowFixture() []JobSpec {
+	return []JobSpec{
+		workflowJobFixture("export", nil),
+		workflowJobFixture("transform", func(s *JobSpec) { s.OnSuccess = []string{"export"} }),
+		workflowJobFixture("upload", func(s *JobSpec) { s.OnSuccess = []string{"transform"} }),
+		workflowJobFixture("alert", func(s *JobSpec) { s.OnFailure = []string{"export"} }),
+		workflowJobFixture("cleanup", func(s *JobSpec) { s.After = []string{"upload"} }),
+	}
+}
+
+func TestValidateJobSpecAcceptsDependentJobWithoutSchedule(t *testing.T) {
+	spec := workflowJobFixture("transform", func(s *JobSpec) { s.After = []string{"export"} })
+	if err := validateJobSpec(&spec); err != nil {
+		t.Fatalf("dependent job rejected: %v", err)
+	}
+
+	cases := []struct {
+		name   string
+		mutate func(*JobSpec)
+	}{
+		{"schedule with upstream", func(s *JobSpec) { s.After = []string{"export"}; s.Schedule = "@daily" }},
+		{"self edge", func(s *JobSpec) { s.After = []string{"transform"} }},
+		{"duplicate edge", func(s *JobSpec) { s.After = []string{"export"}; s.OnFailure = []string{"export"} }},
+		{"bad upstream", func(s *JobSpec) { s.OnSuccess = []string{"Export!"} }},
+	}
+	for _, tc := range cases {
+		spec := workflowJobFixture("transform", tc.mutate)
+		if err := validateJobSpec(&spec); err == nil {
+			t.Fatalf("%s: spec accepted", tc.name)
+		}
+	}
+}
+
+func TestJobsApplyRejectsInvalidJobGraphs(t *testing.T) {
+	cases := []struct {
+		name string
+		jobs []JobSpec
+		want string
+	}{
+		{"cycle", []JobSpec{
+			workflowJobFixture("a", func(s *JobSpec) { s.After = []string{"b"} }),
+			workflowJobFixture("b", func(s *JobSpec) { s.After = []string{"a"} }),
+		}, "cycle"},
+		{"several roots", []JobSpec{
+			workflowJobFixture("a", nil),
+			workflowJobFixture("b", nil),
+			workflowJobFixture("c", func(s *JobSpec) { s.After = []string{"a", "b"} }),
+		}, "several scheduled jobs"},
+	}
+	for _, tc := range cases {
+		scheduler := newTestJobScheduler(t)
+		_, err := scheduler.Apply(context.Background(), JobsApplyRequest{Project: "demo", Environment: "production", Jobs: tc.jobs})
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: err = %v, want %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestJobsApplyDropsDependentsOfMissingUpstream(t *testing.T) {
+	scheduler := newTestJobScheduler(t)
+	jobs := []JobSpec{
+		workflowJobFixture("transform", func(s *JobSpec) { s.After = []string{"export"} }),
+		workflowJobFixture("upload", func(s *JobSpec) { s.After = []string{"transform"} }),
+	}
+	response, err := scheduler.Apply(context.Background(), JobsApplyRequest{Project: "demo", Environment: "production", Jobs: jobs})
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if len(response.Applied) != 0 || len(response.Warnings) != 2 {
+		t.Fatalf("response = %+v", response)
+	}
+	if !strings.Contains(response.Warnings[0], "transform") || !strings.Contains(response.Warnings[1], "upload") {
+		t.Fatalf("warnings = %v", response.Warnings)
+	}
+}
+
+func TestJobsApplyRegistersDependentsWithoutCronEntries(t *testing.T) {
+	scheduler := newTestJobScheduler(t)
+	if _, err := scheduler.Apply(context.Background(), JobsApplyRequest{Project: "demo", Environment: "production", Jobs: etlWorkflowFixture()}); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	scheduler.mu.Lock()
+	entries := len(scheduler.entries)
+	_, rootScheduled := scheduler.entries[jobKey("demo", "production", "export")]
+	scheduler.mu.Unlock()
+	if entries != 1 || !rootScheduled {
+		t.Fatalf("cron entries = %d, root scheduled %v", entries, rootScheduled)
+	}
+	listed := scheduler.List("demo", "production")
+	if len(listed) != 5 {
+		t.Fatalf("list = %+v", listed)
+	}
+	if idx := slices.IndexFunc(listed, func(s JobStatus) bool { return s.Name == "cleanup" }); idx < 0 || !slices.Equal(listed[idx].After, []string{"upload"}) || listed[idx].Schedule != "" {
+		t.Fatalf("cleanup edges not listed: %+v", listed)
+	}
+}
+
+func TestScheduledJobRunsWorkflowInDependencyOrder(t *testing.T) {
+	scheduler := newTestJobScheduler(t)
+	var order []string
+	scheduler.runJob = func(ctx context.Context, spec JobSpec, container string, output io.Writer) (int, error) {
+		order = append(order, spec.Name)
+		return 0, nil
+	}
+	if _, err := scheduler.Apply(context.Background(), JobsApplyRequest{Project: "demo", Environment: "production", Jobs: etlWorkflowFixture()}); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	scheduler.runScheduledJob(jobKey("demo", "production", "export"))
+
+	if want := []string{"export", "transform", "upload", "cleanup"}; !slices.Equal(order, want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	runs, err := scheduler.Runs("demo", "production", "")
+	if err != nil || len(runs) != 5 {
+		t.Fatalf("runs = %+v, err %v", runs, err)
+	}
+	runID := runs[0].RunID
+	if !strings.HasPrefix(runID, "run-") {
+		t.Fatalf("run ID = %q", runID)
+	}
+	statuses := map[string]string{}
+	for _, run := range runs {
+		if run.RunID != runID {
+			t.Fatalf("run %s has run ID %q, want %q", run.Job, run.RunID, runID)
+		}
+		statuses[run.Job] = run.Status
+		if run.Job != "export" && run.Trigger != JobTriggerDependency {
+			t.Fatalf("downstream run %s trigger = %q", run.Job, run.Trigger)
+		}
+	}
+	if statuses["alert"] != JobRunStatusSkipped || statuses["cleanup"] != JobRunStatusSucceeded {
+		t.Fatalf("statuses = %v", statuses)
+	}
+}
+
+func TestScheduledJobWorkflowTakesFailurePath(t *testing.T) {
+	scheduler := newTestJobScheduler(t)
+	var order []string
+	scheduler.runJob = func(ctx context.Context, spec JobSpec, container string, output io.Writer) (int, error) {
+		order = append(order, spec.Name)
+		if spec.Name == "export" {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	if _, err := scheduler.Apply(context.Background(), JobsApplyRequest{Project: "demo", Environment: "production", Jobs: etlWorkflowFixture()}); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	scheduler.runScheduledJob(jobKey("demo", "production", "export"))
+
+	if want := []string{"export", "alert"}; !slices.Equal(order, want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	transform, err := scheduler.Runs("demo", "production", "transform")
+	if err != nil || len(transform) != 1 || transform[0].Status != JobRunStatusSkipped || !strings.Contains(transform[0].Output, "export did not succeed") {
+		t.Fatalf("transform runs = %+v, err %v", transform, err)
+	}
+	cleanup, err := scheduler.Runs("demo", "production", "cleanup")
+	if err != nil || len(cleanup) != 1 || cleanup[0].Status != JobRunStatusSkipped || !strings.Contains(cleanup[0].Output, "upload did not run") {
+		t.Fatalf("cleanup runs = %+v, err %v", cleanup, err)
+	}
+}
+
+func TestTriggerContinuesWorkflowInBackground(t *testing.T) {
+	scheduler := newTestJobScheduler(t)
+	jobs := etlWorkflowFixture()[:3]
+	if _, err := scheduler.Apply(context.Background(), JobsApplyRequest{Project: "demo", Environment: "production", Jobs: jobs}); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	if err := scheduler.Trigger(context.Background(), "demo", "production", "export", io.Discard); err != nil {
+		t.Fatalf("trigger: %v", err)
+	}
+	scheduler.workflows.Wait()
+
+	runs, err := scheduler.Runs("demo", "production", "")
+	if err != nil || len(runs) != 3 {
+		t.Fatalf("runs = %+v, err %v", runs, err)
+	}
+	for _, run := range runs {
+		if run.RunID != runs[0].RunID || run.Status != JobRunStatusSucceeded {
+			t.Fatalf("runs = %+v", runs)
+		}
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/redentordev/tako-cli/pkg/config"
+	"github.com/redentordev/tako-cli/pkg/notification"
 	"github.com/redentordev/tako-cli/pkg/recovery"
 	"github.com/robfig/cron/v3"
 )
@@ -44,27 +45,40 @@ const (
 	JobRunStatusFailed    = "failed"
 	JobRunStatusTimeout   = "timeout"
 	JobRunStatusSkipped   = "skipped"
+	// JobRunStatusReplaced marks a run stopped by a newer run under the
+	// replace concurrency policy.
+	JobRunStatusReplaced = "replaced"
 )
 
-// Job run triggers.
+// Job run triggers. JobTriggerDependency marks a run started by the
+// completion of its upstream jobs within a workflow run.
 const (
-	JobTriggerSchedule = "schedule"
-	JobTriggerManual   = "manual"
+	JobTriggerSchedule   = "schedule"
+	JobTriggerManual     = "manual"
+	JobTriggerDependency = "dependency"
 )
 
 // JobSpec declares one scheduled job on this node. The owning node receives
 // the spec at deploy time via /v1/jobs/apply and fires it with its local
 // cron; each run is a fresh one-off container from Image.
 type JobSpec struct {
-	Project     string            `json:"project"`
-	Environment string            `json:"environment"`
-	Name        string            `json:"name"`
-	Schedule    string            `json:"schedule"`
-	Timezone    string            `json:"timezone,omitempty"`
-	Image       string            `json:"image"`
-	Command     []string          `json:"command"`
-	Entrypoint  []string          `json:"entrypoint,omitempty"`
-	Labels      map[string]string `json:"labels,omitempty"`
+	Project     string `json:"project"`
+	Environment string `json:"environment"`
+	Name        string `json:"name"`
+	// Schedule is empty for dependent jobs, which run only inside the
+	// workflow run of their upstream jobs.
+	Schedule string `json:"schedule,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+	// After, OnSuccess, and OnFailure name upstream jobs on the same node. A
+	// dependent job runs once every After job has finished, every OnSuccess
+	// job has succeeded, and every OnFailure job has failed or timed out.
+	After      []string          `json:"after,omitempty"`
+	OnSuccess  []string          `json:"onSuccess,omitempty"`
+	OnFailure  []string          `json:"onFailure,omitempty"`
+	Image      string            `json:"image"`
+	Command    []string          `json:"command"`
+	Entrypoint []string          `json:"entrypoint,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
 	// EnvFileContent carries the job's env/secrets; it is written to a 0600
 	// temp file per run and passed via --env-file.
 	EnvFileContent string   `json:"envFileContent,omitempty"`
@@ -84,6 +98,19 @@ type JobSpec struct {
 	Ulimits            map[string]config.UlimitConfig `json:"ulimits,omitempty"`
 	ShmSize            string                         `json:"shmSize,omitempty"`
 	TimeoutSeconds     int                            `json:"timeoutSeconds,omitempty"`
+	// Retries re-runs a failed or timed-out attempt up to this many times,
+	// waiting RetryBackoffSeconds (default 10s) doubled per retry.
+	Retries             int `json:"retries,omitempty"`
+	RetryBackoffSeconds int `json:"retryBackoffSeconds,omitempty"`
+	// ConcurrencyPolicy is forbid (default), replace, or allow.
+	ConcurrencyPolicy string `json:"concurrencyPolicy,omitempty"`
+	// LogMaxBytes caps each attempt's archived output (default 64 MiB);
+	// LogRetainDays bounds how long archives are kept (default 14).
+	LogMaxBytes   int64 `json:"logMaxBytes,omitempty"`
+	LogRetainDays int   `json:"logRetainDays,omitempty"`
+	// Notifications receives JobFailed, JobRecovered, and JobMissedSchedule
+	// alerts; nil disables job alerting.
+	Notifications *JobNotifications `json:"notifications,omitempty"`
 	// ConfigHash is the deployer's fingerprint of the job's service config,
 	// reported back through actual state for drift/plan comparison.
 	ConfigHash string `json:"configHash,omitempty"`
@@ -117,25 +144,36 @@ type JobTriggerRequest struct {
 // JobStatus describes a scheduled job without its env-file content, which
 // must never leave the node through list responses.
 type JobStatus struct {
-	Project        string        `json:"project"`
-	Environment    string        `json:"environment"`
-	Name           string        `json:"name"`
-	Schedule       string        `json:"schedule"`
-	Timezone       string        `json:"timezone,omitempty"`
-	Image          string        `json:"image"`
-	Command        []string      `json:"command"`
-	TimeoutSeconds int           `json:"timeoutSeconds,omitempty"`
-	ConfigHash     string        `json:"configHash,omitempty"`
-	NextRun        *time.Time    `json:"nextRun,omitempty"`
-	LastRun        *JobRunRecord `json:"lastRun,omitempty"`
-}
-
-// JobRunRecord is one completed (or skipped) run in a job's history.
+	Project             string        `json:"project"`
+	Environment         string        `json:"environment"`
+	Name                string        `json:"name"`
+	Schedule            string        `json:"schedule,omitempty"`
+	Timezone            string        `json:"timezone,omitempty"`
+	After               []string      `json:"after,omitempty"`
+	OnSuccess           []string      `json:"onSuccess,omitempty"`
+	OnFailure           []string      `json:"onFailure,omitempty"`
+	Image               string        `json:"image"`
+	Command             []string      `json:"command"`
+	TimeoutSeconds      int           `json:"timeoutSeconds,omitempty"`
+	Retries             int           `json:"retries,omitempty"`
+	RetryBackoffSeconds int           `json:"retryBackoffSeconds,omitempty"`
+	ConcurrencyPolicy   string        `json:"concurrencyPolicy,omitempty"`
+	ConfigHash          string        `json:"configHash,omitempty"`
+	NextRun             *time.Time    `json:"nextRun,omitempty"`
+	LastRun             *JobRunRecord `json:"lastRun,omitempty"`
+}
+
+// JobRunRecord is one completed (or skipped) attempt in a job's history.
+// Every job run within one workflow run shares its RunID; a job with
+// retries records each attempt, numbered from 1 up to MaxAttempts.
 type JobRunRecord struct {
 	Project     string    `json:"project"`
 	Environment string    `json:"environment"`
 	Job         string    `json:"job"`
+	RunID       string    `json:"runId,omitempty"`
 	Trigger     string    `json:"trigger"`
+	Attempt     int       `json:"attempt,omitempty"`
+	MaxAttempts int       `json:"maxAttempts,omitempty"`
 	Container   string    `json:"container,omitempty"`
 	StartedAt   time.Time `json:"startedAt"`
 	FinishedAt  time.Time `json:"finishedAt"`
@@ -144,6 +182,10 @@ type JobRunRecord struct {
 	Status      string    `json:"status"`
 	// Output is the bounded tail of the run's combined output.
 	Output string `json:"output,omitempty"`
+	// LogBytes is the size of the attempt's full output archive, served by
+	// /v1/jobs/logs; LogTruncated marks an archive that hit its size cap.
+	LogBytes     int64 `json:"logBytes,omitempty"`
+	LogTruncated bool  `json:"logTruncated,omitempty"`
 }
 
 // JobScheduler fires job specs with a local cron, mirroring BackupScheduler:
@@ -155,27 +197,44 @@ type JobScheduler struct {
 	// runJob is the container-execution seam; tests stub it.
 	runJob func(ctx context.Context, spec JobSpec, container string, output io.Writer) (int, error)
 	admit  func(...string) error
+	// sleep waits out retry backoff; tests stub it.
+	sleep func(ctx context.Context, delay time.Duration) error
+	// notify delivers job alerts; tests stub it.
+	notify func(targets JobNotifications, event notification.Event) error
 
 	mu      sync.Mutex
 	entries map[string]cron.EntryID
 	specs   map[string]JobSpec
-	running map[string]bool
+	running map[string][]*jobRunSlot
+	// registered is when each job was last applied or loaded; missed holds
+	// the expected run a missed-schedule alert was already sent for.
+	registered map[string]time.Time
+	missed     map[string]time.Time
 
 	// runsMu serializes run-history read-modify-write cycles: a skipped-run
 	// record can land while the blocking run still owns the running flag.
 	runsMu sync.Mutex
+
+	// workflows tracks downstream runs continuing after a manual trigger.
+	workflows sync.WaitGroup
+	// alerts tracks job notifications still being delivered.
+	alerts sync.WaitGroup
 }
 
 func NewJobScheduler(dataDir string) *JobScheduler {
 	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
 	return &JobScheduler{
-		dataDir: dataDir,
-		parser:  parser,
-		cron:    cron.New(cron.WithParser(parser), cron.WithChain(cron.Recover(cron.DefaultLogger))),
-		runJob:  runJobDocker,
-		entries: map[string]cron.EntryID{},
-		specs:   map[string]JobSpec{},
-		running: map[string]bool{},
+		dataDir:    dataDir,
+		parser:     parser,
+		cron:       cron.New(cron.WithParser(parser), cron.WithChain(cron.Recover(cron.DefaultLogger))),
+		runJob:     runJobDocker,
+		sleep:      sleepJobRetry,
+		notify:     deliverJobNotification,
+		entries:    map[string]cron.EntryID{},
+		specs:      map[string]JobSpec{},
+		running:    map[string][]*jobRunSlot{},
+		registered: map[string]time.Time{},
+		missed:     map[string]time.Time{},
 	}
 }
 
@@ -188,10 +247,18 @@ func (s *JobScheduler) Run(ctx context.Context) {
 		fmt.Fprintf(os.Stderr, "takod job scheduler failed to load jobs: %v\n", err)
 	}
 	s.cron.Start()
+	go s.watchMissedSchedules(ctx)
 	<-ctx.Done()
 	stopCtx := s.cron.Stop()
+	workflowsDone := make(chan struct{})
+	go func() {
+		<-stopCtx.Done()
+		s.workflows.Wait()
+		s.alerts.Wait()
+		close(workflowsDone)
+	}()
 	select {
-	case <-stopCtx.Done():
+	case <-workflowsDone:
 	case <-time.After(30 * time.Second):
 	}
 }
@@ -235,6 +302,10 @@ func (s *JobScheduler) Apply(ctx context.Context, request JobsApplyRequest) (*Jo
 		}
 		desired[spec.Name] = spec
 	}
+	warnings = append(warnings, pruneJobsWithMissingUpstreams(desired)...)
+	if err := validateJobGraph(desired); err != nil {
+		return nil, err
+	}
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
@@ -266,12 +337,15 @@ func (s *JobScheduler) Apply(ctx context.Context, request JobsApplyRequest) (*Jo
 		}
 		s.mu.Lock()
 		err := s.scheduleLocked(spec)
+		if err == nil {
+			s.registered[jobKey(spec.Project, spec.Environment, spec.Name)] = time.Now()
+		}
 		s.mu.Unlock()
 		if err != nil {
 			return nil, err
 		}
 		s.mu.Lock()
-		running := s.running[jobKey(spec.Project, spec.Environment, spec.Name)]
+		running := len(s.running[jobKey(spec.Project, spec.Environment, spec.Name)]) > 0
 		s.mu.Unlock()
 		if !running {
 			if err := cleanupServiceFileVersions(spec.Project, spec.Environment, spec.Name, spec.FileSetID); err != nil {
@@ -303,7 +377,8 @@ func (s *JobScheduler) Apply(ctx context.Context, request JobsApplyRequest) (*Jo
 }
 
 // RemoveProject unschedules every job for a project (one environment, or all
-// when environment is empty) and deletes its specs and run history.
+// when environment is empty) and deletes its specs, run history, and log
+// archives.
 func (s *JobScheduler) RemoveProject(project string, environment string) ([]string, error) {
 	if s == nil {
 		return nil, nil
@@ -337,7 +412,7 @@ func (s *JobScheduler) RemoveProject(project string, environment string) ([]stri
 	}
 	sort.Strings(removed)
 
-	for _, dir := range []string{jobSpecDirName, jobRunsDirName} {
+	for _, dir := range []string{jobSpecDirName, jobRunsDirName, jobLogsDirName} {
 		path := filepath.Join(s.dataDir, dir, project)
 		if environment != "" {
 			path = filepath.Join(path, environment)
@@ -382,15 +457,21 @@ func (s *JobScheduler) List(project string, environment string) []JobStatus {
 	for _, item := range snapshot {
 		spec := item.spec
 		status := JobStatus{
-			Project:        spec.Project,
-			Environment:    spec.Environment,
-			Name:           spec.Name,
-			Schedule:       spec.Schedule,
-			Timezone:       spec.Timezone,
-			Image:          spec.Image,
-			Command:        append([]string(nil), spec.Command...),
-			TimeoutSeconds: spec.TimeoutSeconds,
-			ConfigHash:     spec.ConfigHash,
+			Project:             spec.Project,
+			Environment:         spec.Environment,
+			Name:                spec.Name,
+			Schedule:            spec.Schedule,
+			Timezone:            spec.Timezone,
+			After:               append([]string(nil), spec.After...),
+			OnSuccess:           append([]string(nil), spec.OnSuccess...),
+			OnFailure:           append([]string(nil), spec.OnFailure...),
+			Image:               spec.Image,
+			Command:             append([]string(nil), spec.Command...),
+			TimeoutSeconds:      spec.TimeoutSeconds,
+			Retries:             spec.Retries,
+			RetryBackoffSeconds: spec.RetryBackoffSeconds,
+			ConcurrencyPolicy:   spec.ConcurrencyPolicy,
+			ConfigHash:          spec.ConfigHash,
 		}
 		if item.has {
 			if next := s.cron.Entry(item.entry).Next; !next.IsZero() {
@@ -455,7 +536,9 @@ func (s *JobScheduler) Runs(project string, environment string, job string) ([]J
 
 // Trigger runs a scheduled job immediately, streaming raw output framed by
 // the exec markers to stream. An overlapping run surfaces as an error before
-// any bytes are streamed.
+// any bytes are streamed unless the job's concurrency policy allows or
+// replaces it. Downstream jobs continue the workflow run in the background
+// once the triggered job finishes.
 func (s *JobScheduler) Trigger(ctx context.Context, project string, environment string, job string, stream io.Writer) error {
 	if s == nil {
 		return fmt.Errorf("job scheduler is not initialized")
@@ -472,79 +555,160 @@ func (s *JobScheduler) Trigger(ctx context.Context, project string, environment
 	s.mu.Lock()
 	key := jobKey(project, environment, job)
 	spec, ok := s.specs[key]
-	reserved := ok && !s.running[key]
-	if reserved {
-		s.running[key] = true
+	var slot *jobRunSlot
+	if ok {
+		slot = s.reserveJobRunLocked(key, spec.ConcurrencyPolicy)
 	}
 	s.mu.Unlock()
 	if !ok {
 		return fmt.Errorf("job %s is not scheduled for %s/%s on this node", job, project, environment)
 	}
-	var record JobRunRecord
-	if reserved {
-		record = s.executeReservedJob(ctx, spec, JobTriggerManual, stream)
-	} else {
-		record = s.recordSkippedJob(spec, JobTriggerManual)
-	}
-	if record.Status == JobRunStatusSkipped {
+	runID := newJobRunID(time.Now())
+	if slot == nil {
+		s.recordSkippedJob(spec, JobTriggerManual, runID, jobSkippedOverlapOutput)
 		return fmt.Errorf("job %s is already running; try again after it finishes", job)
 	}
+	record := s.executeReservedJob(ctx, spec, JobTriggerManual, runID, slot, stream)
+	downstream := s.downstreamJobs(spec)
+	if len(downstream) == 0 {
+		return nil
+	}
+	// The trigger response ends with the triggered job; the rest of the
+	// workflow run continues under the snapshot lock like a scheduled run.
+	s.workflows.Add(1)
+	go func() {
+		defer s.workflows.Done()
+		unlock, err := recovery.AcquireMutationLock(s.dataDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "takod job workflow %s snapshot lock failed: %v\n", runID, err)
+			return
+		}
+		defer unlock()
+		s.runDownstreamJobs(context.Background(), downstream, spec.Name, record)
+	}()
 	return nil
 }
 
 // executeJob runs one job to completion and records the run. A nil stream
 // captures output only; a non-nil stream additionally receives raw output
-// framed by ExecContainerMarker/ExecExitMarker lines. Overlapping runs are
-// skipped and recorded as such without touching the stream.
+// framed by ExecContainerMarker/ExecExitMarker lines. Runs the concurrency
+// policy forbids are skipped and recorded as such without touching the
+// stream.
 func (s *JobScheduler) executeJob(ctx context.Context, spec JobSpec, trigger string, stream io.Writer) JobRunRecord {
 	key := jobKey(spec.Project, spec.Environment, spec.Name)
+	runID := newJobRunID(time.Now())
 	s.mu.Lock()
-	if s.running[key] {
-		s.mu.Unlock()
-		return s.recordSkippedJob(spec, trigger)
-	}
-	s.running[key] = true
+	slot := s.reserveJobRunLocked(key, spec.ConcurrencyPolicy)
 	s.mu.Unlock()
-	return s.executeReservedJob(ctx, spec, trigger, stream)
+	if slot == nil {
+		return s.recordSkippedJob(spec, trigger, runID, jobSkippedOverlapOutput)
+	}
+	return s.executeReservedJob(ctx, spec, trigger, runID, slot, stream)
 }
 
-func (s *JobScheduler) recordSkippedJob(spec JobSpec, trigger string) JobRunRecord {
+// jobSkippedOverlapOutput explains a run skipped because the job was busy.
+const jobSkippedOverlapOutput = "skipped: previous run still in progress"
+
+func (s *JobScheduler) recordSkippedJob(spec JobSpec, trigger string, runID string, reason string) JobRunRecord {
 	started := time.Now().UTC()
 	record := JobRunRecord{
 		Project:     spec.Project,
 		Environment: spec.Environment,
 		Job:         spec.Name,
+		RunID:       runID,
 		Trigger:     trigger,
 		StartedAt:   started,
 		FinishedAt:  started,
 		ExitCode:    -1,
 		Status:      JobRunStatusSkipped,
-		Output:      "skipped: previous run still in progress",
+		Output:      reason,
 	}
 	s.appendRunRecord(record)
 	return record
 }
 
-// executeReservedJob runs a job whose running reference was acquired while
-// the scheduler spec was still protected by s.mu.
-func (s *JobScheduler) executeReservedJob(ctx context.Context, spec JobSpec, trigger string, stream io.Writer) JobRunRecord {
+// executeReservedJob runs a job whose run slot was reserved while the
+// scheduler spec was still protected by s.mu. Failed and timed-out attempts
+// are retried with exponential backoff up to spec.Retries times; every
+// attempt is recorded and the last one is returned.
+func (s *JobScheduler) executeReservedJob(ctx context.Context, spec JobSpec, trigger string, runID string, slot *jobRunSlot, stream io.Writer) JobRunRecord {
 	key := jobKey(spec.Project, spec.Environment, spec.Name)
-	started := time.Now().UTC()
 	defer func() {
 		s.mu.Lock()
-		delete(s.running, key)
+		s.releaseJobRunLocked(key, slot)
+		idle := len(s.running[key]) == 0
 		current, exists := s.specs[key]
 		s.mu.Unlock()
+		if !idle {
+			return
+		}
 		keep := ""
 		if exists {
 			keep = current.FileSetID
 		}
 		_ = cleanupServiceFileVersions(spec.Project, spec.Environment, spec.Name, keep)
 	}()
+	defer s.pruneJobLogs(spec)
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	runCtx, cancelRun := context.WithCancel(ctx)
+	defer cancelRun()
+	s.mu.Lock()
+	slot.cancel = cancelRun
+	slot.runID = runID
+	if slot.replaced {
+		cancelRun()
+	}
+	s.mu.Unlock()
+
+	maxAttempts := spec.Retries + 1
+	var record JobRunRecord
+	for attempt := 1; ; attempt++ {
+		record = s.runJobAttempt(runCtx, spec, trigger, runID, slot, attempt, stream)
+		if record.Status == JobRunStatusSucceeded || record.Status == JobRunStatusReplaced || attempt >= maxAttempts || runCtx.Err() != nil {
+			break
+		}
+		delay := jobRetryDelay(spec.RetryBackoffSeconds, attempt)
+		if stream != nil {
+			fmt.Fprintf(stream, "job attempt %d of %d %s; retrying in %s\n", attempt, maxAttempts, record.Status, delay)
+		}
+		if err := s.sleep(runCtx, delay); err != nil {
+			break
+		}
+	}
+	if stream != nil {
+		fmt.Fprintf(stream, "%s%d\n", ExecExitMarker, record.ExitCode)
+	}
+	s.alertJobRun(spec, record)
+	return record
+}
+
+// runJobAttempt runs and records one attempt of a job run. A non-nil stream
+// receives the attempt's container marker and raw output.
+func (s *JobScheduler) runJobAttempt(ctx context.Context, spec JobSpec, trigger string, runID string, slot *jobRunSlot, attempt int, stream io.Writer) JobRunRecord {
+	started := time.Now().UTC()
+	record := JobRunRecord{
+		Project:     spec.Project,
+		Environment: spec.Environment,
+		Job:         spec.Name,
+		RunID:       runID,
+		Trigger:     trigger,
+		StartedAt:   started,
+	}
+	if spec.Retries > 0 {
+		record.Attempt = attempt
+		record.MaxAttempts = spec.Retries + 1
+	}
 	if s.admit != nil {
 		if err := s.admit(s.dataDir); err != nil {
 			finished := time.Now().UTC()
-			record := JobRunRecord{Project: spec.Project, Environment: spec.Environment, Job: spec.Name, Trigger: trigger, StartedAt: started, FinishedAt: finished, DurationMs: finished.Sub(started).Milliseconds(), ExitCode: -1, Status: JobRunStatusFailed, Output: "job denied by resource admission: " + err.Error()}
+			record.FinishedAt = finished
+			record.DurationMs = finished.Sub(started).Milliseconds()
+			record.ExitCode = -1
+			record.Status = JobRunStatusFailed
+			record.Output = "job denied by resource admission: " + err.Error()
 			s.appendRunRecord(record)
 			return record
 		}
@@ -558,63 +722,75 @@ func (s *JobScheduler) executeReservedJob(ctx context.Context, spec JobSpec, tri
 	container := fmt.Sprintf("tako_%s_%s_%s_job_%d", spec.Project, spec.Environment, spec.Name, time.Now().UnixNano())
 
 	capped := newCappedOutputBuffer(jobRunOutputMaxBytes)
-	target := io.Writer(capped)
+	targets := []io.Writer{capped}
+	archive := s.jobLogArchiveFor(spec, container)
+	if archive != nil {
+		targets = append(targets, archive)
+	}
 	if stream != nil {
 		fmt.Fprintf(stream, "%s%s\n", ExecContainerMarker, container)
-		target = io.MultiWriter(stream, capped)
+		targets = append([]io.Writer{stream}, targets...)
 	}
-	out := &lineStartWriter{writer: target}
+	out := &lineStartWriter{writer: io.MultiWriter(targets...)}
+	s.mu.Lock()
+	slot.container = container
+	slot.attempt = record.Attempt
+	slot.maxAttempts = record.MaxAttempts
+	s.mu.Unlock()
 
-	if ctx == nil {
-		ctx = context.Background()
-	}
 	runCtx, cancel := context.WithTimeout(ctx, timeout)
 	exitCode, runErr := s.runJob(runCtx, spec, container, out)
 	timedOut := errors.Is(runCtx.Err(), context.DeadlineExceeded)
 	cancel()
-	if timedOut {
+	replaced := s.jobRunReplaced(slot)
+	if timedOut || replaced {
 		removeExecContainer(container)
 	}
 
-	if runErr != nil {
+	switch {
+	case replaced:
+		out.ensureLineStart()
+		fmt.Fprintf(out, "job run replaced by a newer run\n")
+	case runErr != nil:
 		out.ensureLineStart()
 		fmt.Fprintf(out, "job run failed: %v\n", runErr)
-	} else if timedOut {
+	case timedOut:
 		out.ensureLineStart()
 		fmt.Fprintf(out, "job run timed out after %s\n", timeout)
 	}
 	out.ensureLineStart()
-	if stream != nil {
-		fmt.Fprintf(stream, "%s%d\n", ExecExitMarker, exitCode)
-	}
 
 	status := JobRunStatusSucceeded
 	switch {
+	case replaced:
+		status = JobRunStatusReplaced
 	case timedOut:
 		status = JobRunStatusTimeout
 	case runErr != nil || exitCode != 0:
 		status = JobRunStatusFailed
 	}
 	finished := time.Now().UTC()
-	record := JobRunRecord{
-		Project:     spec.Project,
-		Environment: spec.Environment,
-		Job:         spec.Name,
-		Trigger:     trigger,
-		Container:   container,
-		StartedAt:   started,
-		FinishedAt:  finished,
-		DurationMs:  finished.Sub(started).Milliseconds(),
-		ExitCode:    exitCode,
-		Status:      status,
-		Output:      capped.String(),
+	record.Container = container
+	record.FinishedAt = finished
+	record.DurationMs = finished.Sub(started).Milliseconds()
+	record.ExitCode = exitCode
+	record.Status = status
+	record.Output = capped.String()
+	if archive != nil {
+		_ = archive.Close()
+		record.LogBytes = archive.written
+		record.LogTruncated = archive.truncated
 	}
 	s.appendRunRecord(record)
+	s.mu.Lock()
+	slot.container = ""
+	s.mu.Unlock()
 	return record
 }
 
 // runScheduledJob is the cron entry point: it resolves the current spec so
-// a re-applied job fires with its latest definition.
+// a re-applied job fires with its latest definition, then runs the job's
+// downstream workflow under the same run ID.
 func (s *JobScheduler) runScheduledJob(key string) {
 	unlock, err := recovery.AcquireMutationLock(s.dataDir)
 	if err != nil {
@@ -624,23 +800,25 @@ func (s *JobScheduler) runScheduledJob(key string) {
 	defer unlock()
 	s.mu.Lock()
 	spec, ok := s.specs[key]
-	reserved := ok && !s.running[key]
-	if reserved {
-		s.running[key] = true
+	var slot *jobRunSlot
+	if ok {
+		slot = s.reserveJobRunLocked(key, spec.ConcurrencyPolicy)
 	}
 	s.mu.Unlock()
 	if !ok {
 		return
 	}
-	var record JobRunRecord
-	if reserved {
-		record = s.executeReservedJob(context.Background(), spec, JobTriggerSchedule, nil)
-	} else {
-		record = s.recordSkippedJob(spec, JobTriggerSchedule)
+	runID := newJobRunID(time.Now())
+	if slot == nil {
+		s.recordSkippedJob(spec, JobTriggerSchedule, runID, jobSkippedOverlapOutput)
+		fmt.Fprintf(os.Stderr, "takod scheduled job %s skipped: previous run still in progress\n", key)
+		return
 	}
+	record := s.executeReservedJob(context.Background(), spec, JobTriggerSchedule, runID, slot, nil)
 	if record.Status != JobRunStatusSucceeded {
 		fmt.Fprintf(os.Stderr, "takod scheduled job %s finished %s (exit %d)\n", key, record.Status, record.ExitCode)
 	}
+	s.runDownstreamJobs(context.Background(), s.downstreamJobs(spec), spec.Name, record)
 }
 
 // runJobDocker is the production execution seam: a one-off --rm container
@@ -716,7 +894,23 @@ func validateJobSpec(spec *JobSpec) error {
 	if !isSafeServiceName(spec.Name) {
 		return fmt.Errorf("invalid job name")
 	}
-	if strings.TrimSpace(spec.Schedule) == "" {
+	if err := validateJobUpstreams(*spec); err != nil {
+		return err
+	}
+	if err := validateJobRetryPolicy(*spec); err != nil {
+		return err
+	}
+	if err := validateJobLogArchive(*spec); err != nil {
+		return err
+	}
+	if err := validateJobNotifications(spec.Notifications); err != nil {
+		return err
+	}
+	if spec.IsDependent() {
+		if strings.TrimSpace(spec.Schedule) != "" || spec.Timezone != "" {
+			return fmt.Errorf("a job with upstream jobs cannot set a schedule or timezone")
+		}
+	} else if strings.TrimSpace(spec.Schedule) == "" {
 		return fmt.Errorf("schedule is required")
 	}
 	if spec.Timezone != "" {
@@ -727,9 +921,11 @@ func validateJobSpec(spec *JobSpec) error {
 			return fmt.Errorf("invalid timezone: %w", err)
 		}
 	}
-	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
-	if _, err := parser.Parse(jobCronSpec(*spec)); err != nil {
-		return fmt.Errorf("invalid schedule: %w", err)
+	if !spec.IsDependent() {
+		parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+		if _, err := parser.Parse(jobCronSpec(*spec)); err != nil {
+			return fmt.Errorf("invalid schedule: %w", err)
+		}
 	}
 	if err := validateContainerArgv("command", spec.Command); err != nil {
 		return err
@@ -832,8 +1028,17 @@ func (s *JobScheduler) loadSpecs(ctx context.Context) error {
 		if err := validateJobSpec(&spec); err != nil {
 			return fmt.Errorf("invalid job spec %s: %w", path, err)
 		}
+		// The spec file is rewritten on every apply, so its mtime is the
+		// last registration the missed-schedule check counts from.
+		registered := time.Now()
+		if info, err := entry.Info(); err == nil {
+			registered = info.ModTime()
+		}
 		s.mu.Lock()
 		err = s.scheduleLocked(spec)
+		if err == nil {
+			s.registered[jobKey(spec.Project, spec.Environment, spec.Name)] = registered
+		}
 		s.mu.Unlock()
 		return err
 	})
@@ -861,6 +1066,10 @@ func (s *JobScheduler) scheduleLocked(spec JobSpec) error {
 		s.cron.Remove(entryID)
 		delete(s.entries, key)
 	}
+	if spec.IsDependent() {
+		s.specs[key] = spec
+		return nil
+	}
 	entryID, err := s.cron.AddFunc(jobCronSpec(spec), func() {
 		s.runScheduledJob(key)
 	})
@@ -880,7 +1089,9 @@ func (s *JobScheduler) removeJob(project string, environment string, name string
 		delete(s.entries, key)
 	}
 	delete(s.specs, key)
-	running := s.running[key]
+	delete(s.registered, key)
+	delete(s.missed, key)
+	running := len(s.running[key]) > 0
 	s.mu.Unlock()
 	if err := os.Remove(jobSpecPath(s.dataDir, project, environment, name)); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to remove job spec: %w", err)
@@ -888,6 +1099,9 @@ func (s *JobScheduler) removeJob(project string, environment string, name string
 	if err := os.Remove(jobRunsPath(s.dataDir, project, environment, name)); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to remove job run history: %w", err)
 	}
+	if err := os.RemoveAll(jobLogsDir(s.dataDir, project, environment, name)); err != nil {
+		return fmt.Errorf("failed to remove job logs: %w", err)
+	}
 	if !running {
 		if err := removeServiceFiles(project, environment, name); err != nil {
 			return fmt.Errorf("failed to remove job files: %w", err)
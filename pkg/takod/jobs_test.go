@@ -305,7 +305,7 @@ func TestExecuteJobSkipsOverlappingRun(t *testing.T) {
 	spec := validJobSpecFixture()
 	key := jobKey(spec.Project, spec.Environment, spec.Name)
 	scheduler.mu.Lock()
-	scheduler.running[key] = true
+	scheduler.running[key] = []*jobRunSlot{{}}
 	scheduler.mu.Unlock()
 
 	var stream bytes.Buffer
@@ -22,17 +22,21 @@ func (s *Server) registeredRoutes() []takodRoute {
 	return []takodRoute{
 		{"/healthz", s.handleHealthz}, {"/v1/status", s.handleStatus}, {"/v1/actual", s.handleActual},
 		{"/v1/reconcile-service", s.handleReconcileService}, {"/v1/service-files", s.handleServiceFiles}, {"/v1/service-files/check", s.handleServiceFilesCheck},
-		{"/v1/remove-service", s.handleRemoveService}, {"/v1/proxy-file", s.handleProxyFile}, {"/v1/proxy", s.handleProxy},
+		{"/v1/service-secrets/rotate", s.handleServiceSecretsRotate},
+		{"/v1/remove-service", s.handleRemoveService}, {"/v1/proxy-file", s.handleProxyFile}, {"/v1/proxy", s.handleProxy}, {"/v1/proxy/analysis", s.handleProxyAnalysis},
 		{"/v1/certs", s.handleProxyCertificates}, {"/v1/acme-dns", s.handleProxyACMEDNS}, {"/v1/ports/allocate", s.handlePortAllocate},
 		{"/v1/cleanup", s.handleCleanup}, {"/v1/state", s.handleState}, {"/v1/lease", s.handleLease}, {"/v1/fence", s.handleFence}, {"/v1/env-bundle", s.handleEnvBundle},
-		{"/v1/backups", s.handleBackups}, {"/v1/backups/restore", s.handleBackupRestore}, {"/v1/backups/cleanup", s.handleBackupCleanup},
-		{"/v1/backup-schedule", s.handleBackupSchedule}, {"/v1/metadata", s.handleMetadata}, {"/v1/mesh/key", s.handleMeshKey},
+		{"/v1/shared-secrets", s.handleSharedSecrets},
+		{"/v1/backups", s.handleBackups}, {"/v1/backups/restore", s.handleBackupRestore}, {"/v1/backups/cleanup", s.handleBackupCleanup}, {"/v1/backups/verify", s.handleBackupVerify},
+		{"/v1/backups/pitr-restore", s.handleBackupPITRRestore}, {"/v1/backup-schedule", s.handleBackupSchedule}, {"/v1/metadata", s.handleMetadata}, {"/v1/mesh/key", s.handleMeshKey},
 		{"/v1/mesh/apply", s.handleMeshApply}, {"/v1/mesh/status", s.handleMeshStatus}, {"/v1/images/exists", s.handleImageExists},
 		{"/v1/images/inspect", s.handleImageInspect}, {"/v1/images/export", s.handleImageExport}, {"/v1/images/import", s.handleImageImport},
 		{"/v1/images/build", s.handleImageBuild}, {"/v1/platform", s.handlePlatform}, {"/v1/platform/inventory", s.handleInventoryAuthority}, {"/v1/platform/allocations/authorize", s.handleAllocationAuthorization}, {"/v1/platform/membership/reconcile", s.handleMembershipReconcile},
 		{"/v1/logs", s.handleLogs}, {"/v1/exec", s.handleExec}, {"/v1/jobs", s.handleJobs}, {"/v1/jobs/apply", s.handleJobsApply},
-		{"/v1/jobs/runs", s.handleJobRuns}, {"/v1/jobs/trigger", s.handleJobsTrigger}, {"/v1/stats", s.handleStats},
-		{"/v1/metrics", s.handleMetrics}, {"/v1/access-logs", s.handleAccessLogs}, {"/v1/discovery/exports", s.handleDiscoveryExports},
+		{"/v1/jobs/runs", s.handleJobRuns}, {"/v1/jobs/trigger", s.handleJobsTrigger}, {"/v1/jobs/logs", s.handleJobLogs},
+		{"/v1/logging", s.handleLogging}, {"/v1/logging/apply", s.handleLoggingApply}, {"/v1/stats", s.handleStats},
+		{"/v1/metrics", s.handleMetrics}, {"/v1/metrics/exporter", s.handleMetricsExporterApply}, {"/v1/metrics/history", s.handleMetricsHistory},
+		{"/v1/alerts", s.handleAlerts}, {"/v1/alerts/apply", s.handleAlertsApply}, {"/v1/alerts/silence", s.handleAlertSilence}, {"/v1/tracing/apply", s.handleTracingApply}, {"/v1/events", s.handleContainerEvents}, {"/v1/events/apply", s.handleContainerEventsApply}, {"/v1/access-logs", s.handleAccessLogs}, {"/v1/discovery/exports", s.handleDiscoveryExports},
 	}
 }
 
@@ -0,0 +1,834 @@
+package takod
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/logship"
+)
+
+const (
+	logShippingDirName        = "logging"
+	logShippingSpecFile       = "spec.json"
+	logShippingCheckpointFile = "checkpoints.json"
+	// logShippingDiscoverInterval is how often a pipeline looks for new
+	// containers to tail and saves its checkpoints.
+	logShippingDiscoverInterval = 10 * time.Second
+	maxLogShippingSinks         = 8
+	maxLogShippingBufferSize    = 1000000
+	// logShippingAccessCheckpoint keys the proxy access log's checkpoint
+	// beside the per-container ones.
+	logShippingAccessCheckpoint = "proxy-access-log"
+	logShippingStopTimeout      = 30 * time.Second
+)
+
+// LoggingSpec ships one project environment's logs from this node. Node is
+// the name the deployer knows this server by, set as the node label.
+type LoggingSpec struct {
+	Sinks      []logship.SinkConfig `json:"sinks"`
+	AccessLogs bool                 `json:"accessLogs,omitempty"`
+	BufferSize int                  `json:"bufferSize,omitempty"`
+	Node       string               `json:"node,omitempty"`
+}
+
+// LoggingApplyRequest replaces an environment's shipping spec; a nil Spec
+// stops shipping and forgets its checkpoints.
+type LoggingApplyRequest struct {
+	Project     string       `json:"project"`
+	Environment string       `json:"environment"`
+	Spec        *LoggingSpec `json:"spec,omitempty"`
+}
+
+type LoggingApplyResponse struct {
+	Project     string   `json:"project"`
+	Environment string   `json:"environment"`
+	Shipping    bool     `json:"shipping"`
+	Sinks       []string `json:"sinks,omitempty"`
+}
+
+// LoggingStatus reports one environment's shipping pipeline on this node.
+type LoggingStatus struct {
+	Project     string                   `json:"project"`
+	Environment string                   `json:"environment"`
+	Containers  []string                 `json:"containers"`
+	AccessLogs  bool                     `json:"accessLogs"`
+	Sinks       []logship.ForwarderStats `json:"sinks"`
+}
+
+func validateLoggingSpec(spec *LoggingSpec) error {
+	if len(spec.Sinks) == 0 {
+		return fmt.Errorf("logging needs at least one sink")
+	}
+	if len(spec.Sinks) > maxLogShippingSinks {
+		return fmt.Errorf("at most %d log sinks are allowed", maxLogShippingSinks)
+	}
+	names := map[string]bool{}
+	for _, sink := range spec.Sinks {
+		if err := logship.ValidateSinkConfig(sink); err != nil {
+			return fmt.Errorf("sink %s: %w", sink.DisplayName(), err)
+		}
+		if names[sink.DisplayName()] {
+			return fmt.Errorf("duplicate log sink %s; name each sink of the same type", sink.DisplayName())
+		}
+		names[sink.DisplayName()] = true
+	}
+	if spec.BufferSize < 0 || spec.BufferSize > maxLogShippingBufferSize {
+		return fmt.Errorf("bufferSize must be between 0 and %d", maxLogShippingBufferSize)
+	}
+	if len(spec.Node) > 255 || strings.IndexFunc(spec.Node, func(r rune) bool { return r <= ' ' || r == 0x7f }) >= 0 {
+		return fmt.Errorf("invalid node name")
+	}
+	return nil
+}
+
+// shippedContainer is one container of a shipping environment.
+type shippedContainer struct {
+	name     string
+	service  string
+	revision string
+	replica  int
+	running  bool
+}
+
+// logShippingCheckpoints records, per container and for the access log, the
+// time of the last entry handed to the forwarders, so a restarted takod
+// resumes instead of re-shipping or skipping. StartedAt bounds sources
+// without a checkpoint: shipping never backfills history from before the
+// spec was first applied.
+type logShippingCheckpoints struct {
+	StartedAt time.Time            `json:"startedAt"`
+	Sources   map[string]time.Time `json:"sources"`
+}
+
+// LogShipper runs one shipping pipeline per environment with a logging
+// spec, mirroring JobScheduler: specs persist as JSON under the data dir
+// and are reloaded on start.
+type LogShipper struct {
+	dataDir string
+	// Seams for container discovery, log tailing, and sinks; tests stub them.
+	listContainers  func(ctx context.Context, project string, environment string) ([]shippedContainer, error)
+	followContainer func(ctx context.Context, container shippedContainer, since time.Time, visit func(LogEntry) error) error
+	followAccessLog func(ctx context.Context, visit func(line string) error) error
+	newSink         func(config logship.SinkConfig) (logship.Sink, error)
+	interval        time.Duration
+
+	// applyMu serializes pipeline replacement and removal.
+	applyMu   sync.Mutex
+	mu        sync.Mutex
+	ctx       context.Context
+	pipelines map[string]*logPipeline
+}
+
+func NewLogShipper(dataDir string) *LogShipper {
+	return &LogShipper{
+		dataDir:         dataDir,
+		listContainers:  listShippedContainers,
+		followContainer: followShippedContainer,
+		followAccessLog: followProxyAccessLog,
+		newSink:         logship.NewSink,
+		interval:        logShippingDiscoverInterval,
+		pipelines:       map[string]*logPipeline{},
+	}
+}
+
+// Run starts a pipeline for every persisted spec and blocks until ctx ends,
+// then waits for the pipelines to flush.
+func (s *LogShipper) Run(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	// Holding applyMu keeps an early Apply from starting a pipeline the
+	// load would start again.
+	s.applyMu.Lock()
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+	if err := s.loadSpecs(); err != nil {
+		fmt.Fprintf(os.Stderr, "takod log shipper failed to load specs: %v\n", err)
+	}
+	s.applyMu.Unlock()
+	<-ctx.Done()
+	s.mu.Lock()
+	pipelines := make([]*logPipeline, 0, len(s.pipelines))
+	for _, pipeline := range s.pipelines {
+		pipelines = append(pipelines, pipeline)
+	}
+	s.mu.Unlock()
+	deadline := time.After(logShippingStopTimeout)
+	for _, pipeline := range pipelines {
+		select {
+		case <-pipeline.done:
+		case <-deadline:
+			return
+		}
+	}
+}
+
+// Apply replaces one environment's spec and restarts its pipeline; an
+// unchanged spec leaves the running pipeline alone.
+func (s *LogShipper) Apply(request LoggingApplyRequest) (*LoggingApplyResponse, error) {
+	if s == nil {
+		return nil, fmt.Errorf("log shipper is not initialized")
+	}
+	if !isSafeProjectName(request.Project) {
+		return nil, fmt.Errorf("invalid project name")
+	}
+	if !isSafeRuntimeName(request.Environment) {
+		return nil, fmt.Errorf("invalid environment name")
+	}
+	s.applyMu.Lock()
+	defer s.applyMu.Unlock()
+	response := &LoggingApplyResponse{Project: request.Project, Environment: request.Environment}
+	if request.Spec == nil {
+		if err := s.remove(request.Project, request.Environment); err != nil {
+			return nil, err
+		}
+		return response, nil
+	}
+	spec := *request.Spec
+	if err := validateLoggingSpec(&spec); err != nil {
+		return nil, err
+	}
+	for _, sink := range spec.Sinks {
+		response.Sinks = append(response.Sinks, sink.DisplayName())
+	}
+	response.Shipping = true
+
+	key := logShippingKey(request.Project, request.Environment)
+	s.mu.Lock()
+	existing := s.pipelines[key]
+	s.mu.Unlock()
+	if existing != nil && reflect.DeepEqual(existing.spec, spec) {
+		return response, nil
+	}
+	if err := s.persistSpec(request.Project, request.Environment, spec); err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		existing.stop(false)
+		s.mu.Lock()
+		delete(s.pipelines, key)
+		s.mu.Unlock()
+	}
+	if err := s.start(request.Project, request.Environment, spec); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// RemoveProject stops shipping for a project (one environment, or all when
+// environment is empty) and deletes its specs and checkpoints.
+func (s *LogShipper) RemoveProject(project string, environment string) error {
+	if s == nil {
+		return nil
+	}
+	if !isSafeProjectName(project) {
+		return fmt.Errorf("invalid project name")
+	}
+	s.applyMu.Lock()
+	defer s.applyMu.Unlock()
+	if environment != "" {
+		return s.remove(project, environment)
+	}
+	s.mu.Lock()
+	var environments []string
+	for _, pipeline := range s.pipelines {
+		if pipeline.project == project {
+			environments = append(environments, pipeline.environment)
+		}
+	}
+	s.mu.Unlock()
+	for _, env := range environments {
+		if err := s.remove(project, env); err != nil {
+			return err
+		}
+	}
+	if err := os.RemoveAll(filepath.Join(s.dataDir, logShippingDirName, project)); err != nil {
+		return fmt.Errorf("failed to remove log shipping state: %w", err)
+	}
+	return nil
+}
+
+// Status reports running pipelines, optionally filtered by
+// project/environment.
+func (s *LogShipper) Status(project string, environment string) []LoggingStatus {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	var pipelines []*logPipeline
+	for _, pipeline := range s.pipelines {
+		if project != "" && pipeline.project != project {
+			continue
+		}
+		if environment != "" && pipeline.environment != environment {
+			continue
+		}
+		pipelines = append(pipelines, pipeline)
+	}
+	s.mu.Unlock()
+	statuses := make([]LoggingStatus, 0, len(pipelines))
+	for _, pipeline := range pipelines {
+		statuses = append(statuses, pipeline.status())
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Project != statuses[j].Project {
+			return statuses[i].Project < statuses[j].Project
+		}
+		return statuses[i].Environment < statuses[j].Environment
+	})
+	return statuses
+}
+
+func (s *LogShipper) remove(project string, environment string) error {
+	key := logShippingKey(project, environment)
+	s.mu.Lock()
+	pipeline := s.pipelines[key]
+	delete(s.pipelines, key)
+	s.mu.Unlock()
+	if pipeline != nil {
+		pipeline.stop(true)
+	}
+	if err := os.RemoveAll(s.envDir(project, environment)); err != nil {
+		return fmt.Errorf("failed to remove log shipping state: %w", err)
+	}
+	_ = os.Remove(filepath.Join(s.dataDir, logShippingDirName, project))
+	return nil
+}
+
+// start launches a pipeline for spec. Before Run has provided a context
+// the spec is only persisted; Run starts it.
+func (s *LogShipper) start(project string, environment string, spec LoggingSpec) error {
+	checkpoints, err := s.readCheckpoints(project, environment)
+	if err != nil {
+		return err
+	}
+	pipeline := &logPipeline{
+		shipper:     s,
+		project:     project,
+		environment: environment,
+		spec:        spec,
+		checkpoints: checkpoints,
+		tailing:     map[string]bool{},
+		done:        make(chan struct{}),
+	}
+	for _, sinkConfig := range spec.Sinks {
+		sink, err := s.newSink(sinkConfig)
+		if err != nil {
+			for _, forwarder := range pipeline.forwarders {
+				_ = forwarder.Close()
+			}
+			return fmt.Errorf("sink %s: %w", sinkConfig.DisplayName(), err)
+		}
+		pipeline.forwarders = append(pipeline.forwarders, &shippingForwarder{sink: sink, Forwarder: logship.NewForwarder(sinkConfig, sink, spec.BufferSize)})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx == nil {
+		for _, forwarder := range pipeline.forwarders {
+			_ = forwarder.Close()
+		}
+		return nil
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	pipeline.cancel = cancel
+	s.pipelines[logShippingKey(project, environment)] = pipeline
+	go pipeline.run(ctx)
+	return nil
+}
+
+func (s *LogShipper) loadSpecs() error {
+	root := filepath.Join(s.dataDir, logShippingDirName)
+	projects, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, project := range projects {
+		if !project.IsDir() {
+			continue
+		}
+		environments, err := os.ReadDir(filepath.Join(root, project.Name()))
+		if err != nil {
+			return err
+		}
+		for _, environment := range environments {
+			if !environment.IsDir() {
+				continue
+			}
+			path := filepath.Join(root, project.Name(), environment.Name(), logShippingSpecFile)
+			data, err := os.ReadFile(path)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			var spec LoggingSpec
+			if err := json.Unmarshal(data, &spec); err != nil {
+				return fmt.Errorf("failed to parse logging spec %s: %w", path, err)
+			}
+			if err := validateLoggingSpec(&spec); err != nil {
+				return fmt.Errorf("invalid logging spec %s: %w", path, err)
+			}
+			if err := s.start(project.Name(), environment.Name(), spec); err != nil {
+				return fmt.Errorf("logging spec %s: %w", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *LogShipper) envDir(project string, environment string) string {
+	return filepath.Join(s.dataDir, logShippingDirName, project, environment)
+}
+
+func (s *LogShipper) persistSpec(project string, environment string, spec LoggingSpec) error {
+	dir := s.envDir(project, environment)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create logging spec directory: %w", err)
+	}
+	data, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode logging spec: %w", err)
+	}
+	data = append(data, '\n')
+	// Sink headers can carry credentials.
+	if err := writeFileAtomic(filepath.Join(dir, logShippingSpecFile), data, 0600); err != nil {
+		return fmt.Errorf("failed to write logging spec: %w", err)
+	}
+	return nil
+}
+
+func (s *LogShipper) readCheckpoints(project string, environment string) (logShippingCheckpoints, error) {
+	checkpoints := logShippingCheckpoints{StartedAt: time.Now().UTC(), Sources: map[string]time.Time{}}
+	data, err := os.ReadFile(filepath.Join(s.envDir(project, environment), logShippingCheckpointFile))
+	if os.IsNotExist(err) {
+		return checkpoints, nil
+	}
+	if err != nil {
+		return checkpoints, fmt.Errorf("failed to read log shipping checkpoints: %w", err)
+	}
+	var stored logShippingCheckpoints
+	if err := json.Unmarshal(data, &stored); err != nil || stored.StartedAt.IsZero() {
+		// A corrupt checkpoint file restarts shipping from now rather
+		// than wedging the pipeline.
+		return checkpoints, nil
+	}
+	if stored.Sources == nil {
+		stored.Sources = map[string]time.Time{}
+	}
+	return stored, nil
+}
+
+// shippingForwarder pairs a forwarder with its sink so a pipeline that
+// never ran can still release the sink.
+type shippingForwarder struct {
+	*logship.Forwarder
+	sink logship.Sink
+}
+
+func (f *shippingForwarder) Close() error {
+	return f.sink.Close()
+}
+
+// logPipeline tails one environment's containers and, on proxy nodes, its
+// access log lines into a forwarder per sink.
+type logPipeline struct {
+	shipper     *LogShipper
+	project     string
+	environment string
+	spec        LoggingSpec
+	forwarders  []*shippingForwarder
+	cancel      context.CancelFunc
+	done        chan struct{}
+	tailers     sync.WaitGroup
+
+	mu            sync.Mutex
+	checkpoints   logShippingCheckpoints
+	tailing       map[string]bool
+	accessTailing bool
+	routes        accessLogRoutes
+	// discarded is set when the pipeline's state is being deleted, so its
+	// final checkpoint save does not recreate it.
+	discarded bool
+}
+
+func (p *logPipeline) run(ctx context.Context) {
+	defer close(p.done)
+	// Forwarders outlive the tailers so entries read before shutdown still
+	// get their final flush.
+	forwardCtx, stopForwarders := context.WithCancel(context.Background())
+	var forwarders sync.WaitGroup
+	for _, forwarder := range p.forwarders {
+		forwarders.Add(1)
+		go func() {
+			defer forwarders.Done()
+			forwarder.Run(forwardCtx)
+		}()
+	}
+
+	ticker := time.NewTicker(p.shipper.interval)
+	p.discover(ctx)
+	for running := true; running; {
+		select {
+		case <-ctx.Done():
+			running = false
+		case <-ticker.C:
+			p.discover(ctx)
+			p.saveCheckpoints()
+		}
+	}
+	ticker.Stop()
+	p.tailers.Wait()
+	stopForwarders()
+	forwarders.Wait()
+	p.saveCheckpoints()
+}
+
+// stop cancels the pipeline and waits for its final flush; discard skips
+// the final checkpoint save because the state is being deleted.
+func (p *logPipeline) stop(discard bool) {
+	p.mu.Lock()
+	p.discarded = discard
+	p.mu.Unlock()
+	p.cancel()
+	select {
+	case <-p.done:
+	case <-time.After(logShippingStopTimeout):
+	}
+}
+
+// discover starts a tailer for every running container not already
+// followed, forgets checkpoints of containers that no longer exist, and
+// refreshes access log attribution from the published routes.
+func (p *logPipeline) discover(ctx context.Context) {
+	if ctx.Err() != nil {
+		return
+	}
+	containers, err := p.shipper.listContainers(ctx, p.project, p.environment)
+	if err != nil {
+		if ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "takod log shipping for %s/%s failed to list containers: %v\n", p.project, p.environment, err)
+		}
+	} else {
+		existing := map[string]bool{logShippingAccessCheckpoint: true}
+		p.mu.Lock()
+		for _, container := range containers {
+			existing[container.name] = true
+			if !container.running || p.tailing[container.name] {
+				continue
+			}
+			p.tailing[container.name] = true
+			p.tailers.Add(1)
+			go p.tailContainer(ctx, container)
+		}
+		for name := range p.checkpoints.Sources {
+			if !existing[name] && !p.tailing[name] {
+				delete(p.checkpoints.Sources, name)
+			}
+		}
+		p.mu.Unlock()
+	}
+
+	if !p.spec.AccessLogs {
+		return
+	}
+	routes, err := loadAccessLogRoutes(p.project, p.environment)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "takod log shipping for %s/%s failed to read proxy routes: %v\n", p.project, p.environment, err)
+		return
+	}
+	p.mu.Lock()
+	p.routes = routes
+	start := !p.accessTailing && !routes.empty()
+	if start {
+		p.accessTailing = true
+		p.tailers.Add(1)
+	}
+	p.mu.Unlock()
+	if start {
+		go p.tailAccessLog(ctx)
+	}
+}
+
+func (p *logPipeline) tailContainer(ctx context.Context, container shippedContainer) {
+	defer p.tailers.Done()
+	defer func() {
+		p.mu.Lock()
+		delete(p.tailing, container.name)
+		p.mu.Unlock()
+	}()
+	since := p.checkpoint(container.name)
+	labels := p.labels(container.service, container.revision, container.replica, logship.SourceContainer)
+	err := p.shipper.followContainer(ctx, container, since, func(entry LogEntry) error {
+		// docker logs --since is inclusive and second-granular on some
+		// engines; the checkpoint keeps already shipped lines out.
+		if !entry.Time.After(since) {
+			return nil
+		}
+		if err := p.enqueue(ctx, logship.Entry{Time: entry.Time, Line: entry.Line, Labels: labels}); err != nil {
+			return err
+		}
+		since = entry.Time
+		p.setCheckpoint(container.name, entry.Time)
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		fmt.Fprintf(os.Stderr, "takod log shipping stopped following %s: %v\n", container.name, err)
+	}
+}
+
+func (p *logPipeline) tailAccessLog(ctx context.Context) {
+	defer p.tailers.Done()
+	defer func() {
+		p.mu.Lock()
+		p.accessTailing = false
+		p.mu.Unlock()
+	}()
+	since := p.checkpoint(logShippingAccessCheckpoint)
+	err := p.shipper.followAccessLog(ctx, func(line string) error {
+		var entry proxyAccessLogEntry
+		if json.Unmarshal([]byte(line), &entry) != nil || entry.TS <= 0 {
+			return nil
+		}
+		seconds, fraction := math.Modf(entry.TS)
+		at := time.Unix(int64(seconds), int64(fraction*float64(time.Second))).UTC()
+		if !at.After(since) {
+			return nil
+		}
+		p.mu.Lock()
+		target, ok := p.routes.attribute(entry)
+		p.mu.Unlock()
+		if !ok {
+			return nil
+		}
+		if err := p.enqueue(ctx, logship.Entry{Time: at, Line: line, Labels: p.labels(target.service, target.revision, 0, logship.SourceAccess)}); err != nil {
+			return err
+		}
+		since = at
+		p.setCheckpoint(logShippingAccessCheckpoint, at)
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		fmt.Fprintf(os.Stderr, "takod log shipping stopped following the proxy access log: %v\n", err)
+	}
+}
+
+// enqueue hands entry to every sink; a full buffer blocks here, which
+// stops reading the source until the sink catches up.
+func (p *logPipeline) enqueue(ctx context.Context, entry logship.Entry) error {
+	for _, forwarder := range p.forwarders {
+		if err := forwarder.Enqueue(ctx, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *logPipeline) labels(service string, revision string, replica int, source string) map[string]string {
+	labels := map[string]string{
+		logship.LabelProject:     p.project,
+		logship.LabelEnvironment: p.environment,
+		logship.LabelService:     service,
+		logship.LabelRevision:    revision,
+		logship.LabelNode:        p.spec.Node,
+		logship.LabelSource:      source,
+	}
+	if replica > 0 {
+		labels[logship.LabelReplica] = strconv.Itoa(replica)
+	}
+	return labels
+}
+
+func (p *logPipeline) checkpoint(source string) time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if at, ok := p.checkpoints.Sources[source]; ok {
+		return at
+	}
+	return p.checkpoints.StartedAt
+}
+
+func (p *logPipeline) setCheckpoint(source string, at time.Time) {
+	p.mu.Lock()
+	p.checkpoints.Sources[source] = at
+	p.mu.Unlock()
+}
+
+func (p *logPipeline) saveCheckpoints() {
+	p.mu.Lock()
+	if p.discarded {
+		p.mu.Unlock()
+		return
+	}
+	data, err := json.MarshalIndent(p.checkpoints, "", "  ")
+	p.mu.Unlock()
+	if err != nil {
+		return
+	}
+	dir := p.shipper.envDir(p.project, p.environment)
+	if err := os.MkdirAll(dir, 0700); err == nil {
+		err = writeFileAtomic(filepath.Join(dir, logShippingCheckpointFile), append(data, '\n'), 0600)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "takod log shipping for %s/%s failed to save checkpoints: %v\n", p.project, p.environment, err)
+	}
+}
+
+func (p *logPipeline) status() LoggingStatus {
+	p.mu.Lock()
+	containers := make([]string, 0, len(p.tailing))
+	for name := range p.tailing {
+		containers = append(containers, name)
+	}
+	accessLogs := p.accessTailing
+	p.mu.Unlock()
+	sort.Strings(containers)
+	status := LoggingStatus{
+		Project:     p.project,
+		Environment: p.environment,
+		Containers:  containers,
+		AccessLogs:  accessLogs,
+	}
+	for _, forwarder := range p.forwarders {
+		status.Sinks = append(status.Sinks, forwarder.Stats())
+	}
+	return status
+}
+
+type accessLogTarget struct {
+	service  string
+	revision string
+}
+
+// accessLogRoutes attributes proxy access log lines to one environment's
+// services: by the upstream that served the request when the route records
+// revisions, otherwise by the service's access logger when no other
+// environment on this proxy shares that logger name.
+type accessLogRoutes struct {
+	upstreams map[string]accessLogTarget
+	loggers   map[string]string
+}
+
+func loadAccessLogRoutes(project string, environment string) (accessLogRoutes, error) {
+	routes := accessLogRoutes{upstreams: map[string]accessLogTarget{}, loggers: map[string]string{}}
+	manifests, err := readProxyRouteManifests(proxyRoutesDir)
+	if err != nil {
+		return routes, err
+	}
+	owners := map[string]map[string]bool{}
+	for _, manifest := range manifests {
+		owner := manifest.Project + "/" + manifest.Environment
+		for _, route := range manifest.Routes {
+			logger := "http.log.access." + caddyAccessLogName(route.Service)
+			if owners[logger] == nil {
+				owners[logger] = map[string]bool{}
+			}
+			owners[logger][owner] = true
+			if manifest.Project != project || manifest.Environment != environment {
+				continue
+			}
+			routes.loggers[logger] = route.Service
+			for upstream, revision := range proxyRouteUpstreamRevisions(route) {
+				routes.upstreams[upstream] = accessLogTarget{service: route.Service, revision: revision}
+			}
+		}
+	}
+	for logger := range routes.loggers {
+		if len(owners[logger]) > 1 {
+			delete(routes.loggers, logger)
+		}
+	}
+	return routes, nil
+}
+
+func (r accessLogRoutes) empty() bool {
+	return len(r.upstreams) == 0 && len(r.loggers) == 0
+}
+
+func (r accessLogRoutes) attribute(entry proxyAccessLogEntry) (accessLogTarget, bool) {
+	if entry.Upstream != "" {
+		if target, ok := r.upstreams[entry.Upstream]; ok {
+			return target, true
+		}
+	}
+	if service, ok := r.loggers[entry.Logger]; ok {
+		return accessLogTarget{service: service}, true
+	}
+	return accessLogTarget{}, false
+}
+
+func listShippedContainers(ctx context.Context, project string, environment string) ([]shippedContainer, error) {
+	cmd := dockerCommandContext(ctx, "docker", "ps", "-a",
+		"--filter", "label=tako.project="+project,
+		"--filter", "label=tako.environment="+environment,
+		"--format", `{{.Names}}|{{.Label "tako.service"}}|{{.Label "tako.revision"}}|{{.Label "tako.slot"}}|{{.State}}`)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	var containers []shippedContainer
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "|")
+		if len(fields) != 5 || fields[0] == "" {
+			continue
+		}
+		replica, _ := strconv.Atoi(fields[3])
+		containers = append(containers, shippedContainer{
+			name:     fields[0],
+			service:  fields[1],
+			revision: fields[2],
+			replica:  replica,
+			running:  fields[4] == "running",
+		})
+	}
+	return containers, nil
+}
+
+func followShippedContainer(ctx context.Context, container shippedContainer, since time.Time, visit func(LogEntry) error) error {
+	return scanContainerLogEntries(ctx, logContainer{name: container.name, replica: container.replica},
+		[]string{"--since", since.UTC().Format(time.RFC3339Nano), "-f"}, visit)
+}
+
+// followProxyAccessLog reads the whole access log and then follows it
+// across rotation; callers skip lines at or before their checkpoint.
+func followProxyAccessLog(ctx context.Context, visit func(line string) error) error {
+	cmd := tailCommandContext(ctx, "tail", "-n", "+1", "-F", proxyAccessLogPath)
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to follow proxy access log: %w", err)
+	}
+	go func() {
+		_ = writer.CloseWithError(cmd.Wait())
+	}()
+	defer reader.Close()
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxProxyAnalysisLineBytes)
+	for scanner.Scan() {
+		if err := visit(scanner.Text()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func logShippingKey(project string, environment string) string {
+	return project + "/" + environment
+}
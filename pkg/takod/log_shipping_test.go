@@ -0,0 +1,269 @@
+package takod
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/logship"
+)
+
+type captureSink struct {
+	entries chan logship.Entry
+}
+
+func (s *captureSink) Send(ctx context.Context, entries []logship.Entry) error {
+	for _, entry := range entries {
+		s.entries <- entry
+	}
+	return nil
+}
+
+func (s *captureSink) Close() error { return nil }
+
+func newTestLogShipper(t *testing.T, dataDir string, sink *captureSink) *LogShipper {
+	t.Helper()
+	shipper := NewLogShipper(dataDir)
+	shipper.interval = 10 * time.Millisecond
+	shipper.newSink = func(logship.SinkConfig) (logship.Sink, error) { return sink, nil }
+	shipper.listContainers = func(context.Context, string, string) ([]shippedContainer, error) { return nil, nil }
+	shipper.followAccessLog = func(ctx context.Context, visit func(string) error) error {
+		<-ctx.Done()
+		return nil
+	}
+	return shipper
+}
+
+func runTestLogShipper(t *testing.T, shipper *LogShipper) func() {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		shipper.Run(ctx)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		shipper.mu.Lock()
+		started := shipper.ctx != nil
+		shipper.mu.Unlock()
+		if started || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func receiveShippedEntries(t *testing.T, sink *captureSink, count int) []logship.Entry {
+	t.Helper()
+	var entries []logship.Entry
+	for len(entries) < count {
+		select {
+		case entry := <-sink.entries:
+			entries = append(entries, entry)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d of %d shipped entries: %+v", len(entries), count, entries)
+		}
+	}
+	return entries
+}
+
+func testLoggingSpec() *LoggingSpec {
+	return &LoggingSpec{Sinks: []logship.SinkConfig{{Type: logship.SinkHTTP, URL: "https://logs.example.com/ingest"}}, Node: "node-a"}
+}
+
+func TestLogShipperShipsContainerLogsAndResumesFromCheckpoint(t *testing.T) {
+	dataDir := t.TempDir()
+	base := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	var mu sync.Mutex
+	var sinces []time.Time
+	var followed []string
+	follow := func(lines ...LogEntry) func(context.Context, shippedContainer, time.Time, func(LogEntry) error) error {
+		return func(ctx context.Context, container shippedContainer, since time.Time, visit func(LogEntry) error) error {
+			mu.Lock()
+			sinces = append(sinces, since)
+			followed = append(followed, container.name)
+			mu.Unlock()
+			for _, line := range lines {
+				if err := visit(line); err != nil {
+					return err
+				}
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		}
+	}
+	containers := func(context.Context, string, string) ([]shippedContainer, error) {
+		return []shippedContainer{
+			{name: "demo_production_web_1", service: "web", revision: "rev-a", replica: 1, running: true},
+			{name: "demo_production_web_old", service: "web", revision: "rev-0", replica: 1},
+		}, nil
+	}
+
+	sink := &captureSink{entries: make(chan logship.Entry, 16)}
+	shipper := newTestLogShipper(t, dataDir, sink)
+	shipper.listContainers = containers
+	shipper.followContainer = follow(LogEntry{Time: base.Add(time.Second), Line: "one"}, LogEntry{Time: base.Add(2 * time.Second), Line: "two"})
+	stop := runTestLogShipper(t, shipper)
+	if _, err := shipper.Apply(LoggingApplyRequest{Project: "demo", Environment: "production", Spec: testLoggingSpec()}); err != nil {
+		stop()
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	entries := receiveShippedEntries(t, sink, 2)
+	status := shipper.Status("demo", "production")
+	mu.Lock()
+	followedBeforeStop := strings.Join(followed, ",")
+	mu.Unlock()
+	stop()
+
+	if entries[0].Line != "one" || entries[1].Line != "two" {
+		t.Fatalf("entries = %+v", entries)
+	}
+	want := map[string]string{"project": "demo", "environment": "production", "service": "web", "revision": "rev-a", "replica": "1", "node": "node-a", "source": "container"}
+	for key, value := range want {
+		if entries[0].Labels[key] != value {
+			t.Fatalf("labels = %v, want %s=%s", entries[0].Labels, key, value)
+		}
+	}
+	if len(status) != 1 || len(status[0].Sinks) != 1 || strings.Join(status[0].Containers, ",") != "demo_production_web_1" {
+		t.Fatalf("status = %+v", status)
+	}
+	if followedBeforeStop != "demo_production_web_1" {
+		t.Fatalf("followed %v, want only the running container", followedBeforeStop)
+	}
+	data, err := os.ReadFile(filepath.Join(dataDir, logShippingDirName, "demo", "production", logShippingCheckpointFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var checkpoints logShippingCheckpoints
+	if err := json.Unmarshal(data, &checkpoints); err != nil {
+		t.Fatal(err)
+	}
+	if !checkpoints.Sources["demo_production_web_1"].Equal(base.Add(2 * time.Second)) {
+		t.Fatalf("checkpoints = %+v", checkpoints)
+	}
+
+	// A restarted takod reloads the spec and resumes after the checkpoint.
+	resumed := &captureSink{entries: make(chan logship.Entry, 16)}
+	shipper = newTestLogShipper(t, dataDir, resumed)
+	shipper.listContainers = containers
+	sinces = nil
+	shipper.followContainer = follow(LogEntry{Time: base.Add(2 * time.Second), Line: "two"}, LogEntry{Time: base.Add(3 * time.Second), Line: "three"})
+	stop = runTestLogShipper(t, shipper)
+	entries = receiveShippedEntries(t, resumed, 1)
+	stop()
+	if entries[0].Line != "three" {
+		t.Fatalf("resumed entries = %+v, want only the unshipped line", entries)
+	}
+	if len(sinces) == 0 || !sinces[0].Equal(base.Add(2*time.Second)) {
+		t.Fatalf("resumed since = %v", sinces)
+	}
+	select {
+	case extra := <-resumed.entries:
+		t.Fatalf("re-shipped %+v", extra)
+	default:
+	}
+}
+
+func TestLogShipperAttributesAccessLogLinesToRoutes(t *testing.T) {
+	useTempProxyPaths(t)
+	if err := os.MkdirAll(proxyRoutesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	manifests := map[string]string{
+		"demo-production.json":  `{"version": 1, "project": "demo", "environment": "production", "routes": [{"service": "web", "revision": "rev-a", "domains": ["demo.example.com"], "upstreams": ["http://demo-web-a:3000"]}, {"service": "api", "domains": ["api.example.com"], "upstreams": ["http://demo-api:3000"]}]}`,
+		"other-production.json": `{"version": 1, "project": "other", "environment": "production", "routes": [{"service": "api", "domains": ["other.example.com"], "upstreams": ["http://other-api:3000"]}]}`,
+	}
+	for name, manifest := range manifests {
+		if err := os.WriteFile(filepath.Join(proxyRoutesDir, name), []byte(manifest), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ts := float64(time.Now().Add(time.Hour).Unix())
+	line := func(logger string, offset float64, upstream string) string {
+		data, _ := json.Marshal(map[string]any{"logger": logger, "ts": ts + offset, "status": 200, "tako_upstream": upstream})
+		return string(data)
+	}
+	lines := []string{
+		line("http.log.access.tako_web", -7200, "demo-web-a:3000"),
+		line("http.log.access.tako_web", 1, "demo-web-a:3000"),
+		line("http.log.access.tako_api", 2, ""),
+		"not json",
+		line("http.log.access.tako_web", 3, ""),
+	}
+
+	sink := &captureSink{entries: make(chan logship.Entry, 16)}
+	shipper := newTestLogShipper(t, t.TempDir(), sink)
+	shipper.followAccessLog = func(ctx context.Context, visit func(string) error) error {
+		for _, line := range lines {
+			if err := visit(line); err != nil {
+				return err
+			}
+		}
+		<-ctx.Done()
+		return nil
+	}
+	stop := runTestLogShipper(t, shipper)
+	spec := testLoggingSpec()
+	spec.AccessLogs = true
+	if _, err := shipper.Apply(LoggingApplyRequest{Project: "demo", Environment: "production", Spec: spec}); err != nil {
+		stop()
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	entries := receiveShippedEntries(t, sink, 2)
+	stop()
+
+	// The stale line predates shipping, the shared tako_api logger cannot be
+	// attributed, and the unmatched upstream falls back to the web logger.
+	if entries[0].Labels["service"] != "web" || entries[0].Labels["revision"] != "rev-a" || entries[0].Labels["source"] != "access" {
+		t.Fatalf("first access entry labels = %v", entries[0].Labels)
+	}
+	if entries[1].Labels["service"] != "web" || entries[1].Labels["revision"] != "" {
+		t.Fatalf("second access entry labels = %v", entries[1].Labels)
+	}
+	select {
+	case extra := <-sink.entries:
+		t.Fatalf("shipped unattributable line %+v", extra)
+	default:
+	}
+}
+
+func TestLogShipperApplyValidatesAndRemovesSpecs(t *testing.T) {
+	dataDir := t.TempDir()
+	shipper := newTestLogShipper(t, dataDir, &captureSink{entries: make(chan logship.Entry, 1)})
+	duplicate := testLoggingSpec()
+	duplicate.Sinks = append(duplicate.Sinks, duplicate.Sinks[0])
+	if _, err := shipper.Apply(LoggingApplyRequest{Project: "demo", Environment: "production", Spec: duplicate}); err == nil || !strings.Contains(err.Error(), "duplicate log sink") {
+		t.Fatalf("expected duplicate sink error, got %v", err)
+	}
+	if _, err := shipper.Apply(LoggingApplyRequest{Project: "../demo", Environment: "production", Spec: testLoggingSpec()}); err == nil {
+		t.Fatal("unsafe project accepted")
+	}
+
+	response, err := shipper.Apply(LoggingApplyRequest{Project: "demo", Environment: "production", Spec: testLoggingSpec()})
+	if err != nil || !response.Shipping || strings.Join(response.Sinks, ",") != "http" {
+		t.Fatalf("Apply = %+v, %v", response, err)
+	}
+	specPath := filepath.Join(dataDir, logShippingDirName, "demo", "production", logShippingSpecFile)
+	info, err := os.Stat(specPath)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("spec file = %v, %v", info, err)
+	}
+
+	response, err = shipper.Apply(LoggingApplyRequest{Project: "demo", Environment: "production"})
+	if err != nil || response.Shipping {
+		t.Fatalf("clearing Apply = %+v, %v", response, err)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, logShippingDirName, "demo")); !os.IsNotExist(err) {
+		t.Fatalf("logging state survived removal: %v", err)
+	}
+}
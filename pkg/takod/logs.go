@@ -7,17 +7,35 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
+// LogsRequest selects service container logs. Without query fields it
+// streams raw docker logs; with any of Since, Until, Replica, Grep, Exclude,
+// Level, or Fields it runs a log query and streams LogEntry lines instead.
 type LogsRequest struct {
-	Project     string `json:"project"`
-	Environment string `json:"environment"`
-	Service     string `json:"service"`
-	Tail        int    `json:"tail,omitempty"`
-	Follow      bool   `json:"follow,omitempty"`
+	Project     string            `json:"project"`
+	Environment string            `json:"environment"`
+	Service     string            `json:"service"`
+	Tail        int               `json:"tail,omitempty"`
+	Follow      bool              `json:"follow,omitempty"`
+	Since       time.Time         `json:"since,omitzero"`
+	Until       time.Time         `json:"until,omitzero"`
+	Replica     int               `json:"replica,omitempty"`
+	Grep        []string          `json:"grep,omitempty"`
+	Exclude     []string          `json:"exclude,omitempty"`
+	Level       string            `json:"level,omitempty"`
+	Fields      map[string]string `json:"fields,omitempty"`
 }
 
-const maxLogTail = 10000
+// IsQuery reports whether the request uses any log query field.
+func (req LogsRequest) IsQuery() bool {
+	return !req.Since.IsZero() || !req.Until.IsZero() || req.Replica != 0 ||
+		len(req.Grep) > 0 || len(req.Exclude) > 0 || req.Level != "" || len(req.Fields) > 0
+}
+
+// MaxLogTail bounds how many lines one logs request returns.
+const MaxLogTail = 10000
 
 func StreamServiceLogs(ctx context.Context, req LogsRequest, writer io.Writer) error {
 	if err := validateLogsRequest(req); err != nil {
@@ -32,12 +50,18 @@ func StreamServiceLogs(ctx context.Context, req LogsRequest, writer io.Writer) e
 		return err
 	}
 	if len(containers) == 0 {
+		if req.Replica > 0 {
+			return fmt.Errorf("no containers found for service %s replica %d", req.Service, req.Replica)
+		}
 		return fmt.Errorf("no containers found for service %s", req.Service)
 	}
+	if req.IsQuery() {
+		return queryServiceLogs(ctx, req, containers, writer)
+	}
 
 	if !req.Follow || len(containers) == 1 {
 		for _, container := range containers {
-			if err := streamContainerLogs(ctx, writer, container, req.Tail, req.Follow); err != nil {
+			if err := streamContainerLogs(ctx, writer, container.name, req.Tail, req.Follow); err != nil {
 				return err
 			}
 		}
@@ -52,7 +76,7 @@ func StreamServiceLogs(ctx context.Context, req LogsRequest, writer io.Writer) e
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err := streamContainerLogs(ctx, streamWriter, container, req.Tail, true); err != nil {
+			if err := streamContainerLogs(ctx, streamWriter, container.name, req.Tail, true); err != nil {
 				errCh <- err
 			}
 		}()
@@ -89,27 +113,41 @@ func validateLogsRequest(req LogsRequest) error {
 	if req.Tail < 0 {
 		return fmt.Errorf("tail cannot be negative")
 	}
-	if req.Tail > maxLogTail {
-		return fmt.Errorf("tail cannot exceed %d", maxLogTail)
+	if req.Tail > MaxLogTail {
+		return fmt.Errorf("tail cannot exceed %d", MaxLogTail)
 	}
-	return nil
+	return validateLogQuery(req)
 }
 
-func listServiceLogContainers(ctx context.Context, req LogsRequest) ([]string, error) {
-	output, err := runDocker(
-		ctx,
+// logContainer is one service container and the replica slot it serves.
+type logContainer struct {
+	name    string
+	replica int
+}
+
+func listServiceLogContainers(ctx context.Context, req LogsRequest) ([]logContainer, error) {
+	args := []string{
 		"ps",
 		"-a",
-		"--filter", "label=tako.project="+req.Project,
-		"--filter", "label=tako.environment="+req.Environment,
-		"--filter", "label=tako.service="+req.Service,
-		"--format", "{{.Names}}",
-	)
+		"--filter", "label=tako.project=" + req.Project,
+		"--filter", "label=tako.environment=" + req.Environment,
+		"--filter", "label=tako.service=" + req.Service,
+	}
+	if req.Replica > 0 {
+		args = append(args, "--filter", "label=tako.slot="+strconv.Itoa(req.Replica))
+	}
+	args = append(args, "--format", `{{.Names}}|{{.Label "tako.slot"}}`)
+	output, err := runDocker(ctx, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list service containers: %w", err)
 	}
 
-	containers := strings.Fields(strings.TrimSpace(output))
+	var containers []logContainer
+	for _, line := range strings.Fields(strings.TrimSpace(output)) {
+		name, slot, _ := strings.Cut(line, "|")
+		replica, _ := strconv.Atoi(slot)
+		containers = append(containers, logContainer{name: name, replica: replica})
+	}
 	return containers, nil
 }
 
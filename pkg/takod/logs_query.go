@@ -0,0 +1,389 @@
+package takod
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxLogQueryPatterns      = 8
+	maxLogQueryPatternLength = 512
+	maxLogQueryFields        = 16
+	maxLogQueryLineBytes     = 1024 * 1024
+)
+
+// LogEntry is one line of a log query: the docker receive timestamp, the
+// container and replica slot that wrote it, and the raw line.
+type LogEntry struct {
+	Time      time.Time `json:"time"`
+	Container string    `json:"container"`
+	Replica   int       `json:"replica,omitempty"`
+	Line      string    `json:"line"`
+}
+
+// logLevelRanks orders the level names JSON loggers commonly emit. A level
+// filter keeps lines at or above the requested rank.
+var logLevelRanks = map[string]int{
+	"trace":     0,
+	"debug":     1,
+	"info":      2,
+	"notice":    2,
+	"warn":      3,
+	"warning":   3,
+	"error":     4,
+	"err":       4,
+	"fatal":     5,
+	"critical":  5,
+	"crit":      5,
+	"panic":     5,
+	"alert":     5,
+	"emerg":     5,
+	"emergency": 5,
+}
+
+// logLevelKeys are the JSON keys a line's level is read from, in order.
+var logLevelKeys = []string{"level", "lvl", "severity", "loglevel"}
+
+var logFieldKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_@][A-Za-z0-9_.@-]{0,127}$`)
+
+// IsLogLevel reports whether level is a level name log queries understand.
+func IsLogLevel(level string) bool {
+	_, ok := logLevelRanks[strings.ToLower(level)]
+	return ok
+}
+
+func validateLogQuery(req LogsRequest) error {
+	if !req.Since.IsZero() && !req.Until.IsZero() && !req.Since.Before(req.Until) {
+		return fmt.Errorf("since must be before until")
+	}
+	if req.Follow && !req.Until.IsZero() {
+		return fmt.Errorf("until cannot be combined with follow")
+	}
+	if req.Replica < 0 {
+		return fmt.Errorf("replica cannot be negative")
+	}
+	if len(req.Grep)+len(req.Exclude) > maxLogQueryPatterns {
+		return fmt.Errorf("at most %d grep and exclude patterns are allowed", maxLogQueryPatterns)
+	}
+	for _, pattern := range append(append([]string{}, req.Grep...), req.Exclude...) {
+		if pattern == "" || len(pattern) > maxLogQueryPatternLength {
+			return fmt.Errorf("filter patterns must be 1-%d characters", maxLogQueryPatternLength)
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid filter pattern %q: %w", pattern, err)
+		}
+	}
+	if req.Level != "" && !IsLogLevel(req.Level) {
+		return fmt.Errorf("unknown log level %q", req.Level)
+	}
+	if len(req.Fields) > maxLogQueryFields {
+		return fmt.Errorf("at most %d field filters are allowed", maxLogQueryFields)
+	}
+	for key, value := range req.Fields {
+		if !logFieldKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid field filter key %q", key)
+		}
+		if len(value) > maxLogQueryPatternLength || hasControlChars(value) {
+			return fmt.Errorf("invalid value for field filter %q", key)
+		}
+	}
+	return nil
+}
+
+// logMatcher applies a query's line filters. Grep patterns must all match
+// and exclude patterns must not; level and field filters only match lines
+// that parse as JSON objects.
+type logMatcher struct {
+	grep    []*regexp.Regexp
+	exclude []*regexp.Regexp
+	level   int
+	fields  map[string]string
+}
+
+func newLogMatcher(req LogsRequest) *logMatcher {
+	matcher := &logMatcher{level: -1, fields: req.Fields}
+	for _, pattern := range req.Grep {
+		matcher.grep = append(matcher.grep, regexp.MustCompile(pattern))
+	}
+	for _, pattern := range req.Exclude {
+		matcher.exclude = append(matcher.exclude, regexp.MustCompile(pattern))
+	}
+	if req.Level != "" {
+		matcher.level = logLevelRanks[strings.ToLower(req.Level)]
+	}
+	return matcher
+}
+
+// filters reports whether the matcher drops any lines; without filters the
+// query can let docker apply the tail.
+func (m *logMatcher) filters() bool {
+	return len(m.grep) > 0 || len(m.exclude) > 0 || m.level >= 0 || len(m.fields) > 0
+}
+
+func (m *logMatcher) match(line string) bool {
+	for _, pattern := range m.grep {
+		if !pattern.MatchString(line) {
+			return false
+		}
+	}
+	for _, pattern := range m.exclude {
+		if pattern.MatchString(line) {
+			return false
+		}
+	}
+	if m.level < 0 && len(m.fields) == 0 {
+		return true
+	}
+	doc, ok := parseJSONLogLine(line)
+	if !ok {
+		return false
+	}
+	if m.level >= 0 {
+		rank, ok := jsonLogLevel(doc)
+		if !ok || rank < m.level {
+			return false
+		}
+	}
+	for key, want := range m.fields {
+		got, ok := jsonLogField(doc, key)
+		if !ok || got != want {
+			return false
+		}
+	}
+	return true
+}
+
+func parseJSONLogLine(line string) (map[string]any, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "{") {
+		return nil, false
+	}
+	decoder := json.NewDecoder(strings.NewReader(line))
+	decoder.UseNumber()
+	var doc map[string]any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, false
+	}
+	return doc, true
+}
+
+// jsonLogLevel reads a line's level by name, or by pino's numeric scale
+// (10 trace through 60 fatal).
+func jsonLogLevel(doc map[string]any) (int, bool) {
+	for _, key := range logLevelKeys {
+		for candidate, value := range doc {
+			if !strings.EqualFold(candidate, key) {
+				continue
+			}
+			switch typed := value.(type) {
+			case string:
+				rank, ok := logLevelRanks[strings.ToLower(typed)]
+				return rank, ok
+			case json.Number:
+				number, err := typed.Int64()
+				if err != nil {
+					return 0, false
+				}
+				rank := int(number/10) - 1
+				return min(max(rank, 0), 5), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// jsonLogField reads key from doc, first as a literal key and then as a
+// dotted path into nested objects. Scalars compare in their JSON spelling.
+func jsonLogField(doc map[string]any, key string) (string, bool) {
+	value, ok := doc[key]
+	if !ok {
+		var current any = doc
+		for _, part := range strings.Split(key, ".") {
+			object, isObject := current.(map[string]any)
+			if !isObject {
+				return "", false
+			}
+			if current, ok = object[part]; !ok {
+				return "", false
+			}
+		}
+		value = current
+	}
+	switch typed := value.(type) {
+	case string:
+		return typed, true
+	case json.Number:
+		return typed.String(), true
+	case bool:
+		return strconv.FormatBool(typed), true
+	case nil:
+		return "null", true
+	default:
+		return "", false
+	}
+}
+
+// queryServiceLogs answers a log query. The backlog up to a cutoff is read
+// from every container, filtered, merged in time order, and trimmed to the
+// last Tail entries; with Follow each container then streams entries after
+// the cutoff as they arrive.
+func queryServiceLogs(ctx context.Context, req LogsRequest, containers []logContainer, writer io.Writer) error {
+	matcher := newLogMatcher(req)
+	cutoff := req.Until
+	if cutoff.IsZero() {
+		cutoff = time.Now().UTC()
+	}
+
+	var backlog []LogEntry
+	for _, container := range containers {
+		entries, err := readContainerLogEntries(ctx, container, req, cutoff, matcher)
+		if err != nil {
+			return err
+		}
+		backlog = append(backlog, entries...)
+	}
+	sort.SliceStable(backlog, func(i, j int) bool { return backlog[i].Time.Before(backlog[j].Time) })
+	if len(backlog) > req.Tail {
+		backlog = backlog[len(backlog)-req.Tail:]
+	}
+	output := &lockedWriter{writer: writer}
+	for _, entry := range backlog {
+		if err := writeLogEntry(output, entry); err != nil {
+			return err
+		}
+	}
+	if !req.Follow {
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, len(containers))
+	for _, container := range containers {
+		container := container
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := scanContainerLogEntries(ctx, container, []string{"--since", cutoff.Format(time.RFC3339Nano), "-f"}, func(entry LogEntry) error {
+				if !entry.Time.After(cutoff) || !matcher.match(entry.Line) {
+					return nil
+				}
+				return writeLogEntry(output, entry)
+			})
+			if err != nil {
+				errCh <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readContainerLogEntries returns the last Tail matching entries a container
+// wrote between Since and cutoff. Without line filters docker applies the
+// tail itself; with filters the whole range is scanned so older matches are
+// not lost behind newer non-matching lines.
+func readContainerLogEntries(ctx context.Context, container logContainer, req LogsRequest, cutoff time.Time, matcher *logMatcher) ([]LogEntry, error) {
+	args := []string{"--until", cutoff.Format(time.RFC3339Nano)}
+	if !req.Since.IsZero() {
+		args = append(args, "--since", req.Since.UTC().Format(time.RFC3339Nano))
+	}
+	if !matcher.filters() {
+		args = append(args, "--tail", strconv.Itoa(req.Tail))
+	}
+	var entries []LogEntry
+	err := scanContainerLogEntries(ctx, container, args, func(entry LogEntry) error {
+		if entry.Time.After(cutoff) || (!req.Since.IsZero() && entry.Time.Before(req.Since)) || !matcher.match(entry.Line) {
+			return nil
+		}
+		entries = append(entries, entry)
+		if len(entries) >= 2*req.Tail {
+			entries = append(entries[:0], entries[len(entries)-req.Tail:]...)
+		}
+		return nil
+	})
+	if len(entries) > req.Tail {
+		entries = entries[len(entries)-req.Tail:]
+	}
+	return entries, err
+}
+
+// scanContainerLogEntries runs docker logs with timestamps and hands each
+// parsed entry to visit.
+func scanContainerLogEntries(ctx context.Context, container logContainer, extra []string, visit func(LogEntry) error) error {
+	args := append([]string{"logs", "--timestamps"}, extra...)
+	args = append(args, container.name)
+	cmd := dockerCommandContext(ctx, "docker", args...)
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to read logs for %s: %w", container.name, err)
+	}
+	waitErr := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		_ = writer.CloseWithError(err)
+		waitErr <- err
+	}()
+
+	var visitErr error
+	var last time.Time
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLogQueryLineBytes)
+	for scanner.Scan() {
+		entry := parseTimestampedLogLine(scanner.Text(), last)
+		entry.Container = container.name
+		entry.Replica = container.replica
+		last = entry.Time
+		if visitErr = visit(entry); visitErr != nil {
+			break
+		}
+	}
+	scanErr := scanner.Err()
+	// Drain so docker never blocks on a full pipe after an early stop.
+	_, _ = io.Copy(io.Discard, reader)
+	if err := <-waitErr; err != nil && visitErr == nil {
+		return fmt.Errorf("failed to read logs for %s: %w", container.name, err)
+	}
+	if visitErr == nil && scanErr != nil {
+		return fmt.Errorf("failed to read logs for %s: %w", container.name, scanErr)
+	}
+	return visitErr
+}
+
+// parseTimestampedLogLine splits docker's RFC3339Nano timestamp prefix off a
+// line. A line without one inherits the previous line's time.
+func parseTimestampedLogLine(raw string, previous time.Time) LogEntry {
+	stamp, line, found := strings.Cut(raw, " ")
+	if found {
+		if parsed, err := time.Parse(time.RFC3339Nano, stamp); err == nil {
+			return LogEntry{Time: parsed.UTC(), Line: line}
+		}
+	}
+	return LogEntry{Time: previous, Line: raw}
+}
+
+func writeLogEntry(writer io.Writer, entry LogEntry) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(entry); err != nil {
+		return err
+	}
+	_, err := writer.Write(buf.Bytes())
+	return err
+}
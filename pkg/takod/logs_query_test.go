@@ -0,0 +1,196 @@
+package takod
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFakeContainerLogs(t *testing.T, dir string, container string, lines ...string) {
+	t.Helper()
+	content := strings.Join(lines, "\n") + "\n"
+	if err := os.WriteFile(filepath.Join(dir, container+".log"), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func decodeLogEntries(t *testing.T, output []byte) []LogEntry {
+	t.Helper()
+	var entries []LogEntry
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		var entry LogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid entry %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestQueryServiceLogsMergesReplicasInTimeOrder(t *testing.T) {
+	logPath := t.TempDir() + "/commands.log"
+	restore := useFakeCommands(t, logPath)
+	defer restore()
+	logsDir := t.TempDir()
+	t.Setenv("TAKO_FAKE_LOGS_DIR", logsDir)
+	t.Setenv("TAKO_FAKE_PS_OUTPUT", "demo_production_web_1|1\ndemo_production_web_2|2\n")
+	writeFakeContainerLogs(t, logsDir, "demo_production_web_1",
+		`2026-07-06T12:00:01Z {"level":"error","msg":"db timeout","user_id":42}`,
+		`2026-07-06T12:00:03Z {"level":"info","msg":"ok","user_id":42}`,
+		`2026-07-06T12:00:05Z {"level":"error","msg":"db timeout","user_id":7}`,
+	)
+	writeFakeContainerLogs(t, logsDir, "demo_production_web_2",
+		`2026-07-06T12:00:02Z plain text error`,
+		`2026-07-06T12:00:04Z {"level":"fatal","msg":"crash","user_id":42}`,
+	)
+
+	var output bytes.Buffer
+	err := StreamServiceLogs(context.Background(), LogsRequest{
+		Project:     "demo",
+		Environment: "production",
+		Service:     "web",
+		Until:       time.Date(2026, 7, 6, 13, 0, 0, 0, time.UTC),
+		Level:       "error",
+		Fields:      map[string]string{"user_id": "42"},
+	}, &output)
+	if err != nil {
+		t.Fatalf("StreamServiceLogs returned error: %v", err)
+	}
+
+	entries := decodeLogEntries(t, output.Bytes())
+	var lines []string
+	for _, entry := range entries {
+		lines = append(lines, entry.Container+":"+entry.Line)
+	}
+	want := []string{
+		`demo_production_web_1:{"level":"error","msg":"db timeout","user_id":42}`,
+		`demo_production_web_2:{"level":"fatal","msg":"crash","user_id":42}`,
+	}
+	if !slices.Equal(lines, want) {
+		t.Fatalf("entries = %q, want %q", lines, want)
+	}
+	if entries[1].Replica != 2 || !entries[1].Time.Equal(time.Date(2026, 7, 6, 12, 0, 4, 0, time.UTC)) {
+		t.Fatalf("unexpected entry metadata: %+v", entries[1])
+	}
+
+	commands := readCommandLog(t, logPath)
+	for _, entry := range commands[1:] {
+		if !strings.HasPrefix(entry, "docker logs --timestamps --until 2026-07-06T13:00:00Z") || strings.Contains(entry, "--tail") {
+			t.Fatalf("filtered query must scan the range without a docker tail: %q", entry)
+		}
+	}
+}
+
+func TestQueryServiceLogsAppliesTailAfterFiltering(t *testing.T) {
+	logPath := t.TempDir() + "/commands.log"
+	restore := useFakeCommands(t, logPath)
+	defer restore()
+	logsDir := t.TempDir()
+	t.Setenv("TAKO_FAKE_LOGS_DIR", logsDir)
+	t.Setenv("TAKO_FAKE_PS_OUTPUT", "demo_production_web_2|2\n")
+	writeFakeContainerLogs(t, logsDir, "demo_production_web_2",
+		"2026-07-06T12:00:01Z GET /orders timeout",
+		"2026-07-06T12:00:02Z GET /healthz timeout",
+		"2026-07-06T12:00:03Z GET /users ok",
+		"2026-07-06T12:00:04Z GET /users timeout",
+		"2026-07-06T12:00:05Z GET /cart timeout",
+	)
+
+	var output bytes.Buffer
+	err := StreamServiceLogs(context.Background(), LogsRequest{
+		Project:     "demo",
+		Environment: "production",
+		Service:     "web",
+		Tail:        2,
+		Replica:     2,
+		Since:       time.Date(2026, 7, 6, 12, 0, 0, 0, time.UTC),
+		Grep:        []string{"timeout"},
+		Exclude:     []string{"healthz"},
+	}, &output)
+	if err != nil {
+		t.Fatalf("StreamServiceLogs returned error: %v", err)
+	}
+
+	var lines []string
+	for _, entry := range decodeLogEntries(t, output.Bytes()) {
+		lines = append(lines, entry.Line)
+	}
+	if want := []string{"GET /users timeout", "GET /cart timeout"}; !slices.Equal(lines, want) {
+		t.Fatalf("lines = %q, want %q", lines, want)
+	}
+	commands := readCommandLog(t, logPath)
+	if !strings.Contains(commands[0], "label=tako.slot=2") {
+		t.Fatalf("replica selection missing from container discovery: %q", commands[0])
+	}
+	if !strings.Contains(commands[1], "--since 2026-07-06T12:00:00Z") {
+		t.Fatalf("since bound missing from docker logs: %q", commands[1])
+	}
+}
+
+func TestLogMatcherReadsJSONLevelsAndNestedFields(t *testing.T) {
+	matcher := newLogMatcher(LogsRequest{Level: "warn", Fields: map[string]string{"req.user.id": "42", "ok": "false"}})
+	cases := map[string]bool{
+		`{"level":"WARN","req":{"user":{"id":42}},"ok":false}`:       true,
+		`{"severity":"error","req":{"user":{"id":"42"}},"ok":false}`: true,
+		`{"level":50,"req.user.id":42,"ok":false}`:                   true,
+		`{"level":30,"req":{"user":{"id":42}},"ok":false}`:           false,
+		`{"level":"error","req":{"user":{"id":43}},"ok":false}`:      false,
+		`error req.user.id=42`:                                       false,
+	}
+	for line, want := range cases {
+		if got := matcher.match(line); got != want {
+			t.Fatalf("match(%s) = %v, want %v", line, got, want)
+		}
+	}
+}
+
+func TestValidateLogsRequestRejectsBadQueries(t *testing.T) {
+	base := LogsRequest{Project: "demo", Environment: "production", Service: "web"}
+	now := time.Now()
+	for name, mutate := range map[string]func(*LogsRequest){
+		"range":        func(req *LogsRequest) { req.Since, req.Until = now, now.Add(-time.Minute) },
+		"follow until": func(req *LogsRequest) { req.Follow, req.Until = true, now },
+		"pattern":      func(req *LogsRequest) { req.Grep = []string{"("} },
+		"level":        func(req *LogsRequest) { req.Level = "loud" },
+		"field key":    func(req *LogsRequest) { req.Fields = map[string]string{"a b": "1"} },
+		"replica":      func(req *LogsRequest) { req.Replica = -1 },
+	} {
+		req := base
+		mutate(&req)
+		if err := validateLogsRequest(req); err == nil {
+			t.Fatalf("%s: query accepted", name)
+		}
+	}
+}
+
+func TestParseLogQueryParamsReadsRepeatedFilters(t *testing.T) {
+	query, err := url.ParseQuery("since=2026-07-06T12%3A00%3A00Z&replica=3&grep=a&grep=b&exclude=c&level=error&field=user_id%3D42&field=path%3D%2Fa%3Db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var request LogsRequest
+	if err := parseLogQueryParams(query, &request); err != nil {
+		t.Fatalf("parseLogQueryParams returned error: %v", err)
+	}
+	if !request.IsQuery() || request.Replica != 3 || !slices.Equal(request.Grep, []string{"a", "b"}) || request.Exclude[0] != "c" || request.Level != "error" {
+		t.Fatalf("request = %+v", request)
+	}
+	if request.Fields["user_id"] != "42" || request.Fields["path"] != "/a=b" || !request.Since.Equal(time.Date(2026, 7, 6, 12, 0, 0, 0, time.UTC)) {
+		t.Fatalf("request = %+v", request)
+	}
+	for _, raw := range []string{"since=yesterday", "replica=two", "field=novalue"} {
+		query, _ := url.ParseQuery(raw)
+		if err := parseLogQueryParams(query, &LogsRequest{}); err == nil {
+			t.Fatalf("%s accepted", raw)
+		}
+	}
+}
@@ -0,0 +1,532 @@
+package takod
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	metricsExporterDirName = "metrics-exporter"
+	// metricsExporterRetryInterval is how long a listener waits before
+	// binding again, e.g. while the mesh interface is still coming up.
+	metricsExporterRetryInterval = 10 * time.Second
+	// metricsExporterRoutesInterval is how often access log attribution
+	// picks up route changes from deploys.
+	metricsExporterRoutesInterval = 30 * time.Second
+	metricsExporterPath           = "/metrics"
+)
+
+// MetricsExporterSpec serves one project environment's metrics on
+// Address:Port, normally the node's mesh address. TokenSHA256 is the hex
+// SHA-256 of the bearer token scrapes must send; the token itself never
+// reaches the node. Node is the name the deployer knows this server by, set
+// as the server label.
+type MetricsExporterSpec struct {
+	Address     string `json:"address"`
+	Port        int    `json:"port"`
+	TokenSHA256 string `json:"tokenSha256"`
+	Node        string `json:"node,omitempty"`
+}
+
+// MetricsExporterApplyRequest replaces an environment's exporter spec; a nil
+// Spec stops serving it.
+type MetricsExporterApplyRequest struct {
+	Project     string               `json:"project"`
+	Environment string               `json:"environment"`
+	Spec        *MetricsExporterSpec `json:"spec,omitempty"`
+}
+
+type MetricsExporterApplyResponse struct {
+	Project     string `json:"project"`
+	Environment string `json:"environment"`
+	Serving     bool   `json:"serving"`
+	Listen      string `json:"listen,omitempty"`
+}
+
+func validateMetricsExporterSpec(spec *MetricsExporterSpec) error {
+	if ip := net.ParseIP(spec.Address); ip == nil || ip.IsUnspecified() {
+		return fmt.Errorf("metrics address must be a specific IP address")
+	}
+	if spec.Port < 1 || spec.Port > 65535 {
+		return fmt.Errorf("metrics port must be between 1 and 65535")
+	}
+	if decoded, err := hex.DecodeString(spec.TokenSHA256); err != nil || len(decoded) != sha256.Size {
+		return fmt.Errorf("metrics token digest must be a hex SHA-256")
+	}
+	if len(spec.Node) > 255 || strings.IndexFunc(spec.Node, func(r rune) bool { return r <= ' ' || r == 0x7f }) >= 0 {
+		return fmt.Errorf("invalid node name")
+	}
+	return nil
+}
+
+func (spec MetricsExporterSpec) listenAddress() string {
+	return net.JoinHostPort(spec.Address, strconv.Itoa(spec.Port))
+}
+
+// proxyRequestKey counts the proxy requests one route served per status.
+type proxyRequestKey struct {
+	project     string
+	environment string
+	service     string
+	code        string
+}
+
+type proxyRouteKey struct {
+	project     string
+	environment string
+	service     string
+}
+
+type proxyDurationTotal struct {
+	seconds float64
+	count   uint64
+}
+
+// MetricsExporter serves a Prometheus scrape target for every environment
+// with an exporter spec, mirroring LogShipper: specs persist as JSON under
+// the data dir and are reloaded on start. Environments that share an address
+// share one listener; a scrape sees the environments its token belongs to.
+type MetricsExporter struct {
+	dataDir string
+	jobs    *JobScheduler
+	// Seams for the metric sources, the access log, and the listener;
+	// tests stub them.
+	readNode         func(ctx context.Context) (*MetricsResponse, error)
+	readStats        func(ctx context.Context, req StatsRequest) (*StatsResponse, error)
+	listBackups      func(ctx context.Context, req BackupRequest) (*BackupListResponse, error)
+	listCertificates func(ctx context.Context) (*ProxyCertificateListResponse, error)
+	loadRoutes       func(project string, environment string) (accessLogRoutes, error)
+	followAccessLog  func(ctx context.Context, visit func(line string) error) error
+	listen           func(network string, address string) (net.Listener, error)
+	now              func() time.Time
+
+	// applyMu serializes spec replacement and removal.
+	applyMu   sync.Mutex
+	mu        sync.Mutex
+	ctx       context.Context
+	specs     map[string]MetricsExporterSpec
+	listeners map[string]context.CancelFunc
+	tail      context.CancelFunc
+
+	countsMu       sync.Mutex
+	requests       map[proxyRequestKey]uint64
+	durations      map[proxyRouteKey]proxyDurationTotal
+	routes         map[proxyRouteKey]accessLogRoutes
+	routesLoadedAt time.Time
+}
+
+func NewMetricsExporter(dataDir string, jobs *JobScheduler) *MetricsExporter {
+	return &MetricsExporter{
+		dataDir:          dataDir,
+		jobs:             jobs,
+		readNode:         func(ctx context.Context) (*MetricsResponse, error) { return ReadNodeMetrics(ctx, false) },
+		readStats:        ReadContainerStats,
+		listBackups:      ListVolumeBackups,
+		listCertificates: ListProxyCertificates,
+		loadRoutes:       loadAccessLogRoutes,
+		followAccessLog:  followProxyAccessLog,
+		listen:           net.Listen,
+		now:              time.Now,
+		specs:            map[string]MetricsExporterSpec{},
+		listeners:        map[string]context.CancelFunc{},
+		requests:         map[proxyRequestKey]uint64{},
+		durations:        map[proxyRouteKey]proxyDurationTotal{},
+	}
+}
+
+// Run serves every persisted spec and blocks until ctx ends.
+func (x *MetricsExporter) Run(ctx context.Context) {
+	if x == nil {
+		return
+	}
+	x.applyMu.Lock()
+	x.mu.Lock()
+	x.ctx = ctx
+	x.mu.Unlock()
+	if err := x.loadSpecs(); err != nil {
+		fmt.Fprintf(os.Stderr, "takod metrics exporter failed to load specs: %v\n", err)
+	}
+	x.reconcile(nil)
+	x.applyMu.Unlock()
+	<-ctx.Done()
+}
+
+// Apply replaces one environment's spec. A new listen address is bound
+// before the spec is saved, so a port conflict fails the apply.
+func (x *MetricsExporter) Apply(request MetricsExporterApplyRequest) (*MetricsExporterApplyResponse, error) {
+	if x == nil {
+		return nil, fmt.Errorf("metrics exporter is not initialized")
+	}
+	if !isSafeProjectName(request.Project) {
+		return nil, fmt.Errorf("invalid project name")
+	}
+	if !isSafeRuntimeName(request.Environment) {
+		return nil, fmt.Errorf("invalid environment name")
+	}
+	x.applyMu.Lock()
+	defer x.applyMu.Unlock()
+	response := &MetricsExporterApplyResponse{Project: request.Project, Environment: request.Environment}
+	if request.Spec == nil {
+		if err := x.remove(request.Project, request.Environment); err != nil {
+			return nil, err
+		}
+		return response, nil
+	}
+	spec := *request.Spec
+	if err := validateMetricsExporterSpec(&spec); err != nil {
+		return nil, err
+	}
+	response.Serving = true
+	response.Listen = spec.listenAddress()
+
+	key := logShippingKey(request.Project, request.Environment)
+	x.mu.Lock()
+	existing, ok := x.specs[key]
+	_, bound := x.listeners[spec.listenAddress()]
+	started := x.ctx != nil
+	x.mu.Unlock()
+	if ok && reflect.DeepEqual(existing, spec) {
+		return response, nil
+	}
+	var listener net.Listener
+	if started && !bound {
+		var err error
+		listener, err = x.listen("tcp", spec.listenAddress())
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", spec.listenAddress(), err)
+		}
+	}
+	if err := x.persistSpec(request.Project, request.Environment, spec); err != nil {
+		if listener != nil {
+			_ = listener.Close()
+		}
+		return nil, err
+	}
+	x.mu.Lock()
+	x.specs[key] = spec
+	x.mu.Unlock()
+	x.reconcile(listener)
+	return response, nil
+}
+
+// RemoveProject stops serving a project (one environment, or all when
+// environment is empty) and deletes its specs.
+func (x *MetricsExporter) RemoveProject(project string, environment string) error {
+	if x == nil {
+		return nil
+	}
+	if !isSafeProjectName(project) {
+		return fmt.Errorf("invalid project name")
+	}
+	x.applyMu.Lock()
+	defer x.applyMu.Unlock()
+	if environment != "" {
+		return x.remove(project, environment)
+	}
+	x.mu.Lock()
+	for key := range x.specs {
+		if strings.HasPrefix(key, project+"/") {
+			delete(x.specs, key)
+		}
+	}
+	x.mu.Unlock()
+	x.reconcile(nil)
+	if err := os.RemoveAll(filepath.Join(x.dataDir, metricsExporterDirName, project)); err != nil {
+		return fmt.Errorf("failed to remove metrics exporter state: %w", err)
+	}
+	return nil
+}
+
+func (x *MetricsExporter) remove(project string, environment string) error {
+	x.mu.Lock()
+	delete(x.specs, logShippingKey(project, environment))
+	x.mu.Unlock()
+	x.reconcile(nil)
+	if err := os.Remove(x.specPath(project, environment)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove metrics exporter spec: %w", err)
+	}
+	_ = os.Remove(filepath.Join(x.dataDir, metricsExporterDirName, project))
+	return nil
+}
+
+// reconcile starts a listener for every address a spec needs, handing bound
+// over to the one it was bound for, stops listeners no spec needs, and runs
+// the access log counter while any spec exists. Before Run has provided a
+// context nothing is started.
+func (x *MetricsExporter) reconcile(bound net.Listener) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.ctx == nil {
+		if bound != nil {
+			_ = bound.Close()
+		}
+		return
+	}
+	wanted := map[string]bool{}
+	for _, spec := range x.specs {
+		wanted[spec.listenAddress()] = true
+	}
+	for address, cancel := range x.listeners {
+		if !wanted[address] {
+			cancel()
+			delete(x.listeners, address)
+		}
+	}
+	for address := range wanted {
+		if _, ok := x.listeners[address]; ok {
+			continue
+		}
+		var listener net.Listener
+		if bound != nil && bound.Addr().String() == address {
+			listener, bound = bound, nil
+		}
+		ctx, cancel := context.WithCancel(x.ctx)
+		x.listeners[address] = cancel
+		go x.serve(ctx, address, listener)
+	}
+	if bound != nil {
+		_ = bound.Close()
+	}
+	switch {
+	case len(x.specs) == 0 && x.tail != nil:
+		x.tail()
+		x.tail = nil
+	case len(x.specs) > 0 && x.tail == nil:
+		ctx, cancel := context.WithCancel(x.ctx)
+		x.tail = cancel
+		go x.countAccessLog(ctx)
+	}
+}
+
+// serve answers scrapes on address until ctx ends, binding again after a
+// failure.
+func (x *MetricsExporter) serve(ctx context.Context, address string, listener net.Listener) {
+	for {
+		if listener == nil {
+			var err error
+			listener, err = x.listen("tcp", address)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "takod metrics exporter failed to listen on %s: %v\n", address, err)
+			}
+		}
+		if listener != nil {
+			server := newTakodHTTPServer(x.handler(address))
+			stopped := make(chan struct{})
+			go func() {
+				select {
+				case <-ctx.Done():
+					shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+					_ = server.Shutdown(shutdownCtx)
+				case <-stopped:
+				}
+			}()
+			err := server.Serve(listener)
+			close(stopped)
+			listener = nil
+			if err != nil && !errors.Is(err, http.ErrServerClosed) && ctx.Err() == nil {
+				fmt.Fprintf(os.Stderr, "takod metrics exporter on %s stopped: %v\n", address, err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(metricsExporterRetryInterval):
+		}
+	}
+}
+
+func (x *MetricsExporter) handler(address string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != metricsExporterPath {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		scopes, node := x.authenticate(address, r.Header.Get("Authorization"))
+		if len(scopes) == 0 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="takod metrics"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		exposition := x.collect(r.Context(), scopes, node)
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if r.Method == http.MethodHead {
+			return
+		}
+		_ = exposition.write(w)
+	})
+}
+
+// authenticate returns the environments served on address whose token the
+// bearer header carries, and the node name their specs set.
+func (x *MetricsExporter) authenticate(address string, header string) ([]proxyRouteKey, string) {
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || strings.TrimSpace(token) == "" {
+		return nil, ""
+	}
+	digest := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	presented := hex.EncodeToString(digest[:])
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	var scopes []proxyRouteKey
+	node := ""
+	for key, spec := range x.specs {
+		if spec.listenAddress() != address || subtle.ConstantTimeCompare([]byte(presented), []byte(strings.ToLower(spec.TokenSHA256))) != 1 {
+			continue
+		}
+		project, environment, _ := strings.Cut(key, "/")
+		scopes = append(scopes, proxyRouteKey{project: project, environment: environment})
+		if node == "" {
+			node = spec.Node
+		}
+	}
+	sort.Slice(scopes, func(i, j int) bool {
+		if scopes[i].project != scopes[j].project {
+			return scopes[i].project < scopes[j].project
+		}
+		return scopes[i].environment < scopes[j].environment
+	})
+	return scopes, node
+}
+
+// countAccessLog counts the proxy requests each served environment's routes
+// answer from now on; counters start at zero when takod starts.
+func (x *MetricsExporter) countAccessLog(ctx context.Context) {
+	since := float64(x.now().UnixNano()) / float64(time.Second)
+	err := x.followAccessLog(ctx, func(line string) error {
+		var entry proxyAccessLogEntry
+		if json.Unmarshal([]byte(line), &entry) != nil || entry.TS <= since {
+			return nil
+		}
+		x.countAccessLogEntry(entry)
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		fmt.Fprintf(os.Stderr, "takod metrics exporter stopped following the proxy access log: %v\n", err)
+	}
+}
+
+func (x *MetricsExporter) countAccessLogEntry(entry proxyAccessLogEntry) {
+	x.countsMu.Lock()
+	defer x.countsMu.Unlock()
+	if x.routes == nil || x.now().Sub(x.routesLoadedAt) >= metricsExporterRoutesInterval {
+		x.refreshRoutesLocked()
+	}
+	for scope, routes := range x.routes {
+		target, ok := routes.attribute(entry)
+		if !ok {
+			continue
+		}
+		route := proxyRouteKey{project: scope.project, environment: scope.environment, service: target.service}
+		code := strconv.Itoa(entry.Status)
+		x.requests[proxyRequestKey{project: route.project, environment: route.environment, service: route.service, code: code}]++
+		total := x.durations[route]
+		if !math.IsNaN(entry.Duration) && entry.Duration >= 0 {
+			total.seconds += entry.Duration
+		}
+		total.count++
+		x.durations[route] = total
+		return
+	}
+}
+
+func (x *MetricsExporter) refreshRoutesLocked() {
+	x.mu.Lock()
+	keys := make([]string, 0, len(x.specs))
+	for key := range x.specs {
+		keys = append(keys, key)
+	}
+	x.mu.Unlock()
+	routes := make(map[proxyRouteKey]accessLogRoutes, len(keys))
+	for _, key := range keys {
+		project, environment, _ := strings.Cut(key, "/")
+		loaded, err := x.loadRoutes(project, environment)
+		if err != nil || loaded.empty() {
+			continue
+		}
+		routes[proxyRouteKey{project: project, environment: environment}] = loaded
+	}
+	x.routes = routes
+	x.routesLoadedAt = x.now()
+}
+
+func (x *MetricsExporter) loadSpecs() error {
+	root := filepath.Join(x.dataDir, metricsExporterDirName)
+	projects, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, project := range projects {
+		if !project.IsDir() {
+			continue
+		}
+		environments, err := os.ReadDir(filepath.Join(root, project.Name()))
+		if err != nil {
+			return err
+		}
+		for _, environment := range environments {
+			name, ok := strings.CutSuffix(environment.Name(), ".json")
+			if environment.IsDir() || !ok {
+				continue
+			}
+			path := filepath.Join(root, project.Name(), environment.Name())
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			var spec MetricsExporterSpec
+			if err := json.Unmarshal(data, &spec); err != nil {
+				return fmt.Errorf("failed to parse metrics exporter spec %s: %w", path, err)
+			}
+			if err := validateMetricsExporterSpec(&spec); err != nil {
+				return fmt.Errorf("invalid metrics exporter spec %s: %w", path, err)
+			}
+			x.mu.Lock()
+			x.specs[logShippingKey(project.Name(), name)] = spec
+			x.mu.Unlock()
+		}
+	}
+	return nil
+}
+
+func (x *MetricsExporter) specPath(project string, environment string) string {
+	return filepath.Join(x.dataDir, metricsExporterDirName, project, environment+".json")
+}
+
+func (x *MetricsExporter) persistSpec(project string, environment string, spec MetricsExporterSpec) error {
+	path := x.specPath(project, environment)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create metrics exporter directory: %w", err)
+	}
+	data, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode metrics exporter spec: %w", err)
+	}
+	if err := writeFileAtomic(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write metrics exporter spec: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package takod
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func metricsTokenDigest(token string) string {
+	digest := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(digest[:])
+}
+
+func freeLocalPort(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func newTestMetricsExporter(t *testing.T, dataDir string) *MetricsExporter {
+	t.Helper()
+	exporter := NewMetricsExporter(dataDir, nil)
+	exporter.readNode = func(context.Context) (*MetricsResponse, error) {
+		return &MetricsResponse{Metrics: json.RawMessage(`{"cpu_percent":"12.5","memory":{"total_mb":2048,"used_mb":1024,"percent":"50.0"},"load_average":{"1min":"0.42"}}`)}, nil
+	}
+	exporter.readStats = func(_ context.Context, req StatsRequest) (*StatsResponse, error) {
+		return &StatsResponse{Stats: []ContainerStat{{Name: req.Project + "_" + req.Environment + "_web_1", CPUPercent: "3.25%", MemUsage: "64MiB / 1GiB", MemPercent: "6.25%", PIDs: "7"}}}, nil
+	}
+	exporter.listBackups = func(_ context.Context, req BackupRequest) (*BackupListResponse, error) {
+		created := time.Now().Add(-time.Hour)
+		return &BackupListResponse{Backups: []BackupInfo{
+			{ID: "new", Service: "db", Volume: "data", Size: 4096, CreatedAt: created, Verification: &BackupVerification{Status: BackupVerifyPassed}},
+			{ID: "old", Service: "db", Volume: "data", Size: 1024, CreatedAt: created.Add(-24 * time.Hour)},
+		}}, nil
+	}
+	exporter.listCertificates = func(context.Context) (*ProxyCertificateListResponse, error) {
+		return &ProxyCertificateListResponse{Certificates: []ProxyCertificateMetadata{
+			{Domain: "shop.example.com", Source: CertificateSourceACMEDNS, NotAfter: time.Unix(1900000000, 0), OwnerProject: "shop", OwnerEnvironment: "production"},
+			{Domain: "blog.example.com", Source: CertificateSourceACMEDNS, NotAfter: time.Unix(1900000000, 0), OwnerProject: "blog", OwnerEnvironment: "production"},
+		}}, nil
+	}
+	exporter.loadRoutes = func(project string, environment string) (accessLogRoutes, error) {
+		return accessLogRoutes{loggers: map[string]string{"http.log.access." + caddyAccessLogName(project+"-web"): "web"}}, nil
+	}
+	exporter.followAccessLog = func(ctx context.Context, visit func(string) error) error {
+		<-ctx.Done()
+		return nil
+	}
+	return exporter
+}
+
+func runTestMetricsExporter(t *testing.T, exporter *MetricsExporter) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go exporter.Run(ctx)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		exporter.mu.Lock()
+		started := exporter.ctx != nil
+		exporter.mu.Unlock()
+		if started || time.Now().After(deadline) {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func scrapeTestMetrics(t *testing.T, address string, token string) (int, string) {
+	t.Helper()
+	request, err := http.NewRequest(http.MethodGet, "http://"+address+"/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("scrape %s: %v", address, err)
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+	return response.StatusCode, string(body)
+}
+
+func TestMetricsExporterServesEachTokenItsOwnEnvironments(t *testing.T) {
+	dataDir := t.TempDir()
+	exporter := newTestMetricsExporter(t, dataDir)
+	runTestMetricsExporter(t, exporter)
+
+	port := freeLocalPort(t)
+	for project, token := range map[string]string{"shop": "shop-scrape-token-123", "blog": "blog-scrape-token-456"} {
+		response, err := exporter.Apply(MetricsExporterApplyRequest{Project: project, Environment: "production", Spec: &MetricsExporterSpec{
+			Address: "127.0.0.1", Port: port, TokenSHA256: metricsTokenDigest(token), Node: "node-a",
+		}})
+		if err != nil {
+			t.Fatalf("Apply %s: %v", project, err)
+		}
+		if !response.Serving || response.Listen != net.JoinHostPort("127.0.0.1", strconv.Itoa(port)) {
+			t.Fatalf("response = %+v", response)
+		}
+	}
+	exporter.countAccessLogEntry(proxyAccessLogEntry{Logger: "http.log.access.tako_shop-web", Status: 200, Duration: 0.25})
+	exporter.countAccessLogEntry(proxyAccessLogEntry{Logger: "http.log.access.tako_shop-web", Status: 502, Duration: 0.5})
+	exporter.countAccessLogEntry(proxyAccessLogEntry{Logger: "http.log.access.tako_blog-web", Status: 200, Duration: 0.1})
+
+	address := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	if status, _ := scrapeTestMetrics(t, address, ""); status != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated scrape status = %d", status)
+	}
+	if status, _ := scrapeTestMetrics(t, address, "wrong-token-000000000"); status != http.StatusUnauthorized {
+		t.Fatalf("wrong token scrape status = %d", status)
+	}
+	status, body := scrapeTestMetrics(t, address, "shop-scrape-token-123")
+	if status != http.StatusOK {
+		t.Fatalf("scrape status = %d: %s", status, body)
+	}
+	for _, want := range []string{
+		"# TYPE tako_cpu_usage_percent gauge\n",
+		`tako_cpu_usage_percent{server="node-a"} 12.5`,
+		`tako_memory_used_bytes{server="node-a"} 1073741824`,
+		`tako_container_memory_used_bytes{server="node-a",project="shop",environment="production",container="shop_production_web_1"} 67108864`,
+		`tako_backup_last_size_bytes{server="node-a",project="shop",environment="production",service="db",volume="data"} 4096`,
+		`tako_backup_last_verified{server="node-a",project="shop",environment="production",service="db",volume="data"} 1`,
+		`tako_certificate_expiry_timestamp_seconds{server="node-a",project="shop",environment="production",domain="shop.example.com",source="acme-dns"} 1900000000`,
+		`tako_lease_held{server="node-a",project="shop",environment="production"} 0`,
+		`tako_proxy_requests_total{server="node-a",project="shop",environment="production",service="web",code="502"} 1`,
+		`tako_proxy_request_duration_seconds_sum{server="node-a",project="shop",environment="production",service="web"} 0.75`,
+		`tako_proxy_request_duration_seconds_count{server="node-a",project="shop",environment="production",service="web"} 2`,
+		`tako_exporter_collector_success{server="node-a",collector="node"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("scrape is missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "blog") {
+		t.Fatalf("shop's token saw blog's metrics:\n%s", body)
+	}
+	if strings.Count(body, "# TYPE tako_backup_age_seconds") != 1 || strings.Count(body, "tako_backup_age_seconds{") != 1 {
+		t.Fatalf("expected one backup age sample for the newest backup:\n%s", body)
+	}
+
+	if _, err := exporter.Apply(MetricsExporterApplyRequest{Project: "shop", Environment: "production"}); err != nil {
+		t.Fatalf("Apply nil spec: %v", err)
+	}
+	if status, _ := scrapeTestMetrics(t, address, "shop-scrape-token-123"); status != http.StatusUnauthorized {
+		t.Fatalf("removed environment's token still scrapes: %d", status)
+	}
+	if status, _ := scrapeTestMetrics(t, address, "blog-scrape-token-456"); status != http.StatusOK {
+		t.Fatalf("remaining environment's scrape status = %d", status)
+	}
+}
+
+func TestMetricsExporterReloadsPersistedSpecs(t *testing.T) {
+	dataDir := t.TempDir()
+	port := freeLocalPort(t)
+	first := newTestMetricsExporter(t, dataDir)
+	if _, err := first.Apply(MetricsExporterApplyRequest{Project: "shop", Environment: "production", Spec: &MetricsExporterSpec{
+		Address: "127.0.0.1", Port: port, TokenSHA256: metricsTokenDigest("shop-scrape-token-123"), Node: "node-a",
+	}}); err != nil {
+		t.Fatalf("Apply before Run: %v", err)
+	}
+
+	restarted := newTestMetricsExporter(t, dataDir)
+	runTestMetricsExporter(t, restarted)
+	address := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", address)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("restarted exporter never listened on %s", address)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if status, body := scrapeTestMetrics(t, address, "shop-scrape-token-123"); status != http.StatusOK || !strings.Contains(body, `project="shop"`) {
+		t.Fatalf("restarted scrape = %d:\n%s", status, body)
+	}
+}
+
+func TestValidateMetricsExporterSpecRejectsUnsafeSpecs(t *testing.T) {
+	valid := MetricsExporterSpec{Address: "10.210.0.2", Port: 9465, TokenSHA256: metricsTokenDigest("token"), Node: "node-a"}
+	if err := validateMetricsExporterSpec(&valid); err != nil {
+		t.Fatalf("valid spec rejected: %v", err)
+	}
+	for name, mutate := range map[string]func(*MetricsExporterSpec){
+		"wildcard address": func(spec *MetricsExporterSpec) { spec.Address = "0.0.0.0" },
+		"hostname":         func(spec *MetricsExporterSpec) { spec.Address = "node-a" },
+		"port":             func(spec *MetricsExporterSpec) { spec.Port = 0 },
+		"raw token":        func(spec *MetricsExporterSpec) { spec.TokenSHA256 = "shop-scrape-token-123" },
+	} {
+		spec := valid
+		mutate(&spec)
+		if err := validateMetricsExporterSpec(&spec); err == nil {
+			t.Fatalf("%s: spec accepted", name)
+		}
+	}
+}
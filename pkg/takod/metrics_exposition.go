@@ -0,0 +1,428 @@
+package takod
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// promExposition builds a Prometheus text exposition. Samples are grouped
+// under their family's HELP and TYPE lines in the order families are first
+// added, and every sample carries the constant labels first.
+type promExposition struct {
+	constLabels []string
+	families    []*promFamily
+	index       map[string]*promFamily
+}
+
+type promFamily struct {
+	name    string
+	kind    string
+	help    string
+	samples []promSample
+}
+
+type promSample struct {
+	suffix string
+	labels []string
+	value  float64
+}
+
+func newPromExposition(constLabels ...string) *promExposition {
+	return &promExposition{constLabels: constLabels, index: map[string]*promFamily{}}
+}
+
+// add records one sample of family name; labels alternate names and values.
+func (e *promExposition) add(name string, kind string, help string, value float64, labels ...string) {
+	e.addSuffixed(name, "", kind, help, value, labels...)
+}
+
+func (e *promExposition) addSuffixed(name string, suffix string, kind string, help string, value float64, labels ...string) {
+	family := e.index[name]
+	if family == nil {
+		family = &promFamily{name: name, kind: kind, help: help}
+		e.index[name] = family
+		e.families = append(e.families, family)
+	}
+	family.samples = append(family.samples, promSample{suffix: suffix, labels: labels, value: value})
+}
+
+func (e *promExposition) write(w io.Writer) error {
+	var b strings.Builder
+	for _, family := range e.families {
+		fmt.Fprintf(&b, "# HELP %s %s\n", family.name, family.help)
+		fmt.Fprintf(&b, "# TYPE %s %s\n", family.name, family.kind)
+		for _, sample := range family.samples {
+			b.WriteString(family.name)
+			b.WriteString(sample.suffix)
+			labels := append(append([]string(nil), e.constLabels...), sample.labels...)
+			if len(labels) > 0 {
+				b.WriteByte('{')
+				for i := 0; i+1 < len(labels); i += 2 {
+					if i > 0 {
+						b.WriteByte(',')
+					}
+					fmt.Fprintf(&b, "%s=\"%s\"", labels[i], promLabelValue(labels[i+1]))
+				}
+				b.WriteByte('}')
+			}
+			b.WriteByte(' ')
+			b.WriteString(promValue(sample.value))
+			b.WriteByte('\n')
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func promLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func promValue(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	if value == math.Trunc(value) && math.Abs(value) < 1e15 {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func promBool(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// nodeMetricsSnapshot is the part of the node monitor's current.json the
+// exporter serves. The monitor writes percentages and load as strings.
+type nodeMetricsSnapshot struct {
+	CPUPercent string `json:"cpu_percent"`
+	Memory     struct {
+		TotalMB     int64  `json:"total_mb"`
+		UsedMB      int64  `json:"used_mb"`
+		Percent     string `json:"percent"`
+		SwapTotalMB int64  `json:"swap_total_mb"`
+		SwapUsedMB  int64  `json:"swap_used_mb"`
+	} `json:"memory"`
+	Disk struct {
+		TotalMB int64  `json:"total_mb"`
+		UsedMB  int64  `json:"used_mb"`
+		Percent string `json:"percent"`
+	} `json:"disk"`
+	Network struct {
+		RxBytes int64 `json:"rx_bytes"`
+		TxBytes int64 `json:"tx_bytes"`
+	} `json:"network"`
+	DiskIO struct {
+		ReadSectors  int64 `json:"read_sectors"`
+		WriteSectors int64 `json:"write_sectors"`
+	} `json:"disk_io"`
+	UptimeSeconds int64 `json:"uptime_seconds"`
+	LoadAverage   struct {
+		OneMin     string `json:"1min"`
+		FiveMin    string `json:"5min"`
+		FifteenMin string `json:"15min"`
+	} `json:"load_average"`
+}
+
+// collect renders node metrics and the metrics of scopes' environments. A
+// source that fails is left out and reported through
+// tako_exporter_collector_success rather than failing the scrape.
+func (x *MetricsExporter) collect(ctx context.Context, scopes []proxyRouteKey, node string) *promExposition {
+	e := newPromExposition("server", node)
+	collectors := []struct {
+		name string
+		run  func() error
+	}{
+		{"node", func() error { return x.collectNode(ctx, e) }},
+		{"containers", func() error {
+			return x.forEachScope(scopes, func(scope proxyRouteKey) error { return x.collectContainers(ctx, e, scope) })
+		}},
+		{"jobs", func() error {
+			return x.forEachScope(scopes, func(scope proxyRouteKey) error { return x.collectJobs(e, scope) })
+		}},
+		{"backups", func() error {
+			return x.forEachScope(scopes, func(scope proxyRouteKey) error { return x.collectBackups(ctx, e, scope) })
+		}},
+		{"certificates", func() error { return x.collectCertificates(ctx, e, scopes) }},
+		{"leases", func() error {
+			return x.forEachScope(scopes, func(scope proxyRouteKey) error { return x.collectLease(ctx, e, scope) })
+		}},
+		{"proxy", func() error { x.collectProxyRequests(e, scopes); return nil }},
+	}
+	results := make([]float64, len(collectors))
+	for i, collector := range collectors {
+		results[i] = promBool(collector.run() == nil)
+	}
+	for i, collector := range collectors {
+		e.add("tako_exporter_collector_success", "gauge", "Whether the collector read its source on this scrape", results[i], "collector", collector.name)
+	}
+	return e
+}
+
+func (x *MetricsExporter) forEachScope(scopes []proxyRouteKey, visit func(scope proxyRouteKey) error) error {
+	var failed error
+	for _, scope := range scopes {
+		if err := visit(scope); err != nil && failed == nil {
+			failed = err
+		}
+	}
+	return failed
+}
+
+func (x *MetricsExporter) collectNode(ctx context.Context, e *promExposition) error {
+	response, err := x.readNode(ctx)
+	if err != nil {
+		return err
+	}
+	var node nodeMetricsSnapshot
+	if err := json.Unmarshal(response.Metrics, &node); err != nil {
+		return fmt.Errorf("failed to parse node metrics: %w", err)
+	}
+	const mb = 1024 * 1024
+	addParsed := func(name string, help string, value string) {
+		if parsed, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64); err == nil {
+			e.add(name, "gauge", help, parsed)
+		}
+	}
+	addParsed("tako_cpu_usage_percent", "CPU usage percentage", node.CPUPercent)
+	e.add("tako_memory_total_bytes", "gauge", "Total memory in bytes", float64(node.Memory.TotalMB*mb))
+	e.add("tako_memory_used_bytes", "gauge", "Used memory in bytes", float64(node.Memory.UsedMB*mb))
+	addParsed("tako_memory_usage_percent", "Memory usage percentage", node.Memory.Percent)
+	e.add("tako_swap_total_bytes", "gauge", "Total swap in bytes", float64(node.Memory.SwapTotalMB*mb))
+	e.add("tako_swap_used_bytes", "gauge", "Used swap in bytes", float64(node.Memory.SwapUsedMB*mb))
+	e.add("tako_disk_total_bytes", "gauge", "Total disk space in bytes", float64(node.Disk.TotalMB*mb))
+	e.add("tako_disk_used_bytes", "gauge", "Used disk space in bytes", float64(node.Disk.UsedMB*mb))
+	addParsed("tako_disk_usage_percent", "Disk usage percentage", node.Disk.Percent)
+	e.add("tako_network_receive_bytes", "counter", "Network bytes received", float64(node.Network.RxBytes))
+	e.add("tako_network_transmit_bytes", "counter", "Network bytes transmitted", float64(node.Network.TxBytes))
+	e.add("tako_disk_read_bytes", "counter", "Disk bytes read", float64(node.DiskIO.ReadSectors*512))
+	e.add("tako_disk_write_bytes", "counter", "Disk bytes written", float64(node.DiskIO.WriteSectors*512))
+	addParsed("tako_load_average_1m", "Load average 1 minute", node.LoadAverage.OneMin)
+	addParsed("tako_load_average_5m", "Load average 5 minutes", node.LoadAverage.FiveMin)
+	addParsed("tako_load_average_15m", "Load average 15 minutes", node.LoadAverage.FifteenMin)
+	e.add("tako_uptime_seconds", "counter", "System uptime in seconds", float64(node.UptimeSeconds))
+	return nil
+}
+
+func (x *MetricsExporter) collectContainers(ctx context.Context, e *promExposition, scope proxyRouteKey) error {
+	response, err := x.readStats(ctx, StatsRequest{Project: scope.project, Environment: scope.environment})
+	if err != nil {
+		return err
+	}
+	for _, stat := range response.Stats {
+		labels := []string{"project", scope.project, "environment", scope.environment, "container", stat.Name}
+		if cpu, err := strconv.ParseFloat(strings.TrimSuffix(stat.CPUPercent, "%"), 64); err == nil {
+			e.add("tako_container_cpu_usage_percent", "gauge", "Container CPU usage percentage", cpu, labels...)
+		}
+		if used, limit, ok := strings.Cut(stat.MemUsage, " / "); ok {
+			e.add("tako_container_memory_used_bytes", "gauge", "Container memory usage in bytes", float64(parseDockerSize(used)), labels...)
+			e.add("tako_container_memory_limit_bytes", "gauge", "Container memory limit in bytes", float64(parseDockerSize(limit)), labels...)
+		}
+		if memory, err := strconv.ParseFloat(strings.TrimSuffix(stat.MemPercent, "%"), 64); err == nil {
+			e.add("tako_container_memory_usage_percent", "gauge", "Container memory usage percentage", memory, labels...)
+		}
+		if pids, err := strconv.ParseFloat(stat.PIDs, 64); err == nil {
+			e.add("tako_container_pids", "gauge", "Container process count", pids, labels...)
+		}
+	}
+	return nil
+}
+
+// parseDockerSize reads docker stats sizes like "100MiB" or "1.5GB".
+func parseDockerSize(value string) int64 {
+	var number float64
+	var unit string
+	_, _ = fmt.Sscanf(strings.TrimSpace(value), "%f%s", &number, &unit)
+	multiplier := float64(1)
+	switch strings.ToUpper(unit) {
+	case "KIB":
+		multiplier = 1 << 10
+	case "MIB":
+		multiplier = 1 << 20
+	case "GIB":
+		multiplier = 1 << 30
+	case "TIB":
+		multiplier = 1 << 40
+	case "KB":
+		multiplier = 1e3
+	case "MB":
+		multiplier = 1e6
+	case "GB":
+		multiplier = 1e9
+	case "TB":
+		multiplier = 1e12
+	}
+	return int64(number * multiplier)
+}
+
+func (x *MetricsExporter) collectJobs(e *promExposition, scope proxyRouteKey) error {
+	if x.jobs == nil {
+		return nil
+	}
+	for _, job := range x.jobs.List(scope.project, scope.environment) {
+		labels := []string{"project", scope.project, "environment", scope.environment, "job", job.Name}
+		if job.NextRun != nil {
+			e.add("tako_job_next_run_timestamp_seconds", "gauge", "When the job is next scheduled to run", float64(job.NextRun.Unix()), labels...)
+		}
+		if last := job.LastRun; last != nil {
+			e.add("tako_job_last_run_timestamp_seconds", "gauge", "When the job's last run finished", float64(last.FinishedAt.Unix()), labels...)
+			e.add("tako_job_last_run_success", "gauge", "Whether the job's last run succeeded", promBool(last.Status == JobRunStatusSucceeded), labels...)
+			e.add("tako_job_last_run_duration_seconds", "gauge", "How long the job's last run took", float64(last.DurationMs)/1000, labels...)
+		}
+	}
+	runs, err := x.jobs.Runs(scope.project, scope.environment, "")
+	if err != nil {
+		return err
+	}
+	counts := map[[2]string]int{}
+	for _, run := range runs {
+		counts[[2]string{run.Job, run.Status}]++
+	}
+	keys := make([][2]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	for _, key := range keys {
+		e.add("tako_job_recorded_runs", "gauge", "Job runs in the node's retained run history by outcome", float64(counts[key]),
+			"project", scope.project, "environment", scope.environment, "job", key[0], "status", key[1])
+	}
+	return nil
+}
+
+func (x *MetricsExporter) collectBackups(ctx context.Context, e *promExposition, scope proxyRouteKey) error {
+	response, err := x.listBackups(ctx, BackupRequest{Project: scope.project, Environment: scope.environment})
+	if err != nil {
+		return err
+	}
+	now := x.now()
+	seen := map[string]bool{}
+	// The list is newest first within each volume.
+	for _, backup := range response.Backups {
+		if seen[backup.Volume] {
+			continue
+		}
+		seen[backup.Volume] = true
+		labels := []string{"project", scope.project, "environment", scope.environment, "service", backup.Service, "volume", backup.Volume}
+		e.add("tako_backup_last_timestamp_seconds", "gauge", "When the volume's newest backup on this node was taken", float64(backup.CreatedAt.Unix()), labels...)
+		e.add("tako_backup_age_seconds", "gauge", "Age of the volume's newest backup on this node", now.Sub(backup.CreatedAt).Seconds(), labels...)
+		e.add("tako_backup_last_size_bytes", "gauge", "Size of the volume's newest backup on this node", float64(backup.Size), labels...)
+		if backup.Verification != nil {
+			e.add("tako_backup_last_verified", "gauge", "Whether the newest backup's latest restore drill passed", promBool(backup.Verification.Status == BackupVerifyPassed), labels...)
+		}
+	}
+	return nil
+}
+
+// collectCertificates reports the node-managed certificates the scoped
+// environments own: pushed certificates and DNS-01 issuance. Certificates
+// the proxy obtains on its own are not in the node's store.
+func (x *MetricsExporter) collectCertificates(ctx context.Context, e *promExposition, scopes []proxyRouteKey) error {
+	response, err := x.listCertificates(ctx)
+	if err != nil {
+		return err
+	}
+	owned := map[proxyRouteKey]bool{}
+	for _, scope := range scopes {
+		owned[scope] = true
+	}
+	for _, certificate := range response.Certificates {
+		scope := proxyRouteKey{project: certificate.OwnerProject, environment: certificate.OwnerEnvironment}
+		if !owned[scope] {
+			continue
+		}
+		labels := []string{"project", scope.project, "environment", scope.environment, "domain", certificate.Domain, "source", certificate.Source}
+		if !certificate.NotAfter.IsZero() {
+			e.add("tako_certificate_expiry_timestamp_seconds", "gauge", "When the certificate expires", float64(certificate.NotAfter.Unix()), labels...)
+		}
+		e.add("tako_certificate_renewal_failing", "gauge", "Whether the certificate's last issuance or renewal attempt failed", promBool(certificate.LastError != ""), labels...)
+	}
+	return nil
+}
+
+func (x *MetricsExporter) collectLease(ctx context.Context, e *promExposition, scope proxyRouteKey) error {
+	response, err := ReadLease(ctx, x.dataDir, LeaseRequest{Project: scope.project, Environment: scope.environment})
+	if err != nil {
+		return err
+	}
+	labels := []string{"project", scope.project, "environment", scope.environment}
+	e.add("tako_lease_held", "gauge", "Whether a deploy or other operation holds the environment's lease on this node", promBool(response.Found), labels...)
+	if response.Found && response.Lease != nil {
+		e.add("tako_lease_expiry_timestamp_seconds", "gauge", "When the held lease expires unless renewed", float64(response.Lease.ExpiresAt.Unix()),
+			append(labels, "operation", response.Lease.Operation, "holder", response.Lease.Who)...)
+	}
+	return nil
+}
+
+func (x *MetricsExporter) collectProxyRequests(e *promExposition, scopes []proxyRouteKey) {
+	served := map[proxyRouteKey]bool{}
+	for _, scope := range scopes {
+		served[scope] = true
+	}
+	x.countsMu.Lock()
+	requests := make([]proxyRequestKey, 0, len(x.requests))
+	for key := range x.requests {
+		if served[proxyRouteKey{project: key.project, environment: key.environment}] {
+			requests = append(requests, key)
+		}
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		a, b := requests[i], requests[j]
+		if a.project != b.project {
+			return a.project < b.project
+		}
+		if a.environment != b.environment {
+			return a.environment < b.environment
+		}
+		if a.service != b.service {
+			return a.service < b.service
+		}
+		return a.code < b.code
+	})
+	for _, key := range requests {
+		e.add("tako_proxy_requests_total", "counter", "Requests the proxy answered for the route by status code", float64(x.requests[key]),
+			"project", key.project, "environment", key.environment, "service", key.service, "code", key.code)
+	}
+	routes := make([]proxyRouteKey, 0, len(x.durations))
+	for key := range x.durations {
+		if served[proxyRouteKey{project: key.project, environment: key.environment}] {
+			routes = append(routes, key)
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		a, b := routes[i], routes[j]
+		if a.project != b.project {
+			return a.project < b.project
+		}
+		if a.environment != b.environment {
+			return a.environment < b.environment
+		}
+		return a.service < b.service
+	})
+	for _, key := range routes {
+		labels := []string{"project", key.project, "environment", key.environment, "service", key.service}
+		total := x.durations[key]
+		e.addSuffixed("tako_proxy_request_duration_seconds", "_sum", "summary", "Time the proxy spent answering the route's requests", total.seconds, labels...)
+		e.addSuffixed("tako_proxy_request_duration_seconds", "_count", "summary", "Time the proxy spent answering the route's requests", float64(total.count), labels...)
+	}
+	x.countsMu.Unlock()
+}
@@ -0,0 +1,746 @@
+package takod
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	metricsHistoryDirName = "metrics-history"
+	// metricsHistoryInterval is how often node and container usage is
+	// sampled into the minute ring.
+	metricsHistoryInterval = time.Minute
+	// metricsHistoryStaleAfter skips node samples when the monitoring agent
+	// stopped refreshing current.json.
+	metricsHistoryStaleAfter = 3 * time.Minute
+	// MaxMetricsHistoryPoints caps the points per series in one response;
+	// longer ranges are averaged into wider steps.
+	MaxMetricsHistoryPoints = 1440
+
+	metricsRingMagic      = "TKMH"
+	metricsRingVersion    = 1
+	metricsRingHeaderSize = 16
+	metricsRingSlotSize   = 8 + 4*metricsHistoryFieldCount
+)
+
+// Field offsets within one ring slot. Counters (network and disk IO) are
+// stored as per-second rates so downsampling can average every field.
+const (
+	historyCPUPercent = iota
+	historyMemoryBytes
+	historyMemoryLimitBytes
+	historyNetRxRate
+	historyNetTxRate
+	historyDiskReadRate
+	historyDiskWriteRate
+	historyLoad1
+	historyReplicas
+	metricsHistoryFieldCount
+)
+
+// metricsHistoryTier is one ring: slots samples at resolution each. The
+// minute ring keeps seven days; the hour ring keeps ninety days of hourly
+// averages rolled up from it.
+type metricsHistoryTier struct {
+	name       string
+	resolution time.Duration
+	slots      int
+}
+
+var metricsHistoryTiers = []metricsHistoryTier{
+	{name: "1m", resolution: time.Minute, slots: 7 * 24 * 60},
+	{name: "1h", resolution: time.Hour, slots: 90 * 24},
+}
+
+const (
+	MetricsHistoryScopeNode    = "node"
+	MetricsHistoryScopeService = "service"
+)
+
+// MetricsHistoryRequest selects an environment's recorded usage between
+// Since and Until; a zero Until means now. Node usage is included unless
+// Service narrows the answer to one service.
+type MetricsHistoryRequest struct {
+	Project     string    `json:"project"`
+	Environment string    `json:"environment"`
+	Service     string    `json:"service,omitempty"`
+	Since       time.Time `json:"since"`
+	Until       time.Time `json:"until,omitempty"`
+}
+
+// MetricsHistoryPoint is the average usage over one step starting at Time.
+// Fields the step has no samples for are omitted. Service points sum every
+// replica on the node.
+type MetricsHistoryPoint struct {
+	Time                    time.Time `json:"time"`
+	CPUPercent              *float64  `json:"cpuPercent,omitempty"`
+	MemoryBytes             *float64  `json:"memoryBytes,omitempty"`
+	MemoryLimitBytes        *float64  `json:"memoryLimitBytes,omitempty"`
+	NetRxBytesPerSecond     *float64  `json:"netRxBytesPerSecond,omitempty"`
+	NetTxBytesPerSecond     *float64  `json:"netTxBytesPerSecond,omitempty"`
+	DiskReadBytesPerSecond  *float64  `json:"diskReadBytesPerSecond,omitempty"`
+	DiskWriteBytesPerSecond *float64  `json:"diskWriteBytesPerSecond,omitempty"`
+	Load1                   *float64  `json:"load1,omitempty"`
+	Replicas                *float64  `json:"replicas,omitempty"`
+}
+
+type MetricsHistorySeries struct {
+	Scope       string                `json:"scope"`
+	Project     string                `json:"project,omitempty"`
+	Environment string                `json:"environment,omitempty"`
+	Service     string                `json:"service,omitempty"`
+	Points      []MetricsHistoryPoint `json:"points"`
+}
+
+type MetricsHistoryResponse struct {
+	Since         time.Time              `json:"since"`
+	Until         time.Time              `json:"until"`
+	StepSeconds   int64                  `json:"stepSeconds"`
+	Series        []MetricsHistorySeries `json:"series"`
+	RetentionDays int                    `json:"retentionDays"`
+}
+
+// historyContainerStat is one running tako container's docker stats with
+// the identity its labels carry.
+type historyContainerStat struct {
+	Project     string
+	Environment string
+	Service     string
+	Stat        ContainerStat
+}
+
+// historyCounters are the cumulative counters behind a rate, remembered
+// from the previous sample.
+type historyCounters struct {
+	at                          time.Time
+	rx, tx, diskRead, diskWrite float64
+}
+
+// MetricsHistory samples node and per-service container usage once a minute
+// into fixed-size ring files under dataDir, so "what was memory doing before
+// the OOM" stays answerable without a monitoring stack.
+type MetricsHistory struct {
+	dataDir string
+
+	readNode       func(context.Context) (*MetricsResponse, error)
+	readContainers func(context.Context) ([]historyContainerStat, error)
+	now            func() time.Time
+	// observe receives every sample; the server hands them to the alert
+	// evaluator.
+	observe func(metricsObservation)
+
+	mu       sync.Mutex
+	previous map[string]historyCounters
+}
+
+func NewMetricsHistory(dataDir string) *MetricsHistory {
+	return &MetricsHistory{
+		dataDir: dataDir,
+		readNode: func(ctx context.Context) (*MetricsResponse, error) {
+			return ReadNodeMetrics(ctx, false)
+		},
+		readContainers: readHistoryContainers,
+		now:            time.Now,
+		previous:       make(map[string]historyCounters),
+	}
+}
+
+// Run samples until ctx ends. Failed samples are logged and retried on the
+// next tick; history is best effort and never blocks the node.
+func (h *MetricsHistory) Run(ctx context.Context) {
+	ticker := time.NewTicker(metricsHistoryInterval)
+	defer ticker.Stop()
+	for {
+		if err := h.Sample(ctx); err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "metrics history: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sample records one minute of node and container usage.
+func (h *MetricsHistory) Sample(ctx context.Context) error {
+	now := h.now().UTC()
+	observation := metricsObservation{at: now}
+	var errs []error
+	var err error
+	if observation.node, err = h.sampleNode(ctx, now); err != nil {
+		errs = append(errs, err)
+	}
+	if observation.services, err = h.sampleContainers(ctx, now); err != nil {
+		errs = append(errs, err)
+	}
+	if h.observe != nil {
+		h.observe(observation)
+	}
+	return errors.Join(errs...)
+}
+
+// sampleNode records the node's usage and returns it keyed by alert metric,
+// or nil when the monitor's sample is missing or stale.
+func (h *MetricsHistory) sampleNode(ctx context.Context, now time.Time) (map[string]float64, error) {
+	response, err := h.readNode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var node struct {
+		Timestamp string `json:"timestamp"`
+		nodeMetricsSnapshot
+	}
+	if err := json.Unmarshal(response.Metrics, &node); err != nil {
+		return nil, fmt.Errorf("failed to parse node metrics: %w", err)
+	}
+	at := now
+	if parsed, err := time.Parse(time.RFC3339, node.Timestamp); err == nil {
+		if now.Sub(parsed) > metricsHistoryStaleAfter {
+			return nil, nil
+		}
+		at = parsed
+	}
+	const mb = 1024 * 1024
+	values := emptyHistoryValues()
+	values[historyCPUPercent] = parseHistoryPercent(node.CPUPercent)
+	values[historyMemoryBytes] = float32(node.Memory.UsedMB * mb)
+	values[historyMemoryLimitBytes] = float32(node.Memory.TotalMB * mb)
+	values[historyLoad1] = parseHistoryPercent(node.LoadAverage.OneMin)
+
+	h.mu.Lock()
+	h.recordRates(&values, "node", historyCounters{
+		at:        at,
+		rx:        float64(node.Network.RxBytes),
+		tx:        float64(node.Network.TxBytes),
+		diskRead:  float64(node.DiskIO.ReadSectors * 512),
+		diskWrite: float64(node.DiskIO.WriteSectors * 512),
+	})
+	h.mu.Unlock()
+
+	observed := historyObservation(values)
+	if percent := parseHistoryPercent(node.Disk.Percent); !math.IsNaN(float64(percent)) {
+		observed[AlertMetricDiskPercent] = float64(percent)
+	}
+	observed[alertObservedDiskUsed] = float64(node.Disk.UsedMB * mb)
+	observed[alertObservedDiskTotal] = float64(node.Disk.TotalMB * mb)
+	return observed, h.write(h.nodeDir(), now, values)
+}
+
+// sampleContainers records each service's summed usage and returns it keyed
+// by alert metric, or nil when docker could not be read.
+func (h *MetricsHistory) sampleContainers(ctx context.Context, now time.Time) (map[proxyRouteKey]map[string]float64, error) {
+	containers, err := h.readContainers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	services := make(map[proxyRouteKey][metricsHistoryFieldCount]float32)
+	h.mu.Lock()
+	seen := make(map[string]bool, len(containers))
+	for _, container := range containers {
+		key := proxyRouteKey{project: container.Project, environment: container.Environment, service: container.Service}
+		values := services[key]
+		stat := container.Stat
+		values[historyCPUPercent] += zeroNaN(parseHistoryPercent(stat.CPUPercent))
+		if used, limit, ok := strings.Cut(stat.MemUsage, " / "); ok {
+			values[historyMemoryBytes] += float32(parseDockerSize(used))
+			values[historyMemoryLimitBytes] += float32(parseDockerSize(limit))
+		}
+		rates := emptyHistoryValues()
+		counters := historyCounters{at: now}
+		if rx, tx, ok := strings.Cut(stat.NetIO, " / "); ok {
+			counters.rx, counters.tx = float64(parseDockerSize(rx)), float64(parseDockerSize(tx))
+		}
+		if read, write, ok := strings.Cut(stat.BlockIO, " / "); ok {
+			counters.diskRead, counters.diskWrite = float64(parseDockerSize(read)), float64(parseDockerSize(write))
+		}
+		containerKey := "container/" + stat.Name
+		seen[containerKey] = true
+		h.recordRates(&rates, containerKey, counters)
+		for _, field := range []int{historyNetRxRate, historyNetTxRate, historyDiskReadRate, historyDiskWriteRate} {
+			values[field] += zeroNaN(rates[field])
+		}
+		values[historyReplicas]++
+		services[key] = values
+	}
+	for key := range h.previous {
+		if strings.HasPrefix(key, "container/") && !seen[key] {
+			delete(h.previous, key)
+		}
+	}
+	h.mu.Unlock()
+
+	observed := make(map[proxyRouteKey]map[string]float64, len(services))
+	var errs []error
+	for key, values := range services {
+		observed[key] = historyObservation(values)
+		if err := h.write(h.serviceDir(key.project, key.environment, key.service), now, values); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return observed, errors.Join(errs...)
+}
+
+// historyObservation keys one sample's fields by alert metric.
+func historyObservation(values [metricsHistoryFieldCount]float32) map[string]float64 {
+	observed := map[string]float64{}
+	set := func(key string, value float32) {
+		if !math.IsNaN(float64(value)) {
+			observed[key] = float64(value)
+		}
+	}
+	set(AlertMetricCPUPercent, values[historyCPUPercent])
+	set(AlertMetricMemoryBytes, values[historyMemoryBytes])
+	set(AlertMetricLoad1, values[historyLoad1])
+	set(alertObservedMemoryUsed, values[historyMemoryBytes])
+	set(alertObservedMemoryLimit, values[historyMemoryLimitBytes])
+	if limit := values[historyMemoryLimitBytes]; limit > 0 && !math.IsNaN(float64(values[historyMemoryBytes])) {
+		observed[AlertMetricMemoryPercent] = float64(values[historyMemoryBytes]) / float64(limit) * 100
+	}
+	return observed
+}
+
+// recordRates turns cumulative counters into per-second rates against the
+// previous sample under key. A counter that went backwards restarted, so
+// its current value is the amount since the restart. Callers hold h.mu.
+func (h *MetricsHistory) recordRates(values *[metricsHistoryFieldCount]float32, key string, current historyCounters) {
+	previous, ok := h.previous[key]
+	h.previous[key] = current
+	if !ok {
+		return
+	}
+	seconds := current.at.Sub(previous.at).Seconds()
+	if seconds <= 0 {
+		return
+	}
+	rate := func(now float64, before float64) float32 {
+		if now < before {
+			return float32(now / seconds)
+		}
+		return float32((now - before) / seconds)
+	}
+	values[historyNetRxRate] = rate(current.rx, previous.rx)
+	values[historyNetTxRate] = rate(current.tx, previous.tx)
+	values[historyDiskReadRate] = rate(current.diskRead, previous.diskRead)
+	values[historyDiskWriteRate] = rate(current.diskWrite, previous.diskWrite)
+}
+
+// write stores values in the minute ring and, on the first sample of an
+// hour, rolls the previous hour up into the hour ring.
+func (h *MetricsHistory) write(dir string, at time.Time, values [metricsHistoryFieldCount]float32) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create metrics history directory: %w", err)
+	}
+	minute := metricsRing{path: filepath.Join(dir, metricsHistoryTiers[0].name+".ring"), tier: metricsHistoryTiers[0]}
+	if err := minute.write(at, values); err != nil {
+		return err
+	}
+	hour := metricsRing{path: filepath.Join(dir, metricsHistoryTiers[1].name+".ring"), tier: metricsHistoryTiers[1]}
+	previousHour := at.Truncate(time.Hour).Add(-time.Hour)
+	if recorded, err := hour.has(previousHour); err != nil || recorded {
+		return err
+	}
+	samples, err := minute.read(previousHour, previousHour.Add(time.Hour-time.Second))
+	if err != nil || len(samples) == 0 {
+		return err
+	}
+	return hour.write(previousHour, averageHistorySamples(samples))
+}
+
+// RemoveProject drops an environment's recorded service history.
+func (h *MetricsHistory) RemoveProject(project string, environment string) error {
+	if !isSafeProjectName(project) || !isSafeRuntimeName(environment) {
+		return fmt.Errorf("invalid metrics history identity")
+	}
+	if err := os.RemoveAll(filepath.Join(h.dataDir, metricsHistoryDirName, "services", project, environment)); err != nil {
+		return fmt.Errorf("failed to remove metrics history: %w", err)
+	}
+	return nil
+}
+
+func validateMetricsHistoryRequest(req MetricsHistoryRequest) error {
+	if !isSafeProjectName(req.Project) {
+		return fmt.Errorf("invalid project name")
+	}
+	if !isSafeRuntimeName(req.Environment) {
+		return fmt.Errorf("invalid environment name")
+	}
+	if req.Service != "" && !isSafeServiceName(req.Service) {
+		return fmt.Errorf("invalid service name")
+	}
+	if req.Since.IsZero() {
+		return fmt.Errorf("since is required")
+	}
+	if !req.Until.IsZero() && !req.Since.Before(req.Until) {
+		return fmt.Errorf("since must be before until")
+	}
+	return nil
+}
+
+// Query answers req from the finest ring that still covers Since, averaging
+// into wider steps when the range would exceed MaxMetricsHistoryPoints.
+func (h *MetricsHistory) Query(req MetricsHistoryRequest) (*MetricsHistoryResponse, error) {
+	if err := validateMetricsHistoryRequest(req); err != nil {
+		return nil, err
+	}
+	now := h.now().UTC()
+	until := req.Until.UTC()
+	if until.IsZero() || until.After(now) {
+		until = now
+	}
+	tier := metricsHistoryTiers[len(metricsHistoryTiers)-1]
+	for _, candidate := range metricsHistoryTiers {
+		if now.Sub(req.Since) <= candidate.resolution*time.Duration(candidate.slots) {
+			tier = candidate
+			break
+		}
+	}
+	since := req.Since.UTC().Truncate(tier.resolution)
+	if oldest := now.Truncate(tier.resolution).Add(-tier.resolution * time.Duration(tier.slots-1)); since.Before(oldest) {
+		since = oldest
+	}
+	step := tier.resolution
+	if buckets := int64(until.Sub(since)/tier.resolution) + 1; buckets > MaxMetricsHistoryPoints {
+		step = tier.resolution * time.Duration((buckets+MaxMetricsHistoryPoints-1)/MaxMetricsHistoryPoints)
+	}
+	last := metricsHistoryTiers[len(metricsHistoryTiers)-1]
+	response := &MetricsHistoryResponse{
+		Since:         since,
+		Until:         until,
+		StepSeconds:   int64(step / time.Second),
+		Series:        []MetricsHistorySeries{},
+		RetentionDays: int(last.resolution * time.Duration(last.slots) / (24 * time.Hour)),
+	}
+
+	read := func(dir string) ([]MetricsHistoryPoint, error) {
+		ring := metricsRing{path: filepath.Join(dir, tier.name+".ring"), tier: tier}
+		samples, err := ring.read(since, until)
+		if err != nil {
+			return nil, err
+		}
+		return historyPoints(samples, since, step), nil
+	}
+	if req.Service == "" {
+		points, err := read(h.nodeDir())
+		if err != nil {
+			return nil, err
+		}
+		if len(points) > 0 {
+			response.Series = append(response.Series, MetricsHistorySeries{Scope: MetricsHistoryScopeNode, Points: points})
+		}
+	}
+	services, err := h.recordedServices(req.Project, req.Environment)
+	if err != nil {
+		return nil, err
+	}
+	for _, service := range services {
+		if req.Service != "" && service != req.Service {
+			continue
+		}
+		points, err := read(h.serviceDir(req.Project, req.Environment, service))
+		if err != nil {
+			return nil, err
+		}
+		if len(points) == 0 {
+			continue
+		}
+		response.Series = append(response.Series, MetricsHistorySeries{
+			Scope:       MetricsHistoryScopeService,
+			Project:     req.Project,
+			Environment: req.Environment,
+			Service:     service,
+			Points:      points,
+		})
+	}
+	return response, nil
+}
+
+func (h *MetricsHistory) recordedServices(project string, environment string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(h.dataDir, metricsHistoryDirName, "services", project, environment))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list metrics history: %w", err)
+	}
+	services := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() && isSafeServiceName(entry.Name()) {
+			services = append(services, entry.Name())
+		}
+	}
+	sort.Strings(services)
+	return services, nil
+}
+
+func (h *MetricsHistory) nodeDir() string {
+	return filepath.Join(h.dataDir, metricsHistoryDirName, "node")
+}
+
+func (h *MetricsHistory) serviceDir(project string, environment string, service string) string {
+	return filepath.Join(h.dataDir, metricsHistoryDirName, "services", project, environment, service)
+}
+
+// historyPoints averages samples into steps starting at since, dropping
+// steps without samples.
+func historyPoints(samples []metricsHistorySample, since time.Time, step time.Duration) []MetricsHistoryPoint {
+	buckets := make(map[int64][]metricsHistorySample)
+	var order []int64
+	for _, sample := range samples {
+		index := int64(sample.at.Sub(since) / step)
+		if _, ok := buckets[index]; !ok {
+			order = append(order, index)
+		}
+		buckets[index] = append(buckets[index], sample)
+	}
+	sort.Slice(order, func(i, j int) bool { return order[i] < order[j] })
+	points := make([]MetricsHistoryPoint, 0, len(order))
+	for _, index := range order {
+		values := averageHistorySamples(buckets[index])
+		field := func(i int) *float64 {
+			if math.IsNaN(float64(values[i])) {
+				return nil
+			}
+			value := float64(values[i])
+			return &value
+		}
+		points = append(points, MetricsHistoryPoint{
+			Time:                    since.Add(time.Duration(index) * step),
+			CPUPercent:              field(historyCPUPercent),
+			MemoryBytes:             field(historyMemoryBytes),
+			MemoryLimitBytes:        field(historyMemoryLimitBytes),
+			NetRxBytesPerSecond:     field(historyNetRxRate),
+			NetTxBytesPerSecond:     field(historyNetTxRate),
+			DiskReadBytesPerSecond:  field(historyDiskReadRate),
+			DiskWriteBytesPerSecond: field(historyDiskWriteRate),
+			Load1:                   field(historyLoad1),
+			Replicas:                field(historyReplicas),
+		})
+	}
+	return points
+}
+
+// averageHistorySamples averages each field over the samples that have it.
+func averageHistorySamples(samples []metricsHistorySample) [metricsHistoryFieldCount]float32 {
+	values := emptyHistoryValues()
+	for i := range values {
+		var sum float64
+		var count int
+		for _, sample := range samples {
+			if value := sample.values[i]; !math.IsNaN(float64(value)) {
+				sum += float64(value)
+				count++
+			}
+		}
+		if count > 0 {
+			values[i] = float32(sum / float64(count))
+		}
+	}
+	return values
+}
+
+func emptyHistoryValues() [metricsHistoryFieldCount]float32 {
+	var values [metricsHistoryFieldCount]float32
+	for i := range values {
+		values[i] = float32(math.NaN())
+	}
+	return values
+}
+
+func parseHistoryPercent(value string) float32 {
+	parsed, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+	if err != nil {
+		return float32(math.NaN())
+	}
+	return float32(parsed)
+}
+
+func zeroNaN(value float32) float32 {
+	if math.IsNaN(float64(value)) {
+		return 0
+	}
+	return value
+}
+
+// readHistoryContainers reads docker stats for every running container
+// that carries tako's project, environment, and service labels.
+func readHistoryContainers(ctx context.Context) ([]historyContainerStat, error) {
+	output, err := runDocker(ctx, "ps", "--filter", "label=tako.project", "--format", "{{.Names}}\t{{.Label \"tako.project\"}}\t{{.Label \"tako.environment\"}}\t{{.Label \"tako.service\"}}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	identities := make(map[string]historyContainerStat)
+	names := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if len(fields) != 4 || !isSafeProjectName(fields[1]) || !isSafeRuntimeName(fields[2]) || !isSafeServiceName(fields[3]) {
+			continue
+		}
+		identities[fields[0]] = historyContainerStat{Project: fields[1], Environment: fields[2], Service: fields[3]}
+		names = append(names, fields[0])
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	output, err = runDocker(ctx, append([]string{"stats", "--no-stream", "--format", "{{json .}}"}, names...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read container stats: %w", err)
+	}
+	stats, err := parseDockerStats(output)
+	if err != nil {
+		return nil, err
+	}
+	containers := make([]historyContainerStat, 0, len(stats))
+	for _, stat := range stats {
+		identity, ok := identities[stat.Name]
+		if !ok {
+			continue
+		}
+		identity.Stat = stat
+		containers = append(containers, identity)
+	}
+	return containers, nil
+}
+
+// metricsRing is one fixed-size ring file: a 16-byte header followed by
+// tier.slots slots of a unix timestamp and float32 fields, where the slot
+// for time t is (t / resolution) mod slots. NaN marks a missing field and a
+// zero timestamp an empty slot.
+type metricsRing struct {
+	path string
+	tier metricsHistoryTier
+}
+
+type metricsHistorySample struct {
+	at     time.Time
+	values [metricsHistoryFieldCount]float32
+}
+
+func (r metricsRing) size() int64 {
+	return metricsRingHeaderSize + int64(r.tier.slots)*metricsRingSlotSize
+}
+
+func (r metricsRing) header() []byte {
+	header := make([]byte, metricsRingHeaderSize)
+	copy(header, metricsRingMagic)
+	binary.LittleEndian.PutUint16(header[4:], metricsRingVersion)
+	binary.LittleEndian.PutUint16(header[6:], metricsHistoryFieldCount)
+	binary.LittleEndian.PutUint32(header[8:], uint32(r.tier.resolution/time.Second))
+	binary.LittleEndian.PutUint32(header[12:], uint32(r.tier.slots))
+	return header
+}
+
+func (r metricsRing) slotOffset(at time.Time) int64 {
+	index := (at.Unix() / int64(r.tier.resolution/time.Second)) % int64(r.tier.slots)
+	return metricsRingHeaderSize + index*metricsRingSlotSize
+}
+
+// open returns the ring file, resetting it when its layout does not match.
+func (r metricsRing) open() (*os.File, error) {
+	file, err := os.OpenFile(r.path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open metrics history: %w", err)
+	}
+	header := make([]byte, metricsRingHeaderSize)
+	info, statErr := file.Stat()
+	if statErr == nil && info.Size() == r.size() {
+		if _, err := file.ReadAt(header, 0); err == nil && string(header) == string(r.header()) {
+			return file, nil
+		}
+	}
+	if err := file.Truncate(0); err == nil {
+		err = file.Truncate(r.size())
+		if err == nil {
+			_, err = file.WriteAt(r.header(), 0)
+		}
+		if err == nil {
+			return file, nil
+		}
+	}
+	file.Close()
+	return nil, fmt.Errorf("failed to initialize metrics history %s", r.path)
+}
+
+func (r metricsRing) write(at time.Time, values [metricsHistoryFieldCount]float32) error {
+	file, err := r.open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	at = at.Truncate(r.tier.resolution)
+	slot := make([]byte, metricsRingSlotSize)
+	binary.LittleEndian.PutUint64(slot, uint64(at.Unix()))
+	for i, value := range values {
+		binary.LittleEndian.PutUint32(slot[8+4*i:], math.Float32bits(value))
+	}
+	if _, err := file.WriteAt(slot, r.slotOffset(at)); err != nil {
+		return fmt.Errorf("failed to write metrics history: %w", err)
+	}
+	return nil
+}
+
+// has reports whether the slot for at holds a sample from exactly at.
+func (r metricsRing) has(at time.Time) (bool, error) {
+	file, err := os.Open(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to open metrics history: %w", err)
+	}
+	defer file.Close()
+	stamp := make([]byte, 8)
+	if _, err := file.ReadAt(stamp, r.slotOffset(at)); err != nil {
+		return false, nil
+	}
+	return int64(binary.LittleEndian.Uint64(stamp)) == at.Truncate(r.tier.resolution).Unix(), nil
+}
+
+// read returns the samples in [since, until] in time order.
+func (r metricsRing) read(since time.Time, until time.Time) ([]metricsHistorySample, error) {
+	file, err := os.Open(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open metrics history: %w", err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metrics history: %w", err)
+	}
+	if int64(len(data)) != r.size() || string(data[:metricsRingHeaderSize]) != string(r.header()) {
+		return nil, nil
+	}
+	var samples []metricsHistorySample
+	for offset := metricsRingHeaderSize; offset+metricsRingSlotSize <= len(data); offset += metricsRingSlotSize {
+		stamp := int64(binary.LittleEndian.Uint64(data[offset:]))
+		if stamp == 0 {
+			continue
+		}
+		at := time.Unix(stamp, 0).UTC()
+		if at.Before(since) || at.After(until) {
+			continue
+		}
+		sample := metricsHistorySample{at: at}
+		for i := range sample.values {
+			sample.values[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[offset+8+4*i:]))
+		}
+		samples = append(samples, sample)
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].at.Before(samples[j].at) })
+	return samples, nil
+}
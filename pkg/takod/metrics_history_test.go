@@ -0,0 +1,167 @@
+package takod
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type fakeMetricsHistoryClock struct{ now time.Time }
+
+func newTestMetricsHistory(t *testing.T, clock *fakeMetricsHistoryClock) *MetricsHistory {
+	t.Helper()
+	history := NewMetricsHistory(t.TempDir())
+	history.now = func() time.Time { return clock.now }
+	rx := int64(0)
+	history.readNode = func(context.Context) (*MetricsResponse, error) {
+		rx += 6000
+		return &MetricsResponse{Metrics: json.RawMessage(`{"timestamp":"` + clock.now.Format(time.RFC3339) + `","cpu_percent":"40.0","memory":{"total_mb":2048,"used_mb":1024},"network":{"rx_bytes":` + strconv.FormatInt(rx, 10) + `},"load_average":{"1min":"0.50"}}`)}, nil
+	}
+	netIO := "0B / 0B"
+	history.readContainers = func(context.Context) ([]historyContainerStat, error) {
+		stats := []historyContainerStat{
+			{Project: "shop", Environment: "production", Service: "api", Stat: ContainerStat{Name: "shop_production_api_1", CPUPercent: "10.00%", MemUsage: "100MiB / 1GiB", NetIO: netIO, BlockIO: "0B / 0B"}},
+			{Project: "shop", Environment: "production", Service: "api", Stat: ContainerStat{Name: "shop_production_api_2", CPUPercent: "5.00%", MemUsage: "50MiB / 1GiB", NetIO: "0B / 0B", BlockIO: "0B / 0B"}},
+			{Project: "shop", Environment: "production", Service: "worker", Stat: ContainerStat{Name: "shop_production_worker_1", CPUPercent: "1.00%", MemUsage: "10MiB / 1GiB", NetIO: "0B / 0B", BlockIO: "0B / 0B"}},
+		}
+		netIO = "60kB / 0B"
+		return stats, nil
+	}
+	return history
+}
+
+func TestMetricsHistoryRecordsNodeAndServiceUsage(t *testing.T) {
+	clock := &fakeMetricsHistoryClock{now: time.Date(2026, 7, 6, 12, 0, 0, 0, time.UTC)}
+	history := newTestMetricsHistory(t, clock)
+	for i := 0; i < 2; i++ {
+		if err := history.Sample(context.Background()); err != nil {
+			t.Fatalf("Sample: %v", err)
+		}
+		clock.now = clock.now.Add(time.Minute)
+	}
+
+	response, err := history.Query(MetricsHistoryRequest{Project: "shop", Environment: "production", Since: clock.now.Add(-time.Hour)})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if response.StepSeconds != 60 || len(response.Series) != 3 {
+		t.Fatalf("response = %+v", response)
+	}
+	node := response.Series[0]
+	if node.Scope != MetricsHistoryScopeNode || len(node.Points) != 2 {
+		t.Fatalf("node series = %+v", node)
+	}
+	if node.Points[0].NetRxBytesPerSecond != nil {
+		t.Fatalf("first sample has no previous counters, got rate %v", *node.Points[0].NetRxBytesPerSecond)
+	}
+	latest := node.Points[1]
+	if *latest.CPUPercent != 40 || *latest.MemoryBytes != 1<<30 || *latest.Load1 != 0.5 || *latest.NetRxBytesPerSecond != 100 {
+		t.Fatalf("node point = %+v", latest)
+	}
+
+	api := response.Series[1]
+	if api.Scope != MetricsHistoryScopeService || api.Service != "api" || len(api.Points) != 2 {
+		t.Fatalf("api series = %+v", api)
+	}
+	point := api.Points[1]
+	if *point.CPUPercent != 15 || *point.MemoryBytes != 150<<20 || *point.Replicas != 2 || *point.NetRxBytesPerSecond != 1000 {
+		t.Fatalf("api point = %+v", point)
+	}
+
+	filtered, err := history.Query(MetricsHistoryRequest{Project: "shop", Environment: "production", Service: "worker", Since: clock.now.Add(-time.Hour)})
+	if err != nil {
+		t.Fatalf("Query service: %v", err)
+	}
+	if len(filtered.Series) != 1 || filtered.Series[0].Service != "worker" {
+		t.Fatalf("service filter = %+v", filtered.Series)
+	}
+
+	if err := history.RemoveProject("shop", "production"); err != nil {
+		t.Fatalf("RemoveProject: %v", err)
+	}
+	removed, err := history.Query(MetricsHistoryRequest{Project: "shop", Environment: "production", Since: clock.now.Add(-time.Hour)})
+	if err != nil {
+		t.Fatalf("Query after remove: %v", err)
+	}
+	if len(removed.Series) != 1 || removed.Series[0].Scope != MetricsHistoryScopeNode {
+		t.Fatalf("after remove = %+v", removed.Series)
+	}
+}
+
+func TestMetricsHistoryRollsUpHoursAndServesLongRangesFromThem(t *testing.T) {
+	clock := &fakeMetricsHistoryClock{now: time.Date(2026, 7, 6, 10, 58, 0, 0, time.UTC)}
+	history := newTestMetricsHistory(t, clock)
+	for i := 0; i < 3; i++ {
+		if err := history.Sample(context.Background()); err != nil {
+			t.Fatalf("Sample: %v", err)
+		}
+		clock.now = clock.now.Add(time.Minute)
+	}
+
+	response, err := history.Query(MetricsHistoryRequest{Project: "shop", Environment: "production", Service: "api", Since: clock.now.Add(-30 * 24 * time.Hour)})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if response.StepSeconds != 3600 || len(response.Series) != 1 {
+		t.Fatalf("response = %+v", response)
+	}
+	points := response.Series[0].Points
+	if len(points) != 1 || !points[0].Time.Equal(time.Date(2026, 7, 6, 10, 0, 0, 0, time.UTC)) || *points[0].CPUPercent != 15 {
+		t.Fatalf("hourly points = %+v", points)
+	}
+}
+
+func TestMetricsHistoryWidensStepsForLongMinuteRanges(t *testing.T) {
+	clock := &fakeMetricsHistoryClock{now: time.Date(2026, 7, 6, 12, 0, 0, 0, time.UTC)}
+	history := newTestMetricsHistory(t, clock)
+	if err := history.Sample(context.Background()); err != nil {
+		t.Fatalf("Sample: %v", err)
+	}
+	response, err := history.Query(MetricsHistoryRequest{Project: "shop", Environment: "production", Since: clock.now.Add(-7 * 24 * time.Hour)})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if response.StepSeconds != 7*60 {
+		t.Fatalf("step = %ds, want 420s", response.StepSeconds)
+	}
+}
+
+func TestMetricsRingResetsMismatchedFiles(t *testing.T) {
+	ring := metricsRing{path: filepath.Join(t.TempDir(), "1m.ring"), tier: metricsHistoryTiers[0]}
+	if err := os.WriteFile(ring.path, []byte("not a ring"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 7, 6, 12, 0, 30, 0, time.UTC)
+	values := emptyHistoryValues()
+	values[historyCPUPercent] = 12.5
+	if err := ring.write(at, values); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	info, err := os.Stat(ring.path)
+	if err != nil || info.Size() != ring.size() {
+		t.Fatalf("ring size = %v, %v", info, err)
+	}
+	samples, err := ring.read(at.Add(-time.Hour), at)
+	if err != nil || len(samples) != 1 || samples[0].values[historyCPUPercent] != 12.5 || !samples[0].at.Equal(at.Truncate(time.Minute)) {
+		t.Fatalf("samples = %+v, %v", samples, err)
+	}
+}
+
+func TestValidateMetricsHistoryRequestRejectsUnsafeScopes(t *testing.T) {
+	since := time.Now().Add(-time.Hour)
+	for name, req := range map[string]MetricsHistoryRequest{
+		"project":     {Project: "../shop", Environment: "production", Since: since},
+		"environment": {Project: "shop", Environment: "prod/us", Since: since},
+		"service":     {Project: "shop", Environment: "production", Service: "api;rm", Since: since},
+		"since":       {Project: "shop", Environment: "production"},
+		"range":       {Project: "shop", Environment: "production", Since: since, Until: since.Add(-time.Minute)},
+	} {
+		if err := validateMetricsHistoryRequest(req); err == nil {
+			t.Fatalf("%s: request accepted", name)
+		}
+	}
+}
@@ -8,19 +8,40 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+
+	"github.com/redentordev/tako-cli/pkg/telemetry"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
 	defaultProxyImage = "caddy:2.9-alpine"
 	defaultProxyEmail = "tako@redentor.dev"
+	// rateLimitProxyImage is defaultProxyImage rebuilt with the
+	// caddy-ratelimit module. Stock Caddy has no rate_limit directive, so
+	// takod builds this image on the node the first time a route needs it.
+	// The tag carries the pinned module version, so bumping it rebuilds the
+	// image instead of reusing one built from another release.
+	rateLimitProxyImage = "tako-proxy:2.9-ratelimit-" + rateLimitModuleVersion
+	// rateLimitModuleVersion pins caddy-ratelimit so every node builds the
+	// same proxy.
+	rateLimitModuleVersion = "v0.1.0"
 )
 
+const rateLimitProxyDockerfile = `FROM caddy:2.9-builder-alpine AS builder
+RUN xcaddy build --with github.com/mholt/caddy-ratelimit@` + rateLimitModuleVersion + `
+FROM caddy:2.9-alpine
+COPY --from=builder /usr/bin/caddy /usr/bin/caddy
+`
+
 type ReconcileProxyRequest struct {
 	Project     string `json:"project,omitempty"`
 	Environment string `json:"environment,omitempty"`
 	Network     string `json:"network"`
 	Email       string `json:"email,omitempty"`
 	Image       string `json:"image,omitempty"`
+	// RateLimit asks for a proxy that can render rate_limit before the
+	// route manifest that needs it is written.
+	RateLimit bool `json:"rateLimit,omitempty"`
 }
 
 type ReconcileProxyResponse struct {
@@ -43,6 +64,20 @@ func ReconcileProxy(ctx context.Context, req ReconcileProxyRequest) (*ReconcileP
 	if err != nil {
 		return nil, err
 	}
+	if req.Image == defaultProxyImage {
+		rateLimited := req.RateLimit
+		if !rateLimited {
+			if rateLimited, err = proxyRoutesUseRateLimit(proxyRoutesDir); err != nil {
+				return nil, err
+			}
+		}
+		if rateLimited {
+			if err := ensureRateLimitProxyImage(ctx); err != nil {
+				return nil, err
+			}
+			req.Image = rateLimitProxyImage
+		}
+	}
 
 	running, _ := runDocker(ctx, "ps", "--filter", "name=^tako-proxy$", "--format", "{{.Names}}")
 	if strings.TrimSpace(running) == "tako-proxy" {
@@ -63,7 +98,10 @@ func ReconcileProxy(ctx context.Context, req ReconcileProxyRequest) (*ReconcileP
 		return nil, err
 	}
 	_, _ = runDocker(ctx, "rm", "-f", "tako-proxy")
-	if output, err := runDocker(ctx, buildProxyContainerArgs(req)...); err != nil {
+	startCtx, span := startTakodSpan(ctx, "proxy.start", attribute.String("image", req.Image))
+	output, err := runDocker(startCtx, buildProxyContainerArgs(req)...)
+	telemetry.EndSpan(span, err)
+	if err != nil {
 		return nil, fmt.Errorf("failed to start tako-proxy: %w, output: %s", err, output)
 	}
 	if err := ensureProxyNetworkAttachments(ctx, networks); err != nil {
@@ -97,6 +135,41 @@ func validateReconcileProxyRequest(req ReconcileProxyRequest) error {
 	return nil
 }
 
+// proxyRoutesUseRateLimit reports whether any route on the node throttles
+// clients, which keeps the proxy on rateLimitProxyImage.
+func proxyRoutesUseRateLimit(dir string) (bool, error) {
+	manifests, err := readProxyRouteManifests(dir)
+	if err != nil {
+		return false, err
+	}
+	for _, manifest := range manifests {
+		for _, route := range manifest.Routes {
+			if route.RateLimit != nil {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func ensureRateLimitProxyImage(ctx context.Context) error {
+	if _, err := runDocker(ctx, "image", "inspect", rateLimitProxyImage); err == nil {
+		return nil
+	}
+	dir, err := os.MkdirTemp("", "tako-proxy-build-")
+	if err != nil {
+		return fmt.Errorf("failed to stage rate limit proxy build: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte(rateLimitProxyDockerfile), 0644); err != nil {
+		return fmt.Errorf("failed to stage rate limit proxy build: %w", err)
+	}
+	if output, err := runDocker(ctx, "build", "-t", rateLimitProxyImage, dir); err != nil {
+		return fmt.Errorf("failed to build rate limit proxy image %s: %w, output: %s", rateLimitProxyImage, err, output)
+	}
+	return nil
+}
+
 func isSafeProxyEmail(value string) bool {
 	if len(value) == 0 || len(value) > 254 || strings.TrimSpace(value) != value {
 		return false
@@ -0,0 +1,346 @@
+package takod
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// proxyAccessLogUpstreamField is the access log field revision routes append
+// with the upstream host:port that served each request.
+const proxyAccessLogUpstreamField = "tako_upstream"
+
+const (
+	// maxProxyAnalysisLineBytes bounds a single access log line during
+	// analysis.
+	maxProxyAnalysisLineBytes = 1 << 20
+
+	// proxyAnalysisSeekStep is how close the binary search over the access
+	// log gets to the start of the window before reading forward.
+	proxyAnalysisSeekStep = 64 << 10
+
+	// proxyAnalysisSeekSlack widens that search for entries logged out of
+	// order; Caddy stamps each entry when its request finishes.
+	proxyAnalysisSeekSlack = time.Minute
+
+	// latencyBucketGrowth is the ratio between consecutive latency bucket
+	// bounds, so a percentile is reported within 5% of the true value.
+	latencyBucketGrowth = 1.05
+)
+
+// ProxyTrafficAnalysisRequest selects the access log window to analyze for
+// one proxied service.
+type ProxyTrafficAnalysisRequest struct {
+	Project     string    `json:"project"`
+	Environment string    `json:"environment"`
+	Service     string    `json:"service"`
+	Since       time.Time `json:"since"`
+}
+
+// ProxyTrafficAnalysis reports per-revision traffic observed by this node's
+// proxy since the requested time.
+type ProxyTrafficAnalysis struct {
+	Service   string                 `json:"service"`
+	Since     time.Time              `json:"since"`
+	Revisions []RevisionTrafficStats `json:"revisions"`
+}
+
+// RevisionTrafficStats summarizes the requests one revision served. Latency
+// percentiles are in milliseconds; ErrorRate is the 5xx share in percent.
+// Latency is the histogram the percentiles were read from, which lets
+// MergeRevisionTrafficStats combine the stats of several proxies.
+type RevisionTrafficStats struct {
+	Revision  string          `json:"revision"`
+	Requests  int             `json:"requests"`
+	Errors    int             `json:"errors"`
+	ErrorRate float64         `json:"errorRate"`
+	P50Ms     float64         `json:"p50Ms"`
+	P95Ms     float64         `json:"p95Ms"`
+	P99Ms     float64         `json:"p99Ms"`
+	Latency   []LatencyBucket `json:"latency,omitempty"`
+}
+
+// LatencyBucket counts the requests whose latency fell into one histogram
+// bucket and keeps the slowest of them. Bucket n holds latencies above
+// 1.05^(n-1) and up to 1.05^n milliseconds; bucket 0 holds everything up to
+// 1ms.
+type LatencyBucket struct {
+	Bucket int     `json:"bucket"`
+	Count  int     `json:"count"`
+	MaxMs  float64 `json:"maxMs"`
+}
+
+type proxyAccessLogEntry struct {
+	Logger   string  `json:"logger"`
+	TS       float64 `json:"ts"`
+	Status   int     `json:"status"`
+	Duration float64 `json:"duration"`
+	Upstream string  `json:"tako_upstream"`
+}
+
+// AnalyzeProxyTraffic attributes proxy access log entries to revisions using
+// the published route manifest and computes error rate and latency
+// percentiles for each revision the route currently serves. It reads only the
+// part of the log written since the requested time and keeps a fixed-size
+// latency histogram per revision rather than every sample.
+func AnalyzeProxyTraffic(req ProxyTrafficAnalysisRequest) (*ProxyTrafficAnalysis, error) {
+	if err := validateProxyTrafficAnalysisRequest(req); err != nil {
+		return nil, err
+	}
+	route, err := findProxyRoute(req.Project, req.Environment, req.Service)
+	if err != nil {
+		return nil, err
+	}
+	revisionByUpstream := proxyRouteUpstreamRevisions(*route)
+
+	latencies := make(map[string]map[int]LatencyBucket)
+	failures := make(map[string]int)
+	file, err := os.Open(proxyAccessLogPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to open proxy access log: %w", err)
+	}
+	if err == nil {
+		defer file.Close()
+		logger := "http.log.access." + caddyAccessLogName(req.Service)
+		since := float64(req.Since.UnixNano()) / float64(time.Second)
+		offset, err := seekProxyAccessLog(file, since-proxyAnalysisSeekSlack.Seconds())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read proxy access log: %w", err)
+		}
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to read proxy access log: %w", err)
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), maxProxyAnalysisLineBytes)
+		if offset > 0 {
+			// The search lands inside a line; the next one starts the window.
+			scanner.Scan()
+		}
+		for scanner.Scan() {
+			var entry proxyAccessLogEntry
+			if json.Unmarshal(scanner.Bytes(), &entry) != nil {
+				continue
+			}
+			if entry.Logger != logger || entry.TS < since {
+				continue
+			}
+			revision, ok := revisionByUpstream[entry.Upstream]
+			if !ok {
+				continue
+			}
+			if latencies[revision] == nil {
+				latencies[revision] = make(map[int]LatencyBucket)
+			}
+			addLatencyBucket(latencies[revision], LatencyBucket{Bucket: latencyBucketIndex(entry.Duration * 1000), Count: 1, MaxMs: entry.Duration * 1000})
+			if entry.Status >= 500 {
+				failures[revision]++
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read proxy access log: %w", err)
+		}
+	}
+
+	analysis := &ProxyTrafficAnalysis{Service: req.Service, Since: req.Since}
+	for _, revision := range proxyRouteRevisions(*route) {
+		analysis.Revisions = append(analysis.Revisions, revisionTrafficStats(revision, latencies[revision], failures[revision]))
+	}
+	return analysis, nil
+}
+
+// seekProxyAccessLog returns an offset at or before the line holding the
+// first entry logged at since. Caddy appends entries in time order, so a
+// binary search over the file skips the history before the window instead of
+// reading it.
+func seekProxyAccessLog(file *os.File, since float64) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	low, high := int64(0), info.Size()
+	for high-low > proxyAnalysisSeekStep {
+		middle := low + (high-low)/2
+		ts, ok, err := proxyAccessLogTimeAfter(file, middle, high)
+		if err != nil {
+			return 0, err
+		}
+		if ok && ts < since {
+			low = middle
+		} else {
+			high = middle
+		}
+	}
+	return low, nil
+}
+
+// proxyAccessLogTimeAfter returns the timestamp of the first whole entry that
+// starts after offset and before limit.
+func proxyAccessLogTimeAfter(file *os.File, offset int64, limit int64) (float64, bool, error) {
+	scanner := bufio.NewScanner(io.NewSectionReader(file, offset, limit-offset))
+	scanner.Buffer(make([]byte, 64*1024), maxProxyAnalysisLineBytes)
+	scanner.Scan()
+	for scanner.Scan() {
+		var entry proxyAccessLogEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil && entry.TS > 0 {
+			return entry.TS, true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, bufio.ErrTooLong) {
+		return 0, false, err
+	}
+	return 0, false, nil
+}
+
+func validateProxyTrafficAnalysisRequest(req ProxyTrafficAnalysisRequest) error {
+	if !isSafeRuntimeName(req.Project) || !isSafeRuntimeName(req.Environment) {
+		return fmt.Errorf("project and environment are required")
+	}
+	if !isSafeRuntimeName(req.Service) {
+		return fmt.Errorf("invalid service")
+	}
+	if req.Since.IsZero() {
+		return fmt.Errorf("since is required")
+	}
+	return nil
+}
+
+func findProxyRoute(project, environment, service string) (*ProxyRoute, error) {
+	manifests, err := readProxyRouteManifests(proxyRoutesDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, manifest := range manifests {
+		if manifest.Project != project || manifest.Environment != environment {
+			continue
+		}
+		for _, route := range manifest.Routes {
+			if route.Service == service {
+				return &route, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no published proxy route for service %s", service)
+}
+
+func proxyRouteRevisions(route ProxyRoute) []string {
+	var revisions []string
+	if route.Revision != "" {
+		revisions = append(revisions, route.Revision)
+	}
+	if route.CanaryRevision != "" {
+		revisions = append(revisions, route.CanaryRevision)
+	}
+	return revisions
+}
+
+// proxyRouteUpstreamRevisions maps upstream host:port values, as Caddy logs
+// them, to the revision each upstream belongs to.
+func proxyRouteUpstreamRevisions(route ProxyRoute) map[string]string {
+	revisions := make(map[string]string, len(route.Upstreams))
+	if route.Revision == "" {
+		return revisions
+	}
+	canaryStart := len(route.Upstreams) - route.CanaryUpstreams
+	for i, upstream := range route.Upstreams {
+		parsed, err := url.Parse(upstream)
+		if err != nil || parsed.Host == "" {
+			continue
+		}
+		revision := route.Revision
+		if route.CanaryRevision != "" && i >= canaryStart {
+			revision = route.CanaryRevision
+		}
+		revisions[parsed.Host] = revision
+	}
+	return revisions
+}
+
+// MergeRevisionTrafficStats combines one revision's stats from two proxies by
+// summing their latency histograms and reading the percentiles again.
+func MergeRevisionTrafficStats(a RevisionTrafficStats, b RevisionTrafficStats) RevisionTrafficStats {
+	buckets := make(map[int]LatencyBucket, len(a.Latency)+len(b.Latency))
+	for _, bucket := range a.Latency {
+		addLatencyBucket(buckets, bucket)
+	}
+	for _, bucket := range b.Latency {
+		addLatencyBucket(buckets, bucket)
+	}
+	return revisionTrafficStats(a.Revision, buckets, a.Errors+b.Errors)
+}
+
+func revisionTrafficStats(revision string, buckets map[int]LatencyBucket, errors int) RevisionTrafficStats {
+	stats := RevisionTrafficStats{Revision: revision, Errors: errors}
+	for _, bucket := range buckets {
+		stats.Latency = append(stats.Latency, bucket)
+		stats.Requests += bucket.Count
+	}
+	if stats.Requests == 0 {
+		return stats
+	}
+	sort.Slice(stats.Latency, func(i, j int) bool { return stats.Latency[i].Bucket < stats.Latency[j].Bucket })
+	stats.ErrorRate = roundAnalysisValue(float64(errors) * 100 / float64(stats.Requests))
+	stats.P50Ms = roundAnalysisValue(latencyPercentile(stats.Latency, stats.Requests, 50))
+	stats.P95Ms = roundAnalysisValue(latencyPercentile(stats.Latency, stats.Requests, 95))
+	stats.P99Ms = roundAnalysisValue(latencyPercentile(stats.Latency, stats.Requests, 99))
+	return stats
+}
+
+func addLatencyBucket(buckets map[int]LatencyBucket, bucket LatencyBucket) {
+	current := buckets[bucket.Bucket]
+	current.Bucket = bucket.Bucket
+	current.Count += bucket.Count
+	current.MaxMs = math.Max(current.MaxMs, bucket.MaxMs)
+	buckets[bucket.Bucket] = current
+}
+
+func latencyBucketIndex(latencyMs float64) int {
+	if latencyMs <= 1 {
+		return 0
+	}
+	return int(math.Ceil(math.Log(latencyMs) / math.Log(latencyBucketGrowth)))
+}
+
+// latencyPercentile returns the nearest-rank percentile of a sorted histogram
+// as the slowest latency in the bucket holding that rank. That is never below
+// the true percentile and at most one bucket above it.
+func latencyPercentile(buckets []LatencyBucket, requests int, percentile float64) float64 {
+	rank := int(math.Ceil(percentile / 100 * float64(requests)))
+	seen := 0
+	for _, bucket := range buckets {
+		seen += bucket.Count
+		if seen >= rank {
+			return bucket.MaxMs
+		}
+	}
+	return buckets[len(buckets)-1].MaxMs
+}
+
+func roundAnalysisValue(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// decodeProxyTrafficAnalysisQuery reads an analysis request from URL query
+// parameters; since is an RFC3339 timestamp.
+func decodeProxyTrafficAnalysisQuery(query url.Values) (ProxyTrafficAnalysisRequest, error) {
+	req := ProxyTrafficAnalysisRequest{
+		Project:     strings.TrimSpace(query.Get("project")),
+		Environment: strings.TrimSpace(query.Get("environment")),
+		Service:     strings.TrimSpace(query.Get("service")),
+	}
+	if raw := strings.TrimSpace(query.Get("since")); raw != "" {
+		since, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return req, fmt.Errorf("since must be an RFC3339 timestamp")
+		}
+		req.Since = since
+	}
+	return req, nil
+}
@@ -0,0 +1,149 @@
+package takod
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnalyzeProxyTrafficAttributesRequestsToRevisions(t *testing.T) {
+	useTempProxyPaths(t)
+	manifest := `{"version": 1, "project": "demo", "environment": "production", "routes": [{"service": "web", "revision": "rev-stable", "canaryRevision": "rev-canary", "canaryPercent": 25, "canaryUpstreams": 1, "domains": ["example.com"], "upstreams": ["http://demo-web-stable:3000", "http://demo-web-canary:3000"], "weights": [3, 1]}]}`
+	if err := os.MkdirAll(proxyRoutesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(proxyRoutesDir, "demo-production.json"), []byte(manifest), 0600); err != nil {
+		t.Fatal(err)
+	}
+	since := time.Unix(1700000000, 0).UTC()
+	lines := []string{
+		`{"logger":"http.log.access.tako_web","ts":1699999999.0,"status":500,"duration":9,"tako_upstream":"demo-web-canary:3000"}`,
+		`{"logger":"http.log.access.tako_web","ts":1700000001.0,"status":200,"duration":0.010,"tako_upstream":"demo-web-stable:3000"}`,
+		`{"logger":"http.log.access.tako_web","ts":1700000002.0,"status":200,"duration":0.020,"tako_upstream":"demo-web-stable:3000"}`,
+		`{"logger":"http.log.access.tako_web","ts":1700000003.0,"status":502,"duration":0.300,"tako_upstream":"demo-web-canary:3000"}`,
+		`{"logger":"http.log.access.tako_web","ts":1700000004.0,"status":200,"duration":0.100,"tako_upstream":"demo-web-canary:3000"}`,
+		`{"logger":"http.log.access.tako_api","ts":1700000005.0,"status":500,"duration":1,"tako_upstream":"demo-web-canary:3000"}`,
+		`{"logger":"http.log.access.tako_web","ts":1700000006.0,"status":500,"duration":1,"tako_upstream":"unknown:3000"}`,
+		`not json`,
+	}
+	restore := useTempAccessLog(t, strings.Join(lines, "\n")+"\n")
+	defer restore()
+
+	analysis, err := AnalyzeProxyTraffic(ProxyTrafficAnalysisRequest{Project: "demo", Environment: "production", Service: "web", Since: since})
+	if err != nil {
+		t.Fatalf("AnalyzeProxyTraffic returned error: %v", err)
+	}
+	if len(analysis.Revisions) != 2 {
+		t.Fatalf("revisions = %#v, want stable and canary", analysis.Revisions)
+	}
+	stable, canary := analysis.Revisions[0], analysis.Revisions[1]
+	if stable.Revision != "rev-stable" || stable.Requests != 2 || stable.Errors != 0 || stable.ErrorRate != 0 || stable.P50Ms != 10 || stable.P99Ms != 20 {
+		t.Fatalf("stable stats = %#v", stable)
+	}
+	if canary.Revision != "rev-canary" || canary.Requests != 2 || canary.Errors != 1 || canary.ErrorRate != 50 || canary.P50Ms != 100 || canary.P95Ms != 300 {
+		t.Fatalf("canary stats = %#v", canary)
+	}
+}
+
+func TestAnalyzeProxyTrafficRequiresPublishedRoute(t *testing.T) {
+	useTempProxyPaths(t)
+	_, err := AnalyzeProxyTraffic(ProxyTrafficAnalysisRequest{Project: "demo", Environment: "production", Service: "web", Since: time.Now()})
+	if err == nil || !strings.Contains(err.Error(), "no published proxy route") {
+		t.Fatalf("expected missing route error, got %v", err)
+	}
+}
+
+func TestDecodeProxyTrafficAnalysisQueryValidatesSince(t *testing.T) {
+	if _, err := decodeProxyTrafficAnalysisQuery(url.Values{"since": {"yesterday"}}); err == nil {
+		t.Fatal("expected invalid since to be rejected")
+	}
+	req, err := decodeProxyTrafficAnalysisQuery(url.Values{"project": {"demo"}, "environment": {"production"}, "service": {"web"}, "since": {"2026-01-02T03:04:05Z"}})
+	if err != nil {
+		t.Fatalf("decodeProxyTrafficAnalysisQuery returned error: %v", err)
+	}
+	if err := validateProxyTrafficAnalysisRequest(req); err != nil {
+		t.Fatalf("decoded request should validate: %v", err)
+	}
+}
+
+func TestAnalyzeProxyTrafficSeeksPastHistoryBeforeTheWindow(t *testing.T) {
+	useTempProxyPaths(t)
+	manifest := `{"version": 1, "project": "demo", "environment": "production", "routes": [{"service": "web", "revision": "rev-stable", "domains": ["example.com"], "upstreams": ["http://demo-web-stable:3000"], "weights": [1]}]}`
+	if err := os.MkdirAll(proxyRoutesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(proxyRoutesDir, "demo-production.json"), []byte(manifest), 0600); err != nil {
+		t.Fatal(err)
+	}
+	since := time.Unix(1700000000, 0).UTC()
+	var log strings.Builder
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&log, `{"logger":"http.log.access.tako_web","ts":%.1f,"status":500,"duration":9,"tako_upstream":"demo-web-stable:3000"}`+"\n", float64(since.Unix()-3600)+float64(i)*0.1)
+	}
+	windowStart := log.Len()
+	// Caddy stamps entries when requests finish, so the first request in the
+	// window can be logged after one that finished just before it.
+	log.WriteString(`{"logger":"http.log.access.tako_web","ts":1700000001.0,"status":200,"duration":0.010,"tako_upstream":"demo-web-stable:3000"}` + "\n")
+	log.WriteString(`{"logger":"http.log.access.tako_web","ts":1699999999.0,"status":500,"duration":9,"tako_upstream":"demo-web-stable:3000"}` + "\n")
+	log.WriteString(`{"logger":"http.log.access.tako_web","ts":1700000002.0,"status":200,"duration":0.030,"tako_upstream":"demo-web-stable:3000"}` + "\n")
+	restore := useTempAccessLog(t, log.String())
+	defer restore()
+
+	file, err := os.Open(proxyAccessLogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	offset, err := seekProxyAccessLog(file, float64(since.Unix())-proxyAnalysisSeekSlack.Seconds())
+	if err != nil {
+		t.Fatalf("seekProxyAccessLog returned error: %v", err)
+	}
+	if offset == 0 || offset > int64(windowStart) || int64(windowStart)-offset > 2*proxyAnalysisSeekStep {
+		t.Fatalf("seek offset = %d, want just before the window at %d", offset, windowStart)
+	}
+
+	analysis, err := AnalyzeProxyTraffic(ProxyTrafficAnalysisRequest{Project: "demo", Environment: "production", Service: "web", Since: since})
+	if err != nil {
+		t.Fatalf("AnalyzeProxyTraffic returned error: %v", err)
+	}
+	stable := analysis.Revisions[0]
+	if stable.Requests != 2 || stable.Errors != 0 || stable.P50Ms != 10 || stable.P99Ms != 30 {
+		t.Fatalf("stable stats = %#v", stable)
+	}
+}
+
+func TestRevisionTrafficStatsReadPercentilesFromTheHistogram(t *testing.T) {
+	buckets := make(map[int]LatencyBucket)
+	for i := 1; i <= 1000; i++ {
+		latencyMs := float64(i)
+		addLatencyBucket(buckets, LatencyBucket{Bucket: latencyBucketIndex(latencyMs), Count: 1, MaxMs: latencyMs})
+	}
+	stats := revisionTrafficStats("rev", buckets, 10)
+	if stats.Requests != 1000 || stats.ErrorRate != 1 || len(stats.Latency) > 150 {
+		t.Fatalf("stats = %d requests, %.2f%% errors, %d buckets", stats.Requests, stats.ErrorRate, len(stats.Latency))
+	}
+	for _, check := range []struct {
+		name     string
+		observed float64
+		exact    float64
+	}{
+		{name: "p50", observed: stats.P50Ms, exact: 500},
+		{name: "p95", observed: stats.P95Ms, exact: 950},
+		{name: "p99", observed: stats.P99Ms, exact: 990},
+	} {
+		if check.observed < check.exact || check.observed > check.exact*latencyBucketGrowth {
+			t.Fatalf("%s = %.2fms, want within one bucket above %.0fms", check.name, check.observed, check.exact)
+		}
+	}
+
+	fast := revisionTrafficStats("rev", map[int]LatencyBucket{latencyBucketIndex(10): {Bucket: latencyBucketIndex(10), Count: 30, MaxMs: 10}}, 0)
+	slow := revisionTrafficStats("rev", map[int]LatencyBucket{latencyBucketIndex(300): {Bucket: latencyBucketIndex(300), Count: 10, MaxMs: 300}}, 5)
+	merged := MergeRevisionTrafficStats(fast, slow)
+	if merged.Requests != 40 || merged.Errors != 5 || merged.ErrorRate != 12.5 || merged.P50Ms != 10 || merged.P95Ms != 300 {
+		t.Fatalf("merged stats = %#v", merged)
+	}
+}
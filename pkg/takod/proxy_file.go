@@ -7,6 +7,8 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+
+	"github.com/redentordev/tako-cli/pkg/telemetry"
 )
 
 var proxyDynamicDir = "/etc/tako/proxy/dynamic"
@@ -21,6 +23,28 @@ type ProxyFileRequest struct {
 
 type ProxyFileResponse struct {
 	Path string `json:"path"`
+	// Content is returned by reads only; it is empty when no route manifest
+	// has been published under the requested name.
+	Content string `json:"content,omitempty"`
+}
+
+// ReadProxyFile returns the currently published route manifest so
+// controllers can carry forward node-held route state such as canary weights.
+func ReadProxyFile(name string) (*ProxyFileResponse, error) {
+	name, err := validateProxyFileName(name)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(proxyRoutesDir, name)
+	data, exists, err := readFileIfExists(path)
+	if err != nil {
+		return nil, err
+	}
+	response := &ProxyFileResponse{Path: path}
+	if exists {
+		response.Content = string(data)
+	}
+	return response, nil
 }
 
 func WriteProxyFile(ctx context.Context, req ProxyFileRequest) (*ProxyFileResponse, error) {
@@ -137,7 +161,11 @@ func renderAndWriteCaddyfileThen(ctx context.Context, excludedCertificateDomain
 	return nil
 }
 
-func renderAndWriteCaddyfileLocked(ctx context.Context, excludedCertificateDomain string) error {
+// renderAndWriteCaddyfileLocked publishes the Caddyfile, which the running
+// proxy reloads on its own once the file changes.
+func renderAndWriteCaddyfileLocked(ctx context.Context, excludedCertificateDomain string) (err error) {
+	ctx, span := startTakodSpan(ctx, "proxy.reload")
+	defer func() { telemetry.EndSpan(span, err) }()
 	caddyfile, err := renderCaddyfileFromRouteManifestsExcluding(proxyRoutesDir, excludedCertificateDomain)
 	if err != nil {
 		return err
@@ -595,3 +595,128 @@ func TestRenderCaddyfileSetsDynamicHealthHostHeader(t *testing.T) {
 		t.Fatalf("health Host header count = %d, want 2:\n%s", got, caddyfile)
 	}
 }
+
+func TestRenderCaddyfileWeightsCanaryUpstreams(t *testing.T) {
+	caddyfile, err := renderCaddyfile([]ProxyRouteManifest{
+		{
+			Version:     1,
+			Project:     "demo",
+			Environment: "production",
+			Routes: []ProxyRoute{
+				{
+					Service:         "web",
+					Revision:        "rev-stable",
+					CanaryRevision:  "rev-canary",
+					CanaryPercent:   25,
+					CanaryUpstreams: 1,
+					Domains:         []string{"example.com"},
+					Upstreams:       []string{"http://demo-web-stable:3000", "http://demo-web-canary:3000"},
+					Weights:         []int{3, 1},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("renderCaddyfile returned error: %v", err)
+	}
+	if !strings.Contains(caddyfile, "reverse_proxy http://demo-web-stable:3000 http://demo-web-canary:3000 {") {
+		t.Fatalf("canary route should proxy both revisions:\n%s", caddyfile)
+	}
+	if !strings.Contains(caddyfile, "lb_policy weighted_round_robin 3 1") {
+		t.Fatalf("canary route should weight upstreams:\n%s", caddyfile)
+	}
+	if !strings.Contains(caddyfile, "log_append tako_upstream {http.reverse_proxy.upstream.hostport}") {
+		t.Fatalf("revision route should log the serving upstream:\n%s", caddyfile)
+	}
+}
+
+func TestParseProxyRouteManifestRejectsInvalidCanaryWeights(t *testing.T) {
+	tests := []struct {
+		name  string
+		route string
+		want  string
+	}{
+		{
+			name:  "weights length",
+			route: `"canaryRevision": "rev-canary", "canaryPercent": 5, "canaryUpstreams": 1, "weights": [1]`,
+			want:  "upstream weights must match upstreams",
+		},
+		{
+			name:  "missing weights",
+			route: `"canaryRevision": "rev-canary", "canaryPercent": 5`,
+			want:  "canary revision requires upstream weights",
+		},
+		{
+			name:  "same revision",
+			route: `"canaryRevision": "rev-stable", "canaryPercent": 5, "canaryUpstreams": 1, "weights": [19, 1]`,
+			want:  "canary revision must differ",
+		},
+		{
+			name:  "percent out of range",
+			route: `"canaryRevision": "rev-canary", "canaryPercent": 100, "canaryUpstreams": 1, "weights": [1, 1]`,
+			want:  "canary percent must be between 1 and 99",
+		},
+		{
+			name:  "canary upstream count",
+			route: `"canaryRevision": "rev-canary", "canaryPercent": 5, "canaryUpstreams": 2, "weights": [19, 1]`,
+			want:  "canary upstreams must leave",
+		},
+		{
+			name:  "zero weight",
+			route: `"weights": [0, 1]`,
+			want:  "upstream weights must be between",
+		},
+		{
+			name:  "sticky",
+			route: `"sticky": true, "weights": [1, 1]`,
+			want:  "weighted upstreams cannot be sticky",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseProxyRouteManifest(`{
+				"version": 1,
+				"project": "demo",
+				"environment": "production",
+				"routes": [
+					{
+						"service": "web",
+						"revision": "rev-stable",
+						"domains": ["example.com"],
+						"upstreams": ["http://demo-web-stable:3000", "http://demo-web-canary:3000"],
+						` + tt.route + `
+					}
+				]
+			}`)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadProxyFileReturnsPublishedManifest(t *testing.T) {
+	useTempProxyPaths(t)
+	response, err := ReadProxyFile("demo-production.json")
+	if err != nil {
+		t.Fatalf("ReadProxyFile returned error: %v", err)
+	}
+	if response.Content != "" {
+		t.Fatalf("missing manifest content = %q, want empty", response.Content)
+	}
+
+	content := `{"version": 1, "project": "demo", "environment": "production", "routes": [{"service": "web", "domains": ["example.com"], "upstreams": ["http://demo-web:3000"]}]}`
+	if _, err := WriteProxyFile(context.Background(), ProxyFileRequest{Name: "demo-production.json", Content: content}); err != nil {
+		t.Fatalf("WriteProxyFile returned error: %v", err)
+	}
+	response, err = ReadProxyFile("demo-production.json")
+	if err != nil {
+		t.Fatalf("ReadProxyFile returned error: %v", err)
+	}
+	if response.Content != content {
+		t.Fatalf("content = %q, want published manifest", response.Content)
+	}
+	if _, err := ReadProxyFile("../demo-production.json"); err == nil {
+		t.Fatal("expected unsafe proxy file name to be rejected")
+	}
+}
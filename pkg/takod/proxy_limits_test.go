@@ -0,0 +1,113 @@
+package takod
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/redentordev/tako-cli/pkg/runtimeid"
+)
+
+func TestRenderCaddyfileEmitsRateLimitAndBodySize(t *testing.T) {
+	caddyfile, err := renderCaddyfile(accessControlManifest(ProxyRoute{
+		RateLimit:    &ProxyRouteRateLimit{Requests: 5, Window: "1m0s", Paths: []string{"/login", "/api/auth/*"}},
+		MaxBodyBytes: 10 << 20,
+		BasicAuth:    &ProxyRouteBasicAuth{Username: "admin", PasswordBcrypt: takodTestBcryptHash},
+	}))
+	if err != nil {
+		t.Fatalf("renderCaddyfile returned error: %v", err)
+	}
+	if !strings.Contains(caddyfile, "\torder rate_limit before basic_auth\n") {
+		t.Fatalf("missing rate_limit directive order:\n%s", caddyfile)
+	}
+	for _, want := range []string{
+		"\t\t\tmatch {\n\t\t\t\tpath /login /api/auth/*\n\t\t\t}\n",
+		"\t\t\tkey {remote_host}\n\t\t\tevents 5\n\t\t\twindow 1m0s\n",
+		"\trequest_body {\n\t\tmax_size 10485760\n\t}\n",
+	} {
+		if !strings.Contains(caddyfile, want) {
+			t.Fatalf("missing %q:\n%s", want, caddyfile)
+		}
+	}
+	if !strings.Contains(caddyfile, "\trate_limit {\n\t\tzone tako_web_") {
+		t.Fatalf("missing route rate limit zone:\n%s", caddyfile)
+	}
+
+	caddyfile, err = renderCaddyfile(accessControlManifest(ProxyRoute{
+		RateLimit:      &ProxyRouteRateLimit{Requests: 100, Window: "1h0m0s", Header: "X-Api-Key"},
+		TrustedProxies: []string{"203.0.113.0/24"},
+	}))
+	if err != nil {
+		t.Fatalf("renderCaddyfile returned error: %v", err)
+	}
+	if !strings.Contains(caddyfile, "\t\t\tkey {http.request.header.X-Api-Key}\n") || strings.Contains(caddyfile, "match {") {
+		t.Fatalf("header-keyed limit not rendered:\n%s", caddyfile)
+	}
+}
+
+func TestRenderCaddyfileWithoutLimitsOmitsRateLimitOrder(t *testing.T) {
+	caddyfile, err := renderCaddyfile(accessControlManifest(ProxyRoute{}))
+	if err != nil {
+		t.Fatalf("renderCaddyfile returned error: %v", err)
+	}
+	if strings.Contains(caddyfile, "rate_limit") || strings.Contains(caddyfile, "request_body") {
+		t.Fatalf("stock Caddy cannot load rate_limit; limits rendered without a route asking:\n%s", caddyfile)
+	}
+}
+
+func TestValidateProxyRouteManifestRejectsUnsafeLimits(t *testing.T) {
+	cases := []struct {
+		name    string
+		route   ProxyRoute
+		wantErr string
+	}{
+		{"zero requests", ProxyRoute{RateLimit: &ProxyRouteRateLimit{Window: "1m"}}, "requests must be between"},
+		{"bad window", ProxyRoute{RateLimit: &ProxyRouteRateLimit{Requests: 1, Window: "1m\n}"}}, "invalid rate limit window"},
+		{"short window", ProxyRoute{RateLimit: &ProxyRouteRateLimit{Requests: 1, Window: "10ms"}}, "invalid rate limit window"},
+		{"header injection", ProxyRoute{RateLimit: &ProxyRouteRateLimit{Requests: 1, Window: "1m", Header: "X-Key}"}}, "invalid rate limit header"},
+		{"bad path", ProxyRoute{RateLimit: &ProxyRouteRateLimit{Requests: 1, Window: "1m", Paths: []string{"login"}}}, "invalid rate limit path"},
+		{"paths with stripPrefix", ProxyRoute{Paths: []string{"/api/*"}, StripPrefix: true, RateLimit: &ProxyRouteRateLimit{Requests: 1, Window: "1m", Paths: []string{"/api/login"}}}, "cannot be combined with stripPrefix"},
+		{"negative body", ProxyRoute{MaxBodyBytes: -1}, "maxBodyBytes"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			manifest := accessControlManifest(tc.route)[0]
+			err := validateProxyRouteManifest(&manifest)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("error = %v, want substring %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestReconcileProxyUsesRateLimitImageWhenRequested(t *testing.T) {
+	useTempProxyPaths(t)
+	logPath := filepath.Join(t.TempDir(), "commands.log")
+	restore := useFakeCommands(t, logPath)
+	defer restore()
+
+	network := runtimeid.NetworkName("demo", "production")
+	response, err := ReconcileProxy(context.Background(), ReconcileProxyRequest{Network: network, RateLimit: true})
+	if err != nil {
+		t.Fatalf("ReconcileProxy returned error: %v", err)
+	}
+	if response.Image != rateLimitProxyImage {
+		t.Fatalf("image = %q, want %q", response.Image, rateLimitProxyImage)
+	}
+	commands := strings.Join(readCommandLog(t, logPath), "\n")
+	if !strings.Contains(commands, "docker image inspect "+rateLimitProxyImage) || !strings.Contains(commands, " "+rateLimitProxyImage+" caddy run") {
+		t.Fatalf("proxy not started from the rate limit image:\n%s", commands)
+	}
+
+	response, err = ReconcileProxy(context.Background(), ReconcileProxyRequest{Network: network})
+	if err != nil {
+		t.Fatalf("ReconcileProxy returned error: %v", err)
+	}
+	if response.Image != defaultProxyImage {
+		t.Fatalf("image without rate limited routes = %q, want %q", response.Image, defaultProxyImage)
+	}
+	if !strings.Contains(rateLimitProxyDockerfile, "--with github.com/mholt/caddy-ratelimit@"+rateLimitModuleVersion+"\n") || !strings.HasSuffix(rateLimitProxyImage, "-"+rateLimitModuleVersion) {
+		t.Fatalf("rate limit module not pinned in the build and image tag:\n%s%s", rateLimitProxyDockerfile, rateLimitProxyImage)
+	}
+}
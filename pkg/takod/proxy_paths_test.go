@@ -0,0 +1,161 @@
+package takod
+
+import (
+	"strings"
+	"testing"
+)
+
+func pathRoutesManifest(routes ...ProxyRoute) []ProxyRouteManifest {
+	return []ProxyRouteManifest{{
+		Version:     1,
+		Project:     "demo",
+		Environment: "production",
+		Routes:      routes,
+	}}
+}
+
+func TestRenderCaddyfileSharesDomainAcrossPathRoutes(t *testing.T) {
+	caddyfile, err := renderCaddyfile(pathRoutesManifest(
+		ProxyRoute{Service: "web", Domains: []string{"example.com"}, Upstreams: []string{"http://demo-web:3000"}},
+		ProxyRoute{Service: "api", Domains: []string{"example.com"}, Upstreams: []string{"http://demo-api:3000"}, Paths: []string{"/api/*"}, StripPrefix: true},
+		ProxyRoute{Service: "admin", Domains: []string{"example.com"}, Upstreams: []string{"http://demo-admin:3000"}, Paths: []string{"/api/admin/*"}, AllowIPs: []string{"10.0.0.0/8"}},
+	))
+	if err != nil {
+		t.Fatalf("renderCaddyfile returned error: %v", err)
+	}
+	if count := strings.Count(caddyfile, "\nexample.com {\n"); count != 1 {
+		t.Fatalf("site written %d times:\n%s", count, caddyfile)
+	}
+	site := caddyfile[strings.Index(caddyfile, "\nexample.com {\n"):]
+	for _, logger := range []string{"tako_web", "tako_api", "tako_admin"} {
+		if !strings.Contains(site, "\tlog "+logger+" {") {
+			t.Fatalf("missing %s logger:\n%s", logger, site)
+		}
+	}
+	admin := strings.Index(site, "\thandle /api/admin/* {\n\t\tlog_name tako_admin\n")
+	api := strings.Index(site, "\thandle_path /api/* {\n\t\tlog_name tako_api\n\t\treverse_proxy http://demo-api:3000")
+	fallback := strings.Index(site, "\thandle {\n\t\tlog_name tako_web\n\t\treverse_proxy http://demo-web:3000")
+	if admin < 0 || api < 0 || fallback < 0 || !(admin < api && api < fallback) {
+		t.Fatalf("path handlers missing or out of order (admin=%d api=%d fallback=%d):\n%s", admin, api, fallback, site)
+	}
+	matcher := site[strings.Index(site, "\t@tako_allowed_")+1:]
+	matcher = matcher[:strings.Index(matcher, " ")]
+	if !strings.Contains(site, "\t"+matcher+" remote_ip 10.0.0.0/8\n") || !strings.Contains(site[admin:api], "\t\thandle "+matcher+" {\n") {
+		t.Fatalf("admin allowlist not scoped to its path:\n%s", site)
+	}
+}
+
+func TestRenderCaddyfilePathRoutesWithoutFallbackRespond404(t *testing.T) {
+	caddyfile, err := renderCaddyfile(pathRoutesManifest(
+		ProxyRoute{Service: "api", Domains: []string{"example.com"}, Upstreams: []string{"http://demo-api:3000"}, Paths: []string{"/api/*"}},
+	))
+	if err != nil {
+		t.Fatalf("renderCaddyfile returned error: %v", err)
+	}
+	if !strings.Contains(caddyfile, "\thandle /api/* {\n") || !strings.Contains(caddyfile, "\thandle {\n\t\trespond 404\n\t}\n") {
+		t.Fatalf("missing path handler or 404 fallback:\n%s", caddyfile)
+	}
+}
+
+func TestResolveProxyRouteClaimsSettlesPathConflicts(t *testing.T) {
+	web := ProxyRoute{Service: "web", Domains: []string{"example.com"}, Upstreams: []string{"http://demo-web:3000"}}
+	api := ProxyRoute{Service: "api", Domains: []string{"example.com", "api.example.com"}, Upstreams: []string{"http://demo-api:3000"}, Paths: []string{"/api/*", "/v1/*"}}
+
+	routes, err := resolveProxyRouteClaims([]ProxyRoute{web, api})
+	if err != nil {
+		t.Fatalf("disjoint paths rejected: %v", err)
+	}
+	if len(routes) != 3 {
+		t.Fatalf("routes = %+v, want web plus one api route per domain", routes)
+	}
+
+	cases := []struct {
+		name    string
+		routes  []ProxyRoute
+		wantErr string
+	}{
+		{"same path", []ProxyRoute{api, {Service: "v2", Domains: []string{"example.com"}, Paths: []string{"/v1/*"}}}, `proxy path "example.com/v1/*" is configured by both api and v2`},
+		{"two fallbacks", []ProxyRoute{web, {Service: "other", Domains: []string{"example.com"}}}, "configured by both web and other"},
+		{"mixed visibility", []ProxyRoute{web, {Service: "ops", Domains: []string{"example.com"}, Paths: []string{"/ops/*"}, Visibility: proxyRouteVisibilityInternal}}, "need the same visibility"},
+		{"redirect host", []ProxyRoute{{Service: "web", Domains: []string{"example.com"}, RedirectFrom: []string{"www.example.com"}}, {Service: "api", Domains: []string{"www.example.com"}, Paths: []string{"/api/*"}}}, "redirects for web"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := resolveProxyRouteClaims(tc.routes)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("error = %v, want substring %q", err, tc.wantErr)
+			}
+		})
+	}
+
+	// A higher priority route takes over a single path and leaves the rest.
+	override := ProxyRoute{Service: "preview", Priority: 10, Domains: []string{"example.com"}, Paths: []string{"/v1/*"}}
+	routes, err = resolveProxyRouteClaims([]ProxyRoute{api, override})
+	if err != nil {
+		t.Fatalf("priority override rejected: %v", err)
+	}
+	paths := map[string]string{}
+	for _, route := range routes {
+		for _, domain := range route.Domains {
+			paths[route.Service+" "+domain] = strings.Join(route.Paths, ",")
+		}
+	}
+	if paths["api example.com"] != "/api/*" || paths["api api.example.com"] != "/api/*,/v1/*" || paths["preview example.com"] != "/v1/*" {
+		t.Fatalf("paths after override = %v", paths)
+	}
+}
+
+func TestRenderCaddyfileRejectsPathClaimsFromAnotherEnvironment(t *testing.T) {
+	shop := ProxyRouteManifest{Version: 1, Project: "shop", Environment: "production", Routes: []ProxyRoute{
+		{Service: "web", Domains: []string{"shop.example.com"}, Upstreams: []string{"http://shop-web:3000"}},
+	}}
+	for name, route := range map[string]ProxyRoute{
+		"path":     {Service: "login", Domains: []string{"shop.example.com"}, Upstreams: []string{"http://other-login:3000"}, Paths: []string{"/login"}},
+		"priority": {Service: "login", Domains: []string{"shop.example.com"}, Upstreams: []string{"http://other-login:3000"}, Paths: []string{"/login"}, Priority: 100},
+		"redirect": {Service: "app", Domains: []string{"other.example.com"}, RedirectFrom: []string{"shop.example.com"}, Upstreams: []string{"http://other-app:3000"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for _, environment := range []string{"production", "staging"} {
+				project := "other"
+				if environment == "staging" {
+					project = "shop"
+				}
+				other := ProxyRouteManifest{Version: 1, Project: project, Environment: environment, Routes: []ProxyRoute{route}}
+				_, err := renderCaddyfile([]ProxyRouteManifest{shop, other})
+				want := `proxy domain "shop.example.com" is routed by both shop/production and ` + project + "/" + environment
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Fatalf("error = %v, want substring %q", err, want)
+				}
+			}
+		})
+	}
+
+	shop.Routes = append(shop.Routes, ProxyRoute{Service: "login", Domains: []string{"shop.example.com"}, Upstreams: []string{"http://shop-login:3000"}, Paths: []string{"/login"}})
+	if _, err := renderCaddyfile([]ProxyRouteManifest{shop}); err != nil {
+		t.Fatalf("same environment path route rejected: %v", err)
+	}
+}
+
+func TestValidateProxyRouteManifestRejectsUnsafePaths(t *testing.T) {
+	cases := []struct {
+		name    string
+		route   ProxyRoute
+		wantErr string
+	}{
+		{"relative", ProxyRoute{Paths: []string{"api/*"}}, "invalid proxy path"},
+		{"inner wildcard", ProxyRoute{Paths: []string{"/api/*/users"}}, "invalid proxy path"},
+		{"injection", ProxyRoute{Paths: []string{"/api {\nrespond 200"}}, "invalid proxy path"},
+		{"catch-all", ProxyRoute{Paths: []string{"/*"}}, "invalid proxy path"},
+		{"duplicate", ProxyRoute{Paths: []string{"/api/*", "/api/*"}}, "duplicate proxy path"},
+		{"strip without paths", ProxyRoute{StripPrefix: true}, "stripPrefix requires paths"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			manifest := accessControlManifest(tc.route)[0]
+			err := validateProxyRouteManifest(&manifest)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("error = %v, want substring %q", err, tc.wantErr)
+			}
+		})
+	}
+}
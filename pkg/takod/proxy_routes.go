@@ -1,6 +1,8 @@
 package takod
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net"
@@ -10,6 +12,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -86,6 +89,26 @@ type ProxyRoute struct {
 	AllowIPs       []string             `json:"allowIps,omitempty"`
 	TrustedProxies []string             `json:"trustedProxies,omitempty"`
 	Destinations   []ProxyDestination   `json:"destinations,omitempty"`
+	// Paths limits the route to these request paths on its domains, so
+	// several routes can share one domain; a trailing * matches a prefix.
+	// A route without Paths serves every path no other route claims.
+	Paths []string `json:"paths,omitempty"`
+	// StripPrefix removes the matched path prefix before proxying.
+	StripPrefix bool `json:"stripPrefix,omitempty"`
+	// RateLimit throttles clients of the route; requests over the limit
+	// receive 429.
+	RateLimit *ProxyRouteRateLimit `json:"rateLimit,omitempty"`
+	// MaxBodyBytes rejects larger request bodies with 413.
+	MaxBodyBytes int64 `json:"maxBodyBytes,omitempty"`
+	// CanaryRevision names a second revision that receives a weighted share
+	// of traffic beside Revision while a canary deploy ramps up. Weights
+	// then carries one relative weight per upstream, in upstream order, and
+	// CanaryPercent records the intended split so controllers can resume it.
+	// The last CanaryUpstreams upstreams belong to CanaryRevision.
+	CanaryRevision  string `json:"canaryRevision,omitempty"`
+	CanaryPercent   int    `json:"canaryPercent,omitempty"`
+	CanaryUpstreams int    `json:"canaryUpstreams,omitempty"`
+	Weights         []int  `json:"weights,omitempty"`
 }
 
 // ProxyRouteBasicAuth protects a route's serving domains with HTTP basic
@@ -95,6 +118,16 @@ type ProxyRouteBasicAuth struct {
 	PasswordBcrypt string `json:"passwordBcrypt"`
 }
 
+// ProxyRouteRateLimit allows Requests per Window for each client, keyed by
+// client IP or, when Header is set, by that request header. Paths limits
+// throttling to matching requests.
+type ProxyRouteRateLimit struct {
+	Requests int      `json:"requests"`
+	Window   string   `json:"window"`
+	Header   string   `json:"header,omitempty"`
+	Paths    []string `json:"paths,omitempty"`
+}
+
 type ProxyRouteHealth struct {
 	Path     string `json:"path,omitempty"`
 	Interval string `json:"interval,omitempty"`
@@ -256,6 +289,15 @@ func validateProxyRouteManifest(manifest *ProxyRouteManifest) error {
 				return fmt.Errorf("route %s: invalid upstream %q: %w", route.Service, upstream, err)
 			}
 		}
+		if err := validateProxyRouteWeights(*route); err != nil {
+			return fmt.Errorf("route %s: %w", route.Service, err)
+		}
+		if err := validateProxyRoutePaths(*route); err != nil {
+			return fmt.Errorf("route %s: %w", route.Service, err)
+		}
+		if err := validateProxyRouteLimits(*route); err != nil {
+			return fmt.Errorf("route %s: %w", route.Service, err)
+		}
 		if manifest.Version >= 2 {
 			if len(route.Destinations) != len(route.Upstreams) {
 				return fmt.Errorf("route %s: every upstream requires destination identity proof", route.Service)
@@ -321,7 +363,7 @@ func validateProxyDestination(manifest ProxyRouteManifest, route ProxyRoute, pro
 	if proof.Revision != "" && !isSafeRuntimeName(proof.Revision) {
 		return fmt.Errorf("destination revision is invalid")
 	}
-	if !allowPath && (proof.Service != route.Service || proof.Revision != route.Revision) {
+	if !allowPath && (proof.Service != route.Service || (proof.Revision != route.Revision && (route.CanaryRevision == "" || proof.Revision != route.CanaryRevision))) {
 		return fmt.Errorf("destination service/revision does not match its route")
 	}
 	port := parsed.Port()
@@ -377,6 +419,137 @@ func validateProxyDestination(manifest ProxyRouteManifest, route ProxyRoute, pro
 	return nil
 }
 
+const maxProxyRouteWeight = 10000
+
+// validateProxyRouteWeights checks the weighted canary split: weights align
+// with upstreams, and a canary revision is only meaningful with weights.
+func validateProxyRouteWeights(route ProxyRoute) error {
+	if route.CanaryRevision != "" {
+		if !isSafeRuntimeName(route.CanaryRevision) {
+			return fmt.Errorf("invalid canary revision")
+		}
+		if route.CanaryRevision == route.Revision {
+			return fmt.Errorf("canary revision must differ from the active revision")
+		}
+		if len(route.Weights) == 0 {
+			return fmt.Errorf("canary revision requires upstream weights")
+		}
+		if route.CanaryPercent < 1 || route.CanaryPercent > 99 {
+			return fmt.Errorf("canary percent must be between 1 and 99")
+		}
+		if route.CanaryUpstreams < 1 || route.CanaryUpstreams >= len(route.Upstreams) {
+			return fmt.Errorf("canary upstreams must leave at least one upstream for each revision")
+		}
+	} else if route.CanaryPercent != 0 || route.CanaryUpstreams != 0 {
+		return fmt.Errorf("canary percent requires a canary revision")
+	}
+	if len(route.Weights) == 0 {
+		return nil
+	}
+	if len(route.Weights) != len(route.Upstreams) {
+		return fmt.Errorf("upstream weights must match upstreams")
+	}
+	if route.Sticky {
+		return fmt.Errorf("weighted upstreams cannot be sticky")
+	}
+	for _, weight := range route.Weights {
+		if weight < 1 || weight > maxProxyRouteWeight {
+			return fmt.Errorf("upstream weights must be between 1 and %d", maxProxyRouteWeight)
+		}
+	}
+	return nil
+}
+
+const maxProxyRoutePaths = 16
+
+// validateProxyRoutePaths checks path matchers: each is an absolute path,
+// optionally ending in * to match a prefix. The catch-all is expressed by
+// omitting Paths, and on-demand TLS routes serve whole hosts.
+func validateProxyRoutePaths(route ProxyRoute) error {
+	if len(route.Paths) == 0 {
+		if route.StripPrefix {
+			return fmt.Errorf("stripPrefix requires paths")
+		}
+		return nil
+	}
+	if route.DynamicDomain != nil {
+		return fmt.Errorf("dynamic domain routes cannot be limited to paths")
+	}
+	if len(route.Paths) > maxProxyRoutePaths {
+		return fmt.Errorf("at most %d paths are allowed", maxProxyRoutePaths)
+	}
+	seen := make(map[string]bool, len(route.Paths))
+	for _, path := range route.Paths {
+		if !isSafeProxyPath(path) {
+			return fmt.Errorf("invalid proxy path %q", path)
+		}
+		if seen[path] {
+			return fmt.Errorf("duplicate proxy path %q", path)
+		}
+		seen[path] = true
+	}
+	return nil
+}
+
+const (
+	maxProxyRateLimitRequests = 1000000
+	maxProxyRateLimitWindow   = 24 * time.Hour
+	maxProxyBodyBytes         = 10 << 30
+)
+
+var proxyHeaderNamePattern = regexp.MustCompile(`^[A-Za-z0-9-]{1,64}$`)
+
+func validateProxyRouteLimits(route ProxyRoute) error {
+	if route.MaxBodyBytes < 0 || route.MaxBodyBytes > maxProxyBodyBytes {
+		return fmt.Errorf("maxBodyBytes must be between 0 and %d", int64(maxProxyBodyBytes))
+	}
+	limit := route.RateLimit
+	if limit == nil {
+		return nil
+	}
+	if limit.Requests < 1 || limit.Requests > maxProxyRateLimitRequests {
+		return fmt.Errorf("rate limit requests must be between 1 and %d", maxProxyRateLimitRequests)
+	}
+	window, err := time.ParseDuration(limit.Window)
+	if err != nil || window < time.Second || window > maxProxyRateLimitWindow {
+		return fmt.Errorf("invalid rate limit window %q", limit.Window)
+	}
+	if limit.Header != "" && !proxyHeaderNamePattern.MatchString(limit.Header) {
+		return fmt.Errorf("invalid rate limit header %q", limit.Header)
+	}
+	if len(limit.Paths) > maxProxyRoutePaths {
+		return fmt.Errorf("at most %d rate limit paths are allowed", maxProxyRoutePaths)
+	}
+	// handle_path strips the prefix before rate_limit runs, so limit paths
+	// would be matched against the stripped path and never fire.
+	if len(limit.Paths) > 0 && route.StripPrefix {
+		return fmt.Errorf("rate limit paths cannot be combined with stripPrefix")
+	}
+	for _, path := range limit.Paths {
+		if !isSafeProxyPath(path) {
+			return fmt.Errorf("invalid rate limit path %q", path)
+		}
+	}
+	return nil
+}
+
+func isSafeProxyPath(value string) bool {
+	if len(value) < 2 || len(value) > 256 || value[0] != '/' || value == "/*" {
+		return false
+	}
+	for i, r := range value {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') ||
+			r == '/' || r == '-' || r == '.' || r == '_' || r == '~' || r == '%' {
+			continue
+		}
+		if r == '*' && i == len(value)-1 {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
 func safeProxyMeshAddress(address netip.Addr) bool {
 	if !address.IsValid() || address.IsUnspecified() || address.IsLoopback() || address.IsLinkLocalUnicast() || address.IsLinkLocalMulticast() || address.IsMulticast() {
 		return false
@@ -449,6 +622,9 @@ func renderCaddyfileWithCertificates(manifests []ProxyRouteManifest, certificate
 }
 
 func renderCaddyfileWithCertificatesAndOwners(manifests []ProxyRouteManifest, certificates []proxyCertificateEntry, owners []acmeDNSOwnerClaim) (string, error) {
+	if err := checkProxyHostOwners(manifests); err != nil {
+		return "", err
+	}
 	var routes []ProxyRoute
 	for _, manifest := range manifests {
 		routes = append(routes, manifest.Routes...)
@@ -493,6 +669,11 @@ func renderCaddyfileWithCertificatesAndOwners(manifests []ProxyRouteManifest, ce
 		b.WriteString("\t\ttrusted_proxies_strict\n")
 		b.WriteString("\t}\n")
 	}
+	if proxyRoutesRateLimited(effectiveRoutes) {
+		// rate_limit comes from a plugin and has no default position in
+		// Caddy's directive order; throttle before authenticating.
+		b.WriteString("\torder rate_limit before basic_auth\n")
+	}
 	if dynamicRoute != nil {
 		b.WriteString("\ton_demand_tls {\n")
 		b.WriteString("\t\task " + dynamicRoute.DynamicDomain.AskURL + "\n")
@@ -503,8 +684,14 @@ func renderCaddyfileWithCertificatesAndOwners(manifests []ProxyRouteManifest, ce
 	writeCaddyAccessLog(&b, "tako_proxy")
 	b.WriteString("\tredir https://{host}{uri} 308\n}\n")
 
+	siteRoutes := proxyRoutesByHost(effectiveRoutes)
+	writtenSites := make(map[string]bool)
 	for _, route := range effectiveRoutes {
 		for _, domain := range route.Domains {
+			if writtenSites[domain] {
+				continue
+			}
+			writtenSites[domain] = true
 			var certificate *proxyCertificateEntry
 			if route.Visibility != proxyRouteVisibilityInternal {
 				certificate = selectProxyCertificate(certificates, domain)
@@ -514,7 +701,7 @@ func renderCaddyfileWithCertificatesAndOwners(manifests []ProxyRouteManifest, ce
 					return "", fmt.Errorf("domain %s is covered by ACME DNS certificate %s owned by %s/%s, but no valid certificate is stored; deploy the owning configuration first", domain, owner.Domain, owner.Project, owner.Environment)
 				}
 			}
-			writeCaddyRoute(&b, caddyRouteAddress(domain, route), route, certificate)
+			writeCaddyRoute(&b, caddyRouteAddress(domain, route), siteRoutes[domain], certificate)
 		}
 		primary := ""
 		if len(route.Domains) > 0 {
@@ -537,7 +724,7 @@ func renderCaddyfileWithCertificatesAndOwners(manifests []ProxyRouteManifest, ce
 	}
 
 	if dynamicRoute != nil {
-		writeCaddyRoute(&b, ":443", *dynamicRoute, nil)
+		writeCaddyRoute(&b, ":443", []ProxyRoute{*dynamicRoute}, nil)
 	}
 	return b.String(), nil
 }
@@ -568,15 +755,42 @@ type proxyRouteHostClaim struct {
 	service    string
 }
 
+// resolveProxyRouteClaims settles which route serves each host and path.
+// A route without paths claims its whole hosts; a route with paths claims
+// host/path pairs and gets one effective route per host, so a higher
+// priority route can take over single paths. Claims at equal priority
+// conflict.
 func resolveProxyRouteClaims(routes []ProxyRoute) ([]ProxyRoute, error) {
 	effective := make([]ProxyRoute, 0, len(routes))
 	claims := make(map[string]proxyRouteHostClaim)
 
 	for _, route := range routes {
-		routeIndex := len(effective)
 		copyRoute := route
 		copyRoute.Domains = nil
 		copyRoute.RedirectFrom = nil
+		copyRoute.Paths = nil
+		if len(route.Paths) > 0 {
+			for i, domain := range route.Domains {
+				routeIndex := len(effective)
+				effective = append(effective, copyRoute)
+				for _, path := range route.Paths {
+					if err := claimProxyRoutePath(effective, claims, domain, path, routeIndex, false, route); err != nil {
+						return nil, err
+					}
+				}
+				if i > 0 {
+					continue
+				}
+				for _, redirect := range route.RedirectFrom {
+					if err := claimProxyRoutePath(effective, claims, redirect, "", routeIndex, true, route); err != nil {
+						return nil, err
+					}
+				}
+			}
+			continue
+		}
+
+		routeIndex := len(effective)
 		effective = append(effective, copyRoute)
 
 		for _, domain := range route.Domains {
@@ -604,9 +818,69 @@ func resolveProxyRouteClaims(routes []ProxyRoute) ([]ProxyRoute, error) {
 		}
 		filtered = append(filtered, route)
 	}
+	if err := checkProxySharedHosts(filtered, claims); err != nil {
+		return nil, err
+	}
 	return filtered, nil
 }
 
+// checkProxySharedHosts rejects hosts whose routes cannot share one site:
+// mixed visibility, or paths served on a host that redirects elsewhere.
+func checkProxySharedHosts(routes []ProxyRoute, claims map[string]proxyRouteHostClaim) error {
+	visibility := make(map[string]ProxyRoute)
+	for _, route := range routes {
+		for _, domain := range route.Domains {
+			if claim, ok := claims[domain]; ok && claim.redirect && len(route.Paths) > 0 {
+				return fmt.Errorf("proxy domain %q redirects for %s but %s serves paths on it", domain, claim.service, route.Service)
+			}
+			first, ok := visibility[domain]
+			if !ok {
+				visibility[domain] = route
+				continue
+			}
+			if first.Visibility != route.Visibility {
+				return fmt.Errorf("proxy domain %q is %s for %s but %s for %s; routes sharing a domain need the same visibility", domain, first.Visibility, first.Service, route.Visibility, route.Service)
+			}
+		}
+	}
+	return nil
+}
+
+// checkProxyHostOwners rejects a host routed by more than one project
+// environment. Routes of one environment may split a host by path or take
+// it over by priority; another environment claiming a path or redirect on
+// the host would hijack part of a site it does not own.
+func checkProxyHostOwners(manifests []ProxyRouteManifest) error {
+	owners := make(map[string]string)
+	for _, manifest := range manifests {
+		owner := manifest.Project + "/" + manifest.Environment
+		for _, route := range manifest.Routes {
+			for _, host := range append(append([]string(nil), route.Domains...), route.RedirectFrom...) {
+				existing, ok := owners[host]
+				if !ok {
+					owners[host] = owner
+					continue
+				}
+				if existing != owner {
+					return fmt.Errorf("proxy domain %q is routed by both %s and %s; only one project environment may route a domain", host, existing, owner)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// proxyRoutesByHost groups the routes serving each host, in route order.
+func proxyRoutesByHost(routes []ProxyRoute) map[string][]ProxyRoute {
+	byHost := make(map[string][]ProxyRoute)
+	for _, route := range routes {
+		for _, domain := range route.Domains {
+			byHost[domain] = append(byHost[domain], route)
+		}
+	}
+	return byHost
+}
+
 func sortProxyRoutesForCaddy(routes []ProxyRoute) {
 	sort.SliceStable(routes, func(i, j int) bool {
 		if routes[i].Priority != routes[j].Priority {
@@ -620,33 +894,64 @@ func sortProxyRoutesForCaddy(routes []ProxyRoute) {
 }
 
 func claimProxyRouteHost(effective []ProxyRoute, claims map[string]proxyRouteHostClaim, host string, routeIndex int, redirect bool, route ProxyRoute) error {
-	if existing, exists := claims[host]; exists {
+	return claimProxyRoutePath(effective, claims, host, "", routeIndex, redirect, route)
+}
+
+// claimProxyRoutePath claims host, or one path on it, for a route. Whole
+// host claims and redirects share the host's key; path claims are keyed by
+// host and path, so they coexist with the host's fallback route.
+func claimProxyRoutePath(effective []ProxyRoute, claims map[string]proxyRouteHostClaim, host string, path string, routeIndex int, redirect bool, route ProxyRoute) error {
+	key := host
+	subject := fmt.Sprintf("proxy domain %q", host)
+	if path != "" {
+		key = host + " " + path
+		subject = fmt.Sprintf("proxy path %q", host+path)
+	}
+	if existing, exists := claims[key]; exists {
 		if existing.routeIndex == routeIndex {
-			return fmt.Errorf("proxy domain %q is duplicated in route %s", host, route.Service)
+			return fmt.Errorf("%s is duplicated in route %s", subject, route.Service)
 		}
 		if existing.priority == route.Priority {
-			return fmt.Errorf("proxy domain %q is configured by both %s and %s", host, existing.service, route.Service)
+			return fmt.Errorf("%s is configured by both %s and %s", subject, existing.service, route.Service)
 		}
 		if existing.priority > route.Priority {
 			return nil
 		}
-		removeClaimedHost(&effective[existing.routeIndex], host, existing.redirect)
+		removeClaimedPath(&effective[existing.routeIndex], host, path, existing.redirect)
 	}
 
-	claims[host] = proxyRouteHostClaim{
+	claims[key] = proxyRouteHostClaim{
 		routeIndex: routeIndex,
 		priority:   route.Priority,
 		redirect:   redirect,
 		service:    route.Service,
 	}
-	if redirect {
-		effective[routeIndex].RedirectFrom = append(effective[routeIndex].RedirectFrom, host)
-		return nil
+	target := &effective[routeIndex]
+	switch {
+	case redirect:
+		target.RedirectFrom = append(target.RedirectFrom, host)
+	case path != "":
+		target.Paths = append(target.Paths, path)
+		if len(target.Domains) == 0 {
+			target.Domains = []string{host}
+		}
+	default:
+		target.Domains = append(target.Domains, host)
 	}
-	effective[routeIndex].Domains = append(effective[routeIndex].Domains, host)
 	return nil
 }
 
+func removeClaimedPath(route *ProxyRoute, host string, path string, redirect bool) {
+	if path == "" {
+		removeClaimedHost(route, host, redirect)
+		return
+	}
+	route.Paths = removeString(route.Paths, path)
+	if len(route.Paths) == 0 {
+		route.Domains = nil
+	}
+}
+
 func removeClaimedHost(route *ProxyRoute, host string, redirect bool) {
 	if redirect {
 		route.RedirectFrom = removeString(route.RedirectFrom, host)
@@ -667,34 +972,167 @@ func removeString(values []string, value string) []string {
 	return values
 }
 
-func writeCaddyRoute(b *strings.Builder, address string, route ProxyRoute, certificate *proxyCertificateEntry) {
+// writeCaddyRoute renders one site. A site served by a single whole-host
+// route proxies every request; a site shared by path routes renders each
+// path as a handle block (handle_path when the prefix is stripped), which
+// Caddy tries most specific first, with the whole-host route as the final
+// fallback.
+func writeCaddyRoute(b *strings.Builder, address string, routes []ProxyRoute, certificate *proxyCertificateEntry) {
 	b.WriteString("\n" + address + " {\n")
 	if address == ":443" {
 		b.WriteString("\ttls {\n\t\ton_demand\n\t}\n")
 	} else if certificate != nil {
 		writeCaddyCertificate(b, "\t", certificate)
 	}
-	writeCaddyAccessLog(b, caddyAccessLogName(route.Service))
+	if len(routes) == 1 && len(routes[0].Paths) == 0 {
+		route := routes[0]
+		writeCaddyAccessLog(b, caddyAccessLogName(route.Service))
+		writeCaddyUpstreamLogField(b, "\t", route)
+		b.WriteString("\tencode zstd gzip\n")
+		writeCaddyAllowMatcher(b, "\t", "@tako_allowed", route)
+		writeCaddyRouteHandlers(b, "\t", address, "@tako_allowed", route)
+		b.WriteString("}\n")
+		return
+	}
+
+	loggers := make(map[string]bool)
+	for _, route := range routes {
+		name := caddyAccessLogName(route.Service)
+		if !loggers[name] {
+			loggers[name] = true
+			writeCaddyAccessLog(b, name)
+		}
+	}
 	b.WriteString("\tencode zstd gzip\n")
+	type pathHandler struct {
+		path    string
+		route   ProxyRoute
+		matcher string
+	}
+	var handlers []pathHandler
+	var fallback *pathHandler
+	for i, route := range routes {
+		matcher := fmt.Sprintf("@tako_allowed_%d", i)
+		writeCaddyAllowMatcher(b, "\t", matcher, route)
+		if len(route.Paths) == 0 {
+			fallback = &pathHandler{route: route, matcher: matcher}
+			continue
+		}
+		for _, path := range route.Paths {
+			handlers = append(handlers, pathHandler{path: path, route: route, matcher: matcher})
+		}
+	}
+	sort.SliceStable(handlers, func(i, j int) bool {
+		if len(handlers[i].path) != len(handlers[j].path) {
+			return len(handlers[i].path) > len(handlers[j].path)
+		}
+		return handlers[i].path < handlers[j].path
+	})
+	for _, handler := range handlers {
+		directive := "handle"
+		if handler.route.StripPrefix {
+			directive = "handle_path"
+		}
+		b.WriteString("\t" + directive + " " + handler.path + " {\n")
+		writeCaddySharedRouteHandlers(b, address, handler.matcher, handler.route)
+		b.WriteString("\t}\n")
+	}
+	b.WriteString("\thandle {\n")
+	if fallback != nil {
+		writeCaddySharedRouteHandlers(b, address, fallback.matcher, fallback.route)
+	} else {
+		b.WriteString("\t\trespond 404\n")
+	}
+	b.WriteString("\t}\n")
+	b.WriteString("}\n")
+}
+
+// writeCaddySharedRouteHandlers renders one route inside a shared site's
+// handle block; log_name keeps its requests in the route's own access log.
+func writeCaddySharedRouteHandlers(b *strings.Builder, address string, matcher string, route ProxyRoute) {
+	b.WriteString("\t\tlog_name " + caddyAccessLogName(route.Service) + "\n")
+	writeCaddyUpstreamLogField(b, "\t\t", route)
+	writeCaddyRouteHandlers(b, "\t\t", address, matcher, route)
+}
+
+func writeCaddyUpstreamLogField(b *strings.Builder, indent string, route ProxyRoute) {
+	if route.Revision != "" {
+		// Revision routes record the chosen upstream so traffic analysis can
+		// attribute each request to the revision that served it.
+		b.WriteString(indent + "log_append " + proxyAccessLogUpstreamField + " {http.reverse_proxy.upstream.hostport}\n")
+	}
+}
+
+func writeCaddyAllowMatcher(b *strings.Builder, indent string, name string, route ProxyRoute) {
+	if len(route.AllowIPs) == 0 {
+		return
+	}
+	matcher := "remote_ip"
+	if len(route.TrustedProxies) > 0 {
+		matcher = "client_ip"
+	}
+	b.WriteString(indent + name + " " + matcher + " " + strings.Join(route.AllowIPs, " ") + "\n")
+}
+
+func writeCaddyRouteHandlers(b *strings.Builder, indent string, address string, matcher string, route ProxyRoute) {
+	writeCaddyRateLimit(b, indent, route)
+	if route.MaxBodyBytes > 0 {
+		b.WriteString(indent + "request_body {\n")
+		b.WriteString(indent + "\tmax_size " + strconv.FormatInt(route.MaxBodyBytes, 10) + "\n")
+		b.WriteString(indent + "}\n")
+	}
 	if len(route.AllowIPs) > 0 {
 		// handle blocks force the allowlist to win before basic_auth:
 		// Caddy's default directive order would otherwise run basic_auth
 		// ahead of a bare respond matcher and 401 denied addresses.
-		matcher := "remote_ip"
-		if len(route.TrustedProxies) > 0 {
-			matcher = "client_ip"
-		}
-		b.WriteString("\t@tako_allowed " + matcher + " " + strings.Join(route.AllowIPs, " ") + "\n")
-		b.WriteString("\thandle @tako_allowed {\n")
-		writeCaddyBasicAuth(b, "\t\t", route)
-		writeCaddyReverseProxy(b, "\t\t", address, route)
-		b.WriteString("\t}\n")
-		b.WriteString("\thandle {\n\t\trespond 403\n\t}\n")
-	} else {
-		writeCaddyBasicAuth(b, "\t", route)
-		writeCaddyReverseProxy(b, "\t", address, route)
+		b.WriteString(indent + "handle " + matcher + " {\n")
+		writeCaddyBasicAuth(b, indent+"\t", route)
+		writeCaddyReverseProxy(b, indent+"\t", address, route)
+		b.WriteString(indent + "}\n")
+		b.WriteString(indent + "handle {\n" + indent + "\trespond 403\n" + indent + "}\n")
+		return
 	}
-	b.WriteString("}\n")
+	writeCaddyBasicAuth(b, indent, route)
+	writeCaddyReverseProxy(b, indent, address, route)
+}
+
+func proxyRoutesRateLimited(routes []ProxyRoute) bool {
+	for _, route := range routes {
+		if route.RateLimit != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCaddyRateLimit renders the route's rate limit as one zone. The zone
+// name is derived from the route so every site rendering the route shares
+// the same counters.
+func writeCaddyRateLimit(b *strings.Builder, indent string, route ProxyRoute) {
+	limit := route.RateLimit
+	if limit == nil {
+		return
+	}
+	key := "{remote_host}"
+	if len(route.TrustedProxies) > 0 {
+		key = "{client_ip}"
+	}
+	if limit.Header != "" {
+		key = "{http.request.header." + limit.Header + "}"
+	}
+	sum := sha256.Sum256([]byte(route.Service + "\x00" + strings.Join(route.Domains, ",")))
+	b.WriteString(indent + "rate_limit {\n")
+	b.WriteString(indent + "\tzone " + caddyAccessLogName(route.Service) + "_" + hex.EncodeToString(sum[:4]) + " {\n")
+	if len(limit.Paths) > 0 {
+		b.WriteString(indent + "\t\tmatch {\n")
+		b.WriteString(indent + "\t\t\tpath " + strings.Join(limit.Paths, " ") + "\n")
+		b.WriteString(indent + "\t\t}\n")
+	}
+	b.WriteString(indent + "\t\tkey " + key + "\n")
+	b.WriteString(indent + "\t\tevents " + strconv.Itoa(limit.Requests) + "\n")
+	b.WriteString(indent + "\t\twindow " + limit.Window + "\n")
+	b.WriteString(indent + "\t}\n")
+	b.WriteString(indent + "}\n")
 }
 
 func writeCaddyCertificate(b *strings.Builder, indent string, certificate *proxyCertificateEntry) {
@@ -715,7 +1153,7 @@ func writeCaddyReverseProxy(b *strings.Builder, indent string, address string, r
 	for _, upstream := range route.Upstreams {
 		b.WriteString(" " + upstream)
 	}
-	if route.HealthCheck == nil && !route.Sticky {
+	if route.HealthCheck == nil && !route.Sticky && len(route.Weights) == 0 {
 		b.WriteString("\n")
 		return
 	}
@@ -734,6 +1172,13 @@ func writeCaddyReverseProxy(b *strings.Builder, indent string, address string, r
 	if route.Sticky {
 		b.WriteString(indent + "\tlb_policy cookie\n")
 	}
+	if len(route.Weights) > 0 {
+		b.WriteString(indent + "\tlb_policy weighted_round_robin")
+		for _, weight := range route.Weights {
+			b.WriteString(" " + strconv.Itoa(weight))
+		}
+		b.WriteString("\n")
+	}
 	b.WriteString(indent + "}\n")
 }
 
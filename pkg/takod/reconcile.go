@@ -17,6 +17,8 @@ import (
 
 	"github.com/redentordev/tako-cli/pkg/config"
 	"github.com/redentordev/tako-cli/pkg/runtimeid"
+	"github.com/redentordev/tako-cli/pkg/telemetry"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
@@ -64,6 +66,10 @@ type ReconcileServiceRequest struct {
 	ExtraHosts         []string                       `json:"extraHosts,omitempty"`
 	Ulimits            map[string]config.UlimitConfig `json:"ulimits,omitempty"`
 	ShmSize            string                         `json:"shmSize,omitempty"`
+	// SecretFiles switches the service to files delivery: takod writes them
+	// to a node tmpfs mounted read-only at /run/secrets. Like EnvFileContent,
+	// the values are request-scoped and never persisted.
+	SecretFiles *SecretFilesSpec `json:"secretFiles,omitempty"`
 	// RegistryAuths carries request-scoped pull credentials; they feed an
 	// ephemeral DOCKER_CONFIG for this reconcile only and are never
 	// persisted (ADR 10).
@@ -188,6 +194,9 @@ func ReconcileService(ctx context.Context, req ReconcileServiceRequest) (*Reconc
 	if err != nil {
 		return nil, err
 	}
+	if err := prepareServiceSecretFiles(ctx, req, deployStrategy); err != nil {
+		return nil, err
+	}
 	cleanupEnvFile, err := prepareServiceEnvFile(&req)
 	if err != nil {
 		return nil, err
@@ -196,14 +205,20 @@ func ReconcileService(ctx context.Context, req ReconcileServiceRequest) (*Reconc
 		defer cleanupEnvFile()
 	}
 	if req.PullImage {
-		if output, err := runDockerWithAuth(ctx, req.RegistryAuths, "pull", req.Image); err != nil {
+		pullCtx, span := startTakodSpan(ctx, "image.pull", attribute.String("image", req.Image))
+		output, err := runDockerWithAuth(pullCtx, req.RegistryAuths, "pull", req.Image)
+		telemetry.EndSpan(span, err)
+		if err != nil {
 			return nil, fmt.Errorf("failed to pull image %s: %w: %s", req.Image, err, annotateRegistryAuthFailure(strings.TrimSpace(output)))
 		}
 	}
 
 	started := make([]string, 0, len(req.Containers))
 	for _, container := range req.Containers {
-		if err := runServiceContainer(ctx, req, container); err != nil {
+		startCtx, span := startTakodSpan(ctx, "container.start", attribute.String("container", container.Name), attribute.String("image", req.Image))
+		err := runServiceContainer(startCtx, req, container)
+		telemetry.EndSpan(span, err)
+		if err != nil {
 			if cleanupErr := cleanupStartedContainers(started); cleanupErr != nil {
 				return nil, fmt.Errorf("%w; additionally failed to clean up started containers: %v", err, cleanupErr)
 			}
@@ -216,7 +231,10 @@ func ReconcileService(ctx context.Context, req ReconcileServiceRequest) (*Reconc
 			}
 			return nil, err
 		}
-		if err := waitForContainerHealthy(ctx, req.Network, container.Name, req.Health); err != nil {
+		healthCtx, span := startTakodSpan(ctx, "container.health_wait", attribute.String("container", container.Name))
+		err = waitForContainerHealthy(healthCtx, req.Network, container.Name, req.Health)
+		telemetry.EndSpan(span, err)
+		if err != nil {
 			if cleanupErr := cleanupStartedContainers(started); cleanupErr != nil {
 				return nil, fmt.Errorf("%w; additionally failed to clean up started containers: %v", err, cleanupErr)
 			}
@@ -244,6 +262,9 @@ func RemoveService(ctx context.Context, req RemoveServiceRequest) (*RemoveServic
 		if err := removeServiceFiles(req.Project, req.Environment, req.Service); err != nil {
 			return nil, fmt.Errorf("failed to remove service files: %w", err)
 		}
+		if err := removeServiceSecretFiles(req.Project, req.Environment, req.Service); err != nil {
+			return nil, fmt.Errorf("failed to remove secret files: %w", err)
+		}
 	}
 	return &RemoveServiceResponse{
 		Project:           req.Project,
@@ -364,6 +385,9 @@ func validateReconcileServiceRequest(req ReconcileServiceRequest) error {
 	} else if len(req.Files) > 0 {
 		return fmt.Errorf("fileSetId is required when service files are present")
 	}
+	if err := validateSecretFilesSpec(req.SecretFiles); err != nil {
+		return err
+	}
 	if err := validateDockerLabels(req.Labels); err != nil {
 		return fmt.Errorf("invalid label: %w", err)
 	}
@@ -635,7 +659,7 @@ func normalizeTakodDeployStrategy(strategy string) string {
 		return "recreate"
 	}
 	switch strategy {
-	case "recreate", "rolling", "blue_green":
+	case "recreate", "rolling", "blue_green", "canary":
 		return strategy
 	default:
 		return ""
@@ -643,7 +667,7 @@ func normalizeTakodDeployStrategy(strategy string) string {
 }
 
 func takodDeployStrategyUsesRevisionScope(strategy string) bool {
-	return strategy == "rolling" || strategy == "blue_green"
+	return strategy == "rolling" || strategy == "blue_green" || strategy == "canary"
 }
 
 func isSafeServiceName(name string) bool {
@@ -1176,6 +1200,9 @@ func buildServiceContainerArgs(req ReconcileServiceRequest, container ContainerS
 	for _, mount := range req.Mounts {
 		args = append(args, "--mount", mount)
 	}
+	if req.SecretFiles != nil {
+		args = append(args, "--mount", ServiceSecretsMount(req.Project, req.Environment, req.Service))
+	}
 	for _, publish := range container.Publishes {
 		args = append(args, "--publish", publish)
 	}
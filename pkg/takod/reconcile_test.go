@@ -242,7 +242,7 @@ func TestValidateReconcileServiceRequest(t *testing.T) {
 	}
 
 	invalid = valid
-	invalid.DeployStrategy = "canary"
+	invalid.DeployStrategy = "linear"
 	if err := validateReconcileServiceRequest(invalid); err == nil {
 		t.Fatalf("expected invalid deploy strategy to be rejected")
 	}
@@ -1422,6 +1422,9 @@ func TestTakodCommandHelper(t *testing.T) {
 			_, _ = os.Stderr.WriteString(output)
 			os.Exit(1)
 		}
+		if output := os.Getenv("TAKO_FAKE_DOCKER_EXEC_OUTPUT"); output != "" {
+			_, _ = os.Stdout.WriteString(output)
+		}
 		if os.Getenv("TAKO_FAKE_DOCKER_EXEC_INTERACTIVE") == "echo" {
 			scanner := bufio.NewScanner(os.Stdin)
 			for scanner.Scan() {
@@ -1504,11 +1507,22 @@ func TestTakodCommandHelper(t *testing.T) {
 			}
 			os.Exit(0)
 		}
+		if strings.Contains(joined, ".State.Error") {
+			if output := os.Getenv("TAKO_FAKE_INSPECT_STATE_ERROR"); output != "" {
+				_, _ = os.Stdout.WriteString(output + "\n")
+			}
+			os.Exit(0)
+		}
 		if strings.Contains(joined, ".State.Running") {
 			_, _ = os.Stdout.WriteString("true\n")
 		}
 		os.Exit(0)
 	case "logs":
+		if dir := os.Getenv("TAKO_FAKE_LOGS_DIR"); dir != "" {
+			data, _ := os.ReadFile(filepath.Join(dir, commandArgs[len(commandArgs)-1]+".log"))
+			_, _ = os.Stdout.Write(data)
+			os.Exit(0)
+		}
 		_, _ = os.Stdout.WriteString("logs\n")
 		os.Exit(0)
 	case "stats":
@@ -0,0 +1,536 @@
+package takod
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/crypto"
+)
+
+// DefaultServiceSecretsRoot holds files-delivered secrets on a tmpfs, one
+// directory per service, bind-mounted read-only at /run/secrets.
+const DefaultServiceSecretsRoot = "/run/tako/secrets"
+
+// DefaultSealedSecretsRoot keeps each service's secret files encrypted with
+// a node key, so takod can put them back on the tmpfs after a reboot.
+const DefaultSealedSecretsRoot = "/var/lib/tako/secret-files"
+
+// DefaultSealedSecretsKeyPath is the node key for sealed secret files. It
+// lives with the node's other keys under /etc/tako rather than next to the
+// ciphertext, so a copy of the state directory alone cannot open it.
+const DefaultSealedSecretsKeyPath = "/etc/tako/secret-files.key"
+
+const (
+	serviceSecretsTarget    = "/run/secrets"
+	maxSecretFiles          = 256
+	maxSecretFilesBytes     = 1 << 20
+	secretReloadHTTPTimeout = 10 * time.Second
+	sealedSecretsSuffix     = ".enc"
+)
+
+var (
+	serviceSecretsRoot = DefaultServiceSecretsRoot
+	sealedSecretsRoot  = DefaultSealedSecretsRoot
+	sealedSecretsKey   = DefaultSealedSecretsKeyPath
+	serviceSecretLocks sync.Map
+	// ensureServiceSecretsTmpfs is swapped out by tests that cannot mount.
+	ensureServiceSecretsTmpfs = ensureTmpfs
+)
+
+var secretReloadSignals = map[string]bool{"SIGHUP": true, "SIGUSR1": true, "SIGUSR2": true, "SIGWINCH": true}
+
+// SecretFilesSpec is the request-scoped set of secret files for one service.
+// Values are written to the node tmpfs and a node-sealed copy, never to
+// desired state.
+type SecretFilesSpec struct {
+	UID   int          `json:"uid,omitempty"`
+	GID   int          `json:"gid,omitempty"`
+	Mode  uint32       `json:"mode"`
+	Files []SecretFile `json:"files"`
+}
+
+type SecretFile struct {
+	Name string `json:"name"`
+	Data []byte `json:"data"`
+}
+
+// SecretReloadSpec tells takod how to notify running replicas after a
+// rotation: send Signal, or POST to Path on Port.
+type SecretReloadSpec struct {
+	Signal string `json:"signal,omitempty"`
+	Path   string `json:"path,omitempty"`
+	Port   int    `json:"port,omitempty"`
+}
+
+// SecretRotateRequest replaces some of a service's secret files in place and
+// notifies its running replicas on this node.
+type SecretRotateRequest struct {
+	Project     string            `json:"project"`
+	Environment string            `json:"environment"`
+	Service     string            `json:"service"`
+	Network     string            `json:"network,omitempty"`
+	Secrets     SecretFilesSpec   `json:"secrets"`
+	Reload      *SecretReloadSpec `json:"reload,omitempty"`
+}
+
+type SecretRotateResponse struct {
+	Project     string `json:"project"`
+	Environment string `json:"environment"`
+	Service     string `json:"service"`
+	// Delivered is false when no files-delivered replica of the service has
+	// run on this node, in which case nothing was written.
+	Delivered  bool     `json:"delivered"`
+	Updated    []string `json:"updated,omitempty"`
+	Containers []string `json:"containers,omitempty"`
+	Errors     []string `json:"errors,omitempty"`
+}
+
+// ServiceSecretsMount is the read-only bind mount exposing a service's
+// secret files at /run/secrets.
+func ServiceSecretsMount(project, environment, service string) string {
+	return fmt.Sprintf("type=bind,source=%s,target=%s,readonly", serviceSecretsDir(project, environment, service), serviceSecretsTarget)
+}
+
+func serviceSecretsDir(project, environment, service string) string {
+	return filepath.Join(serviceSecretsRoot, project, environment, service)
+}
+
+func sealedSecretsPath(project, environment, service string) string {
+	return filepath.Join(sealedSecretsRoot, project, environment, service+sealedSecretsSuffix)
+}
+
+func serviceSecretsLock(project, environment, service string) *sync.Mutex {
+	key := project + "\x00" + environment + "\x00" + service
+	value, _ := serviceSecretLocks.LoadOrStore(key, &sync.Mutex{})
+	return value.(*sync.Mutex)
+}
+
+func validateSecretFilesSpec(spec *SecretFilesSpec) error {
+	if spec == nil {
+		return nil
+	}
+	if len(spec.Files) == 0 || len(spec.Files) > maxSecretFiles {
+		return fmt.Errorf("secret files must contain between 1 and %d files", maxSecretFiles)
+	}
+	switch spec.Mode {
+	case 0400, 0440, 0444:
+	default:
+		return fmt.Errorf("invalid secret file mode %#o", spec.Mode)
+	}
+	if spec.UID < 0 || spec.UID > 1<<31-1 || spec.GID < 0 || spec.GID > 1<<31-1 {
+		return fmt.Errorf("invalid secret file ownership")
+	}
+	seen := make(map[string]bool, len(spec.Files))
+	total := 0
+	for _, file := range spec.Files {
+		if !isSafeSecretFileName(file.Name) || seen[file.Name] {
+			return fmt.Errorf("invalid or duplicate secret file name")
+		}
+		seen[file.Name] = true
+		total += len(file.Data)
+	}
+	if total > maxSecretFilesBytes {
+		return fmt.Errorf("secret files exceed 1 MiB")
+	}
+	return nil
+}
+
+func isSafeSecretFileName(name string) bool {
+	if name == "" || len(name) > 255 || name[0] == '.' {
+		return false
+	}
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '-' || r == '.' {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// writeServiceSecretFiles writes each file atomically so a running replica
+// reading /run/secrets sees either the old or the new value. With prune, files
+// no longer in the spec are removed.
+func writeServiceSecretFiles(ctx context.Context, project, environment, service string, spec SecretFilesSpec, prune bool) ([]string, error) {
+	if err := ensureServiceSecretsTmpfs(serviceSecretsRoot); err != nil {
+		return nil, err
+	}
+	dir := serviceSecretsDir(project, environment, service)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create secret files directory: %w", err)
+	}
+	mode := os.FileMode(spec.Mode).Perm()
+	written := make([]string, 0, len(spec.Files))
+	keep := make(map[string]bool, len(spec.Files))
+	for _, file := range spec.Files {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+		path := filepath.Join(dir, file.Name)
+		if err := writeFileAtomic(path, file.Data, mode); err != nil {
+			return written, fmt.Errorf("failed to write secret file %s: %w", file.Name, err)
+		}
+		if err := setServiceFileMetadata(path, mode, spec.UID, spec.GID); err != nil {
+			return written, err
+		}
+		keep[file.Name] = true
+		written = append(written, file.Name)
+	}
+	if prune {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return written, fmt.Errorf("failed to list secret files: %w", err)
+		}
+		for _, entry := range entries {
+			if !keep[entry.Name()] {
+				if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+					return written, fmt.Errorf("failed to remove stale secret file: %w", err)
+				}
+			}
+		}
+	}
+	// The directory grants search to whoever may read the files.
+	dirMode := os.FileMode(0500)
+	if spec.Mode&0040 != 0 {
+		dirMode |= 0050
+	}
+	if spec.Mode&0004 != 0 {
+		dirMode |= 0005
+	}
+	if err := setServiceFileMetadata(dir, dirMode, spec.UID, spec.GID); err != nil {
+		return written, err
+	}
+	sort.Strings(written)
+	return written, nil
+}
+
+func prepareServiceSecretFiles(ctx context.Context, req ReconcileServiceRequest, strategy string) error {
+	lock := serviceSecretsLock(req.Project, req.Environment, req.Service)
+	lock.Lock()
+	defer lock.Unlock()
+	if req.SecretFiles == nil {
+		// Env delivery drops files left by an earlier files deploy, unless
+		// replicas of the previous revision may still be reading them.
+		if takodDeployStrategyUsesRevisionScope(strategy) {
+			return nil
+		}
+		return removeServiceSecretFilesLocked(req.Project, req.Environment, req.Service)
+	}
+	if _, err := writeServiceSecretFiles(ctx, req.Project, req.Environment, req.Service, *req.SecretFiles, true); err != nil {
+		return err
+	}
+	return sealServiceSecretFiles(req.Project, req.Environment, req.Service, *req.SecretFiles)
+}
+
+func removeServiceSecretFiles(project, environment, service string) error {
+	lock := serviceSecretsLock(project, environment, service)
+	lock.Lock()
+	defer lock.Unlock()
+	return removeServiceSecretFilesLocked(project, environment, service)
+}
+
+func removeServiceSecretFilesLocked(project, environment, service string) error {
+	if err := os.RemoveAll(serviceSecretsDir(project, environment, service)); err != nil {
+		return err
+	}
+	if err := os.Remove(sealedSecretsPath(project, environment, service)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// removeProjectSecretFiles removes the secret files of every service of the
+// project, or of one environment of it, from the tmpfs and the sealed copies,
+// so a reboot cannot bring them back.
+func removeProjectSecretFiles(project, environment string, warn func(string, ...any)) {
+	for _, root := range []string{serviceSecretsRoot, sealedSecretsRoot} {
+		path := filepath.Join(root, project)
+		if environment != "" {
+			path = filepath.Join(path, environment)
+		}
+		removeFixedPath(path, "secret files", warn)
+	}
+}
+
+// sealServiceSecretFiles encrypts the service's secret files as they now are
+// on the tmpfs, after a deploy or a rotation, with the node key.
+func sealServiceSecretFiles(project, environment, service string, spec SecretFilesSpec) error {
+	dir := serviceSecretsDir(project, environment, service)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to list secret files: %w", err)
+	}
+	sealed := SecretFilesSpec{UID: spec.UID, GID: spec.GID, Mode: spec.Mode}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !isSafeSecretFileName(entry.Name()) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read secret file %s: %w", entry.Name(), err)
+		}
+		sealed.Files = append(sealed.Files, SecretFile{Name: entry.Name(), Data: data})
+	}
+	plaintext, err := json.Marshal(sealed)
+	if err != nil {
+		return err
+	}
+	encryptor, err := crypto.NewEncryptorFromKeyFile(sealedSecretsKey)
+	if err != nil {
+		return fmt.Errorf("failed to load secret files node key: %w", err)
+	}
+	ciphertext, err := encryptor.Encrypt(plaintext)
+	if err != nil {
+		return fmt.Errorf("failed to seal secret files: %w", err)
+	}
+	path := sealedSecretsPath(project, environment, service)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create sealed secret files directory: %w", err)
+	}
+	if err := writeFileAtomic(path, ciphertext, 0600); err != nil {
+		return fmt.Errorf("failed to write sealed secret files: %w", err)
+	}
+	return nil
+}
+
+// RestoreServiceSecretFiles writes sealed secret files back to the tmpfs for
+// every service whose directory a reboot emptied, then starts the service
+// containers docker could not start without their /run/secrets source.
+// takod runs it once at startup; one service failing does not stop the rest.
+func RestoreServiceSecretFiles(ctx context.Context) error {
+	paths, err := filepath.Glob(filepath.Join(sealedSecretsRoot, "*", "*", "*"+sealedSecretsSuffix))
+	if err != nil || len(paths) == 0 {
+		return err
+	}
+	encryptor, err := crypto.NewEncryptorFromKeyFile(sealedSecretsKey)
+	if err != nil {
+		return fmt.Errorf("failed to load secret files node key: %w", err)
+	}
+	var errs []error
+	for _, path := range paths {
+		parts := strings.Split(strings.TrimPrefix(path, filepath.Clean(sealedSecretsRoot)+string(filepath.Separator)), string(filepath.Separator))
+		if len(parts) != 3 {
+			continue
+		}
+		project, environment, service := parts[0], parts[1], strings.TrimSuffix(parts[2], sealedSecretsSuffix)
+		if !isSafeProjectName(project) || !isSafeRuntimeName(environment) || !isSafeServiceName(service) {
+			continue
+		}
+		restored, err := restoreServiceSecretFiles(ctx, encryptor, project, environment, service)
+		if err == nil && restored {
+			err = startContainersMissingSecretFiles(ctx, project, environment, service)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s/%s/%s: %w", project, environment, service, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func restoreServiceSecretFiles(ctx context.Context, encryptor *crypto.Encryptor, project, environment, service string) (bool, error) {
+	lock := serviceSecretsLock(project, environment, service)
+	lock.Lock()
+	defer lock.Unlock()
+	if _, err := os.Stat(serviceSecretsDir(project, environment, service)); err == nil {
+		return false, nil
+	} else if !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to inspect secret files directory: %w", err)
+	}
+	ciphertext, err := os.ReadFile(sealedSecretsPath(project, environment, service))
+	if err != nil {
+		return false, fmt.Errorf("failed to read sealed secret files: %w", err)
+	}
+	plaintext, err := encryptor.Decrypt(ciphertext)
+	if err != nil {
+		return false, fmt.Errorf("failed to unseal secret files: %w", err)
+	}
+	var spec SecretFilesSpec
+	if err := json.Unmarshal(plaintext, &spec); err != nil {
+		return false, fmt.Errorf("invalid sealed secret files: %w", err)
+	}
+	if err := validateSecretFilesSpec(&spec); err != nil {
+		return false, fmt.Errorf("invalid sealed secret files: %w", err)
+	}
+	if _, err := writeServiceSecretFiles(ctx, project, environment, service, spec, true); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// startContainersMissingSecretFiles starts the service's stopped containers
+// whose last start failed on the missing secret files mount source.
+// Containers stopped for any other reason stay stopped.
+func startContainersMissingSecretFiles(ctx context.Context, project, environment, service string) error {
+	output, err := runDocker(
+		ctx,
+		"ps", "-a",
+		"--filter", "label=tako.project="+project,
+		"--filter", "label=tako.environment="+environment,
+		"--filter", "label=tako.service="+service,
+		"--filter", "status=created",
+		"--filter", "status=exited",
+		"--format", "{{.Names}}",
+	)
+	if err != nil {
+		return fmt.Errorf("failed to list service containers: %w", err)
+	}
+	dir := serviceSecretsDir(project, environment, service)
+	containers := strings.Fields(strings.TrimSpace(output))
+	sort.Strings(containers)
+	for _, container := range containers {
+		stateErr, err := runDocker(ctx, "inspect", "--format", "{{.State.Error}}", container)
+		if err != nil || !strings.Contains(stateErr, dir) {
+			continue
+		}
+		if output, err := runDocker(ctx, "start", container); err != nil {
+			return fmt.Errorf("failed to start %s: %w: %s", container, err, strings.TrimSpace(output))
+		}
+	}
+	return nil
+}
+
+// RotateServiceSecrets rewrites the given secret files for a service that
+// uses files delivery on this node and then notifies its running replicas.
+// Reload failures are reported per container; the files stay rotated.
+func RotateServiceSecrets(ctx context.Context, req SecretRotateRequest) (*SecretRotateResponse, error) {
+	if err := validateSecretRotateRequest(req); err != nil {
+		return nil, err
+	}
+	response := &SecretRotateResponse{Project: req.Project, Environment: req.Environment, Service: req.Service}
+	lock := serviceSecretsLock(req.Project, req.Environment, req.Service)
+	lock.Lock()
+	info, err := os.Stat(serviceSecretsDir(req.Project, req.Environment, req.Service))
+	if os.IsNotExist(err) {
+		lock.Unlock()
+		return response, nil
+	}
+	if err != nil {
+		lock.Unlock()
+		return nil, fmt.Errorf("failed to inspect secret files directory: %w", err)
+	}
+	if !info.IsDir() {
+		lock.Unlock()
+		return nil, fmt.Errorf("secret files path is not a directory")
+	}
+	response.Delivered = true
+	response.Updated, err = writeServiceSecretFiles(ctx, req.Project, req.Environment, req.Service, req.Secrets, false)
+	if err == nil {
+		err = sealServiceSecretFiles(req.Project, req.Environment, req.Service, req.Secrets)
+	}
+	lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := runningServiceContainers(ctx, req.Project, req.Environment, req.Service)
+	if err != nil {
+		return nil, err
+	}
+	response.Containers = containers
+	if req.Reload == nil {
+		return response, nil
+	}
+	for _, container := range containers {
+		if err := reloadSecretConsumer(ctx, req.Network, container, *req.Reload); err != nil {
+			response.Errors = append(response.Errors, fmt.Sprintf("%s: %v", container, err))
+		}
+	}
+	return response, nil
+}
+
+func validateSecretRotateRequest(req SecretRotateRequest) error {
+	if !isSafeProjectName(req.Project) || !isSafeRuntimeName(req.Environment) || !isSafeServiceName(req.Service) {
+		return fmt.Errorf("invalid service identity")
+	}
+	if req.Network != "" && !isSafeRuntimeName(req.Network) {
+		return fmt.Errorf("invalid network name")
+	}
+	if err := validateSecretFilesSpec(&req.Secrets); err != nil {
+		return err
+	}
+	return validateSecretReloadSpec(req.Reload)
+}
+
+func validateSecretReloadSpec(reload *SecretReloadSpec) error {
+	if reload == nil {
+		return nil
+	}
+	switch {
+	case reload.Signal != "" && reload.Path != "":
+		return fmt.Errorf("reload accepts a signal or a path, not both")
+	case reload.Signal != "":
+		if !secretReloadSignals[reload.Signal] {
+			return fmt.Errorf("invalid reload signal")
+		}
+	case reload.Path != "":
+		if !strings.HasPrefix(reload.Path, "/") || len(reload.Path) > 2048 || strings.ContainsAny(reload.Path, " #") || hasControlChars(reload.Path) {
+			return fmt.Errorf("invalid reload path")
+		}
+		if reload.Port <= 0 || reload.Port > 65535 {
+			return fmt.Errorf("invalid reload port")
+		}
+	default:
+		return fmt.Errorf("reload needs a signal or a path")
+	}
+	return nil
+}
+
+func runningServiceContainers(ctx context.Context, project, environment, service string) ([]string, error) {
+	output, err := runDocker(
+		ctx,
+		"ps",
+		"--filter", "label=tako.project="+project,
+		"--filter", "label=tako.environment="+environment,
+		"--filter", "label=tako.service="+service,
+		"--filter", "status=running",
+		"--format", "{{.Names}}",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service containers: %w", err)
+	}
+	containers := strings.Fields(strings.TrimSpace(output))
+	sort.Strings(containers)
+	return containers, nil
+}
+
+func reloadSecretConsumer(ctx context.Context, network string, container string, reload SecretReloadSpec) error {
+	if reload.Signal != "" {
+		if output, err := runDocker(ctx, "kill", "--signal", reload.Signal, container); err != nil {
+			return fmt.Errorf("failed to send %s: %w: %s", reload.Signal, err, strings.TrimSpace(output))
+		}
+		return nil
+	}
+	ip, err := containerNetworkIP(ctx, network, container)
+	if err != nil {
+		return err
+	}
+	requestCtx, cancel := context.WithTimeout(ctx, secretReloadHTTPTimeout)
+	defer cancel()
+	target := "http://" + net.JoinHostPort(ip, strconv.Itoa(reload.Port)) + reload.Path
+	request, err := http.NewRequestWithContext(requestCtx, http.MethodPost, target, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build reload request: %w", err)
+	}
+	response, err := healthHTTPClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("reload request failed: %w", err)
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 4096))
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("reload returned status %d", response.StatusCode)
+	}
+	return nil
+}
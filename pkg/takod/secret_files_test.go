@@ -0,0 +1,310 @@
+package takod
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func useTempServiceSecretsRoot(t *testing.T) {
+	t.Helper()
+	oldRoot, oldSealedRoot, oldKey, oldTmpfs := serviceSecretsRoot, sealedSecretsRoot, sealedSecretsKey, ensureServiceSecretsTmpfs
+	serviceSecretsRoot = t.TempDir()
+	sealedSecretsRoot = t.TempDir()
+	sealedSecretsKey = filepath.Join(t.TempDir(), "secret-files.key")
+	ensureServiceSecretsTmpfs = func(string) error { return nil }
+	t.Cleanup(func() {
+		serviceSecretsRoot, sealedSecretsRoot, sealedSecretsKey, ensureServiceSecretsTmpfs = oldRoot, oldSealedRoot, oldKey, oldTmpfs
+	})
+}
+
+func TestPrepareServiceSecretFilesWritesPrunesAndRemoves(t *testing.T) {
+	useTempServiceSecretsRoot(t)
+	req := ReconcileServiceRequest{Project: "demo", Environment: "production", Service: "api", SecretFiles: &SecretFilesSpec{
+		Mode:  0444,
+		Files: []SecretFile{{Name: "DATABASE_URL", Data: []byte("postgres://db")}, {Name: "STALE", Data: []byte("old")}},
+	}}
+	if err := prepareServiceSecretFiles(context.Background(), req, ""); err != nil {
+		t.Fatalf("prepareServiceSecretFiles: %v", err)
+	}
+	dir := serviceSecretsDir("demo", "production", "api")
+	req.SecretFiles.Files = req.SecretFiles.Files[:1]
+	if err := prepareServiceSecretFiles(context.Background(), req, ""); err != nil {
+		t.Fatalf("prepareServiceSecretFiles: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "DATABASE_URL"))
+	if err != nil || string(data) != "postgres://db" {
+		t.Fatalf("DATABASE_URL = %q, %v", data, err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "DATABASE_URL"))
+	if err != nil || info.Mode().Perm() != 0444 {
+		t.Fatalf("secret file mode = %v, %v", info, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "STALE")); !os.IsNotExist(err) {
+		t.Fatalf("stale secret file survived: %v", err)
+	}
+	mount := ServiceSecretsMount("demo", "production", "api")
+	if mount != "type=bind,source="+dir+",target=/run/secrets,readonly" {
+		t.Fatalf("mount = %q", mount)
+	}
+	if args := buildServiceContainerArgs(req, ContainerSpec{Name: "demo_production_api_1"}); !slices.Contains(args, mount) {
+		t.Fatalf("docker args missing secrets mount: %#v", args)
+	}
+
+	req.SecretFiles = nil
+	if err := prepareServiceSecretFiles(context.Background(), req, ""); err != nil {
+		t.Fatalf("prepareServiceSecretFiles env delivery: %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("secret files directory survived env delivery: %v", err)
+	}
+}
+
+func TestRestoreServiceSecretFilesAfterRebootStartsBlockedContainers(t *testing.T) {
+	useTempServiceSecretsRoot(t)
+	logPath := filepath.Join(t.TempDir(), "commands.log")
+	restore := useFakeCommands(t, logPath)
+	defer restore()
+	t.Setenv("TAKO_FAKE_PS_OUTPUT", "demo_production_api_1\n")
+
+	deploy := ReconcileServiceRequest{Project: "demo", Environment: "production", Service: "api", SecretFiles: &SecretFilesSpec{
+		Mode:  0440,
+		Files: []SecretFile{{Name: "DATABASE_URL", Data: []byte("postgres://db")}, {Name: "STRIPE_KEY", Data: []byte("sk_old")}},
+	}}
+	if err := prepareServiceSecretFiles(context.Background(), deploy, ""); err != nil {
+		t.Fatalf("prepareServiceSecretFiles: %v", err)
+	}
+	if _, err := RotateServiceSecrets(context.Background(), SecretRotateRequest{Project: "demo", Environment: "production", Service: "api", Secrets: SecretFilesSpec{
+		Mode:  0440,
+		Files: []SecretFile{{Name: "STRIPE_KEY", Data: []byte("sk_new")}},
+	}}); err != nil {
+		t.Fatalf("RotateServiceSecrets: %v", err)
+	}
+	sealed, err := os.ReadFile(sealedSecretsPath("demo", "production", "api"))
+	if err != nil || strings.Contains(string(sealed), "postgres://db") || strings.Contains(string(sealed), "sk_new") {
+		t.Fatalf("sealed copy missing or in cleartext: %v", err)
+	}
+
+	// A reboot empties the tmpfs; docker fails to start the replica.
+	dir := serviceSecretsDir("demo", "production", "api")
+	if err := os.RemoveAll(serviceSecretsRoot); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TAKO_FAKE_INSPECT_STATE_ERROR", "invalid mount config for type \"bind\": bind source path does not exist: "+dir)
+	if err := os.Remove(logPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := RestoreServiceSecretFiles(context.Background()); err != nil {
+		t.Fatalf("RestoreServiceSecretFiles: %v", err)
+	}
+	for name, want := range map[string]string{"DATABASE_URL": "postgres://db", "STRIPE_KEY": "sk_new"} {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(data) != want {
+			t.Fatalf("restored %s = %q, %v", name, data, err)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(dir, "STRIPE_KEY")); err != nil || info.Mode().Perm() != 0440 {
+		t.Fatalf("restored secret file mode = %v, %v", info, err)
+	}
+	log := strings.Join(readCommandLog(t, logPath), "\n")
+	if !strings.Contains(log, "--filter status=created --filter status=exited") || !strings.Contains(log, "docker start demo_production_api_1") {
+		t.Fatalf("blocked replica not started:\n%s", log)
+	}
+
+	// Files still on the tmpfs are left alone.
+	if err := os.Remove(logPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := RestoreServiceSecretFiles(context.Background()); err != nil {
+		t.Fatalf("RestoreServiceSecretFiles: %v", err)
+	}
+	if _, err := os.Stat(logPath); !os.IsNotExist(err) {
+		t.Fatalf("restore with intact files ran docker: %v", err)
+	}
+
+	if err := removeServiceSecretFiles("demo", "production", "api"); err != nil {
+		t.Fatalf("removeServiceSecretFiles: %v", err)
+	}
+	if _, err := os.Stat(sealedSecretsPath("demo", "production", "api")); !os.IsNotExist(err) {
+		t.Fatalf("sealed copy survived service removal: %v", err)
+	}
+}
+
+func TestCleanupProjectRemovesSecretFilesSoRebootCannotRestoreThem(t *testing.T) {
+	useTempServiceSecretsRoot(t)
+	logPath := filepath.Join(t.TempDir(), "commands.log")
+	restore := useFakeCommands(t, logPath)
+	defer restore()
+	for _, environment := range []string{"production", "staging"} {
+		req := ReconcileServiceRequest{Project: "secretshop", Environment: environment, Service: "api", SecretFiles: &SecretFilesSpec{
+			Mode:  0440,
+			Files: []SecretFile{{Name: "DATABASE_URL", Data: []byte("postgres://" + environment)}},
+		}}
+		if err := prepareServiceSecretFiles(context.Background(), req, ""); err != nil {
+			t.Fatalf("prepareServiceSecretFiles %s: %v", environment, err)
+		}
+	}
+	if _, err := os.Stat(sealedSecretsKey); err != nil {
+		t.Fatalf("node key: %v", err)
+	}
+	if strings.HasPrefix(sealedSecretsKey, sealedSecretsRoot+string(filepath.Separator)) {
+		t.Fatalf("node key %s is stored with the sealed copies", sealedSecretsKey)
+	}
+
+	response, err := CleanupProject(context.Background(), CleanupRequest{Project: "secretshop", Environment: "production", RemoveDeployFiles: true})
+	if err != nil || len(response.Warnings) != 0 {
+		t.Fatalf("CleanupProject = %+v, %v", response, err)
+	}
+	for _, path := range []string{serviceSecretsDir("secretshop", "production", "api"), sealedSecretsPath("secretshop", "production", "api")} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("%s survived cleanup: %v", path, err)
+		}
+	}
+
+	// A reboot empties the tmpfs; only the environment still deployed
+	// comes back.
+	if err := os.RemoveAll(serviceSecretsRoot); err != nil {
+		t.Fatal(err)
+	}
+	if err := RestoreServiceSecretFiles(context.Background()); err != nil {
+		t.Fatalf("RestoreServiceSecretFiles: %v", err)
+	}
+	if _, err := os.Stat(serviceSecretsDir("secretshop", "production", "api")); !os.IsNotExist(err) {
+		t.Fatalf("removed environment's secret files restored after reboot: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(serviceSecretsDir("secretshop", "staging", "api"), "DATABASE_URL")); err != nil || string(data) != "postgres://staging" {
+		t.Fatalf("staging secret file = %q, %v", data, err)
+	}
+
+	if _, err := CleanupProject(context.Background(), CleanupRequest{Project: "secretshop", RemoveDeployFiles: true}); err != nil {
+		t.Fatalf("CleanupProject: %v", err)
+	}
+	if err := os.RemoveAll(serviceSecretsRoot); err != nil {
+		t.Fatal(err)
+	}
+	if err := RestoreServiceSecretFiles(context.Background()); err != nil {
+		t.Fatalf("RestoreServiceSecretFiles: %v", err)
+	}
+	for _, root := range []string{serviceSecretsRoot, sealedSecretsRoot} {
+		if _, err := os.Stat(filepath.Join(root, "secretshop")); !os.IsNotExist(err) {
+			t.Fatalf("project secret files under %s after project cleanup: %v", root, err)
+		}
+	}
+}
+
+func TestValidateSecretFilesSpecRejectsUnsafeFiles(t *testing.T) {
+	for _, spec := range []SecretFilesSpec{
+		{Mode: 0644, Files: []SecretFile{{Name: "KEY"}}},
+		{Mode: 0400, Files: []SecretFile{{Name: "../KEY"}}},
+		{Mode: 0400, Files: []SecretFile{{Name: ".hidden"}}},
+		{Mode: 0400, Files: []SecretFile{{Name: "KEY"}, {Name: "KEY"}}},
+		{Mode: 0400, UID: -1, Files: []SecretFile{{Name: "KEY"}}},
+	} {
+		if err := validateSecretFilesSpec(&spec); err == nil {
+			t.Fatalf("validateSecretFilesSpec(%+v) succeeded", spec)
+		}
+	}
+}
+
+func TestRotateServiceSecretsSignalsRunningReplicas(t *testing.T) {
+	useTempServiceSecretsRoot(t)
+	logPath := filepath.Join(t.TempDir(), "commands.log")
+	restore := useFakeCommands(t, logPath)
+	defer restore()
+	t.Setenv("TAKO_FAKE_PS_OUTPUT", "demo_production_api_2\ndemo_production_api_1\n")
+
+	req := SecretRotateRequest{Project: "demo", Environment: "production", Service: "api", Secrets: SecretFilesSpec{
+		Mode:  0444,
+		Files: []SecretFile{{Name: "STRIPE_KEY", Data: []byte("sk_new")}},
+	}, Reload: &SecretReloadSpec{Signal: "SIGHUP"}}
+	response, err := RotateServiceSecrets(context.Background(), req)
+	if err != nil {
+		t.Fatalf("RotateServiceSecrets: %v", err)
+	}
+	if response.Delivered {
+		t.Fatalf("rotation without delivered files = %+v", response)
+	}
+	if _, err := os.Stat(logPath); !os.IsNotExist(err) {
+		t.Fatalf("rotation without delivered files ran docker: %v", err)
+	}
+
+	deploy := ReconcileServiceRequest{Project: "demo", Environment: "production", Service: "api", SecretFiles: &SecretFilesSpec{
+		Mode:  0444,
+		Files: []SecretFile{{Name: "DATABASE_URL", Data: []byte("postgres://db")}, {Name: "STRIPE_KEY", Data: []byte("sk_old")}},
+	}}
+	if err := prepareServiceSecretFiles(context.Background(), deploy, ""); err != nil {
+		t.Fatalf("prepareServiceSecretFiles: %v", err)
+	}
+	response, err = RotateServiceSecrets(context.Background(), req)
+	if err != nil {
+		t.Fatalf("RotateServiceSecrets: %v", err)
+	}
+	if !response.Delivered || !reflect.DeepEqual(response.Updated, []string{"STRIPE_KEY"}) || len(response.Errors) != 0 {
+		t.Fatalf("response = %+v", response)
+	}
+	if want := []string{"demo_production_api_1", "demo_production_api_2"}; !reflect.DeepEqual(response.Containers, want) {
+		t.Fatalf("containers = %v, want %v", response.Containers, want)
+	}
+	dir := serviceSecretsDir("demo", "production", "api")
+	if data, err := os.ReadFile(filepath.Join(dir, "STRIPE_KEY")); err != nil || string(data) != "sk_new" {
+		t.Fatalf("STRIPE_KEY = %q, %v", data, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "DATABASE_URL")); err != nil || string(data) != "postgres://db" {
+		t.Fatalf("rotation touched DATABASE_URL: %q, %v", data, err)
+	}
+	log := strings.Join(readCommandLog(t, logPath), "\n")
+	for _, want := range []string{
+		"docker ps --filter label=tako.project=demo --filter label=tako.environment=production --filter label=tako.service=api --filter status=running",
+		"docker kill --signal SIGHUP demo_production_api_1",
+		"docker kill --signal SIGHUP demo_production_api_2",
+	} {
+		if !strings.Contains(log, want) {
+			t.Fatalf("command log missing %q:\n%s", want, log)
+		}
+	}
+}
+
+func TestRotateServiceSecretsReportsReloadEndpointFailures(t *testing.T) {
+	useTempServiceSecretsRoot(t)
+	restore := useFakeCommands(t, filepath.Join(t.TempDir(), "commands.log"))
+	defer restore()
+
+	var reloads []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reloads = append(reloads, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	host, portText, err := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, _ := strconv.Atoi(portText)
+	t.Setenv("TAKO_FAKE_PS_OUTPUT", "demo_production_api_1")
+	t.Setenv("TAKO_FAKE_CONTAINER_IP", host)
+
+	spec := SecretFilesSpec{Mode: 0444, Files: []SecretFile{{Name: "API_KEY", Data: []byte("v2")}}}
+	if err := prepareServiceSecretFiles(context.Background(), ReconcileServiceRequest{Project: "demo", Environment: "production", Service: "api", SecretFiles: &spec}, ""); err != nil {
+		t.Fatalf("prepareServiceSecretFiles: %v", err)
+	}
+	response, err := RotateServiceSecrets(context.Background(), SecretRotateRequest{
+		Project: "demo", Environment: "production", Service: "api", Network: "tako_demo_production",
+		Secrets: spec, Reload: &SecretReloadSpec{Path: "/-/reload", Port: port},
+	})
+	if err != nil {
+		t.Fatalf("RotateServiceSecrets: %v", err)
+	}
+	if !reflect.DeepEqual(reloads, []string{"POST /-/reload"}) {
+		t.Fatalf("reload requests = %v", reloads)
+	}
+	if len(response.Errors) != 1 || !strings.Contains(response.Errors[0], "status 503") {
+		t.Fatalf("errors = %v", response.Errors)
+	}
+}
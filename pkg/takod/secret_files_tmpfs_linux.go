@@ -0,0 +1,30 @@
+//go:build linux
+
+package takod
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// ensureTmpfs makes sure root lives on a tmpfs so secret files never reach
+// disk. /run already is one on systemd hosts; elsewhere takod mounts a small
+// private tmpfs at root.
+func ensureTmpfs(root string) error {
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return fmt.Errorf("failed to create secret files root: %w", err)
+	}
+	var stat unix.Statfs_t
+	if err := unix.Statfs(root, &stat); err != nil {
+		return fmt.Errorf("failed to inspect secret files root: %w", err)
+	}
+	if stat.Type == unix.TMPFS_MAGIC {
+		return nil
+	}
+	if err := unix.Mount("tmpfs", root, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, "mode=0700,size=16m"); err != nil {
+		return fmt.Errorf("failed to mount tmpfs for secret files at %s: %w", root, err)
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package takod
+
+import "fmt"
+
+func ensureTmpfs(root string) error {
+	return fmt.Errorf("secret files delivery requires a Linux node")
+}
@@ -10,6 +10,7 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -21,7 +22,9 @@ import (
 	"github.com/redentordev/tako-cli/pkg/platform"
 	"github.com/redentordev/tako-cli/pkg/recovery"
 	"github.com/redentordev/tako-cli/pkg/takoapi/ptystream"
+	"github.com/redentordev/tako-cli/pkg/telemetry"
 	"github.com/redentordev/tako-cli/pkg/upgradeprotocol"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Server struct {
@@ -45,6 +48,12 @@ type Server struct {
 	server                  *http.Server
 	backupScheduler         *BackupScheduler
 	jobScheduler            *JobScheduler
+	logShipper              *LogShipper
+	metricsExporter         *MetricsExporter
+	metricsHistory          *MetricsHistory
+	alerts                  *AlertEvaluator
+	tracing                 *Tracing
+	containerEvents         *ContainerEvents
 	certificateScheduler    *CertificateScheduler
 	uploadReadTimeout       time.Duration
 	diskReservationMu       sync.Mutex
@@ -161,6 +170,108 @@ const CapabilityProxyRemoteMeshRoutesV1 = "proxy.remote-mesh-routes-v1"
 // store API and can render store-backed TLS directives safely.
 const CapabilityProxyCertsV1 = "proxy.certs-v1"
 
+// CapabilityDeployCanaryV1 means reconcile accepts the canary deploy strategy
+// and proxy route manifests accept weighted canary upstreams.
+const CapabilityDeployCanaryV1 = "deploy.canary-v1"
+
+// CapabilityProxyAnalysisV1 means the node attributes proxy access log
+// entries to revisions and serves per-revision traffic analysis.
+const CapabilityProxyAnalysisV1 = "proxy.analysis-v1"
+
+// CapabilityJobWorkflowsV1 means job specs accept after/onSuccess/onFailure
+// edges and downstream jobs run within their upstream's workflow run.
+const CapabilityJobWorkflowsV1 = "jobs.workflows-v1"
+
+// CapabilityJobRetriesV1 means job specs accept retries with backoff and a
+// concurrency policy, and run records carry attempt numbers.
+const CapabilityJobRetriesV1 = "jobs.retries-v1"
+
+// CapabilityJobLogsV1 means job runs archive their full output under the
+// data dir and /v1/jobs/logs serves it, following runs in progress.
+const CapabilityJobLogsV1 = "jobs.logs-v1"
+
+// CapabilityJobNotificationsV1 means job specs accept notification targets
+// and the node alerts on job failures, recoveries, and missed schedules.
+const CapabilityJobNotificationsV1 = "jobs.notifications-v1"
+
+// CapabilityLogsQueryV1 means /v1/logs accepts since/until, replica, grep,
+// exclude, level, and field query parameters and answers them with
+// time-ordered NDJSON log entries.
+const CapabilityLogsQueryV1 = "logs.query-v1"
+
+// CapabilityLogShippingV1 means the node serves /v1/logging and ships an
+// environment's container and proxy access logs to Loki, syslog, and HTTP
+// sinks.
+const CapabilityLogShippingV1 = "logs.shipping-v1"
+
+// CapabilityProxyPathsV1 means proxy route manifests accept path matchers
+// with prefix stripping and several routes may share one domain.
+const CapabilityProxyPathsV1 = "proxy.paths-v1"
+
+// CapabilityProxyLimitsV1 means proxy route manifests accept per-route rate
+// limits and request body size limits, and proxy reconcile switches to a
+// Caddy build with the rate limit module when a route needs it.
+const CapabilityProxyLimitsV1 = "proxy.limits-v1"
+
+// CapabilityBackupConsistentV1 means backup requests and schedules accept a
+// mode (pg_dump, mysqldump, redis-bgsave, hook) and preBackup/postBackup
+// commands that run inside the service container.
+const CapabilityBackupConsistentV1 = "backups.consistent-v1"
+
+// CapabilityBackupChunkedV1 means backup storage accepts format: chunked and
+// uploads encrypted, deduplicated chunks instead of plaintext archives.
+const CapabilityBackupChunkedV1 = "backups.chunked-v1"
+
+// CapabilityBackupVerifyV1 means takod runs backup restore drills on demand
+// and on a schedule, and reports their outcome in the backup list.
+const CapabilityBackupVerifyV1 = "backups.verify-v1"
+
+// CapabilityBackupRetentionV1 means backup requests accept a
+// grandfather-father-son retention policy. Older takod ignores it and
+// applies its 7-day default, deleting the restore points it should keep.
+const CapabilityBackupRetentionV1 = "backups.retention-v1"
+
+// CapabilityBackupTargetsV1 means backup storage accepts the sftp,
+// filesystem, and peer providers, and enrolled nodes accept peer replicas on
+// their mesh address.
+const CapabilityBackupTargetsV1 = "backups.targets-v1"
+
+// CapabilityBackupPITRV1 means backups accept mode pg_basebackup with pitr,
+// takod ships archived Postgres WAL to backup storage, and
+// /v1/backups/pitr-restore recovers a volume to a point in time.
+const CapabilityBackupPITRV1 = "backups.pitr-v1"
+
+// CapabilityServiceSecretFilesV1 means reconcile accepts secretFiles, mounted
+// from a node tmpfs at /run/secrets, and /v1/service-secrets/rotate rewrites
+// them in place and notifies running replicas.
+const CapabilityServiceSecretFilesV1 = "service.secret-files-v1"
+
+// CapabilitySharedSecretsV1 means /v1/shared-secrets stores the environment's
+// recipient-sealed team secrets for tako secrets push and pull.
+const CapabilitySharedSecretsV1 = "secrets.shared-v1"
+
+// CapabilityMetricsExporterV1 means /v1/metrics/exporter configures an
+// authenticated Prometheus /metrics listener on the node's mesh address.
+const CapabilityMetricsExporterV1 = "metrics.exporter-v1"
+
+// CapabilityMetricsHistoryV1 means the node records minute-resolution usage
+// history and answers /v1/metrics/history range queries.
+const CapabilityMetricsHistoryV1 = "metrics.history-v1"
+
+// CapabilityAlertsV1 means the node evaluates deployed threshold alert rules
+// against its own metrics samples and serves /v1/alerts status and silences.
+const CapabilityAlertsV1 = "alerts.rules-v1"
+
+// CapabilityTracingV1 means the node continues W3C trace context from
+// requests with spans for its operations, exported to the environment's
+// OTLP collector.
+const CapabilityTracingV1 = "tracing.otlp-v1"
+
+// CapabilityContainerEventsV1 means the node follows docker events for its
+// service containers, records crashes, restarts, and OOM kills in /v1/events,
+// and notifies OOM kills and crash loops.
+const CapabilityContainerEventsV1 = "events.containers-v1"
+
 // CapabilityNodeIdentityV1 means status exposes an immutable installation
 // identity separately from mutable project/environment node metadata.
 const CapabilityNodeIdentityV1 = nodeidentity.Capability
@@ -204,6 +315,7 @@ func NewServerWithOptions(socket string, dataDir string, version string, opts Se
 		startedAt:               time.Now().UTC(),
 		backupScheduler:         NewBackupScheduler(dataDir),
 		jobScheduler:            NewJobScheduler(dataDir),
+		logShipper:              NewLogShipper(dataDir),
 		certificateScheduler:    NewCertificateScheduler(dataDir),
 		uploadReadTimeout:       opts.UploadReadTimeout,
 		diskReservations:        make(map[string]int64),
@@ -232,6 +344,12 @@ func NewServerWithOptions(socket string, dataDir string, version string, opts Se
 	server.backupScheduler.admit = func(...string) error { return server.checkFreeDisk(0, backupRootDir) }
 	server.jobScheduler.admit = func(...string) error { return server.checkFreeDisk(0, server.dataDir, server.dockerDataRoot) }
 	server.certificateScheduler.admit = func(paths ...string) error { return server.checkFreeDisk(0, paths...) }
+	server.metricsExporter = NewMetricsExporter(dataDir, server.jobScheduler)
+	server.metricsHistory = NewMetricsHistory(dataDir)
+	server.alerts = NewAlertEvaluator(dataDir)
+	server.metricsHistory.observe = server.alerts.Evaluate
+	server.tracing = NewTracing(dataDir, version)
+	server.containerEvents = NewContainerEvents(dataDir)
 	return server
 }
 
@@ -300,6 +418,8 @@ func (s *Server) Run(ctx context.Context) error {
 			return startupErr
 		}
 		defer deactivatePolicy()
+		defer activateBackupPeers(s.installation, s.inventoryFile)()
+		go s.runBackupPeerListener(ctx)
 	}
 
 	if err := os.MkdirAll(filepath.Dir(s.socket), 0755); err != nil {
@@ -323,7 +443,12 @@ func (s *Server) Run(ctx context.Context) error {
 		mux.HandleFunc(route.path, route.handler)
 	}
 
-	httpServer := newTakodHTTPServer(s.enrolledLifecycleHandler(mux))
+	if err := s.tracing.Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "takod tracing: %v\n", err)
+	}
+	defer s.tracing.Shutdown()
+
+	httpServer := newTakodHTTPServer(s.enrolledLifecycleHandler(s.tracing.Handler(mux)))
 	s.mu.Lock()
 	s.server = httpServer
 	s.mu.Unlock()
@@ -336,7 +461,16 @@ func (s *Server) Run(ctx context.Context) error {
 	}
 	go s.backupScheduler.Run(ctx)
 	go s.jobScheduler.Run(ctx)
+	go s.logShipper.Run(ctx)
+	go s.metricsExporter.Run(ctx)
+	go s.metricsHistory.Run(ctx)
+	go s.containerEvents.Run(ctx)
 	go s.certificateScheduler.Run(ctx)
+	go func() {
+		if err := RestoreServiceSecretFiles(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "takod secret files: %v\n", err)
+		}
+	}()
 
 	errCh := make(chan error, 1)
 	go func() {
@@ -756,6 +890,30 @@ func (s *Server) handleServiceFilesCheck(w http.ResponseWriter, r *http.Request)
 	_ = json.NewEncoder(w).Encode(map[string]string{"fileSetId": request.FileSetID})
 }
 
+func (s *Server) handleServiceSecretsRotate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	defer r.Body.Close()
+	var request SecretRotateRequest
+	if err := decodeJSONRequest(w, r, &request); err != nil {
+		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateSecretRotateRequest(request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	response, err := RotateServiceSecrets(r.Context(), request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
 func (s *Server) handleRemoveService(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -856,6 +1014,16 @@ func (s *Server) handleProxyFile(w http.ResponseWriter, r *http.Request) {
 	)
 
 	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		response, err = ReadProxyFile(query.Get("name"))
+		if err == nil && response.Content != "" {
+			manifest, parseErr := ParseProxyRouteManifest(response.Content)
+			if parseErr != nil || manifest.Project != query.Get("project") || manifest.Environment != query.Get("environment") {
+				http.Error(w, "proxy route manifest is outside the requested project scope", http.StatusConflict)
+				return
+			}
+		}
 	case http.MethodPut:
 		defer r.Body.Close()
 		var request ProxyFileRequest
@@ -948,6 +1116,30 @@ func (s *Server) handleProxy(w http.ResponseWriter, r *http.Request) {
 	_ = encoder.Encode(response)
 }
 
+func (s *Server) handleProxyAnalysis(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	request, err := decodeProxyTrafficAnalysisQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.proxyAuthorityMu.Lock()
+	response, err := AnalyzeProxyTraffic(request)
+	s.proxyAuthorityMu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(response)
+}
+
 func (s *Server) handleProxyCertificates(w http.ResponseWriter, r *http.Request) {
 	var (
 		response any
@@ -1080,6 +1272,24 @@ func (s *Server) handleCleanup(w http.ResponseWriter, r *http.Request) {
 			response.Warnings = append(response.Warnings, fmt.Sprintf("failed to unschedule jobs: %v", err))
 		}
 		response.JobsRemoved = len(removedJobs)
+		if err := s.logShipper.RemoveProject(request.Project, request.Environment); err != nil {
+			response.Warnings = append(response.Warnings, fmt.Sprintf("failed to stop log shipping: %v", err))
+		}
+		if err := s.metricsExporter.RemoveProject(request.Project, request.Environment); err != nil {
+			response.Warnings = append(response.Warnings, fmt.Sprintf("failed to stop the metrics exporter: %v", err))
+		}
+		if err := s.metricsHistory.RemoveProject(request.Project, request.Environment); err != nil {
+			response.Warnings = append(response.Warnings, fmt.Sprintf("failed to remove metrics history: %v", err))
+		}
+		if err := s.alerts.RemoveProject(request.Project, request.Environment); err != nil {
+			response.Warnings = append(response.Warnings, fmt.Sprintf("failed to remove alert rules: %v", err))
+		}
+		if err := s.tracing.RemoveProject(request.Project, request.Environment); err != nil {
+			response.Warnings = append(response.Warnings, fmt.Sprintf("failed to stop tracing: %v", err))
+		}
+		if err := s.containerEvents.RemoveProject(request.Project, request.Environment); err != nil {
+			response.Warnings = append(response.Warnings, fmt.Sprintf("failed to remove container event history: %v", err))
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -1195,6 +1405,16 @@ func (s *Server) handleLease(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleEnvBundle(w http.ResponseWriter, r *http.Request) {
+	s.serveBundle(w, r, ReadEnvBundle, WriteEnvBundle)
+}
+
+func (s *Server) handleSharedSecrets(w http.ResponseWriter, r *http.Request) {
+	s.serveBundle(w, r, ReadSharedSecrets, WriteSharedSecrets)
+}
+
+type bundleFunc func(context.Context, string, EnvBundleRequest) (*EnvBundleResponse, error)
+
+func (s *Server) serveBundle(w http.ResponseWriter, r *http.Request, read, write bundleFunc) {
 	var (
 		response *EnvBundleResponse
 		err      error
@@ -1202,7 +1422,7 @@ func (s *Server) handleEnvBundle(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
-		response, err = ReadEnvBundle(r.Context(), s.dataDir, EnvBundleRequest{
+		response, err = read(r.Context(), s.dataDir, EnvBundleRequest{
 			Project:     r.URL.Query().Get("project"),
 			Environment: r.URL.Query().Get("environment"),
 		})
@@ -1216,7 +1436,7 @@ func (s *Server) handleEnvBundle(w http.ResponseWriter, r *http.Request) {
 		if !s.requireFreeDisk(w, s.dataDir) {
 			return
 		}
-		response, err = WriteEnvBundle(r.Context(), s.dataDir, request)
+		response, err = write(r.Context(), s.dataDir, request)
 	default:
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -1313,6 +1533,68 @@ func (s *Server) handleBackupRestore(w http.ResponseWriter, r *http.Request) {
 	_ = encoder.Encode(map[string]bool{"restored": true})
 }
 
+func (s *Server) handleBackupVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	defer r.Body.Close()
+
+	var request BackupVerifyRequest
+	if err := decodeJSONRequest(w, r, &request); err != nil {
+		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateBackupVerifyRequest(request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !s.requireFreeDisk(w, s.dockerDataRoot) {
+		return
+	}
+	verification, err := VerifyVolumeBackup(r.Context(), request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(verification)
+}
+
+func (s *Server) handleBackupPITRRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	defer r.Body.Close()
+
+	var request PITRRestoreRequest
+	if err := decodeJSONRequest(w, r, &request); err != nil {
+		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validatePITRRestoreRequest(request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !s.requireFreeDisk(w, s.dockerDataRoot, backupRootDir) {
+		return
+	}
+	response, err := RestorePointInTime(r.Context(), request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(response)
+}
+
 func (s *Server) handleBackupCleanup(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -1692,11 +1974,13 @@ func (s *Server) handleImageBuild(w http.ResponseWriter, r *http.Request) {
 		body = buffered
 	}
 
-	response, err := BuildImageWithOptions(r.Context(), image, body, auths, ImageBuildOptions{
+	buildCtx, span := startTakodSpan(r.Context(), "image.build", attribute.String("image", image))
+	response, err := BuildImageWithOptions(buildCtx, image, body, auths, ImageBuildOptions{
 		Dockerfile: dockerfile,
 		BuildArgs:  buildArgs,
 		Target:     target,
 	})
+	telemetry.EndSpan(span, err)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadGateway)
 		return
@@ -1882,11 +2166,23 @@ func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
 		Tail:        tail,
 		Follow:      follow,
 	}
+	if err := parseLogQueryParams(r.URL.Query(), &request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err := validateLogsRequest(request); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	if request.IsQuery() {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		counting := &countingWriter{writer: &flushResponseWriter{writer: w}}
+		if err := StreamServiceLogs(r.Context(), request, counting); err != nil && counting.written == 0 {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+		return
+	}
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	if err := StreamServiceLogs(r.Context(), request, &flushResponseWriter{writer: w}); err != nil {
 		http.Error(w, err.Error(), http.StatusBadGateway)
@@ -1894,6 +2190,44 @@ func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// parseLogQueryParams reads the log query fields of a /v1/logs request:
+// RFC 3339 since/until bounds, a replica slot, repeated grep, exclude, and
+// field=value filters, and a minimum level.
+func parseLogQueryParams(query url.Values, request *LogsRequest) error {
+	for name, target := range map[string]*time.Time{"since": &request.Since, "until": &request.Until} {
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+		}
+		*target = parsed.UTC()
+	}
+	if raw := query.Get("replica"); raw != "" {
+		replica, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("replica must be an integer")
+		}
+		request.Replica = replica
+	}
+	request.Grep = query["grep"]
+	request.Exclude = query["exclude"]
+	request.Level = query.Get("level")
+	for _, raw := range query["field"] {
+		key, value, ok := strings.Cut(raw, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("field filters must be key=value")
+		}
+		if request.Fields == nil {
+			request.Fields = map[string]string{}
+		}
+		request.Fields[key] = value
+	}
+	return nil
+}
+
 // handleExec streams a service-context command run. Validation and
 // resolution errors return HTTP errors before any output; once the stream
 // starts, failures surface as output text and the terminal exit marker.
@@ -2008,6 +2342,252 @@ func (s *Server) handleJobsApply(w http.ResponseWriter, r *http.Request) {
 	_ = encoder.Encode(response)
 }
 
+// handleLogging reports this node's log shipping pipelines, optionally
+// filtered by project/environment.
+func (s *Server) handleLogging(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	pipelines := s.logShipper.Status(r.URL.Query().Get("project"), r.URL.Query().Get("environment"))
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(map[string]any{"pipelines": pipelines})
+}
+
+// handleLoggingApply replaces one project/environment's log shipping spec.
+func (s *Server) handleLoggingApply(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	defer r.Body.Close()
+
+	var request LoggingApplyRequest
+	if err := decodeJSONRequestWithLimit(w, r, &request, takodMaxServiceJSONBodyBytes); err != nil {
+		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	response, err := s.logShipper.Apply(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(response)
+}
+
+// handleMetricsExporterApply replaces one project/environment's metrics
+// exporter spec.
+func (s *Server) handleMetricsExporterApply(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	defer r.Body.Close()
+
+	var request MetricsExporterApplyRequest
+	if err := decodeJSONRequest(w, r, &request); err != nil {
+		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	response, err := s.metricsExporter.Apply(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(response)
+}
+
+// handleMetricsHistory answers a range query over the node's recorded
+// usage for one project/environment.
+func (s *Server) handleMetricsHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	request := MetricsHistoryRequest{
+		Project:     query.Get("project"),
+		Environment: query.Get("environment"),
+		Service:     query.Get("service"),
+	}
+	for name, target := range map[string]*time.Time{"since": &request.Since, "until": &request.Until} {
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			http.Error(w, name+" must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		*target = parsed
+	}
+	response, err := s.metricsHistory.Query(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(response)
+}
+
+// handleAlerts returns one project/environment's alert states and active
+// silences on this node.
+func (s *Server) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	response, err := s.alerts.Status(r.URL.Query().Get("project"), r.URL.Query().Get("environment"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(response)
+}
+
+// handleAlertsApply replaces one project/environment's alert rules.
+func (s *Server) handleAlertsApply(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	defer r.Body.Close()
+
+	var request AlertsApplyRequest
+	if err := decodeJSONRequest(w, r, &request); err != nil {
+		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	response, err := s.alerts.Apply(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(response)
+}
+
+// handleAlertSilence adds or clears alert silences.
+func (s *Server) handleAlertSilence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	defer r.Body.Close()
+
+	var request AlertSilenceRequest
+	if err := decodeJSONRequest(w, r, &request); err != nil {
+		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	response, err := s.alerts.Silence(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(response)
+}
+
+// handleTracingApply replaces one project/environment's tracing spec.
+func (s *Server) handleTracingApply(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	defer r.Body.Close()
+
+	var request TracingApplyRequest
+	if err := decodeJSONRequest(w, r, &request); err != nil {
+		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	response, err := s.tracing.Apply(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(response)
+}
+
+// handleContainerEvents returns one project/environment's container event
+// history and per-service counters on this node.
+func (s *Server) handleContainerEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	request := ContainerEventsRequest{
+		Project:     query.Get("project"),
+		Environment: query.Get("environment"),
+		Service:     query.Get("service"),
+	}
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		request.Limit = limit
+	}
+	response, err := s.containerEvents.Events(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(response)
+}
+
+// handleContainerEventsApply replaces one project/environment's container
+// event notification spec.
+func (s *Server) handleContainerEventsApply(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	defer r.Body.Close()
+
+	var request ContainerEventsApplyRequest
+	if err := decodeJSONRequest(w, r, &request); err != nil {
+		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	response, err := s.containerEvents.Apply(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(response)
+}
+
 // handleJobRuns returns run history for one job or a whole environment.
 func (s *Server) handleJobRuns(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -2057,6 +2637,34 @@ func (s *Server) handleJobsTrigger(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleJobLogs streams the archived output of one job run, optionally
+// following a run in progress. Selection failures map to 404.
+func (s *Server) handleJobLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	follow := false
+	if rawFollow := query.Get("follow"); rawFollow != "" {
+		parsed, err := strconv.ParseBool(rawFollow)
+		if err != nil {
+			http.Error(w, "follow must be a boolean", http.StatusBadRequest)
+			return
+		}
+		follow = parsed
+	}
+	if !isSafeProjectName(query.Get("project")) || !isSafeRuntimeName(query.Get("environment")) || !isSafeServiceName(query.Get("job")) {
+		http.Error(w, "invalid job logs identity", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	counting := &countingWriter{writer: &flushResponseWriter{writer: w}}
+	if err := s.jobScheduler.StreamLogs(r.Context(), query.Get("project"), query.Get("environment"), query.Get("job"), query.Get("run"), follow, counting); err != nil && counting.written == 0 {
+		http.Error(w, err.Error(), http.StatusNotFound)
+	}
+}
+
 // countingWriter tracks whether any bytes reached the response so handlers
 // know if an HTTP error can still be written.
 type countingWriter struct {
@@ -2198,7 +2806,7 @@ func (s *Server) Status() Status {
 		Version:                s.version,
 		UpgradeProtocol:        upgradeprotocol.Current,
 		MinimumUpgradeProtocol: upgradeprotocol.Current,
-		Capabilities:           []string{CapabilityContainerArgvV1, CapabilityContainerRuntimeControlsV1, CapabilityImageBuildOptionsV1, CapabilityImageDescriptorV1, CapabilityNodePlatformV1, CapabilityExecOneOffControlsV1, CapabilityServiceFilesV1, CapabilityProxyTrustedProxiesV1, CapabilityProxyCertsV1, CapabilityAcmeDNSV1, CapabilityNodeIdentityV1, CapabilityNodeUpgradeV1},
+		Capabilities:           []string{CapabilityContainerArgvV1, CapabilityContainerRuntimeControlsV1, CapabilityImageBuildOptionsV1, CapabilityImageDescriptorV1, CapabilityNodePlatformV1, CapabilityExecOneOffControlsV1, CapabilityServiceFilesV1, CapabilityProxyTrustedProxiesV1, CapabilityProxyCertsV1, CapabilityAcmeDNSV1, CapabilityNodeIdentityV1, CapabilityNodeUpgradeV1, CapabilityDeployCanaryV1, CapabilityProxyAnalysisV1, CapabilityJobWorkflowsV1, CapabilityJobRetriesV1, CapabilityJobLogsV1, CapabilityJobNotificationsV1, CapabilityLogsQueryV1, CapabilityLogShippingV1, CapabilityProxyPathsV1, CapabilityProxyLimitsV1, CapabilityBackupConsistentV1, CapabilityBackupChunkedV1, CapabilityBackupVerifyV1, CapabilityBackupRetentionV1, CapabilityBackupTargetsV1, CapabilityBackupPITRV1, CapabilityServiceSecretFilesV1, CapabilitySharedSecretsV1, CapabilityMetricsExporterV1, CapabilityMetricsHistoryV1, CapabilityAlertsV1, CapabilityTracingV1, CapabilityContainerEventsV1},
 		Hostname:               hostname,
 		Socket:                 s.socket,
 		DataDir:                s.dataDir,
@@ -230,7 +230,10 @@ func TestBackgroundSchedulersUseResourceAdmission(t *testing.T) {
 	ran := false
 	jobs.runJob = func(context.Context, JobSpec, string, io.Writer) (int, error) { ran = true; return 0, nil }
 	spec := JobSpec{Project: "demo", Environment: "production", Name: "worker"}
-	record := jobs.executeReservedJob(context.Background(), spec, JobTriggerSchedule, nil)
+	jobs.mu.Lock()
+	slot := jobs.reserveJobRunLocked(jobKey(spec.Project, spec.Environment, spec.Name), spec.ConcurrencyPolicy)
+	jobs.mu.Unlock()
+	record := jobs.executeReservedJob(context.Background(), spec, JobTriggerSchedule, "", slot, nil)
 	if ran || record.Status != JobRunStatusFailed || !strings.Contains(record.Output, "resource admission") {
 		t.Fatalf("scheduled job bypassed admission: ran=%v record=%#v", ran, record)
 	}
@@ -72,7 +72,7 @@ func TestServerStatusOverUnixSocket(t *testing.T) {
 			if status.UpgradeProtocol != upgradeprotocol.Current || status.MinimumUpgradeProtocol != upgradeprotocol.Current {
 				t.Fatalf("unexpected upgrade protocol window %d/%d", status.UpgradeProtocol, status.MinimumUpgradeProtocol)
 			}
-			if len(status.Capabilities) != 12 || status.Capabilities[0] != CapabilityContainerArgvV1 || status.Capabilities[1] != CapabilityContainerRuntimeControlsV1 || status.Capabilities[2] != CapabilityImageBuildOptionsV1 || status.Capabilities[3] != CapabilityImageDescriptorV1 || status.Capabilities[4] != CapabilityNodePlatformV1 || status.Capabilities[5] != CapabilityExecOneOffControlsV1 || status.Capabilities[6] != CapabilityServiceFilesV1 || status.Capabilities[7] != CapabilityProxyTrustedProxiesV1 || status.Capabilities[8] != CapabilityProxyCertsV1 || status.Capabilities[9] != CapabilityAcmeDNSV1 || status.Capabilities[10] != CapabilityNodeIdentityV1 || status.Capabilities[11] != CapabilityNodeUpgradeV1 {
+			if len(status.Capabilities) != 35 || status.Capabilities[0] != CapabilityContainerArgvV1 || status.Capabilities[1] != CapabilityContainerRuntimeControlsV1 || status.Capabilities[2] != CapabilityImageBuildOptionsV1 || status.Capabilities[3] != CapabilityImageDescriptorV1 || status.Capabilities[4] != CapabilityNodePlatformV1 || status.Capabilities[5] != CapabilityExecOneOffControlsV1 || status.Capabilities[6] != CapabilityServiceFilesV1 || status.Capabilities[7] != CapabilityProxyTrustedProxiesV1 || status.Capabilities[8] != CapabilityProxyCertsV1 || status.Capabilities[9] != CapabilityAcmeDNSV1 || status.Capabilities[10] != CapabilityNodeIdentityV1 || status.Capabilities[11] != CapabilityNodeUpgradeV1 || status.Capabilities[12] != CapabilityDeployCanaryV1 || status.Capabilities[13] != CapabilityProxyAnalysisV1 || status.Capabilities[14] != CapabilityJobWorkflowsV1 || status.Capabilities[15] != CapabilityJobRetriesV1 || status.Capabilities[16] != CapabilityJobLogsV1 || status.Capabilities[17] != CapabilityJobNotificationsV1 || status.Capabilities[18] != CapabilityLogsQueryV1 || status.Capabilities[19] != CapabilityLogShippingV1 || status.Capabilities[20] != CapabilityProxyPathsV1 || status.Capabilities[21] != CapabilityProxyLimitsV1 || status.Capabilities[22] != CapabilityBackupConsistentV1 || status.Capabilities[23] != CapabilityBackupChunkedV1 || status.Capabilities[24] != CapabilityBackupVerifyV1 || status.Capabilities[25] != CapabilityBackupRetentionV1 || status.Capabilities[26] != CapabilityBackupTargetsV1 || status.Capabilities[27] != CapabilityBackupPITRV1 || status.Capabilities[28] != CapabilityServiceSecretFilesV1 || status.Capabilities[29] != CapabilitySharedSecretsV1 || status.Capabilities[30] != CapabilityMetricsExporterV1 || status.Capabilities[31] != CapabilityMetricsHistoryV1 || status.Capabilities[32] != CapabilityAlertsV1 || status.Capabilities[33] != CapabilityTracingV1 || status.Capabilities[34] != CapabilityContainerEventsV1 {
 				t.Fatalf("unexpected capabilities %#v", status.Capabilities)
 			}
 			return
@@ -0,0 +1,26 @@
+package takod
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+)
+
+// ReadSharedSecrets returns the environment's team secrets bundle, sealed by
+// tako secrets push to the environment's secretRecipients. takod never holds
+// a key that opens it.
+func ReadSharedSecrets(ctx context.Context, dataDir string, req EnvBundleRequest) (*EnvBundleResponse, error) {
+	return readBundle(ctx, dataDir, req, sharedSecretsPath)
+}
+
+// WriteSharedSecrets replaces the environment's team secrets bundle
+func WriteSharedSecrets(ctx context.Context, dataDir string, req EnvBundleRequest) (*EnvBundleResponse, error) {
+	return writeBundle(ctx, dataDir, req, sharedSecretsPath)
+}
+
+func sharedSecretsPath(dataDir string, req EnvBundleRequest) (string, error) {
+	if dataDir == "" {
+		return "", fmt.Errorf("data directory is required")
+	}
+	return filepath.Join(dataDir, "shared-secrets", req.Project, req.Environment+".enc"), nil
+}
@@ -0,0 +1,33 @@
+package takod
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSharedSecretsAreStoredApartFromEnvBundle(t *testing.T) {
+	dataDir := t.TempDir()
+	content := base64.StdEncoding.EncodeToString([]byte("TAKO_ENVELOPE_V1:sealed"))
+	request := EnvBundleRequest{Project: "demo", Environment: "production", Content: content}
+
+	if _, err := WriteSharedSecrets(context.Background(), dataDir, request); err != nil {
+		t.Fatalf("WriteSharedSecrets returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "shared-secrets", "demo", "production.enc")); err != nil {
+		t.Fatalf("shared secrets not stored under shared-secrets: %v", err)
+	}
+	envBundle, err := ReadEnvBundle(context.Background(), dataDir, EnvBundleRequest{Project: "demo", Environment: "production"})
+	if err != nil || envBundle.Found {
+		t.Fatalf("shared secrets leaked into env bundle: %#v, %v", envBundle, err)
+	}
+	read, err := ReadSharedSecrets(context.Background(), dataDir, EnvBundleRequest{Project: "demo", Environment: "production"})
+	if err != nil {
+		t.Fatalf("ReadSharedSecrets returned error: %v", err)
+	}
+	if !read.Found || read.Content != content || read.UpdatedAt.IsZero() {
+		t.Fatalf("unexpected shared secrets response: %#v", read)
+	}
+}
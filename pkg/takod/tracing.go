@@ -0,0 +1,422 @@
+package takod
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redentordev/tako-cli/pkg/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracingDirName    = "tracing"
+	tracingTracerName = "takod"
+	// tracingShutdownTimeout bounds flushing a replaced or stopped
+	// environment's pending spans.
+	tracingShutdownTimeout = 5 * time.Second
+)
+
+// TracingSpec exports one environment's takod spans to an OTLP/gRPC
+// collector at Endpoint (host:port). Headers may carry collector
+// credentials. Node is the name the deployer knows this server by, set as
+// the tako.node resource attribute.
+type TracingSpec struct {
+	Endpoint string            `json:"endpoint"`
+	Insecure bool              `json:"insecure,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Node     string            `json:"node,omitempty"`
+}
+
+// TracingApplyRequest replaces an environment's tracing spec; a nil Spec
+// stops exporting its spans.
+type TracingApplyRequest struct {
+	Project     string       `json:"project"`
+	Environment string       `json:"environment"`
+	Spec        *TracingSpec `json:"spec,omitempty"`
+}
+
+type TracingApplyResponse struct {
+	Project     string `json:"project"`
+	Environment string `json:"environment"`
+	Exporting   bool   `json:"exporting"`
+	Endpoint    string `json:"endpoint,omitempty"`
+}
+
+func validateTracingSpec(spec *TracingSpec) error {
+	if err := telemetry.ValidateOTLPExporter(spec.Endpoint, spec.Headers); err != nil {
+		return fmt.Errorf("tracing %w", err)
+	}
+	if len(spec.Node) > 255 || strings.IndexFunc(spec.Node, func(r rune) bool { return r <= ' ' || r == 0x7f }) >= 0 {
+		return fmt.Errorf("invalid node name")
+	}
+	return nil
+}
+
+// Tracing holds a tracer provider for every environment with a tracing
+// spec. Requests carrying a sampled W3C traceparent and the environment's
+// scope baggage get a server span, and the operations under it child spans,
+// all exported to that environment's collector. Untraced requests, and
+// environments without a spec, start no spans. Specs persist as JSON under
+// the data dir, like the metrics exporter's.
+type Tracing struct {
+	dataDir string
+	version string
+	// newExporter is a seam for tests.
+	newExporter func(ctx context.Context, spec TracingSpec) (sdktrace.SpanExporter, error)
+
+	// applyMu serializes spec replacement and removal.
+	applyMu   sync.Mutex
+	mu        sync.Mutex
+	specs     map[string]TracingSpec
+	providers map[string]*sdktrace.TracerProvider
+}
+
+func NewTracing(dataDir string, version string) *Tracing {
+	return &Tracing{
+		dataDir: dataDir,
+		version: version,
+		newExporter: func(ctx context.Context, spec TracingSpec) (sdktrace.SpanExporter, error) {
+			return telemetry.NewOTLPExporter(ctx, spec.Endpoint, spec.Insecure, spec.Headers)
+		},
+		specs:     map[string]TracingSpec{},
+		providers: map[string]*sdktrace.TracerProvider{},
+	}
+}
+
+// Load starts a provider for every persisted spec. A spec that fails to
+// load is reported and skipped so tracing never keeps takod from serving.
+func (t *Tracing) Load() error {
+	if t == nil {
+		return nil
+	}
+	t.applyMu.Lock()
+	defer t.applyMu.Unlock()
+	root := filepath.Join(t.dataDir, tracingDirName)
+	projects, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var failures []string
+	for _, project := range projects {
+		if !project.IsDir() {
+			continue
+		}
+		environments, err := os.ReadDir(filepath.Join(root, project.Name()))
+		if err != nil {
+			return err
+		}
+		for _, environment := range environments {
+			name, ok := strings.CutSuffix(environment.Name(), ".json")
+			if environment.IsDir() || !ok {
+				continue
+			}
+			path := filepath.Join(root, project.Name(), environment.Name())
+			var spec TracingSpec
+			data, err := os.ReadFile(path)
+			if err == nil {
+				err = json.Unmarshal(data, &spec)
+			}
+			if err == nil {
+				err = validateTracingSpec(&spec)
+			}
+			var provider *sdktrace.TracerProvider
+			if err == nil {
+				provider, err = t.newProvider(project.Name(), name, spec)
+			}
+			if err == nil {
+				t.install(project.Name(), name, spec, provider)
+			}
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", path, err))
+			}
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to load tracing specs: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// Apply replaces one environment's spec. The new provider is created before
+// the spec is saved; the replaced one flushes what it has buffered.
+func (t *Tracing) Apply(request TracingApplyRequest) (*TracingApplyResponse, error) {
+	if t == nil {
+		return nil, fmt.Errorf("tracing is not initialized")
+	}
+	if !isSafeProjectName(request.Project) {
+		return nil, fmt.Errorf("invalid project name")
+	}
+	if !isSafeRuntimeName(request.Environment) {
+		return nil, fmt.Errorf("invalid environment name")
+	}
+	t.applyMu.Lock()
+	defer t.applyMu.Unlock()
+	response := &TracingApplyResponse{Project: request.Project, Environment: request.Environment}
+	if request.Spec == nil {
+		if err := t.remove(request.Project, request.Environment); err != nil {
+			return nil, err
+		}
+		return response, nil
+	}
+	spec := *request.Spec
+	if err := validateTracingSpec(&spec); err != nil {
+		return nil, err
+	}
+	response.Exporting = true
+	response.Endpoint = spec.Endpoint
+
+	key := logShippingKey(request.Project, request.Environment)
+	t.mu.Lock()
+	existing, ok := t.specs[key]
+	t.mu.Unlock()
+	if ok && reflect.DeepEqual(existing, spec) {
+		return response, nil
+	}
+	provider, err := t.newProvider(request.Project, request.Environment, spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.persistSpec(request.Project, request.Environment, spec); err != nil {
+		shutdownTracerProviders(provider)
+		return nil, err
+	}
+	t.install(request.Project, request.Environment, spec, provider)
+	return response, nil
+}
+
+// RemoveProject stops exporting a project's spans (one environment, or all
+// when environment is empty) and deletes its specs.
+func (t *Tracing) RemoveProject(project string, environment string) error {
+	if t == nil {
+		return nil
+	}
+	if !isSafeProjectName(project) {
+		return fmt.Errorf("invalid project name")
+	}
+	t.applyMu.Lock()
+	defer t.applyMu.Unlock()
+	if environment != "" {
+		return t.remove(project, environment)
+	}
+	var stopped []*sdktrace.TracerProvider
+	t.mu.Lock()
+	for key, provider := range t.providers {
+		if strings.HasPrefix(key, project+"/") {
+			stopped = append(stopped, provider)
+			delete(t.providers, key)
+			delete(t.specs, key)
+		}
+	}
+	t.mu.Unlock()
+	shutdownTracerProviders(stopped...)
+	if err := os.RemoveAll(filepath.Join(t.dataDir, tracingDirName, project)); err != nil {
+		return fmt.Errorf("failed to remove tracing state: %w", err)
+	}
+	return nil
+}
+
+// Shutdown flushes and stops every provider.
+func (t *Tracing) Shutdown() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	providers := make([]*sdktrace.TracerProvider, 0, len(t.providers))
+	for _, provider := range t.providers {
+		providers = append(providers, provider)
+	}
+	t.providers = map[string]*sdktrace.TracerProvider{}
+	t.mu.Unlock()
+	shutdownTracerProviders(providers...)
+}
+
+func (t *Tracing) newProvider(project string, environment string, spec TracingSpec) (*sdktrace.TracerProvider, error) {
+	exporter, err := t.newExporter(context.Background(), spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter for %s: %w", spec.Endpoint, err)
+	}
+	attributes := []attribute.KeyValue{
+		semconv.ServiceName("takod"),
+		semconv.ServiceVersion(t.version),
+		attribute.String("tako.project", project),
+		attribute.String("tako.environment", environment),
+	}
+	if spec.Node != "" {
+		attributes = append(attributes, attribute.String("tako.node", spec.Node))
+	}
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, attributes...)),
+		// takod only continues traces a sampled caller started.
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.NeverSample())),
+	), nil
+}
+
+// install makes provider serve the environment; the one it replaces
+// flushes in the background.
+func (t *Tracing) install(project string, environment string, spec TracingSpec, provider *sdktrace.TracerProvider) {
+	key := logShippingKey(project, environment)
+	t.mu.Lock()
+	replaced := t.providers[key]
+	t.providers[key] = provider
+	t.specs[key] = spec
+	t.mu.Unlock()
+	if replaced != nil {
+		go shutdownTracerProviders(replaced)
+	}
+}
+
+func (t *Tracing) remove(project string, environment string) error {
+	key := logShippingKey(project, environment)
+	t.mu.Lock()
+	provider := t.providers[key]
+	delete(t.providers, key)
+	delete(t.specs, key)
+	t.mu.Unlock()
+	if provider != nil {
+		shutdownTracerProviders(provider)
+	}
+	if err := os.Remove(t.specPath(project, environment)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove tracing spec: %w", err)
+	}
+	_ = os.Remove(filepath.Join(t.dataDir, tracingDirName, project))
+	return nil
+}
+
+func (t *Tracing) provider(project string, environment string) *sdktrace.TracerProvider {
+	if t == nil || project == "" || environment == "" {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.providers[logShippingKey(project, environment)]
+}
+
+func (t *Tracing) specPath(project string, environment string) string {
+	return filepath.Join(t.dataDir, tracingDirName, project, environment+".json")
+}
+
+func (t *Tracing) persistSpec(project string, environment string, spec TracingSpec) error {
+	path := t.specPath(project, environment)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create tracing directory: %w", err)
+	}
+	data, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode tracing spec: %w", err)
+	}
+	// Collector headers can carry credentials.
+	if err := writeFileAtomic(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write tracing spec: %w", err)
+	}
+	return nil
+}
+
+func shutdownTracerProviders(providers ...*sdktrace.TracerProvider) {
+	ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+	defer cancel()
+	for _, provider := range providers {
+		if err := provider.Shutdown(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "takod tracing shutdown failed: %v\n", err)
+		}
+	}
+}
+
+// Handler starts a server span for requests that continue a sampled trace
+// of an environment with a tracing spec, named "METHOD /path". The span
+// rides the request context, so the operations below it can add children
+// with startTakodSpan.
+func (t *Tracing) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := telemetry.Extract(r.Context(), r.Header)
+		parent := trace.SpanContextFromContext(ctx)
+		if !parent.IsValid() || !parent.IsSampled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		provider := t.provider(telemetry.ScopeFromContext(ctx))
+		if provider == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, span := provider.Tracer(tracingTracerName).Start(ctx, r.Method+" "+r.URL.Path,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+			),
+		)
+		defer span.End()
+		recorder := &tracingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+		span.SetAttributes(attribute.Int("http.response.status_code", recorder.status))
+		if recorder.status >= http.StatusBadRequest {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status))
+		}
+	})
+}
+
+// startTakodSpan starts a child of the request's server span. Without one
+// the span is a no-op, so operations call it unconditionally.
+func startTakodSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return trace.SpanFromContext(ctx).TracerProvider().Tracer(tracingTracerName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// tracingResponseWriter records the response status. Exec and log streams
+// hijack or flush the connection, so both pass through.
+type tracingResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *tracingResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *tracingResponseWriter) Write(data []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *tracingResponseWriter) Flush() {
+	w.wroteHeader = true
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *tracingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	w.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+func (w *tracingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
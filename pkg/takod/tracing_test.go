@@ -0,0 +1,140 @@
+package takod
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/redentordev/tako-cli/pkg/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTestTracing(t *testing.T, dataDir string) (*Tracing, *tracetest.InMemoryExporter) {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	tracing := NewTracing(dataDir, "test")
+	tracing.newExporter = func(context.Context, TracingSpec) (sdktrace.SpanExporter, error) { return exporter, nil }
+	t.Cleanup(tracing.Shutdown)
+	return tracing, exporter
+}
+
+func tracedRequest(project string, environment string, sampled bool) *http.Request {
+	config := trace.SpanContextConfig{
+		TraceID: trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:  trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+	}
+	if sampled {
+		config.TraceFlags = trace.FlagsSampled
+	}
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(config))
+	ctx = telemetry.WithScope(ctx, project, environment)
+	request := httptest.NewRequest(http.MethodPost, "/v1/reconcile-service", nil)
+	telemetry.Inject(ctx, request.Header)
+	return request
+}
+
+func TestTracingContinuesSampledRequestsOfTracedEnvironments(t *testing.T) {
+	tracing, exporter := newTestTracing(t, t.TempDir())
+	if _, err := tracing.Apply(TracingApplyRequest{Project: "shop", Environment: "production", Spec: &TracingSpec{Endpoint: "otel.example.com:4317", Node: "web-1"}}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	handler := tracing.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, span := startTakodSpan(r.Context(), "image.pull", attribute.String("image", "ghcr.io/acme/web:v1"))
+		span.End()
+		http.Error(w, "pull failed", http.StatusBadGateway)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), tracedRequest("shop", "production", true))
+	handler.ServeHTTP(httptest.NewRecorder(), tracedRequest("shop", "production", false))
+	handler.ServeHTTP(httptest.NewRecorder(), tracedRequest("blog", "production", true))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/health", nil))
+	if err := tracing.provider("shop", "production").ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("spans = %d, want the server span and its child", len(spans))
+	}
+	child, server := spans[0], spans[1]
+	if server.Name != "POST /v1/reconcile-service" || server.SpanKind != trace.SpanKindServer || server.Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Fatalf("server span = %s kind %v parent %s", server.Name, server.SpanKind, server.Parent.SpanID())
+	}
+	if server.Status.Description != "Bad Gateway" {
+		t.Fatalf("server status = %+v", server.Status)
+	}
+	if child.Name != "image.pull" || child.Parent.SpanID() != server.SpanContext.SpanID() || child.SpanContext.TraceID() != server.SpanContext.TraceID() {
+		t.Fatalf("child span = %s parent %s", child.Name, child.Parent.SpanID())
+	}
+	node, ok := server.Resource.Set().Value("tako.node")
+	if !ok || node.AsString() != "web-1" {
+		t.Fatalf("resource tako.node = %v", node)
+	}
+}
+
+func TestTracingPersistsSpecsAndRemovesThem(t *testing.T) {
+	dataDir := t.TempDir()
+	tracing, _ := newTestTracing(t, dataDir)
+	spec := &TracingSpec{Endpoint: "otel.example.com:4317", Headers: map[string]string{"x-api-key": "secret"}}
+	if _, err := tracing.Apply(TracingApplyRequest{Project: "shop", Environment: "production", Spec: spec}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	path := filepath.Join(dataDir, tracingDirName, "shop", "production.json")
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("spec file = %v, %v", info, err)
+	}
+
+	restarted, _ := newTestTracing(t, dataDir)
+	if err := restarted.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if restarted.provider("shop", "production") == nil {
+		t.Fatal("restarted tracing has no provider for the persisted spec")
+	}
+
+	response, err := restarted.Apply(TracingApplyRequest{Project: "shop", Environment: "production"})
+	if err != nil || response.Exporting {
+		t.Fatalf("remove = %+v, %v", response, err)
+	}
+	if restarted.provider("shop", "production") != nil {
+		t.Fatal("provider survived removing the spec")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("spec file after removal: %v", err)
+	}
+}
+
+func TestTracingRejectsInvalidSpecs(t *testing.T) {
+	tracing, _ := newTestTracing(t, t.TempDir())
+	for name, spec := range map[string]TracingSpec{
+		"scheme":      {Endpoint: "https://otel.example.com:4317"},
+		"no port":     {Endpoint: "otel.example.com"},
+		"bad header":  {Endpoint: "otel.example.com:4317", Headers: map[string]string{"bad header": "x"}},
+		"control":     {Endpoint: "otel.example.com:4317", Headers: map[string]string{"x-api-key": "a\nb"}},
+		"node spaces": {Endpoint: "otel.example.com:4317", Node: "web 1"},
+	} {
+		if _, err := tracing.Apply(TracingApplyRequest{Project: "shop", Environment: "production", Spec: &spec}); err == nil {
+			t.Errorf("%s: Apply accepted %+v", name, spec)
+		}
+	}
+}
+
+func TestTracingResponseWriterKeepsStreamingInterfaces(t *testing.T) {
+	var writer http.ResponseWriter = &tracingResponseWriter{ResponseWriter: httptest.NewRecorder()}
+	if _, ok := writer.(http.Flusher); !ok {
+		t.Fatal("wrapped writer is not a Flusher")
+	}
+	if _, ok := writer.(http.Hijacker); !ok {
+		t.Fatal("wrapped writer is not a Hijacker")
+	}
+	if err := http.NewResponseController(writer).Flush(); err != nil {
+		t.Fatalf("ResponseController.Flush: %v", err)
+	}
+}
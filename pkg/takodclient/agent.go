@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/redentordev/tako-cli/pkg/nodeidentity"
+	"github.com/redentordev/tako-cli/pkg/telemetry"
 	"github.com/redentordev/tako-cli/pkg/upgradeprotocol"
 )
 
@@ -435,6 +436,7 @@ func (c *AgentClient) do(ctx context.Context, method string, endpoint string, bo
 	if err := attachOperationFenceHeader(request); err != nil {
 		return nil, err
 	}
+	telemetry.Inject(ctx, request.Header)
 	response, err := c.httpClient.Do(request)
 	if err != nil {
 		return nil, fmt.Errorf("takod request %s %s failed: %w", method, endpoint, err)
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -377,6 +378,69 @@ func JobsApplyEndpoint() string {
 	return "/v1/jobs/apply"
 }
 
+// LoggingApplyEndpoint returns the takod log shipping apply endpoint path.
+func LoggingApplyEndpoint() string {
+	return "/v1/logging/apply"
+}
+
+// MetricsExporterApplyEndpoint returns the takod metrics exporter apply
+// endpoint path.
+func MetricsExporterApplyEndpoint() string {
+	return "/v1/metrics/exporter"
+}
+
+// AlertsEndpoint returns the takod alert status endpoint path.
+func AlertsEndpoint(project string, environment string) string {
+	values := url.Values{}
+	values.Set("project", project)
+	values.Set("environment", environment)
+	return "/v1/alerts?" + values.Encode()
+}
+
+// AlertsApplyEndpoint returns the takod alert rules apply endpoint path.
+func AlertsApplyEndpoint() string {
+	return "/v1/alerts/apply"
+}
+
+// AlertSilenceEndpoint returns the takod alert silence endpoint path.
+func AlertSilenceEndpoint() string {
+	return "/v1/alerts/silence"
+}
+
+// TracingApplyEndpoint returns the takod tracing apply endpoint path.
+func TracingApplyEndpoint() string {
+	return "/v1/tracing/apply"
+}
+
+// ContainerEventsEndpoint returns the takod container event history
+// endpoint path, narrowed to service when it is set.
+func ContainerEventsEndpoint(project string, environment string, service string, limit int) string {
+	values := url.Values{}
+	values.Set("project", project)
+	values.Set("environment", environment)
+	if service != "" {
+		values.Set("service", service)
+	}
+	if limit > 0 {
+		values.Set("limit", strconv.Itoa(limit))
+	}
+	return "/v1/events?" + values.Encode()
+}
+
+// ContainerEventsApplyEndpoint returns the takod container event
+// notification apply endpoint path.
+func ContainerEventsApplyEndpoint() string {
+	return "/v1/events/apply"
+}
+
+// LoggingEndpoint returns the takod log shipping status endpoint path.
+func LoggingEndpoint(project string, environment string) string {
+	values := url.Values{}
+	values.Set("project", project)
+	values.Set("environment", environment)
+	return "/v1/logging?" + values.Encode()
+}
+
 func ExecEndpoint() string {
 	return "/v1/exec"
 }
@@ -385,6 +449,13 @@ func ProxyFileEndpoint(name string) string {
 	return "/v1/proxy-file?name=" + url.QueryEscape(name)
 }
 
+func ProxyAnalysisEndpoint(project, environment, service string, since time.Time) string {
+	query := url.Values{}
+	query.Set("service", service)
+	query.Set("since", since.UTC().Format(time.RFC3339Nano))
+	return addEndpointScope("/v1/proxy/analysis?"+query.Encode(), project, environment)
+}
+
 func CertificatesEndpoint(domain string) string {
 	if strings.TrimSpace(domain) == "" {
 		return "/v1/certs"
@@ -445,6 +516,13 @@ func EnvBundleEndpoint(project string, environment string) string {
 	return "/v1/env-bundle?" + query.Encode()
 }
 
+func SharedSecretsEndpoint(project string, environment string) string {
+	query := url.Values{}
+	query.Set("project", project)
+	query.Set("environment", environment)
+	return "/v1/shared-secrets?" + query.Encode()
+}
+
 func BackupsEndpoint(project string, environment string, volume string, backupID string) string {
 	query := url.Values{}
 	query.Set("project", project)
@@ -557,6 +635,71 @@ func LogsEndpoint(project string, environment string, service string, tail int,
 	return "/v1/logs?" + query.Encode()
 }
 
+// LogsQuery carries the server-side filters of a log query endpoint.
+type LogsQuery struct {
+	Since   time.Time
+	Until   time.Time
+	Replica int
+	Grep    []string
+	Exclude []string
+	Level   string
+	Fields  map[string]string
+}
+
+// LogsQueryEndpoint is LogsEndpoint with log query filters; takod answers it
+// with NDJSON log entries in time order.
+func LogsQueryEndpoint(project string, environment string, service string, tail int, follow bool, filters LogsQuery) string {
+	query := url.Values{}
+	query.Set("project", project)
+	query.Set("environment", environment)
+	query.Set("service", service)
+	query.Set("tail", fmt.Sprintf("%d", tail))
+	if follow {
+		query.Set("follow", "true")
+	}
+	if !filters.Since.IsZero() {
+		query.Set("since", filters.Since.UTC().Format(time.RFC3339Nano))
+	}
+	if !filters.Until.IsZero() {
+		query.Set("until", filters.Until.UTC().Format(time.RFC3339Nano))
+	}
+	if filters.Replica > 0 {
+		query.Set("replica", strconv.Itoa(filters.Replica))
+	}
+	for _, pattern := range filters.Grep {
+		query.Add("grep", pattern)
+	}
+	for _, pattern := range filters.Exclude {
+		query.Add("exclude", pattern)
+	}
+	if filters.Level != "" {
+		query.Set("level", filters.Level)
+	}
+	keys := make([]string, 0, len(filters.Fields))
+	for key := range filters.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		query.Add("field", key+"="+filters.Fields[key])
+	}
+	return "/v1/logs?" + query.Encode()
+}
+
+func JobLogsEndpoint(project string, environment string, job string, run string, follow bool) string {
+	query := url.Values{}
+	query.Set("project", project)
+	query.Set("environment", environment)
+	query.Set("job", job)
+	if run != "" {
+		query.Set("run", run)
+	}
+	if follow {
+		query.Set("follow", "true")
+	}
+	return "/v1/jobs/logs?" + query.Encode()
+}
+
 func StatsEndpoint(project string, environment string, service string, all bool) string {
 	query := url.Values{}
 	if project != "" {
@@ -574,6 +717,22 @@ func StatsEndpoint(project string, environment string, service string, all bool)
 	return "/v1/stats?" + query.Encode()
 }
 
+// MetricsHistoryEndpoint returns the takod range query path for an
+// environment's recorded usage; a zero until means now.
+func MetricsHistoryEndpoint(project string, environment string, service string, since time.Time, until time.Time) string {
+	query := url.Values{}
+	query.Set("project", project)
+	query.Set("environment", environment)
+	if service != "" {
+		query.Set("service", service)
+	}
+	query.Set("since", since.UTC().Format(time.RFC3339))
+	if !until.IsZero() {
+		query.Set("until", until.UTC().Format(time.RFC3339))
+	}
+	return "/v1/metrics/history?" + query.Encode()
+}
+
 func MetricsEndpoint(collect bool) string {
 	if !collect {
 		return "/v1/metrics"
@@ -296,6 +296,14 @@ func TestProxyFileEndpointEscapesName(t *testing.T) {
 	}
 }
 
+func TestProxyAnalysisEndpointScopesServiceWindow(t *testing.T) {
+	got := ProxyAnalysisEndpoint("demo", "production", "web", time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	want := "/v1/proxy/analysis?service=web&since=2026-01-02T03%3A04%3A05Z&environment=production&project=demo"
+	if got != want {
+		t.Fatalf("ProxyAnalysisEndpoint() = %q, want %q", got, want)
+	}
+}
+
 func TestStateEndpointEscapesQueryValues(t *testing.T) {
 	got := StateEndpoint("demo app", "prod/us", "desired")
 	want := "/v1/state?document=desired&environment=prod%2Fus&project=demo+app"
@@ -379,6 +387,33 @@ func TestLogsEndpointEscapesQueryValues(t *testing.T) {
 	}
 }
 
+func TestLogsQueryEndpointEncodesFilters(t *testing.T) {
+	got := LogsQueryEndpoint("demo", "production", "web", 100, false, LogsQuery{
+		Since:   time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Replica: 2,
+		Grep:    []string{"timeout", "db"},
+		Level:   "error",
+		Fields:  map[string]string{"user_id": "42", "route": "/login"},
+	})
+	want := "/v1/logs?environment=production&field=route%3D%2Flogin&field=user_id%3D42&grep=timeout&grep=db&level=error&project=demo&replica=2&service=web&since=2026-01-02T03%3A04%3A05Z&tail=100"
+	if got != want {
+		t.Fatalf("LogsQueryEndpoint() = %q, want %q", got, want)
+	}
+}
+
+func TestJobLogsEndpointOmitsEmptyRun(t *testing.T) {
+	got := JobLogsEndpoint("demo", "production", "report", "", false)
+	want := "/v1/jobs/logs?environment=production&job=report&project=demo"
+	if got != want {
+		t.Fatalf("JobLogsEndpoint() = %q, want %q", got, want)
+	}
+	got = JobLogsEndpoint("demo", "production", "report", "run-20260101T020000Z-1a2b3c4d", true)
+	want = "/v1/jobs/logs?environment=production&follow=true&job=report&project=demo&run=run-20260101T020000Z-1a2b3c4d"
+	if got != want {
+		t.Fatalf("JobLogsEndpoint() = %q, want %q", got, want)
+	}
+}
+
 func TestStatsEndpointEscapesQueryValues(t *testing.T) {
 	got := StatsEndpoint("demo app", "prod/us", "web api", true)
 	want := "/v1/stats?all=true&environment=prod%2Fus&project=demo+app&service=web+api"
@@ -387,6 +422,34 @@ func TestStatsEndpointEscapesQueryValues(t *testing.T) {
 	}
 }
 
+func TestMetricsHistoryEndpointFormatsRange(t *testing.T) {
+	since := time.Date(2026, 7, 6, 12, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+	got := MetricsHistoryEndpoint("demo", "production", "api", since, time.Time{})
+	want := "/v1/metrics/history?environment=production&project=demo&service=api&since=2026-07-06T10%3A00%3A00Z"
+	if got != want {
+		t.Fatalf("MetricsHistoryEndpoint() = %q, want %q", got, want)
+	}
+}
+
+func TestAlertsEndpointScopesEnvironment(t *testing.T) {
+	got := AlertsEndpoint("demo", "production")
+	want := "/v1/alerts?environment=production&project=demo"
+	if got != want {
+		t.Fatalf("AlertsEndpoint() = %q, want %q", got, want)
+	}
+}
+
+func TestContainerEventsEndpointNarrowsService(t *testing.T) {
+	got := ContainerEventsEndpoint("demo", "production", "api", 20)
+	want := "/v1/events?environment=production&limit=20&project=demo&service=api"
+	if got != want {
+		t.Fatalf("ContainerEventsEndpoint() = %q, want %q", got, want)
+	}
+	if got := ContainerEventsEndpoint("demo", "production", "", 0); got != "/v1/events?environment=production&project=demo" {
+		t.Fatalf("ContainerEventsEndpoint() without filters = %q", got)
+	}
+}
+
 func TestMetricsEndpointWithCollect(t *testing.T) {
 	got := MetricsEndpoint(true)
 	want := "/v1/metrics?collect=true"
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/redentordev/tako-cli/pkg/takoapi/ptystream"
+	"github.com/redentordev/tako-cli/pkg/telemetry"
 )
 
 // upgradeHandshakeTimeout bounds the HTTP request/response exchange before
@@ -87,6 +88,7 @@ func UpgradeStream(ctx context.Context, dialer UnixSocketDialer, socket string,
 	if err := attachOperationFenceHeader(request); err != nil {
 		return nil, err
 	}
+	telemetry.Inject(ctx, request.Header)
 	if err := request.Write(conn); err != nil {
 		return nil, fmt.Errorf("takod upgrade request %s failed: %w", endpoint, err)
 	}
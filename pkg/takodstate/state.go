@@ -95,6 +95,7 @@ type DesiredService struct {
 	Ulimits         map[string]config.UlimitConfig `json:"ulimits,omitempty"`
 	ShmSize         string                         `json:"shmSize,omitempty"`
 	SecretRefs      []string                       `json:"secretRefs,omitempty"`
+	SecretsDelivery *config.SecretsDeliveryConfig  `json:"secretsDelivery,omitempty"`
 	DependsOn       []string                       `json:"dependsOn,omitempty"`
 	HealthCheck     config.HealthCheckConfig       `json:"healthCheck,omitempty"`
 	DeployStrategy  string                         `json:"deployStrategy,omitempty"`
@@ -821,6 +822,7 @@ func sanitizeDesiredService(serviceName string, service config.ServiceConfig, im
 		Ulimits:         cloneUlimits(service.Ulimits),
 		ShmSize:         service.ShmSize,
 		SecretRefs:      sortedCopy(service.Secrets),
+		SecretsDelivery: service.SecretsDelivery,
 		DependsOn:       sortedCopy(service.DependsOn),
 		HealthCheck:     service.HealthCheck,
 		DeployStrategy:  service.Deploy.Strategy,
@@ -0,0 +1,61 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc/credentials"
+)
+
+const maxOTLPHeaders = 16
+
+var otlpHeaderNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]{0,63}$`)
+
+// ValidateOTLPExporter checks a collector endpoint (host:port, no scheme)
+// and the headers sent with each export.
+func ValidateOTLPExporter(endpoint string, headers map[string]string) error {
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil || host == "" || strings.Contains(endpoint, "/") {
+		return fmt.Errorf("endpoint %q must be host:port", endpoint)
+	}
+	if number, err := strconv.Atoi(port); err != nil || number < 1 || number > 65535 {
+		return fmt.Errorf("endpoint %q has an invalid port", endpoint)
+	}
+	if len(headers) > maxOTLPHeaders {
+		return fmt.Errorf("at most %d headers are allowed", maxOTLPHeaders)
+	}
+	for name, value := range headers {
+		if !otlpHeaderNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		if strings.IndexFunc(value, func(r rune) bool { return r < 0x20 || r == 0x7f }) >= 0 {
+			return fmt.Errorf("header %q contains control characters", name)
+		}
+	}
+	return nil
+}
+
+// NewOTLPExporter creates a gRPC OTLP span exporter for endpoint
+// (host:port). TLS uses the system roots unless insecure is set.
+func NewOTLPExporter(ctx context.Context, endpoint string, insecure bool, headers map[string]string) (sdktrace.SpanExporter, error) {
+	options := []otlptracegrpc.Option{
+		otlptracegrpc.WithEndpoint(endpoint),
+	}
+	if insecure {
+		options = append(options, otlptracegrpc.WithInsecure())
+	} else {
+		options = append(options, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(&tls.Config{})))
+	}
+	if len(headers) > 0 {
+		options = append(options, otlptracegrpc.WithHeaders(headers))
+	}
+	return otlptrace.New(ctx, otlptracegrpc.NewClient(options...))
+}
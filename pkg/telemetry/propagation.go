@@ -0,0 +1,86 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Baggage keys carrying the deployment scope across the takod socket. takod
+// picks the project/environment's exporter from them, so a node shared by
+// several projects exports each trace to the collector its project chose.
+const (
+	BaggageProject     = "tako.project"
+	BaggageEnvironment = "tako.environment"
+)
+
+// propagator speaks W3C traceparent/tracestate plus W3C baggage.
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Propagator returns the W3C trace context and baggage propagator used on
+// takod requests.
+func Propagator() propagation.TextMapPropagator {
+	return propagator
+}
+
+// Inject writes the span context and baggage in ctx into header. Without a
+// recording span nothing but existing baggage is written, so untraced
+// commands send the same requests as before.
+func Inject(ctx context.Context, header http.Header) {
+	if ctx == nil || header == nil {
+		return
+	}
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// Extract returns ctx with the remote span context and baggage found in
+// header.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return propagator.Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// WithScope records project and environment as baggage on ctx.
+func WithScope(ctx context.Context, project, environment string) context.Context {
+	bag := baggage.FromContext(ctx)
+	for key, value := range map[string]string{BaggageProject: project, BaggageEnvironment: environment} {
+		if value == "" {
+			continue
+		}
+		member, err := baggage.NewMemberRaw(key, value)
+		if err != nil {
+			continue
+		}
+		if next, err := bag.SetMember(member); err == nil {
+			bag = next
+		}
+	}
+	return baggage.ContextWithBaggage(ctx, bag)
+}
+
+// ScopeFromContext returns the project and environment WithScope recorded,
+// either locally or as extracted from a request.
+func ScopeFromContext(ctx context.Context) (project, environment string) {
+	bag := baggage.FromContext(ctx)
+	return bag.Member(BaggageProject).Value(), bag.Member(BaggageEnvironment).Value()
+}
+
+// Detach returns a background context carrying only ctx's span and
+// baggage: requests made with it join the trace but not ctx's cancellation
+// or other values.
+func Detach(ctx context.Context) context.Context {
+	detached := trace.ContextWithSpan(context.Background(), trace.SpanFromContext(ctx))
+	return baggage.ContextWithBaggage(detached, baggage.FromContext(ctx))
+}
+
+// EndSpan marks span failed when err is non-nil and ends it.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
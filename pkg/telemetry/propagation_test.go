@@ -0,0 +1,44 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInjectExtractCarriesTraceAndScope(t *testing.T) {
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx, cancel := context.WithCancel(WithScope(trace.ContextWithSpanContext(context.Background(), parent), "shop", "production"))
+	cancel()
+
+	header := http.Header{}
+	Inject(Detach(ctx), header)
+	if got, want := header.Get("traceparent"), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"; got != want {
+		t.Fatalf("traceparent = %q, want %q", got, want)
+	}
+
+	extracted := Extract(context.Background(), header)
+	if got := trace.SpanContextFromContext(extracted); !got.IsRemote() || got.TraceID() != parent.TraceID() || !got.IsSampled() {
+		t.Fatalf("extracted span context = %+v", got)
+	}
+	if project, environment := ScopeFromContext(extracted); project != "shop" || environment != "production" {
+		t.Fatalf("scope = %q/%q", project, environment)
+	}
+	if Detach(ctx).Err() != nil {
+		t.Fatal("Detach kept the cancellation")
+	}
+}
+
+func TestInjectWithoutSpanWritesNoTraceparent(t *testing.T) {
+	header := http.Header{}
+	Inject(context.Background(), header)
+	if len(header) != 0 {
+		t.Fatalf("header = %v, want none", header)
+	}
+}
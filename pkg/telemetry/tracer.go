@@ -4,7 +4,6 @@ package telemetry
 
 import (
 	"context"
-	"crypto/tls"
 	"os"
 	"strings"
 	"sync"
@@ -12,15 +11,12 @@ import (
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 	"go.opentelemetry.io/otel/trace"
 	"go.opentelemetry.io/otel/trace/noop"
-	"google.golang.org/grpc/credentials"
 )
 
 var (
@@ -42,6 +38,8 @@ type Config struct {
 	OTLPEndpoint string
 	// OTLPInsecure disables TLS for local or explicitly trusted collectors.
 	OTLPInsecure bool
+	// OTLPHeaders are sent with every export, e.g. a collector API key
+	OTLPHeaders map[string]string
 	// Debug enables stdout trace exporter for debugging
 	Debug bool
 }
@@ -99,9 +97,11 @@ func initTracer(cfg Config) error {
 	var exporter sdktrace.SpanExporter
 
 	if cfg.Debug {
-		// Use stdout exporter for debugging
+		// Use stdout exporter for debugging, on stderr so it never mixes
+		// with machine-readable output
 		exporter, err = stdouttrace.New(
 			stdouttrace.WithPrettyPrint(),
+			stdouttrace.WithWriter(os.Stderr),
 		)
 		if err != nil {
 			return err
@@ -111,18 +111,7 @@ func initTracer(cfg Config) error {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		options := []otlptracegrpc.Option{
-			otlptracegrpc.WithEndpoint(cfg.OTLPEndpoint),
-		}
-		if cfg.OTLPInsecure {
-			options = append(options, otlptracegrpc.WithInsecure())
-		} else {
-			options = append(options, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(&tls.Config{})))
-		}
-
-		client := otlptracegrpc.NewClient(options...)
-
-		exporter, err = otlptrace.New(ctx, client)
+		exporter, err = NewOTLPExporter(ctx, cfg.OTLPEndpoint, cfg.OTLPInsecure, cfg.OTLPHeaders)
 		if err != nil {
 			return err
 		}
@@ -135,8 +124,9 @@ func initTracer(cfg Config) error {
 		sdktrace.WithSampler(sdktrace.AlwaysSample()), // Sample everything for CLI tool
 	)
 
-	// Set global tracer provider
+	// Set global tracer provider and the W3C propagator takod requests carry
 	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(propagator)
 
 	// Create tracer
 	tracer = tracerProvider.Tracer(cfg.ServiceName)
@@ -190,6 +180,17 @@ func TraceSSH(ctx context.Context, host, command string) (context.Context, trace
 	)
 }
 
+// TraceDeployment starts the root span of one deploy and records the
+// project and environment as baggage so takod can attribute its spans.
+func TraceDeployment(ctx context.Context, project, environment string) (context.Context, trace.Span) {
+	return StartSpan(WithScope(ctx, project, environment), "deploy",
+		trace.WithAttributes(
+			attribute.String("deploy.project", project),
+			attribute.String("deploy.environment", environment),
+		),
+	)
+}
+
 // TraceDeploy starts a span for deployment operations
 func TraceDeploy(ctx context.Context, project, service, environment string) (context.Context, trace.Span) {
 	return StartSpan(ctx, "deploy.service",